@@ -0,0 +1,126 @@
+// Authenticates and authorizes the principals using a shared XNYSS tree, and
+// keeps an append-only audit log of the operations they perform. It is meant to
+// be used by a signing service in front of its trees: requests are
+// authenticated with an API key or a TLS client certificate, and are passed to
+// a Guard, which checks the principal's roles and logs the operation before
+// returning its result.
+package access
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unknown credentials")
+	ErrUnauthorized    = errors.New("principal is not allowed to perform this operation")
+)
+
+// A set of roles. Roles do not imply each other, so an administrator that may
+// also sign must have both RoleAdmin and RoleSign.
+type Role uint8
+
+const (
+	// May create signatures.
+	RoleSign Role = 1 << iota
+	// May confirm nodes.
+	RoleConfirm
+	// May create backups and change tree settings.
+	RoleAdmin
+)
+
+// An authenticated user of the service.
+type Principal struct {
+	Name  string
+	Roles Role
+}
+
+// Returns ErrUnauthorized unless p has all roles in r.
+func (p *Principal) Authorize(r Role) error {
+	if p == nil || p.Roles&r != r {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+// Maps credentials to principals. Only digests of the credentials are stored.
+// It is safe for concurrent use.
+type Authenticator struct {
+	mu    sync.RWMutex
+	keys  map[[32]byte]*Principal
+	certs map[[32]byte]*Principal
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		keys:  make(map[[32]byte]*Principal),
+		certs: make(map[[32]byte]*Principal),
+	}
+}
+
+// Registers an API key for the principal p.
+func (a *Authenticator) AddKey(key string, p Principal) {
+	a.mu.Lock()
+	a.keys[sha256.Sum256([]byte(key))] = &p
+	a.mu.Unlock()
+}
+
+// Registers a client certificate, in DER encoding, for the principal p.
+func (a *Authenticator) AddCertificate(der []byte, p Principal) {
+	a.mu.Lock()
+	a.certs[sha256.Sum256(der)] = &p
+	a.mu.Unlock()
+}
+
+// Removes an API key.
+func (a *Authenticator) RemoveKey(key string) {
+	a.mu.Lock()
+	delete(a.keys, sha256.Sum256([]byte(key)))
+	a.mu.Unlock()
+}
+
+// Returns the principal of the API key. Keys are compared by digest, so the
+// lookup does not leak the key through timing.
+func (a *Authenticator) Key(key string) (*Principal, error) {
+	digest := sha256.Sum256([]byte(key))
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var found *Principal
+	for d, p := range a.keys {
+		if subtle.ConstantTimeCompare(d[:], digest[:]) == 1 {
+			found = p
+		}
+	}
+
+	if found == nil {
+		return nil, ErrUnauthenticated
+	}
+
+	return found, nil
+}
+
+// Returns the principal of the verified client certificate of a TLS
+// connection. The server must require and verify client certificates, e.g.
+// with tls.RequireAndVerifyClientCert.
+func (a *Authenticator) TLS(state *tls.ConnectionState) (*Principal, error) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	digest := sha256.Sum256(state.VerifiedChains[0][0].Raw)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if p, ok := a.certs[digest]; ok {
+		return p, nil
+	}
+
+	return nil, ErrUnauthenticated
+}
@@ -0,0 +1,99 @@
+package access
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+func newTree(t *testing.T) *xnyss.NYTree {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+
+	return xnyss.New(seeds[:32], seeds[32:], false)
+}
+
+func TestGuard(t *testing.T) {
+	auth := NewAuthenticator()
+	auth.AddKey("signer-key", Principal{Name: "signer", Roles: RoleSign})
+	auth.AddKey("oracle-key", Principal{Name: "oracle", Roles: RoleConfirm})
+
+	// 1 - Authentication
+	if _, err := auth.Key("unknown-key"); err != ErrUnauthenticated {
+		t.Fatal("Unknown key was authenticated, err was", err)
+	}
+	signer, err := auth.Key("signer-key")
+	if err != nil || signer.Name != "signer" {
+		t.Fatal("Failed to authenticate signer -", err)
+	}
+	oracle, err := auth.Key("oracle-key")
+	if err != nil {
+		t.Fatal("Failed to authenticate oracle -", err)
+	}
+
+	// 2 - Authorization
+	buf := &bytes.Buffer{}
+	guard := NewGuard(newTree(t), NewLog(buf))
+
+	if _, err := guard.Sign(oracle, make([]byte, 32), make([]byte, 32)); err != ErrUnauthorized {
+		t.Fatal("Oracle was allowed to sign, err was", err)
+	}
+	sig, err := guard.Sign(signer, make([]byte, 32), make([]byte, 32))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := guard.Confirm(oracle, sig.ChildHashes[0], xnyss.ConfirmsRequired); err != nil {
+		t.Fatal("Failed to confirm -", err)
+	}
+	if _, err := guard.Backup(signer, 1); err != ErrUnauthorized {
+		t.Fatal("Signer was allowed to create a backup, err was", err)
+	}
+
+	// 3 - Every request is logged, and the log can be resumed
+	log := buf.String()
+	last, err := Verify(strings.NewReader(log))
+	if err != nil {
+		t.Fatal("Failed to verify log -", err)
+	}
+	if last.Seq != 3 || last.Principal != "signer" || last.Error != ErrUnauthorized.Error() {
+		t.Fatal("Invalid last record", last)
+	}
+
+	resumed, err := ResumeLog(strings.NewReader(log), buf)
+	if err != nil {
+		t.Fatal("Failed to resume log -", err)
+	}
+	if err := resumed.Append(Record{Op: "test"}); err != nil {
+		t.Fatal("Failed to append record -", err)
+	}
+	if _, err := Verify(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal("Failed to verify resumed log -", err)
+	}
+
+	// 4 - Tampering is detected
+	tampered := strings.Replace(log, `"principal":"oracle"`, `"principal":"signer"`, 1)
+	if _, err := Verify(strings.NewReader(tampered)); err != ErrLogTampered {
+		t.Fatal("Tampered log was verified, err was", err)
+	}
+	lines := strings.SplitAfter(log, "\n")
+	if _, err := Verify(strings.NewReader(lines[0] + lines[2])); err != ErrLogTampered {
+		t.Fatal("Truncated log was verified, err was", err)
+	}
+
+	// 5 - Records take their time from the clock of the guard
+	buf.Reset()
+	guard = NewGuard(newTree(t), NewLog(buf))
+	guard.SetClock(xnyss.NewManualClock(xnyss.HarnessEpoch))
+	if _, err := guard.Sign(oracle, make([]byte, 32), make([]byte, 32)); err != ErrUnauthorized {
+		t.Fatal("Oracle was allowed to sign, err was", err)
+	}
+	last, err = Verify(bytes.NewReader(buf.Bytes()))
+	if err != nil || !last.Time.Equal(xnyss.HarnessEpoch) {
+		t.Fatal("Record does not use the clock of the guard", last, err)
+	}
+}
@@ -0,0 +1,125 @@
+package access
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLogTampered = errors.New("audit log has been modified")
+)
+
+// A single entry of the audit log. Every record includes the hash of the
+// previous record, so records can not be removed, reordered or changed without
+// invalidating the rest of the log.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	Op        string    `json:"op"`
+	TreeID    string    `json:"tree_id"`
+	// Hex encoded message and txid of signatures, or public key hash of
+	// confirmations.
+	Message string `json:"message,omitempty"`
+	Txid    string `json:"txid,omitempty"`
+	// Empty if the operation succeeded.
+	Error string `json:"error,omitempty"`
+	Prev  string `json:"prev"`
+}
+
+// Returns the hash of the record r, used as Prev of the next record.
+func (r *Record) hash() string {
+	b, _ := json.Marshal(r)
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// An append-only audit log, writing one JSON record per line. It is safe for
+// concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev string
+}
+
+// Creates a log that appends records to w. To continue an existing log, use
+// ResumeLog instead.
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// Verifies the existing log in r, and returns a log appending records to w
+// that continues it.
+func ResumeLog(r io.Reader, w io.Writer) (*Log, error) {
+	last, err := Verify(r)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{w: w}
+	if last != nil {
+		l.seq = last.Seq + 1
+		l.prev = last.hash()
+	}
+
+	return l, nil
+}
+
+// Appends the record r, setting its sequence number and previous hash. If
+// w returns an error, the record is considered not written.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq
+	r.Prev = l.prev
+	b, err := json.Marshal(&r)
+	if err != nil {
+		return err
+	}
+
+	if _, err := l.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	l.seq++
+	l.prev = r.hash()
+	return nil
+}
+
+// Checks the hash chain of the log in r, and returns its last record (nil if
+// the log is empty). Returns ErrLogTampered if a record was changed, removed or
+// reordered.
+func Verify(r io.Reader) (*Record, error) {
+	var last *Record
+	prev := ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for seq := uint64(0); scanner.Scan(); seq++ {
+		rec := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return nil, ErrLogTampered
+		}
+
+		if rec.Seq != seq || rec.Prev != prev {
+			return nil, ErrLogTampered
+		}
+
+		prev = rec.hash()
+		last = rec
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return last, nil
+}
@@ -0,0 +1,179 @@
+package access
+
+import (
+	"encoding/hex"
+	"sync"
+
+	"github.com/Re0h/xnyss"
+)
+
+// Wraps a tree, authorizing and logging every operation. Operations are
+// serialised, so the log records them in the order they are applied.
+type Guard struct {
+	mu    sync.Mutex
+	tree  *xnyss.NYTree
+	log   *Log
+	clock xnyss.Clock
+}
+
+func NewGuard(tree *xnyss.NYTree, log *Log) *Guard {
+	return &Guard{tree: tree, log: log}
+}
+
+// Sets the clock of the times of audit records, or xnyss.SystemClock if c is
+// nil.
+func (g *Guard) SetClock(c xnyss.Clock) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.clock = c
+}
+
+// Signs the message for principal p, which requires RoleSign. The signature is
+// only returned if the request was logged.
+func (g *Guard) Sign(p *Principal, msg, txid []byte) (*xnyss.Signature, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, xnyss.OpSign)
+	rec.Message = hex.EncodeToString(msg)
+	rec.Txid = hex.EncodeToString(txid)
+
+	var sig *xnyss.Signature
+	err := p.Authorize(RoleSign)
+	if err == nil {
+		sig, err = g.tree.Sign(msg, txid)
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return nil, err
+	}
+
+	return sig, err
+}
+
+// Confirms a node for principal p, which requires RoleConfirm.
+func (g *Guard) Confirm(p *Principal, pkh []byte, confirms uint8) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, xnyss.OpConfirm)
+	rec.Message = hex.EncodeToString(pkh)
+
+	err := p.Authorize(RoleConfirm)
+	if err == nil {
+		g.tree.Confirm(pkh, confirms)
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// Returns the number of signatures the tree can create for txid (see
+// xnyss.NYTree.Available) for principal p, which may hold any role.
+func (g *Guard) Available(p *Principal, txid []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, "available")
+	rec.Txid = hex.EncodeToString(txid)
+
+	var n int
+	err := p.Authorize(0)
+	if err == nil {
+		n = g.tree.Available(txid)
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return 0, err
+	}
+
+	return n, err
+}
+
+// Returns the public key hashes of the unconfirmed nodes of the tree for
+// principal p, which may hold any role.
+func (g *Guard) Unconfirmed(p *Principal) ([][]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, "unconfirmed")
+
+	var pkhs [][]byte
+	err := p.Authorize(0)
+	if err == nil {
+		pkhs = g.tree.Unconfirmed()
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return nil, err
+	}
+
+	return pkhs, err
+}
+
+// Creates a backup of count nodes of the tree for principal p, which requires
+// RoleAdmin.
+func (g *Guard) Backup(p *Principal, count int) (*xnyss.NYTree, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, "backup")
+
+	var backup *xnyss.NYTree
+	err := p.Authorize(RoleAdmin)
+	if err == nil {
+		backup, err = g.tree.Backup(count)
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return nil, err
+	}
+
+	return backup, err
+}
+
+// Runs f with the tree for principal p, which requires RoleAdmin. The operation
+// is logged under the name op.
+func (g *Guard) Admin(p *Principal, op string, f func(t *xnyss.NYTree) error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := g.record(p, op)
+	err := p.Authorize(RoleAdmin)
+	if err == nil {
+		err = f(g.tree)
+	}
+
+	if err := g.append(rec, err); err != nil {
+		return err
+	}
+
+	return err
+}
+
+func (g *Guard) record(p *Principal, op string) Record {
+	clock := g.clock
+	if clock == nil {
+		clock = xnyss.SystemClock
+	}
+
+	rec := Record{Time: clock.Now().UTC(), Op: op, TreeID: g.tree.ID()}
+	if p != nil {
+		rec.Principal = p.Name
+	}
+
+	return rec
+}
+
+// Logs the record with the result of its operation.
+func (g *Guard) append(rec Record, result error) error {
+	if result != nil {
+		rec.Error = result.Error()
+	}
+
+	return g.log.Append(rec)
+}
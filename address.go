@@ -0,0 +1,109 @@
+package xnyss
+
+import (
+	"encoding/binary"
+
+	wotsp "github.com/Re0h/xnyss/wotsp256"
+)
+
+// The position of a node in its tree: its depth, and its index among the nodes
+// at that depth. The root is at depth 0, index 0, and child i of the node at
+// index p (in the order the children are derived, not the canonical order of
+// the child hashes) has index p*branches+i. Indices wrap around at 2^64.
+type Position struct {
+	Depth uint32
+	Index uint64
+}
+
+// Enables position addressing for the fresh tree t: the WOTS+ keys of all nodes
+// are derived using an address that encodes their position, with the depth in
+// the OTS field and the index in the tree field. Signatures then carry the
+// position of the node that created them (see Signature.Position), so tooling
+// can tell where in the tree a key lives, and keys at different positions are
+// domain separated even if their seeds were to collide.
+//
+// Trees created by New are addressed already. Only the keys of trees loaded
+// from states written before addressing was the default use the all-zero
+// address, and keep doing so unless addressing is enabled while they are
+// fresh.
+//
+// Verifying an addressed signature requires its position, so such signatures
+// must be transferred using Signature.Envelope rather than Signature.Bytes. The
+// root has an all-zero address either way, so enabling addressing does not
+// change the long-term public key. Returns ErrTreeNotFresh if t was used.
+func (t *NYTree) EnableAddressing() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.addressed {
+		return nil
+	}
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+
+	t.addressed = true
+	t.nodes[0].addressed = true
+	t.epoch++
+
+	return nil
+}
+
+// Returns whether position addressing is enabled for the tree t.
+func (t *NYTree) Addressed() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.addressed
+}
+
+// Returns the position of the node that created the signature sig, if it was
+// created by a tree with position addressing. Signatures decoded with
+// NewSignature never have one, since Bytes does not include it.
+func (sig *Signature) Position() (p Position, ok bool) {
+	if sig.position == nil {
+		return Position{}, false
+	}
+
+	return *sig.position, true
+}
+
+// Returns the WOTS+ address of the key at position p, or the all-zero address
+// if p is nil.
+func (p *Position) address() *wotsp.Address {
+	adrs := &wotsp.Address{}
+	if p != nil {
+		adrs.SetOTS(p.Depth)
+		adrs.SetTree(p.Index)
+	}
+
+	return adrs
+}
+
+// Returns the position of the node n, or nil if the node is not addressed.
+func (n *nyNode) position() *Position {
+	if !n.addressed {
+		return nil
+	}
+
+	return &Position{Depth: n.depth, Index: n.index}
+}
+
+func encodePosition(p Position) []byte {
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[:], p.Depth)
+	binary.BigEndian.PutUint64(b[4:], p.Index)
+
+	return b[:]
+}
+
+func decodePosition(b []byte) (*Position, error) {
+	if len(b) != 12 {
+		return nil, ErrFieldInvalid
+	}
+
+	return &Position{
+		Depth: binary.BigEndian.Uint32(b),
+		Index: binary.BigEndian.Uint64(b[4:]),
+	}, nil
+}
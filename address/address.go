@@ -0,0 +1,119 @@
+// Derives addresses from XNYSS long-term public keys, for UTXO-based chains
+// that lock outputs to the hash of a key, the way P2PKH does.
+//
+// An address is the bech32m encoding (BIP 350) of an address version and a
+// 32-byte program: the SHA-256 digest of the long-term public key, separated
+// from other uses of the hash by a domain prefix. Unlike hash160, the program
+// is not truncated to 160 bits, which would leave only 80 bits of collision
+// resistance to a scheme that is otherwise post-quantum.
+//
+// An output locked to an address is spent by a signature chain of the key:
+// the root public key is recomputed from the first signature of the chain, so
+// the spend does not need to carry it, see VerifyAgainstAddress.
+package address
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrInvalidAddress  = errors.New("invalid XNYSS address")
+	ErrAddressChecksum = errors.New("XNYSS address checksum mismatch")
+	ErrAddressVersion  = errors.New("unsupported XNYSS address version")
+	ErrAddressMismatch = errors.New("signature chain does not belong to the address")
+)
+
+// The version of the addresses created by the package.
+const Version = 0
+
+// The length of the program of an address.
+const ProgramLen = sha256.Size
+
+// Domain separation prefix of address programs.
+const programDomain = "xnyss address"
+
+// Returns the program of the address of the long-term public key rootPubKey.
+func Program(rootPubKey []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(programDomain))
+	h.Write(rootPubKey)
+
+	return h.Sum(nil)
+}
+
+// Returns the address of the long-term public key rootPubKey, using the human
+// readable part hrp, e.g. the prefix of the chain.
+func FromPublicKey(hrp string, rootPubKey []byte) (string, error) {
+	return Encode(hrp, Program(rootPubKey))
+}
+
+// Encodes the address holding program, using the human readable part hrp.
+// Returns ErrInvalidAddress if hrp is not a valid bech32 prefix, or program is
+// not ProgramLen bytes long.
+func Encode(hrp string, program []byte) (string, error) {
+	if len(program) != ProgramLen || !validHRP(hrp) {
+		return "", ErrInvalidAddress
+	}
+
+	data := append([]byte{Version}, convertBits(program, 8, 5)...)
+
+	return bech32mEncode(strings.ToLower(hrp), data), nil
+}
+
+// Decodes the address addr into its human readable part and program. Returns
+// ErrAddressChecksum if its checksum does not match, ErrAddressVersion if it
+// has an unknown version, and ErrInvalidAddress if it is malformed otherwise.
+func Decode(addr string) (hrp string, program []byte, err error) {
+	hrp, data, err := bech32mDecode(addr)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) < 1 {
+		return "", nil, ErrInvalidAddress
+	}
+	if data[0] != Version {
+		return "", nil, ErrAddressVersion
+	}
+
+	program, ok := convertBitsStrict(data[1:])
+	if !ok || len(program) != ProgramLen {
+		return "", nil, ErrInvalidAddress
+	}
+
+	return hrp, program, nil
+}
+
+// Verifies that the last signature of chain signs msg under the long-term key
+// of the address addr, like xnyss.VerifyChain. The root public key is
+// recomputed from the first signature of the chain, which must be created by
+// the root, and returns ErrAddressMismatch if it does not belong to addr.
+func VerifyAgainstAddress(addr string, chain []*xnyss.Signature, msg []byte) error {
+	_, program, err := Decode(addr)
+	if err != nil {
+		return err
+	}
+	if len(chain) == 0 {
+		return xnyss.ErrChainEmpty
+	}
+	if chain[0] == nil {
+		return xnyss.ErrChainBroken
+	}
+
+	// The public key of a chain is only known after verifying its first
+	// signature, so recompute it from that signature and check the rest
+	// against it
+	rootPubKey, err := chain[0].PublicKey()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(Program(rootPubKey), program) {
+		return ErrAddressMismatch
+	}
+
+	return xnyss.VerifyChain(rootPubKey, chain, msg)
+}
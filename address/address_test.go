@@ -0,0 +1,95 @@
+package address
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+func TestBech32m(t *testing.T) {
+	// 1 - Valid strings of BIP 350 decode
+	for _, s := range []string{
+		"A1LQFN3A",
+		"a1lqfn3a",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+		"?1v759aa",
+	} {
+		hrp, data, err := bech32mDecode(s)
+		if err != nil {
+			t.Fatal("Failed to decode", s, "-", err)
+		}
+		if bech32mEncode(hrp, data) != strings.ToLower(s) {
+			t.Fatal("Failed to encode", s)
+		}
+	}
+
+	// 2 - Invalid strings are refused
+	for _, s := range []string{
+		"qyrz8wqd2c9m",  // no separator
+		"1qyrz8wqd2c9m", // empty human readable part
+		"A1G7SGD8",      // checksum of bech32, not bech32m
+		"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+		"aBcDeF1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx", // mixed case
+	} {
+		if _, _, err := bech32mDecode(s); err == nil {
+			t.Fatal("Decoded invalid string", s)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree := xnyss.New(seeds[:32], seeds[32:], false)
+	msg := make([]byte, 32)
+
+	// 1 - Addresses encode the program of the public key
+	addr, err := FromPublicKey("xn", tree.PublicKey())
+	if err != nil {
+		t.Fatal("Failed to derive address -", err)
+	}
+	hrp, program, err := Decode(addr)
+	if err != nil || hrp != "xn" || !bytes.Equal(program, Program(tree.PublicKey())) {
+		t.Fatal("Failed to decode address", addr, err)
+	}
+	if _, _, err := Decode(addr[:len(addr)-1] + "q"); err != ErrAddressChecksum {
+		t.Fatal("Decoded address with invalid checksum, err was", err)
+	}
+	if _, err := Encode("xn", program[1:]); err != ErrInvalidAddress {
+		t.Fatal("Encoded invalid program, err was", err)
+	}
+
+	// 2 - Chains of the key verify against the address
+	rootSig, err := tree.Sign(make([]byte, 32), xnyss.Txid("address", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Confirm(rootSig.ChildHashes[0], xnyss.ConfirmsRequired)
+	msg[0] = 1
+	sig, err := tree.Sign(msg, xnyss.Txid("address", []byte{1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyAgainstAddress(addr, []*xnyss.Signature{rootSig, sig}, msg); err != nil {
+		t.Fatal("Failed to verify against address -", err)
+	}
+	pkh, err := sig.PublicKeyHash()
+	if err != nil || !bytes.Equal(pkh, rootSig.ChildHashes[0]) && !bytes.Equal(pkh, rootSig.ChildHashes[1]) {
+		t.Fatal("Public key hash does not link to the parent", err)
+	}
+
+	// 3 - Chains of other keys, and other messages, do not
+	other, _ := FromPublicKey("xn", make([]byte, len(tree.PublicKey())))
+	if err := VerifyAgainstAddress(other, []*xnyss.Signature{rootSig, sig}, msg); err != ErrAddressMismatch {
+		t.Fatal("Verified against other address, err was", err)
+	}
+	if err := VerifyAgainstAddress(addr, []*xnyss.Signature{rootSig, sig}, make([]byte, 32)); err != xnyss.ErrChainMessage {
+		t.Fatal("Verified other message, err was", err)
+	}
+}
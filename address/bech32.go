@@ -0,0 +1,146 @@
+package address
+
+import "strings"
+
+// The characters of the bech32 data part, by value.
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// The constant of bech32m checksums, see BIP 350.
+const bech32mConst = 0x2bc830a3
+
+// The maximum length of a bech32 string.
+const maxLen = 90
+
+// Returns the BCH checksum state of values, see BIP 173.
+func polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// Expands the human readable part for checksum computation.
+func hrpExpand(hrp string) []byte {
+	out := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]>>5)
+	}
+	out = append(out, 0)
+	for i := 0; i < len(hrp); i++ {
+		out = append(out, hrp[i]&31)
+	}
+
+	return out
+}
+
+// Reports whether hrp is a valid human readable part: 1 to 83 printable ASCII
+// characters, not mixing upper and lower case.
+func validHRP(hrp string) bool {
+	if len(hrp) < 1 || len(hrp) > 83 || (strings.ToLower(hrp) != hrp && strings.ToUpper(hrp) != hrp) {
+		return false
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Encodes the 5-bit values data with the lower case human readable part hrp.
+func bech32mEncode(hrp string, data []byte) string {
+	values := append(hrpExpand(hrp), data...)
+	mod := polymod(append(values, 0, 0, 0, 0, 0, 0)) ^ bech32mConst
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range data {
+		sb.WriteByte(charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		sb.WriteByte(charset[(mod>>uint(5*(5-i)))&31])
+	}
+
+	return sb.String()
+}
+
+// Decodes the bech32m string s into its lower case human readable part and
+// 5-bit values, without the checksum.
+func bech32mDecode(s string) (string, []byte, error) {
+	if len(s) > maxLen || (strings.ToLower(s) != s && strings.ToUpper(s) != s) {
+		return "", nil, ErrInvalidAddress
+	}
+	s = strings.ToLower(s)
+
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || sep+7 > len(s) || !validHRP(s[:sep]) {
+		return "", nil, ErrInvalidAddress
+	}
+
+	hrp := s[:sep]
+	data := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(charset, s[i])
+		if v < 0 {
+			return "", nil, ErrInvalidAddress
+		}
+		data = append(data, byte(v))
+	}
+
+	if polymod(append(hrpExpand(hrp), data...)) != bech32mConst {
+		return "", nil, ErrAddressChecksum
+	}
+
+	return hrp, data[:len(data)-6], nil
+}
+
+// Regroups the bits of data from groups of from bits into groups of to bits,
+// padding the last group with zeroes.
+func convertBits(data []byte, from, to uint) []byte {
+	var acc, bits uint
+	out := make([]byte, 0, (uint(len(data))*from+to-1)/to)
+	for _, v := range data {
+		acc = acc<<from | uint(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits)&(1<<to-1))
+		}
+	}
+	if bits > 0 {
+		out = append(out, byte(acc<<(to-bits))&(1<<to-1))
+	}
+
+	return out
+}
+
+// Regroups the 5-bit values data into bytes, rejecting padding of more than 4
+// bits or padding that is not zero.
+func convertBitsStrict(data []byte) ([]byte, bool) {
+	var acc, bits uint
+	out := make([]byte, 0, len(data)*5/8)
+	for _, v := range data {
+		acc = acc<<5 | uint(v)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 {
+		return nil, false
+	}
+
+	return out, true
+}
@@ -0,0 +1,117 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+// Returns a tree like New whose keys use the all-zero address, like those of
+// trees loaded from states written before addressing was the default.
+func newLegacy(seed, pubSeed []byte, ots bool) *NYTree {
+	tree := New(seed, pubSeed, ots)
+	tree.addressed, tree.nodes[0].addressed = false, false
+
+	return tree
+}
+
+func TestNYTree_EnableAddressing(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newLegacy(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	if err := tree.EnableAddressing(); err != nil {
+		t.Fatal("Failed to enable addressing -", err)
+	}
+
+	// 1 - The long-term key does not change, and new trees are addressed
+	if !bytes.Equal(tree.PublicKey(), pk) {
+		t.Fatal("Addressing changed the long-term key")
+	}
+	if !New(seed, pubSeed, false).Addressed() {
+		t.Fatal("New tree is not addressed")
+	}
+
+	// 2 - Signatures carry the position of their node, and verify
+	var chain []*Signature
+	for i := 0; i < 3; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("address", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		p, ok := sig.Position()
+		if !ok || p.Depth != uint32(i) || (i == 0 && p.Index != 0) || (i == 1 && p.Index >= uint64(Branches)) {
+			t.Fatal("Invalid position", p, "of signature", i)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+		chain = append(chain, sig)
+		if err := VerifyChain(pk, chain, testdata.Message); err != nil {
+			t.Fatal("Failed to verify addressed signature -", err)
+		}
+	}
+
+	// 3 - The position travels in the envelope, but not in Bytes
+	envelope := chain[2].Envelope()
+	if len(envelope) != EnvelopeLen(Branches, true)+EnvelopePositionLen {
+		t.Fatal("Position was not included in the envelope")
+	}
+	parsed, err := ParseEnvelope(envelope, testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if err := VerifyChain(pk, []*Signature{chain[0], chain[1], parsed}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify parsed signature -", err)
+	}
+	decoded, err := NewSignature(chain[2].Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChain(pk, []*Signature{chain[0], chain[1], decoded}, testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified signature without its position, err was", err)
+	}
+
+	// 4 - Addressing is persisted
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !loaded.Addressed() {
+		t.Fatal("Addressing was not persisted")
+	}
+	sig, err := loaded.Sign(testdata.Message, Txid("address", []byte{3}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := VerifyChain(pk, append(chain, sig), testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature of loaded tree -", err)
+	}
+
+	// 5 - Only fresh trees can be addressed
+	used := newLegacy(seed, pubSeed, false)
+	if _, err := used.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal(err)
+	}
+	if err := used.EnableAddressing(); err != ErrTreeNotFresh {
+		t.Fatal("Enabled addressing of a used tree, err was", err)
+	}
+
+	// 6 - Legacy states keep the all-zero address
+	loaded, err = Load(used.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load legacy tree -", err)
+	}
+	if loaded.Addressed() {
+		t.Fatal("Legacy tree was loaded as addressed")
+	}
+	sig, err = loaded.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if _, ok := sig.Position(); ok {
+		t.Fatal("Signature of legacy tree carries a position")
+	}
+}
@@ -0,0 +1,230 @@
+package xnyss
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrAggregateInputs   = newError(ErrCrypto, "aggregate does not hold one signature per message")
+	ErrInvalidAggregate  = newError(ErrEncoding, "invalid aggregate encoding")
+	ErrAggregateNotBatch = newError(ErrState, "signatures of the aggregate were not created by one batch")
+)
+
+// Version byte of an encoded aggregate.
+const aggregateVersion = 0x01
+
+// Tags of the fields of an encoded aggregate. Links are encoded as a message
+// field followed by an envelope field, and every input after the first as a
+// parent field followed by an envelope field. The messages of the inputs are
+// not encoded, since the verifier knows them.
+const (
+	aggregateFieldMessage = 0x01
+	aggregateFieldLink    = 0x02
+	aggregateFieldParent  = 0x03
+	aggregateFieldInput   = 0x04
+)
+
+// The signatures of all inputs of a transaction signed by SignAggregate, with
+// the context they share stored once: the signatures linking the root to the
+// node that signed the first input, and the child hashes through which every
+// other input links to an earlier one. Sending one aggregate instead of a
+// bundle per input saves the links for every input after the first, and
+// verifying it recovers every public key only once, see Verify.
+type Aggregate struct {
+	// The signatures linking the root to the node that signed the first
+	// input, oldest first, see Bundle
+	Links []*Signature
+	// The signatures of the inputs, in the order of their messages
+	Inputs []*Signature
+	// The index of the input whose child hashes hold the public key hash of
+	// the node that signed each input. Parents[0] is -1, since the first input
+	// links to the root through Links.
+	Parents []int
+}
+
+// Signs the messages of all inputs of the transaction txid like SignBatch, and
+// returns them as an aggregate. Like SignBundle, the aggregate includes the
+// signatures linking the root to the first signing node, and
+// ErrBundleIncomplete is returned before signing if they are not available.
+func (t *NYTree) SignAggregate(msgs [][]byte, txid []byte) (*Aggregate, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a := &Aggregate{Parents: make([]int, len(msgs))}
+	signers := make([]*Signature, len(msgs))
+	sigs, err := t.signBatch(msgs, txid, func(i int, node *nyNode) (err error) {
+		if i == 0 {
+			a.Links, err = t.links(node, nil)
+		} else if node.link != nil {
+			signers[i] = node.link.sig
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.Inputs = sigs
+	a.Parents[0] = -1
+	for i := 1; i < len(sigs); i++ {
+		a.Parents[i] = -1
+		for j := 0; j < i; j++ {
+			if sigs[j] == signers[i] {
+				a.Parents[i] = j
+			}
+		}
+		if a.Parents[i] < 0 {
+			return nil, ErrAggregateNotBatch
+		}
+	}
+
+	return a, nil
+}
+
+// Verifies the aggregate a of the signatures of msgs against the long-term key
+// rootPubKey: the first input must verify like a bundle, and the public key
+// hash of every other input must be a child hash of its parent input. Every
+// public key is recovered once, in one wotsp.Arena.
+//
+// Returns ErrAggregateInputs if a does not hold a signature per message, and
+// the errors of VerifyChain, which limits the depth of the chain of every
+// input to MaxChainDepth.
+func (a *Aggregate) Verify(rootPubKey []byte, msgs [][]byte) error {
+	if len(a.Inputs) == 0 || len(a.Inputs) != len(msgs) || len(a.Parents) != len(a.Inputs) {
+		return ErrAggregateInputs
+	}
+
+	arena := wotsp.NewArena()
+	chain := append(append([]*Signature(nil), a.Links...), a.Inputs[0])
+	if err := verifyChain(context.Background(), arena, rootPubKey, chain, msgs[0], nil); err != nil {
+		return err
+	}
+
+	first := a.Inputs[0]
+	depths := make([]int, len(a.Inputs))
+	depths[0] = len(chain)
+	for i := 1; i < len(a.Inputs); i++ {
+		sig, p := a.Inputs[i], a.Parents[i]
+		if p < 0 || p >= i || sig == nil {
+			return ErrChainBroken
+		}
+		depths[i] = depths[p] + 1
+		if MaxChainDepth > 0 && depths[i] > MaxChainDepth {
+			return ErrChainTooLong
+		}
+		if !bytes.Equal(sig.Message, msgs[i]) {
+			return ErrChainMessage
+		}
+		if sig.scheme() != first.scheme() || sig.HashSuite() != first.HashSuite() || sig.w != first.w {
+			return ErrSchemeMismatch
+		}
+
+		pk, err := sig.publicKeyIn(arena)
+		if err != nil {
+			return err
+		}
+		if !hasChild(a.Inputs[p], pk) {
+			return ErrChainBroken
+		}
+	}
+
+	return nil
+}
+
+// Returns the chain of the signature of input i of the verified aggregate a,
+// from the root to the input, which verifies with VerifyChain on its own.
+func (a *Aggregate) Chain(i int) []*Signature {
+	var path []*Signature
+	for i > 0 && a.Parents[i] < i {
+		path = append([]*Signature{a.Inputs[i]}, path...)
+		i = a.Parents[i]
+	}
+	path = append([]*Signature{a.Inputs[0]}, path...)
+
+	return append(append([]*Signature(nil), a.Links...), path...)
+}
+
+// Returns the encoding of the aggregate a, which holds the envelopes of its
+// signatures (see Signature.Envelope).
+func (a *Aggregate) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(aggregateVersion)
+	for _, link := range a.Links {
+		writeField(buf, aggregateFieldMessage, link.Message)
+		writeField(buf, aggregateFieldLink, link.Envelope())
+	}
+	for i, sig := range a.Inputs {
+		if i > 0 {
+			var parent [2]byte
+			binary.BigEndian.PutUint16(parent[:], uint16(a.Parents[i]))
+			writeField(buf, aggregateFieldParent, parent[:])
+		}
+		writeField(buf, aggregateFieldInput, sig.Envelope())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes an aggregate encoded by Aggregate.Bytes of the signatures of msgs.
+// The aggregate still needs to be verified, see Aggregate.Verify.
+func ParseAggregate(b []byte, msgs [][]byte) (*Aggregate, error) {
+	if len(b) < 1 || b[0] != aggregateVersion {
+		return nil, ErrInvalidAggregate
+	}
+
+	a := &Aggregate{}
+	var msg []byte
+	parent := -1
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case aggregateFieldMessage:
+			if len(a.Inputs) > 0 {
+				return ErrInvalidAggregate
+			}
+			msg = value
+		case aggregateFieldLink:
+			if msg == nil {
+				return ErrInvalidAggregate
+			}
+			link, err := ParseEnvelope(value, msg)
+			if err != nil {
+				return err
+			}
+			a.Links = append(a.Links, link)
+			msg = nil
+		case aggregateFieldParent:
+			if len(value) != 2 || len(a.Inputs) == 0 {
+				return ErrInvalidAggregate
+			}
+			parent = int(binary.BigEndian.Uint16(value))
+		case aggregateFieldInput:
+			i := len(a.Inputs)
+			if i >= len(msgs) || (i > 0) != (parent >= 0) {
+				return ErrInvalidAggregate
+			}
+			sig, err := ParseEnvelope(value, msgs[i])
+			if err != nil {
+				return err
+			}
+			a.Inputs = append(a.Inputs, sig)
+			a.Parents = append(a.Parents, parent)
+			parent = -1
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg != nil || parent >= 0 || len(a.Inputs) != len(msgs) {
+		return nil, ErrInvalidAggregate
+	}
+
+	return a, nil
+}
@@ -0,0 +1,79 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNYTree_SignAggregate(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	rootSig, _, err := signMessage("aggregate test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range rootSig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	var msgs [][]byte
+	for i := 0; i < 4; i++ {
+		msg := sha256.Sum256([]byte{byte(i)})
+		msgs = append(msgs, msg[:])
+	}
+	txid := sha256.Sum256([]byte("aggregate txid"))
+
+	// 1 - Aggregates verify, and so do the chains of their inputs
+	a, err := tree.SignAggregate(msgs, txid[:])
+	if err != nil {
+		t.Fatal("Failed to sign aggregate -", err)
+	}
+	if len(a.Links) != 1 || len(a.Inputs) != len(msgs) {
+		t.Fatal("Invalid aggregate", len(a.Links), len(a.Inputs))
+	}
+	if err := a.Verify(pk, msgs); err != nil {
+		t.Fatal("Failed to verify aggregate -", err)
+	}
+	for i := range msgs {
+		if err := VerifyChain(pk, a.Chain(i), msgs[i]); err != nil {
+			t.Fatal("Failed to verify chain of input", i, "-", err)
+		}
+	}
+
+	// 2 - Aggregates survive encoding, and are smaller than a bundle per input
+	b := a.Bytes()
+	parsed, err := ParseAggregate(b, msgs)
+	if err != nil {
+		t.Fatal("Failed to parse aggregate -", err)
+	}
+	if err := parsed.Verify(pk, msgs); err != nil {
+		t.Fatal("Failed to verify parsed aggregate -", err)
+	}
+	var bundles int
+	for i := range msgs {
+		for _, sig := range a.Chain(i) {
+			bundles += len(sig.Envelope())
+		}
+	}
+	if len(b) >= bundles {
+		t.Fatal("Aggregate is not smaller than the bundles", len(b), bundles)
+	}
+
+	// 3 - Aggregates do not verify for other messages or parents
+	other := append([][]byte(nil), msgs...)
+	other[2] = msgs[0]
+	if err := a.Verify(pk, other); err != ErrChainMessage {
+		t.Fatal("Verified aggregate for other message, err was", err)
+	}
+	if err := a.Verify(pk, msgs[:3]); err != ErrAggregateInputs {
+		t.Fatal("Verified aggregate for fewer messages, err was", err)
+	}
+	a.Parents[3] = 3
+	if err := a.Verify(pk, msgs); err != ErrChainBroken {
+		t.Fatal("Verified aggregate with invalid parent, err was", err)
+	}
+}
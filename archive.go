@@ -0,0 +1,117 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+var (
+	ErrInvalidArchive = newError(ErrEncoding, "invalid archive encoding")
+)
+
+// Version byte of an encoded archive.
+const archiveVersion = 0x01
+
+// Tags of the fields of an encoded archive.
+const (
+	archiveFieldTree        = 0x01
+	archiveFieldEpoch       = 0x02
+	archiveFieldConsumed    = 0x03
+	archiveFieldTransitions = 0x04
+)
+
+// Audit-only data moved out of a tree by ArchiveConsumed.
+type Archive struct {
+	// ID of the tree the data was taken from, see NYTree.ID.
+	TreeID string
+	// Epoch of the tree once the data was removed.
+	Epoch uint64
+	// Public key hashes of consumed nodes, sorted.
+	Consumed [][]byte
+	// Mode transitions, in order.
+	Transitions []ModeTransition
+}
+
+// Moves the audit-only data of the tree t into an archive: the public key
+// hashes of consumed nodes and the history of mode transitions. The serialised
+// tree stays small, while the archive retains the data for later inspection.
+//
+// Consumed nodes are no longer in the tree, so the live state does not need
+// their hashes to sign. They do protect against nodes of an older state being
+// reintroduced though, e.g. by MarkConsumed or a backup of t: to keep that
+// protection, pass archive.Consumed to MarkConsumed before merging old states.
+func (t *NYTree) ArchiveConsumed() *Archive {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.epoch++
+
+	a := &Archive{
+		TreeID:      t.ID(),
+		Epoch:       t.epoch,
+		Consumed:    t.consumedHashes(),
+		Transitions: t.copyTransitions(),
+	}
+
+	t.consumed = nil
+	t.transitions = nil
+
+	return a
+}
+
+// Returns the encoding of the archive a.
+func (a *Archive) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(archiveVersion)
+
+	var epoch [8]byte
+	binary.BigEndian.PutUint64(epoch[:], a.Epoch)
+	writeField(buf, archiveFieldTree, []byte(a.TreeID))
+	writeField(buf, archiveFieldEpoch, epoch[:])
+	writeField(buf, archiveFieldConsumed, bytes.Join(a.Consumed, nil))
+	writeField(buf, archiveFieldTransitions, encodeTransitions(a.Transitions))
+
+	return buf.Bytes()
+}
+
+// Decodes an archive encoded by Archive.Bytes.
+func LoadArchive(b []byte) (*Archive, error) {
+	if len(b) < 1 || b[0] != archiveVersion {
+		return nil, ErrInvalidArchive
+	}
+
+	a := &Archive{}
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case archiveFieldTree:
+			a.TreeID = string(value)
+		case archiveFieldEpoch:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			a.Epoch = binary.BigEndian.Uint64(value)
+		case archiveFieldConsumed:
+			if len(value)%32 != 0 {
+				return ErrFieldInvalid
+			}
+			for i := 0; i < len(value); i += 32 {
+				a.Consumed = append(a.Consumed, append([]byte(nil), value[i:i+32]...))
+			}
+		case archiveFieldTransitions:
+			transitions, err := decodeTransitions(value)
+			if err != nil {
+				return err
+			}
+			a.Transitions = transitions
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
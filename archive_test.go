@@ -0,0 +1,56 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_ArchiveConsumed(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	old := tree.Bytes()
+
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if err := tree.Freeze(); err != nil {
+		t.Fatal(err)
+	}
+	before := len(tree.Bytes())
+
+	// 1 - Audit data is moved into the archive
+	archive := tree.ArchiveConsumed()
+	if len(archive.Consumed) != 1 || len(archive.Transitions) != 1 || archive.TreeID != tree.ID() {
+		t.Fatal("Invalid archive", archive)
+	}
+	if len(tree.Consumed()) != 0 || len(tree.Transitions()) != 0 || len(tree.Bytes()) >= before {
+		t.Fatal("Audit data was not removed from the tree")
+	}
+
+	// 2 - The archive survives encoding, and can restore the consumed set
+	loaded, err := LoadArchive(archive.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load archive -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), archive.Bytes()) || loaded.Epoch != tree.Epoch() {
+		t.Fatal("Archive changed after encoding")
+	}
+
+	oldTree, err := Load(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldTree.MarkConsumed(loaded.Consumed) != 1 {
+		t.Fatal("Archived consumed nodes were not removed from old state")
+	}
+	if _, err := LoadArchive(old); err != ErrInvalidArchive {
+		t.Fatal("Loaded invalid archive, err was", err)
+	}
+}
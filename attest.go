@@ -0,0 +1,131 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+var (
+	ErrAttestationInvalid = newError(ErrCrypto, "attestation does not answer the challenge for the long-term key")
+)
+
+// The capacity of a tree when it created an attestation, see Attest.
+type CapacityStatement struct {
+	// The amount of confirmed nodes that could sign, including the node that
+	// signs the attestation
+	Confirmed uint32
+	// The amount of nodes awaiting confirmation
+	Unconfirmed uint32
+	// The amount of nodes known to be consumed, see NYTree.Consumed
+	Consumed uint32
+}
+
+func (c CapacityStatement) bytes() []byte {
+	var b [12]byte
+	binary.BigEndian.PutUint32(b[:], c.Confirmed)
+	binary.BigEndian.PutUint32(b[4:], c.Unconfirmed)
+	binary.BigEndian.PutUint32(b[8:], c.Consumed)
+
+	return b[:]
+}
+
+// A statement proving control of the tree with long-term public key PublicKey
+// at the time an auditor issued Challenge, together with the capacity the tree
+// claims to have, see Attest.
+type Attestation struct {
+	PublicKey []byte
+	Challenge []byte
+	Capacity  CapacityStatement
+	// The signature of AttestationMessage by the tree
+	Signature *Signature
+	// The signatures linking the root of the tree to the node that created
+	// Signature, oldest first, see Bundle
+	Links []*Signature
+}
+
+// Returns the message signed by the tree with long-term public key pubKey to
+// answer challenge with capacity statement c. It is also the txid of the
+// signature, see Attest.
+func AttestationMessage(pubKey, challenge []byte, c CapacityStatement) []byte {
+	var challengeLen [4]byte
+	binary.BigEndian.PutUint32(challengeLen[:], uint32(len(challenge)))
+
+	h := sha256.New()
+	h.Write([]byte("xnyss attestation"))
+	h.Write(pubKey)
+	h.Write(challengeLen[:])
+	h.Write(challenge)
+	h.Write(c.bytes())
+
+	return h.Sum(nil)
+}
+
+// Answers the challenge of an auditor with an attestation signed by t, which
+// proves control of its long-term key and states its capacity, e.g. for
+// proof-of-reserves audits of custodians. The challenge should be fresh and
+// chosen by the auditor, so that attestations cannot be prepared in advance.
+//
+// Like any signature, the attestation consumes a node of t, and adds children
+// that must be confirmed before they are used. Since the capacity statement is
+// signed, it is taken before the attestation is created. The statement is a
+// claim of the holder of the key: auditors should compare it to the signatures
+// they observed, e.g. using the consumed count.
+//
+// The attestation includes the signatures linking the root of t to the signing
+// node, like SignBundle does for a verifier that only knows the long-term key.
+// Since trees only remember these links since they were loaded, returns
+// ErrBundleIncomplete if they are not available.
+func (t *NYTree) Attest(challenge []byte) (*Attestation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+
+	a := &Attestation{
+		PublicKey: t.publicKey(),
+		Challenge: append([]byte(nil), challenge...),
+		Capacity:  t.capacityStatement(),
+	}
+
+	msg := AttestationMessage(a.PublicKey, a.Challenge, a.Capacity)
+	sig, bundle, err := t.signBundle(msg, msg[:TxidLen], nil)
+	if err != nil {
+		return nil, err
+	}
+	a.Signature, a.Links = sig, bundle.Links
+	t.log(LevelAudit, "attestation created", "links", len(a.Links))
+
+	return a, nil
+}
+
+func (t *NYTree) capacityStatement() (c CapacityStatement) {
+	c.Confirmed = uint32(t.capacity())
+	for _, node := range t.nodes {
+		if node.confirms < ConfirmsRequired {
+			c.Unconfirmed++
+		}
+	}
+	c.Consumed = uint32(len(t.consumed))
+
+	return
+}
+
+// Verifies that a is an attestation of the long-term key pubKey answering
+// challenge, created by Attest. Returns ErrAttestationInvalid if a attests
+// another key, answers another challenge or signs another message, and the
+// errors of VerifyChain if its signatures do not verify.
+func VerifyAttestation(pubKey, challenge []byte, a *Attestation) error {
+	if a == nil || a.Signature == nil || !bytes.Equal(a.PublicKey, pubKey) || !bytes.Equal(a.Challenge, challenge) {
+		return ErrAttestationInvalid
+	}
+
+	msg := AttestationMessage(a.PublicKey, a.Challenge, a.Capacity)
+	if !bytes.Equal(a.Signature.Message, msg) {
+		return ErrAttestationInvalid
+	}
+
+	return (&Bundle{Links: a.Links}).Verify(pubKey, nil, a.Signature, msg)
+}
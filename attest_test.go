@@ -0,0 +1,62 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_Attest(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("attest test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	pubKey := tree.PublicKey()
+	challenge := []byte("audit 2026-10")
+
+	// 1 - The attestation answers the challenge and states the capacity
+	available := tree.Available(nil)
+	a, err := tree.Attest(challenge)
+	if err != nil {
+		t.Fatal("Failed to attest -", err)
+	}
+	if int(a.Capacity.Confirmed) != available || a.Capacity.Consumed != 1 || a.Capacity.Unconfirmed != 0 || len(a.Links) != 1 {
+		t.Fatal("Invalid attestation", a.Capacity)
+	}
+	if err := VerifyAttestation(pubKey, challenge, a); err != nil {
+		t.Fatal("Failed to verify attestation -", err)
+	}
+	if tree.Available(nil) != available-1 {
+		t.Fatal("Attestation did not consume a node")
+	}
+
+	// 2 - Attestations for other challenges, keys or capacities are rejected
+	if err := VerifyAttestation(pubKey, []byte("audit 2026-11"), a); err != ErrAttestationInvalid {
+		t.Fatal("Verified attestation for another challenge, err was", err)
+	}
+	other := New(seed, pubSeed[1:], false)
+	if err := VerifyAttestation(other.PublicKey(), challenge, a); err != ErrAttestationInvalid {
+		t.Fatal("Verified attestation for another key, err was", err)
+	}
+	forged := *a
+	forged.Capacity.Confirmed++
+	if err := VerifyAttestation(pubKey, challenge, &forged); err != ErrAttestationInvalid {
+		t.Fatal("Verified forged capacity, err was", err)
+	}
+
+	// 3 - The root attests without links
+	fresh := New(seed, pubSeed, false)
+	a, err = fresh.Attest(challenge)
+	if err != nil || len(a.Links) != 0 || a.Capacity.Confirmed != 1 {
+		t.Fatal("Failed to attest with the root -", err)
+	}
+	if err := VerifyAttestation(pubKey, challenge, a); err != nil {
+		t.Fatal("Failed to verify attestation -", err)
+	}
+}
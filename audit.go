@@ -0,0 +1,359 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+var (
+	ErrAuditTampered = newError(ErrEncoding, "audit log has been modified")
+)
+
+// Domain separation prefix of the hashes of audit entries.
+var auditLabel = []byte("xnyss audit")
+
+// Length of an encoded audit entry without child hashes:
+// seq || time || pkh || txid || message || uint8(len(children)) || prev
+const auditEntryLen = 8 + 8 + 32 + TxidLen + MsgLen + 1 + 32
+
+// An entry of the audit log of a tree, recording a signature created by Sign
+// or one of its variants, see EnableAudit.
+type AuditEntry struct {
+	// The position of the entry in the log, starting at zero
+	Seq  uint64
+	Time time.Time
+	// The public key hash of the node that signed, and the (hashed) txid and
+	// message it signed
+	PubKeyHash []byte
+	Txid       []byte
+	Message    []byte
+	// The public key hashes of the child nodes created by the signature
+	ChildHashes [][]byte
+	// The hash of the previous entry, or 32 zero bytes for the first entry
+	Prev []byte
+}
+
+// Returns the hash of the entry e, which is the Prev of the next entry. Since
+// every entry includes the hash of the one before it, entries can not be
+// changed, removed or reordered without breaking the chain of the entries
+// following them.
+func (e *AuditEntry) Hash() []byte {
+	s := sha256.New()
+	s.Write(auditLabel)
+	s.Write(e.bytes())
+
+	return s.Sum(nil)
+}
+
+func (e *AuditEntry) bytes() []byte {
+	buf := make([]byte, 16, auditEntryLen+32*len(e.ChildHashes))
+	binary.BigEndian.PutUint64(buf, e.Seq)
+	binary.BigEndian.PutUint64(buf[8:], uint64(e.Time.UnixNano()))
+	buf = append(buf, e.PubKeyHash...)
+	buf = append(buf, e.Txid...)
+	buf = append(buf, e.Message...)
+	buf = append(buf, byte(len(e.ChildHashes)))
+	for _, pkh := range e.ChildHashes {
+		buf = append(buf, pkh...)
+	}
+
+	return append(buf, e.Prev...)
+}
+
+func parseAuditEntry(b []byte) (*AuditEntry, error) {
+	if len(b) < auditEntryLen || len(b) != auditEntryLen+32*int(b[16+32+TxidLen+MsgLen]) {
+		return nil, ErrAuditTampered
+	}
+
+	e := &AuditEntry{
+		Seq:        binary.BigEndian.Uint64(b),
+		Time:       time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+		PubKeyHash: append([]byte(nil), b[16:48]...),
+		Txid:       append([]byte(nil), b[48:48+TxidLen]...),
+		Message:    append([]byte(nil), b[48+TxidLen:48+TxidLen+MsgLen]...),
+		Prev:       append([]byte(nil), b[len(b)-32:]...),
+	}
+	for offset := auditEntryLen - 32; offset < len(b)-32; offset += 32 {
+		e.ChildHashes = append(e.ChildHashes, append([]byte(nil), b[offset:offset+32]...))
+	}
+
+	return e, nil
+}
+
+// Controls the audit log of a tree, see EnableAudit.
+type AuditOptions struct {
+	// If not nil, every entry is written to Writer, see ReadAuditLog. The
+	// writer is not persisted.
+	Writer io.Writer
+	// Whether entries are kept by the tree, and included in the serialised
+	// tree, see AuditLog.
+	Keep bool
+}
+
+// The audit log of a tree: the head of its hash chain, and the kept entries.
+type auditLog struct {
+	w       io.Writer
+	keep    bool
+	seq     uint64
+	prev    [32]byte
+	entries []AuditEntry
+}
+
+// Enables the audit log of the tree t, or changes its options if it was
+// enabled before. From then on, every signature of t is recorded as an
+// AuditEntry, holding the time, the node that signed, the txid and message it
+// signed and the child nodes it created, for compliance reviews that need to
+// know which node signed which message when.
+//
+// Entries are hash chained (see AuditEntry.Hash), written to opts.Writer, and
+// kept by the tree if opts.Keep is set. Whether the log is enabled, and the
+// head of its chain, are included in the serialised tree, so the chain of a
+// tree that is saved and loaded continues where it left off; the writer must
+// be set again after loading. Entries kept before are dropped if opts.Keep is
+// not set.
+//
+// An entry is written before the node that signed is consumed: if writing
+// fails, Sign returns the error and the node remains available, so no
+// signature is ever returned without its entry.
+func (t *NYTree) EnableAudit(opts AuditOptions) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.audit == nil {
+		t.audit = &auditLog{}
+		t.epoch++
+	}
+	if t.audit.keep != opts.Keep {
+		t.epoch++
+	}
+	t.audit.w, t.audit.keep = opts.Writer, opts.Keep
+	if !opts.Keep {
+		t.audit.entries = nil
+	}
+}
+
+// Returns whether the audit log of the tree t is enabled, see EnableAudit.
+func (t *NYTree) AuditEnabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.audit != nil
+}
+
+// Returns the entries kept by the audit log of the tree t, oldest first, see
+// EnableAudit.
+func (t *NYTree) AuditLog() []AuditEntry {
+	return t.QueryAudit(AuditQuery{})
+}
+
+// Selects entries of an audit log, see QueryAudit. Empty fields match all
+// entries.
+type AuditQuery struct {
+	PubKeyHash []byte
+	// The txid is hashed like for Sign if the tree uses txid hashing, see
+	// SetTxidHashing.
+	Txid    []byte
+	Message []byte
+	// Entries created before Since, or at or after Until, do not match
+	Since, Until time.Time
+}
+
+// Returns whether the entry e matches the query q.
+func (q *AuditQuery) matches(e *AuditEntry) bool {
+	return (q.PubKeyHash == nil || bytes.Equal(q.PubKeyHash, e.PubKeyHash)) &&
+		(q.Txid == nil || bytes.Equal(q.Txid, e.Txid)) &&
+		(q.Message == nil || bytes.Equal(q.Message, e.Message)) &&
+		(q.Since.IsZero() || !e.Time.Before(q.Since)) &&
+		(q.Until.IsZero() || e.Time.Before(q.Until))
+}
+
+// Returns the entries kept by the audit log of the tree t that match q,
+// oldest first. A node signs only once, so querying by public key hash returns
+// at most one entry.
+func (t *NYTree) QueryAudit(q AuditQuery) []AuditEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.audit == nil {
+		return nil
+	}
+	if q.Txid != nil {
+		q.Txid = t.lookupTxid(q.Txid)
+	}
+
+	var entries []AuditEntry
+	for i := range t.audit.entries {
+		if q.matches(&t.audit.entries[i]) {
+			entries = append(entries, t.audit.entries[i].copy())
+		}
+	}
+
+	return entries
+}
+
+func (e *AuditEntry) copy() AuditEntry {
+	c := *e
+	c.PubKeyHash = append([]byte(nil), e.PubKeyHash...)
+	c.Txid = append([]byte(nil), e.Txid...)
+	c.Message = append([]byte(nil), e.Message...)
+	c.Prev = append([]byte(nil), e.Prev...)
+	c.ChildHashes = make([][]byte, len(e.ChildHashes))
+	for i, pkh := range e.ChildHashes {
+		c.ChildHashes[i] = append([]byte(nil), pkh...)
+	}
+
+	return c
+}
+
+// Records the signature sig, created by the node with public key hash pkh for
+// msg and txid, in the audit log of t if it is enabled.
+func (t *NYTree) auditSign(sig *Signature, pkh, msg, txid []byte) error {
+	if t.audit == nil {
+		return nil
+	}
+
+	e := AuditEntry{
+		Seq:         t.audit.seq,
+		Time:        t.now(),
+		PubKeyHash:  append([]byte(nil), pkh...),
+		Txid:        append([]byte(nil), txid...),
+		Message:     append([]byte(nil), msg...),
+		ChildHashes: make([][]byte, len(sig.ChildHashes)),
+		Prev:        append([]byte(nil), t.audit.prev[:]...),
+	}
+	for i, child := range sig.ChildHashes {
+		e.ChildHashes[i] = append([]byte(nil), child...)
+	}
+
+	if t.audit.w != nil {
+		b := e.bytes()
+		frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(b)), uint32(len(b)))
+		if _, err := t.audit.w.Write(append(frame, b...)); err != nil {
+			return err
+		}
+	}
+
+	t.audit.seq++
+	copy(t.audit.prev[:], e.Hash())
+	if t.audit.keep {
+		t.audit.entries = append(t.audit.entries, e)
+	}
+
+	return nil
+}
+
+// Reads the entries written to the writer of an audit log (see
+// AuditOptions.Writer) from r, and verifies their chain, see VerifyAuditLog.
+// Returns ErrAuditTampered if the log is damaged or was modified.
+func ReadAuditLog(r io.Reader) ([]AuditEntry, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for offset := 0; offset < len(b); {
+		frame, n, err := readFrame(b[offset:])
+		if err != nil {
+			return nil, ErrAuditTampered
+		}
+		e, err := parseAuditEntry(frame)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+		offset += n
+	}
+
+	if err := VerifyAuditLog(entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Verifies that the entries form an unbroken chain: every entry holds the
+// hash of the entry before it, and the sequence numbers are consecutive. The
+// first entry must be the first of its log, unless it continues a log whose
+// earlier entries were not kept. Returns ErrAuditTampered otherwise.
+func VerifyAuditLog(entries []AuditEntry) error {
+	for i := range entries {
+		e := &entries[i]
+		if len(e.Prev) != 32 {
+			return ErrAuditTampered
+		}
+		if i == 0 {
+			if e.Seq == 0 && !bytes.Equal(e.Prev, make([]byte, 32)) {
+				return ErrAuditTampered
+			}
+			continue
+		}
+		if e.Seq != entries[i-1].Seq+1 || !bytes.Equal(e.Prev, entries[i-1].Hash()) {
+			return ErrAuditTampered
+		}
+	}
+
+	return nil
+}
+
+// Encodes the audit log of t as flags || uint64(seq) || prev || entries, where
+// every entry is framed as uint32(len(entry)) || entry. Bit 0 of the flags is
+// set if entries are kept.
+func (t *NYTree) encodeAudit() []byte {
+	buf := &bytes.Buffer{}
+	var flags byte
+	if t.audit.keep {
+		flags |= 0x01
+	}
+	buf.WriteByte(flags)
+	binary.Write(buf, binary.BigEndian, t.audit.seq)
+	buf.Write(t.audit.prev[:])
+	for i := range t.audit.entries {
+		b := t.audit.entries[i].bytes()
+		binary.Write(buf, binary.BigEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+
+	return buf.Bytes()
+}
+
+func (t *NYTree) loadAudit(b []byte) error {
+	if len(b) < 1+8+32 || b[0]&^0x01 != 0 {
+		return ErrFieldInvalid
+	}
+
+	a := &auditLog{keep: b[0]&0x01 != 0, seq: binary.BigEndian.Uint64(b[1:])}
+	copy(a.prev[:], b[9:41])
+	for offset := 41; offset < len(b); {
+		frame, n, err := readFrame(b[offset:])
+		if err != nil {
+			return ErrFieldInvalid
+		}
+		e, err := parseAuditEntry(frame)
+		if err != nil {
+			return err
+		}
+		a.entries = append(a.entries, *e)
+		offset += n
+	}
+	if len(a.entries) > 0 && !a.keep {
+		return ErrFieldInvalid
+	}
+
+	// The kept entries must end at the head of the chain
+	if err := VerifyAuditLog(a.entries); err != nil {
+		return err
+	}
+	if n := len(a.entries); n > 0 {
+		last := &a.entries[n-1]
+		if last.Seq+1 != a.seq || !bytes.Equal(last.Hash(), a.prev[:]) {
+			return ErrAuditTampered
+		}
+	}
+	t.audit = a
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package xnyss
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// A writer that fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestNYTree_EnableAudit(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	clock := NewManualClock(time.Unix(1000, 0))
+	tree.SetClock(clock)
+	out := &bytes.Buffer{}
+	tree.EnableAudit(AuditOptions{Writer: out, Keep: true})
+
+	msg := make([]byte, MsgLen)
+	var sigs []*Signature
+	for i := 0; i < 3; i++ {
+		msg[0] = byte(i)
+		sig, err := tree.Sign(append([]byte(nil), msg...), Txid("audit", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+		sigs = append(sigs, sig)
+		clock.Advance(time.Minute)
+	}
+
+	// 1 - Every signature is recorded, and written to the writer
+	entries := tree.AuditLog()
+	if len(entries) != len(sigs) {
+		t.Fatal("Audit log holds", len(entries), "entries")
+	}
+	for i, e := range entries {
+		pkh, _ := sigs[i].PublicKeyHash()
+		if e.Seq != uint64(i) || !bytes.Equal(e.PubKeyHash, pkh) || !bytes.Equal(e.Message, sigs[i].Message) ||
+			!bytes.Equal(e.ChildHashes[0], sigs[i].ChildHashes[0]) || !e.Time.Equal(time.Unix(1000+60*int64(i), 0)) {
+			t.Fatal("Invalid audit entry", i)
+		}
+	}
+	written, err := ReadAuditLog(bytes.NewReader(out.Bytes()))
+	if err != nil || len(written) != len(entries) || !bytes.Equal(written[2].Hash(), entries[2].Hash()) {
+		t.Fatal("Failed to read written audit log -", err)
+	}
+
+	// 2 - Entries are queried by node, txid and time
+	if q := tree.QueryAudit(AuditQuery{PubKeyHash: entries[1].PubKeyHash}); len(q) != 1 || q[0].Seq != 1 {
+		t.Fatal("Invalid entries for public key hash")
+	}
+	if q := tree.QueryAudit(AuditQuery{Txid: Txid("audit", []byte{2})}); len(q) != 1 || q[0].Seq != 2 {
+		t.Fatal("Invalid entries for txid")
+	}
+	if q := tree.QueryAudit(AuditQuery{Since: time.Unix(1060, 0), Until: time.Unix(1120, 0)}); len(q) != 1 || q[0].Seq != 1 {
+		t.Fatal("Invalid entries for time range")
+	}
+
+	// 3 - The chain continues after the tree is saved and loaded
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !loaded.AuditEnabled() || len(loaded.AuditLog()) != len(entries) {
+		t.Fatal("Audit log was not persisted")
+	}
+	loaded.EnableAudit(AuditOptions{Writer: out, Keep: true})
+	if _, err := loaded.Sign(msg, Txid("audit", []byte{3})); err != nil {
+		t.Fatal(err)
+	}
+	written, err = ReadAuditLog(bytes.NewReader(out.Bytes()))
+	if err != nil || len(written) != 4 || VerifyAuditLog(loaded.AuditLog()) != nil {
+		t.Fatal("Audit log chain broken after loading -", err)
+	}
+
+	// 4 - Modified logs are detected
+	damaged := append([]byte(nil), out.Bytes()...)
+	damaged[40] ^= 1
+	if _, err := ReadAuditLog(bytes.NewReader(damaged)); err != ErrAuditTampered {
+		t.Fatal("Read modified audit log, err was", err)
+	}
+	written[1], written[2] = written[2], written[1]
+	if err := VerifyAuditLog(written); err != ErrAuditTampered {
+		t.Fatal("Verified reordered audit log, err was", err)
+	}
+
+	// 5 - No signature is returned if its entry can not be written
+	available := loaded.Available(nil)
+	loaded.EnableAudit(AuditOptions{Writer: failingWriter{}})
+	if _, err := loaded.Sign(msg, Txid("audit", []byte{4})); err == nil {
+		t.Fatal("Signed without writing the audit entry")
+	}
+	if loaded.Available(nil) != available || len(loaded.AuditLog()) != 0 {
+		t.Fatal("Failed audit write consumed a node")
+	}
+}
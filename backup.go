@@ -0,0 +1,164 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+var (
+	ErrBackupNodeUnavailable = newError(ErrCapacity, "selected backup node is not available")
+)
+
+// Selects which available nodes are moved to a backup, see BackupWith.
+type BackupSelection uint8
+
+const (
+	// The first available nodes, in the order they are considered for signing
+	BackupFirst BackupSelection = iota
+	// The available nodes closest to the root
+	BackupShallowest
+	// The available nodes furthest from the root
+	BackupDeepest
+)
+
+// Selects the nodes moved to a backup by BackupWith.
+type BackupPolicy struct {
+	// The amount of nodes to move, ignored if Nodes is not nil
+	Count  int
+	Select BackupSelection
+	// If not nil, the public key hashes of the nodes to move, which must all
+	// be available
+	Nodes [][]byte
+}
+
+// Returns the amount of nodes moved by the policy.
+func (p BackupPolicy) count() int {
+	if p.Nodes != nil {
+		return len(p.Nodes)
+	}
+
+	return p.Count
+}
+
+// Describes how a backup was created, see NYTree.BackupInfo. Devices holding
+// backups can compare it to detect stale or duplicate backups before using
+// them: backups of the same origin are numbered in the order they were
+// created, and a backup whose origin and sequence number equal those of
+// another backup is a copy of it.
+type BackupInfo struct {
+	// The creation time, according to the clock of the origin tree (see
+	// SetClock), in seconds
+	Created time.Time
+	// The fingerprint of the origin tree right after the backup was split
+	// off, see Fingerprint
+	Origin [32]byte
+	// The number of backups the origin tree created up to and including this
+	// one
+	Sequence uint64
+}
+
+// Length of an encoded BackupInfo: created || origin || sequence.
+const backupInfoLen = 8 + 32 + 8
+
+// Creates a backup of the tree t like Backup, moving the nodes selected by
+// policy. Returns ErrBackupNodeUnavailable if a node of policy.Nodes is not
+// available to sign, e.g. because it is unconfirmed, reserved or the locked
+// root.
+func (t *NYTree) BackupWith(policy BackupPolicy) (*NYTree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.backup(policy)
+}
+
+// Returns the nodes of t moved by a backup with the given policy.
+func (t *NYTree) backupSelection(policy BackupPolicy) ([]*nyNode, error) {
+	var available []*nyNode
+	for _, node := range t.nodes {
+		if node.confirms >= ConfirmsRequired && node.reservation == 0 && !(t.rootLocked && t.isRoot(node)) {
+			available = append(available, node)
+		}
+	}
+
+	if policy.Nodes != nil {
+		selected := make([]*nyNode, len(policy.Nodes))
+		for i, pkh := range policy.Nodes {
+			for _, node := range available {
+				if bytes.Equal(node.pubKeyHash(), pkh) {
+					selected[i] = node
+					break
+				}
+			}
+			if selected[i] == nil || containsNode(selected[:i], selected[i]) {
+				return nil, ErrBackupNodeUnavailable
+			}
+		}
+		return selected, nil
+	}
+
+	switch policy.Select {
+	case BackupShallowest:
+		sort.SliceStable(available, func(i, j int) bool { return available[i].depth < available[j].depth })
+	case BackupDeepest:
+		sort.SliceStable(available, func(i, j int) bool { return available[i].depth > available[j].depth })
+	}
+
+	return available[:policy.Count], nil
+}
+
+func containsNode(nodes []*nyNode, n *nyNode) bool {
+	for _, node := range nodes {
+		if node == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Describes how the tree t was created, if it is a backup created by Backup or
+// BackupWith. Returns false if t is not a backup.
+func (t *NYTree) BackupInfo() (BackupInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.backupInfo == nil {
+		return BackupInfo{}, false
+	}
+
+	return *t.backupInfo, true
+}
+
+// Returns the description of a backup created by t now.
+func (t *NYTree) newBackupInfo() *BackupInfo {
+	return &BackupInfo{
+		Created:  time.Unix(t.now().Unix(), 0),
+		Origin:   t.fingerprint(),
+		Sequence: t.backups,
+	}
+}
+
+func (info *BackupInfo) encode() []byte {
+	b := make([]byte, backupInfoLen)
+	binary.BigEndian.PutUint64(b, uint64(info.Created.Unix()))
+	copy(b[8:], info.Origin[:])
+	binary.BigEndian.PutUint64(b[40:], info.Sequence)
+
+	return b
+}
+
+func decodeBackupInfo(b []byte) (*BackupInfo, error) {
+	if len(b) != backupInfoLen {
+		return nil, ErrFieldInvalid
+	}
+
+	info := &BackupInfo{
+		Created:  time.Unix(int64(binary.BigEndian.Uint64(b)), 0),
+		Sequence: binary.BigEndian.Uint64(b[40:]),
+	}
+	copy(info.Origin[:], b[8:])
+
+	return info, nil
+}
@@ -0,0 +1,68 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNYTree_BackupWith(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetClock(NewManualClock(time.Unix(1000, 0)))
+	for i := 0; i < 3; i++ {
+		sig, _, err := signMessage("backup test", tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+	}
+
+	// 1 - Specific nodes are moved
+	nodes := tree.Nodes()
+	backup, err := tree.BackupWith(BackupPolicy{Nodes: [][]byte{nodes[1].PubKeyHash}})
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	if moved := backup.Nodes(); len(moved) != 1 || !containsHash([][]byte{moved[0].PubKeyHash}, nodes[1].PubKeyHash) {
+		t.Fatal("Backup does not hold the selected node")
+	}
+	if _, err := tree.BackupWith(BackupPolicy{Nodes: [][]byte{nodes[1].PubKeyHash}}); err != ErrBackupNodeUnavailable {
+		t.Fatal("Moved a node twice, err was", err)
+	}
+
+	// 2 - The deepest or shallowest nodes are moved
+	deepest, err := tree.BackupWith(BackupPolicy{Count: 1, Select: BackupDeepest})
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	shallowest, err := tree.BackupWith(BackupPolicy{Count: 1, Select: BackupShallowest})
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	for _, node := range tree.Nodes() {
+		if node.Depth > deepest.Nodes()[0].Depth || node.Depth < shallowest.Nodes()[0].Depth {
+			t.Fatal("Backup does not hold the deepest or shallowest node")
+		}
+	}
+
+	// 3 - Backups describe their origin
+	info, ok := shallowest.BackupInfo()
+	if !ok || info.Sequence != 3 || info.Origin != tree.fingerprint() || info.Created.Unix() != 1000 {
+		t.Fatalf("Invalid backup info %+v", info)
+	}
+	loaded, err := Load(shallowest.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedInfo, ok := loaded.BackupInfo(); !ok || loadedInfo != info {
+		t.Fatal("Backup info not serialised")
+	}
+	if _, ok := tree.BackupInfo(); ok {
+		t.Fatal("Original tree claims to be a backup")
+	}
+}
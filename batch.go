@@ -0,0 +1,85 @@
+package xnyss
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+// Verifies a burst of signature chains, e.g. all inputs of a block, reusing the
+// buffers of the public key computations between verifications rather than
+// allocating them for every signature (see wotsp.Arena). Call Release once the
+// burst is done, to make the buffers available to the next one. A Batch is not
+// safe for concurrent use.
+type Batch struct {
+	arena *wotsp.Arena
+}
+
+// Creates a new batch.
+func NewBatch() *Batch {
+	return &Batch{arena: wotsp.NewArena()}
+}
+
+// Like VerifyChain, using the buffers of the batch b.
+func (b *Batch) VerifyChain(rootPubKey []byte, chain []*Signature, msg []byte) error {
+	return verifyChain(context.Background(), b.arena, rootPubKey, chain, msg, nil)
+}
+
+// Releases the buffers used since the last call to Release, so the next
+// verifications reuse them.
+func (b *Batch) Release() {
+	b.arena.Release()
+}
+
+// A signature chain verified by VerifyBatch, with the arguments of
+// VerifyChain.
+type VerifyItem struct {
+	RootPubKey []byte
+	Chain      []*Signature
+	Msg        []byte
+}
+
+// Verifies every item like VerifyChain, e.g. all inputs of a block, and returns
+// the error of each of them, nil if it is valid. Items are verified by a pool
+// of at most workers goroutines, or GOMAXPROCS if workers is not positive,
+// which each verify their items one after the other in the buffers of their
+// own Batch, so no goroutine or buffers are created per signature. See
+// wotsp.Params.VerifyBatch for batches of plain WOTS+ signatures.
+func VerifyBatch(items []VerifyItem, workers int) []error {
+	errs, _ := VerifyBatchContext(context.Background(), items, workers)
+	return errs
+}
+
+// Verifies every item like VerifyBatch, but stops with ctx.Err() once ctx is
+// done. Workers check ctx between the signatures of the chains they verify,
+// and the items that were not verified hold ctx.Err() as their error.
+func VerifyBatchContext(ctx context.Context, items []VerifyItem, workers int) ([]error, error) {
+	errs := make([]error, len(items))
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(-1)
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	var taken atomic.Int64
+	wg := new(sync.WaitGroup)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			b := NewBatch()
+			for i := int(taken.Add(1)) - 1; i < len(items); i = int(taken.Add(1)) - 1 {
+				errs[i] = verifyChain(ctx, b.arena, items[i].RootPubKey, items[i].Chain, items[i].Msg, nil)
+				b.Release()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs, ctx.Err()
+}
@@ -0,0 +1,106 @@
+package xnyss
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestBatch_VerifyChain(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	var chain []*Signature
+	for i := 0; i < 3; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("batch", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+	}
+
+	// 1 - Chains verify in a batch, also after releasing it
+	batch := NewBatch()
+	for i := 0; i < 2; i++ {
+		if err := batch.VerifyChain(tree.PublicKey(), chain[:1], testdata.Message); err != nil {
+			t.Fatal("Failed to verify chain -", err)
+		}
+		batch.Release()
+	}
+
+	// 2 - Invalid chains are rejected like VerifyChain does
+	other := New(pubSeed, seed, false)
+	if err := batch.VerifyChain(other.PublicKey(), chain[:1], testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified chain with the wrong root, err was", err)
+	}
+}
+
+func TestVerifyBatch(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+
+	var items []VerifyItem
+	var chain []*Signature
+	for i := 0; i < 4; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("verify batch", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+		items = append(items, VerifyItem{RootPubKey: pk, Chain: chain, Msg: testdata.Message})
+	}
+	other := New(pubSeed, seed, false)
+	items = append(items, VerifyItem{RootPubKey: other.PublicKey(), Chain: chain[:1], Msg: testdata.Message})
+
+	// 1 - Every item is verified like VerifyChain, with any number of workers
+	for _, workers := range []int{0, 1, 2, 10} {
+		errs := VerifyBatch(items, workers)
+		if len(errs) != len(items) {
+			t.Fatal("Invalid number of results")
+		}
+		for i, item := range items {
+			if errs[i] != VerifyChain(item.RootPubKey, item.Chain, item.Msg) {
+				t.Fatal("Wrong result for item", i, "with", workers, "workers -", errs[i])
+			}
+		}
+		if errs[len(errs)-1] != ErrChainBroken {
+			t.Fatal("Verified chain with the wrong root, err was", errs[len(errs)-1])
+		}
+	}
+}
+
+func TestVerifyBatchContext(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, Txid("verify batch context", nil))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	items := []VerifyItem{{RootPubKey: tree.PublicKey(), Chain: []*Signature{sig}, Msg: testdata.Message}}
+
+	// 1 - Cancelled batches verify nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs, err := VerifyBatchContext(ctx, items, 1)
+	if err != context.Canceled || errs[0] != context.Canceled {
+		t.Fatal("Verified with cancelled context", err, errs[0])
+	}
+
+	// 2 - Batches with a live context verify their items
+	if errs, err := VerifyBatchContext(context.Background(), items, 1); err != nil || errs[0] != nil {
+		t.Fatal("Failed to verify batch", err, errs[0])
+	}
+}
@@ -0,0 +1,97 @@
+package xnyss
+
+import (
+	"io"
+)
+
+// Keeps the root seed of the tree t only in XOR-masked form in memory, using a
+// fresh random mask read from the entropy source of t, see SetEntropy. The seed is unmasked into a temporary buffer whenever it
+// is used, e.g. while the WOTS+ key of the root is expanded, and that buffer is
+// wiped immediately after. This raises the bar for memory-dump attacks on
+// long-running signers, which now have to find both halves.
+//
+// Calling BlindSeed again replaces the mask. Blinding is not persisted: the
+// output of Bytes contains the unmasked seed, and trees loaded with Load are
+// not blinded. Load copies the seeds out of the serialised state, which the
+// caller must wipe itself. Returns ErrSeedProviderTree if the seeds of t are
+// held by a seed provider.
+func (t *NYTree) BlindSeed() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.providerSeeds {
+		return ErrSeedProviderTree
+	}
+
+	mask := make([]byte, len(t.rootSeed))
+	if _, err := io.ReadFull(t.random(), mask); err != nil {
+		return err
+	}
+
+	// Re-mask the root nodes first, since isRoot compares them to the
+	// currently masked root seed.
+	for _, node := range t.nodes {
+		if t.isRoot(node) {
+			node.privSeed = remask(node.privSeed, t.rootMask, mask)
+			node.mask = mask
+			node.dropKey()
+			node.pkh = nil
+		}
+	}
+	t.dropIndex()
+
+	t.rootSeed = remask(t.rootSeed, t.rootMask, mask)
+	for i := range t.rootMask {
+		t.rootMask[i] = 0
+	}
+	t.rootMask = mask
+
+	return nil
+}
+
+// Returns whether the root seed of t is kept masked, see BlindSeed.
+func (t *NYTree) SeedBlinded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rootMask != nil
+}
+
+// Returns b XOR oldMask XOR newMask in a new slice, where oldMask may be nil.
+// If b was masked, it is wiped.
+func remask(b, oldMask, newMask []byte) []byte {
+	masked := make([]byte, len(b))
+	for i := range masked {
+		masked[i] = b[i] ^ newMask[i]
+		if oldMask != nil {
+			masked[i] ^= oldMask[i]
+		}
+	}
+
+	if oldMask != nil {
+		for i := range b {
+			b[i] = 0
+		}
+	}
+
+	return masked
+}
+
+// Returns b XOR mask in a temporary copy, which is wiped by calling done. If
+// mask is nil, b is returned as is.
+func unmask(b, mask []byte) (unmasked []byte, done func()) {
+	if mask == nil {
+		return b, func() {}
+	}
+
+	unmasked = make([]byte, len(b))
+	for i := range unmasked {
+		unmasked[i] = b[i] ^ mask[i]
+	}
+
+	return unmasked, func() {
+		for i := range unmasked {
+			unmasked[i] = 0
+		}
+	}
+}
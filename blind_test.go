@@ -0,0 +1,50 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_BlindSeed(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewDeterministic(seed, pubSeed, false)
+	clear := NewDeterministic(seed, pubSeed, false)
+	pubKey := tree.PublicKey()
+	state := tree.Bytes()
+
+	// 1 - The seed is not kept in memory, but the tree behaves the same
+	if err := tree.BlindSeed(); err != nil {
+		t.Fatal("Failed to blind seed -", err)
+	}
+	if bytes.Equal(tree.rootSeed, seed) || bytes.Equal(tree.nodes[0].privSeed, seed) {
+		t.Fatal("Unmasked seed in memory")
+	}
+	if !bytes.Equal(tree.PublicKey(), pubKey) || !bytes.Equal(tree.Bytes(), state) {
+		t.Fatal("Blinding changed the tree")
+	}
+
+	// 2 - Replacing the mask keeps the tree intact
+	if err := tree.BlindSeed(); err != nil {
+		t.Fatal("Failed to replace mask -", err)
+	}
+
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	sigClear, err := clear.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig.Bytes(), sigClear.Bytes()) || !bytes.Equal(tree.Bytes(), clear.Bytes()) {
+		t.Fatal("Blinded tree signed differently")
+	}
+	if err := VerifyChain(pubKey, []*Signature{sig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature -", err)
+	}
+}
@@ -0,0 +1,73 @@
+package xnyss
+
+var (
+	ErrInvalidBranching = newError(ErrState, "branching factors must be between 1 and 255")
+)
+
+// Sets the branching schedule of the tree t: a node at depth d creates
+// schedule[d] child nodes when it signs, where the last entry of the schedule
+// applies to all deeper nodes. For example, {3, 3, 3, 2} creates three children
+// at depths 0 to 2, and two children beyond. A higher branching factor makes
+// signatures larger, but lets the tree grow faster. The schedule is included in
+// the serialised tree. An empty schedule reverts to using Branches.
+func (t *NYTree) SetBranching(schedule []int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	branching := make([]uint8, len(schedule))
+	for i, b := range schedule {
+		if b < 1 || b > 255 {
+			return ErrInvalidBranching
+		}
+		branching[i] = uint8(b)
+	}
+
+	if len(branching) == 0 {
+		branching = nil
+	}
+	t.branching = branching
+	t.resetDerivation()
+	t.epoch++
+
+	return nil
+}
+
+// Returns the branching schedule of the tree t, or nil if it uses Branches.
+func (t *NYTree) Branching() []int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.branching) == 0 {
+		return nil
+	}
+
+	schedule := make([]int, len(t.branching))
+	for i, b := range t.branching {
+		schedule[i] = int(b)
+	}
+
+	return schedule
+}
+
+// Returns the number of children created by a node at the given depth.
+func (t *NYTree) branchesAt(depth uint32) int {
+	if len(t.branching) == 0 {
+		return Branches
+	}
+
+	if depth >= uint32(len(t.branching)) {
+		depth = uint32(len(t.branching) - 1)
+	}
+
+	return int(t.branching[depth])
+}
+
+func validBranching(b []uint8) error {
+	for _, branches := range b {
+		if branches == 0 {
+			return ErrInvalidBranching
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_SetBranching(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	if err := tree.SetBranching([]int{3, 0}); err != ErrInvalidBranching {
+		t.Fatal("Invalid schedule was accepted, err was", err)
+	}
+	if err := tree.SetBranching([]int{3, 2}); err != nil {
+		t.Fatal("Failed to set schedule -", err)
+	}
+
+	// 1 - The root creates three children, deeper nodes two
+	sig, _, err := signMessage("first signature", tree)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if len(sig.ChildHashes) != 3 {
+		t.Fatal("Root created", len(sig.ChildHashes), "children, should be 3")
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+	// 2 - The schedule and node depths survive serialisation
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if schedule := loaded.Branching(); len(schedule) != 2 || schedule[1] != 2 {
+		t.Fatal("Schedule was not persisted", schedule)
+	}
+
+	for i := 0; i < 2; i++ {
+		sig, _, err = signMessage("deeper signature", loaded)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if len(sig.ChildHashes) != 2 {
+			t.Fatal("Node created", len(sig.ChildHashes), "children, should be 2")
+		}
+		loaded.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+	if depth := loaded.nodes[len(loaded.nodes)-1].depth; depth != 3 {
+		t.Fatal("Deepest node has depth", depth, "should be 3")
+	}
+}
@@ -0,0 +1,97 @@
+package xnyss
+
+var (
+	ErrBundleIncomplete = newError(ErrCrypto, "signature links are not known back to a node the verifier knows")
+)
+
+// A signature that created a node, linked to the signature that created the
+// node that signed it.
+type link struct {
+	sig    *Signature
+	signer [32]byte
+	byRoot bool
+	parent *link
+}
+
+// The proof material a verifier needs to verify a signature against the
+// long-term key, besides the signature itself: the signatures linking a node
+// the verifier knows (or the root) to the node that created the signature.
+type Bundle struct {
+	// Oldest first. Empty if the verifier knows the signing node.
+	Links []*Signature
+}
+
+// Signs the message like Sign, and returns a bundle with the signatures the
+// verifier needs to verify it, given the public key hashes of the nodes it
+// already knows; known may be nil if the verifier only knows the long-term key.
+//
+// The tree only remembers the signatures that created nodes since it was
+// loaded. If the links back to a known node are not available,
+// ErrBundleIncomplete is returned before signing.
+func (t *NYTree) SignBundle(msg, txid []byte, known func(pkh []byte) bool) (sig *Signature, bundle *Bundle, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.signBundle(msg, txid, known)
+}
+
+func (t *NYTree) signBundle(msg, txid []byte, known func(pkh []byte) bool) (sig *Signature, bundle *Bundle, err error) {
+	opts := &signOptions{selected: func(node *nyNode) (err error) {
+		bundle = &Bundle{}
+		bundle.Links, err = t.links(node, known)
+		return
+	}}
+
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, opts)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig, bundle, nil
+}
+
+// Returns the signatures linking the root, or a node for which known returns
+// true, to node, oldest first. Returns ErrBundleIncomplete if they are not
+// available.
+func (t *NYTree) links(node *nyNode, known func(pkh []byte) bool) ([]*Signature, error) {
+	isKnown := func(pkh []byte) bool { return known != nil && known(pkh) }
+	if t.isRoot(node) || isKnown(node.pubKeyHash()) {
+		return nil, nil
+	}
+
+	var links []*Signature
+	for l := node.link; l != nil; l = l.parent {
+		links = append([]*Signature{l.sig}, links...)
+		if l.byRoot || isKnown(l.signer[:]) {
+			return links, nil
+		}
+	}
+
+	return nil, ErrBundleIncomplete
+}
+
+// Returns the number of signatures verified by Verify, which is limited by
+// MaxChainDepth.
+func (b *Bundle) Depth() int {
+	return len(b.Links) + 1
+}
+
+// Verifies sig, the signature of msg, using the bundle. The first link (or sig
+// if there are no links) must be created by the root key rootPubKey, or by a
+// node for which known returns true.
+func (b *Bundle) Verify(rootPubKey []byte, known func(pkh []byte) bool, sig *Signature, msg []byte) error {
+	chain := append(append([]*Signature(nil), b.Links...), sig)
+
+	pk, err := chain[0].PublicKey()
+	if err != nil {
+		return err
+	}
+	if known != nil && known(chain[0].suite.sum(pk)) {
+		// Start the chain at the known node instead of the root
+		rootPubKey = pk
+	}
+
+	return VerifyChain(rootPubKey, chain, msg)
+}
@@ -0,0 +1,74 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SignBundle(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+
+	// The verifier only learns about nodes from the signatures it verifies
+	verified := map[string]bool{}
+	known := func(pkh []byte) bool { return verified[string(pkh)] }
+
+	// 1 - A verifier that only knows the long-term key gets the full chain
+	var sig *Signature
+	for i := 0; i < 3; i++ {
+		sig, _, err = tree.SignBundle(testdata.Message, Txid("bundle", []byte{byte(i)}), nil)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+	sig, bundle, err := tree.SignBundle(testdata.Message, Txid("bundle", []byte{3}), nil)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if len(bundle.Links) != 3 {
+		t.Fatal("Bundle has", len(bundle.Links), "links, expected 3")
+	}
+	if err := bundle.Verify(pk, nil, sig, testdata.Message); err != nil {
+		t.Fatal("Failed to verify bundle -", err)
+	}
+
+	// 2 - A verifier that knows the signing node's parent gets one link
+	for _, child := range bundle.Links[2].ChildHashes {
+		verified[string(child)] = true
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	sig, bundle, err = tree.SignBundle(testdata.Message, Txid("bundle", []byte{4}), known)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := bundle.Verify(pk, known, sig, testdata.Message); err != nil || len(bundle.Links) != 1 {
+		t.Fatal("Failed to verify partial bundle -", err)
+	}
+	signerPK, _ := bundle.Links[0].PublicKey()
+	signerPKH := sha256.Sum256(signerPK)
+	if !known(signerPKH[:]) {
+		t.Fatal("Bundle does not start at a known node")
+	}
+
+	// 3 - Links are not persisted
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	state := loaded.Bytes()
+	if _, _, err := loaded.SignBundle(testdata.Message, Txid("bundle", []byte{5}), nil); err != ErrBundleIncomplete {
+		t.Fatal("Signed without links, err was", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), state) {
+		t.Fatal("Incomplete bundle consumed a node")
+	}
+}
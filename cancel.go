@@ -0,0 +1,52 @@
+package xnyss
+
+import "context"
+
+// Signs the message like Sign, unless ctx is done before the signature is
+// computed, in which case ctx.Err() is returned and the tree is left
+// unchanged. Waiting for the lock of the tree is not cancelled; see
+// SignOrWait to wait for nodes to become available instead of failing.
+func (t *NYTree) SignContext(ctx context.Context, msg, txid []byte) (sig *Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, &signOptions{ctx: ctx})
+	})
+
+	return
+}
+
+// Confirms the nodes with the public key hashes pkhs like Confirm, e.g. for
+// the public key hashes of the signatures included in a block, and returns the
+// amount of hashes whose node was found. Hashes of nodes that t does not hold
+// are ignored.
+func (t *NYTree) ConfirmBatch(pkhs [][]byte, confirms uint8) (n int) {
+	n, _ = t.ConfirmBatchContext(context.Background(), pkhs, confirms)
+	return
+}
+
+// Confirms the nodes with the public key hashes pkhs like ConfirmBatch, but
+// stops with ctx.Err() once ctx is done. The nodes confirmed before stay
+// confirmed, and their amount is returned along with the error.
+func (t *NYTree) ConfirmBatchContext(ctx context.Context, pkhs [][]byte, confirms uint8) (n int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, pkh := range pkhs {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if len(pkh) == 32 && t.nodeByPkh(pkh) != nil {
+			profile(OpConfirm, t, func() {
+				t.confirm(pkh, confirms)
+			})
+			n++
+		}
+	}
+
+	return n, nil
+}
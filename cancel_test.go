@@ -0,0 +1,68 @@
+package xnyss
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SignContext(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	txid := Txid("sign context", nil)
+
+	// 1 - Cancelled signing leaves the tree unchanged
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := tree.Bytes()
+	if _, err := tree.SignContext(ctx, testdata.Message, txid); err != context.Canceled {
+		t.Fatal("Signed with cancelled context, err was", err)
+	}
+	if string(tree.Bytes()) != string(before) {
+		t.Fatal("Cancelled signing changed the tree")
+	}
+
+	// 2 - Signing with a live context creates a valid signature
+	sig, err := tree.SignContext(context.Background(), testdata.Message, txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{sig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature -", err)
+	}
+}
+
+func TestNYTree_ConfirmBatchContext(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, Txid("confirm batch", nil))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	pkhs := append([][]byte{make([]byte, 32)}, sig.ChildHashes...)
+
+	// 1 - Cancelled batches confirm nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n, err := tree.ConfirmBatchContext(ctx, pkhs, ConfirmsRequired); n != 0 || err != context.Canceled {
+		t.Fatal("Confirmed with cancelled context", n, err)
+	}
+	if tree.Available(nil) != 0 {
+		t.Fatal("Cancelled batch confirmed nodes")
+	}
+
+	// 2 - Batches confirm the nodes the tree holds
+	if n := tree.ConfirmBatch(pkhs, ConfirmsRequired); n != len(sig.ChildHashes) {
+		t.Fatal("Invalid amount of confirmed nodes", n)
+	}
+	if tree.Available(nil) != len(sig.ChildHashes) {
+		t.Fatal("Batch did not confirm nodes")
+	}
+}
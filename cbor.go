@@ -0,0 +1,476 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+var (
+	ErrInvalidCBOR = newError(ErrEncoding, "invalid CBOR encoding")
+)
+
+// The compact CBOR (RFC 8949) encodings of signatures, node descriptions and
+// public trees are maps with small unsigned integer keys. Only the data items
+// these need are supported: unsigned integers, byte and text strings, arrays,
+// maps with unsigned integer keys and booleans, all of definite length and
+// with their arguments in the shortest form. The binary encodings remain the
+// canonical ones.
+
+// Major types of CBOR data items.
+const (
+	cborUint   = 0
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborSimple = 7
+
+	cborFalse = 20
+	cborTrue  = 21
+)
+
+// The maximum nesting of decoded data items.
+const cborMaxDepth = 4
+
+// Keys of the CBOR encoding of a signature.
+const (
+	cborSigMessage  = 1
+	cborSigEnvelope = 2
+)
+
+// Keys of the CBOR encoding of a node description.
+const (
+	cborNodePubKeyHash = 1
+	cborNodeTxid       = 2
+	cborNodeConfirms   = 3
+	cborNodeDepth      = 4
+	cborNodeLabel      = 5
+	cborNodeRoot       = 6
+	cborNodeReserved   = 7
+	cborNodeTxidLen    = 8
+	cborNodeParent     = 9
+)
+
+// Keys of the CBOR encoding of a public tree. Nodes are arrays holding
+// pkh, parent, txid, confirms and used, like the node fields of Bytes.
+const (
+	cborPubTreeRoot     = 1
+	cborPubTreeRootUsed = 2
+	cborPubTreeNodes    = 3
+)
+
+// A map of a CBOR encoding. Entries whose value is nil, false, zero or empty
+// are omitted when encoding, and decode to those values.
+type cborMapValue map[uint64]interface{}
+
+// Implements the CBOR marshaler convention of MarshalCBOR. The signature is
+// encoded as a map holding its message, if it is set, and its envelope.
+func (sig *Signature) MarshalCBOR() ([]byte, error) {
+	return cborEncode(cborMapValue{
+		cborSigMessage:  sig.Message,
+		cborSigEnvelope: sig.Envelope(),
+	}), nil
+}
+
+// Decodes a signature encoded by MarshalCBOR. Returns ErrInvalidCBOR if b is
+// not a valid encoding, and the errors of ParseEnvelope.
+func (sig *Signature) UnmarshalCBOR(b []byte) error {
+	m, err := cborDecodeMap(b)
+	if err != nil {
+		return err
+	}
+
+	var msg, envelope []byte
+	err = m.fields(func(key uint64, value interface{}) (ok bool) {
+		switch key {
+		case cborSigMessage:
+			msg, ok = value.([]byte)
+		case cborSigEnvelope:
+			envelope, ok = value.([]byte)
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	decoded, err := ParseEnvelope(envelope, msg)
+	if err != nil {
+		return err
+	}
+	if len(msg) == 0 {
+		decoded.Message = nil
+	}
+
+	*sig = *decoded
+	return nil
+}
+
+// Implements the CBOR marshaler convention of MarshalCBOR.
+func (n NodeInfo) MarshalCBOR() ([]byte, error) {
+	m := cborMapValue{
+		cborNodePubKeyHash: n.PubKeyHash,
+		cborNodeTxid:       n.Txid,
+		cborNodeConfirms:   uint64(n.Confirms),
+		cborNodeDepth:      uint64(n.Depth),
+		cborNodeLabel:      n.Label,
+		cborNodeRoot:       n.Root,
+		cborNodeReserved:   n.Reserved,
+		cborNodeTxidLen:    uint64(n.TxidLen),
+	}
+	if n.Parent != nil {
+		m[cborNodeParent] = n.Parent
+	}
+
+	return cborEncode(m), nil
+}
+
+// Decodes a node description encoded by MarshalCBOR.
+func (n *NodeInfo) UnmarshalCBOR(b []byte) error {
+	m, err := cborDecodeMap(b)
+	if err != nil {
+		return err
+	}
+
+	var v NodeInfo
+	err = m.fields(func(key uint64, value interface{}) (ok bool) {
+		var u uint64
+		switch key {
+		case cborNodePubKeyHash:
+			v.PubKeyHash, ok = value.([]byte)
+		case cborNodeTxid:
+			v.Txid, ok = value.([]byte)
+		case cborNodeConfirms:
+			u, ok = value.(uint64)
+			v.Confirms, ok = uint8(u), ok && u <= 0xff
+		case cborNodeDepth:
+			u, ok = value.(uint64)
+			v.Depth, ok = uint32(u), ok && u <= 0xffffffff
+		case cborNodeLabel:
+			v.Label, ok = value.(string)
+		case cborNodeRoot:
+			v.Root, ok = value.(bool)
+		case cborNodeReserved:
+			v.Reserved, ok = value.(bool)
+		case cborNodeTxidLen:
+			u, ok = value.(uint64)
+			v.TxidLen, ok = int(u), ok && u <= 0xffffffff
+		case cborNodeParent:
+			v.Parent, ok = value.([]byte)
+			ok = ok && len(v.Parent) == 32
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	*n = v
+	return nil
+}
+
+// Implements the CBOR marshaler convention of MarshalCBOR. Nodes are written
+// in the order of their public key hashes, like Bytes does.
+func (pt *NYPubTree) MarshalCBOR() ([]byte, error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var nodes []interface{}
+	for _, pkh := range pt.sortedHashes() {
+		node := pt.nodes[pkh]
+		nodes = append(nodes, []interface{}{
+			append([]byte(nil), pkh[:]...),
+			append([]byte(nil), node.parent[:]...),
+			node.txid,
+			uint64(node.confirms),
+			node.used,
+		})
+	}
+
+	return cborEncode(cborMapValue{
+		cborPubTreeRoot:     pt.rootPubKey,
+		cborPubTreeRootUsed: pt.rootUsed,
+		cborPubTreeNodes:    nodes,
+	}), nil
+}
+
+// Decodes a public tree encoded by MarshalCBOR. Returns ErrInvalidPubTree if
+// a node is malformed.
+func (pt *NYPubTree) UnmarshalCBOR(b []byte) error {
+	m, err := cborDecodeMap(b)
+	if err != nil {
+		return err
+	}
+
+	var rootPubKey []byte
+	var rootUsed bool
+	var nodes []interface{}
+	err = m.fields(func(key uint64, value interface{}) (ok bool) {
+		switch key {
+		case cborPubTreeRoot:
+			rootPubKey, ok = value.([]byte)
+		case cborPubTreeRootUsed:
+			rootUsed, ok = value.(bool)
+		case cborPubTreeNodes:
+			nodes, ok = value.([]interface{})
+		}
+		return
+	})
+	if err != nil {
+		return err
+	}
+	if len(rootPubKey) == 0 {
+		return ErrInvalidPubTree
+	}
+
+	decoded := make(map[[32]byte]*pubNode, len(nodes))
+	for _, item := range nodes {
+		record, ok := item.([]interface{})
+		if !ok || len(record) != 5 {
+			return ErrInvalidPubTree
+		}
+		pkh, ok1 := record[0].([]byte)
+		parent, ok2 := record[1].([]byte)
+		txid, ok3 := record[2].([]byte)
+		confirms, ok4 := record[3].(uint64)
+		used, ok5 := record[4].(bool)
+		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 || len(pkh) != 32 || len(parent) != 32 || len(txid) != TxidLen || confirms > 0xff {
+			return ErrInvalidPubTree
+		}
+
+		var key [32]byte
+		copy(key[:], pkh)
+		node := &pubNode{txid: txid, confirms: uint8(confirms), used: used}
+		copy(node.parent[:], parent)
+		decoded[key] = node
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.rootPubKey, pt.rootUsed, pt.nodes = rootPubKey, rootUsed, decoded
+	return nil
+}
+
+// Calls f for every entry of m, which must return whether the key is known
+// and the value has the expected type. Returns ErrInvalidCBOR otherwise.
+func (m cborMapValue) fields(f func(key uint64, value interface{}) bool) error {
+	for key, value := range m {
+		if !f(key, value) {
+			return ErrInvalidCBOR
+		}
+	}
+
+	return nil
+}
+
+// Returns the CBOR encoding of v, which holds uint64, []byte, string, bool,
+// []interface{} and cborMapValue values. The entries of maps are written in
+// ascending order of their keys.
+func cborEncode(v interface{}) []byte {
+	buf := &bytes.Buffer{}
+	cborWrite(buf, v)
+
+	return buf.Bytes()
+}
+
+func cborWrite(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case uint64:
+		cborWriteHead(buf, cborUint, v)
+	case []byte:
+		cborWriteHead(buf, cborBytes, uint64(len(v)))
+		buf.Write(v)
+	case string:
+		cborWriteHead(buf, cborText, uint64(len(v)))
+		buf.WriteString(v)
+	case bool:
+		if v {
+			cborWriteHead(buf, cborSimple, cborTrue)
+		} else {
+			cborWriteHead(buf, cborSimple, cborFalse)
+		}
+	case []interface{}:
+		cborWriteHead(buf, cborArray, uint64(len(v)))
+		for _, item := range v {
+			cborWrite(buf, item)
+		}
+	case cborMapValue:
+		keys := make([]uint64, 0, len(v))
+		for key, value := range v {
+			if !cborOmitted(value) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+		cborWriteHead(buf, cborMap, uint64(len(keys)))
+		for _, key := range keys {
+			cborWriteHead(buf, cborUint, key)
+			cborWrite(buf, v[key])
+		}
+	}
+}
+
+// Returns whether the map value v is omitted from encodings.
+func cborOmitted(v interface{}) bool {
+	switch v := v.(type) {
+	case uint64:
+		return v == 0
+	case []byte:
+		return len(v) == 0
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case []interface{}:
+		return len(v) == 0
+	}
+
+	return v == nil
+}
+
+// Writes the initial byte of a data item of the given major type, followed by
+// its argument v in the shortest form.
+func cborWriteHead(buf *bytes.Buffer, major byte, v uint64) {
+	var arg [8]byte
+	switch {
+	case v < 24:
+		buf.WriteByte(major<<5 | byte(v))
+	case v <= 0xff:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(v))
+	case v <= 0xffff:
+		buf.WriteByte(major<<5 | 25)
+		binary.BigEndian.PutUint16(arg[:], uint16(v))
+		buf.Write(arg[:2])
+	case v <= 0xffffffff:
+		buf.WriteByte(major<<5 | 26)
+		binary.BigEndian.PutUint32(arg[:], uint32(v))
+		buf.Write(arg[:4])
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.BigEndian.PutUint64(arg[:], v)
+		buf.Write(arg[:])
+	}
+}
+
+// Decodes b, which must hold a single CBOR map.
+func cborDecodeMap(b []byte) (cborMapValue, error) {
+	v, rest, err := cborRead(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(cborMapValue)
+	if !ok || len(rest) != 0 {
+		return nil, ErrInvalidCBOR
+	}
+
+	return m, nil
+}
+
+// Decodes the data item at the start of b, returning it and the bytes that
+// follow it.
+func cborRead(b []byte, depth int) (v interface{}, rest []byte, err error) {
+	if depth > cborMaxDepth {
+		return nil, nil, ErrInvalidCBOR
+	}
+	major, arg, b, err := cborReadHead(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return arg, b, nil
+	case cborBytes, cborText:
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrInvalidCBOR
+		}
+		if major == cborText {
+			return string(b[:arg]), b[arg:], nil
+		}
+		return append([]byte(nil), b[:arg]...), b[arg:], nil
+	case cborArray:
+		// Every item takes at least one byte
+		if arg > uint64(len(b)) {
+			return nil, nil, ErrInvalidCBOR
+		}
+		items := make([]interface{}, arg)
+		for i := range items {
+			if items[i], b, err = cborRead(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return items, b, nil
+	case cborMap:
+		if arg > uint64(len(b))/2 {
+			return nil, nil, ErrInvalidCBOR
+		}
+		m := make(cborMapValue, arg)
+		for i := uint64(0); i < arg; i++ {
+			var key interface{}
+			if key, b, err = cborRead(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+			k, ok := key.(uint64)
+			if _, dup := m[k]; !ok || dup {
+				return nil, nil, ErrInvalidCBOR
+			}
+			if m[k], b, err = cborRead(b, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return m, b, nil
+	case cborSimple:
+		switch arg {
+		case cborFalse:
+			return false, b, nil
+		case cborTrue:
+			return true, b, nil
+		}
+	}
+
+	return nil, nil, ErrInvalidCBOR
+}
+
+// Decodes the initial byte and argument of the data item at the start of b.
+// Indefinite lengths, reserved values and arguments that are not in their
+// shortest form are rejected.
+func cborReadHead(b []byte) (major byte, arg uint64, rest []byte, err error) {
+	if len(b) < 1 {
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), b, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+	if len(b) < size {
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+
+	for _, c := range b[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	// The shortest form of the argument needs size bytes
+	if (size == 1 && arg < 24) || (size > 1 && arg>>(4*size) == 0) || major == cborSimple {
+		return 0, 0, nil, ErrInvalidCBOR
+	}
+
+	return major, arg, b[size:], nil
+}
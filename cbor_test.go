@@ -0,0 +1,81 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignature_CBOR(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, txid, err := signMessage("cbor test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Signatures round trip, keeping their envelope
+	b, err := sig.MarshalCBOR()
+	if err != nil {
+		t.Fatal("Failed to marshal signature -", err)
+	}
+	decoded := &Signature{}
+	if err := decoded.UnmarshalCBOR(b); err != nil {
+		t.Fatal("Failed to unmarshal signature -", err)
+	}
+	if !bytes.Equal(decoded.Envelope(), sig.Envelope()) || !bytes.Equal(decoded.Message, sig.Message) {
+		t.Fatal("Invalid unmarshalled signature")
+	}
+
+	// 2 - The encoding is compact: a map header, two keys and two byte string
+	// headers around the message and envelope
+	if len(b) != 1+2+2+len(sig.Message)+3+len(sig.Envelope()) {
+		t.Fatal("Encoding not compact -", len(b))
+	}
+
+	// 3 - Node descriptions and public trees round trip
+	nodes := tree.Nodes()
+	for i := range nodes {
+		nodes[i].Label = "label"
+		b, err := nodes[i].MarshalCBOR()
+		if err != nil {
+			t.Fatal("Failed to marshal node -", err)
+		}
+		var node NodeInfo
+		if err := node.UnmarshalCBOR(b); err != nil || !bytes.Equal(node.PubKeyHash, nodes[i].PubKeyHash) || node.Label != "label" || node.Depth != nodes[i].Depth {
+			t.Fatal("Failed to unmarshal node -", err)
+		}
+	}
+	pub := NewPubTree(tree.PublicKey())
+	if err := pub.Observe(sig, txid); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = pub.MarshalCBOR(); err != nil {
+		t.Fatal("Failed to marshal public tree -", err)
+	}
+	decodedPub := &NYPubTree{}
+	if err := decodedPub.UnmarshalCBOR(b); err != nil || !bytes.Equal(decodedPub.Bytes(), pub.Bytes()) {
+		t.Fatal("Failed to unmarshal public tree -", err)
+	}
+}
+
+func TestCBOR_Invalid(t *testing.T) {
+	for i, b := range [][]byte{
+		// 1 - Truncated and trailing data
+		{}, {0xa1, 0x01}, {0xa0, 0x00},
+		// 2 - Arguments not in their shortest form
+		{0xb8, 0x00}, {0xa1, 0x18, 0x01, 0x40},
+		// 3 - Indefinite lengths and unsupported items
+		{0xbf, 0xff}, {0xa1, 0x01, 0x20}, {0xa1, 0x01, 0xf6},
+		// 4 - Duplicate, unknown and non-integer keys
+		{0xa2, 0x01, 0x40, 0x01, 0x40}, {0xa1, 0x17, 0x40}, {0xa1, 0x60, 0x40},
+		// 5 - Lengths beyond the input
+		{0xa1, 0x01, 0x5a, 0xff, 0xff, 0xff, 0xff}, {0xa1, 0x03, 0x9a, 0xff, 0xff, 0xff, 0xff},
+	} {
+		if err := (&NodeInfo{}).UnmarshalCBOR(b); err != ErrInvalidCBOR {
+			t.Fatal("Decoded invalid encoding", i, "- err was", err)
+		}
+	}
+}
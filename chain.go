@@ -0,0 +1,91 @@
+package xnyss
+
+import "bytes"
+
+var ErrInvalidChain = newError(ErrEncoding, "invalid signature chain encoding")
+
+// Version byte of an encoded chain.
+const chainVersion = 0x01
+
+// Tags of the fields of an encoded chain. Every signature is encoded as its
+// message followed by its envelope.
+const (
+	chainFieldMessage  = 0x01
+	chainFieldEnvelope = 0x02
+)
+
+// A proof of ancestry: the signatures on the path from the root of a tree to
+// the node that signed a message, root first. See VerifyChain.
+type Chain []*Signature
+
+// Verifies that the chain c links the long-term public key rootPubKey to a
+// signature of msgHash, like VerifyChain.
+func (c Chain) Verify(rootPubKey, msgHash []byte) error {
+	return VerifyChain(rootPubKey, c, msgHash)
+}
+
+// Returns the signature of the message the chain c proves, or nil if c is
+// empty.
+func (c Chain) Last() *Signature {
+	if len(c) == 0 {
+		return nil
+	}
+
+	return c[len(c)-1]
+}
+
+// Returns the encoding of the chain c, which holds the messages and envelopes
+// of its signatures.
+func (c Chain) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(chainVersion)
+
+	for _, sig := range c {
+		writeField(buf, chainFieldMessage, sig.Message)
+		writeField(buf, chainFieldEnvelope, sig.Envelope())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a chain encoded by Chain.Bytes. The chain must still be verified
+// using Verify.
+func LoadChain(b []byte) (Chain, error) {
+	if len(b) < 1 || b[0] != chainVersion {
+		return nil, ErrInvalidChain
+	}
+
+	var chain Chain
+	var msg []byte
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case chainFieldMessage:
+			if msg != nil || len(value) > MsgLen {
+				return ErrInvalidChain
+			}
+			msg = value
+		case chainFieldEnvelope:
+			if msg == nil {
+				return ErrInvalidChain
+			}
+			sig, err := ParseEnvelope(value, append([]byte(nil), msg...))
+			if err != nil {
+				return err
+			}
+			chain = append(chain, sig)
+			msg = nil
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg != nil || len(chain) == 0 {
+		return nil, ErrInvalidChain
+	}
+
+	return chain, nil
+}
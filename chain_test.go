@@ -0,0 +1,60 @@
+package xnyss
+
+import "testing"
+
+func TestChain(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+
+	var chain Chain
+	for i := 0; i < 3; i++ {
+		sig, _, err := signMessage(string(rune('a'+i)), tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+	}
+	msg := chain.Last().Message
+
+	// 1 - A chain proves the ancestry of its last signature
+	if err := chain.Verify(pk, msg); err != nil {
+		t.Fatal("Failed to verify chain -", err)
+	}
+
+	// 2 - Chains survive encoding, including the messages of their links
+	loaded, err := LoadChain(chain.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load chain -", err)
+	}
+	if len(loaded) != len(chain) {
+		t.Fatal("Loaded chain has", len(loaded), "signatures, expected", len(chain))
+	}
+	if err := loaded.Verify(pk, msg); err != nil {
+		t.Fatal("Failed to verify loaded chain -", err)
+	}
+
+	// 3 - Chains with a missing link or a wrong root are rejected
+	if err := (Chain{chain[0], chain[2]}).Verify(pk, msg); err != ErrChainBroken {
+		t.Fatal("Verified chain with missing link, err was", err)
+	}
+	if err := chain[1:].Verify(pk, msg); err != ErrChainBroken {
+		t.Fatal("Verified chain without root signature, err was", err)
+	}
+
+	// 4 - Malformed encodings are rejected
+	if _, err := LoadChain(nil); err != ErrInvalidChain {
+		t.Fatal("Loaded empty encoding, err was", err)
+	}
+	if _, err := LoadChain([]byte{chainVersion}); err != ErrInvalidChain {
+		t.Fatal("Loaded chain without signatures, err was", err)
+	}
+	b := chain.Bytes()
+	if _, err := LoadChain(b[:len(b)-1]); err == nil {
+		t.Fatal("Loaded truncated chain")
+	}
+}
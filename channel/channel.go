@@ -0,0 +1,163 @@
+// Implements co-signing of alternating updates between two parties, as used by
+// payment channel style protocols, on top of XNYSS trees.
+//
+// Every party signs its updates with a subtree of its own tree that is
+// dedicated to the counterparty (see NYTree.SetLabel), so channels with
+// different counterparties never compete for nodes. Every update carries a
+// counter that must increase monotonically, and that is bound into the signed
+// digest together with the channel ID, so updates can neither be replayed nor
+// reordered. A party verifies the updates of its counterparty against the
+// counterparty's chain: the first update must be signed by one of the nodes it
+// was told to trust, every next one by a child of an earlier update.
+//
+// Channels do not wait for blockchain confirmations: the child nodes of every
+// update are confirmed immediately, since the counterparty learns them from the
+// update itself.
+package channel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrStaleUpdate     = errors.New("update counter does not increase")
+	ErrUntrustedUpdate = errors.New("update is not signed by the counterparty's chain")
+	ErrWrongChannel    = errors.New("update belongs to a different channel")
+)
+
+// Domain separation prefix of update digests.
+const updateDomain = "xnyss channel update"
+
+// A signed channel update.
+type Update struct {
+	Channel string
+	Counter uint64
+	// H(payload) of the application's update
+	PayloadHash []byte
+	Signature   *xnyss.Signature
+}
+
+// Returns the digest signed for an update.
+func digest(channel string, counter uint64, payloadHash []byte) []byte {
+	var c [8]byte
+	binary.BigEndian.PutUint64(c[:], counter)
+
+	h := sha256.New()
+	h.Write([]byte(updateDomain))
+	h.Write([]byte(channel))
+	h.Write(c[:])
+	h.Write(payloadHash)
+	return h.Sum(nil)
+}
+
+// One party's end of a channel.
+type Channel struct {
+	id    string
+	tree  *xnyss.NYTree
+	label string
+
+	counter     uint64
+	peerCounter uint64
+	// Public key hashes of the counterparty's nodes that may sign its next
+	// update
+	peerFrontier [][]byte
+}
+
+// Opens a channel with the ID id. Updates are signed using the subtree of tree
+// labelled label, which must have been assigned a node (see NYTree.SetLabel).
+// The counterparty's updates are verified starting from the nodes in
+// peerTrusted, e.g. the public key hashes it dedicated to this channel.
+//
+// The counters of both parties start at 0; the first update has counter 1.
+func Open(id string, tree *xnyss.NYTree, label string, peerTrusted [][]byte) *Channel {
+	return &Channel{
+		id:           id,
+		tree:         tree,
+		label:        label,
+		peerFrontier: append([][]byte(nil), peerTrusted...),
+	}
+}
+
+// Signs the next update, for the given payload.
+func (c *Channel) Sign(payload []byte) (*Update, error) {
+	counter := c.counter + 1
+	payloadHash := sha256.Sum256(payload)
+
+	var ctr [8]byte
+	binary.BigEndian.PutUint64(ctr[:], counter)
+	txid := xnyss.Txid(updateDomain, append([]byte(c.id), ctr[:]...))
+
+	sig, err := c.tree.SignLabel(digest(c.id, counter, payloadHash[:]), txid, c.label, c.label)
+	if err != nil {
+		return nil, err
+	}
+
+	// The counterparty learns the children from the update, no need to wait
+	for _, pkh := range sig.ChildHashes {
+		c.tree.Confirm(pkh, xnyss.ConfirmsRequired)
+	}
+	c.counter = counter
+
+	return &Update{
+		Channel:     c.id,
+		Counter:     counter,
+		PayloadHash: payloadHash[:],
+		Signature:   sig,
+	}, nil
+}
+
+// Verifies an update of the counterparty for the given payload, and accepts it
+// on success: later updates must have a higher counter, and may be signed by
+// the children of this update.
+func (c *Channel) Accept(u *Update, payload []byte) error {
+	if u.Channel != c.id {
+		return ErrWrongChannel
+	}
+	if u.Counter <= c.peerCounter {
+		return ErrStaleUpdate
+	}
+
+	payloadHash := sha256.Sum256(payload)
+	if !bytes.Equal(u.PayloadHash, payloadHash[:]) ||
+		!bytes.Equal(u.Signature.Message, digest(c.id, u.Counter, payloadHash[:])) {
+		return ErrUntrustedUpdate
+	}
+
+	pk, err := u.Signature.PublicKey()
+	if err != nil {
+		return err
+	}
+	pkh := sha256.Sum256(pk)
+
+	index := -1
+	for i, trusted := range c.peerFrontier {
+		if bytes.Equal(trusted, pkh[:]) {
+			index = i
+		}
+	}
+	if index < 0 {
+		return ErrUntrustedUpdate
+	}
+
+	// The signing node is used up, its children take its place
+	c.peerFrontier = append(c.peerFrontier[:index], c.peerFrontier[index+1:]...)
+	c.peerFrontier = append(c.peerFrontier, u.Signature.ChildHashes...)
+	c.peerCounter = u.Counter
+
+	return nil
+}
+
+// Returns the counter of the last update signed by this party.
+func (c *Channel) Counter() uint64 {
+	return c.counter
+}
+
+// Returns the counter of the last accepted update of the counterparty.
+func (c *Channel) PeerCounter() uint64 {
+	return c.peerCounter
+}
@@ -0,0 +1,82 @@
+package channel
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+// Creates a tree with a confirmed node labelled label, and returns the tree and
+// the public key hash of that node.
+func newParty(t *testing.T, label string) (*xnyss.NYTree, []byte) {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree := xnyss.New(seeds[:32], seeds[32:], false)
+
+	sig, err := tree.Sign(make([]byte, 32), xnyss.Txid("setup", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Confirm(sig.ChildHashes[0], xnyss.ConfirmsRequired)
+	tree.SetLabel(sig.ChildHashes[0], label)
+
+	return tree, sig.ChildHashes[0]
+}
+
+func TestChannel(t *testing.T) {
+	aliceTree, aliceNode := newParty(t, "bob")
+	bobTree, bobNode := newParty(t, "alice")
+
+	alice := Open("alice-bob", aliceTree, "bob", [][]byte{bobNode})
+	bob := Open("alice-bob", bobTree, "alice", [][]byte{aliceNode})
+
+	// 1 - Parties alternately sign and accept updates
+	for i := 0; i < 3; i++ {
+		u, err := alice.Sign([]byte("alice update"))
+		if err != nil {
+			t.Fatal("Alice failed to sign -", err)
+		}
+		if err := bob.Accept(u, []byte("alice update")); err != nil {
+			t.Fatal("Bob failed to accept -", err)
+		}
+
+		u, err = bob.Sign([]byte("bob update"))
+		if err != nil {
+			t.Fatal("Bob failed to sign -", err)
+		}
+		if err := alice.Accept(u, []byte("bob update")); err != nil {
+			t.Fatal("Alice failed to accept -", err)
+		}
+	}
+	if alice.Counter() != 3 || alice.PeerCounter() != 3 {
+		t.Fatal("Invalid counters", alice.Counter(), alice.PeerCounter())
+	}
+
+	// 2 - Replayed, modified and foreign updates are rejected
+	u, err := alice.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bob.Accept(u, []byte("other payload")); err != ErrUntrustedUpdate {
+		t.Fatal("Accepted update for other payload, err was", err)
+	}
+	if err := bob.Accept(u, []byte("payload")); err != nil {
+		t.Fatal("Failed to accept -", err)
+	}
+	if err := bob.Accept(u, []byte("payload")); err != ErrStaleUpdate {
+		t.Fatal("Accepted replayed update, err was", err)
+	}
+
+	forged := *u
+	forged.Counter++
+	if err := bob.Accept(&forged, []byte("payload")); err != ErrUntrustedUpdate {
+		t.Fatal("Accepted update with modified counter, err was", err)
+	}
+	forged.Channel = "other"
+	if err := bob.Accept(&forged, []byte("payload")); err != ErrWrongChannel {
+		t.Fatal("Accepted update of other channel, err was", err)
+	}
+}
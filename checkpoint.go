@@ -0,0 +1,203 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+var (
+	ErrCheckpointUntrusted = newError(ErrCrypto, "checkpoint is not signed by a trusted node")
+	ErrInvalidCheckpoint   = newError(ErrEncoding, "invalid checkpoint encoding")
+)
+
+// Domain separation prefix of checkpoint digests.
+const checkpointDomain = "xnyss checkpoint"
+
+// Version byte of an encoded checkpoint.
+const checkpointVersion = 0x01
+
+// Tags of the fields of an encoded checkpoint.
+const (
+	checkpointFieldEpoch    = 0x01
+	checkpointFieldFrontier = 0x02
+	checkpointFieldEnvelope = 0x03
+)
+
+// A signed statement of the frontier of a tree: the public key hashes of all
+// nodes in the tree at a given epoch. Verifiers that trust a checkpoint can
+// verify signatures of nodes in its frontier (and their descendants) without
+// replaying the signature history before it.
+type Checkpoint struct {
+	Epoch uint64
+	// Public key hashes of the nodes of the tree, sorted, excluding the node
+	// that signed the checkpoint.
+	Frontier  [][]byte
+	Signature *Signature
+}
+
+// Returns the digest signed by a checkpoint.
+func checkpointDigest(epoch uint64, frontier [][]byte) []byte {
+	var e [8]byte
+	binary.BigEndian.PutUint64(e[:], epoch)
+
+	h := sha256.New()
+	h.Write([]byte(checkpointDomain))
+	h.Write(e[:])
+	for _, pkh := range frontier {
+		h.Write(pkh)
+	}
+
+	return h.Sum(nil)
+}
+
+// Signs a checkpoint of the current frontier of the tree t. The checkpoint is
+// signed by a node that verifiers already trust: one of the public key hashes
+// in trusted, typically Trusted() of the previous checkpoint. If trusted is
+// nil, the checkpoint must be signed by the root.
+//
+// Verifiers can thereby follow the chain of checkpoints, each verified against
+// the one before, instead of every signature of the tree.
+func (t *NYTree) Checkpoint(txid []byte, trusted [][]byte) (*Checkpoint, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	isTrusted := func(n *nyNode) bool {
+		if trusted == nil {
+			return t.isRoot(n)
+		}
+		return containsHash(trusted, n.pubKeyHash())
+	}
+
+	index := t.getSignNode(t.lookupTxid(txid), isTrusted)
+	if index < 0 {
+		return nil, ErrCheckpointUntrusted
+	}
+	signer := t.nodes[index]
+
+	cp := &Checkpoint{Epoch: t.epoch}
+	for _, node := range t.nodes {
+		if node != signer {
+			cp.Frontier = append(cp.Frontier, append([]byte(nil), node.pubKeyHash()...))
+		}
+	}
+	sort.Slice(cp.Frontier, func(i, j int) bool {
+		return bytes.Compare(cp.Frontier[i], cp.Frontier[j]) < 0
+	})
+
+	var err error
+	profile(OpSign, t, func() {
+		cp.Signature, err = t.sign(checkpointDigest(cp.Epoch, cp.Frontier), txid, &signOptions{
+			filter: func(n *nyNode) bool { return n == signer },
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+// Verifies the checkpoint cp, which must be signed by the root key rootPubKey
+// if trusted is nil, or by a node with one of the public key hashes in trusted.
+func (cp *Checkpoint) Verify(rootPubKey []byte, trusted [][]byte) error {
+	if cp.Signature == nil || !bytes.Equal(cp.Signature.Message, checkpointDigest(cp.Epoch, cp.Frontier)) {
+		return ErrCheckpointUntrusted
+	}
+
+	pk, err := cp.Signature.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	if trusted == nil {
+		if !bytes.Equal(pk, rootPubKey) {
+			return ErrCheckpointUntrusted
+		}
+		return nil
+	}
+
+	if !containsHash(trusted, cp.Signature.suite.sum(pk)) {
+		return ErrCheckpointUntrusted
+	}
+
+	return nil
+}
+
+// Returns the public key hashes a verifier trusts after verifying cp: its
+// frontier and the children of its signature.
+func (cp *Checkpoint) Trusted() [][]byte {
+	trusted := make([][]byte, 0, len(cp.Frontier)+len(cp.Signature.ChildHashes))
+	trusted = append(trusted, cp.Frontier...)
+	trusted = append(trusted, cp.Signature.ChildHashes...)
+
+	return trusted
+}
+
+// Returns the encoding of the checkpoint cp.
+func (cp *Checkpoint) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(checkpointVersion)
+
+	var epoch [8]byte
+	binary.BigEndian.PutUint64(epoch[:], cp.Epoch)
+	writeField(buf, checkpointFieldEpoch, epoch[:])
+	writeField(buf, checkpointFieldFrontier, bytes.Join(cp.Frontier, nil))
+	writeField(buf, checkpointFieldEnvelope, cp.Signature.Envelope())
+
+	return buf.Bytes()
+}
+
+// Decodes a checkpoint encoded by Checkpoint.Bytes. The checkpoint must still
+// be verified using Verify.
+func LoadCheckpoint(b []byte) (*Checkpoint, error) {
+	if len(b) < 1 || b[0] != checkpointVersion {
+		return nil, ErrInvalidCheckpoint
+	}
+
+	cp := &Checkpoint{}
+	var envelope []byte
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case checkpointFieldEpoch:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			cp.Epoch = binary.BigEndian.Uint64(value)
+		case checkpointFieldFrontier:
+			if len(value)%32 != 0 {
+				return ErrFieldInvalid
+			}
+			for i := 0; i < len(value); i += 32 {
+				cp.Frontier = append(cp.Frontier, append([]byte(nil), value[i:i+32]...))
+			}
+		case checkpointFieldEnvelope:
+			envelope = value
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cp.Signature, err = ParseEnvelope(envelope, checkpointDigest(cp.Epoch, cp.Frontier))
+	if err != nil {
+		return nil, err
+	}
+
+	return cp, nil
+}
+
+func containsHash(hashes [][]byte, h []byte) bool {
+	for _, x := range hashes {
+		if bytes.Equal(x, h) {
+			return true
+		}
+	}
+
+	return false
+}
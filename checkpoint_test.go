@@ -0,0 +1,63 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_Checkpoint(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+
+	// 1 - The first checkpoint is signed by the root
+	first, err := tree.Checkpoint(Txid("checkpoint", []byte{1}), nil)
+	if err != nil {
+		t.Fatal("Failed to create checkpoint -", err)
+	}
+	if err := first.Verify(pk, nil); err != nil {
+		t.Fatal("Failed to verify checkpoint -", err)
+	}
+	for _, pkh := range first.Signature.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 2 - Signatures of other operations grow the tree, the next checkpoint
+	// covers their nodes
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+	second, err := tree.Checkpoint(Txid("checkpoint", []byte{2}), first.Trusted())
+	if err != nil {
+		t.Fatal("Failed to create second checkpoint -", err)
+	}
+	loaded, err := LoadCheckpoint(second.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load checkpoint -", err)
+	}
+	if err := loaded.Verify(pk, first.Trusted()); err != nil {
+		t.Fatal("Failed to verify second checkpoint -", err)
+	}
+	if !containsHash(loaded.Frontier, sig.ChildHashes[0]) {
+		t.Fatal("Checkpoint frontier misses signed children")
+	}
+
+	// 3 - Checkpoints are only accepted from trusted nodes
+	if err := loaded.Verify(pk, nil); err != ErrCheckpointUntrusted {
+		t.Fatal("Verified checkpoint against wrong trust anchor, err was", err)
+	}
+	loaded.Epoch++
+	if err := loaded.Verify(pk, first.Trusted()); err != ErrCheckpointUntrusted {
+		t.Fatal("Verified modified checkpoint, err was", err)
+	}
+	if _, err := tree.Checkpoint(Txid("checkpoint", []byte{3}), nil); err != ErrCheckpointUntrusted {
+		t.Fatal("Signed checkpoint with used root, err was", err)
+	}
+}
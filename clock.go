@@ -0,0 +1,75 @@
+package xnyss
+
+import (
+	"sync"
+	"time"
+)
+
+// A source of the current time, used for reservation expiry (see
+// ReservationTTL), rate limits, lease expiry and the creation time of nodes
+// (see Prune). Tests can control time using ManualClock, and embedded systems
+// without a reliable clock can supply a counter, e.g. of blocks, as time.
+type Clock interface {
+	Now() time.Time
+}
+
+// The clock of the system, used unless another clock is set.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// A clock that only advances when told to. It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Creates a manual clock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Moves the clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// Sets the clock to now, which may be before its current time.
+func (c *ManualClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = now
+}
+
+// Sets the clock used by the tree t, or SystemClock if c is nil. The clock is
+// not persisted.
+func (t *NYTree) SetClock(c Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.clock = c
+}
+
+// Returns the current time according to the clock of t.
+func (t *NYTree) now() time.Time {
+	if t.clock == nil {
+		return SystemClock.Now()
+	}
+
+	return t.clock.Now()
+}
@@ -0,0 +1,76 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetClock(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	clock := NewManualClock(time.Unix(1000, 0))
+	tree.SetClock(clock)
+
+	// 1 - Reservations expire according to the clock
+	if _, err := tree.Reserve(testdata.Txid); err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	clock.Advance(ReservationTTL - time.Second)
+	if tree.ReapReservations() != 0 {
+		t.Fatal("Reservation expired early")
+	}
+	clock.Advance(time.Second)
+	if tree.ReapReservations() != 1 {
+		t.Fatal("Reservation did not expire")
+	}
+
+	// 2 - Rate limits refill according to the clock
+	tree.SetRateLimit(RateLimit{Rate: 1, Burst: 1}, RateLimit{})
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != ErrRateLimited {
+		t.Fatal("Rate limit not applied, err was", err)
+	}
+	clock.Advance(time.Second)
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal("Rate limit not refilled -", err)
+	}
+
+	// 3 - Nodes record their creation time according to the clock
+	if pruned := tree.Prune(PrunePolicy{UnconfirmedBefore: time.Unix(1000, 0)}); len(pruned) != 0 {
+		t.Fatal("Nodes were created before the time of the clock")
+	}
+	unconfirmed := len(tree.Unconfirmed())
+	if pruned := tree.Prune(PrunePolicy{UnconfirmedBefore: clock.Now().Add(time.Second)}); len(pruned) != unconfirmed {
+		t.Fatal("Nodes were not created at the time of the clock")
+	}
+}
+
+func TestLeaseTable_SetClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	table := NewLeaseTable(time.Minute)
+	table.SetClock(clock)
+	a, b := table.Owner("a"), table.Owner("b")
+
+	// 1 - Leases expire according to the clock
+	if err := a.Acquire("key"); err != nil {
+		t.Fatal("Failed to acquire lease -", err)
+	}
+	clock.Advance(time.Minute - time.Second)
+	if err := b.Acquire("key"); err != ErrFenceHeld {
+		t.Fatal("Acquired held lease, err was", err)
+	}
+	clock.Advance(time.Second)
+	if err := a.Check("key"); err != ErrFenceNotHolder {
+		t.Fatal("Lease did not expire, err was", err)
+	}
+	if err := b.Acquire("key"); err != nil {
+		t.Fatal("Failed to acquire expired lease -", err)
+	}
+}
@@ -0,0 +1,139 @@
+//go:build xnyssexp
+
+// Command xnyss-signd serves an XNYSS tree to other applications, see package
+// signd:
+//
+//	xnyss-signd -storage dir -keys keys.txt -audit audit.log [-addr :8443]
+//	    [-tls-cert cert.pem -tls-key key.pem]
+//
+// The tree is loaded from the file storage in the storage directory, and kept
+// in write-through mode, so every signature is durable before it is returned.
+// Each line of the keys file registers an API key as
+//
+//	name roles key
+//
+// where roles is a comma separated list of sign, confirm and admin. Blank lines
+// and lines starting with # are ignored. The audit log is verified and resumed
+// on start. Without a certificate the API is served over plain HTTP, which is
+// only safe behind a TLS terminating proxy.
+//
+// Like package signd, the command is experimental and only built with the
+// xnyssexp build tag:
+//
+//	go build -tags xnyssexp ./cmd/xnyss-signd
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Re0h/xnyss"
+	"github.com/Re0h/xnyss/access"
+	"github.com/Re0h/xnyss/signd"
+)
+
+func main() {
+	storage := flag.String("storage", "", "directory of the file storage of the tree")
+	keys := flag.String("keys", "", "file of the API keys")
+	audit := flag.String("audit", "xnyss-signd.log", "file of the audit log")
+	addr := flag.String("addr", ":8443", "address to listen on")
+	cert := flag.String("tls-cert", "", "certificate of the server")
+	key := flag.String("tls-key", "", "private key of the server")
+	flag.Parse()
+
+	if *storage == "" || *keys == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*storage, *keys, *audit, *addr, *cert, *key); err != nil {
+		fmt.Fprintln(os.Stderr, "xnyss-signd:", err)
+		os.Exit(1)
+	}
+}
+
+func run(storage, keys, audit, addr, cert, key string) error {
+	s, err := xnyss.NewFileStorage(storage)
+	if err != nil {
+		return err
+	}
+	tree, err := xnyss.LoadStorage(s)
+	if err != nil {
+		return fmt.Errorf("failed to load tree: %v", err)
+	}
+	defer tree.Wipe()
+
+	auth := access.NewAuthenticator()
+	if err := loadKeys(auth, keys); err != nil {
+		return err
+	}
+
+	log, err := openLog(audit)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: addr, Handler: signd.NewServer(access.NewGuard(tree, log), auth)}
+	if cert == "" {
+		return server.ListenAndServe()
+	}
+
+	return server.ListenAndServeTLS(cert, key)
+}
+
+// Registers the API keys of the keys file at path.
+func loadKeys(auth *access.Authenticator, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return fmt.Errorf("%s:%d: expected name, roles and key", path, line)
+		}
+		var roles access.Role
+		for _, r := range strings.Split(fields[1], ",") {
+			switch r {
+			case "sign":
+				roles |= access.RoleSign
+			case "confirm":
+				roles |= access.RoleConfirm
+			case "admin":
+				roles |= access.RoleAdmin
+			default:
+				return fmt.Errorf("%s:%d: unknown role %q", path, line, r)
+			}
+		}
+		auth.AddKey(fields[2], access.Principal{Name: fields[0], Roles: roles})
+	}
+
+	return scanner.Err()
+}
+
+// Opens the audit log at path, resuming it if it exists.
+func openLog(path string) (*access.Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	log, err := access.ResumeLog(f, f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to resume audit log: %v", err)
+	}
+
+	return log, nil
+}
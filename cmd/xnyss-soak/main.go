@@ -0,0 +1,260 @@
+// Command xnyss-soak runs continuous sign, confirm and backup cycles against a
+// persisted XNYSS tree, verifying invariants after every step, to catch slow
+// corruption bugs before releases. It is meant to run for hours:
+//
+//	xnyss-soak -state soak.state -duration 6h
+//
+// After every cycle the tree is written to the state file and loaded again,
+// and the following invariants are checked:
+//
+//   - no one-time key signs twice, and no consumed node returns to the tree;
+//   - every signature verifies against the long-term key through its chain
+//     (for nodes created during this run);
+//   - the reloaded state is identical to the state that was written, and
+//     passes Validate;
+//   - the nodes moved into a backup are disjoint from the remaining tree.
+//
+// The soak stops with a non-zero exit status at the first violation. Restarting
+// with the same state file continues the run.
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"time"
+
+	"github.com/Re0h/xnyss"
+)
+
+type soak struct {
+	statePath string
+	tree      *xnyss.NYTree
+	pubKey    []byte
+	rng       *mrand.Rand
+
+	// Recovered one-time public keys seen during this run
+	used map[string]bool
+	// Chains of the signatures that created the nodes, by public key hash
+	chains map[string][]*xnyss.Signature
+
+	signatures, confirms, backups, reloads int
+}
+
+func main() {
+	statePath := flag.String("state", "xnyss-soak.state", "file holding the persisted tree")
+	duration := flag.Duration("duration", time.Hour, "how long to run")
+	report := flag.Duration("report", time.Minute, "interval between progress reports")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "seed of the operation sequence")
+	flag.Parse()
+
+	s, err := newSoak(*statePath, *seed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to open state:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("soaking tree %s for %s (seed %d)\n", s.tree.ID(), *duration, *seed)
+
+	deadline := time.Now().Add(*duration)
+	nextReport := time.Now().Add(*report)
+	for time.Now().Before(deadline) {
+		if err := s.cycle(); err != nil {
+			fmt.Fprintln(os.Stderr, "invariant violated:", err)
+			os.Exit(1)
+		}
+
+		if time.Now().After(nextReport) {
+			s.report()
+			nextReport = nextReport.Add(*report)
+		}
+	}
+
+	s.report()
+}
+
+func newSoak(statePath string, seed int64) (*soak, error) {
+	s := &soak{
+		statePath: statePath,
+		rng:       mrand.New(mrand.NewSource(seed)),
+		used:      make(map[string]bool),
+		chains:    make(map[string][]*xnyss.Signature),
+	}
+
+	state, err := os.ReadFile(statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		seeds := make([]byte, 64)
+		if _, err := rand.Read(seeds); err != nil {
+			return nil, err
+		}
+		s.tree = xnyss.New(seeds[:32], seeds[32:], false)
+		if err := s.persist(); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	} else if s.tree, err = xnyss.Load(state); err != nil {
+		return nil, err
+	}
+
+	s.pubKey = s.tree.PublicKey()
+	return s, nil
+}
+
+// Runs a single cycle of operations, and checks the persisted state after.
+func (s *soak) cycle() error {
+	switch op := s.rng.Intn(10); {
+	case op < 6:
+		if err := s.sign(s.tree); err != nil {
+			return err
+		}
+	case op < 9:
+		s.confirm()
+	default:
+		if err := s.backup(); err != nil {
+			return err
+		}
+	}
+
+	return s.reload()
+}
+
+func (s *soak) sign(tree *xnyss.NYTree) error {
+	msg := make([]byte, xnyss.MsgLen)
+	s.rng.Read(msg)
+	txid := xnyss.Txid("xnyss-soak", []byte{byte(s.rng.Intn(4))})
+
+	sig, err := tree.Sign(msg, txid)
+	if err == xnyss.ErrTreeNoneAvailable {
+		// Out of usable nodes: confirm everything so the next cycle can sign
+		for _, pkh := range tree.Unconfirmed() {
+			tree.Confirm(pkh, xnyss.ConfirmsRequired)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("sign: %v", err)
+	}
+	s.signatures++
+
+	pk, err := sig.PublicKey()
+	if err != nil {
+		return fmt.Errorf("public key: %v", err)
+	}
+	if s.used[string(pk)] {
+		return errors.New("one-time key signed twice")
+	}
+	s.used[string(pk)] = true
+
+	pkh := sha256.Sum256(pk)
+	if !contains(tree.Consumed(), pkh[:]) {
+		return errors.New("signing node was not recorded as consumed")
+	}
+
+	chain, known := s.chains[string(pkh[:])]
+	delete(s.chains, string(pkh[:]))
+	if known || bytes.Equal(pk, s.pubKey) {
+		chain = append(append([]*xnyss.Signature(nil), chain...), sig)
+		if err := xnyss.VerifyChain(s.pubKey, chain, msg); err != nil {
+			return fmt.Errorf("verify chain: %v", err)
+		}
+		for _, child := range sig.ChildHashes {
+			s.chains[string(child)] = chain
+		}
+	}
+
+	return nil
+}
+
+func (s *soak) confirm() {
+	unconfirmed := s.tree.Unconfirmed()
+	if len(unconfirmed) == 0 {
+		return
+	}
+
+	s.tree.Confirm(unconfirmed[s.rng.Intn(len(unconfirmed))], xnyss.ConfirmsRequired)
+	s.confirms++
+}
+
+// Moves a node into a backup and signs with the backup once, checking the node
+// is no longer in the tree.
+func (s *soak) backup() error {
+	backup, err := s.tree.Backup(1)
+	if err == xnyss.ErrTreeBackupFailed {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("backup: %v", err)
+	}
+	s.backups++
+
+	if err := backup.ValidateState(); err != nil {
+		return fmt.Errorf("validate backup: %v", err)
+	}
+
+	if err := s.sign(backup); err != nil {
+		return fmt.Errorf("backup %v", err)
+	}
+
+	// The tree learns about the signature of the backup, which must not
+	// remove any node: the node that signed was moved out of the tree
+	if s.tree.MarkConsumed(backup.Consumed()) != 0 {
+		return errors.New("backup shares a node with the tree")
+	}
+	return nil
+}
+
+func contains(pkhashes [][]byte, pkh []byte) bool {
+	for _, p := range pkhashes {
+		if bytes.Equal(p, pkh) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Persists the tree, loads it again and checks the loaded state.
+func (s *soak) reload() error {
+	if err := s.persist(); err != nil {
+		return fmt.Errorf("persist: %v", err)
+	}
+
+	b, err := os.ReadFile(s.statePath)
+	if err != nil {
+		return fmt.Errorf("read state: %v", err)
+	}
+	loaded, err := xnyss.Load(b)
+	if err != nil {
+		return fmt.Errorf("load: %v", err)
+	}
+	s.reloads++
+
+	if !bytes.Equal(loaded.Bytes(), s.tree.Bytes()) || loaded.Epoch() != s.tree.Epoch() {
+		return errors.New("state replay does not match the written state")
+	}
+	if err := loaded.ValidateState(); err != nil {
+		return fmt.Errorf("validate: %v", err)
+	}
+
+	s.tree = loaded
+	return nil
+}
+
+// Writes the state file atomically, so an interrupted soak can be resumed.
+func (s *soak) persist() error {
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, s.tree.Bytes(), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.statePath)
+}
+
+func (s *soak) report() {
+	fmt.Printf("%s epoch=%d signatures=%d confirms=%d backups=%d reloads=%d available=%d\n",
+		time.Now().Format(time.RFC3339), s.tree.Epoch(), s.signatures, s.confirms,
+		s.backups, s.reloads, s.tree.Available(nil))
+}
@@ -0,0 +1,122 @@
+// Command xnyss-vectors emits a JSON corpus of test vectors that can be used to
+// validate ports of this package to other languages. The corpus covers both
+// WOTS+ layers (wotsp with w=16 and wotsp256 with w=256) and the XNYSS tree
+// construction built on top of wotsp256.
+//
+// All WOTS+ inputs are derived deterministically from the -seed flag, so the
+// same invocation always produces the same WOTS+ vectors. The XNYSS section is
+// the output of xnyss.GenerateVectors for the -seed and -n flags, so with the
+// default flags it matches the golden vectors checked in at
+// testdata/vectors.json.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Re0h/xnyss"
+	"github.com/Re0h/xnyss/wotsp"
+	"github.com/Re0h/xnyss/wotsp256"
+)
+
+type wotsVector struct {
+	Seed      string `json:"seed"`
+	PubSeed   string `json:"pubSeed"`
+	Address   string `json:"address"`
+	Message   string `json:"message"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+type corpus struct {
+	Version  int                `json:"version"`
+	Wotsp    []wotsVector       `json:"wotsp"`
+	Wotsp256 []wotsVector       `json:"wotsp256"`
+	Trees    []xnyss.TestVector `json:"xnyss"`
+}
+
+// Deterministically derives 32-byte inputs from a base seed, a label and a
+// counter.
+type deriver struct {
+	base []byte
+	ctr  uint32
+}
+
+func (d *deriver) next(label string) []byte {
+	ctr := make([]byte, 4)
+	binary.BigEndian.PutUint32(ctr, d.ctr)
+	d.ctr++
+
+	s := sha256.New()
+	s.Write(d.base)
+	s.Write([]byte(label))
+	s.Write(ctr)
+
+	return s.Sum(nil)
+}
+
+// Derives count WOTS+ vectors for the parameter set p.
+func wotsVectors(d *deriver, p wotsp.Params, count int) []wotsVector {
+	vectors := make([]wotsVector, count)
+	for i := range vectors {
+		seed, pubSeed, msg := d.next("seed"), d.next("pubSeed"), d.next("msg")
+
+		adrs := &wotsp.Address{}
+		adrs.SetLayer(uint32(i))
+		adrs.SetTree(uint64(i) << 32)
+		adrs.SetOTS(uint32(i * 7))
+
+		vectors[i] = wotsVector{
+			Seed:      hex.EncodeToString(seed),
+			PubSeed:   hex.EncodeToString(pubSeed),
+			Address:   hex.EncodeToString(adrs.ToBytes()),
+			Message:   hex.EncodeToString(msg),
+			PublicKey: hex.EncodeToString(p.GenPublicKey(seed, pubSeed, adrs)),
+			Signature: hex.EncodeToString(p.Sign(msg, seed, pubSeed, adrs)),
+		}
+	}
+
+	return vectors
+}
+
+func main() {
+	seed := flag.String("seed", "xnyss test vectors", "base seed from which all inputs are derived")
+	count := flag.Int("n", 3, "number of vectors per section")
+	out := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	d := &deriver{base: []byte(*seed)}
+	c := &corpus{
+		Version:  2,
+		Wotsp:    wotsVectors(d, wotsp.W16, *count),
+		Wotsp256: wotsVectors(d, wotsp256.Params, *count),
+	}
+
+	trees, err := xnyss.GenerateVectors([]byte(*seed), *count)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to generate tree vectors:", err)
+		os.Exit(1)
+	}
+	c.Trees = trees
+
+	enc, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to encode corpus:", err)
+		os.Exit(1)
+	}
+	enc = append(enc, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(enc)
+		return
+	}
+	if err := os.WriteFile(*out, enc, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "failed to write corpus:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,241 @@
+// Command xnyss provides tools for working with XNYSS tree states:
+//
+//	xnyss inspect [-json] state-file
+//	xnyss export [-o archive] state-file...
+//	xnyss import [-dir directory] archive
+//
+// The inspect command decodes a serialised tree and prints a summary of its
+// parameters, its capacity, the txids of unconfirmed nodes and a table of its
+// nodes. Secret seeds are never printed: nodes are identified by the hash of
+// their public key. A state file of "-" is read from standard input.
+//
+// The export and import commands migrate a fleet of trees between hosts. Export
+// writes the given states into one encrypted migration archive, and import
+// verifies the archive and writes its states into the directory, one file per
+// tree named after the hash of its long-term key. Import fails without writing
+// anything if a tree does not match the manifest of the archive, or if the
+// directory already holds a state with the same long-term key. The passphrase
+// of the archive is read from the XNYSS_PASSPHRASE environment variable. The
+// exported states must not be used once the archive was imported, or nodes
+// would sign twice.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Re0h/xnyss"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "inspect":
+		err = inspect(os.Args[2:])
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importArchive(os.Args[2:])
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "xnyss:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: xnyss inspect [-json] state-file")
+	fmt.Fprintln(os.Stderr, "       xnyss export [-o archive] state-file...")
+	fmt.Fprintln(os.Stderr, "       xnyss import [-dir directory] archive")
+	os.Exit(2)
+}
+
+type summary struct {
+	ID            string         `json:"id"`
+	PublicKeyHash string         `json:"publicKeyHash"`
+	Mode          string         `json:"mode"`
+	Epoch         uint64         `json:"epoch"`
+	Strict        bool           `json:"strict"`
+	RootLocked    bool           `json:"rootLocked"`
+	Deterministic bool           `json:"deterministic"`
+	Addressed     bool           `json:"addressed"`
+	Spent         bool           `json:"spent"`
+	Branching     []int          `json:"branching,omitempty"`
+	Counter       uint64         `json:"counter"`
+	Consumed      int            `json:"consumed"`
+	Available     int            `json:"available"`
+	ByLabel       map[string]int `json:"availableByLabel"`
+	Exhausted     bool           `json:"exhausted"`
+	// Unconfirmed nodes per txid
+	Pending map[string]int `json:"pendingTxids"`
+	Nodes   []node         `json:"nodes"`
+}
+
+type node struct {
+	PubKeyHash string `json:"pubKeyHash"`
+	Txid       string `json:"txid"`
+	Confirms   uint8  `json:"confirms"`
+	Depth      uint32 `json:"depth"`
+	Label      string `json:"label,omitempty"`
+	Root       bool   `json:"root,omitempty"`
+	Reserved   bool   `json:"reserved,omitempty"`
+}
+
+func inspect(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	var state []byte
+	var err error
+	if path := flags.Arg(0); path == "-" {
+		state, err = io.ReadAll(os.Stdin)
+	} else {
+		state, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	tree, err := xnyss.Load(state)
+	if err != nil {
+		return fmt.Errorf("failed to decode state: %v", err)
+	}
+	defer tree.Wipe()
+
+	s := summarize(tree)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+
+	return s.print(os.Stdout)
+}
+
+func summarize(tree *xnyss.NYTree) *summary {
+	s := &summary{
+		ID:            tree.ID(),
+		PublicKeyHash: hashHex(tree.PublicKey()),
+		Mode:          modeName(tree.Mode()),
+		Epoch:         tree.Epoch(),
+		Strict:        tree.Strict(),
+		RootLocked:    tree.RootLocked(),
+		Deterministic: tree.Deterministic(),
+		Addressed:     tree.Addressed(),
+		Spent:         tree.Spent(),
+		Branching:     tree.Branching(),
+		Counter:       tree.Counter(),
+		Consumed:      len(tree.Consumed()),
+		Available:     tree.Available(nil),
+		ByLabel:       tree.AvailableByLabel(nil),
+		Exhausted:     tree.Exhausted(),
+		Pending:       make(map[string]int),
+	}
+
+	for _, n := range tree.Nodes() {
+		txid := hex.EncodeToString(n.Txid)
+		if n.Confirms < xnyss.ConfirmsRequired {
+			s.Pending[txid]++
+		}
+
+		s.Nodes = append(s.Nodes, node{
+			PubKeyHash: hex.EncodeToString(n.PubKeyHash),
+			Txid:       txid,
+			Confirms:   n.Confirms,
+			Depth:      n.Depth,
+			Label:      n.Label,
+			Root:       n.Root,
+			Reserved:   n.Reserved,
+		})
+	}
+
+	return s
+}
+
+func (s *summary) print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(w, "tree\t%s\n", s.ID)
+	fmt.Fprintf(w, "public key hash\t%s\n", s.PublicKeyHash)
+	fmt.Fprintf(w, "mode\t%s\n", s.Mode)
+	fmt.Fprintf(w, "epoch\t%d\n", s.Epoch)
+	fmt.Fprintf(w, "strict\t%t\n", s.Strict)
+	fmt.Fprintf(w, "root locked\t%t\n", s.RootLocked)
+	fmt.Fprintf(w, "deterministic\t%t\n", s.Deterministic)
+	fmt.Fprintf(w, "addressed\t%t\n", s.Addressed)
+	fmt.Fprintf(w, "spent\t%t\n", s.Spent)
+	if len(s.Branching) > 0 {
+		fmt.Fprintf(w, "branching\t%v\n", s.Branching)
+	}
+	fmt.Fprintf(w, "counter\t%d\n", s.Counter)
+	fmt.Fprintf(w, "consumed nodes\t%d\n", s.Consumed)
+	fmt.Fprintf(w, "available\t%d (exhausted: %t)\n", s.Available, s.Exhausted)
+	for _, label := range sortedKeys(s.ByLabel) {
+		if label != "" {
+			fmt.Fprintf(w, "  label %q\t%d\n", label, s.ByLabel[label])
+		}
+	}
+
+	fmt.Fprintf(w, "\npending txids\t%d\n", len(s.Pending))
+	for _, txid := range sortedKeys(s.Pending) {
+		fmt.Fprintf(w, "  %s\t%d unconfirmed\n", txid, s.Pending[txid])
+	}
+
+	fmt.Fprintf(w, "\nPUBKEY HASH\tDEPTH\tCONFIRMS\tTXID\tLABEL\tFLAGS\n")
+	for _, n := range s.Nodes {
+		var flags string
+		if n.Root {
+			flags += "root "
+		}
+		if n.Reserved {
+			flags += "reserved"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\n", n.PubKeyHash, n.Depth, n.Confirms, n.Txid, n.Label, flags)
+	}
+
+	return w.Flush()
+}
+
+func modeName(m xnyss.Mode) string {
+	switch m {
+	case xnyss.ModeOneTime:
+		return "one-time"
+	case xnyss.ModeFrozen:
+		return "frozen"
+	}
+
+	return "long-term"
+}
+
+func hashHex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Re0h/xnyss"
+)
+
+// The environment variable holding the passphrase of migration archives.
+const passphraseEnv = "XNYSS_PASSPHRASE"
+
+// The extension of the state files written by import.
+const stateExt = ".state"
+
+func passphrase() ([]byte, error) {
+	p := os.Getenv(passphraseEnv)
+	if p == "" {
+		return nil, fmt.Errorf("the passphrase must be set in %s", passphraseEnv)
+	}
+
+	return []byte(p), nil
+}
+
+func export(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("o", "", "write the archive to `file` instead of standard output")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	pass, err := passphrase()
+	if err != nil {
+		return err
+	}
+
+	k := xnyss.NewKeyring()
+	for _, path := range flags.Args() {
+		state, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, err := xnyss.Load(state)
+		if err != nil {
+			return fmt.Errorf("failed to decode state %s: %v", path, err)
+		}
+		defer tree.Wipe()
+		if _, err := k.Add(tree); err != nil {
+			return fmt.Errorf("failed to add state %s: %v", path, err)
+		}
+	}
+
+	archive, entries, err := k.ExportMigration(pass)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(archive)
+	} else {
+		err = os.WriteFile(*out, archive, 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	printManifest(os.Stderr, "exported", entries)
+	return nil
+}
+
+func importArchive(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dir := flags.String("dir", ".", "write the states to `directory`")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	pass, err := passphrase()
+	if err != nil {
+		return err
+	}
+
+	var archive []byte
+	if path := flags.Arg(0); path == "-" {
+		archive, err = io.ReadAll(os.Stdin)
+	} else {
+		archive, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Load the states already in the directory, so that the import rejects
+	// trees with the same long-term keys
+	k := xnyss.NewKeyring()
+	existing, err := filepath.Glob(filepath.Join(*dir, "*"+stateExt))
+	if err != nil {
+		return err
+	}
+	for _, path := range existing {
+		state, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tree, err := xnyss.Load(state)
+		if err != nil {
+			return fmt.Errorf("failed to decode state %s: %v", path, err)
+		}
+		defer tree.Wipe()
+		if _, err := k.Add(tree); err != nil {
+			return fmt.Errorf("failed to add state %s: %v", path, err)
+		}
+	}
+
+	entries, err := k.ImportMigration(archive, pass)
+	if err == xnyss.ErrKeyringDuplicate {
+		return fmt.Errorf("archive holds a key already stored in %s", *dir)
+	} else if err != nil {
+		return fmt.Errorf("failed to import archive: %v", err)
+	}
+
+	for _, e := range entries {
+		tree, err := k.Tree(e.KeyHash)
+		if err != nil {
+			return err
+		}
+		defer tree.Wipe()
+
+		path := filepath.Join(*dir, hex.EncodeToString(e.KeyHash)+stateExt)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(tree.Bytes())
+		if err == nil {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	printManifest(os.Stderr, "imported", entries)
+	return nil
+}
+
+func printManifest(w io.Writer, verb string, entries []xnyss.MigrationEntry) {
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s %x (fingerprint %x)\n", verb, e.KeyHash, e.Fingerprint)
+	}
+}
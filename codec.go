@@ -0,0 +1,241 @@
+package xnyss
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrCodecUnknown  = newError(ErrEncoding, "unknown or unregistered codec")
+	ErrCodecInvalid  = newError(ErrState, "invalid codec")
+	ErrCodecConflict = newError(ErrState, "codec name is registered with another codec")
+)
+
+// A serialisation format for trees and signatures. New formats are added by
+// implementing Codec and registering it with RegisterCodec, after which they
+// can be used wherever a codec is accepted, e.g. by TreeState.
+//
+// The binary encodings of Bytes and Signature.MarshalBinary are the canonical
+// ones: other codecs may wrap them, but must decode to the same tree or
+// signature. Decoders must not alias their input.
+type Codec interface {
+	// The name the codec is registered under, e.g. CodecBinary
+	Name() string
+	EncodeTree(t *NYTree) ([]byte, error)
+	DecodeTree(b []byte) (*NYTree, error)
+	EncodeSignature(sig *Signature) ([]byte, error)
+	DecodeSignature(b []byte) (*Signature, error)
+}
+
+// Names of the codecs registered by this package.
+const (
+	// The canonical binary encodings, see Bytes and Signature.MarshalBinary.
+	CodecBinary = "binary"
+	// JSON, see Signature.MarshalJSON. Trees are encoded as an object holding
+	// their public key in hex and their binary state in base64.
+	CodecJSON = "json"
+	// CBOR, see Signature.MarshalCBOR. Trees are encoded as a map holding
+	// their binary state.
+	CodecCBOR = "cbor"
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		CodecBinary: binaryCodec{},
+		CodecJSON:   jsonCodec{},
+		CodecCBOR:   cborCodec{},
+	}
+)
+
+// Registers the codec c under its name. Returns ErrCodecConflict if another
+// codec is registered under that name, and ErrCodecInvalid if c is nil or has
+// no name. Registering the same codec twice is allowed.
+func RegisterCodec(c Codec) error {
+	if c == nil || c.Name() == "" {
+		return ErrCodecInvalid
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	if registered, ok := codecs[c.Name()]; ok {
+		if !sameCodec(registered, c) {
+			return ErrCodecConflict
+		}
+		return nil
+	}
+	codecs[c.Name()] = c
+
+	return nil
+}
+
+// Returns whether a and b are the same codec. Codecs of types that cannot be
+// compared are never the same, rather than panicking.
+func sameCodec(a, b Codec) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// Returns the codec registered under name, or ErrCodecUnknown.
+func LookupCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[name]
+	if !ok {
+		return nil, ErrCodecUnknown
+	}
+
+	return c, nil
+}
+
+// Returns the names of all registered codecs, sorted.
+func Codecs() []string {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	names := make([]string, 0, len(codecs))
+	for name := range codecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Name() string { return CodecBinary }
+
+func (binaryCodec) EncodeTree(t *NYTree) ([]byte, error) {
+	if t.Wiped() {
+		return nil, ErrTreeWiped
+	}
+
+	return t.MarshalBinary()
+}
+
+func (binaryCodec) DecodeTree(b []byte) (*NYTree, error) {
+	return Load(b)
+}
+
+func (binaryCodec) EncodeSignature(sig *Signature) ([]byte, error) {
+	return sig.MarshalBinary()
+}
+
+func (binaryCodec) DecodeSignature(b []byte) (*Signature, error) {
+	sig := &Signature{}
+	if err := sig.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// The JSON encoding of a tree. The public key is included for readability,
+// and must match the state.
+type treeJSON struct {
+	PublicKey string `json:"publicKey,omitempty"`
+	State     []byte `json:"state"`
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) EncodeTree(t *NYTree) ([]byte, error) {
+	state, err := binaryCodec{}.EncodeTree(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(treeJSON{
+		PublicKey: hex.EncodeToString(t.PublicKey()),
+		State:     state,
+	})
+}
+
+func (jsonCodec) DecodeTree(b []byte) (*NYTree, error) {
+	var v treeJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, ErrInvalidJSON
+	}
+
+	t, err := Load(v.State)
+	if err != nil {
+		return nil, err
+	}
+	if v.PublicKey != "" && v.PublicKey != hex.EncodeToString(t.PublicKey()) {
+		return nil, ErrInvalidJSON
+	}
+
+	return t, nil
+}
+
+func (jsonCodec) EncodeSignature(sig *Signature) ([]byte, error) {
+	return sig.MarshalJSON()
+}
+
+func (jsonCodec) DecodeSignature(b []byte) (*Signature, error) {
+	sig := &Signature{}
+	if err := sig.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// Keys of the CBOR encoding of a tree.
+const (
+	cborTreeState = 1
+)
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string { return CodecCBOR }
+
+func (cborCodec) EncodeTree(t *NYTree) ([]byte, error) {
+	state, err := binaryCodec{}.EncodeTree(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return cborEncode(cborMapValue{cborTreeState: state}), nil
+}
+
+func (cborCodec) DecodeTree(b []byte) (*NYTree, error) {
+	m, err := cborDecodeMap(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var state []byte
+	err = m.fields(func(key uint64, value interface{}) (ok bool) {
+		if key == cborTreeState {
+			state, ok = value.([]byte)
+		}
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(state)
+}
+
+func (cborCodec) EncodeSignature(sig *Signature) ([]byte, error) {
+	return sig.MarshalCBOR()
+}
+
+func (cborCodec) DecodeSignature(b []byte) (*Signature, error) {
+	sig := &Signature{}
+	if err := sig.UnmarshalCBOR(b); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,74 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+// A codec that is registered under the name of the binary codec.
+type renamedCodec struct{ binaryCodec }
+
+func TestCodecs(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("codec test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Every codec round trips trees and signatures
+	for _, name := range Codecs() {
+		c, err := LookupCodec(name)
+		if err != nil || c.Name() != name {
+			t.Fatal("Failed to look up codec", name, "-", err)
+		}
+
+		b, err := c.EncodeTree(tree)
+		if err != nil {
+			t.Fatal("Failed to encode tree with", name, "-", err)
+		}
+		decoded, err := c.DecodeTree(b)
+		if err != nil || !bytes.Equal(decoded.Bytes(), tree.Bytes()) {
+			t.Fatal("Failed to decode tree with", name, "-", err)
+		}
+
+		if b, err = c.EncodeSignature(sig); err != nil {
+			t.Fatal("Failed to encode signature with", name, "-", err)
+		}
+		decodedSig, err := c.DecodeSignature(b)
+		if err != nil || !bytes.Equal(decodedSig.Envelope(), sig.Envelope()) || !bytes.Equal(decodedSig.Message, sig.Message) {
+			t.Fatal("Failed to decode signature with", name, "-", err)
+		}
+	}
+
+	// 2 - The registry is strict
+	if _, err := LookupCodec("protobuf"); err != ErrCodecUnknown {
+		t.Fatal("Looked up unknown codec, err was", err)
+	}
+	if err := RegisterCodec(binaryCodec{}); err != nil {
+		t.Fatal("Failed to register codec twice -", err)
+	}
+	if err := RegisterCodec(renamedCodec{}); err != ErrCodecConflict {
+		t.Fatal("Registered conflicting codec, err was", err)
+	}
+	if err := RegisterCodec(nil); err != ErrCodecInvalid {
+		t.Fatal("Registered nil codec, err was", err)
+	}
+
+	// 3 - Tree states are stored using their codec
+	c, _ := LookupCodec(CodecJSON)
+	value, err := TreeState{Tree: tree, Codec: c}.Value()
+	if err != nil {
+		t.Fatal("Failed to store tree -", err)
+	}
+	state := &TreeState{Codec: c}
+	if err := state.Scan(value); err != nil || !bytes.Equal(state.Tree.Bytes(), tree.Bytes()) {
+		t.Fatal("Failed to scan tree -", err)
+	}
+	if err := (&TreeState{}).Scan(value); err == nil {
+		t.Fatal("Scanned tree using another codec")
+	}
+}
@@ -0,0 +1,56 @@
+package xnyss
+
+var (
+	ErrCommitmentEmpty = newError(ErrEncoding, "commitment must not be empty")
+)
+
+// Signs the message like Sign, committing the signature to the auxiliary data
+// commitment, e.g. the hash of the policy under which it was made. The digest
+// H(commitment) is mixed into the signed digest after the child hashes (and
+// the counter, see SetCounterBinding), so the signature only verifies for this
+// commitment. The commitment is recorded in the envelope of the signature, see
+// Signature.Commitment.
+func (t *NYTree) SignCommitted(msg, txid, commitment []byte) (sig *Signature, err error) {
+	if len(commitment) == 0 {
+		return nil, ErrCommitmentEmpty
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	opts := &signOptions{commitment: commitment}
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, opts)
+	})
+
+	return
+}
+
+// Returns the data the signature sig commits to, if it was created with
+// SignCommitted. Signatures decoded with NewSignature never have one, since
+// Bytes does not include it: use Envelope to transfer such signatures, or
+// SetCommitment to verify them against the expected data.
+func (sig *Signature) Commitment() (commitment []byte, ok bool) {
+	if sig.commitment == nil {
+		return nil, false
+	}
+
+	return append([]byte(nil), sig.commitment...), true
+}
+
+// Sets the data the signature sig is expected to commit to. Verifying sig then
+// fails unless it was created with SignCommitted for the same data. A nil
+// commitment denotes a signature without one.
+func (sig *Signature) SetCommitment(commitment []byte) error {
+	if commitment == nil {
+		sig.commitment = nil
+		return nil
+	}
+	if len(commitment) == 0 {
+		return ErrCommitmentEmpty
+	}
+
+	sig.commitment = append([]byte(nil), commitment...)
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SignCommitted(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	policy := []byte("policy: 2-of-3, expires 2026-12-31")
+
+	if _, err := tree.SignCommitted(testdata.Message, testdata.Txid, nil); err != ErrCommitmentEmpty {
+		t.Fatal("Signed with empty commitment, err was", err)
+	}
+
+	// 1 - Committed signatures verify, and record the commitment
+	sig, err := tree.SignCommitted(testdata.Message, testdata.Txid, policy)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if c, ok := sig.Commitment(); !ok || !bytes.Equal(c, policy) {
+		t.Fatal("Signature does not record its commitment")
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{sig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify committed signature -", err)
+	}
+
+	// 2 - The commitment is included in the envelope
+	parsed, err := ParseEnvelope(sig.Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if c, ok := parsed.Commitment(); !ok || !bytes.Equal(c, policy) {
+		t.Fatal("Commitment was not included in the envelope")
+	}
+	if len(sig.Envelope()) != EnvelopeLen(Branches, true)+EnvelopePositionLen+5+len(policy) {
+		t.Fatal("Invalid envelope length")
+	}
+
+	// 3 - The signature only verifies for its commitment
+	decoded, err := NewSignature(sig.Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{decoded}, testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified signature without its commitment, err was", err)
+	}
+	decoded.SetCommitment([]byte("another policy"))
+	if err := VerifyChain(tree.PublicKey(), []*Signature{decoded}, testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified signature with another commitment, err was", err)
+	}
+	decoded.SetCommitment(policy)
+	if err := VerifyChain(tree.PublicKey(), []*Signature{decoded}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature with its commitment -", err)
+	}
+}
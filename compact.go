@@ -0,0 +1,223 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+)
+
+// Whether the seeds of a node are known to be derived from the root seeds at
+// its position.
+type derivation uint8
+
+const (
+	derivationUnknown derivation = iota
+	derivationDerived
+	derivationNotDerived
+)
+
+// Kinds of node frames in compact states. Compact frames hold
+// depth || index || txid || confirms instead of the node record, followed by
+// the node's fields without its depth and index.
+const (
+	frameFull    = 0x00
+	frameCompact = 0x01
+
+	compactRecordLen = 4 + 8 + 32 + 1
+)
+
+// Enables or disables the compact serialisation of the deterministic tree t.
+// In a deterministic tree the seeds of every node follow from the root seeds
+// and the position of the node (see Position), so compact states store the
+// depth and index of nodes instead of their 64 bytes of seeds. The seeds are
+// derived again when the state is loaded.
+//
+// Nodes whose seeds can not be derived are still stored in full: nodes loaded
+// from states that did not record their index, nodes created before the
+// branching schedule was changed, and nodes whose index wrapped around (the
+// product of the branching factors above them exceeds 2^64). Returns
+// ErrTreeNotDeterministic if the children of t are random.
+func (t *NYTree) SetCompact(compact bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if compact && !t.deterministic {
+		return ErrTreeNotDeterministic
+	}
+
+	if t.compact != compact {
+		t.compact = compact
+		t.epoch++
+	}
+
+	return nil
+}
+
+// Returns whether the tree t is serialised in compact form, see SetCompact.
+func (t *NYTree) Compact() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.compact
+}
+
+// Encodes the frame of node n in the serialised tree t.
+func (t *NYTree) nodeFrame(n *nyNode) []byte {
+	if !t.compact {
+		return append(n.bytes(), n.fields()...)
+	}
+	if !t.derived(n) {
+		return append(append([]byte{frameFull}, n.bytes()...), n.fields()...)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(frameCompact)
+	binary.Write(buf, binary.BigEndian, n.depth)
+	binary.Write(buf, binary.BigEndian, n.index)
+	buf.Write(n.txid)
+	buf.WriteByte(n.confirms)
+	buf.Write(n.encodeFields(false))
+
+	return buf.Bytes()
+}
+
+// Decodes a node frame of the serialised tree t. The seeds of compact nodes
+// are derived by expandNodes, once the header of the tree is loaded.
+func (t *NYTree) loadFrame(frame []byte) (*nyNode, error) {
+	if !t.compact {
+		return loadFramedNode(frame, t.owned != nil)
+	}
+	if len(frame) < 1 {
+		return nil, ErrNodeInvalidInput
+	}
+
+	switch frame[0] {
+	case frameFull:
+		return loadFramedNode(frame[1:], t.owned != nil)
+	case frameCompact:
+		if len(frame) < 1+compactRecordLen {
+			return nil, ErrNodeInvalidInput
+		}
+		record := frame[1:]
+		node := &nyNode{
+			depth:      binary.BigEndian.Uint32(record),
+			index:      binary.BigEndian.Uint64(record[4:]),
+			txid:       append([]byte(nil), record[12:44]...),
+			confirms:   record[44],
+			indexed:    true,
+			derivation: derivationDerived,
+		}
+		if err := node.loadFields(record[compactRecordLen:]); err != nil {
+			return nil, err
+		}
+
+		return node, nil
+	}
+
+	return nil, ErrNodeInvalidInput
+}
+
+// Derives the seeds of the nodes of a loaded compact tree that were stored by
+// position. Returns ErrNodeInvalidInput if the position of a node can not be
+// derived.
+func (t *NYTree) expandNodes() error {
+	if !t.compact {
+		return nil
+	}
+
+	cache := make(map[Position]*nyNode)
+	for _, node := range t.nodes {
+		if node.privSeed != nil {
+			continue
+		}
+
+		derived := t.deriveNode(node.depth, node.index, cache)
+		if derived == nil {
+			return ErrNodeInvalidInput
+		}
+		node.privSeed, node.pubSeed = derived.privSeed, derived.pubSeed
+		node.addressed = t.addressed
+	}
+
+	return nil
+}
+
+// Returns whether the seeds of node n are derived from the root seeds at its
+// position, caching the result.
+func (t *NYTree) derived(n *nyNode) bool {
+	if n.derivation == derivationUnknown {
+		n.derivation = derivationNotDerived
+		if n.indexed || n.addressed || t.isRoot(n) {
+			derived := t.deriveNode(n.depth, n.index, nil)
+			if derived != nil && t.sameSeeds(n, derived) {
+				n.derivation = derivationDerived
+			}
+		}
+	}
+
+	return n.derivation == derivationDerived
+}
+
+// Returns whether the nodes a and b have the same seeds, where b is not masked.
+func (t *NYTree) sameSeeds(a, b *nyNode) bool {
+	seed, done := a.seed()
+	defer done()
+
+	return bytes.Equal(seed, b.privSeed) && bytes.Equal(a.pubSeed, b.pubSeed)
+}
+
+// Derives the node at the given depth and index from the root seeds, or returns
+// nil if the index does not identify a unique node at that depth. Derived
+// ancestors are kept in cache if it is not nil.
+func (t *NYTree) deriveNode(depth uint32, index uint64, cache map[Position]*nyNode) *nyNode {
+	// Child i of the node at index p has index p*branches+i, which must not
+	// have wrapped around
+	var hi, count uint64 = 0, 1
+	for d := uint32(0); d < depth; d++ {
+		if hi, count = bits.Mul64(count, uint64(t.branchesAt(d))); hi != 0 {
+			return nil
+		}
+	}
+	if index >= count {
+		return nil
+	}
+
+	seed, done := unmask(t.rootSeed, t.rootMask)
+	defer done()
+	node := &nyNode{
+		privSeed: append([]byte(nil), seed...),
+		pubSeed:  append([]byte(nil), t.rootPubSeed...),
+	}
+
+	path := make([]int, depth)
+	for d := depth; d > 0; d-- {
+		branches := uint64(t.branchesAt(d - 1))
+		path[d-1] = int(index % branches)
+		index /= branches
+	}
+
+	for d, i := range path {
+		pos := Position{Depth: uint32(d + 1), Index: node.index*uint64(t.branchesAt(uint32(d))) + uint64(i)}
+		if child, ok := cache[pos]; ok {
+			node = child
+			continue
+		}
+
+		// A derivation stream never fails to produce entropy
+		children, _ := node.childNodes(nil, t.branchesAt(uint32(d)), newDerivationStream(node.privSeed, nil))
+		node = children[i]
+		if cache != nil {
+			cache[pos] = node
+		}
+	}
+
+	return node
+}
+
+// Forgets which nodes are derived from the root seeds, after the branching
+// schedule changed.
+func (t *NYTree) resetDerivation() {
+	for _, node := range t.nodes {
+		node.derivation = derivationUnknown
+	}
+}
@@ -0,0 +1,68 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_SetCompact(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(seed, pubSeed, false).SetCompact(true); err != ErrTreeNotDeterministic {
+		t.Fatal("Random tree was made compact, err was", err)
+	}
+
+	tree := NewDeterministic(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	for i := 0; i < 4; i++ {
+		sig, err := tree.Sign(msg, Txid("compact", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+	full := tree.Bytes()
+
+	// 1 - Compact states are smaller, and load into the same tree
+	if err := tree.SetCompact(true); err != nil {
+		t.Fatal("Failed to make tree compact -", err)
+	}
+	compact := tree.Bytes()
+	if len(compact) >= len(full) || tree.EncodedLen() != len(compact) {
+		t.Fatal("Invalid length of compact state")
+	}
+	loaded, err := Load(compact)
+	if err != nil {
+		t.Fatal("Failed to load compact state -", err)
+	}
+	if !loaded.Compact() || !bytes.Equal(loaded.Bytes(), compact) {
+		t.Fatal("Loaded compact tree is serialised differently")
+	}
+	for i, node := range loaded.canonicalNodes() {
+		original := tree.canonicalNodes()[i]
+		if !bytes.Equal(node.privSeed, original.privSeed) || !bytes.Equal(node.pubSeed, original.pubSeed) {
+			t.Fatal("Seeds of node", i, "were not derived correctly")
+		}
+	}
+
+	// 2 - Nodes that can not be derived are stored in full
+	loaded.nodes[0].index++
+	loaded.resetDerivation()
+	reloaded, err := Load(loaded.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load compact state -", err)
+	}
+	if !bytes.Equal(reloaded.nodes[0].privSeed, loaded.nodes[0].privSeed) {
+		t.Fatal("Node that can not be derived was not stored in full")
+	}
+
+	// 3 - Converting back restores the full format
+	if err := loaded.SetCompact(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(loaded.Bytes()); err != nil || loaded.Bytes()[5]&flagCompact != 0 {
+		t.Fatal("Failed to convert tree back to the full format -", err)
+	}
+}
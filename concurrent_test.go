@@ -0,0 +1,76 @@
+package xnyss
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+// Run with -race to detect unsynchronised access.
+func TestNYTree_Concurrent(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Sign, confirm, back up and serialise from several goroutines
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		sigs []*Signature
+	)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				if sig, err := tree.Sign(testdata.Message, Txid("concurrent", []byte{byte(i), byte(j)})); err == nil {
+					mu.Lock()
+					sigs = append(sigs, sig)
+					mu.Unlock()
+				}
+				for _, pkh := range tree.Unconfirmed() {
+					tree.Confirm(pkh, ConfirmsRequired)
+				}
+				tree.Available(nil)
+				tree.Bytes()
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 4; j++ {
+			if backup, err := tree.Backup(1); err == nil {
+				tree.MarkConsumed(backup.Consumed())
+			}
+		}
+	}()
+	wg.Wait()
+
+	// 2 - No node signed twice
+	seen := make(map[string]bool)
+	for _, sig := range sigs {
+		pk, err := sig.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[string(pk)] {
+			t.Fatal("A node was used to sign twice")
+		}
+		seen[string(pk)] = true
+	}
+	if len(sigs) == 0 {
+		t.Fatal("No signatures were created")
+	}
+
+	// 3 - The state has stayed consistent
+	if err := tree.ValidateState(); err != nil {
+		t.Fatal("Invalid state -", err)
+	}
+	if _, err := Load(tree.Bytes()); err != nil {
+		t.Fatal("Failed to load state -", err)
+	}
+}
@@ -0,0 +1,164 @@
+// Shares the confirmed frontiers of XNYSS long-term keys between verifiers.
+//
+// A verifier that accepts a signature must know that the node that created it
+// was confirmed: its public key hash appeared as a child hash in a confirmed
+// signature of the same key. Instead of every verification node tracking these
+// frontiers from scratch, they can share them through Redis, using a Cache.
+//
+// The package does not depend on a Redis client: Redis is a small interface
+// that is easily implemented on top of any client library. Memory implements
+// it for tests and single process deployments.
+package confirmcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrNotConfirmed = errors.New("signature was not created by a confirmed node")
+)
+
+// The Redis set commands used by a Cache.
+type Redis interface {
+	// SADD key member [member ...]
+	SAdd(ctx context.Context, key string, members ...[]byte) error
+	// SREM key member [member ...]
+	SRem(ctx context.Context, key string, members ...[]byte) error
+	// SISMEMBER key member
+	SIsMember(ctx context.Context, key string, member []byte) (bool, error)
+	// SMEMBERS key
+	SMembers(ctx context.Context, key string) ([][]byte, error)
+}
+
+// A confirmation cache, storing the frontier of every long-term key in a set
+// named Prefix followed by the hex encoded hash of the key.
+type Cache struct {
+	Redis  Redis
+	Prefix string
+}
+
+// Creates a cache using the key prefix "xnyss:frontier:".
+func New(r Redis) *Cache {
+	return &Cache{Redis: r, Prefix: "xnyss:frontier:"}
+}
+
+func (c *Cache) key(rootPubKey []byte) string {
+	h := sha256.Sum256(rootPubKey)
+	return c.Prefix + hex.EncodeToString(h[:])
+}
+
+// Adds the public key hashes of confirmed nodes to the frontier of a key.
+func (c *Cache) Confirm(ctx context.Context, rootPubKey []byte, pkhashes ...[]byte) error {
+	if len(pkhashes) == 0 {
+		return nil
+	}
+
+	return c.Redis.SAdd(ctx, c.key(rootPubKey), pkhashes...)
+}
+
+// Removes the public key hashes of used nodes from the frontier of a key.
+func (c *Cache) Consume(ctx context.Context, rootPubKey []byte, pkhashes ...[]byte) error {
+	if len(pkhashes) == 0 {
+		return nil
+	}
+
+	return c.Redis.SRem(ctx, c.key(rootPubKey), pkhashes...)
+}
+
+// Returns whether the node with the given public key hash is confirmed.
+func (c *Cache) Confirmed(ctx context.Context, rootPubKey, pkh []byte) (bool, error) {
+	return c.Redis.SIsMember(ctx, c.key(rootPubKey), pkh)
+}
+
+// Returns the confirmed public key hashes of a key.
+func (c *Cache) Frontier(ctx context.Context, rootPubKey []byte) ([][]byte, error) {
+	return c.Redis.SMembers(ctx, c.key(rootPubKey))
+}
+
+// Verifies that sig was created by the root of the key or by a confirmed node.
+// It does not update the frontier: once the transaction holding sig is
+// confirmed, its child hashes should be added using Confirm.
+func (c *Cache) Verify(ctx context.Context, rootPubKey []byte, sig *xnyss.Signature) error {
+	pk, err := sig.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(pk, rootPubKey) {
+		return nil
+	}
+
+	pkh := sha256.Sum256(pk)
+	confirmed, err := c.Confirmed(ctx, rootPubKey, pkh[:])
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return ErrNotConfirmed
+	}
+
+	return nil
+}
+
+// An in-memory implementation of Redis. It is safe for concurrent use.
+type Memory struct {
+	mu   sync.Mutex
+	sets map[string]map[string]bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{sets: make(map[string]map[string]bool)}
+}
+
+func (m *Memory) SAdd(ctx context.Context, key string, members ...[]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	set, ok := m.sets[key]
+	if !ok {
+		set = make(map[string]bool)
+		m.sets[key] = set
+	}
+	for _, member := range members {
+		set[string(member)] = true
+	}
+
+	return nil
+}
+
+func (m *Memory) SRem(ctx context.Context, key string, members ...[]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, member := range members {
+		delete(m.sets[key], string(member))
+	}
+
+	return nil
+}
+
+func (m *Memory) SIsMember(ctx context.Context, key string, member []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.sets[key][string(member)], nil
+}
+
+func (m *Memory) SMembers(ctx context.Context, key string) ([][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := make([][]byte, 0, len(m.sets[key]))
+	for member := range m.sets[key] {
+		members = append(members, []byte(member))
+	}
+
+	return members, nil
+}
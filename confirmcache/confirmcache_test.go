@@ -0,0 +1,61 @@
+package confirmcache
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree := xnyss.New(seeds[:32], seeds[32:], false)
+	pk := tree.PublicKey()
+
+	// Two verifiers sharing one store
+	store := NewMemory()
+	signer, verifier := New(store), New(store)
+
+	// 1 - The root signature verifies without confirmations
+	sig, err := tree.Sign(make([]byte, 32), xnyss.Txid("test", []byte{1}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := verifier.Verify(ctx, pk, sig); err != nil {
+		t.Fatal("Failed to verify root signature -", err)
+	}
+
+	// 2 - Children only verify once confirmed by any user of the cache
+	tree.Confirm(sig.ChildHashes[0], xnyss.ConfirmsRequired)
+	child, err := tree.Sign(make([]byte, 32), xnyss.Txid("test", []byte{2}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := verifier.Verify(ctx, pk, child); err != ErrNotConfirmed {
+		t.Fatal("Verified unconfirmed node, err was", err)
+	}
+
+	if err := signer.Confirm(ctx, pk, sig.ChildHashes...); err != nil {
+		t.Fatal("Failed to confirm -", err)
+	}
+	if err := verifier.Verify(ctx, pk, child); err != nil {
+		t.Fatal("Failed to verify confirmed node -", err)
+	}
+
+	// 3 - Consumed nodes leave the frontier
+	childPK, _ := child.PublicKey()
+	childPKH := sha256.Sum256(childPK)
+	if err := verifier.Consume(ctx, pk, childPKH[:]); err != nil {
+		t.Fatal(err)
+	}
+	frontier, err := signer.Frontier(ctx, pk)
+	if err != nil || len(frontier) != len(sig.ChildHashes)-1 {
+		t.Fatal("Invalid frontier size", len(frontier), err)
+	}
+}
@@ -0,0 +1,86 @@
+package xnyss
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrFrontierInvalid  = newError(ErrEncoding, "frontier holds a public key hash of invalid length")
+	ErrFrontierTooLarge = newError(ErrCapacity, "frontier holds more than MaxFrontierLen public key hashes")
+	ErrFrontierUnknown  = newError(ErrCrypto, "signature was not created by the long-term key or a frontier node")
+)
+
+// The maximum number of public key hashes in a frontier accepted by
+// VerifyWithFrontier. Checking whether a signature was created by a frontier
+// node costs one comparison per entry, so consensus code bounds the frontier
+// of every key it tracks.
+const MaxFrontierLen = 1 << 16
+
+// Arenas used by VerifyWithFrontier, so verifications reuse their buffers.
+var frontierArenas = sync.Pool{New: func() any { return wotsp.NewArena() }}
+
+// Verifies that sig is a signature of msg, created either by the root node of
+// the tree with long-term public key hash longTermPKH, i.e. the hash of its
+// PublicKey, or by one of the nodes in frontier. Consensus code tracks the
+// frontier of every key as the child hashes of accepted signatures whose nodes
+// did not sign yet, so that unlike VerifyChain, a single signature is verified
+// per transaction.
+//
+// The function is stateless and the cost of a call is bounded by a single
+// WOTS+ public key computation plus one comparison per frontier entry. The
+// inputs are checked in a fixed order before any hashing, so the returned
+// error only depends on the inputs: ErrInvalidSigEncoding if sig is nil,
+// ErrInvalidMsgLen unless msg holds MsgLen bytes, ErrFrontierInvalid if
+// longTermPKH or an entry of frontier is not a 32 byte hash,
+// ErrFrontierTooLarge if frontier holds more than MaxFrontierLen entries, the
+// error of Signature.PublicKey if the signature is malformed, and
+// ErrFrontierUnknown if the signature was created by another key.
+func VerifyWithFrontier(longTermPKH []byte, frontier [][]byte, sig *Signature, msg []byte) error {
+	if sig == nil {
+		return ErrInvalidSigEncoding
+	}
+	if len(msg) != MsgLen {
+		return ErrInvalidMsgLen
+	}
+	if len(longTermPKH) != 32 {
+		return ErrFrontierInvalid
+	}
+	if len(frontier) > MaxFrontierLen {
+		return ErrFrontierTooLarge
+	}
+	for _, pkh := range frontier {
+		if len(pkh) != 32 {
+			return ErrFrontierInvalid
+		}
+	}
+
+	// The signature must sign msg, not just any message
+	verified := *sig
+	verified.Message = msg
+
+	a := frontierArenas.Get().(*wotsp.Arena)
+	defer func() {
+		a.Release()
+		frontierArenas.Put(a)
+	}()
+
+	pk, err := verified.publicKeyIn(a)
+	if err != nil {
+		return err
+	}
+	pkh := sig.suite.sum(pk)
+
+	if bytes.Equal(pkh, longTermPKH) {
+		return nil
+	}
+	for _, node := range frontier {
+		if bytes.Equal(pkh, node) {
+			return nil
+		}
+	}
+
+	return ErrFrontierUnknown
+}
@@ -0,0 +1,119 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestVerifyWithFrontier(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	longTerm := sha256.Sum256(tree.PublicKey())
+
+	// 1 - The first signature is created by the long-term key
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], nil, sig, testdata.Message); err != nil {
+		t.Fatal("Failed to verify root signature -", err)
+	}
+	frontier := sig.ChildHashes
+	for _, pkh := range frontier {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 2 - Later signatures are created by frontier nodes
+	sig, err = tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], frontier, sig, testdata.Message); err != nil {
+		t.Fatal("Failed to verify frontier signature -", err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], nil, sig, testdata.Message); err != ErrFrontierUnknown {
+		t.Fatal("Verified signature of unknown node, err was", err)
+	}
+
+	// 3 - Signatures of other messages are rejected
+	msg := sha256.Sum256(testdata.Message)
+	if err := VerifyWithFrontier(longTerm[:], frontier, sig, msg[:]); err != ErrFrontierUnknown {
+		t.Fatal("Verified signature of another message, err was", err)
+	}
+
+	// 4 - Invalid inputs are rejected in a fixed order
+	if err := VerifyWithFrontier(longTerm[:1], [][]byte{nil}, sig, msg[:1]); err != ErrInvalidMsgLen {
+		t.Fatal("Invalid message not rejected first, err was", err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], [][]byte{nil}, sig, msg[:]); err != ErrFrontierInvalid {
+		t.Fatal("Invalid frontier not rejected, err was", err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], make([][]byte, MaxFrontierLen+1), sig, msg[:]); err != ErrFrontierTooLarge {
+		t.Fatal("Oversized frontier not rejected, err was", err)
+	}
+	if err := VerifyWithFrontier(longTerm[:], frontier, nil, msg[:]); err != ErrInvalidSigEncoding {
+		t.Fatal("Missing signature not rejected, err was", err)
+	}
+}
+
+// Verifies a signature of a node at the end of a frontier of MaxFrontierLen
+// entries, which is the worst case.
+func BenchmarkVerifyWithFrontier(b *testing.B) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		b.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	root, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, pkh := range root.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	frontier := make([][]byte, MaxFrontierLen-len(root.ChildHashes), MaxFrontierLen)
+	for i := range frontier {
+		frontier[i] = make([]byte, 32)
+	}
+	frontier = append(frontier, root.ChildHashes...)
+	longTerm := sha256.Sum256(tree.PublicKey())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := VerifyWithFrontier(longTerm[:], frontier, sig, testdata.Message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyWithFrontierRoot(b *testing.B) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		b.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		b.Fatal(err)
+	}
+	longTerm := sha256.Sum256(tree.PublicKey())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := VerifyWithFrontier(longTerm[:], nil, sig, testdata.Message); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
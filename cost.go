@@ -0,0 +1,107 @@
+package xnyss
+
+import (
+	"math/bits"
+	"time"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+// The cost of verifying signatures, see EstimateVerifyCost.
+type VerifyCost struct {
+	// The amount of signatures verified
+	Signatures int
+	// The amount of hash function calls in the worst case, i.e. for the
+	// messages whose WOTS+ chains are longest to complete
+	MaxHashes int
+	// The approximate amount of hash function calls for random messages
+	ExpectedHashes int
+}
+
+// Estimates the cost of verifying signatures created with the parameter set
+// p, so protocols can base fees or resource limits on it. Verifying a chain of
+// signatures, e.g. with VerifyChain or Bundle.Verify, costs as much as
+// verifying each of its signatures, so signatures is the length of the chain,
+// or 1 for a single signature.
+//
+// The cost is counted in calls of the hash function of p: completing a WOTS+
+// chain takes three calls per step, and every signature takes two more to
+// compute its signed digest and public key hash. All calls hash at most a few
+// blocks, except the digest of signatures with many child hashes. Returns
+// ErrParamSetInvalid if p has an unsupported Winternitz parameter, or
+// signatures is negative.
+func EstimateVerifyCost(p ParamSet, signatures int) (VerifyCost, error) {
+	if validWinternitz(p.Winternitz) != nil || signatures < 0 {
+		return VerifyCost{}, ErrParamSetInvalid
+	}
+
+	maxSteps, expectedSteps := verifySteps(p.Winternitz)
+
+	return VerifyCost{
+		Signatures:     signatures,
+		MaxHashes:      signatures * (3*maxSteps + 2),
+		ExpectedHashes: signatures * (3*expectedSteps + 2),
+	}, nil
+}
+
+// Returns the time verification takes when a hash function call takes
+// perHash, e.g. as measured by MeasureHashTime, for random messages and in the
+// worst case.
+func (c VerifyCost) Time(perHash time.Duration) (expected, max time.Duration) {
+	return time.Duration(c.ExpectedHashes) * perHash, time.Duration(c.MaxHashes) * perHash
+}
+
+// Measures the time a call of the hash function of suite s takes on this
+// machine, for inputs of the size hashed by the WOTS+ chains. Since the chains
+// precompute part of their inputs, this slightly overestimates their cost.
+// Returns ErrHashSuiteUnknown if s is not registered.
+func MeasureHashTime(s HashSuite) (time.Duration, error) {
+	newHash, err := s.lookup()
+	if err != nil {
+		return 0, err
+	}
+
+	const calls = 1000
+	in := make([]byte, 3*32)
+	h := newHash()
+
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		h.Reset()
+		h.Write(in)
+		copy(in[64:], h.Sum(in[64:64]))
+	}
+
+	return time.Since(start) / calls, nil
+}
+
+// Returns the amount of chain steps computed by WOTS+ verification with
+// Winternitz parameter w, in the worst case and approximately for random
+// messages.
+//
+// A message whose digits sum to the maximum minus csum takes csum steps to
+// complete its message chains, plus the steps of the checksum chains, whose
+// digits encode csum. The worst case is found by trying every csum; for random
+// messages, csum is taken to be its mean.
+func verifySteps(w int) (max, expected int) {
+	logw := bits.Len(uint(w)) - 1
+	l1 := 8 * 32 / logw
+	l2 := wotsp.Params{W: w}.SigLen()/32 - l1
+
+	checksumSteps := func(csum int) (steps int) {
+		for i := 0; i < l2; i++ {
+			steps += w - 1 - csum%w
+			csum /= w
+		}
+		return
+	}
+
+	for csum := 0; csum <= l1*(w-1); csum++ {
+		if steps := csum + checksumSteps(csum); steps > max {
+			max = steps
+		}
+	}
+	mean := l1 * (w - 1) / 2
+
+	return max, mean + checksumSteps(mean)
+}
@@ -0,0 +1,56 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateVerifyCost(t *testing.T) {
+	w16, err := LookupParamSet(ParamsSHA256W16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w256, err := LookupParamSet(ParamsDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - The worst case of w=16 is an all-zero checksum digit, with 990 of
+	// the 67*15 steps
+	single, err := EstimateVerifyCost(w16, 1)
+	if err != nil {
+		t.Fatal("Failed to estimate cost -", err)
+	}
+	if single.MaxHashes != 3*990+2 || single.ExpectedHashes <= 2 || single.ExpectedHashes > single.MaxHashes {
+		t.Fatal("Invalid cost for w = 16", single)
+	}
+
+	// 2 - Chains cost as much as their signatures
+	chain, err := EstimateVerifyCost(w16, 3)
+	if err != nil || chain.MaxHashes != 3*single.MaxHashes || chain.ExpectedHashes != 3*single.ExpectedHashes {
+		t.Fatal("Invalid cost for chain", chain, err)
+	}
+
+	// 3 - Larger Winternitz parameters cost more, within l*(w-1) steps
+	large, err := EstimateVerifyCost(w256, 1)
+	if err != nil || large.ExpectedHashes <= single.ExpectedHashes || large.MaxHashes > 3*34*255+2 {
+		t.Fatal("Invalid cost for w = 256", large, err)
+	}
+
+	// 4 - Costs convert to time
+	expected, max := single.Time(time.Microsecond)
+	if expected != time.Duration(single.ExpectedHashes)*time.Microsecond || max != time.Duration(single.MaxHashes)*time.Microsecond {
+		t.Fatal("Invalid time", expected, max)
+	}
+	if perHash, err := MeasureHashTime(SuiteSHA256); err != nil || perHash <= 0 {
+		t.Fatal("Failed to measure hash time -", err)
+	}
+
+	// 5 - Invalid parameters are rejected
+	if _, err := EstimateVerifyCost(ParamSet{Winternitz: 8}, 1); err != ErrParamSetInvalid {
+		t.Fatal("Estimated cost for invalid parameters, err was", err)
+	}
+	if _, err := MeasureHashTime(HashSuite(0xff)); err != ErrHashSuiteUnknown {
+		t.Fatal("Measured unknown hash suite, err was", err)
+	}
+}
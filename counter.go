@@ -0,0 +1,51 @@
+package xnyss
+
+import (
+	"encoding/binary"
+)
+
+// Enables or disables counter binding for the tree t. With counter binding,
+// the tree maintains a counter that is incremented by every signature and is
+// mixed into the signed digest: H(msg || child hashes || counter), with the
+// counter as an 8 byte big endian integer. The value is exposed by
+// Signature.Counter, which lets verifiers order signatures and reject replays
+// in uses without a blockchain, e.g. API request signing or firmware updates.
+//
+// The counter is persisted with the tree. A backup continues from the counter
+// of the tree it was created from, so the signatures of a backup and its
+// origin should not be ordered against each other.
+func (t *NYTree) SetCounterBinding(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counterBound != enabled {
+		t.counterBound = enabled
+		t.epoch++
+	}
+}
+
+// Returns the counter of the last signature created with counter binding.
+func (t *NYTree) Counter() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.counter
+}
+
+// Returns the counter bound into the signature sig, if it has one. Signatures
+// decoded with NewSignature never have one, since Bytes does not include it:
+// use Envelope to transfer such signatures.
+func (sig *Signature) Counter() (counter uint64, ok bool) {
+	if sig.counter == nil {
+		return 0, false
+	}
+
+	return *sig.counter, true
+}
+
+func encodeCounter(counter uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], counter)
+
+	return b[:]
+}
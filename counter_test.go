@@ -0,0 +1,59 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetCounterBinding(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	tree.SetCounterBinding(true)
+
+	// 1 - Every signature binds the next counter value
+	var chain []*Signature
+	for i := uint64(1); i <= 3; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("counter", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if c, ok := sig.Counter(); !ok || c != i {
+			t.Fatal("Invalid counter", c, "expected", i)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+		chain = append(chain, sig)
+		if err := VerifyChain(pk, chain, testdata.Message); err != nil {
+			t.Fatal("Failed to verify signature with counter -", err)
+		}
+	}
+
+	// 2 - The counter is persisted, and travels in the envelope
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.Counter() != 3 {
+		t.Fatal("Counter was not persisted")
+	}
+
+	parsed, err := ParseEnvelope(chain[2].Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if c, ok := parsed.Counter(); !ok || c != 3 || len(chain[2].Envelope()) != EnvelopeLen(Branches, true)+EnvelopePositionLen+EnvelopeCounterLen {
+		t.Fatal("Counter was not included in the envelope")
+	}
+
+	// 3 - The counter is bound: changing it breaks the signature
+	modified := uint64(2)
+	parsed.counter = &modified
+	if err := VerifyChain(pk, []*Signature{chain[0], chain[1], parsed}, testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified signature with modified counter, err was", err)
+	}
+}
@@ -0,0 +1,56 @@
+package xnyss
+
+import "encoding/binary"
+
+// Sets the maximum depth of the nodes of the tree t, or removes the limit if
+// depth is zero. Every signature on the path from the root to a node is needed
+// to verify the signatures of that node against the long-term key, so the
+// depth of a node bounds the size of its proofs.
+//
+// Nodes at the maximum depth still sign, but do not create child nodes, so
+// their branch of the tree ends with them. While a maximum depth is set, Sign
+// prefers the shallowest confirmed node over deeper ones. The maximum depth is
+// included in the serialised tree.
+func (t *NYTree) SetMaxDepth(depth uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.maxDepth != depth {
+		t.maxDepth = depth
+		t.epoch++
+	}
+}
+
+// Returns the maximum depth of the nodes of the tree t, or zero if there is no
+// limit.
+func (t *NYTree) MaxDepth() uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.maxDepth
+}
+
+// Returns whether node n may not create child nodes, because it is at the
+// maximum depth of t.
+func (t *NYTree) atMaxDepth(n *nyNode) bool {
+	return t.maxDepth > 0 && n.depth >= t.maxDepth
+}
+
+// Returns the index of the shallowest node for which usable returns true, or
+// -1 if there is none.
+func (t *NYTree) shallowest(usable func(*nyNode) bool) int {
+	index := -1
+	for i, node := range t.nodes {
+		if usable(node) && (index < 0 || node.depth < t.nodes[index].depth) {
+			index = i
+		}
+	}
+
+	return index
+}
+
+func encodeMaxDepth(depth uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], depth)
+	return b[:]
+}
@@ -0,0 +1,62 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetMaxDepth(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetMaxDepth(1)
+
+	// 1 - The root creates children, nodes at the maximum depth do not
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign with root -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	leafSig, err := tree.Sign(testdata.Message, Txid("depth", []byte{1}))
+	if err != nil {
+		t.Fatal("Failed to sign at maximum depth -", err)
+	}
+	if len(tree.nodes) != Branches-1 {
+		t.Fatal("Node at maximum depth created children, tree has", len(tree.nodes), "nodes")
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{sig, leafSig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature of node at maximum depth -", err)
+	}
+
+	// 2 - Shallower confirmed nodes are preferred
+	tree.SetMaxDepth(3)
+	deep, err := tree.Sign(testdata.Message, Txid("depth", []byte{2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range deep.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if _, err := tree.Sign(testdata.Message, Txid("depth", []byte{3})); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range tree.nodes {
+		if node.depth == 1 {
+			t.Fatal("Signed with a deeper node while a node at depth 1 was available")
+		}
+	}
+
+	// 3 - The maximum depth is persisted
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.MaxDepth() != 3 {
+		t.Fatal("Maximum depth was not persisted")
+	}
+}
@@ -0,0 +1,75 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Domain separation prefix of the derivation stream.
+var derivationLabel = []byte("xnyss child derivation")
+
+// Creates a tree like New, in which the seeds of child nodes are derived
+// deterministically from the seeds of their parent instead of using fresh
+// randomness. Since every node signs only once, every node has exactly one
+// set of children, so the whole tree is determined by the root seeds. This
+// allows reconstructing the tree from its seeds and public data, see
+// NewFromFrontier.
+func NewDeterministic(seed, pubSeed []byte, ots bool) *NYTree {
+	tree := New(seed, pubSeed, ots)
+	tree.deterministic = true
+
+	return tree
+}
+
+// Returns whether child nodes of t are derived deterministically.
+func (t *NYTree) Deterministic() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.deterministic
+}
+
+// An io.Reader producing the stream H(label||key||0) || H(label||key||1) || ...
+// If mask is not nil, the key is stored as key XOR mask, and is only unmasked
+// while it is hashed.
+type derivationStream struct {
+	key  []byte
+	mask []byte
+	ctr  uint32
+	buf  []byte
+}
+
+func newDerivationStream(key, mask []byte) *derivationStream {
+	return &derivationStream{key: key, mask: mask}
+}
+
+func (d *derivationStream) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if len(d.buf) == 0 {
+			var ctr [4]byte
+			binary.BigEndian.PutUint32(ctr[:], d.ctr)
+			d.ctr++
+
+			s := sha256.New()
+			s.Write(derivationLabel)
+			if d.mask != nil {
+				var key [32]byte
+				for i := range key {
+					key[i] = d.key[i] ^ d.mask[i]
+				}
+				s.Write(key[:])
+				key = [32]byte{}
+			} else {
+				s.Write(d.key)
+			}
+			s.Write(ctr[:])
+			d.buf = s.Sum(nil)
+		}
+
+		c := copy(p[n:], d.buf)
+		d.buf = d.buf[c:]
+		n += c
+	}
+
+	return
+}
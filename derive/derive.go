@@ -0,0 +1,279 @@
+// Derives the seeds of many independent XNYSS long-term keys from a single
+// master seed, e.g. one recovered from a BIP39 mnemonic, so that a single
+// backup phrase recreates all keys of a wallet.
+//
+// Derivation follows the structure of BIP32: the master seed is turned into a
+// master key and chain code, from which child keys are derived along a path
+// of indices, e.g. "m/0'/3'". Since hash-based keys have no public
+// derivation, every index is hardened, and paths must mark every index as
+// such. The key at the end of the path is turned into the seed and public
+// seed of a tree, see Key.Seeds and xnyss.New.
+//
+// Keys derived here are not BIP32 keys: the domain separation differs, so the
+// same master seed never yields related ECDSA and XNYSS keys.
+package derive
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSeedLength  = errors.New("master seed must hold between 16 and 64 bytes")
+	ErrInvalidPath = errors.New("invalid derivation path")
+	ErrNotHardened = errors.New("derivation path holds an index that is not hardened")
+	ErrDepth       = errors.New("derivation path is deeper than MaxDepth")
+)
+
+// The offset of hardened indices. All indices of a path are hardened.
+const Hardened uint32 = 1 << 31
+
+// The maximum depth of a key, as in BIP32.
+const MaxDepth = 255
+
+// Domain separation keys of the master key and of tree seeds.
+const (
+	masterDomain = "xnyss seed"
+	seedsDomain  = "xnyss tree seeds"
+)
+
+// Returns the master seed of a BIP39 mnemonic sentence and passphrase:
+// PBKDF2-HMAC-SHA512 with 2048 iterations and salt "mnemonic" + passphrase.
+// Words are separated by single spaces. The package does not hold the BIP39
+// word lists, so the mnemonic is not checked against them, and must already be
+// in Unicode normalization form NFKD, which English mnemonics are.
+func SeedFromMnemonic(mnemonic, passphrase string) []byte {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+
+	// Only fails for invalid key lengths
+	seed, _ := pbkdf2.Key(sha512.New, mnemonic, []byte("mnemonic"+passphrase), 2048, 64)
+
+	return seed
+}
+
+// A key of the derivation hierarchy.
+type Key struct {
+	key       [32]byte
+	chainCode [32]byte
+	depth     uint8
+}
+
+// Returns the master key of the master seed seed, which must hold between 16
+// and 64 bytes, e.g. as returned by SeedFromMnemonic.
+func NewMaster(seed []byte) (*Key, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, ErrSeedLength
+	}
+
+	return newKey(hmacSHA512([]byte(masterDomain), seed), 0), nil
+}
+
+func newKey(i []byte, depth uint8) *Key {
+	k := &Key{depth: depth}
+	copy(k.key[:], i[:32])
+	copy(k.chainCode[:], i[32:])
+	wipe(i)
+
+	return k
+}
+
+// Returns the depth of k: zero for the master key, and the length of the path
+// it was derived along otherwise.
+func (k *Key) Depth() int {
+	return int(k.depth)
+}
+
+// Derives the hardened child of k with index index, which must be at least
+// Hardened: HMAC-SHA512 keyed with the chain code of k, of a zero byte, the key
+// of k and the index. Returns ErrNotHardened for other indices, and ErrDepth
+// if k is at MaxDepth.
+func (k *Key) Child(index uint32) (*Key, error) {
+	if index < Hardened {
+		return nil, ErrNotHardened
+	}
+	if k.depth == MaxDepth {
+		return nil, ErrDepth
+	}
+
+	data := make([]byte, 1+32+4)
+	copy(data[1:], k.key[:])
+	binary.BigEndian.PutUint32(data[33:], index)
+	defer wipe(data)
+
+	return newKey(hmacSHA512(k.chainCode[:], data), k.depth+1), nil
+}
+
+// Derives the key at path p below k.
+func (k *Key) Derive(p Path) (*Key, error) {
+	if len(p) > MaxDepth-int(k.depth) {
+		return nil, ErrDepth
+	}
+
+	key := k
+	for _, index := range p {
+		child, err := key.Child(index)
+		if key != k {
+			key.Wipe()
+		}
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	if key == k {
+		copied := *k
+		key = &copied
+	}
+
+	return key, nil
+}
+
+// Returns the seed and public seed of the tree of k, for xnyss.New: the two
+// halves of HMAC-SHA512 keyed with the key of k, of "xnyss tree seeds".
+func (k *Key) Seeds() (seed, pubSeed []byte) {
+	i := hmacSHA512(k.key[:], []byte(seedsDomain))
+
+	return i[:32], i[32:]
+}
+
+// Overwrites the key and chain code of k with zeros.
+func (k *Key) Wipe() {
+	k.key = [32]byte{}
+	k.chainCode = [32]byte{}
+}
+
+// Derives the seed and public seed of the tree at path below the master key of
+// masterSeed, see NewMaster and ParsePath.
+func Seeds(masterSeed []byte, path string) (seed, pubSeed []byte, err error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	master, err := NewMaster(masterSeed)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer master.Wipe()
+
+	k, err := master.Derive(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer k.Wipe()
+
+	seed, pubSeed = k.Seeds()
+
+	return seed, pubSeed, nil
+}
+
+// A derivation path: the indices of the keys from the master key to the
+// derived key, including Hardened.
+type Path []uint32
+
+// Parses a derivation path in the notation of BIP32, e.g. "m/0'/3'", where
+// hardened indices are marked by ' or h. Returns ErrNotHardened if an index is
+// not marked, and ErrInvalidPath if the path is malformed.
+func ParsePath(s string) (Path, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, ErrInvalidPath
+	}
+	if len(parts)-1 > MaxDepth {
+		return nil, ErrDepth
+	}
+
+	p := make(Path, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		index := strings.TrimRight(part, "'h")
+		if len(part)-len(index) > 1 {
+			return nil, ErrInvalidPath
+		}
+		n, err := strconv.ParseUint(index, 10, 31)
+		if err != nil || index == "" || (index[0] == '0' && len(index) > 1) {
+			return nil, ErrInvalidPath
+		}
+		if index == part {
+			return nil, ErrNotHardened
+		}
+		p = append(p, uint32(n)+Hardened)
+	}
+
+	return p, nil
+}
+
+// Returns p in the notation of BIP32, marking hardened indices with '. Indices
+// that are not hardened are written without mark, although they are not valid.
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, index := range p {
+		b.WriteByte('/')
+		if index >= Hardened {
+			b.WriteString(strconv.FormatUint(uint64(index-Hardened), 10))
+			b.WriteByte('\'')
+		} else {
+			b.WriteString(strconv.FormatUint(uint64(index), 10))
+		}
+	}
+
+	return b.String()
+}
+
+// Returns the path of the given indices, hardened, e.g. NewPath(0, 3) is
+// "m/0'/3'".
+func NewPath(indices ...uint32) Path {
+	p := make(Path, len(indices))
+	for i, index := range indices {
+		p[i] = index | Hardened
+	}
+
+	return p
+}
+
+// Returns the binary encoding of p: the depth as one byte, followed by the
+// indices as 4 byte big endian integers.
+func (p Path) Bytes() []byte {
+	b := make([]byte, 1, 1+4*len(p))
+	b[0] = byte(len(p))
+	for _, index := range p {
+		b = binary.BigEndian.AppendUint32(b, index)
+	}
+
+	return b
+}
+
+// Decodes a path encoded by Path.Bytes. Returns ErrNotHardened if an index is
+// not hardened, and ErrInvalidPath if b is malformed.
+func PathFromBytes(b []byte) (Path, error) {
+	if len(b) < 1 || len(b) != 1+4*int(b[0]) {
+		return nil, ErrInvalidPath
+	}
+
+	p := make(Path, b[0])
+	for i := range p {
+		p[i] = binary.BigEndian.Uint32(b[1+4*i:])
+		if p[i] < Hardened {
+			return nil, ErrNotHardened
+		}
+	}
+
+	return p, nil
+}
+
+func hmacSHA512(key, data []byte) []byte {
+	h := hmac.New(sha512.New, key)
+	h.Write(data)
+
+	return h.Sum(nil)
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
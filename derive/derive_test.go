@@ -0,0 +1,119 @@
+package derive
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+func TestSeedFromMnemonic(t *testing.T) {
+	// 1 - The first English test vector of BIP39
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	expected := "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"
+	if seed := hex.EncodeToString(SeedFromMnemonic(mnemonic, "TREZOR")); seed != expected {
+		t.Fatal("Invalid seed", seed)
+	}
+
+	// 2 - Words are separated by single spaces
+	if !bytes.Equal(SeedFromMnemonic(" abandon  about\n", ""), SeedFromMnemonic("abandon about", "")) {
+		t.Fatal("Whitespace not normalised")
+	}
+}
+
+func TestSeeds(t *testing.T) {
+	master, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Test vectors
+	vectors := []struct {
+		path, seed, pubSeed string
+	}{
+		{"m",
+			"dcf2beb15b810cd593a9930467a82db984e77a162a10df1a721a8b70280b2895",
+			"9b3449a4e0abe62f7ecf634fd433835aa7aeb15d73b60639af7cd50e81f6b922"},
+		{"m/0'",
+			"452fe1e9bf1453f8e8505ebdf10c62366fcb23c8147bc89935e53a1ced2ee670",
+			"64561b1ad259072358b516575ab162105658123c7b55ab10159ed431001b60dc"},
+		{"m/0'/1'",
+			"9785ac26018ebbc01d15709c3b4a059abc85a47ab541ad322901e6cc50ced987",
+			"eaa694cb795ebf4ed85e05ad680fd5e93047a6badffd98e6f1ba60afee77482c"},
+		{"m/44'/2147483647'/0h",
+			"0d3a3a9d9dfb02568143fe009f118b821d7411e8c1f5cfd305d27f145fb93614",
+			"88599a131cc1c958b3e3cb8b0b88ef2de89a6f2e3e0c64137b8a8ad82fb7dedf"},
+	}
+	for _, v := range vectors {
+		seed, pubSeed, err := Seeds(master, v.path)
+		if err != nil {
+			t.Fatal("Failed to derive", v.path, "-", err)
+		}
+		if hex.EncodeToString(seed) != v.seed || hex.EncodeToString(pubSeed) != v.pubSeed {
+			t.Fatal("Invalid seeds for", v.path)
+		}
+	}
+
+	// 2 - Deriving step by step gives the same key, and trees of different
+	// paths are independent
+	key, err := NewMaster(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := key.Child(0 + Hardened)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild, err := child.Derive(NewPath(1))
+	if err != nil || grandchild.Depth() != 2 {
+		t.Fatal("Failed to derive grandchild -", err)
+	}
+	seed, pubSeed := grandchild.Seeds()
+	if hex.EncodeToString(seed) != vectors[2].seed || hex.EncodeToString(pubSeed) != vectors[2].pubSeed {
+		t.Fatal("Step by step derivation differs")
+	}
+	otherSeed, otherPubSeed := child.Seeds()
+	if bytes.Equal(xnyss.New(seed, pubSeed, false).PublicKey(), xnyss.New(otherSeed, otherPubSeed, false).PublicKey()) {
+		t.Fatal("Trees of different paths have the same key")
+	}
+
+	// 3 - Indices must be hardened, and master seeds of valid length
+	if _, err := key.Child(1); err != ErrNotHardened {
+		t.Fatal("Derived child that is not hardened, err was", err)
+	}
+	if _, _, err := Seeds(master[:15], "m"); err != ErrSeedLength {
+		t.Fatal("Derived from short master seed, err was", err)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	// 1 - Paths round trip through both encodings
+	p, err := ParsePath("m/44'/0h/7'")
+	if err != nil {
+		t.Fatal("Failed to parse path -", err)
+	}
+	if p.String() != "m/44'/0'/7'" || p.String() != NewPath(44, 0, 7).String() {
+		t.Fatal("Invalid path", p)
+	}
+	decoded, err := PathFromBytes(p.Bytes())
+	if err != nil || decoded.String() != p.String() {
+		t.Fatal("Failed to decode path -", err)
+	}
+
+	// 2 - Malformed and unhardened paths are rejected
+	for _, s := range []string{"", "0'", "m/", "m/x'", "m/01'", "m/1''", "m/2147483648'"} {
+		if _, err := ParsePath(s); err != ErrInvalidPath {
+			t.Fatal("Parsed invalid path", s, "err was", err)
+		}
+	}
+	if _, err := ParsePath("m/0'/1"); err != ErrNotHardened {
+		t.Fatal("Parsed unhardened path, err was", err)
+	}
+	if _, err := PathFromBytes([]byte{1, 0, 0, 0, 1}); err != ErrNotHardened {
+		t.Fatal("Decoded unhardened path, err was", err)
+	}
+	if _, err := PathFromBytes([]byte{2, 0x80, 0, 0, 1}); err != ErrInvalidPath {
+		t.Fatal("Decoded truncated path, err was", err)
+	}
+}
@@ -0,0 +1,171 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Flags of a serialised tree. Trees serialised before the extended header was
+// introduced only use flagOTS, so they are still loaded correctly. The current
+// format frames every node, so flagNodeFields is only used by legacy states,
+// and flagCompact only by current ones, see NYTree.SetCompact.
+const (
+	flagOTS        = 0x01
+	flagExtended   = 0x02
+	flagNodeFields = 0x04
+	flagCompact    = 0x08
+
+	knownFlags = flagOTS | flagExtended | flagNodeFields
+)
+
+// Magic bytes and format version of serialised trees, see NYTree.Bytes. The
+// first byte of a legacy state holds its flags, which never equals the first
+// magic byte, so both formats can be told apart.
+const (
+	stateMagic   = "XNYS"
+	stateVersion = 0x02
+
+	// Length of the magic bytes, version, flags and seeds
+	stateHeaderLen = len(stateMagic) + 1 + 1 + 32 + 32
+)
+
+// Tags of the fields in the extended header of a serialised tree.
+const (
+	fieldConsumed   = 0x01
+	fieldEpoch      = 0x02
+	fieldRootLocked = 0x03
+	// Deterministic child derivation
+	fieldDeterministic = 0x04
+	fieldBranching     = 0x05
+	fieldStrict        = 0x06
+	// Hardened one-time trees, the spent field holds the public key
+	fieldHardened = 0x07
+	fieldSpent    = 0x08
+	// Frozen trees and the history of mode transitions
+	fieldFrozen      = 0x09
+	fieldTransitions = 0x0a
+	// The signature counter, only present if counter binding is enabled
+	fieldCounter = 0x0b
+	// Position addressing of the keys of nodes
+	fieldAddressed = 0x0c
+	// The hash suite, only present if it is not SHA-256
+	fieldHashSuite = 0x0d
+	// The Winternitz parameter, only present if it is not the default
+	fieldWinternitz = 0x0e
+	// The registered parameter set, see ParamSet. Replaces the hash suite and
+	// Winternitz parameter fields of trees that have one other than
+	// ParamsDefault.
+	fieldParamSet = 0x0f
+	// Pairs of txids, see NYTree.InvalidateTxid
+	fieldTxidParents = 0x10
+	// The maximum depth of nodes, see NYTree.SetMaxDepth
+	fieldMaxDepth = 0x11
+	// The child txid policy, only present if it is not TxidInherit
+	fieldTxidPolicy = 0x12
+	// The owner identifier, see NYTree.Owner
+	fieldOwner = 0x13
+	// Hashing of transaction identifiers, see NYTree.SetTxidHashing
+	fieldTxidHashing = 0x14
+	// The description of a backup, and the number of backups created by a
+	// tree, see NYTree.BackupInfo
+	fieldBackupInfo = 0x15
+	fieldBackups    = 0x16
+	// The instances that consumed nodes, see NYTree.Apply
+	fieldConsumedBy = 0x17
+	// The revocation node, see NYTree.EnableRevocation
+	fieldRevocation = 0x18
+	// The revoked nodes and the sequence number of the last revocation list,
+	// see NYTree.RevokeNodes
+	fieldRevokedNodes = 0x19
+	// The long-term public key of a tree whose seeds are held by a seed
+	// provider, see NewWithSeedProvider
+	fieldSeedProvider = 0x1a
+	// Verification of signatures before they are returned, see
+	// NYTree.SetVerifyAfterSign
+	fieldVerifyAfterSign = 0x1b
+	// The node selection strategy, see NYTree.SetSelection
+	fieldSelection = 0x1c
+	// The audit log of signatures, see NYTree.EnableAudit
+	fieldAudit = 0x1d
+	// The parents of consumed nodes, see NYTree.Topology
+	fieldNodeParents = 0x1e
+	// The reservations of nodes, see NYTree.Reserve
+	fieldReservations = 0x1f
+	// The seed digests of consumed nodes, see NYTree.Validate
+	fieldConsumedSeeds = 0x20
+)
+
+// Tags of the additional fields of serialised nodes, which follow the node
+// record in its frame. In legacy states, flagNodeFields is set if any node has
+// additional fields, and every node record is followed by
+// uint32(len(fields)) || fields.
+const (
+	nodeFieldLabel = 0x01
+	nodeFieldDepth = 0x02
+	// Index of addressed nodes, see NYTree.EnableAddressing
+	nodeFieldIndex = 0x03
+	// The cached public key hash of the node
+	nodeFieldPkh = 0x04
+	// Index of nodes of deterministic trees that are not addressed
+	nodeFieldDerivedIndex = 0x05
+	// Creation time of the node in Unix seconds, see NYTree.Prune
+	nodeFieldCreated = 0x06
+	// Length of the transaction identifier the txid of the node was hashed
+	// from, see NYTree.SetTxidHashing
+	nodeFieldTxidLen = 0x07
+	// Public key hash of the parent of the node, see NYTree.Topology
+	nodeFieldParent = 0x08
+)
+
+var (
+	ErrFieldInvalid = newError(ErrEncoding, "invalid or unknown field in encoding")
+)
+
+// Reads a frame written by stateWriter.frame from the start of b, returning its
+// contents and the number of bytes read.
+func readFrame(b []byte) ([]byte, int, error) {
+	if len(b) < 4 {
+		return nil, 0, ErrTreeInvalidInput
+	}
+
+	length := binary.BigEndian.Uint32(b)
+	if uint64(length) > uint64(len(b)-4) {
+		return nil, 0, ErrTreeInvalidInput
+	}
+
+	return b[4 : 4+int(length)], 4 + int(length), nil
+}
+
+// Writes a field as tag || uint32(len(value)) || value.
+func writeField(buf *bytes.Buffer, tag byte, value []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+
+	buf.WriteByte(tag)
+	buf.Write(length[:])
+	buf.Write(value)
+}
+
+// Calls f for every field encoded in b, in order. Returns ErrFieldInvalid if b
+// does not consist of whole fields, or the error returned by f.
+func readFields(b []byte, f func(tag byte, value []byte) error) error {
+	for offset := 0; offset < len(b); {
+		if len(b)-offset < 5 {
+			return ErrFieldInvalid
+		}
+
+		tag := b[offset]
+		length := binary.BigEndian.Uint32(b[offset+1:])
+		offset += 5
+
+		if uint64(length) > uint64(len(b)-offset) {
+			return ErrFieldInvalid
+		}
+		if err := f(tag, b[offset:offset+int(length)]); err != nil {
+			return err
+		}
+		offset += int(length)
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+var (
+	ErrKDFUnknown       = newError(ErrEncoding, "unknown or unregistered key derivation function")
+	ErrKDFInvalid       = newError(ErrState, "invalid key derivation function")
+	ErrInvalidEncrypted = newError(ErrEncoding, "invalid encrypted state encoding")
+	ErrDecryptionFailed = newError(ErrCrypto, "wrong passphrase or corrupted encrypted state")
+)
+
+// Identifies the function deriving the encryption key of an encrypted state
+// from its passphrase.
+type KDF uint8
+
+const (
+	// Argon2id. The standard library does not implement Argon2, so the
+	// application must register it using RegisterKDF, e.g. with
+	// golang.org/x/crypto/argon2.IDKey, before it is used by default.
+	KDFArgon2id KDF = 0x01
+	// PBKDF2 with HMAC-SHA256, using Time as the iteration count. For
+	// applications that can not use Argon2id.
+	KDFPBKDF2SHA256 KDF = 0x02
+)
+
+// The parameters of a key derivation function, recorded in encrypted states.
+type KDFParams struct {
+	KDF KDF
+	// The number of passes (Argon2id) or iterations (PBKDF2)
+	Time uint32
+	// The memory in KiB, only used by Argon2id
+	Memory uint32
+	// The degree of parallelism, only used by Argon2id
+	Threads uint8
+}
+
+// The iteration count of PBKDF2 used by DefaultEncryptionParams, as recommended
+// by OWASP for PBKDF2 with HMAC-SHA256.
+const PBKDF2Iterations = 600000
+
+// Returns the key derivation parameters used by BytesEncrypted: Argon2id with
+// the parameters recommended by RFC 9106 for memory constrained environments
+// if the application registered it (see RegisterKDF), and PBKDF2 with
+// HMAC-SHA256 and PBKDF2Iterations otherwise.
+func DefaultEncryptionParams() KDFParams {
+	kdfsMu.RLock()
+	_, argon2id := kdfs[KDFArgon2id]
+	kdfsMu.RUnlock()
+	if argon2id {
+		return KDFParams{KDF: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	}
+
+	return KDFParams{KDF: KDFPBKDF2SHA256, Time: PBKDF2Iterations}
+}
+
+// Derives a 32 byte key from a passphrase and salt.
+type KDFFunc func(passphrase, salt []byte, p KDFParams) []byte
+
+var (
+	kdfsMu sync.RWMutex
+	kdfs   = map[KDF]KDFFunc{
+		KDFPBKDF2SHA256: func(passphrase, salt []byte, p KDFParams) []byte {
+			// Only fails for invalid key lengths
+			key, _ := pbkdf2.Key(sha256.New, string(passphrase), salt, int(p.Time), encryptionKeyLen)
+			return key
+		},
+	}
+)
+
+// Registers the key derivation function k, replacing any function registered
+// before. Returns ErrKDFInvalid if k is zero or f is nil.
+func RegisterKDF(k KDF, f KDFFunc) error {
+	if k == 0 || f == nil {
+		return ErrKDFInvalid
+	}
+
+	kdfsMu.Lock()
+	defer kdfsMu.Unlock()
+
+	kdfs[k] = f
+
+	return nil
+}
+
+// Derives the encryption key for passphrase and salt using p. Returns
+// ErrKDFUnknown if the function of p is not registered.
+func (p KDFParams) deriveKey(passphrase, salt []byte) ([]byte, error) {
+	kdfsMu.RLock()
+	f, ok := kdfs[p.KDF]
+	kdfsMu.RUnlock()
+	if !ok {
+		return nil, ErrKDFUnknown
+	}
+	if p.Time == 0 {
+		return nil, ErrKDFInvalid
+	}
+
+	key := f(passphrase, salt, p)
+	if len(key) != encryptionKeyLen {
+		return nil, ErrKDFInvalid
+	}
+
+	return key, nil
+}
+
+// Version byte of an encrypted state.
+const encryptedVersion = 0x01
+
+// Tags of the fields of an encrypted state. The ciphertext field comes last,
+// and everything before it is authenticated as additional data.
+const (
+	encryptedFieldKDF        = 0x01
+	encryptedFieldSalt       = 0x02
+	encryptedFieldNonce      = 0x03
+	encryptedFieldCiphertext = 0x04
+
+	encryptionKeyLen = 32
+	saltLen          = 16
+	gcmNonceLen      = 12
+	kdfParamsLen     = 1 + 4 + 4 + 1
+)
+
+// Returns the state of the tree t (see Bytes), encrypted using AES-256-GCM with
+// a key derived from passphrase using DefaultEncryptionParams. The encrypted
+// state is self-describing: it records the key derivation function and its
+// parameters, so LoadEncrypted does not need them.
+func (t *NYTree) BytesEncrypted(passphrase []byte) ([]byte, error) {
+	return t.BytesEncryptedWith(passphrase, DefaultEncryptionParams())
+}
+
+// Like BytesEncrypted, deriving the key using the parameters p. Returns
+// ErrKDFUnknown if the key derivation function of p is not registered.
+func (t *NYTree) BytesEncryptedWith(passphrase []byte, p KDFParams) ([]byte, error) {
+	if t.Wiped() {
+		return nil, ErrTreeWiped
+	}
+
+	// Read the salt and nonce with t locked, since its entropy source need not
+	// be safe for concurrent use
+	random := make([]byte, saltLen+gcmNonceLen)
+	t.mu.Lock()
+	_, err := io.ReadFull(t.random(), random)
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := t.Bytes()
+	defer wipeBytes(plaintext)
+
+	return encryptState(plaintext, passphrase, p, bytes.NewReader(random))
+}
+
+// Encrypts the serialised state plaintext with a key derived from passphrase
+// using p, see BytesEncrypted, reading the salt and nonce from r.
+func encryptState(plaintext, passphrase []byte, p KDFParams, r io.Reader) ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, err
+	}
+	key, err := p.deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(key)
+
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(r, nonce); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(encryptedVersion)
+	writeField(buf, encryptedFieldKDF, p.encode())
+	writeField(buf, encryptedFieldSalt, salt)
+	writeField(buf, encryptedFieldNonce, nonce)
+
+	writeField(buf, encryptedFieldCiphertext, aead.Seal(nil, nonce, plaintext, buf.Bytes()))
+
+	return buf.Bytes(), nil
+}
+
+// Decrypts and loads a state encrypted by BytesEncrypted. Returns
+// ErrDecryptionFailed if the passphrase is wrong or the encrypted state was
+// modified, and the errors of Load.
+func LoadEncrypted(b, passphrase []byte) (*NYTree, error) {
+	plaintext, err := decryptState(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	defer wipeBytes(plaintext)
+
+	return Load(plaintext)
+}
+
+// Decrypts a state encrypted by encryptState. Returns ErrDecryptionFailed if
+// the passphrase is wrong or the encrypted state was modified.
+func decryptState(b, passphrase []byte) ([]byte, error) {
+	if len(b) < 1 || b[0] != encryptedVersion {
+		return nil, ErrInvalidEncrypted
+	}
+
+	var p KDFParams
+	var salt, nonce, ciphertext []byte
+	aadLen := 1
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		if ciphertext != nil {
+			return ErrInvalidEncrypted
+		}
+
+		switch tag {
+		case encryptedFieldKDF:
+			if len(value) != kdfParamsLen {
+				return ErrInvalidEncrypted
+			}
+			p = decodeKDFParams(value)
+		case encryptedFieldSalt:
+			salt = value
+		case encryptedFieldNonce:
+			nonce = value
+		case encryptedFieldCiphertext:
+			ciphertext = value
+			return nil
+		default:
+			return ErrFieldInvalid
+		}
+		aadLen += 5 + len(value)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.KDF == 0 || salt == nil || ciphertext == nil {
+		return nil, ErrInvalidEncrypted
+	}
+
+	key, err := p.deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(key)
+
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, ErrInvalidEncrypted
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, b[:aadLen])
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
+
+func newStateAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (p KDFParams) encode() []byte {
+	b := make([]byte, kdfParamsLen)
+	b[0] = byte(p.KDF)
+	binary.BigEndian.PutUint32(b[1:], p.Time)
+	binary.BigEndian.PutUint32(b[5:], p.Memory)
+	b[9] = p.Threads
+
+	return b
+}
+
+func decodeKDFParams(b []byte) KDFParams {
+	return KDFParams{
+		KDF:     KDF(b[0]),
+		Time:    binary.BigEndian.Uint32(b[1:]),
+		Memory:  binary.BigEndian.Uint32(b[5:]),
+		Threads: b[9],
+	}
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,85 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// Fast key derivation parameters, for tests that encrypt states.
+var testKDFParams = KDFParams{KDF: KDFPBKDF2SHA256, Time: 1000}
+
+func TestNYTree_BytesEncrypted(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, _, err := signMessage("encrypt test", tree); err != nil {
+		t.Fatal(err)
+	}
+	passphrase := []byte("correct horse battery staple")
+
+	// 1 - Without Argon2id, PBKDF2 is used by default, while Argon2id must be
+	// registered by the application to be used
+	if p := DefaultEncryptionParams(); p.KDF != KDFPBKDF2SHA256 || p.Time != PBKDF2Iterations {
+		t.Fatal("Invalid default parameters", p)
+	}
+	encrypted, err := tree.BytesEncrypted(passphrase)
+	if err != nil {
+		t.Fatal("Failed to encrypt tree with default parameters -", err)
+	}
+	if _, err := LoadEncrypted(encrypted, passphrase); err != nil {
+		t.Fatal("Failed to load encrypted tree -", err)
+	}
+	argon2id := KDFParams{KDF: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	if _, err := tree.BytesEncryptedWith(passphrase, argon2id); err != ErrKDFUnknown {
+		t.Fatal("Encrypted with unregistered KDF, err was", err)
+	}
+
+	// 2 - Encrypted states do not hold the seeds, and load with the passphrase
+	encrypted, err = tree.BytesEncryptedWith(passphrase, testKDFParams)
+	if err != nil {
+		t.Fatal("Failed to encrypt tree -", err)
+	}
+	if bytes.Contains(encrypted, seed) || bytes.Contains(encrypted, tree.nodes[0].privSeed) {
+		t.Fatal("Encrypted state contains seeds")
+	}
+	loaded, err := LoadEncrypted(encrypted, passphrase)
+	if err != nil {
+		t.Fatal("Failed to load encrypted tree -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Loaded tree differs")
+	}
+
+	// 3 - Wrong passphrases and modified states are rejected
+	if _, err := LoadEncrypted(encrypted, []byte("wrong")); err != ErrDecryptionFailed {
+		t.Fatal("Loaded with wrong passphrase, err was", err)
+	}
+	modified := append([]byte(nil), encrypted...)
+	modified[8]++
+	if _, err := LoadEncrypted(modified, passphrase); err != ErrDecryptionFailed {
+		t.Fatal("Loaded modified state, err was", err)
+	}
+
+	// 4 - Argon2id is used by default once it is registered
+	err = RegisterKDF(KDFArgon2id, func(passphrase, salt []byte, p KDFParams) []byte {
+		key := sha256.Sum256(append(append([]byte(nil), passphrase...), salt...))
+		return key[:]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		kdfsMu.Lock()
+		delete(kdfs, KDFArgon2id)
+		kdfsMu.Unlock()
+	}()
+	if DefaultEncryptionParams() != argon2id {
+		t.Fatal("Registered Argon2id is not the default")
+	}
+	if encrypted, err = tree.BytesEncrypted(passphrase); err != nil || encrypted[6] != byte(KDFArgon2id) {
+		t.Fatal("Failed to encrypt tree with Argon2id -", err)
+	}
+}
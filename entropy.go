@@ -0,0 +1,44 @@
+package xnyss
+
+import (
+	"crypto/rand"
+	"io"
+)
+
+// Sets the entropy source from which the seeds of child nodes are generated,
+// e.g. the random number generator of an HSM, or a seeded reader for
+// reproducible tests (see Harness). If r is nil, crypto/rand is used, which is
+// the default. The source is not used for the child nodes of deterministic
+// trees (see NewDeterministic), which derive them from their parent.
+//
+// All other randomness of t is read from the source too: the masks of
+// BlindSeed, the owner identifiers assigned by Backup, and the salts and
+// nonces of BytesEncrypted.
+//
+// The entropy source is not persisted: it must be set again after Load, and is
+// not passed on to backups. It is only read with the tree locked, so it does
+// not need to be safe for concurrent use.
+func (t *NYTree) SetEntropy(r io.Reader) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entropy = r
+}
+
+// Returns the entropy source used to generate the children of node.
+func (t *NYTree) childEntropy(node *nyNode) io.Reader {
+	if t.entropy != nil && !t.deterministic {
+		return injectEntropyFault(t.entropy)
+	}
+
+	return node.entropy(t.deterministic)
+}
+
+// Returns the entropy source of t for randomness other than child seeds.
+func (t *NYTree) random() io.Reader {
+	if t.entropy != nil {
+		return t.entropy
+	}
+
+	return rand.Reader
+}
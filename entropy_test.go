@@ -0,0 +1,59 @@
+package xnyss
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetEntropy(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(tree *NYTree) *Signature {
+		sig, err := tree.Sign(testdata.Message, testdata.Txid)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		return sig
+	}
+
+	// 1 - The same entropy yields the same child nodes
+	a, b := New(seed, pubSeed, false), New(seed, pubSeed, false)
+	a.SetEntropy(rand.New(rand.NewSource(1)))
+	b.SetEntropy(rand.New(rand.NewSource(1)))
+	if !bytes.Equal(sign(a).Bytes(), sign(b).Bytes()) {
+		t.Fatal("Signatures with the same entropy differ")
+	}
+
+	// 2 - The default source is crypto/rand
+	c := New(seed, pubSeed, false)
+	c.SetEntropy(rand.New(rand.NewSource(1)))
+	c.SetEntropy(nil)
+	if bytes.Equal(sign(c).Bytes(), sign(New(seed, pubSeed, false)).Bytes()) {
+		t.Fatal("Signatures with random entropy are equal")
+	}
+
+	// 3 - Errors of the source make signing fail without consuming a node
+	d := New(seed, pubSeed, false)
+	d.SetEntropy(iotest.ErrReader(errors.New("hsm unavailable")))
+	if _, err := d.Sign(testdata.Message, testdata.Txid); err == nil {
+		t.Fatal("Signed without entropy")
+	}
+	if d.Available(nil) != 1 {
+		t.Fatal("Failed signature consumed a node")
+	}
+
+	// 4 - Deterministic trees do not use the source
+	e, f := NewDeterministic(seed, pubSeed, false), NewDeterministic(seed, pubSeed, false)
+	e.SetEntropy(rand.New(rand.NewSource(2)))
+	if !bytes.Equal(sign(e).Bytes(), sign(f).Bytes()) {
+		t.Fatal("Deterministic tree used the entropy source")
+	}
+}
@@ -0,0 +1,286 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// The newest version of the signature envelope, created by Signature.Envelope,
+// and the oldest version this package can encode and parse.
+const (
+	EnvelopeVersion       = 0x01
+	OldestEnvelopeVersion = 0x01
+)
+
+// The lowest envelope version accepted by ParseEnvelope. Verifiers raise it once
+// all their signers create newer envelopes, so signatures can not be downgraded
+// to an encoding that was replaced, e.g. after a cryptographic fix.
+var MinEnvelopeVersion uint8 = OldestEnvelopeVersion
+
+var (
+	ErrEnvelopeVersion = newError(ErrEncoding, "unsupported or rejected signature envelope version")
+)
+
+// Tags of the fields of a signature envelope.
+const (
+	sigFieldBytes    = 0x01
+	sigFieldBranches = 0x02
+	sigFieldConfirms = 0x03
+	sigFieldCounter  = 0x04
+	sigFieldPosition = 0x05
+	sigFieldScheme   = 0x06
+	sigFieldSuite    = 0x07
+	sigFieldW        = 0x08
+	sigFieldCommit   = 0x09
+	// The registered parameter set, see ParamSet. Replaces the suite and W
+	// fields of signatures that have one other than ParamsDefault.
+	sigFieldParamSet = 0x0a
+	// The randomizer of the message digest, see SignMessage.
+	sigFieldRandomizer = 0x0b
+)
+
+// The parameters of the tree that created a signature. Verifiers that accept
+// signatures from trees with different settings can use them to apply the
+// right policy, e.g. to know how many confirmations the child nodes need.
+type Params struct {
+	// The branching factor of the node that created the signature, i.e. the
+	// amount of child nodes it would have (one-time trees do not add them).
+	Branches int
+	// The value of ConfirmsRequired when the signature was created.
+	ConfirmsRequired uint8
+	// The signature scheme of the tree, or zero if the envelope did not
+	// record it.
+	Scheme Scheme
+	// The hash suite of the tree.
+	HashSuite HashSuite
+	// The Winternitz parameter of the tree.
+	Winternitz int
+}
+
+// Returns the parameters of the tree that created the signature sig. The
+// parameters are only known for signatures created by Sign, or parsed from an
+// envelope, since Signature.Bytes does not include them.
+func (sig *Signature) Params() (p Params, ok bool) {
+	if sig.params == nil {
+		return Params{}, false
+	}
+
+	return *sig.params, true
+}
+
+// Returns the envelope of the signature sig: the encoding of Bytes, together
+// with its parameters if these are known. Use ParseEnvelope to decode it.
+func (sig *Signature) Envelope() []byte {
+	envelope, _ := sig.EnvelopeVersion(EnvelopeVersion)
+	return envelope
+}
+
+// Returns the envelope of the signature sig in the given version, e.g. as
+// agreed with NegotiateEnvelope. Returns ErrEnvelopeVersion if this package can
+// not encode that version.
+func (sig *Signature) EnvelopeVersion(version uint8) ([]byte, error) {
+	if version < OldestEnvelopeVersion || version > EnvelopeVersion {
+		return nil, ErrEnvelopeVersion
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(version)
+	writeField(buf, sigFieldBytes, sig.Bytes())
+
+	if sig.params != nil {
+		var branches [4]byte
+		binary.BigEndian.PutUint32(branches[:], uint32(sig.params.Branches))
+		writeField(buf, sigFieldBranches, branches[:])
+		writeField(buf, sigFieldConfirms, []byte{sig.params.ConfirmsRequired})
+		if sig.params.Scheme != 0 {
+			writeField(buf, sigFieldScheme, []byte{byte(sig.params.Scheme)})
+		}
+	}
+	if sig.counter != nil {
+		writeField(buf, sigFieldCounter, encodeCounter(*sig.counter))
+	}
+	if sig.commitment != nil {
+		writeField(buf, sigFieldCommit, sig.commitment)
+	}
+	if sig.randomizer != nil {
+		writeField(buf, sigFieldRandomizer, sig.randomizer)
+	}
+	if sig.position != nil {
+		writeField(buf, sigFieldPosition, encodePosition(*sig.position))
+	}
+	if p, ok := sig.ParamSet(); ok && p.ID != ParamsDefault {
+		writeField(buf, sigFieldParamSet, encodeParamSetID(p.ID))
+	} else {
+		if sig.suite != 0 {
+			writeField(buf, sigFieldSuite, []byte{byte(sig.suite)})
+		}
+		if sig.w != 0 {
+			var w [2]byte
+			binary.BigEndian.PutUint16(w[:], sig.w)
+			writeField(buf, sigFieldW, w[:])
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Returns the envelope versions accepted by ParseEnvelope, newest first.
+func EnvelopeVersions() []uint8 {
+	var versions []uint8
+	for v := EnvelopeVersion; v >= int(MinEnvelopeVersion) && v >= OldestEnvelopeVersion; v-- {
+		versions = append(versions, uint8(v))
+	}
+
+	return versions
+}
+
+// Returns the newest envelope version supported by both this package (see
+// EnvelopeVersions) and a peer, given the versions the peer supports. Returns
+// ErrEnvelopeVersion if there is none.
+func NegotiateEnvelope(peer []uint8) (uint8, error) {
+	for _, v := range EnvelopeVersions() {
+		for _, p := range peer {
+			if p == v {
+				return v, nil
+			}
+		}
+	}
+
+	return 0, ErrEnvelopeVersion
+}
+
+// Decodes a signature envelope created by Signature.Envelope, for the message
+// msg. Returns ErrEnvelopeVersion if the envelope has a version below
+// MinEnvelopeVersion, or one this package does not know.
+func ParseEnvelope(b, msg []byte) (*Signature, error) {
+	if len(b) < 1 {
+		return nil, ErrInvalidSigEncoding
+	}
+	if b[0] < MinEnvelopeVersion || b[0] < OldestEnvelopeVersion || b[0] > EnvelopeVersion {
+		return nil, ErrEnvelopeVersion
+	}
+
+	var sigBytes []byte
+	var counter *uint64
+	var commitment []byte
+	var randomizer []byte
+	var position *Position
+	var suite HashSuite
+	var w uint16
+	var paramSet *ParamSet
+	var params Params
+	var fields int
+	var scheme []byte
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case sigFieldBytes:
+			sigBytes = value
+		case sigFieldBranches:
+			if len(value) != 4 {
+				return ErrFieldInvalid
+			}
+			params.Branches = int(binary.BigEndian.Uint32(value))
+			fields++
+		case sigFieldConfirms:
+			if len(value) != 1 {
+				return ErrFieldInvalid
+			}
+			params.ConfirmsRequired = value[0]
+			fields++
+		case sigFieldCounter:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			c := binary.BigEndian.Uint64(value)
+			counter = &c
+		case sigFieldScheme:
+			if len(value) != 1 {
+				return ErrFieldInvalid
+			}
+			scheme = value
+		case sigFieldPosition:
+			p, err := decodePosition(value)
+			if err != nil {
+				return err
+			}
+			position = p
+		case sigFieldSuite:
+			if len(value) != 1 || value[0] == byte(SuiteSHA256) {
+				return ErrFieldInvalid
+			}
+			if _, err := HashSuite(value[0]).lookup(); err != nil {
+				return err
+			}
+			suite = HashSuite(value[0])
+		case sigFieldCommit:
+			if len(value) == 0 {
+				return ErrFieldInvalid
+			}
+			commitment = append([]byte(nil), value...)
+		case sigFieldRandomizer:
+			if len(value) != RandomizerLen {
+				return ErrFieldInvalid
+			}
+			randomizer = append([]byte(nil), value...)
+		case sigFieldW:
+			if len(value) != 2 {
+				return ErrFieldInvalid
+			}
+			w = binary.BigEndian.Uint16(value)
+			if validWinternitz(int(w)) != nil || w == DefaultWinternitz {
+				return ErrFieldInvalid
+			}
+		case sigFieldParamSet:
+			p, err := decodeParamSetID(value)
+			if err != nil {
+				return err
+			}
+			paramSet = &p
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if sigBytes == nil || (fields != 0 && fields != 2) || (scheme != nil && fields == 0) {
+		return nil, ErrInvalidSigEncoding
+	}
+	if paramSet != nil {
+		if suite != 0 || w != 0 || (fields != 0 && params.Branches != paramSet.Branches) {
+			return nil, ErrFieldInvalid
+		}
+		if suite, w, err = paramSet.keyParams(); err != nil {
+			return nil, err
+		}
+	}
+
+	sig, err := newSignature(w, sigBytes, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if fields != 0 {
+		if scheme != nil {
+			params.Scheme = Scheme(scheme[0])
+		}
+		sig.params = &params
+	}
+	sig.counter = counter
+	sig.commitment = commitment
+	sig.randomizer = randomizer
+	sig.position = position
+	sig.suite = suite
+	if sig.params != nil {
+		sig.params.HashSuite = sig.HashSuite()
+		sig.params.Winternitz = sig.Winternitz()
+	}
+	if err := sig.checkScheme(); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
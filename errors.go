@@ -0,0 +1,46 @@
+package xnyss
+
+import "errors"
+
+// Categories of the errors returned by this package. Every exported error of
+// the package matches exactly one category using errors.Is, so callers can
+// decide how to handle an error without matching its message:
+//
+//	if errors.Is(err, xnyss.ErrCapacity) {
+//		// Wait for confirmations, or rotate the key if ErrTreeExhausted
+//	}
+//
+// The category of an error can also be retrieved using errors.As, see Error.
+var (
+	// The operation is not allowed in the current state of the tree, or the
+	// state itself is inconsistent.
+	ErrState = errors.New("xnyss: invalid state")
+	// A signature, chain or primitive failed a cryptographic check.
+	ErrCrypto = errors.New("xnyss: cryptographic check failed")
+	// The tree can not sign now (e.g. nodes are awaiting confirmations) or
+	// ever again.
+	ErrCapacity = errors.New("xnyss: no signing capacity")
+	// An input or serialised artifact is malformed or unsupported.
+	ErrEncoding = errors.New("xnyss: invalid encoding")
+)
+
+// The type of the exported errors of this package.
+type Error struct {
+	// One of ErrState, ErrCrypto, ErrCapacity and ErrEncoding.
+	Category error
+	msg      string
+}
+
+func newError(category error, msg string) error {
+	return &Error{Category: category, msg: msg}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Reports whether target is the category of e, so that errors.Is(err,
+// ErrCapacity) holds for e.g. ErrTreeNoneAvailable.
+func (e *Error) Is(target error) bool {
+	return target == e.Category
+}
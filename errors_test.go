@@ -0,0 +1,48 @@
+package xnyss
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCategories(t *testing.T) {
+	categories := map[error]error{
+		ErrTreeNoneAvailable:  ErrCapacity,
+		ErrTreeExhausted:      ErrCapacity,
+		ErrTreeSeedReuse:      ErrState,
+		ErrTreeChecksum:       ErrEncoding,
+		ErrInvalidSigEncoding: ErrEncoding,
+		ErrChainBroken:        ErrCrypto,
+	}
+
+	for err, category := range categories {
+		wrapped := fmt.Errorf("wrapped: %w", err)
+		if !errors.Is(wrapped, category) || !errors.Is(wrapped, err) {
+			t.Fatal("Error", err, "does not match its category")
+		}
+		for _, other := range []error{ErrState, ErrCrypto, ErrCapacity, ErrEncoding} {
+			if other != category && errors.Is(err, other) {
+				t.Fatal("Error", err, "matches more than one category")
+			}
+		}
+
+		var xerr *Error
+		if !errors.As(wrapped, &xerr) || xerr.Category != category {
+			t.Fatal("Failed to retrieve the category of", err)
+		}
+	}
+
+	// Errors returned by the tree match their category
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, true)
+	if _, _, err := signMessage("first", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if _, _, err := signMessage("second", tree); !errors.Is(err, ErrCapacity) {
+		t.Fatal("Exhausted tree did not return a capacity error, err was", err)
+	}
+}
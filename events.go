@@ -0,0 +1,182 @@
+package xnyss
+
+import (
+	"sort"
+)
+
+// Kinds of events passed to OnEvent.
+const (
+	// A node reached ConfirmsRequired confirmations.
+	EventConfirmed = "confirmed"
+	// A node was consumed by Sign. Only passed to the handlers of trees, see
+	// NYTree.OnEvent.
+	EventConsumed = "consumed"
+	// A node was consumed by Sign without adding children, e.g. because it
+	// is at the maximum depth (see SetMaxDepth), so its branch of the tree
+	// ends. It follows the EventConsumed of the node, and is only passed to
+	// the handlers of trees.
+	EventBranchExhausted = "branch_exhausted"
+	// The capacity of a tree dropped below one of the CapacityThresholds, or
+	// the threshold of the tree, see SetCapacityThreshold.
+	EventCapacityLow = "capacity_low"
+	// The capacity of a tree rose to or above one of the thresholds.
+	EventCapacityRestored = "capacity_restored"
+)
+
+// An event describing a change of the signing state of a tree.
+type Event struct {
+	Kind   string
+	TreeID string
+	// The public key hash of the node, for EventConfirmed, EventConsumed and
+	// EventBranchExhausted.
+	PubKeyHash []byte
+	// The amount of confirmed nodes in the tree after the change.
+	Capacity int
+	// The threshold that was crossed, for the capacity events.
+	Threshold int
+}
+
+// When not nil, OnEvent is called synchronously when a node of a tree becomes
+// confirmed, and when the capacity of a tree crosses one of the
+// CapacityThresholds. It lets applications (e.g. a webhook sender, see the
+// webhook package) react to signer state without polling Available. OnEvent
+// must not use the tree that caused the event. Handlers of a single tree can be
+// set using NYTree.OnEvent.
+var OnEvent func(e Event)
+
+// Capacities, in confirmed nodes, at which EventCapacityLow and
+// EventCapacityRestored are emitted.
+var CapacityThresholds = []int{1, 5}
+
+// Sets the handler called synchronously for every event of the tree t, after
+// the package level OnEvent, or removes it if fn is nil. Unlike the package
+// level OnEvent, the handler also receives EventConsumed and
+// EventBranchExhausted. The handler is not persisted, and must not use t.
+func (t *NYTree) OnEvent(fn func(Event)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onEvent = fn
+}
+
+// Sets a capacity, in confirmed nodes, at which EventCapacityLow and
+// EventCapacityRestored are emitted for the tree t in addition to the
+// CapacityThresholds, so a wallet can warn its user before the key becomes
+// unusable. Zero removes the threshold. The threshold is not persisted.
+func (t *NYTree) SetCapacityThreshold(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.capacityThreshold = n
+}
+
+// Passes the event e to the handlers of t.
+func (t *NYTree) emit(e Event) {
+	if OnEvent != nil {
+		OnEvent(e)
+	}
+	if t.onEvent != nil {
+		t.onEvent(e)
+	}
+}
+
+// Returns the amount of confirmed nodes that can be used to sign.
+func (t *NYTree) capacity() int {
+	n := t.confirmedNodes()
+	// A locked root is confirmed, but can not sign
+	if t.rootLocked {
+		for _, node := range t.nodes {
+			if t.isRoot(node) && node.confirms >= ConfirmsRequired {
+				n--
+			}
+		}
+	}
+
+	return n
+}
+
+// Returns whether the events of t are passed to a handler.
+func (t *NYTree) observed() bool {
+	return OnEvent != nil || t.onEvent != nil
+}
+
+// Returns the capacity of t to pass to notifyCapacity, which is only computed
+// if the events of t are observed.
+func (t *NYTree) observedCapacity() int {
+	if !t.observed() {
+		return 0
+	}
+
+	return t.capacity()
+}
+
+// Emits capacity events for every threshold crossed since the capacity was
+// before.
+func (t *NYTree) notifyCapacity(before int) {
+	if !t.observed() {
+		return
+	}
+
+	thresholds := append([]int(nil), CapacityThresholds...)
+	if t.capacityThreshold > 0 && !containsInt(thresholds, t.capacityThreshold) {
+		thresholds = append(thresholds, t.capacityThreshold)
+	}
+	sort.Ints(thresholds)
+
+	after := t.capacity()
+	for _, threshold := range thresholds {
+		kind := ""
+		if before >= threshold && after < threshold {
+			kind = EventCapacityLow
+		} else if before < threshold && after >= threshold {
+			kind = EventCapacityRestored
+		}
+
+		if kind != "" {
+			t.emit(Event{
+				Kind:      kind,
+				TreeID:    t.ID(),
+				Capacity:  after,
+				Threshold: threshold,
+			})
+		}
+	}
+}
+
+func (t *NYTree) notifyConfirmed(pkh []byte) {
+	t.notifyAvailable()
+	if !t.observed() {
+		return
+	}
+
+	t.emit(Event{
+		Kind:       EventConfirmed,
+		TreeID:     t.ID(),
+		PubKeyHash: append([]byte(nil), pkh...),
+		Capacity:   t.capacity(),
+	})
+}
+
+// Emits EventConsumed for the node with public key hash pkh, followed by
+// EventBranchExhausted if the node did not add children.
+func (t *NYTree) notifyConsumed(pkh []byte, exhausted bool) {
+	if t.onEvent == nil {
+		return
+	}
+
+	capacity := t.capacity()
+	t.onEvent(Event{Kind: EventConsumed, TreeID: t.ID(), PubKeyHash: append([]byte(nil), pkh...), Capacity: capacity})
+	if exhausted {
+		t.onEvent(Event{Kind: EventBranchExhausted, TreeID: t.ID(), PubKeyHash: append([]byte(nil), pkh...), Capacity: capacity})
+	}
+}
+
+func containsInt(s []int, n int) bool {
+	for _, v := range s {
+		if v == n {
+			return true
+		}
+	}
+
+	return false
+}
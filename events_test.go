@@ -0,0 +1,73 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_OnEvent(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetMaxDepth(1)
+
+	var events []Event
+	tree.OnEvent(func(e Event) { events = append(events, e) })
+	tree.SetCapacityThreshold(Branches)
+
+	// 1 - Consuming the root emits an event
+	sig, _, err := signMessage("events test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || events[0].Kind != EventConsumed {
+		t.Fatal("Consumed event not emitted")
+	}
+
+	// 2 - Confirming the children emits events, and restores the capacity
+	events = nil
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if len(events) < len(sig.ChildHashes) || events[0].Kind != EventConfirmed || !bytes.Equal(events[0].PubKeyHash, sig.ChildHashes[0]) {
+		t.Fatal("Confirmed events not emitted")
+	}
+	if !containsEvent(events, EventCapacityRestored, Branches) {
+		t.Fatal("Capacity event of tree threshold not emitted")
+	}
+
+	// 3 - Nodes at the maximum depth exhaust their branch, dropping the
+	// capacity below the threshold of the tree
+	events = nil
+	if _, _, err = signMessage("events test", tree); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) < 2 || events[0].Kind != EventConsumed || events[1].Kind != EventBranchExhausted {
+		t.Fatal("Branch exhausted event not emitted")
+	}
+	if !containsEvent(events, EventCapacityLow, Branches) {
+		t.Fatal("Capacity event of tree threshold not emitted")
+	}
+
+	// 4 - Removed handlers are not called
+	events = nil
+	tree.OnEvent(nil)
+	if _, _, err = signMessage("events test", tree); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatal("Removed handler called")
+	}
+}
+
+func containsEvent(events []Event, kind string, threshold int) bool {
+	for _, e := range events {
+		if e.Kind == kind && e.Threshold == threshold {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,285 @@
+// A minimal but complete XNYSS wallet, built only on the public APIs of the
+// xnyss package. It serves as a reference for how the pieces compose:
+//
+//   - A Keystore keeps the state of the tree encrypted on disk (see
+//     xnyss.NYTree.BytesEncrypted), together with the journal of signatures in
+//     progress.
+//   - A ConfirmationSource, e.g. a blockchain node, reports the confirmations
+//     of the transactions that created new nodes, which Refresh applies.
+//   - Sign runs a signing session: the node is selected and journaled before
+//     the signature is created, and the new state is stored before the journal
+//     entry is dropped (see xnyss.NYTree.BeginSign), so a crash at any point
+//     never lets a node sign twice. Open recovers interrupted sessions.
+//   - Backup splits off nodes into the keystore of another device.
+//
+// Encrypted states use xnyss.DefaultEncryptionParams, which derive keys with
+// Argon2id if the application registered it (see xnyss.RegisterKDF), and with
+// PBKDF2 otherwise.
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrKeystoreExists = errors.New("keystore already holds a wallet")
+	ErrKeystoreEmpty  = errors.New("keystore does not hold a wallet")
+)
+
+// Name of the encrypted state in a keystore directory, and the prefix of its
+// journal entries.
+const (
+	stateFile     = "state"
+	journalPrefix = "journal-"
+)
+
+// Stores the encrypted state of a wallet, and the journal entries of its
+// signatures in progress, in a directory. Every file is replaced atomically.
+type Keystore struct {
+	dir        string
+	passphrase []byte
+	kdf        xnyss.KDFParams
+}
+
+// Creates a keystore in the directory dir, which must exist, encrypting the
+// state with passphrase.
+func NewKeystore(dir string, passphrase []byte) *Keystore {
+	return &Keystore{dir: dir, passphrase: append([]byte(nil), passphrase...), kdf: xnyss.DefaultEncryptionParams()}
+}
+
+// Sets the key derivation parameters used to encrypt the state of ks. States
+// record their parameters, so states encrypted before still load.
+func (ks *Keystore) SetKDFParams(p xnyss.KDFParams) {
+	ks.kdf = p
+}
+
+// Reports the confirmations of the signatures of a wallet, e.g. by looking up
+// the transactions that include them on the blockchain.
+type ConfirmationSource interface {
+	// Returns the number of confirmations of the transaction whose signature
+	// created the node with public key hash pkh, or zero if it is not known.
+	Confirmations(ctx context.Context, pkh []byte) (uint8, error)
+}
+
+// A wallet signing with an XNYSS tree kept in a keystore.
+type Wallet struct {
+	mu     sync.Mutex
+	ks     *Keystore
+	tree   *xnyss.NYTree
+	source ConfirmationSource
+}
+
+// Creates a wallet with a fresh deterministic tree from seed and pubSeed in the
+// keystore ks. Returns ErrKeystoreExists if ks already holds a wallet.
+func Create(ks *Keystore, source ConfirmationSource, seed, pubSeed []byte) (*Wallet, error) {
+	if _, err := os.Stat(ks.path(stateFile)); err == nil {
+		return nil, ErrKeystoreExists
+	}
+
+	w := &Wallet{ks: ks, tree: xnyss.NewDeterministic(seed, pubSeed, false), source: source}
+	if err := ks.save(w.tree); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Opens the wallet in the keystore ks, recovering the signatures that were in
+// progress when it was last used. Returns ErrKeystoreEmpty if ks holds no
+// wallet.
+func Open(ks *Keystore, source ConfirmationSource) (*Wallet, error) {
+	tree, err := ks.load()
+	if err != nil {
+		return nil, err
+	}
+
+	journal, names, err := ks.journal()
+	if err != nil {
+		return nil, err
+	}
+	if len(journal) > 0 {
+		if _, err := tree.Recover(journal); err != nil {
+			return nil, err
+		}
+		if err := ks.save(tree); err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if err := os.Remove(ks.path(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Wallet{ks: ks, tree: tree, source: source}, nil
+}
+
+// Returns the long-term public key of the wallet.
+func (w *Wallet) PublicKey() []byte {
+	return w.tree.PublicKey()
+}
+
+// Returns the amount of signatures the wallet can create right now.
+func (w *Wallet) Available() int {
+	return w.tree.Available(nil)
+}
+
+// Signs msg for the transaction txid in a signing session, see the package
+// documentation. The signature must be included in the transaction, and its
+// child nodes become available once Refresh sees it confirmed.
+func (w *Wallet) Sign(msg, txid []byte) (*xnyss.Signature, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	p, err := w.tree.BeginSign(txid)
+	if err != nil {
+		return nil, err
+	}
+	entry := journalPrefix + hex.EncodeToString(p.PublicKeyHash())
+	if err := w.ks.write(entry, p.Journal()); err != nil {
+		w.tree.AbortSign(p)
+		return nil, err
+	}
+
+	sig, err := w.tree.CommitSign(p, msg)
+	if err != nil {
+		// The node is consumed by Open if the abort is not stored
+		w.tree.AbortSign(p)
+		return nil, err
+	}
+	if err := w.ks.save(w.tree); err != nil {
+		return nil, err
+	}
+
+	return sig, os.Remove(w.ks.path(entry))
+}
+
+// Applies the confirmations reported by the confirmation source to the
+// unconfirmed nodes of the wallet, and stores the new state. Returns the
+// amount of nodes that became available.
+func (w *Wallet) Refresh(ctx context.Context) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	before := w.tree.Available(nil)
+	for _, pkh := range w.tree.Unconfirmed() {
+		confirms, err := w.source.Confirmations(ctx, pkh)
+		if err != nil {
+			return 0, err
+		}
+		if confirms > 0 {
+			w.tree.Confirm(pkh, confirms)
+		}
+	}
+
+	if err := w.ks.save(w.tree); err != nil {
+		return 0, err
+	}
+
+	return w.tree.Available(nil) - before, nil
+}
+
+// Moves n available nodes into a new wallet in the keystore ks, e.g. of
+// another device. The state of w is stored before the backup, so the moved
+// nodes can not be used by both wallets. Returns ErrKeystoreExists if ks
+// already holds a wallet, and the errors of xnyss.NYTree.Backup.
+func (w *Wallet) Backup(ks *Keystore, n int) (*Wallet, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := os.Stat(ks.path(stateFile)); err == nil {
+		return nil, ErrKeystoreExists
+	}
+
+	backup, err := w.tree.Backup(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.ks.save(w.tree); err != nil {
+		return nil, err
+	}
+	if err := ks.save(backup); err != nil {
+		return nil, err
+	}
+
+	return &Wallet{ks: ks, tree: backup, source: w.source}, nil
+}
+
+func (ks *Keystore) path(name string) string {
+	return filepath.Join(ks.dir, name)
+}
+
+func (ks *Keystore) save(tree *xnyss.NYTree) error {
+	state, err := tree.BytesEncryptedWith(ks.passphrase, ks.kdf)
+	if err != nil {
+		return err
+	}
+
+	return ks.write(stateFile, state)
+}
+
+func (ks *Keystore) load() (*xnyss.NYTree, error) {
+	state, err := os.ReadFile(ks.path(stateFile))
+	if os.IsNotExist(err) {
+		return nil, ErrKeystoreEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return xnyss.LoadEncrypted(state, ks.passphrase)
+}
+
+// Returns the journal entries in the keystore, and the names of their files.
+func (ks *Keystore) journal() (journal [][]byte, names []string, err error) {
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), journalPrefix) {
+			continue
+		}
+		b, err := os.ReadFile(ks.path(e.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		journal = append(journal, b)
+		names = append(names, e.Name())
+	}
+
+	return journal, names, nil
+}
+
+// Replaces the file name with b, syncing it to disk before it is renamed into
+// place.
+func (ks *Keystore) write(name string, b []byte) error {
+	f, err := os.CreateTemp(ks.dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), ks.path(name))
+}
@@ -0,0 +1,137 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+// A confirmation source for which every signature is confirmed once it is
+// mined.
+type chain struct {
+	mu    sync.Mutex
+	mined map[string]bool
+}
+
+func (c *chain) mine(sig *xnyss.Signature) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, pkh := range sig.ChildHashes {
+		c.mined[string(pkh)] = true
+	}
+}
+
+func (c *chain) Confirmations(ctx context.Context, pkh []byte) (uint8, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mined[string(pkh)] {
+		return xnyss.ConfirmsRequired, nil
+	}
+
+	return 0, nil
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// Fast key derivation parameters, so the test does not spend its time on
+// deriving keys.
+var fastKDF = xnyss.KDFParams{KDF: xnyss.KDFPBKDF2SHA256, Time: 1000}
+
+func TestWallet(t *testing.T) {
+	ctx := context.Background()
+	source := &chain{mined: make(map[string]bool)}
+	ks := NewKeystore(t.TempDir(), []byte("passphrase"))
+	ks.SetKDFParams(fastKDF)
+	msg := randomBytes(t, xnyss.MsgLen)
+
+	// 1 - A new wallet signs once with its root, then waits for confirmations
+	w, err := Create(ks, source, randomBytes(t, 32), randomBytes(t, 32))
+	if err != nil {
+		t.Fatal("Failed to create wallet -", err)
+	}
+	if _, err := Create(ks, source, randomBytes(t, 32), randomBytes(t, 32)); err != ErrKeystoreExists {
+		t.Fatal("Overwrote wallet, err was", err)
+	}
+	sig, err := w.Sign(msg, randomBytes(t, xnyss.TxidLen))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	chain := []*xnyss.Signature{sig}
+	if err := xnyss.VerifyChain(w.PublicKey(), chain, msg); err != nil {
+		t.Fatal("Failed to verify -", err)
+	}
+	if w.Available() != 0 {
+		t.Fatal("Unconfirmed nodes available")
+	}
+	source.mine(sig)
+	if n, err := w.Refresh(ctx); err != nil || n != len(sig.ChildHashes) {
+		t.Fatal("Failed to refresh -", err)
+	}
+
+	// 2 - The wallet survives being reopened
+	w, err = Open(ks, source)
+	if err != nil {
+		t.Fatal("Failed to open wallet -", err)
+	}
+	if w.Available() != len(sig.ChildHashes) {
+		t.Fatal("State was not stored")
+	}
+
+	// 3 - Interrupted signing sessions consume their node when reopened
+	p, err := w.tree.BeginSign(randomBytes(t, xnyss.TxidLen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.write(journalPrefix+"interrupted", p.Journal()); err != nil {
+		t.Fatal(err)
+	}
+	w, err = Open(ks, source)
+	if err != nil {
+		t.Fatal("Failed to open wallet -", err)
+	}
+	if w.Available() != len(sig.ChildHashes)-1 {
+		t.Fatal("Interrupted session was not recovered")
+	}
+	if journal, _, err := ks.journal(); err != nil || len(journal) != 0 {
+		t.Fatal("Journal was not cleared")
+	}
+
+	// 4 - Backups move nodes to another keystore
+	backupKs := NewKeystore(t.TempDir(), []byte("backup passphrase"))
+	backupKs.SetKDFParams(fastKDF)
+	backup, err := w.Backup(backupKs, 1)
+	if err != nil {
+		t.Fatal("Failed to back up -", err)
+	}
+	if backup.Available() != 1 || w.Available() != len(sig.ChildHashes)-2 {
+		t.Fatal("Invalid backup")
+	}
+	if backup, err = Open(backupKs, source); err != nil {
+		t.Fatal("Failed to open backup -", err)
+	}
+	sig, err = backup.Sign(msg, randomBytes(t, xnyss.TxidLen))
+	if err != nil {
+		t.Fatal("Failed to sign with backup -", err)
+	}
+	if !bytes.Equal(sig.Message, msg) {
+		t.Fatal("Invalid signature")
+	}
+
+	// 5 - Wrong passphrases are rejected
+	if _, err := Open(NewKeystore(ks.dir, []byte("wrong")), source); err != xnyss.ErrDecryptionFailed {
+		t.Fatal("Opened wallet with wrong passphrase, err was", err)
+	}
+}
@@ -0,0 +1,104 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+var (
+	ErrExpansionUnavailable = newError(ErrCapacity, "no node available that adds children when signing")
+)
+
+// The message signed by expansion signatures, see Expand. Verifiers can
+// recognise expansion signatures by it, since they authorise no transaction.
+var ExpansionMessage = func() []byte {
+	msg := sha256.Sum256([]byte("xnyss expansion"))
+	return msg[:]
+}()
+
+// Controls the expansion signatures created by Expand(0).
+type ExpansionPolicy struct {
+	// Expand when fewer confirmed nodes than Threshold can sign, see
+	// Available. Zero disables the policy.
+	Threshold int
+	// The amount of expansion signatures to create, at least one
+	Count int
+}
+
+// Sets the policy used by Expand(0). The policy is not persisted.
+func (t *NYTree) SetExpansionPolicy(p ExpansionPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expansion = p
+}
+
+// Creates n expansion signatures, which sign ExpansionMessage instead of a
+// transaction purely to add child nodes to the tree: every signature consumes
+// one node and adds the children of a regular signature. This lets wallets
+// that sign rarely, but in bursts, grow the number of nodes ahead of time.
+// If n is zero, the expansion policy of t decides whether and how many
+// signatures are created, see SetExpansionPolicy.
+//
+// Like any other signature, the wallet must broadcast or anchor the returned
+// signatures, and confirm their children using Confirm once they are. Every
+// signature is created for its own txid, derived from ExpansionMessage, so
+// that the children of one expansion are not used before they are confirmed.
+// Only nodes that add children when signing are used. If not all signatures
+// can be created, the created signatures are returned along with the error,
+// which is ErrExpansionUnavailable if no more nodes could be used.
+func (t *NYTree) Expand(n int) (sigs []*Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	if n == 0 && t.expansion.Threshold > 0 && t.capacity() < t.expansion.Threshold {
+		n = t.expansion.Count
+		if n < 1 {
+			n = 1
+		}
+	}
+	if n > 0 && (t.ots || t.frozen) {
+		return nil, ErrExpansionUnavailable
+	}
+
+	opts := &signOptions{
+		filter: func(node *nyNode) bool { return !t.atMaxDepth(node) },
+	}
+	for len(sigs) < n {
+		txid := t.expansionTxid()
+		if t.getSignNode(txid, opts.filter) < 0 {
+			err = ErrExpansionUnavailable
+			break
+		}
+
+		var sig *Signature
+		profile(OpSign, t, func() {
+			sig, err = t.sign(ExpansionMessage, txid, opts)
+		})
+		if err != nil {
+			break
+		}
+		sigs = append(sigs, sig)
+	}
+	if len(sigs) > 0 {
+		t.log(LevelAudit, "tree expanded", "signatures", len(sigs))
+	}
+
+	return sigs, err
+}
+
+// Returns the txid of the next expansion signature, which is unique to the
+// state of t.
+func (t *NYTree) expansionTxid() []byte {
+	var epoch [8]byte
+	binary.BigEndian.PutUint64(epoch[:], t.epoch)
+
+	h := sha256.New()
+	h.Write(ExpansionMessage)
+	h.Write(epoch[:])
+
+	return h.Sum(nil)[:TxidLen]
+}
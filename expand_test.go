@@ -0,0 +1,63 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_Expand(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Expansion signatures sign the expansion message and add children
+	sigs, err := tree.Expand(1)
+	if err != nil || len(sigs) != 1 {
+		t.Fatal("Failed to expand -", err)
+	}
+	if ok, err := Verify(mustPublicKey(t, sigs[0]), sigs[0], ExpansionMessage); !ok || err != nil {
+		t.Fatal("Expansion signature does not sign the expansion message")
+	}
+	if len(tree.Unconfirmed()) != Branches {
+		t.Fatal("Expansion did not add children")
+	}
+
+	// 2 - Unconfirmed children of an expansion are not used by the next one
+	if _, err := tree.Expand(1); err != ErrExpansionUnavailable {
+		t.Fatal("Expanded using unconfirmed nodes, err was", err)
+	}
+	for _, pkh := range sigs[0].ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if sigs, err = tree.Expand(2); err != nil || len(sigs) != 2 {
+		t.Fatal("Failed to expand -", err)
+	}
+	if tree.Available(nil) != Branches-2 || len(tree.Unconfirmed()) != 2*Branches {
+		t.Fatal("Invalid node counts after expansion")
+	}
+
+	// 3 - The policy expands only below its threshold
+	tree.SetExpansionPolicy(ExpansionPolicy{Threshold: Branches - 2, Count: 1})
+	if sigs, err = tree.Expand(0); err != nil || len(sigs) != 0 {
+		t.Fatal("Expanded above the threshold, err was", err)
+	}
+	tree.SetExpansionPolicy(ExpansionPolicy{Threshold: Branches, Count: 1})
+	if sigs, err = tree.Expand(0); err != nil || len(sigs) != 1 {
+		t.Fatal("Failed to expand below the threshold -", err)
+	}
+
+	// 4 - Nodes at the maximum depth are not used
+	tree = New(seed, pubSeed, false)
+	tree.SetMaxDepth(1)
+	sigs, err = tree.Expand(1)
+	if err != nil {
+		t.Fatal("Failed to expand -", err)
+	}
+	for _, pkh := range sigs[0].ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if _, err := tree.Expand(1); err != ErrExpansionUnavailable {
+		t.Fatal("Expanded using nodes at the maximum depth, err was", err)
+	}
+}
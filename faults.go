@@ -0,0 +1,79 @@
+package xnyss
+
+import (
+	"io"
+)
+
+// Failure modes injected into trees, for testing how applications handle
+// errors of the tree. Nil functions inject nothing. This is meant for tests
+// only: see InjectFaults.
+type FaultInjection struct {
+	// Called whenever a tree is persisted through GobEncode, MarshalBinary,
+	// WriteTo or TreeState.Value, or writes to its storage (see SetStorage); a
+	// non-nil error is returned instead of the state.
+	Persist func() error
+	// Called whenever entropy is read to create child nodes; a non-nil error
+	// makes Sign fail as if the random source failed.
+	Entropy func() error
+	// Called before every WOTS+ key or signature computation, e.g. to delay
+	// hashing and widen race windows.
+	Hash func()
+	// Called with the WOTS+ signature computed by a node before it is
+	// returned, e.g. to flip bits like a faulty computation would, see
+	// NYTree.SetVerifyAfterSign.
+	Sign func(sigBytes []byte)
+}
+
+var faults *FaultInjection
+
+// Injects the faults in f into all trees, until restore is called. Faults are
+// global and not synchronised, so tests using them must not run in parallel
+// with other tests that use trees.
+func InjectFaults(f *FaultInjection) (restore func()) {
+	previous := faults
+	faults = f
+
+	return func() { faults = previous }
+}
+
+func injectPersistFault() error {
+	if faults == nil || faults.Persist == nil {
+		return nil
+	}
+
+	return faults.Persist()
+}
+
+func injectHashFault() {
+	if faults != nil && faults.Hash != nil {
+		faults.Hash()
+	}
+}
+
+func injectSignFault(sigBytes []byte) {
+	if faults != nil && faults.Sign != nil {
+		faults.Sign(sigBytes)
+	}
+}
+
+// Wraps r so reads fail with the error of the entropy fault, if injected.
+func injectEntropyFault(r io.Reader) io.Reader {
+	if faults == nil || faults.Entropy == nil {
+		return r
+	}
+
+	return faultyReader{r: r, fault: faults.Entropy}
+}
+
+type faultyReader struct {
+	r     io.Reader
+	fault func() error
+}
+
+func (f faultyReader) Read(p []byte) (int, error) {
+	if err := f.fault(); err != nil {
+		return 0, err
+	}
+
+	return f.r.Read(p)
+}
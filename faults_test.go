@@ -0,0 +1,49 @@
+package xnyss
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestInjectFaults(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	errFault := errors.New("injected fault")
+
+	hashes := 0
+	restore := InjectFaults(&FaultInjection{
+		Persist: func() error { return errFault },
+		Entropy: func() error { return errFault },
+		Hash:    func() { hashes++ },
+	})
+
+	// 1 - Injected faults surface as errors, without changing the tree
+	state := tree.Bytes()
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err == nil {
+		t.Fatal("Signed with failing entropy source")
+	}
+	if string(tree.Bytes()) != string(state) {
+		t.Fatal("Failed signature changed the tree")
+	}
+	if _, err := tree.GobEncode(); err != errFault {
+		t.Fatal("Persisted despite fault, err was", err)
+	}
+	if _, err := (TreeState{Tree: tree}).Value(); err != errFault {
+		t.Fatal("Persisted despite fault, err was", err)
+	}
+
+	if hashes == 0 {
+		t.Fatal("Hash fault was not called")
+	}
+
+	// 2 - Restoring removes the faults
+	restore()
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal("Failed to sign after restoring -", err)
+	}
+}
@@ -0,0 +1,189 @@
+package xnyss
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	ErrTreeFenced     = newError(ErrState, "tree state is fenced off: lease is not held")
+	ErrFenceHeld      = newError(ErrState, "lease is held by another owner")
+	ErrFenceNotHolder = newError(ErrState, "lease is not held by this owner")
+)
+
+// A Fence grants exclusive use of a tree's state to a single signer, so that
+// replicas in a high-availability deployment never sign with the same state.
+// Implementations are typically backed by a lease in an external lock service
+// such as etcd or a database. Leases are keyed by the tree's ID.
+type Fence interface {
+	// Acquires the lease for key, returning an error if it is held elsewhere.
+	Acquire(key string) error
+	// Extends a lease that was previously acquired.
+	Renew(key string) error
+	// Releases the lease, allowing other signers to acquire it.
+	Release(key string) error
+	// Returns nil if and only if the lease for key is currently held. It is
+	// called before every signature, so it should not block for long.
+	Check(key string) error
+}
+
+// Acquires the lease for t from the given fence. Once a fence is set, Sign checks
+// that the lease is still held before consuming a node, and returns
+// ErrTreeFenced otherwise. Renewing the lease is up to the caller, see
+// RenewFence.
+func (t *NYTree) SetFence(f Fence) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := f.Acquire(t.ID()); err != nil {
+		return err
+	}
+
+	t.fence = f
+	return nil
+}
+
+// Renews the lease of the fence set on t.
+func (t *NYTree) RenewFence() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fence == nil {
+		return nil
+	}
+
+	return t.fence.Renew(t.ID())
+}
+
+// Releases the lease of the fence set on t and removes the fence. Note that t
+// can be used to sign again afterwards: the caller must make sure the state is
+// handed over before another signer acquires the lease.
+func (t *NYTree) ReleaseFence() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.fence == nil {
+		return nil
+	}
+
+	if err := t.fence.Release(t.ID()); err != nil {
+		return err
+	}
+
+	t.fence = nil
+	return nil
+}
+
+// Returns ErrTreeFenced if a fence is set on t and its lease is not held.
+func (t *NYTree) checkFence() error {
+	if t.fence == nil {
+		return nil
+	}
+
+	if t.fence.Check(t.ID()) != nil {
+		return ErrTreeFenced
+	}
+
+	return nil
+}
+
+// An in-process Fence implementation, in which leases expire after a fixed
+// duration unless renewed. A LeaseTable can be shared between owners using
+// Owner, which is mostly useful for tests and for multiple signers in the same
+// process.
+type LeaseTable struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu     sync.Mutex
+	leases map[string]lease
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+// Creates a table in which leases expire after ttl.
+func NewLeaseTable(ttl time.Duration) *LeaseTable {
+	return &LeaseTable{
+		ttl:    ttl,
+		leases: make(map[string]lease),
+	}
+}
+
+// Sets the clock used by the table to expire leases, or SystemClock if c is
+// nil. Must be called before the table is used.
+func (lt *LeaseTable) SetClock(c Clock) {
+	lt.clock = c
+}
+
+// Returns the current time according to the clock of lt.
+func (lt *LeaseTable) now() time.Time {
+	if lt.clock == nil {
+		return SystemClock.Now()
+	}
+
+	return lt.clock.Now()
+}
+
+// Returns a Fence that acquires leases in the table on behalf of owner.
+func (lt *LeaseTable) Owner(owner string) Fence {
+	return &leaseOwner{table: lt, owner: owner}
+}
+
+type leaseOwner struct {
+	table *LeaseTable
+	owner string
+}
+
+func (o *leaseOwner) Acquire(key string) error {
+	o.table.mu.Lock()
+	defer o.table.mu.Unlock()
+
+	now := o.table.now()
+	if l, ok := o.table.leases[key]; ok && l.owner != o.owner && now.Before(l.expires) {
+		return ErrFenceHeld
+	}
+
+	o.table.leases[key] = lease{owner: o.owner, expires: now.Add(o.table.ttl)}
+	return nil
+}
+
+func (o *leaseOwner) Renew(key string) error {
+	o.table.mu.Lock()
+	defer o.table.mu.Unlock()
+
+	now := o.table.now()
+	l, ok := o.table.leases[key]
+	if !ok || l.owner != o.owner || !now.Before(l.expires) {
+		return ErrFenceNotHolder
+	}
+
+	o.table.leases[key] = lease{owner: o.owner, expires: now.Add(o.table.ttl)}
+	return nil
+}
+
+func (o *leaseOwner) Release(key string) error {
+	o.table.mu.Lock()
+	defer o.table.mu.Unlock()
+
+	if l, ok := o.table.leases[key]; !ok || l.owner != o.owner {
+		return ErrFenceNotHolder
+	}
+
+	delete(o.table.leases, key)
+	return nil
+}
+
+func (o *leaseOwner) Check(key string) error {
+	o.table.mu.Lock()
+	defer o.table.mu.Unlock()
+
+	l, ok := o.table.leases[key]
+	if !ok || l.owner != o.owner || !o.table.now().Before(l.expires) {
+		return ErrFenceNotHolder
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNYTree_SetFence(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leases := NewLeaseTable(time.Hour)
+
+	primary := New(seed, pubSeed, false)
+	standby, err := Load(primary.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load standby -", err)
+	}
+
+	// 1 - Only one of the replicas can acquire the lease
+	if err := primary.SetFence(leases.Owner("primary")); err != nil {
+		t.Fatal("Primary failed to acquire lease -", err)
+	}
+	if err := standby.SetFence(leases.Owner("standby")); err != ErrFenceHeld {
+		t.Fatal("Standby should not acquire the lease, err was", err)
+	}
+
+	sig, _, err := signMessage("first signature test", primary)
+	if err != nil {
+		t.Fatal("Primary failed to sign -", err)
+	}
+	if err := primary.RenewFence(); err != nil {
+		t.Fatal("Primary failed to renew lease -", err)
+	}
+
+	// 2 - After releasing, the standby takes over and the primary is fenced
+	fence := primary.fence
+	if err := primary.ReleaseFence(); err != nil {
+		t.Fatal("Primary failed to release lease -", err)
+	}
+	if err := standby.SetFence(leases.Owner("standby")); err != nil {
+		t.Fatal("Standby failed to acquire lease -", err)
+	}
+
+	primary.fence = fence
+	primary.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if _, _, err := signMessage("second signature test", primary); err != ErrTreeFenced {
+		t.Fatal("Fenced primary should not sign, err was", err)
+	}
+	if primary.Available(nil) != 1 {
+		t.Fatal("Fenced signature consumed a node")
+	}
+}
+
+func TestLeaseTable_Expiry(t *testing.T) {
+	leases := NewLeaseTable(time.Millisecond)
+	a, b := leases.Owner("a"), leases.Owner("b")
+
+	if err := a.Acquire("key"); err != nil {
+		t.Fatal("Failed to acquire lease -", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if err := a.Check("key"); err != ErrFenceNotHolder {
+		t.Fatal("Expired lease is still held, err was", err)
+	}
+	if err := a.Renew("key"); err != ErrFenceNotHolder {
+		t.Fatal("Expired lease was renewed, err was", err)
+	}
+	if err := b.Acquire("key"); err != nil {
+		t.Fatal("Failed to acquire expired lease -", err)
+	}
+}
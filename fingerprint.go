@@ -0,0 +1,49 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"io"
+)
+
+// Domain separation prefix of fingerprints.
+const fingerprintDomain = "xnyss fingerprint"
+
+// Returns the fingerprint of the state of the tree t: the SHA-256 digest of
+// "xnyss fingerprint" followed by its serialised state without checksum (see
+// Bytes). The serialised state is canonical, with nodes in ascending order of
+// their public key hash and the fields of the header in a fixed order, so two
+// devices holding the same state of a tree compute the same fingerprint, and
+// any divergence, e.g. one device having consumed a node the other still
+// holds, changes it. The fingerprint reveals nothing about the seeds. Returns
+// the zero fingerprint if t was wiped.
+func (t *NYTree) Fingerprint() [32]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return [32]byte{}
+	}
+
+	return t.fingerprint()
+}
+
+func (t *NYTree) fingerprint() (fp [32]byte) {
+	h := sha256.New()
+	h.Write([]byte(fingerprintDomain))
+	t.writeBody(&stateWriter{w: io.Discard, h: h})
+	h.Sum(fp[:0])
+
+	return
+}
+
+// Returns the fingerprint of the serialised state b, see NYTree.Fingerprint.
+// States in the legacy format, or written by versions of this package that
+// ordered nodes differently, have the fingerprint of their canonical encoding.
+func StateFingerprint(b []byte) ([32]byte, error) {
+	tree, err := Load(b)
+	if err != nil {
+		return [32]byte{}, err
+	}
+
+	return tree.fingerprint(), nil
+}
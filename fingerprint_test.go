@@ -0,0 +1,46 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_Fingerprint(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("fingerprint test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 1 - Devices holding the same state agree
+	device, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Fingerprint() != tree.Fingerprint() {
+		t.Fatal("Fingerprints of the same state differ")
+	}
+	if fp, err := StateFingerprint(tree.Bytes()); err != nil || fp != tree.Fingerprint() {
+		t.Fatal("Fingerprint of the serialised state differs -", err)
+	}
+
+	// 2 - Diverging states are detected
+	if _, _, err := signMessage("fingerprint test", device); err != nil {
+		t.Fatal(err)
+	}
+	if device.Fingerprint() == tree.Fingerprint() {
+		t.Fatal("Fingerprints of diverged states are equal")
+	}
+
+	// 3 - Wiped trees have no fingerprint
+	device.Wipe()
+	if device.Fingerprint() != ([32]byte{}) {
+		t.Fatal("Wiped tree has a fingerprint")
+	}
+}
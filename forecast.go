@@ -0,0 +1,100 @@
+package xnyss
+
+import (
+	"bytes"
+	"sort"
+)
+
+// The projected signing capacity of a tree once pending confirmations land,
+// see NYTree.Forecast.
+type ForecastResult struct {
+	// The amount of signatures that can be created now, and once the
+	// confirmations landed, see Available
+	Available int
+	Projected int
+	// The amount of nodes that reach ConfirmsRequired through the
+	// confirmations
+	Unlocked int
+	// The transactions whose nodes still await confirmations afterwards, most
+	// nodes first
+	Bottlenecks []Bottleneck
+}
+
+// A transaction whose nodes hold back the signing capacity of a tree, see
+// ForecastResult.
+type Bottleneck struct {
+	Txid []byte
+	// The amount of nodes of the txid that can not sign yet, and the
+	// confirmations they still miss
+	Nodes   int
+	Missing uint8
+}
+
+// Predicts the signing capacity of the tree t once the confirmations in
+// pendingConfirms land, without changing t. pendingConfirms maps txids, as
+// strings, to the confirmation count their transactions are expected to reach,
+// like the count passed to Confirm for the nodes they created; txids are
+// hashed like for Sign if t uses txid hashing, see SetTxidHashing. Every
+// unconfirmed node is assumed to keep its current count unless its txid is
+// listed.
+//
+// The result holds the projected Available count, and the transactions that
+// still block capacity afterwards, e.g. for a wallet to tell which payments it
+// should wait for before signing again.
+func (t *NYTree) Forecast(pendingConfirms map[string]uint8) ForecastResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make(map[string]uint8, len(pendingConfirms))
+	for txid, confirms := range pendingConfirms {
+		pending[string(t.lookupTxid([]byte(txid)))] = boundConfirms(confirms)
+	}
+
+	result := ForecastResult{Available: t.available(nil)}
+
+	// The confirmations are applied to the nodes for as long as the tree is
+	// locked, so the projection uses the same rules as signing
+	saved := make(map[*nyNode]uint8)
+	for _, node := range t.nodes {
+		if confirms, ok := pending[string(node.txid)]; ok && node.confirms < confirms && !t.isRoot(node) {
+			saved[node] = node.confirms
+			if node.confirms < ConfirmsRequired && confirms >= ConfirmsRequired {
+				result.Unlocked++
+			}
+			t.setConfirms(node, confirms)
+		}
+	}
+	result.Projected = t.available(nil)
+
+	bottlenecks := make(map[string]*Bottleneck)
+	for _, node := range t.nodes {
+		if node.confirms >= ConfirmsRequired || t.isRoot(node) {
+			continue
+		}
+		b, ok := bottlenecks[string(node.txid)]
+		if !ok {
+			b = &Bottleneck{Txid: append([]byte(nil), node.txid...)}
+			bottlenecks[string(node.txid)] = b
+		}
+		b.Nodes++
+		if missing := ConfirmsRequired - node.confirms; missing > b.Missing {
+			b.Missing = missing
+		}
+	}
+	for node, confirms := range saved {
+		t.setConfirms(node, confirms)
+	}
+
+	for _, b := range bottlenecks {
+		result.Bottlenecks = append(result.Bottlenecks, *b)
+	}
+	sort.Slice(result.Bottlenecks, func(i, j int) bool {
+		a, b := result.Bottlenecks[i], result.Bottlenecks[j]
+		if a.Nodes != b.Nodes {
+			return a.Nodes > b.Nodes
+		}
+		return bytes.Compare(a.Txid, b.Txid) < 0
+	})
+
+	return result
+}
@@ -0,0 +1,75 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_Forecast(t *testing.T) {
+	required := ConfirmsRequired
+	ConfirmsRequired = 3
+	defer func() { ConfirmsRequired = required }()
+
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	first, second := Txid("forecast", []byte{1}), Txid("forecast", []byte{2})
+
+	// 1 - Fresh trees can sign once, and nothing blocks them
+	if f := tree.Forecast(nil); f.Available != 1 || f.Projected != 1 || len(f.Bottlenecks) != 0 {
+		t.Fatal("Invalid forecast of fresh tree", f)
+	}
+
+	sig, err := tree.Sign(testdata.Message, first)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if _, err := tree.Sign(testdata.Message, second); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	for _, node := range tree.NodesForTxid(first) {
+		tree.Confirm(node.PubKeyHash, 1)
+	}
+	epoch, available := tree.Epoch(), tree.Available(nil)
+
+	// 2 - Without pending confirmations, the nodes of both txids block the tree,
+	// most nodes first
+	f := tree.Forecast(nil)
+	if f.Available != available || f.Projected != available || f.Unlocked != 0 {
+		t.Fatal("Invalid forecast without confirmations", f)
+	}
+	if len(f.Bottlenecks) != 2 || !bytes.Equal(f.Bottlenecks[0].Txid, second) ||
+		f.Bottlenecks[0].Nodes != Branches || f.Bottlenecks[0].Missing != ConfirmsRequired ||
+		!bytes.Equal(f.Bottlenecks[1].Txid, first) || f.Bottlenecks[1].Nodes != Branches-1 ||
+		f.Bottlenecks[1].Missing != ConfirmsRequired-1 {
+		t.Fatal("Invalid bottlenecks", f.Bottlenecks)
+	}
+
+	// 3 - Confirmations of a txid unlock its nodes, without changing the tree
+	f = tree.Forecast(map[string]uint8{string(first): ConfirmsRequired})
+	if f.Available != available || f.Projected != available+Branches-1 || f.Unlocked != Branches-1 {
+		t.Fatal("Invalid forecast with confirmations", f)
+	}
+	if len(f.Bottlenecks) != 1 || !bytes.Equal(f.Bottlenecks[0].Txid, second) {
+		t.Fatal("Invalid bottlenecks", f.Bottlenecks)
+	}
+	if tree.Epoch() != epoch || tree.Available(nil) != available {
+		t.Fatal("Forecast changed the tree")
+	}
+
+	// 4 - Too few confirmations leave the txid blocking, with fewer missing
+	f = tree.Forecast(map[string]uint8{string(second): ConfirmsRequired - 1})
+	if f.Projected != available || f.Unlocked != 0 || len(f.Bottlenecks) != 2 {
+		t.Fatal("Invalid forecast with partial confirmations", f)
+	}
+	for _, b := range f.Bottlenecks {
+		if b.Missing != 1 && bytes.Equal(b.Txid, second) {
+			t.Fatal("Invalid missing confirmations", b.Missing)
+		}
+	}
+}
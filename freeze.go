@@ -0,0 +1,228 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+)
+
+var (
+	ErrFreezeListInvalid = newError(ErrCrypto, "freeze list is not signed by the long-term key")
+	ErrInvalidFreezeList = newError(ErrEncoding, "invalid freeze list encoding")
+	ErrChainFrozen       = newError(ErrCrypto, "signature chain passes through a frozen node")
+)
+
+// Version byte of an encoded freeze list.
+const freezeListVersion = 0x01
+
+// Tags of the fields of an encoded freeze list. Links are encoded as a message
+// field followed by an envelope field.
+const (
+	freezeListFieldNode      = 0x01
+	freezeListFieldSignature = 0x02
+	freezeListFieldMessage   = 0x03
+	freezeListFieldLink      = 0x04
+)
+
+// A statement by the holder of a long-term key that the nodes with the public
+// key hashes Frozen, and all their descendants, must no longer be accepted,
+// e.g. because they were delegated to a device that is suspected to be
+// compromised. See FreezeBranches.
+type FreezeList struct {
+	// The public key hashes of the frozen nodes, sorted
+	Frozen [][]byte
+	// The signature of FreezeListMessage by the tree
+	Signature *Signature
+	// The signatures linking the root to the node that created Signature, see
+	// Bundle
+	Links []*Signature
+}
+
+// Returns the message signed to freeze the nodes with the public key hashes
+// frozen, in ascending order, of the long-term key rootPubKey. It is also the
+// txid of the signature, see FreezeBranches.
+func FreezeListMessage(rootPubKey []byte, frozen [][]byte) []byte {
+	var count [4]byte
+	binary.BigEndian.PutUint32(count[:], uint32(len(frozen)))
+
+	h := sha256.New()
+	h.Write([]byte("xnyss freeze list"))
+	h.Write(rootPubKey)
+	h.Write(count[:])
+	for _, pkh := range frozen {
+		h.Write(pkh)
+	}
+
+	return h.Sum(nil)
+}
+
+// Freezes the nodes of the tree t with the public key hashes pkhs, and the
+// branches below them, returning a freeze list signed by t that verifiers use
+// to reject signatures from those branches, see FreezeList.CheckChain. This
+// responds to a device that holds nodes split off by Backup being suspected
+// compromised: the nodes of the device, and any nodes it created since, can no
+// longer sign signatures that verifiers accept.
+//
+// Frozen nodes that t holds are marked consumed, like MarkConsumed does, before
+// the freeze list is signed. Freeze lists are independent of each other, so
+// verifiers must apply every list they receive; the frozen nodes are also
+// included in the next revocation list, see RevokeNodes. Like Attest, the
+// freeze list includes the signatures linking the root of t to the signing
+// node, and returns ErrBundleIncomplete if they are not available. Returns
+// ErrFrontierInvalid if a hash does not hold 32 bytes.
+func (t *NYTree) FreezeBranches(pkhs [][]byte) (*FreezeList, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+
+	fl := &FreezeList{}
+	seen := make(map[[32]byte]bool, len(pkhs))
+	for _, pkh := range pkhs {
+		if len(pkh) != 32 {
+			return nil, ErrFrontierInvalid
+		}
+		var key [32]byte
+		copy(key[:], pkh)
+		if !seen[key] {
+			seen[key] = true
+			fl.Frozen = append(fl.Frozen, key[:])
+		}
+	}
+	sort.Slice(fl.Frozen, func(i, j int) bool {
+		return bytes.Compare(fl.Frozen[i], fl.Frozen[j]) < 0
+	})
+
+	t.markConsumed(fl.Frozen)
+	t.addRevokedNodes(fl.Frozen)
+	t.log(LevelAudit, "branches frozen", "nodes", len(fl.Frozen))
+
+	msg := FreezeListMessage(t.publicKey(), fl.Frozen)
+	sig, bundle, err := t.signBundle(msg, msg[:TxidLen], nil)
+	if err != nil {
+		return nil, err
+	}
+	fl.Signature, fl.Links = sig, bundle.Links
+
+	return fl, nil
+}
+
+// Verifies that fl is a freeze list of the long-term key rootPubKey. Returns
+// ErrFreezeListInvalid if fl signs another message, and the errors of
+// VerifyChain if its signatures do not verify.
+func VerifyFreezeList(rootPubKey []byte, fl *FreezeList) error {
+	if fl == nil || fl.Signature == nil {
+		return ErrFreezeListInvalid
+	}
+
+	msg := FreezeListMessage(rootPubKey, fl.Frozen)
+	if !bytes.Equal(fl.Signature.Message, msg) {
+		return ErrFreezeListInvalid
+	}
+
+	return (&Bundle{Links: fl.Links}).Verify(rootPubKey, nil, fl.Signature, msg)
+}
+
+// Returns whether the node with public key hash pkh is frozen by fl. Verifiers
+// that track the frontier of a key (see VerifyWithFrontier) must also drop the
+// child hashes of frozen nodes that signed before the freeze.
+func (fl *FreezeList) Contains(pkh []byte) bool {
+	i := sort.Search(len(fl.Frozen), func(i int) bool {
+		return bytes.Compare(fl.Frozen[i], pkh) >= 0
+	})
+
+	return i < len(fl.Frozen) && bytes.Equal(fl.Frozen[i], pkh)
+}
+
+// Returns ErrChainFrozen if a signature of chain was created by a node frozen
+// by fl, i.e. if chain passes through a frozen branch. The root is never
+// frozen. CheckChain does not verify the chain, see VerifyChain, but computes
+// the public key of every signature after the first.
+func (fl *FreezeList) CheckChain(chain []*Signature) error {
+	for i, sig := range chain {
+		if i == 0 || sig == nil {
+			continue
+		}
+
+		pk, err := sig.PublicKey()
+		if err != nil {
+			return err
+		}
+		if fl.Contains(sig.suite.sum(pk)) {
+			return ErrChainFrozen
+		}
+	}
+
+	return nil
+}
+
+// Returns the encoding of the freeze list fl, which holds the envelopes of its
+// signatures (see Signature.Envelope).
+func (fl *FreezeList) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(freezeListVersion)
+	for _, pkh := range fl.Frozen {
+		writeField(buf, freezeListFieldNode, pkh)
+	}
+	writeField(buf, freezeListFieldSignature, fl.Signature.Envelope())
+	for _, link := range fl.Links {
+		writeField(buf, freezeListFieldMessage, link.Message)
+		writeField(buf, freezeListFieldLink, link.Envelope())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a freeze list encoded by FreezeList.Bytes of the long-term key
+// rootPubKey. The freeze list still needs to be verified, see
+// VerifyFreezeList.
+func ParseFreezeList(rootPubKey, b []byte) (*FreezeList, error) {
+	if len(b) < 1 || b[0] != freezeListVersion {
+		return nil, ErrInvalidFreezeList
+	}
+
+	fl := &FreezeList{}
+	var sig, msg []byte
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case freezeListFieldNode:
+			if len(value) != 32 || (len(fl.Frozen) > 0 && bytes.Compare(fl.Frozen[len(fl.Frozen)-1], value) >= 0) {
+				return ErrInvalidFreezeList
+			}
+			fl.Frozen = append(fl.Frozen, append([]byte(nil), value...))
+		case freezeListFieldSignature:
+			sig = value
+		case freezeListFieldMessage:
+			msg = value
+		case freezeListFieldLink:
+			if msg == nil {
+				return ErrInvalidFreezeList
+			}
+			link, err := ParseEnvelope(value, msg)
+			if err != nil {
+				return err
+			}
+			fl.Links = append(fl.Links, link)
+			msg = nil
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sig == nil {
+		return nil, ErrInvalidFreezeList
+	}
+
+	if fl.Signature, err = ParseEnvelope(sig, FreezeListMessage(rootPubKey, fl.Frozen)); err != nil {
+		return nil, err
+	}
+
+	return fl, nil
+}
@@ -0,0 +1,78 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNYTree_FreezeBranches(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	rootSig, _, err := signMessage("freeze test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range rootSig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// Delegate a node to a device, which signs with it
+	device, err := tree.Backup(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated := device.Nodes()[0].PubKeyHash
+	msg := sha256.Sum256([]byte("device message"))
+	deviceSig, err := device.Sign(msg[:], msg[:TxidLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Signature{rootSig, deviceSig}
+	if err := VerifyChain(pk, chain, msg[:]); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Freeze lists verify against the long-term key, and reject chains
+	// through frozen nodes
+	fl, err := tree.FreezeBranches([][]byte{delegated, delegated})
+	if err != nil {
+		t.Fatal("Failed to freeze branches -", err)
+	}
+	if err := VerifyFreezeList(pk, fl); err != nil {
+		t.Fatal("Failed to verify freeze list -", err)
+	}
+	if len(fl.Frozen) != 1 || !fl.Contains(delegated) {
+		t.Fatal("Invalid frozen nodes", fl.Frozen)
+	}
+	if err := fl.CheckChain(chain); err != ErrChainFrozen {
+		t.Fatal("Accepted chain through frozen node, err was", err)
+	}
+
+	// 2 - Chains through other nodes are accepted, like the one of the freeze
+	// list itself
+	if err := fl.CheckChain(append(fl.Links, fl.Signature)); err != nil {
+		t.Fatal("Rejected chain through unfrozen node -", err)
+	}
+
+	// 3 - Freeze lists survive encoding, and do not verify for other nodes
+	parsed, err := ParseFreezeList(pk, fl.Bytes())
+	if err != nil {
+		t.Fatal("Failed to parse freeze list -", err)
+	}
+	if err := VerifyFreezeList(pk, parsed); err != nil {
+		t.Fatal("Failed to verify parsed freeze list -", err)
+	}
+	parsed.Frozen = nil
+	if err := VerifyFreezeList(pk, parsed); err != ErrFreezeListInvalid {
+		t.Fatal("Verified freeze list for other nodes, err was", err)
+	}
+
+	// 4 - Hashes of invalid length are rejected
+	if _, err := tree.FreezeBranches([][]byte{delegated[:16]}); err != ErrFrontierInvalid {
+		t.Fatal("Froze invalid hash, err was", err)
+	}
+}
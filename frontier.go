@@ -0,0 +1,91 @@
+package xnyss
+
+// Describes the nodes of a tree that was reconstructed by NewFromFrontier. All
+// entries are public key hashes.
+type FrontierReport struct {
+	// Nodes that were reconstructed and are safe to use: they were committed to
+	// by a known signature, and none of their own children are known.
+	Usable [][]byte
+	// Nodes that already created a signature, since their children are known.
+	Consumed [][]byte
+	// Known child hashes that could not be derived from the root seeds. These
+	// were not created by a deterministic tree with these seeds and branching
+	// factor, or the known child hashes are incomplete.
+	Unknown [][]byte
+	// Whether the root node did not sign any of the known signatures. The root
+	// is then included in the tree, but locked (see UnlockRoot).
+	RootUnused bool
+}
+
+// Reconstructs a deterministic tree (see NewDeterministic) from its root seeds
+// and the child hashes of all its known signatures, e.g. collected from the
+// blockchain. The tree is derived starting at the root: a node whose children
+// appear in knownChildHashes has been used, while a node that appears in
+// knownChildHashes but whose children do not is added to the tree as confirmed.
+//
+// Recovery relies on knownChildHashes being complete: a node that signed a
+// transaction which is not included is reported as usable. The tree must have
+// been created with the current value of Branches.
+func NewFromFrontier(rootSeed, rootPubSeed []byte, knownChildHashes [][]byte) (*NYTree, *FrontierReport) {
+	tree := NewDeterministic(rootSeed, rootPubSeed, false)
+	report := &FrontierReport{}
+
+	known := make(map[[32]byte]bool, len(knownChildHashes))
+	for _, pkh := range knownChildHashes {
+		var key [32]byte
+		copy(key[:], pkh)
+		known[key] = true
+	}
+	found := make(map[[32]byte]bool, len(known))
+
+	txid := make([]byte, 32)
+	queue := tree.nodes
+	tree.nodes = make([]*nyNode, 0, len(knownChildHashes))
+	tree.consumed = make(map[[32]byte]bool)
+	tree.consumedSeeds = make(map[[32]byte]bool)
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		// A derivation stream never fails to produce entropy
+		children, _ := node.childNodes(txid, tree.branchesAt(node.depth), node.entropy(true))
+
+		used := false
+		for _, child := range children {
+			var key [32]byte
+			copy(key[:], child.pubKeyHash())
+			if known[key] {
+				found[key] = true
+				used = true
+			}
+		}
+
+		var pkh [32]byte
+		copy(pkh[:], node.pubKeyHash())
+
+		switch {
+		case used:
+			tree.consumed[pkh] = true
+			tree.consumedSeeds[node.seedDigest()] = true
+			report.Consumed = append(report.Consumed, pkh[:])
+			queue = append(queue, children...)
+		case tree.isRoot(node):
+			tree.rootLocked = true
+			tree.nodes = append(tree.nodes, node)
+			report.RootUnused = true
+		default:
+			node.confirms = ConfirmsRequired
+			tree.nodes = append(tree.nodes, node)
+			report.Usable = append(report.Usable, pkh[:])
+		}
+	}
+
+	for key := range known {
+		if !found[key] {
+			report.Unknown = append(report.Unknown, append([]byte(nil), key[:]...))
+		}
+	}
+
+	return tree, report
+}
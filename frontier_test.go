@@ -0,0 +1,59 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNewFromFrontier(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewDeterministic(seed, pubSeed, false)
+
+	// 1 - An unused tree recovers with only its (locked) root
+	_, report := NewFromFrontier(seed, pubSeed, nil)
+	if !report.RootUnused || len(report.Usable) != 0 {
+		t.Fatal("Invalid recovery of unused tree")
+	}
+
+	// 2 - Sign a few messages, publishing the child hashes
+	var known [][]byte
+	for i := 0; i < 3; i++ {
+		sig, _, err := signMessage("frontier test", tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		known = append(known, sig.ChildHashes...)
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+
+	recovered, report := NewFromFrontier(seed, pubSeed, known)
+	if report.RootUnused || len(report.Unknown) != 0 {
+		t.Fatal("Invalid recovery report")
+	}
+	if len(report.Consumed) != 3 || len(report.Usable) != len(tree.nodes) {
+		t.Fatal("Recovered", len(report.Usable), "usable nodes, should be", len(tree.nodes))
+	}
+
+	// 3 - The recovered nodes are exactly the nodes of the original tree
+	for _, node := range tree.nodes {
+		match := false
+		for _, pkh := range report.Usable {
+			match = match || bytes.Equal(node.pubKeyHash(), pkh)
+		}
+		if !match {
+			t.Fatal("Node of the original tree was not recovered")
+		}
+	}
+	if recovered.Available(nil) != len(tree.nodes) {
+		t.Fatal("Recovered nodes are not available")
+	}
+
+	// 4 - Child hashes from another tree are reported as unknown
+	_, report = NewFromFrontier(pubSeed, seed, known)
+	if len(report.Unknown) != len(known) {
+		t.Fatal("Foreign child hashes were not reported as unknown")
+	}
+}
@@ -0,0 +1,52 @@
+package xnyss
+
+var (
+	ErrTreeNotDeterministic = newError(ErrState, "tree does not derive child nodes deterministically")
+)
+
+// Returns the public key hashes of up to count future nodes of the
+// deterministic tree t, without consuming any node: the descendants of the
+// current nodes that are depth generations below them (1 for their children),
+// so they can be registered ahead of time with protocols that require it.
+// Descendants are listed per current node, in the order nodes are considered
+// for signing, and in derivation order below that.
+//
+// The keys are derived from the current branching schedule, so they are only
+// created if it is not changed. Returns ErrTreeNotDeterministic if the children
+// of t are random, and ErrTreeNotLongTerm for one-time trees, which create no
+// children.
+func (t *NYTree) ExportFutureKeys(depth, count int) ([][]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.deterministic {
+		return nil, ErrTreeNotDeterministic
+	}
+	if t.ots {
+		return nil, ErrTreeNotLongTerm
+	}
+
+	pkhashes := make([][]byte, 0, count)
+	var descend func(node *nyNode, generations int)
+	descend = func(node *nyNode, generations int) {
+		if len(pkhashes) >= count {
+			return
+		}
+		if generations == 0 {
+			pkhashes = append(pkhashes, append([]byte(nil), node.pubKeyHash()...))
+			return
+		}
+
+		// A derivation stream never fails to produce entropy
+		children, _ := node.childNodes(node.txid, t.branchesAt(node.depth), node.entropy(true))
+		for _, child := range children {
+			descend(child, generations-1)
+		}
+	}
+
+	for _, node := range t.nodes {
+		descend(node, depth)
+	}
+
+	return pkhashes, nil
+}
@@ -0,0 +1,61 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_ExportFutureKeys(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewDeterministic(seed, pubSeed, false)
+
+	// 1 - Keys two generations ahead, without consuming the root
+	future, err := tree.ExportFutureKeys(2, Branches*Branches)
+	if err != nil {
+		t.Fatal("Failed to export future keys -", err)
+	}
+	if len(future) != Branches*Branches || tree.Available(nil) != 1 {
+		t.Fatal("Exported", len(future), "keys, expected", Branches*Branches)
+	}
+	registered := make(map[string]bool)
+	for _, pkh := range future {
+		registered[string(pkh)] = true
+	}
+
+	// 2 - The keys are created by signing
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	for i := 0; i < Branches; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("future", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			if !registered[string(pkh)] {
+				t.Fatal("Created a key that was not exported")
+			}
+		}
+	}
+
+	// 3 - The count limits the export
+	if keys, err := tree.ExportFutureKeys(1, 2); err != nil || len(keys) != 2 {
+		t.Fatal("Export was not limited to count, err was", err)
+	}
+
+	// 4 - Only deterministic long-term trees know their future keys
+	if _, err := New(seed, pubSeed, false).ExportFutureKeys(1, 1); err != ErrTreeNotDeterministic {
+		t.Fatal("Exported keys of random tree, err was", err)
+	}
+	if _, err := NewDeterministic(seed, pubSeed, true).ExportFutureKeys(1, 1); err != ErrTreeNotLongTerm {
+		t.Fatal("Exported keys of one-time tree, err was", err)
+	}
+}
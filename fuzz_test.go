@@ -0,0 +1,241 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Returns a deterministic tree with an audit log that signed and confirmed a
+// few messages, and its signatures.
+func fuzzTree(f *testing.F) (*NYTree, []*Signature) {
+	h := NewHarness([]byte("xnyss fuzz"))
+	seed, pubSeed := h.Seeds()
+	tree := NewDeterministic(seed, pubSeed, false)
+	h.Attach(tree)
+	tree.EnableAudit(AuditOptions{Keep: true})
+
+	var sigs []*Signature
+	for i := 0; i < 3; i++ {
+		sig, err := tree.Sign(make([]byte, MsgLen), Txid("fuzz", []byte{byte(i)}))
+		if err != nil {
+			f.Fatal(err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		sigs = append(sigs, sig)
+	}
+
+	return tree, sigs
+}
+
+// Fuzzes decode with the seed inputs. Decoding must not panic, inputs with
+// trailing bytes must be rejected, and whatever is decoded must not alias its
+// input: encoding it again gives the same bytes once the input was overwritten.
+func fuzzDecoder(f *testing.F, seeds [][]byte, decode func(b []byte) (encode func() []byte, err error)) {
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		encode, err := decode(b)
+		if err != nil {
+			return
+		}
+		if _, err := decode(append(append([]byte(nil), b...), 0)); err == nil {
+			t.Fatal("Decoded input with a trailing byte")
+		}
+
+		before := encode()
+		for i := range b {
+			b[i] = ^b[i]
+		}
+		if !bytes.Equal(encode(), before) {
+			t.Fatal("Decoded value aliases its input")
+		}
+	})
+}
+
+func FuzzLoad(f *testing.F) {
+	tree, _ := fuzzTree(f)
+	compact, _ := fuzzTree(f)
+	if err := compact.SetCompact(true); err != nil {
+		f.Fatal(err)
+	}
+
+	legacy := []byte{flagNodeFields}
+	legacy = append(legacy, tree.rootSeed...)
+	legacy = append(legacy, tree.rootPubSeed...)
+	legacy = append(legacy, make([]byte, nodeByteLen)...)
+	legacy = append(legacy, 0, 0, 0, 0)
+
+	fuzzDecoder(f, [][]byte{tree.Bytes(), compact.Bytes(), legacy}, func(b []byte) (func() []byte, error) {
+		loaded, err := Load(b)
+		if err != nil {
+			return nil, err
+		}
+		return loaded.Bytes, nil
+	})
+}
+
+func FuzzLoadSeedBytes(f *testing.F) {
+	tree, _ := fuzzTree(f)
+	b, err := tree.SeedBytes()
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	fuzzDecoder(f, [][]byte{b}, func(b []byte) (func() []byte, error) {
+		loaded, err := LoadSeedBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		return loaded.Bytes, nil
+	})
+}
+
+func FuzzNewSignature(f *testing.F) {
+	_, sigs := fuzzTree(f)
+
+	fuzzDecoder(f, [][]byte{sigs[0].Bytes(), sigs[2].Bytes()}, func(b []byte) (func() []byte, error) {
+		sig, err := NewSignature(b, make([]byte, MsgLen))
+		if err != nil {
+			return nil, err
+		}
+		return sig.Bytes, nil
+	})
+}
+
+func FuzzParseEnvelope(f *testing.F) {
+	_, sigs := fuzzTree(f)
+
+	fuzzDecoder(f, [][]byte{sigs[0].Envelope(), sigs[1].Envelope()}, func(b []byte) (func() []byte, error) {
+		sig, err := ParseEnvelope(b, make([]byte, MsgLen))
+		if err != nil {
+			return nil, err
+		}
+		return sig.Envelope, nil
+	})
+}
+
+func FuzzParseNodeRecord(f *testing.F) {
+	tree, _ := fuzzTree(f)
+	var seeds [][]byte
+	for _, node := range tree.nodes {
+		seeds = append(seeds, append(node.bytes(), node.fields()...))
+	}
+
+	fuzzDecoder(f, seeds, func(b []byte) (func() []byte, error) {
+		r, err := ParseNodeRecord(b)
+		if err != nil {
+			return nil, err
+		}
+		return func() []byte {
+			encoded, _ := r.Bytes()
+			return encoded
+		}, nil
+	})
+}
+
+func FuzzLoadPubTree(f *testing.F) {
+	tree, sigs := fuzzTree(f)
+	pt := NewPubTree(tree.PublicKey())
+	for i, sig := range sigs {
+		if err := pt.Observe(sig, Txid("fuzz", []byte{byte(i)})); err != nil {
+			f.Fatal(err)
+		}
+	}
+
+	fuzzDecoder(f, [][]byte{pt.Bytes()}, func(b []byte) (func() []byte, error) {
+		loaded, err := LoadPubTree(b)
+		if err != nil {
+			return nil, err
+		}
+		return loaded.Bytes, nil
+	})
+}
+
+func FuzzLoadChain(f *testing.F) {
+	_, sigs := fuzzTree(f)
+
+	fuzzDecoder(f, [][]byte{Chain(sigs).Bytes()}, func(b []byte) (func() []byte, error) {
+		c, err := LoadChain(b)
+		if err != nil {
+			return nil, err
+		}
+		return c.Bytes, nil
+	})
+}
+
+func FuzzLoadArchive(f *testing.F) {
+	tree, _ := fuzzTree(f)
+
+	fuzzDecoder(f, [][]byte{tree.ArchiveConsumed().Bytes()}, func(b []byte) (func() []byte, error) {
+		a, err := LoadArchive(b)
+		if err != nil {
+			return nil, err
+		}
+		return a.Bytes, nil
+	})
+}
+
+func FuzzParseSyncSummary(f *testing.F) {
+	tree, _ := fuzzTree(f)
+
+	fuzzDecoder(f, [][]byte{tree.Summary().Bytes()}, func(b []byte) (func() []byte, error) {
+		s, err := ParseSyncSummary(b)
+		if err != nil {
+			return nil, err
+		}
+		return s.Bytes, nil
+	})
+}
+
+func FuzzParseSyncDelta(f *testing.F) {
+	tree, _ := fuzzTree(f)
+	fork, err := tree.Fork()
+	if err != nil {
+		f.Fatal(err)
+	}
+	if _, err := fork.Sign(make([]byte, MsgLen), Txid("fuzz", []byte{0xff})); err != nil {
+		f.Fatal(err)
+	}
+	delta, err := fork.Diff(tree.Fingerprint(), tree.Summary())
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	fuzzDecoder(f, [][]byte{delta.Bytes()}, func(b []byte) (func() []byte, error) {
+		d, err := ParseSyncDelta(b)
+		if err != nil {
+			return nil, err
+		}
+		return d.Bytes, nil
+	})
+}
+
+func FuzzReadAuditLog(f *testing.F) {
+	h := NewHarness([]byte("xnyss fuzz"))
+	tree := h.NewTree(false)
+	var log bytes.Buffer
+	tree.EnableAudit(AuditOptions{Writer: &log})
+	for i := 0; i < 2; i++ {
+		sig, err := tree.Sign(make([]byte, MsgLen), Txid("fuzz", []byte{byte(i)}))
+		if err != nil {
+			f.Fatal(err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+
+	fuzzDecoder(f, [][]byte{log.Bytes()}, func(b []byte) (func() []byte, error) {
+		entries, err := ReadAuditLog(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return func() []byte {
+			var encoded []byte
+			for i := range entries {
+				encoded = append(encoded, entries[i].bytes()...)
+			}
+			return encoded
+		}, nil
+	})
+}
@@ -0,0 +1,78 @@
+package xnyss
+
+import (
+	"bytes"
+)
+
+// Tags of the fields of a gob encoded signature.
+const (
+	gobFieldMessage  = 0x01
+	gobFieldEnvelope = 0x02
+)
+
+// Implements gob.GobEncoder using the canonical binary format of Bytes, since
+// the state of a tree is held in unexported fields that gob would silently
+// drop.
+func (t *NYTree) GobEncode() ([]byte, error) {
+	if err := injectPersistFault(); err != nil {
+		return nil, err
+	}
+
+	if t.Wiped() {
+		return nil, ErrTreeWiped
+	}
+
+	return t.Bytes(), nil
+}
+
+// Implements gob.GobDecoder, see Load.
+func (t *NYTree) GobDecode(b []byte) error {
+	loaded, err := Load(b)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state = loaded.state
+	return nil
+}
+
+// Implements gob.GobEncoder, encoding the envelope of the signature together
+// with its message, so the parameters of the signature are kept.
+func (sig *Signature) GobEncode() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeField(buf, gobFieldMessage, sig.Message)
+	writeField(buf, gobFieldEnvelope, sig.Envelope())
+
+	return buf.Bytes(), nil
+}
+
+// Implements gob.GobDecoder, see GobEncode.
+func (sig *Signature) GobDecode(b []byte) error {
+	var msg, envelope []byte
+	err := readFields(b, func(tag byte, value []byte) error {
+		switch tag {
+		case gobFieldMessage:
+			msg = value
+		case gobFieldEnvelope:
+			envelope = value
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	decoded, err := ParseEnvelope(envelope, msg)
+	if err != nil {
+		return err
+	}
+
+	*sig = *decoded
+	return nil
+}
@@ -0,0 +1,48 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+// A job as it would be stored in a gob-based queue.
+type gobJob struct {
+	Tree      *NYTree
+	Signature *Signature
+}
+
+func TestGob(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(&gobJob{Tree: tree, Signature: sig}); err != nil {
+		t.Fatal("Failed to encode -", err)
+	}
+
+	job := &gobJob{}
+	if err := gob.NewDecoder(buf).Decode(job); err != nil {
+		t.Fatal("Failed to decode -", err)
+	}
+
+	// 1 - No state is lost
+	if !bytes.Equal(job.Tree.Bytes(), tree.Bytes()) {
+		t.Fatal("Decoded tree differs")
+	}
+	if !bytes.Equal(job.Signature.Bytes(), sig.Bytes()) || !bytes.Equal(job.Signature.Message, sig.Message) {
+		t.Fatal("Decoded signature differs")
+	}
+	if p, ok := job.Signature.Params(); !ok || p.Branches != Branches {
+		t.Fatal("Signature parameters were lost")
+	}
+}
@@ -0,0 +1,93 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"sync"
+	"time"
+)
+
+// Domain separation prefix of the keys of the entropy streams of a harness.
+const harnessDomain = "xnyss harness"
+
+// The time the clock of a harness starts at.
+var HarnessEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// A reproducible environment for end-to-end tests. Trees and keyrings attached
+// to a harness read all their randomness from deterministic streams derived
+// from its seed, and the time from its manual clock, so that running the same
+// flow twice produces the same states, signatures and encrypted states byte for
+// byte. This lets reviewers compare the output of a flow before and after a
+// change, e.g. against recorded test vectors.
+//
+// Streams are handed out in order, so flows are only reproducible if they
+// create and attach their trees in the same order. Trees created by Backup or
+// Load must be attached again, since neither the clock nor the entropy source
+// is passed on. The streams are not random: a harness must never be used
+// outside of tests.
+type Harness struct {
+	// The clock of attached trees, starting at HarnessEpoch
+	Clock *ManualClock
+
+	mu      sync.Mutex
+	seed    []byte
+	streams uint32
+}
+
+// Creates a harness whose entropy streams are derived from seed.
+func NewHarness(seed []byte) *Harness {
+	return &Harness{
+		Clock: NewManualClock(HarnessEpoch),
+		seed:  append([]byte(nil), seed...),
+	}
+}
+
+// Returns the next entropy stream of h: the stream of SHA-256 digests used by
+// deterministic trees, keyed with H("xnyss harness" || seed || n) for the n-th
+// stream. Streams are independent of each other.
+func (h *Harness) Entropy() io.Reader {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], h.streams)
+	h.streams++
+
+	key := sha256.New()
+	key.Write([]byte(harnessDomain))
+	key.Write(h.seed)
+	key.Write(n[:])
+
+	return newDerivationStream(key.Sum(nil), nil)
+}
+
+// Returns a seed and public seed for New, read from the next entropy stream.
+func (h *Harness) Seeds() (seed, pubSeed []byte) {
+	seeds := make([]byte, 64)
+	// Derivation streams do not fail
+	io.ReadFull(h.Entropy(), seeds)
+
+	return seeds[:32], seeds[32:]
+}
+
+// Creates a tree like New with seeds from h, attached to h.
+func (h *Harness) NewTree(ots bool) *NYTree {
+	seed, pubSeed := h.Seeds()
+	t := New(seed, pubSeed, ots)
+	h.Attach(t)
+
+	return t
+}
+
+// Sets the clock of the tree t to the clock of h, and its entropy source to
+// the next entropy stream of h.
+func (h *Harness) Attach(t *NYTree) {
+	t.SetClock(h.Clock)
+	t.SetEntropy(h.Entropy())
+}
+
+// Sets the entropy source of the keyring k to the next entropy stream of h.
+func (h *Harness) AttachKeyring(k *Keyring) {
+	k.SetEntropy(h.Entropy())
+}
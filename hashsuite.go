@@ -0,0 +1,184 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"crypto/sha3"
+	"hash"
+	"sync"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrHashSuiteUnknown = newError(ErrCrypto, "unknown or unregistered hash suite")
+	ErrHashSuiteInvalid = newError(ErrCrypto, "hash suite must produce 32 byte digests")
+)
+
+// Identifies the hash function used by the WOTS+ chains of a tree, and to hash
+// public keys and signed digests. The derivation of seeds always uses SHA-256.
+type HashSuite uint8
+
+const (
+	// SHA-256, used by trees that do not set a hash suite.
+	SuiteSHA256 HashSuite = 0x01
+	// SHA3-256.
+	SuiteSHA3_256 HashSuite = 0x02
+	// BLAKE2b-256. The standard library does not implement BLAKE2b, so the
+	// application must register it using RegisterHashSuite, e.g. with
+	// golang.org/x/crypto/blake2b.
+	SuiteBLAKE2b256 HashSuite = 0x03
+)
+
+var (
+	hashSuitesMu sync.RWMutex
+	hashSuites   = map[HashSuite]wotsp.Hash{
+		SuiteSHA256:   sha256.New,
+		SuiteSHA3_256: func() hash.Hash { return sha3.New256() },
+	}
+)
+
+// Registers the hash function of suite s, replacing any function registered
+// before. The hash function must produce 32 byte digests, and meet the
+// requirements of wotsp.Hash. Returns ErrHashSuiteInvalid otherwise.
+func RegisterHashSuite(s HashSuite, newHash func() hash.Hash) error {
+	if s == 0 || newHash == nil || newHash().Size() != 32 {
+		return ErrHashSuiteInvalid
+	}
+
+	hashSuitesMu.Lock()
+	defer hashSuitesMu.Unlock()
+
+	hashSuites[s] = newHash
+
+	return nil
+}
+
+// Returns the hash function of suite s. The zero suite is SuiteSHA256.
+func (s HashSuite) lookup() (wotsp.Hash, error) {
+	if s == 0 {
+		s = SuiteSHA256
+	}
+
+	hashSuitesMu.RLock()
+	defer hashSuitesMu.RUnlock()
+
+	newHash, ok := hashSuites[s]
+	if !ok {
+		return nil, ErrHashSuiteUnknown
+	}
+
+	return newHash, nil
+}
+
+// Like lookup, for suites that are known to be registered: trees and
+// signatures check their suite when it is set or loaded.
+func (s HashSuite) hash() wotsp.Hash {
+	newHash, err := s.lookup()
+	if err != nil {
+		panic(err)
+	}
+
+	return newHash
+}
+
+// Returns the digest of b using suite s.
+func (s HashSuite) sum(b []byte) []byte {
+	h := s.hash()()
+	h.Write(b)
+
+	return h.Sum(nil)
+}
+
+// Sets the hash suite of the fresh tree t. Changing the hash suite changes the
+// long-term public key, so returns ErrTreeNotFresh if t was used,
+// ErrHashSuiteUnknown if s is not registered, and ErrSeedProviderTree if the
+// seeds of t are held by a seed provider.
+func (t *NYTree) SetHashSuite(s HashSuite) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := s.lookup(); err != nil {
+		return err
+	}
+	if s == SuiteSHA256 {
+		s = 0
+	}
+	if t.suite == s {
+		return nil
+	}
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+	if t.providerSeeds {
+		return ErrSeedProviderTree
+	}
+
+	t.suite = s
+	t.nodes[0].suite = s
+	t.nodes[0].pkh = nil
+	t.nodes[0].dropKey()
+	t.dropIndex()
+	t.epoch++
+
+	return nil
+}
+
+// Returns the hash suite of the tree t.
+func (t *NYTree) HashSuite() HashSuite {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.suite == 0 {
+		return SuiteSHA256
+	}
+
+	return t.suite
+}
+
+// Sets the hash suite, Winternitz parameter and seed provider of the nodes of a
+// loaded tree to those of the tree, bounds their confirmation counts (see
+// MaxConfirms), and assigns its reservations to them.
+func (t *NYTree) loadKeyParams() {
+	for _, node := range t.nodes {
+		node.suite = t.suite
+		node.w = t.w
+		// States written before counts were bounded may hold higher ones
+		node.confirms = boundConfirms(node.confirms)
+	}
+	if t.revocationNode != nil {
+		t.revocationNode.suite = t.suite
+		t.revocationNode.w = t.w
+	}
+	t.loadSeedProvider()
+	t.resolveReservations()
+}
+
+// Returns the hash suite of the tree that created the signature sig.
+// Signatures created with Sign and parsed from an envelope know their suite;
+// those decoded with NewSignature are assumed to use SHA-256, see
+// Signature.SetHashSuite.
+func (sig *Signature) HashSuite() HashSuite {
+	if sig.suite == 0 {
+		return SuiteSHA256
+	}
+
+	return sig.suite
+}
+
+// Sets the hash suite used to verify sig, for signatures decoded with
+// NewSignature. Returns ErrHashSuiteUnknown if s is not registered.
+func (sig *Signature) SetHashSuite(s HashSuite) error {
+	if _, err := s.lookup(); err != nil {
+		return err
+	}
+	if s == SuiteSHA256 {
+		s = 0
+	}
+
+	sig.suite = s
+	if sig.params != nil {
+		sig.params.HashSuite = sig.HashSuite()
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetHashSuite(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sha3Tree := New(seed, pubSeed, false)
+	if err := sha3Tree.SetHashSuite(SuiteSHA3_256); err != nil {
+		t.Fatal("Failed to set hash suite -", err)
+	}
+	if sha3Tree.HashSuite() != SuiteSHA3_256 || tree.HashSuite() != SuiteSHA256 {
+		t.Fatal("Invalid tree hash suites")
+	}
+
+	// 1 - The hash suite changes the long-term public key
+	if bytes.Equal(tree.PublicKey(), sha3Tree.PublicKey()) {
+		t.Fatal("Hash suite did not change the public key")
+	}
+
+	// 2 - Chains of signatures verify, and record the suite in the envelope
+	var chain []*Signature
+	for i := 0; i < 2; i++ {
+		sig, err := sha3Tree.Sign(testdata.Message, Txid("suite", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		sha3Tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+	}
+	if err := VerifyChain(sha3Tree.PublicKey(), chain, testdata.Message); err != nil {
+		t.Fatal("Failed to verify chain -", err)
+	}
+	parsed, err := ParseEnvelope(chain[1].Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if p, ok := parsed.Params(); !ok || p.HashSuite != SuiteSHA3_256 {
+		t.Fatal("Hash suite was not included in the envelope")
+	}
+	if len(chain[1].Envelope()) != EnvelopeLen(Branches, true)+EnvelopePositionLen+EnvelopeParamSetLen {
+		t.Fatal("Invalid envelope length")
+	}
+
+	// 3 - Signatures decoded from bytes need their suite to be set
+	plain, err := NewSignature(chain[0].Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pk, _ := plain.PublicKey(); bytes.Equal(pk, sha3Tree.PublicKey()) {
+		t.Fatal("Verified signature with the wrong hash suite")
+	}
+	if err := plain.SetHashSuite(SuiteSHA3_256); err != nil {
+		t.Fatal(err)
+	}
+	if pk, _ := plain.PublicKey(); !bytes.Equal(pk, sha3Tree.PublicKey()) {
+		t.Fatal("Failed to verify signature with its hash suite")
+	}
+
+	// 4 - Chains can not mix hash suites
+	other, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	mixed := []*Signature{other, chain[1]}
+	if err := VerifyChain(tree.PublicKey(), mixed, testdata.Message); err != ErrSchemeMismatch {
+		t.Fatal("Verified chain mixing hash suites, err was", err)
+	}
+
+	// 5 - The suite is persisted, and can not change once the tree was used
+	loaded, err := Load(sha3Tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.HashSuite() != SuiteSHA3_256 {
+		t.Fatal("Hash suite was not persisted")
+	}
+	sig, err := loaded.Sign(testdata.Message, Txid("suite", []byte{2}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if !hasChild(chain[0], mustPublicKey(t, sig)) && !hasChild(chain[1], mustPublicKey(t, sig)) {
+		t.Fatal("Loaded tree did not sign with a child node")
+	}
+	if err := loaded.SetHashSuite(SuiteSHA256); err != ErrTreeNotFresh {
+		t.Fatal("Changed hash suite of used tree, err was", err)
+	}
+}
+
+func TestRegisterHashSuite(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Unregistered suites are rejected
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetHashSuite(0xfe); err != ErrHashSuiteUnknown {
+		t.Fatal("Set unregistered hash suite, err was", err)
+	}
+
+	// 2 - Registered suites can be used
+	if err := RegisterHashSuite(0xfe, sha256.New); err != nil {
+		t.Fatal("Failed to register hash suite -", err)
+	}
+	defer func() {
+		hashSuitesMu.Lock()
+		delete(hashSuites, 0xfe)
+		hashSuitesMu.Unlock()
+	}()
+	if err := tree.SetHashSuite(0xfe); err != nil {
+		t.Fatal("Failed to set registered hash suite -", err)
+	}
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{sig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify -", err)
+	}
+}
+
+func mustPublicKey(t *testing.T, sig *Signature) []byte {
+	pk, err := sig.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pk
+}
@@ -0,0 +1,349 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+var (
+	ErrHypertreeUncertified = newError(ErrCrypto, "lower tree is not certified by the upper tree")
+	ErrInvalidHypertree     = newError(ErrEncoding, "invalid hypertree encoding")
+)
+
+// Domain separation prefixes of the seeds of lower trees and of the digests
+// signed by certificates.
+const (
+	hypertreeSeedDomain = "xnyss hypertree seed"
+	hypertreeCertDomain = "xnyss hypertree certificate"
+)
+
+// The namespace of the txids of certificates, see Txid.
+const hypertreeNamespace = "xnyss hypertree"
+
+// Version byte of an encoded hypertree.
+const hypertreeVersion = 0x01
+
+// Tags of the fields of an encoded hypertree. Upper signature fields hold the
+// signed digest followed by the envelope of the signature.
+const (
+	hypertreeFieldUpper      = 0x01
+	hypertreeFieldUpperSig   = 0x02
+	hypertreeFieldLower      = 0x03
+	hypertreeFieldGeneration = 0x04
+	hypertreeFieldSigned     = 0x05
+	hypertreeFieldRollover   = 0x06
+)
+
+// A two layer construction for signers that need more signatures than a single
+// tree comfortably provides. The upper tree only certifies the long-term keys
+// of lower trees, and the lower trees sign messages. Once a lower tree has
+// created a given amount of signatures, or can not sign any more, the
+// hypertree rolls over to a new lower tree, certified by the next signature of
+// the upper tree. The chains verifiers need stay short, since every lower tree
+// starts from a fresh root.
+//
+// The seeds of lower trees are derived from the root seeds of the upper tree
+// and the generation of the lower tree. The nodes of the upper tree are
+// confirmed as soon as they are created, since certificates are not published
+// on a blockchain; the nodes of lower trees must be confirmed using Confirm.
+type Hypertree struct {
+	mu    sync.Mutex
+	upper *NYTree
+	// All signatures created by the upper tree, oldest first
+	upperSigs []*Signature
+	lower     *NYTree
+	// The certificate of the lower tree: the chain of upper signatures ending
+	// with the signature of its long-term key
+	cert       Chain
+	generation uint64
+	// The amount of signatures created by the lower tree
+	signed   uint64
+	rollover uint64
+}
+
+// A signature created by a hypertree.
+type HyperSignature struct {
+	// The generation of the lower tree that created the signature
+	Generation uint64
+	// The chain of upper signatures certifying LowerKey
+	Certificate Chain
+	// The long-term key of the lower tree
+	LowerKey []byte
+	// The chain of lower signatures from LowerKey to the signature of the
+	// message
+	Chain Chain
+}
+
+// Creates a hypertree whose upper tree has the given root seeds. Lower trees
+// roll over after rollover signatures, or only once they can not sign any more
+// if rollover is zero. The first lower tree is created and certified
+// immediately.
+func NewHypertree(seed, pubSeed []byte, rollover uint64) (*Hypertree, error) {
+	h := &Hypertree{upper: New(seed, pubSeed, false), rollover: rollover}
+	if err := h.rollOver(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Returns the long-term public key of the hypertree h, i.e. of its upper tree.
+func (h *Hypertree) PublicKey() []byte {
+	return h.upper.PublicKey()
+}
+
+// Returns the generation of the current lower tree of h. The first lower tree
+// has generation 1.
+func (h *Hypertree) Generation() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.generation
+}
+
+// Signs msg with the current lower tree of h, like NYTree.Sign, rolling over to
+// a new lower tree first if the current one reached its limit. The returned
+// signature holds everything needed to verify it against the long-term key of
+// h.
+//
+// Lower trees only remember the signatures that created their nodes since they
+// were loaded, so a loaded hypertree may roll over before the limit is reached
+// to be able to return complete chains.
+func (h *Hypertree) Sign(msg, txid []byte) (*HyperSignature, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if (h.rollover > 0 && h.signed >= h.rollover) || h.lower.Exhausted() {
+		if err := h.rollOver(); err != nil {
+			return nil, err
+		}
+	}
+
+	sig, bundle, err := h.lower.SignBundle(msg, txid, nil)
+	if err == ErrBundleIncomplete {
+		if err := h.rollOver(); err != nil {
+			return nil, err
+		}
+		sig, bundle, err = h.lower.SignBundle(msg, txid, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.signed++
+
+	return &HyperSignature{
+		Generation:  h.generation,
+		Certificate: append(Chain(nil), h.cert...),
+		LowerKey:    h.lower.PublicKey(),
+		Chain:       append(Chain(bundle.Links), sig),
+	}, nil
+}
+
+// Sets the confirmation count of the node of the current lower tree with
+// public key hash pkh, like NYTree.Confirm.
+func (h *Hypertree) Confirm(pkh []byte, confirms uint8) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lower.Confirm(pkh, confirms)
+}
+
+// Replaces the lower tree of h by a new one, certified by the upper tree.
+func (h *Hypertree) Rollover() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.rollOver()
+}
+
+func (h *Hypertree) rollOver() error {
+	generation := h.generation + 1
+	seed, pubSeed := h.lowerSeeds(generation)
+	lower := New(seed, pubSeed, false)
+
+	var gen [8]byte
+	binary.BigEndian.PutUint64(gen[:], generation)
+	sig, err := h.upper.Sign(hypertreeDigest(generation, lower.PublicKey()), Txid(hypertreeNamespace, gen[:]))
+	if err != nil {
+		return err
+	}
+	for _, pkh := range sig.ChildHashes {
+		h.upper.Confirm(pkh, ConfirmsRequired)
+	}
+
+	h.upperSigs = append(h.upperSigs, sig)
+	cert, err := h.certificate(sig)
+	if err != nil {
+		return err
+	}
+
+	if h.lower != nil {
+		h.lower.Wipe()
+	}
+	h.lower, h.cert, h.generation, h.signed = lower, cert, generation, 0
+
+	return nil
+}
+
+// Derives the root seeds of the lower tree of the given generation.
+func (h *Hypertree) lowerSeeds(generation uint64) (seed, pubSeed []byte) {
+	h.upper.mu.Lock()
+	defer h.upper.mu.Unlock()
+	root, done := unmask(h.upper.rootSeed, h.upper.rootMask)
+	defer done()
+
+	var gen [8]byte
+	binary.BigEndian.PutUint64(gen[:], generation)
+	derive := func(i byte) []byte {
+		s := sha256.New()
+		s.Write([]byte(hypertreeSeedDomain))
+		s.Write(root)
+		s.Write(gen[:])
+		s.Write([]byte{i})
+		return s.Sum(nil)
+	}
+
+	return derive(0), derive(1)
+}
+
+// Returns the chain of upper signatures from the root of the upper tree to sig.
+func (h *Hypertree) certificate(sig *Signature) (Chain, error) {
+	root := h.upper.PublicKey()
+	chain := Chain{sig}
+
+	for {
+		pk, err := chain[0].PublicKey()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(pk, root) {
+			return chain, nil
+		}
+
+		var parent *Signature
+		for _, s := range h.upperSigs {
+			if hasChild(s, pk) {
+				parent = s
+				break
+			}
+		}
+		if parent == nil {
+			return nil, ErrHypertreeUncertified
+		}
+		chain = append(Chain{parent}, chain...)
+	}
+}
+
+// Returns the digest signed by the certificate of the lower tree of the given
+// generation with long-term key lowerKey.
+func hypertreeDigest(generation uint64, lowerKey []byte) []byte {
+	var gen [8]byte
+	binary.BigEndian.PutUint64(gen[:], generation)
+
+	s := sha256.New()
+	s.Write([]byte(hypertreeCertDomain))
+	s.Write(gen[:])
+	s.Write(lowerKey)
+
+	return s.Sum(nil)
+}
+
+// Verifies that hs is a signature of msg by the hypertree with long-term key
+// rootPubKey: the certificate must link rootPubKey to the lower key, and the
+// chain must link the lower key to msg. Returns ErrHypertreeUncertified if the
+// certificate does not sign the lower key, or the errors of VerifyChain.
+func (hs *HyperSignature) Verify(rootPubKey, msg []byte) error {
+	err := hs.Certificate.Verify(rootPubKey, hypertreeDigest(hs.Generation, hs.LowerKey))
+	if err == ErrChainMessage {
+		return ErrHypertreeUncertified
+	}
+	if err != nil {
+		return err
+	}
+
+	return hs.Chain.Verify(hs.LowerKey, msg)
+}
+
+// Returns the encoding of the hypertree h, which includes the states of its
+// upper and lower tree. Like tree states, it must be stored before the
+// signatures created since the previous encoding are published.
+func (h *Hypertree) Bytes() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(hypertreeVersion)
+	writeField(buf, hypertreeFieldUpper, h.upper.Bytes())
+	for _, sig := range h.upperSigs {
+		writeField(buf, hypertreeFieldUpperSig, append(append([]byte(nil), sig.Message...), sig.Envelope()...))
+	}
+	writeField(buf, hypertreeFieldLower, h.lower.Bytes())
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], h.generation)
+	writeField(buf, hypertreeFieldGeneration, b[:])
+	binary.BigEndian.PutUint64(b[:], h.signed)
+	writeField(buf, hypertreeFieldSigned, b[:])
+	binary.BigEndian.PutUint64(b[:], h.rollover)
+	writeField(buf, hypertreeFieldRollover, b[:])
+
+	return buf.Bytes()
+}
+
+// Decodes a hypertree encoded by Hypertree.Bytes.
+func LoadHypertree(b []byte) (*Hypertree, error) {
+	if len(b) < 1 || b[0] != hypertreeVersion {
+		return nil, ErrInvalidHypertree
+	}
+
+	h := &Hypertree{}
+	err := readFields(b[1:], func(tag byte, value []byte) (err error) {
+		switch tag {
+		case hypertreeFieldUpper:
+			h.upper, err = Load(value)
+		case hypertreeFieldUpperSig:
+			if len(value) < MsgLen {
+				return ErrInvalidHypertree
+			}
+			sig, err := ParseEnvelope(value[MsgLen:], append([]byte(nil), value[:MsgLen]...))
+			if err != nil {
+				return err
+			}
+			h.upperSigs = append(h.upperSigs, sig)
+		case hypertreeFieldLower:
+			h.lower, err = Load(value)
+		case hypertreeFieldGeneration, hypertreeFieldSigned, hypertreeFieldRollover:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			v := binary.BigEndian.Uint64(value)
+			switch tag {
+			case hypertreeFieldGeneration:
+				h.generation = v
+			case hypertreeFieldSigned:
+				h.signed = v
+			default:
+				h.rollover = v
+			}
+		default:
+			return ErrFieldInvalid
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if h.upper == nil || h.lower == nil || len(h.upperSigs) == 0 {
+		return nil, ErrInvalidHypertree
+	}
+
+	h.cert, err = h.certificate(h.upperSigs[len(h.upperSigs)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
@@ -0,0 +1,71 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestHypertree(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := NewHypertree(seed, pubSeed, 2)
+	if err != nil {
+		t.Fatal("Failed to create hypertree -", err)
+	}
+	pk := h.PublicKey()
+
+	sign := func(h *Hypertree, i int) *HyperSignature {
+		hs, err := h.Sign(testdata.Message, Txid("hypertree", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign", i, "-", err)
+		}
+		for _, pkh := range hs.Chain[len(hs.Chain)-1].ChildHashes {
+			h.Confirm(pkh, ConfirmsRequired)
+		}
+		return hs
+	}
+
+	// 1 - Signatures verify against the key of the upper tree, lower trees roll
+	// over after two signatures
+	for i := 0; i < 5; i++ {
+		hs := sign(h, i)
+		if err := hs.Verify(pk, testdata.Message); err != nil {
+			t.Fatal("Failed to verify signature", i, "-", err)
+		}
+		if hs.Generation != uint64(i/2+1) {
+			t.Fatal("Signature", i, "was created by generation", hs.Generation)
+		}
+	}
+
+	// 2 - Certificates are bound to their lower key and generation
+	hs := sign(h, 5)
+	hs.Generation++
+	if err := hs.Verify(pk, testdata.Message); err != ErrHypertreeUncertified {
+		t.Fatal("Verified signature with wrong generation, err was", err)
+	}
+	hs.Generation--
+	other := New(seed[1:], pubSeed, false)
+	if err := hs.Verify(other.PublicKey(), testdata.Message); err != ErrChainBroken {
+		t.Fatal("Verified signature against other key, err was", err)
+	}
+
+	// 3 - Loaded hypertrees continue where they stopped
+	loaded, err := LoadHypertree(h.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load hypertree -", err)
+	}
+	if !bytes.Equal(loaded.PublicKey(), pk) || loaded.Generation() != h.Generation() {
+		t.Fatal("Loaded hypertree differs")
+	}
+	hs = sign(loaded, 6)
+	if err := hs.Verify(pk, testdata.Message); err != nil {
+		t.Fatal("Failed to verify signature of loaded hypertree -", err)
+	}
+	if hs.Generation <= 3 {
+		t.Fatal("Loaded hypertree reused generation", hs.Generation)
+	}
+}
@@ -0,0 +1,226 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+var (
+	ErrInvalidJournal = newError(ErrEncoding, "invalid journal entry encoding")
+)
+
+// Version byte of an encoded journal entry.
+const journalVersion = 0x01
+
+// Tags of the fields of an encoded journal entry.
+const (
+	journalFieldPkh        = 0x01
+	journalFieldSeedDigest = 0x02
+	journalFieldTxid       = 0x03
+)
+
+// A signature in progress, see BeginSign.
+type PendingSign struct {
+	id         ReservationID
+	pkh        []byte
+	seedDigest [32]byte
+	txid       []byte
+}
+
+// Starts a signature for the transaction txid in two phases, so a crash
+// between using a node and storing the new state of the tree can not lead to
+// the node signing twice. BeginSign selects the node that will sign, like Sign
+// does, and reserves it until CommitSign or AbortSign is called; unlike the
+// reservations of Reserve, the reservation does not expire.
+//
+// The application must durably store the journal entry of the returned
+// signature (see PendingSign.Journal) before calling CommitSign, and may drop
+// it once the state of the tree is stored after CommitSign or AbortSign. After
+// loading a state, the stored journal entries must be passed to Recover.
+func (t *NYTree) BeginSign(txid []byte) (*PendingSign, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	txid, _, err := t.normalizeTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := t.selectSignNode(txid, nil)
+	if err != nil {
+		return nil, err
+	}
+	node := t.nodes[index]
+	// The signature takes over a reservation made for txid with Reserve
+	t.consumeReservation(node)
+
+	p := &PendingSign{
+		pkh:        append([]byte(nil), node.pubKeyHash()...),
+		seedDigest: node.seedDigest(),
+		txid:       append([]byte(nil), txid...),
+	}
+	p.id = t.reserve(node, &reservation{txid: p.txid, pending: true})
+	t.log(LevelAudit, "signature begun", pkhAttr(p.pkh), "txid", hex.EncodeToString(txid))
+
+	return p, nil
+}
+
+// Creates the signature of msg started by BeginSign, using the node reserved
+// for it, like Sign. Returns ErrReservationUnknown if the signature was
+// already committed or aborted, or if it was begun by another tree.
+func (t *NYTree) CommitSign(p *PendingSign, msg []byte) (sig *Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.reservations[p.id]
+	if !ok || !r.pending || !bytes.Equal(r.node.pubKeyHash(), p.pkh) {
+		return nil, ErrReservationUnknown
+	}
+
+	// The reservation only keeps other signatures from using the node
+	r.pending = false
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, p.txid, &signOptions{
+			filter: func(n *nyNode) bool { return n == r.node },
+		})
+	})
+	if err != nil && r.node.reservation == p.id {
+		r.pending = true
+	}
+
+	return
+}
+
+// Aborts the signature started by BeginSign, returning its node to the pool of
+// available nodes. Returns ErrReservationUnknown if the signature was already
+// committed or aborted, or if it was begun by another tree.
+func (t *NYTree) AbortSign(p *PendingSign) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.reservations[p.id]
+	if !ok || !r.pending || !bytes.Equal(r.node.pubKeyHash(), p.pkh) {
+		return ErrReservationUnknown
+	}
+
+	r.node.reservation = 0
+	delete(t.reservations, p.id)
+	t.resStats.Released++
+	t.log(LevelAudit, "signature aborted", pkhAttr(p.pkh))
+	t.notifyAvailable()
+
+	return nil
+}
+
+// Returns the public key hash of the node that creates the signature p.
+func (p *PendingSign) PublicKeyHash() []byte {
+	return append([]byte(nil), p.pkh...)
+}
+
+// Returns the journal entry of the signature p, which identifies its node
+// without revealing its seeds, see BeginSign.
+func (p *PendingSign) Journal() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(journalVersion)
+	writeField(buf, journalFieldPkh, p.pkh)
+	writeField(buf, journalFieldSeedDigest, p.seedDigest[:])
+	writeField(buf, journalFieldTxid, p.txid)
+
+	return buf.Bytes()
+}
+
+// Applies the journal entries of signatures that were begun but whose outcome
+// may not be part of the state the tree t was loaded from, see BeginSign. The
+// node of every entry is considered used: it is marked as consumed and
+// removed from t, even if the signature was aborted or never created, so an
+// interrupted signature never leads to a node signing twice. Returns the
+// number of nodes removed, or ErrInvalidJournal without changing t if an
+// entry is malformed.
+func (t *NYTree) Recover(journal [][]byte) (recovered int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return 0, ErrTreeWiped
+	}
+
+	entries := make([]*PendingSign, len(journal))
+	for i, b := range journal {
+		if entries[i], err = decodeJournal(b); err != nil {
+			return 0, err
+		}
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool, len(entries))
+	}
+	if t.consumedSeeds == nil {
+		t.consumedSeeds = make(map[[32]byte]bool, len(entries))
+	}
+	for _, p := range entries {
+		var pkh [32]byte
+		copy(pkh[:], p.pkh)
+		t.consumed[pkh] = true
+		t.consumedSeeds[p.seedDigest] = true
+	}
+
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		var key [32]byte
+		copy(key[:], node.pubKeyHash())
+		if t.consumed[key] || t.consumedSeeds[node.seedDigest()] {
+			t.consumeReservation(node)
+			node.wipe()
+			recovered++
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	t.nodes = nodes
+	t.dropIndex()
+	t.epoch++
+	t.log(LevelAudit, "journal recovered", "entries", len(entries), "nodes", recovered)
+
+	return recovered, t.writeThrough()
+}
+
+func decodeJournal(b []byte) (*PendingSign, error) {
+	if len(b) < 1 || b[0] != journalVersion {
+		return nil, ErrInvalidJournal
+	}
+
+	p := &PendingSign{}
+	var digest bool
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case journalFieldPkh:
+			p.pkh = append([]byte(nil), value...)
+		case journalFieldSeedDigest:
+			if len(value) != 32 {
+				return ErrInvalidJournal
+			}
+			copy(p.seedDigest[:], value)
+			digest = true
+		case journalFieldTxid:
+			p.txid = append([]byte(nil), value...)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(p.pkh) != 32 || !digest || len(p.txid) != TxidLen {
+		return nil, ErrInvalidJournal
+	}
+
+	return p, nil
+}
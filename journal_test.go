@@ -0,0 +1,82 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_BeginSign(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+
+	// 1 - The node of a pending signature is not used by other signatures
+	pending, err := tree.BeginSign(testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to begin signature -", err)
+	}
+	if _, _, err := signMessage("journal test", tree); err != ErrTreeNoneAvailable {
+		t.Fatal("Signed with the node of a pending signature, err was", err)
+	}
+	state := tree.Bytes()
+
+	// 2 - Committing creates the signature with the reserved node
+	sig, err := tree.CommitSign(pending, testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to commit signature -", err)
+	}
+	if ok, err := Verify(pk, sig, testdata.Message); !ok || err != nil {
+		t.Fatal("Committed signature is invalid -", err)
+	}
+	if _, err := tree.CommitSign(pending, testdata.Message); err != ErrReservationUnknown {
+		t.Fatal("Committed signature twice, err was", err)
+	}
+
+	// 3 - Recovering the journal of a stale state removes the node
+	loaded, err := Load(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := loaded.Recover([][]byte{pending.Journal()})
+	if err != nil || recovered != 1 {
+		t.Fatal("Recovered", recovered, "nodes -", err)
+	}
+	if !containsHash(loaded.Consumed(), pending.PublicKeyHash()) || !loaded.Exhausted() {
+		t.Fatal("Recovered tree can still use the journaled node")
+	}
+
+	// 4 - Aborted signatures return their node
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	available := tree.Available(nil)
+	pending, err = tree.BeginSign(testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to begin signature -", err)
+	}
+	if tree.Available(nil) != available-1 {
+		t.Fatal("Pending signature does not hold a node")
+	}
+	if err := tree.AbortSign(pending); err != nil {
+		t.Fatal("Failed to abort signature -", err)
+	}
+	if tree.Available(nil) != available {
+		t.Fatal("Aborted signature did not return its node")
+	}
+	if _, err := tree.CommitSign(pending, testdata.Message); err != ErrReservationUnknown {
+		t.Fatal("Committed aborted signature, err was", err)
+	}
+
+	// 5 - Malformed journal entries are rejected
+	entry := pending.Journal()
+	if _, err := tree.Recover([][]byte{entry[:len(entry)-1]}); err == nil {
+		t.Fatal("Recovered truncated journal entry")
+	}
+	if tree.Available(nil) != available {
+		t.Fatal("Tree changed by malformed journal")
+	}
+}
@@ -0,0 +1,227 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+)
+
+var (
+	ErrInvalidJSON = newError(ErrEncoding, "invalid JSON encoding")
+)
+
+// The JSON encoding of a signature. The envelope is the canonical encoding;
+// the child hashes are included for readability, and must match it.
+type signatureJSON struct {
+	Message     string   `json:"message,omitempty"`
+	Envelope    []byte   `json:"envelope"`
+	ChildHashes []string `json:"childHashes,omitempty"`
+}
+
+// Implements json.Marshaler. The signature is encoded as an object holding
+// its message in hex, its envelope (see Envelope) in base64 and, for
+// readability, its child hashes in hex.
+func (sig *Signature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(signatureJSON{
+		Message:     hex.EncodeToString(sig.Message),
+		Envelope:    sig.Envelope(),
+		ChildHashes: hexList(sig.ChildHashes),
+	})
+}
+
+// Implements json.Unmarshaler for the encoding of MarshalJSON. Returns
+// ErrInvalidJSON if the child hashes do not match the envelope, and the errors
+// of ParseEnvelope if it is invalid.
+func (sig *Signature) UnmarshalJSON(b []byte) error {
+	var v signatureJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return ErrInvalidJSON
+	}
+	msg, err := hex.DecodeString(v.Message)
+	if err != nil {
+		return ErrInvalidJSON
+	}
+
+	decoded, err := ParseEnvelope(v.Envelope, msg)
+	if err != nil {
+		return err
+	}
+	if v.ChildHashes != nil && !equalHexList(v.ChildHashes, decoded.ChildHashes) {
+		return ErrInvalidJSON
+	}
+	if len(msg) == 0 {
+		decoded.Message = nil
+	}
+
+	*sig = *decoded
+	return nil
+}
+
+// The JSON encoding of a node description.
+type nodeInfoJSON struct {
+	PubKeyHash string `json:"pubKeyHash"`
+	Txid       string `json:"txid"`
+	Confirms   uint8  `json:"confirms"`
+	Depth      uint32 `json:"depth"`
+	Label      string `json:"label,omitempty"`
+	Root       bool   `json:"root,omitempty"`
+	Reserved   bool   `json:"reserved,omitempty"`
+	TxidLen    int    `json:"txidLen,omitempty"`
+	Parent     string `json:"parent,omitempty"`
+}
+
+// Implements json.Marshaler, encoding hashes in hex.
+func (n NodeInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(nodeInfoJSON{
+		PubKeyHash: hex.EncodeToString(n.PubKeyHash),
+		Txid:       hex.EncodeToString(n.Txid),
+		Confirms:   n.Confirms,
+		Depth:      n.Depth,
+		Label:      n.Label,
+		Root:       n.Root,
+		Reserved:   n.Reserved,
+		TxidLen:    n.TxidLen,
+		Parent:     hex.EncodeToString(n.Parent),
+	})
+}
+
+// Implements json.Unmarshaler for the encoding of MarshalJSON.
+func (n *NodeInfo) UnmarshalJSON(b []byte) error {
+	var v nodeInfoJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return ErrInvalidJSON
+	}
+
+	pkh, err := hex.DecodeString(v.PubKeyHash)
+	if err != nil {
+		return ErrInvalidJSON
+	}
+	txid, err := hex.DecodeString(v.Txid)
+	if err != nil {
+		return ErrInvalidJSON
+	}
+	var parent []byte
+	if v.Parent != "" {
+		if parent, err = hex.DecodeString(v.Parent); err != nil {
+			return ErrInvalidJSON
+		}
+	}
+
+	*n = NodeInfo{
+		PubKeyHash: pkh,
+		Txid:       txid,
+		Confirms:   v.Confirms,
+		Depth:      v.Depth,
+		Label:      v.Label,
+		Root:       v.Root,
+		Reserved:   v.Reserved,
+		TxidLen:    v.TxidLen,
+		Parent:     parent,
+	}
+	return nil
+}
+
+// The JSON encoding of a public tree and its nodes.
+type pubTreeJSON struct {
+	PublicKey string            `json:"publicKey"`
+	RootUsed  bool              `json:"rootUsed,omitempty"`
+	Nodes     []pubTreeNodeJSON `json:"nodes"`
+}
+
+type pubTreeNodeJSON struct {
+	PubKeyHash string `json:"pubKeyHash"`
+	Parent     string `json:"parent"`
+	Txid       string `json:"txid"`
+	Confirms   uint8  `json:"confirms"`
+	Used       bool   `json:"used,omitempty"`
+}
+
+// Implements json.Marshaler, encoding keys and hashes in hex. Nodes are
+// written in the order of their public key hashes, like Bytes does, which
+// remains the canonical encoding.
+func (pt *NYPubTree) MarshalJSON() ([]byte, error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	v := pubTreeJSON{
+		PublicKey: hex.EncodeToString(pt.rootPubKey),
+		RootUsed:  pt.rootUsed,
+		Nodes:     make([]pubTreeNodeJSON, 0, len(pt.nodes)),
+	}
+	for _, pkh := range pt.sortedHashes() {
+		node := pt.nodes[pkh]
+		v.Nodes = append(v.Nodes, pubTreeNodeJSON{
+			PubKeyHash: hex.EncodeToString(pkh[:]),
+			Parent:     hex.EncodeToString(node.parent[:]),
+			Txid:       hex.EncodeToString(node.txid),
+			Confirms:   node.confirms,
+			Used:       node.used,
+		})
+	}
+
+	return json.Marshal(v)
+}
+
+// Implements json.Unmarshaler for the encoding of MarshalJSON. Returns
+// ErrInvalidPubTree if a node is malformed.
+func (pt *NYPubTree) UnmarshalJSON(b []byte) error {
+	var v pubTreeJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return ErrInvalidJSON
+	}
+
+	rootPubKey, err := hex.DecodeString(v.PublicKey)
+	if err != nil || len(rootPubKey) == 0 {
+		return ErrInvalidPubTree
+	}
+	nodes := make(map[[32]byte]*pubNode, len(v.Nodes))
+	for _, n := range v.Nodes {
+		pkh, err1 := hex.DecodeString(n.PubKeyHash)
+		parent, err2 := hex.DecodeString(n.Parent)
+		txid, err3 := hex.DecodeString(n.Txid)
+		if err1 != nil || err2 != nil || err3 != nil || len(pkh) != 32 || len(parent) != 32 || len(txid) != TxidLen {
+			return ErrInvalidPubTree
+		}
+
+		var key [32]byte
+		copy(key[:], pkh)
+		node := &pubNode{txid: txid, confirms: n.Confirms, used: n.Used}
+		copy(node.parent[:], parent)
+		nodes[key] = node
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.rootPubKey, pt.rootUsed, pt.nodes = rootPubKey, v.RootUsed, nodes
+	return nil
+}
+
+// Returns the hex encodings of the elements of l, or nil if l is empty.
+func hexList(l [][]byte) []string {
+	if len(l) == 0 {
+		return nil
+	}
+
+	encoded := make([]string, len(l))
+	for i, b := range l {
+		encoded[i] = hex.EncodeToString(b)
+	}
+
+	return encoded
+}
+
+// Returns whether the hex encoded list encoded holds the elements of l.
+func equalHexList(encoded []string, l [][]byte) bool {
+	if len(encoded) != len(l) {
+		return false
+	}
+	for i, s := range encoded {
+		b, err := hex.DecodeString(s)
+		if err != nil || !bytes.Equal(b, l[i]) {
+			return false
+		}
+	}
+
+	return true
+}
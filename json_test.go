@@ -0,0 +1,104 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSignature_JSON(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("json test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Signatures round trip, keeping their envelope
+	b, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatal("Failed to marshal signature -", err)
+	}
+	decoded := &Signature{}
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatal("Failed to unmarshal signature -", err)
+	}
+	if !bytes.Equal(decoded.Envelope(), sig.Envelope()) || !bytes.Equal(decoded.Message, sig.Message) {
+		t.Fatal("Invalid unmarshalled signature")
+	}
+
+	// 2 - Child hashes that do not match the envelope are rejected
+	tampered := strings.Replace(string(b), `"childHashes":["`, `"childHashes":["00`, 1)
+	if err := json.Unmarshal([]byte(tampered), decoded); err != ErrInvalidJSON {
+		t.Fatal("Unmarshalled mismatching child hashes, err was", err)
+	}
+}
+
+func TestNodeInfo_JSON(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, _, err := signMessage("json test", tree); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Node descriptions round trip with hex hashes
+	nodes := tree.Nodes()
+	b, err := json.Marshal(nodes)
+	if err != nil {
+		t.Fatal("Failed to marshal nodes -", err)
+	}
+	if !strings.Contains(string(b), `"pubKeyHash":"`) {
+		t.Fatal("Hashes not encoded in hex", string(b))
+	}
+	var decoded []NodeInfo
+	if err := json.Unmarshal(b, &decoded); err != nil || len(decoded) != len(nodes) {
+		t.Fatal("Failed to unmarshal nodes -", err)
+	}
+	for i := range nodes {
+		if !bytes.Equal(decoded[i].PubKeyHash, nodes[i].PubKeyHash) || !bytes.Equal(decoded[i].Txid, nodes[i].Txid) || decoded[i].Depth != nodes[i].Depth {
+			t.Fatal("Invalid unmarshalled node", i)
+		}
+	}
+}
+
+func TestNYPubTree_JSON(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pub := NewPubTree(tree.PublicKey())
+	sig, txid, err := signMessage("json test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Observe(sig, txid); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Public trees round trip to the same canonical encoding
+	b, err := json.Marshal(pub)
+	if err != nil {
+		t.Fatal("Failed to marshal public tree -", err)
+	}
+	decoded := &NYPubTree{}
+	if err := json.Unmarshal(b, decoded); err != nil {
+		t.Fatal("Failed to unmarshal public tree -", err)
+	}
+	if !bytes.Equal(decoded.Bytes(), pub.Bytes()) {
+		t.Fatal("Invalid unmarshalled public tree")
+	}
+
+	// 2 - Malformed nodes are rejected
+	malformed := strings.Replace(string(b), `"parent":"`, `"parent":"00`, 1)
+	if err := json.Unmarshal([]byte(malformed), decoded); err != ErrInvalidPubTree {
+		t.Fatal("Unmarshalled malformed node, err was", err)
+	}
+}
@@ -0,0 +1,301 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrKeyringUnknown   = newError(ErrState, "keyring holds no tree with this long-term key")
+	ErrKeyringDuplicate = newError(ErrState, "keyring already holds a tree with this long-term key")
+	ErrInvalidKeyring   = newError(ErrEncoding, "invalid keyring encoding")
+)
+
+// Version byte of an encoded keyring.
+const keyringVersion = 0x01
+
+// Tags of the fields of an encoded keyring. Every tree field holds the state
+// of one tree, see Bytes.
+const (
+	keyringFieldTree = 0x01
+)
+
+// A set of trees, e.g. the keys of a wallet, indexed by the hash of their
+// long-term public key (computed with the hash suite of the tree, like the
+// public key hashes of nodes). The keyring routes calls to the tree holding a
+// key, confirms the nodes of all its trees at once, and is serialised and
+// encrypted as one unit.
+//
+// Trees must not change their long-term key, e.g. with SetHashSuite or
+// SetWinternitz, while they are held by a keyring.
+type Keyring struct {
+	mu    sync.Mutex
+	trees map[[32]byte]*NYTree
+	// The entropy source of BytesEncrypted, or nil for crypto/rand
+	entropy io.Reader
+}
+
+// Creates an empty keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{trees: make(map[[32]byte]*NYTree)}
+}
+
+// Adds the tree t to the keyring k, returning the hash of its long-term public
+// key. Returns ErrKeyringDuplicate if k already holds a tree with that key,
+// e.g. another instance split off by Backup, and ErrTreeWiped if t was wiped.
+func (k *Keyring) Add(t *NYTree) ([]byte, error) {
+	key, err := t.keyringKey()
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.trees[key]; ok {
+		return nil, ErrKeyringDuplicate
+	}
+	k.trees[key] = t
+
+	return key[:], nil
+}
+
+// Removes the tree whose long-term public key has hash keyHash from the
+// keyring k, returning it. Returns ErrKeyringUnknown if k holds no such tree.
+func (k *Keyring) Remove(keyHash []byte) (*NYTree, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	t, err := k.tree(keyHash)
+	if err != nil {
+		return nil, err
+	}
+	delete(k.trees, keyringKey(keyHash))
+
+	return t, nil
+}
+
+// Returns the tree whose long-term public key has hash keyHash, or
+// ErrKeyringUnknown.
+func (k *Keyring) Tree(keyHash []byte) (*NYTree, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.tree(keyHash)
+}
+
+func (k *Keyring) tree(keyHash []byte) (*NYTree, error) {
+	t, ok := k.trees[keyringKey(keyHash)]
+	if len(keyHash) != 32 || !ok {
+		return nil, ErrKeyringUnknown
+	}
+
+	return t, nil
+}
+
+// Returns the hashes of the long-term public keys of the trees of the keyring
+// k, sorted.
+func (k *Keyring) Keys() [][]byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.keys()
+}
+
+func (k *Keyring) keys() [][]byte {
+	keys := make([][]byte, 0, len(k.trees))
+	for key := range k.trees {
+		keys = append(keys, append([]byte(nil), key[:]...))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	return keys
+}
+
+// Signs msg with the tree whose long-term public key has hash keyHash, see
+// NYTree.Sign. Returns ErrKeyringUnknown if k holds no such tree.
+func (k *Keyring) Sign(keyHash, msg, txid []byte) (*Signature, error) {
+	t, err := k.Tree(keyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Sign(msg, txid)
+}
+
+// Returns the amount of signatures that can be created with the tree whose
+// long-term public key has hash keyHash, see NYTree.Available. Returns
+// ErrKeyringUnknown if k holds no such tree.
+func (k *Keyring) Available(keyHash, txid []byte) (int, error) {
+	t, err := k.Tree(keyHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return t.Available(txid), nil
+}
+
+// Sets the confirmation count of the node with public key hash pkh in whichever
+// tree of the keyring k holds it, see NYTree.Confirm. Returns whether a tree
+// holds the node.
+func (k *Keyring) Confirm(pkh []byte, confirms uint8) bool {
+	return k.ConfirmAll([][]byte{pkh}, confirms) == 1
+}
+
+// Sets the confirmation count of the nodes with the public key hashes pkhs in
+// the trees of the keyring k, e.g. for the public key hashes of the signatures
+// included in a block. Hashes of nodes that k does not hold are ignored.
+// Returns the amount of hashes whose node was found.
+func (k *Keyring) ConfirmAll(pkhs [][]byte, confirms uint8) (n int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, t := range k.trees {
+		n += t.confirmHeld(pkhs, confirms)
+	}
+
+	return
+}
+
+// Confirms the nodes of t among pkhs, returning their amount.
+func (t *NYTree) confirmHeld(pkhs [][]byte, confirms uint8) (n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return 0
+	}
+	for _, pkh := range pkhs {
+		if len(pkh) == 32 && t.nodeByPkh(pkh) != nil {
+			profile(OpConfirm, t, func() {
+				t.confirm(pkh, confirms)
+			})
+			n++
+		}
+	}
+
+	return
+}
+
+// Returns the encoding of the keyring k, holding the states of its trees (see
+// NYTree.Bytes) in the order of their keys. Wiped trees hold no keys, and are
+// left out. Like tree states, it must be stored before the signatures created
+// since the previous encoding are published.
+func (k *Keyring) Bytes() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(keyringVersion)
+	for _, key := range k.keys() {
+		t := k.trees[keyringKey(key)]
+		if t.Wiped() {
+			continue
+		}
+		writeField(buf, keyringFieldTree, t.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a keyring encoded by Keyring.Bytes. Returns ErrKeyringDuplicate if it holds two trees with the same long-term
+// key, and the errors of Load.
+func LoadKeyring(b []byte) (*Keyring, error) {
+	if len(b) < 1 || b[0] != keyringVersion {
+		return nil, ErrInvalidKeyring
+	}
+
+	k := NewKeyring()
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		if tag != keyringFieldTree {
+			return ErrFieldInvalid
+		}
+
+		t, err := Load(value)
+		if err != nil {
+			return err
+		}
+		_, err = k.Add(t)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// Sets the entropy source of the salts and nonces of BytesEncrypted, like
+// NYTree.SetEntropy. If r is nil, crypto/rand is used, which is the default.
+func (k *Keyring) SetEntropy(r io.Reader) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.entropy = r
+}
+
+// Returns the encoding of the keyring k (see Bytes), encrypted like
+// NYTree.BytesEncrypted.
+func (k *Keyring) BytesEncrypted(passphrase []byte) ([]byte, error) {
+	return k.BytesEncryptedWith(passphrase, DefaultEncryptionParams())
+}
+
+// Like BytesEncrypted, deriving the key using the parameters p, see
+// NYTree.BytesEncryptedWith.
+func (k *Keyring) BytesEncryptedWith(passphrase []byte, p KDFParams) ([]byte, error) {
+	random := make([]byte, saltLen+gcmNonceLen)
+	k.mu.Lock()
+	r := k.entropy
+	if r == nil {
+		r = rand.Reader
+	}
+	_, err := io.ReadFull(r, random)
+	k.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := k.Bytes()
+	defer wipeBytes(plaintext)
+
+	return encryptState(plaintext, passphrase, p, bytes.NewReader(random))
+}
+
+// Decrypts and loads a keyring encrypted by Keyring.BytesEncrypted. Returns
+// ErrDecryptionFailed if the passphrase is wrong or the encrypted keyring was
+// modified, and the errors of LoadKeyring.
+func LoadKeyringEncrypted(b, passphrase []byte) (*Keyring, error) {
+	plaintext, err := decryptState(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	defer wipeBytes(plaintext)
+
+	return LoadKeyring(plaintext)
+}
+
+// Returns the hash of the long-term public key of t.
+func (t *NYTree) keyringKey() (key [32]byte, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pubKey := t.publicKey()
+	if pubKey == nil {
+		return key, ErrTreeWiped
+	}
+	copy(key[:], t.suite.sum(pubKey))
+
+	return key, nil
+}
+
+func keyringKey(keyHash []byte) (key [32]byte) {
+	copy(key[:], keyHash)
+	return
+}
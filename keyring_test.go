@@ -0,0 +1,126 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	k := NewKeyring()
+	var trees []*NYTree
+	var keys [][]byte
+	for i := 0; i < 2; i++ {
+		seed, pubSeed, err := genSeeds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := New(seed, pubSeed, false)
+		key, err := k.Add(tree)
+		if err != nil {
+			t.Fatal("Failed to add tree -", err)
+		}
+		trees, keys = append(trees, tree), append(keys, key)
+	}
+
+	// 1 - Trees are indexed by the hash of their long-term key
+	pkh := sha256.Sum256(trees[0].PublicKey())
+	if tree, err := k.Tree(pkh[:]); err != nil || tree != trees[0] {
+		t.Fatal("Failed to look up tree by key hash -", err)
+	}
+	if len(k.Keys()) != 2 {
+		t.Fatal("Invalid amount of keys", len(k.Keys()))
+	}
+	if _, err := k.Add(trees[1]); err != ErrKeyringDuplicate {
+		t.Fatal("Added duplicate tree, err was", err)
+	}
+
+	// 2 - Signing is routed to the tree holding the key
+	msg := sha256.Sum256([]byte("keyring test"))
+	var childHashes [][]byte
+	for i, key := range keys {
+		sig, err := k.Sign(key, msg[:], bytes.Repeat([]byte{byte(i + 1)}, TxidLen))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if ok, err := Verify(trees[i].PublicKey(), sig, msg[:]); !ok {
+			t.Fatal("Signature not created by the tree of the key -", err)
+		}
+		childHashes = append(childHashes, sig.ChildHashes...)
+	}
+	if n, err := k.Available(keys[0], nil); err != nil || n != 0 {
+		t.Fatal("Unconfirmed nodes available", n, err)
+	}
+
+	// 3 - One block's worth of public key hashes confirms nodes of all trees,
+	// ignoring unknown hashes
+	block := append(childHashes, make([]byte, 32))
+	if n := k.ConfirmAll(block, ConfirmsRequired); n != len(childHashes) {
+		t.Fatal("Invalid amount of confirmed nodes", n)
+	}
+	for _, key := range keys {
+		if n, err := k.Available(key, nil); err != nil || n == 0 {
+			t.Fatal("Confirmed nodes not available", n, err)
+		}
+	}
+
+	// 4 - Unknown keys are rejected
+	if _, err := k.Sign(make([]byte, 32), msg[:], nil); err != ErrKeyringUnknown {
+		t.Fatal("Signed with unknown key, err was", err)
+	}
+	if _, err := k.Available(keys[0][:16], nil); err != ErrKeyringUnknown {
+		t.Fatal("Looked up truncated key, err was", err)
+	}
+}
+
+func TestKeyring_Bytes(t *testing.T) {
+	k := NewKeyring()
+	for i := 0; i < 2; i++ {
+		seed, pubSeed, err := genSeeds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := k.Add(New(seed, pubSeed, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 - Keyrings round trip with all their trees
+	loaded, err := LoadKeyring(k.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load keyring -", err)
+	}
+	for _, key := range k.Keys() {
+		tree, err := loaded.Tree(key)
+		if err != nil {
+			t.Fatal("Loaded keyring lacks tree -", err)
+		}
+		orig, _ := k.Tree(key)
+		if !bytes.Equal(tree.Bytes(), orig.Bytes()) {
+			t.Fatal("Loaded tree differs")
+		}
+	}
+
+	// 2 - Encrypted keyrings load with the passphrase only
+	passphrase := []byte("correct horse battery staple")
+	encrypted, err := k.BytesEncryptedWith(passphrase, testKDFParams)
+	if err != nil {
+		t.Fatal("Failed to encrypt keyring -", err)
+	}
+	if loaded, err := LoadKeyringEncrypted(encrypted, passphrase); err != nil || len(loaded.Keys()) != 2 {
+		t.Fatal("Failed to load encrypted keyring -", err)
+	}
+	if _, err := LoadKeyringEncrypted(encrypted, []byte("wrong")); err != ErrDecryptionFailed {
+		t.Fatal("Loaded with wrong passphrase, err was", err)
+	}
+
+	// 3 - Removed and wiped trees are left out
+	removed, err := k.Remove(k.Keys()[0])
+	if err != nil {
+		t.Fatal("Failed to remove tree -", err)
+	}
+	removed.Wipe()
+	if loaded, err := LoadKeyring(k.Bytes()); err != nil || len(loaded.Keys()) != 1 {
+		t.Fatal("Invalid keyring after removal -", err)
+	}
+}
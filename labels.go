@@ -0,0 +1,62 @@
+package xnyss
+
+// Signs the message like Sign, but only uses nodes that belong to the subtree
+// labelled label (an empty label denotes unlabelled nodes). The child nodes are
+// labelled childLabel, allowing a signature to create capacity for a different
+// operational pool, e.g. "cold refill" nodes created by a "hot wallet" node.
+func (t *NYTree) SignLabel(msg, txid []byte, label, childLabel string) (sig *Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	opts := &signOptions{
+		filter: func(n *nyNode) bool {
+			return n.label == label
+		},
+		childLabel: &childLabel,
+	}
+
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, opts)
+	})
+
+	return
+}
+
+// Labels the node with the given public key hash, and thereby the subtree that
+// grows from it (child nodes inherit the label of their parent). Returns false
+// if no such node exists.
+func (t *NYTree) SetLabel(pkh []byte, label string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node := t.nodeByPkh(pkh)
+	if node == nil {
+		return false
+	}
+
+	if node.label != label {
+		node.label = label
+		t.epoch++
+	}
+
+	return true
+}
+
+// Returns the amount of signatures that can be created per label, see
+// Available. Unlabelled nodes are counted under the empty label.
+func (t *NYTree) AvailableByLabel(txid []byte) map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.reapReservations()
+
+	txid = t.lookupTxid(txid)
+	available := make(map[string]int)
+	for _, node := range t.nodes {
+		if t.canSign(node, txid) {
+			available[node.label]++
+		}
+	}
+
+	return available
+}
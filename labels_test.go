@@ -0,0 +1,61 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_SignLabel(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	txid := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+	// 1 - The root is unlabelled, its children go to the "hot" pool
+	sig, err := tree.SignLabel(msg, txid(1), "", "hot")
+	if err != nil {
+		t.Fatal("Failed to sign with root -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if available := tree.AvailableByLabel(nil); available["hot"] != Branches || len(available) != 1 {
+		t.Fatal("Invalid capacity per label", available)
+	}
+
+	// 2 - Label filters are respected, and children inherit labels
+	if _, err := tree.SignLabel(msg, txid(2), "cold", "cold"); err != ErrTreeNoneAvailable {
+		t.Fatal("Signed with a node from the wrong pool, err was", err)
+	}
+
+	if !tree.SetLabel(sig.ChildHashes[0], "cold") {
+		t.Fatal("Failed to label node")
+	}
+	coldSig, err := tree.SignLabel(msg, txid(3), "cold", "cold")
+	if err != nil {
+		t.Fatal("Failed to sign with cold node -", err)
+	}
+	for _, pkh := range coldSig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	if _, err := tree.Sign(msg, txid(4)); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if tree.nodes[len(tree.nodes)-1].label != "hot" {
+		t.Fatal("Children did not inherit their parent's label")
+	}
+
+	// 3 - Labels survive serialisation
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load labelled tree -", err)
+	}
+	available := loaded.AvailableByLabel(nil)
+	if available["cold"] != Branches || available["hot"] != Branches-2 {
+		t.Fatal("Invalid capacity per label after loading", available)
+	}
+}
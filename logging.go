@@ -0,0 +1,40 @@
+package xnyss
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+// Receives log records at the decision points of a tree. *slog.Logger
+// implements Logger.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// Levels at which records are logged. Selecting nodes is logged at
+// LevelDecision, changes of the signing state (consuming or confirming nodes,
+// creating backups) at LevelAudit, so forensic logging only needs the latter.
+const (
+	LevelDecision = slog.LevelDebug
+	LevelAudit    = slog.LevelInfo
+)
+
+// When not nil, Log receives a record whenever a tree selects a node to sign,
+// consumes a node, applies a confirmation or creates a backup. Records include
+// the tree ID and public key hashes, but never secret data. Logging is off by
+// default.
+var Log Logger
+
+func (t *NYTree) log(level slog.Level, msg string, args ...any) {
+	if Log == nil {
+		return
+	}
+
+	Log.Log(context.Background(), level, msg, append([]any{"tree", t.ID()}, args...)...)
+}
+
+// Returns a log attribute holding a hex encoded public key hash.
+func pkhAttr(pkh []byte) slog.Attr {
+	return slog.String("pkh", hex.EncodeToString(pkh))
+}
@@ -0,0 +1,45 @@
+package xnyss
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	Log = slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: LevelAudit}))
+	defer func() { Log = nil }()
+
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	tree.Confirm(sig.ChildHashes[1], ConfirmsRequired)
+	if _, err := tree.Backup(1); err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+
+	// 1 - Audit records are logged, decisions are below the level
+	out := buf.String()
+	for _, msg := range []string{"node consumed", "confirm applied", "backup created"} {
+		if !strings.Contains(out, msg) {
+			t.Fatal("Missing record", msg, "in", out)
+		}
+	}
+	if strings.Contains(out, "node selected") || !strings.Contains(out, "tree="+tree.ID()) {
+		t.Fatal("Invalid records", out)
+	}
+	if strings.Contains(out, string(seed)) {
+		t.Fatal("Secret data was logged")
+	}
+}
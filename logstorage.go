@@ -0,0 +1,366 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrLogStorageCorrupt = newError(ErrEncoding, "storage log is corrupt")
+	ErrLogStorageClosed  = newError(ErrState, "storage log was closed")
+)
+
+// Magic and version of a storage log.
+const (
+	logStorageMagic   = "XNYL"
+	logStorageVersion = 0x01
+)
+
+// Tags of the entries of a storage log. Put entries hold pkh || node, delete
+// entries the pkh.
+const (
+	logEntryHeader = 0x01
+	logEntryPut    = 0x02
+	logEntryDelete = 0x03
+
+	// Length of the checksum following every entry
+	logChecksumLen = 8
+)
+
+// Length of the magic and version at the start of a storage log.
+const logStorageHeaderLen = len(logStorageMagic) + 1
+
+// The minimum size of a storage log before it is compacted automatically.
+const logStorageCompactMin = 1 << 20
+
+// A Storage keeping the state in a single append-only file, for long-running
+// processes that write many small changes, e.g. validators persisting the
+// public trees of the keys they follow (see NYPubTree.SetStorage): every
+// change appends one entry and syncs the file, where FileStorage replaces a
+// file and syncs the directory. The stored state is kept in memory as well, so
+// reading it does not touch the file.
+//
+// Every entry is followed by a checksum. An incomplete or mismatching last
+// entry is the trace of a write interrupted by a crash, and is cut off when
+// the log is opened, since the change it held was never reported as durable. A
+// mismatching entry before it means the file was damaged, and OpenLogStorage
+// returns ErrLogStorageCorrupt.
+//
+// Entries superseded by later ones are removed by Compact, which is done
+// automatically once they take up more than half of a log of at least 1 MiB.
+type LogStorage struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	// The size of the log file, and of the entries holding the current state
+	size, live int64
+	header     []byte
+	nodes      map[[32]byte][]byte
+}
+
+// Opens the storage log at path, creating it if it does not exist.
+func OpenLogStorage(path string) (*LogStorage, error) {
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	ls := &LogStorage{path: path, live: int64(logStorageHeaderLen), nodes: make(map[[32]byte][]byte)}
+	if len(b) == 0 {
+		if err := ls.rewrite(); err != nil {
+			return nil, err
+		}
+		return ls, nil
+	}
+
+	valid, err := ls.replay(b)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if valid < len(b) {
+		// Cut off the interrupted write
+		if err := f.Truncate(int64(valid)); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(int64(valid), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	ls.f, ls.size = f, int64(valid)
+
+	return ls, nil
+}
+
+// Applies the entries of the log b to ls, and returns the length of the
+// prefix of b holding complete entries with matching checksums.
+func (ls *LogStorage) replay(b []byte) (int, error) {
+	if len(b) < logStorageHeaderLen || string(b[:len(logStorageMagic)]) != logStorageMagic ||
+		b[len(logStorageMagic)] != logStorageVersion {
+		return 0, ErrLogStorageCorrupt
+	}
+
+	offset := logStorageHeaderLen
+	for offset < len(b) {
+		n, complete := logEntryLen(b[offset:])
+		if !complete {
+			return offset, nil
+		}
+		entry := b[offset : offset+n-logChecksumLen]
+		sum := sha256.Sum256(entry)
+		if !bytes.Equal(sum[:logChecksumLen], b[offset+n-logChecksumLen:offset+n]) {
+			if offset+n == len(b) {
+				return offset, nil
+			}
+			return 0, ErrLogStorageCorrupt
+		}
+		if err := ls.apply(entry[0], entry[5:]); err != nil {
+			return 0, err
+		}
+		offset += n
+	}
+
+	return offset, nil
+}
+
+// Returns the length of the entry at the start of b, including its checksum,
+// and whether b holds all of it.
+func logEntryLen(b []byte) (int, bool) {
+	if len(b) < 5 {
+		return 0, false
+	}
+	length := uint64(binary.BigEndian.Uint32(b[1:]))
+	if length > uint64(len(b)) {
+		return 0, false
+	}
+	n := 5 + int(length) + logChecksumLen
+
+	return n, n <= len(b)
+}
+
+// Applies the entry with tag and value to the state held in memory.
+func (ls *LogStorage) apply(tag byte, value []byte) error {
+	switch tag {
+	case logEntryHeader:
+		if ls.header != nil {
+			ls.live -= logEntrySize(len(ls.header))
+		}
+		ls.header = append([]byte(nil), value...)
+		ls.live += logEntrySize(len(value))
+	case logEntryPut:
+		if len(value) < 32 {
+			return ErrLogStorageCorrupt
+		}
+		var key [32]byte
+		copy(key[:], value)
+		if node, ok := ls.nodes[key]; ok {
+			ls.live -= logEntrySize(32 + len(node))
+		}
+		ls.nodes[key] = append([]byte(nil), value[32:]...)
+		ls.live += logEntrySize(len(value))
+	case logEntryDelete:
+		if len(value) != 32 {
+			return ErrLogStorageCorrupt
+		}
+		var key [32]byte
+		copy(key[:], value)
+		if node, ok := ls.nodes[key]; ok {
+			ls.live -= logEntrySize(32 + len(node))
+		}
+		delete(ls.nodes, key)
+	default:
+		return ErrLogStorageCorrupt
+	}
+
+	return nil
+}
+
+// Returns the encoding of the entry with tag and value, followed by its
+// checksum.
+func logEntry(tag byte, value []byte) []byte {
+	buf := &bytes.Buffer{}
+	writeField(buf, tag, value)
+	sum := sha256.Sum256(buf.Bytes())
+	buf.Write(sum[:logChecksumLen])
+
+	return buf.Bytes()
+}
+
+// Returns the size of an entry holding a value of n bytes.
+func logEntrySize(n int) int64 {
+	return int64(5 + n + logChecksumLen)
+}
+
+func (ls *LogStorage) Header() ([]byte, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.header == nil {
+		return nil, nil
+	}
+
+	return append([]byte(nil), ls.header...), nil
+}
+
+func (ls *LogStorage) PutHeader(header []byte) error {
+	return ls.append(logEntryHeader, header)
+}
+
+func (ls *LogStorage) PutNode(pkh, node []byte) error {
+	return ls.append(logEntryPut, append(append(make([]byte, 0, 32+len(node)), pkh[:32]...), node...))
+}
+
+func (ls *LogStorage) DeleteNode(pkh []byte) error {
+	return ls.append(logEntryDelete, pkh[:32])
+}
+
+func (ls *LogStorage) ForEachNode(f func(pkh, node []byte) error) error {
+	ls.mu.Lock()
+	records := make(map[[32]byte][]byte, len(ls.nodes))
+	for pkh, node := range ls.nodes {
+		records[pkh] = append([]byte(nil), node...)
+	}
+	ls.mu.Unlock()
+
+	for pkh, node := range records {
+		if err := f(append([]byte(nil), pkh[:]...), node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Appends the entry with tag and value to the log and syncs it, then applies
+// it to the state held in memory, compacting the log if needed.
+func (ls *LogStorage) append(tag byte, value []byte) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.f == nil {
+		return ErrLogStorageClosed
+	}
+
+	// A failed write is cut off, so the entries appended after it do not
+	// follow a damaged one
+	entry := logEntry(tag, value)
+	if _, err := ls.f.Write(entry); err != nil {
+		ls.truncate()
+		return err
+	}
+	if err := ls.f.Sync(); err != nil {
+		ls.truncate()
+		return err
+	}
+	ls.size += int64(len(entry))
+	if err := ls.apply(tag, value); err != nil {
+		return err
+	}
+
+	if ls.size >= logStorageCompactMin && ls.size > 2*ls.live {
+		return ls.rewrite()
+	}
+
+	return nil
+}
+
+// Cuts the log file off after the last entry that was written completely.
+func (ls *LogStorage) truncate() {
+	if ls.f.Truncate(ls.size) == nil {
+		ls.f.Seek(ls.size, 0)
+	}
+}
+
+// Rewrites the log so it only holds the entries needed for the current state,
+// dropping superseded ones.
+func (ls *LogStorage) Compact() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.f == nil {
+		return ErrLogStorageClosed
+	}
+
+	return ls.rewrite()
+}
+
+// Returns the size of the log file, and the size it has after Compact.
+func (ls *LogStorage) Size() (size, live int64) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return ls.size, ls.live
+}
+
+// Replaces the log file atomically by one holding the state held in memory,
+// written to a temporary file that is renamed.
+func (ls *LogStorage) rewrite() error {
+	buf := &bytes.Buffer{}
+	buf.WriteString(logStorageMagic)
+	buf.WriteByte(logStorageVersion)
+	if ls.header != nil {
+		buf.Write(logEntry(logEntryHeader, ls.header))
+	}
+	for pkh, node := range ls.nodes {
+		buf.Write(logEntry(logEntryPut, append(append([]byte(nil), pkh[:]...), node...)))
+	}
+
+	dir := filepath.Dir(ls.path)
+	tmp, err := os.CreateTemp(dir, storageTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmp.Name(), ls.path); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := (&FileStorage{dir: dir}).syncDir(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if ls.f != nil {
+		ls.f.Close()
+	}
+	ls.f, ls.size = tmp, int64(buf.Len())
+
+	return nil
+}
+
+// Closes the log file. The storage can not be used afterwards.
+func (ls *LogStorage) Close() error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if ls.f == nil {
+		return ErrLogStorageClosed
+	}
+	err := ls.f.Close()
+	ls.f = nil
+
+	return err
+}
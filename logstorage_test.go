@@ -0,0 +1,98 @@
+package xnyss
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogStorage(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tree.log")
+	storage, err := OpenLogStorage(path)
+	if err != nil {
+		t.Fatal("Failed to create storage -", err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetStorage(storage); err != nil {
+		t.Fatal("Failed to set storage -", err)
+	}
+	for i := 0; i < 3; i++ {
+		sig, _, err := signMessage("log storage test", tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+	}
+
+	// 1 - The tree is loaded from the log
+	storage.Close()
+	storage, err = OpenLogStorage(path)
+	if err != nil {
+		t.Fatal("Failed to open storage -", err)
+	}
+	loaded, err := LoadStorage(storage)
+	if err != nil || !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Stored state differs from the tree -", err)
+	}
+
+	// 2 - Compaction drops superseded entries and keeps the state
+	size, live := storage.Size()
+	if size <= live {
+		t.Fatal("Log holds no superseded entries", size, live)
+	}
+	if err := storage.Compact(); err != nil {
+		t.Fatal("Failed to compact -", err)
+	}
+	if size, _ = storage.Size(); size != live {
+		t.Fatal("Compacted log has size", size, "expected", live)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != live {
+		t.Fatal("Log file was not compacted -", err)
+	}
+	if _, _, err := signMessage("log storage test", loaded); err != nil {
+		t.Fatal("Failed to sign after compaction -", err)
+	}
+	storage.Close()
+	storage, err = OpenLogStorage(path)
+	if err != nil {
+		t.Fatal("Failed to open compacted storage -", err)
+	}
+	if reloaded, err := LoadStorage(storage); err != nil || !bytes.Equal(reloaded.Bytes(), loaded.Bytes()) {
+		t.Fatal("Compacted state differs from the tree -", err)
+	}
+	storage.Close()
+
+	// 3 - An interrupted write is cut off
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torn := append(append([]byte(nil), b...), logEntry(logEntryDelete, make([]byte, 32))[:20]...)
+	if err := os.WriteFile(path, torn, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	storage, err = OpenLogStorage(path)
+	if err != nil {
+		t.Fatal("Failed to open log with interrupted write -", err)
+	}
+	storage.Close()
+	if info, err := os.Stat(path); err != nil || info.Size() != int64(len(b)) {
+		t.Fatal("Interrupted write was not cut off -", err)
+	}
+
+	// 4 - Damaged entries are detected
+	b[logStorageHeaderLen+10] ^= 1
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenLogStorage(path); err != ErrLogStorageCorrupt {
+		t.Fatal("Opened damaged log, err was", err)
+	}
+}
@@ -0,0 +1,175 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+)
+
+var (
+	ErrMappedCompact = newError(ErrEncoding, "nodes of compact states are not node records and can not be mapped")
+	ErrMappedClosed  = newError(ErrState, "mapped state was closed")
+)
+
+// Read-only access to the nodes of a serialised tree in a file, for analytics
+// over huge archived trees. Unlike Load and ReadFrom, which decode the whole
+// state, the file is memory-mapped where supported and node records are only
+// parsed when they are accessed, so the operating system pages in just the
+// parts of the file that are used. Opening a state scans the lengths of its node
+// frames, and keeps one offset per node.
+//
+// Node and ForEach may be called concurrently, but not concurrently with Close.
+// The file must not be changed while it is mapped.
+type MappedState struct {
+	data   []byte
+	unmap  func() error
+	ots    bool
+	frames []int
+}
+
+// Maps the state in the file at path, which must be in the current format and
+// not compact (see SetCompact). The checksum of the state is not verified, see
+// Verify.
+func OpenMapped(path string) (*MappedState, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < int64(stateHeaderLen+sha256.Size) {
+		return nil, ErrTreeInvalidInput
+	}
+
+	data, unmap, err := mapFile(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := newMappedState(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	m.unmap = unmap
+
+	return m, nil
+}
+
+// Indexes the node frames of the serialised tree data.
+func newMappedState(data []byte) (*MappedState, error) {
+	if !bytes.HasPrefix(data, []byte(stateMagic)) {
+		return nil, ErrTreeVersion
+	}
+	if len(data) < stateHeaderLen+sha256.Size {
+		return nil, ErrTreeInvalidInput
+	}
+	body := data[:len(data)-sha256.Size]
+
+	_, flags, err := loadHead(body[:stateHeaderLen], 0)
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagCompact != 0 {
+		return nil, ErrMappedCompact
+	}
+
+	m := &MappedState{data: data, ots: flags&flagOTS != 0}
+	offset := stateHeaderLen
+	if flags&flagExtended != 0 {
+		_, n, err := readFrame(body[offset:])
+		if err != nil {
+			return nil, err
+		}
+		offset += n
+	}
+
+	for offset < len(body) {
+		_, n, err := readFrame(body[offset:])
+		if err != nil {
+			return nil, err
+		}
+		m.frames = append(m.frames, offset)
+		offset += n
+	}
+
+	return m, nil
+}
+
+// Returns the amount of nodes of the state.
+func (m *MappedState) Len() int {
+	return len(m.frames)
+}
+
+// Returns whether the state is of a one-time signature tree.
+func (m *MappedState) OTS() bool {
+	return m.ots
+}
+
+// Parses the node with index i, in the order the nodes are stored (see Bytes),
+// which is sorted by public key hash. Returns ErrMappedClosed if m was closed.
+func (m *MappedState) Node(i int) (*NodeRecord, error) {
+	if m.data == nil {
+		return nil, ErrMappedClosed
+	}
+	if i < 0 || i >= len(m.frames) {
+		return nil, ErrNodeInvalidInput
+	}
+
+	frame, _, err := readFrame(m.data[m.frames[i]:])
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseNodeRecord(frame)
+}
+
+// Calls fn for every node of the state in order, stopping at the first error,
+// which is returned.
+func (m *MappedState) ForEach(fn func(i int, r *NodeRecord) error) error {
+	for i := range m.frames {
+		r, err := m.Node(i)
+		if err != nil {
+			return err
+		}
+		if err := fn(i, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Verifies the checksum of the state, which reads the whole file. Returns
+// ErrTreeChecksum if it does not match. Header fields are not checked, see
+// Load.
+func (m *MappedState) Verify() error {
+	if m.data == nil {
+		return ErrMappedClosed
+	}
+
+	body := m.data[:len(m.data)-sha256.Size]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], m.data[len(body):]) {
+		return ErrTreeChecksum
+	}
+
+	return nil
+}
+
+// Unmaps the state. Records returned by m must not be used afterwards.
+func (m *MappedState) Close() error {
+	if m.data == nil {
+		return nil
+	}
+
+	m.data = nil
+	if m.unmap == nil {
+		return nil
+	}
+
+	return m.unmap()
+}
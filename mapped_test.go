@@ -0,0 +1,86 @@
+package xnyss
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("mapped test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.SetLabel(sig.ChildHashes[0], "mapped")
+	state := tree.Bytes()
+
+	path := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(path, state, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Node records are read from the mapped file
+	m, err := OpenMapped(path)
+	if err != nil {
+		t.Fatal("Failed to map state -", err)
+	}
+	if m.Len() != len(sig.ChildHashes) || m.OTS() {
+		t.Fatal("Invalid mapped state")
+	}
+	labels := 0
+	err = m.ForEach(func(i int, r *NodeRecord) error {
+		b, err := r.Bytes()
+		if err != nil || !bytes.Contains(state, b) {
+			t.Fatal("Record", i, "does not match the state")
+		}
+		if r.Label == "mapped" {
+			labels++
+		}
+		return nil
+	})
+	if err != nil || labels != 1 {
+		t.Fatal("Failed to read records -", err)
+	}
+	if err := m.Verify(); err != nil {
+		t.Fatal("Failed to verify state -", err)
+	}
+
+	// 2 - Closed states can not be read
+	if err := m.Close(); err != nil {
+		t.Fatal("Failed to close -", err)
+	}
+	if _, err := m.Node(0); err != ErrMappedClosed {
+		t.Fatal("Read closed state, err was", err)
+	}
+
+	// 3 - Corrupted states fail to verify
+	state[len(state)-1] ^= 1
+	if err := os.WriteFile(path, state, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = OpenMapped(path); err != nil {
+		t.Fatal("Failed to map state -", err)
+	}
+	defer m.Close()
+	if err := m.Verify(); err != ErrTreeChecksum {
+		t.Fatal("Verified corrupted state, err was", err)
+	}
+
+	// 4 - Compact states are rejected
+	tree = NewDeterministic(seed, pubSeed, false)
+	if err := tree.SetCompact(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, tree.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenMapped(path); err != ErrMappedCompact {
+		t.Fatal("Mapped compact state, err was", err)
+	}
+}
@@ -0,0 +1,130 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// The version of the self-describing signature encoding created by
+// Signature.MarshalBinary. The raw layout of Signature.Bytes, which has no
+// version byte, counts as version 1.
+const SignatureEncodingVersion = 0x02
+
+// Flags of the self-describing signature encoding.
+const (
+	// The encoding holds the message of the signature
+	sigFlagMessage = 0x01
+
+	sigFlagsKnown = sigFlagMessage
+)
+
+var (
+	ErrSigEncodingFlags = newError(ErrEncoding, "signature encoding has unknown flags")
+	ErrSigChildCount    = newError(ErrEncoding, "signature child hash count does not match its encoding")
+)
+
+// Implements encoding.BinaryMarshaler with a self-describing encoding: the
+// version byte SignatureEncodingVersion, a flags byte, the amount of child
+// hashes as a 2 byte big endian integer, the message if it is set, and the
+// envelope of the signature (see Envelope). Unlike Bytes, the encoding tells
+// one-time signatures and signatures with children apart without relying on
+// length arithmetic, and decodes without knowing the message.
+func (sig *Signature) MarshalBinary() ([]byte, error) {
+	if len(sig.ChildHashes) > math.MaxUint16 {
+		return nil, ErrSigTooManyChildren
+	}
+
+	var flags byte
+	if len(sig.Message) != 0 {
+		if len(sig.Message) != MsgLen {
+			return nil, ErrInvalidMsgLen
+		}
+		flags |= sigFlagMessage
+	}
+
+	var count [2]byte
+	binary.BigEndian.PutUint16(count[:], uint16(len(sig.ChildHashes)))
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(SignatureEncodingVersion)
+	buf.WriteByte(flags)
+	buf.Write(count[:])
+	if flags&sigFlagMessage != 0 {
+		buf.Write(sig.Message)
+	}
+	buf.Write(sig.Envelope())
+
+	return buf.Bytes(), nil
+}
+
+// Implements encoding.BinaryUnmarshaler for the encoding of MarshalBinary.
+// Returns ErrSigEncodingFlags if flags unknown to this package are set,
+// ErrSigChildCount if the child hash count differs from the amount of child
+// hashes in the envelope, and the errors of ParseEnvelope.
+//
+// For compatibility, b may also hold the raw layout of Bytes. Its message is
+// not set then, so it must be set before the signature is verified. Since the
+// raw layout starts with the WOTS+ signature, it may start with the version
+// byte by chance: such encodings are decoded as raw signatures if they are not
+// valid self-describing encodings.
+func (sig *Signature) UnmarshalBinary(b []byte) error {
+	var decoded *Signature
+	var err error
+	if len(b) > 0 && b[0] == SignatureEncodingVersion {
+		decoded, err = unmarshalSignature(b[1:])
+	}
+
+	if decoded == nil {
+		raw, rawErr := newSignature(0, b, nil)
+		if rawErr != nil {
+			if err == nil {
+				err = rawErr
+			}
+			return err
+		}
+		raw.Message = nil
+		decoded = raw
+	}
+
+	*sig = *decoded
+	return nil
+}
+
+// Decodes the self-describing encoding b of a signature, without its version
+// byte.
+func unmarshalSignature(b []byte) (*Signature, error) {
+	if len(b) < 3 {
+		return nil, ErrInvalidSigEncoding
+	}
+	flags := b[0]
+	if flags&^sigFlagsKnown != 0 {
+		return nil, ErrSigEncodingFlags
+	}
+	count := int(binary.BigEndian.Uint16(b[1:]))
+	if MaxChildHashes > 0 && count > MaxChildHashes {
+		return nil, ErrSigTooManyChildren
+	}
+	b = b[3:]
+
+	var msg []byte
+	if flags&sigFlagMessage != 0 {
+		if len(b) < MsgLen {
+			return nil, ErrInvalidSigEncoding
+		}
+		msg, b = b[:MsgLen], b[MsgLen:]
+	}
+
+	sig, err := ParseEnvelope(b, msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig.ChildHashes) != count {
+		return nil, ErrSigChildCount
+	}
+	if msg == nil {
+		sig.Message = nil
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,92 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSignature_MarshalBinary(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("marshal test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := sig.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Signatures round trip with their message and parameters
+	b, err := sig.MarshalBinary()
+	if err != nil || b[0] != SignatureEncodingVersion {
+		t.Fatal("Failed to marshal signature -", err)
+	}
+	decoded := &Signature{}
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal signature -", err)
+	}
+	if !bytes.Equal(decoded.Message, sig.Message) || len(decoded.ChildHashes) != len(sig.ChildHashes) {
+		t.Fatal("Invalid unmarshalled signature")
+	}
+	if _, ok := decoded.Params(); !ok {
+		t.Fatal("Parameters not unmarshalled")
+	}
+	if decodedPK, err := decoded.PublicKey(); err != nil || !bytes.Equal(decodedPK, pk) {
+		t.Fatal("Unmarshalled signature does not verify -", err)
+	}
+
+	// 2 - One-time signatures are told apart by their child count
+	ots := New(seed, pubSeed, true)
+	otsSig, _, err := signMessage("marshal test", ots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = otsSig.MarshalBinary(); err != nil {
+		t.Fatal("Failed to marshal signature -", err)
+	}
+	if err := decoded.UnmarshalBinary(b); err != nil || len(decoded.ChildHashes) != 0 {
+		t.Fatal("Failed to unmarshal one-time signature -", err)
+	}
+
+	// 3 - Signatures without message decode without one
+	noMsg := *sig
+	noMsg.Message = nil
+	if b, err = noMsg.MarshalBinary(); err != nil {
+		t.Fatal("Failed to marshal signature -", err)
+	}
+	if err := decoded.UnmarshalBinary(b); err != nil || decoded.Message != nil {
+		t.Fatal("Failed to unmarshal signature without message -", err)
+	}
+	if _, err := decoded.PublicKey(); err != ErrSigMsgNotSet {
+		t.Fatal("Verified signature without message, err was", err)
+	}
+
+	// 4 - The raw layout of Bytes is still accepted
+	if err := decoded.UnmarshalBinary(sig.Bytes()); err != nil || decoded.Message != nil || len(decoded.ChildHashes) != len(sig.ChildHashes) {
+		t.Fatal("Failed to unmarshal raw signature -", err)
+	}
+	decoded.Message = sig.Message
+	if decodedPK, err := decoded.PublicKey(); err != nil || !bytes.Equal(decodedPK, pk) {
+		t.Fatal("Raw signature does not verify -", err)
+	}
+
+	// 5 - Invalid encodings are rejected
+	b, _ = sig.MarshalBinary()
+	badFlags := append([]byte(nil), b...)
+	badFlags[1] |= 0x80
+	if err := decoded.UnmarshalBinary(badFlags); err != ErrSigEncodingFlags {
+		t.Fatal("Unmarshalled unknown flags, err was", err)
+	}
+	badCount := append([]byte(nil), b...)
+	badCount[3]++
+	if err := decoded.UnmarshalBinary(badCount); err != ErrSigChildCount {
+		t.Fatal("Unmarshalled wrong child count, err was", err)
+	}
+	if err := decoded.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Fatal("Unmarshalled truncated signature")
+	}
+}
@@ -0,0 +1,53 @@
+package xnyss
+
+var ErrMemoryLockUnsupported = newError(ErrState, "locking memory is not supported on this platform")
+
+// Locks the seed buffers of the tree t into memory, so the operating system
+// does not write them to swap, on platforms that support it (mlock on unix).
+// The root seed and the seeds of the current nodes are locked immediately,
+// and the seeds of the nodes created by later signatures as they are created.
+// Locking is best effort: pages are locked as a whole, stay locked after the
+// seeds on them are wiped, and count against the limit on locked memory of the
+// process (RLIMIT_MEMLOCK), beyond which only the seeds locked so far remain
+// locked. Loaded states and backups do not lock their seeds until
+// LockMemory is called on them.
+//
+// Returns ErrMemoryLockUnsupported on other platforms, and the error of the
+// operating system if the current seeds could not be locked.
+func (t *NYTree) LockMemory() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return ErrTreeWiped
+	}
+
+	bufs := [][]byte{t.rootSeed, t.rootMask}
+	for _, node := range t.nodes {
+		bufs = append(bufs, node.privSeed)
+	}
+	if t.revocationNode != nil {
+		bufs = append(bufs, t.revocationNode.privSeed)
+	}
+	for _, b := range bufs {
+		if err := lockMemory(b); err != nil {
+			return err
+		}
+	}
+	t.lockSeeds = true
+
+	return nil
+}
+
+// Locks the seeds of the child nodes created by a signature of t, if t locks
+// its seeds, see LockMemory.
+func (t *NYTree) lockChildSeeds(nodes []*nyNode) {
+	if !t.lockSeeds {
+		return
+	}
+
+	for _, node := range nodes {
+		// Best effort, see LockMemory
+		lockMemory(node.privSeed)
+	}
+}
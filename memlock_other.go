@@ -0,0 +1,12 @@
+//go:build !unix
+
+package xnyss
+
+// Locking memory is not supported on platforms without mlock.
+func lockMemory(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	return ErrMemoryLockUnsupported
+}
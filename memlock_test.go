@@ -0,0 +1,32 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_LockMemory(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Locking fails only if the platform or its limits do not allow it
+	if err := tree.LockMemory(); err != nil {
+		t.Skip("Locking memory is not available -", err)
+	}
+	if !tree.lockSeeds {
+		t.Fatal("Tree does not lock its seeds")
+	}
+
+	// 2 - Trees locking their seeds sign as usual
+	if _, _, err := signMessage("lock memory test", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// 3 - Wiped trees are not locked
+	tree.Wipe()
+	if err := tree.LockMemory(); err != ErrTreeWiped {
+		t.Fatal("Locked wiped tree, err was", err)
+	}
+}
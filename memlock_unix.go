@@ -0,0 +1,14 @@
+//go:build unix
+
+package xnyss
+
+import "syscall"
+
+// Locks the pages holding b into memory.
+func lockMemory(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	return syscall.Mlock(b)
+}
@@ -0,0 +1,112 @@
+package xnyss
+
+import "io"
+
+// Length of the randomizer of a message digest, see SignMessage.
+const RandomizerLen = 32
+
+var (
+	ErrRandomizerMissing = newError(ErrCrypto, "signature does not carry a message randomizer")
+	ErrInvalidRandomizer = newError(ErrEncoding, "message randomizer must be RandomizerLen bytes long")
+)
+
+// Signs the message read from r, of any length, like Sign. Where Sign signs a
+// digest the caller computed, SignMessage hashes the message itself, using
+// randomized hashing: a fresh randomizer R is drawn from the entropy source of
+// t (see SetEntropy), and the signed message is the digest H(R || message)
+// under the hash suite of t. Since the signer picks R after the message is
+// fixed, finding two messages with the same digest requires a second preimage
+// rather than a collision of H. The randomizer is carried in the envelope of
+// the signature, see Signature.Randomizer, and VerifyMessage recomputes the
+// digest from it.
+//
+// r is read without holding the lock of t, so slow streams do not block other
+// signers. Returns the error of reading r, if any, before anything is signed.
+func (t *NYTree) SignMessage(r io.Reader, txid []byte) (*Signature, error) {
+	randomizer := make([]byte, RandomizerLen)
+	t.mu.Lock()
+	suite := t.suite
+	_, err := io.ReadFull(t.random(), randomizer)
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := messageDigest(suite, randomizer, r)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := t.Sign(digest, txid)
+	if err != nil {
+		return nil, err
+	}
+	sig.randomizer = randomizer
+
+	return sig, nil
+}
+
+// Verifies that the last signature of chain signs the message read from r
+// under the long-term public key rootPubKey, like VerifyChain, for signatures
+// created with SignMessage. The digest is recomputed from the randomizer of
+// the signature, and returns ErrRandomizerMissing if it has none.
+func VerifyMessage(rootPubKey []byte, chain []*Signature, r io.Reader) error {
+	if len(chain) == 0 {
+		return ErrChainEmpty
+	}
+	last := chain[len(chain)-1]
+	if last == nil {
+		return ErrChainBroken
+	}
+	if last.randomizer == nil {
+		return ErrRandomizerMissing
+	}
+
+	digest, err := messageDigest(last.suite, last.randomizer, r)
+	if err != nil {
+		return err
+	}
+
+	return VerifyChain(rootPubKey, chain, digest)
+}
+
+// Returns the digest H(randomizer || message) of the message read from r,
+// using suite.
+func messageDigest(suite HashSuite, randomizer []byte, r io.Reader) ([]byte, error) {
+	h := suite.hash()()
+	h.Write(randomizer)
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Returns the randomizer of the message digest signed by sig, if it was
+// created with SignMessage. Signatures decoded with NewSignature never have
+// one, since Bytes does not include it: use Envelope to transfer such
+// signatures, or SetRandomizer.
+func (sig *Signature) Randomizer() (randomizer []byte, ok bool) {
+	if sig.randomizer == nil {
+		return nil, false
+	}
+
+	return append([]byte(nil), sig.randomizer...), true
+}
+
+// Sets the randomizer of the message digest signed by sig, e.g. when it was
+// transferred separately from Signature.Bytes. A nil randomizer denotes a
+// signature of a plain digest.
+func (sig *Signature) SetRandomizer(randomizer []byte) error {
+	if randomizer == nil {
+		sig.randomizer = nil
+		return nil
+	}
+	if len(randomizer) != RandomizerLen {
+		return ErrInvalidRandomizer
+	}
+
+	sig.randomizer = append([]byte(nil), randomizer...)
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package xnyss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNYTree_SignMessage(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	msg := strings.Repeat("a message longer than a digest, ", 100)
+
+	// 1 - Messages of any length are signed and verify
+	sig, err := tree.SignMessage(strings.NewReader(msg), Txid("sign message", nil))
+	if err != nil {
+		t.Fatal("Failed to sign message -", err)
+	}
+	randomizer, ok := sig.Randomizer()
+	if !ok || len(randomizer) != RandomizerLen {
+		t.Fatal("Signature does not carry its randomizer")
+	}
+	if err := VerifyMessage(pk, []*Signature{sig}, strings.NewReader(msg)); err != nil {
+		t.Fatal("Failed to verify message -", err)
+	}
+	if err := VerifyMessage(pk, []*Signature{sig}, strings.NewReader(msg[1:])); err != ErrChainMessage {
+		t.Fatal("Verified other message, err was", err)
+	}
+
+	// 2 - Every signature uses a fresh randomizer
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	other, err := tree.SignMessage(strings.NewReader(msg), Txid("sign message", []byte{1}))
+	if err != nil {
+		t.Fatal("Failed to sign message -", err)
+	}
+	if r, _ := other.Randomizer(); bytes.Equal(r, randomizer) || bytes.Equal(other.Message, sig.Message) {
+		t.Fatal("Randomizer was reused")
+	}
+
+	// 3 - The randomizer is included in the envelope, but not in Bytes
+	parsed, err := ParseEnvelope(sig.Envelope(), sig.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if err := VerifyMessage(pk, []*Signature{parsed}, strings.NewReader(msg)); err != nil {
+		t.Fatal("Failed to verify parsed message -", err)
+	}
+	if len(sig.Envelope()) != EnvelopeLen(Branches, true)+EnvelopePositionLen+EnvelopeRandomizerLen {
+		t.Fatal("Invalid envelope length")
+	}
+	decoded, err := NewSignature(sig.Bytes(), sig.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyMessage(pk, []*Signature{decoded}, strings.NewReader(msg)); err != ErrRandomizerMissing {
+		t.Fatal("Verified message without randomizer, err was", err)
+	}
+	if err := decoded.SetRandomizer(randomizer[1:]); err != ErrInvalidRandomizer {
+		t.Fatal("Set invalid randomizer, err was", err)
+	}
+	decoded.SetRandomizer(randomizer)
+	if err := VerifyMessage(pk, []*Signature{decoded}, strings.NewReader(msg)); err != nil {
+		t.Fatal("Failed to verify message with its randomizer -", err)
+	}
+}
@@ -0,0 +1,182 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+)
+
+var (
+	ErrInvalidMigration   = newError(ErrEncoding, "invalid migration archive encoding")
+	ErrMigrationIntegrity = newError(ErrCrypto, "tree of migration archive does not match its manifest")
+)
+
+// Version byte of a decrypted migration archive.
+const migrationVersion = 0x01
+
+// Tags of the fields of a decrypted migration archive. The manifest entries
+// come first, followed by the states of the trees in the same order.
+const (
+	migrationFieldEntry = 0x01
+	migrationFieldTree  = 0x02
+)
+
+// Describes a tree of a migration archive.
+type MigrationEntry struct {
+	// The hash of the long-term public key of the tree, see Keyring
+	KeyHash []byte
+	// The fingerprint of the state of the tree, see NYTree.Fingerprint
+	Fingerprint [32]byte
+}
+
+// Returns a migration archive of the trees of the keyring k, for moving a
+// fleet of signing keys to another host, see ImportMigration. The archive is
+// encrypted like NYTree.BytesEncrypted, and holds a manifest of the long-term
+// keys and fingerprints of its trees, which is checked on import. Wiped trees
+// are left out.
+//
+// Like any copy of a state, the trees must not be used on this host once the
+// archive is imported elsewhere, or nodes would sign twice: remove them from k
+// and wipe them once the import succeeded.
+func (k *Keyring) ExportMigration(passphrase []byte) ([]byte, []MigrationEntry, error) {
+	return k.ExportMigrationWith(passphrase, DefaultEncryptionParams())
+}
+
+// Like ExportMigration, deriving the key of the archive using the parameters
+// p, see NYTree.BytesEncryptedWith.
+func (k *Keyring) ExportMigrationWith(passphrase []byte, p KDFParams) ([]byte, []MigrationEntry, error) {
+	random := make([]byte, saltLen+gcmNonceLen)
+	k.mu.Lock()
+	r := k.entropy
+	if r == nil {
+		r = rand.Reader
+	}
+	_, err := io.ReadFull(r, random)
+	var entries []MigrationEntry
+	var states [][]byte
+	for _, key := range k.keys() {
+		state, fp, ok := k.trees[keyringKey(key)].migrationState()
+		if !ok {
+			continue
+		}
+		entries = append(entries, MigrationEntry{KeyHash: key, Fingerprint: fp})
+		states = append(states, state)
+	}
+	k.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(migrationVersion)
+	for _, e := range entries {
+		writeField(buf, migrationFieldEntry, append(append([]byte(nil), e.KeyHash...), e.Fingerprint[:]...))
+	}
+	for _, state := range states {
+		writeField(buf, migrationFieldTree, state)
+		wipeBytes(state)
+	}
+	plaintext := buf.Bytes()
+	defer wipeBytes(plaintext)
+
+	archive, err := encryptState(plaintext, passphrase, p, bytes.NewReader(random))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return archive, entries, nil
+}
+
+// Returns the serialised state of t together with its fingerprint, or false
+// if t was wiped.
+func (t *NYTree) migrationState() ([]byte, [32]byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, [32]byte{}, false
+	}
+	buf := &bytes.Buffer{}
+	if _, err := t.writeTo(buf); err != nil {
+		return nil, [32]byte{}, false
+	}
+
+	return buf.Bytes(), t.fingerprint(), true
+}
+
+// Decrypts the migration archive b created by ExportMigration, and adds its
+// trees to the keyring k, returning the manifest of the archive. The import is
+// atomic: no tree is added unless all trees match the manifest and none has
+// the long-term key of a tree already held by k.
+//
+// Returns ErrDecryptionFailed if the passphrase is wrong or the archive was
+// modified, ErrMigrationIntegrity if a tree does not match its manifest entry,
+// ErrKeyringDuplicate if a long-term key collides, and the errors of Load.
+func (k *Keyring) ImportMigration(b, passphrase []byte) ([]MigrationEntry, error) {
+	plaintext, err := decryptState(b, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer wipeBytes(plaintext)
+	if len(plaintext) < 1 || plaintext[0] != migrationVersion {
+		return nil, ErrInvalidMigration
+	}
+
+	var entries []MigrationEntry
+	var trees []*NYTree
+	err = readFields(plaintext[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case migrationFieldEntry:
+			if len(value) != 64 || len(trees) > 0 {
+				return ErrInvalidMigration
+			}
+			e := MigrationEntry{KeyHash: append([]byte(nil), value[:32]...)}
+			copy(e.Fingerprint[:], value[32:])
+			entries = append(entries, e)
+		case migrationFieldTree:
+			t, err := Load(value)
+			if err != nil {
+				return err
+			}
+			trees = append(trees, t)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != len(trees) {
+		return nil, ErrMigrationIntegrity
+	}
+
+	keys := make([][32]byte, len(trees))
+	for i, t := range trees {
+		key, err := t.keyringKey()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(key[:], entries[i].KeyHash) || t.Fingerprint() != entries[i].Fingerprint {
+			return nil, ErrMigrationIntegrity
+		}
+		keys[i] = key
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	seen := make(map[[32]byte]bool, len(keys))
+	for _, key := range keys {
+		if _, ok := k.trees[key]; ok || seen[key] {
+			return nil, ErrKeyringDuplicate
+		}
+		seen[key] = true
+	}
+	for i, key := range keys {
+		k.trees[key] = trees[i]
+	}
+
+	return entries, nil
+}
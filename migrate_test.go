@@ -0,0 +1,91 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestKeyring_ExportMigration(t *testing.T) {
+	passphrase := []byte("migration passphrase")
+
+	src := NewKeyring()
+	for i := 0; i < 2; i++ {
+		seed, pubSeed, err := genSeeds()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree := New(seed, pubSeed, false)
+		if _, _, err := signMessage("migration test", tree); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := src.Add(tree); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive, entries, err := src.ExportMigrationWith(passphrase, testKDFParams)
+	if err != nil {
+		t.Fatal("Failed to export migration archive -", err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("Invalid amount of manifest entries", len(entries))
+	}
+
+	// 1 - Imported trees have the same keys and states as the exported ones
+	dst := NewKeyring()
+	imported, err := dst.ImportMigration(archive, passphrase)
+	if err != nil {
+		t.Fatal("Failed to import migration archive -", err)
+	}
+	if len(imported) != 2 || len(dst.Keys()) != 2 {
+		t.Fatal("Not all trees imported", len(imported), len(dst.Keys()))
+	}
+	for i, e := range imported {
+		if !bytes.Equal(e.KeyHash, entries[i].KeyHash) {
+			t.Fatal("Manifest of the import does not match the export")
+		}
+		before, _ := src.Tree(e.KeyHash)
+		after, err := dst.Tree(e.KeyHash)
+		if err != nil || after.Fingerprint() != before.Fingerprint() {
+			t.Fatal("Imported tree differs from the exported tree -", err)
+		}
+	}
+
+	// 2 - Wrong passphrases and modified archives are rejected
+	if _, err := NewKeyring().ImportMigration(archive, []byte("wrong")); err != ErrDecryptionFailed {
+		t.Fatal("Imported archive with wrong passphrase, err was", err)
+	}
+	modified := append([]byte(nil), archive...)
+	modified[len(modified)-1] ^= 1
+	if _, err := NewKeyring().ImportMigration(modified, passphrase); err != ErrDecryptionFailed {
+		t.Fatal("Imported modified archive, err was", err)
+	}
+
+	// 3 - Imports colliding with a held long-term key add no trees
+	tree, _ := src.Tree(entries[1].KeyHash)
+	partial := NewKeyring()
+	if _, err := partial.Add(tree); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partial.ImportMigration(archive, passphrase); err != ErrKeyringDuplicate {
+		t.Fatal("Imported colliding archive, err was", err)
+	}
+	if len(partial.Keys()) != 1 {
+		t.Fatal("Colliding import added trees", len(partial.Keys()))
+	}
+
+	// 4 - Trees that do not match the manifest are rejected
+	state := tree.Bytes()
+	buf := &bytes.Buffer{}
+	buf.WriteByte(migrationVersion)
+	writeField(buf, migrationFieldEntry, append(append([]byte(nil), entries[1].KeyHash...), make([]byte, 32)...))
+	writeField(buf, migrationFieldTree, state)
+	forged, err := encryptState(buf.Bytes(), passphrase, testKDFParams, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewKeyring().ImportMigration(forged, passphrase); err != ErrMigrationIntegrity {
+		t.Fatal("Imported tree not matching the manifest, err was", err)
+	}
+}
@@ -0,0 +1,173 @@
+package xnyss
+
+import "encoding/binary"
+
+var (
+	ErrTreeNotFresh = newError(ErrState, "tree has already been used")
+)
+
+// The signing mode of a tree.
+type Mode uint8
+
+const (
+	// Signatures create child nodes that can be used for future signatures.
+	ModeLongTerm Mode = iota
+	// The tree can create a single signature.
+	ModeOneTime
+	// Signatures do not create child nodes, and only the confirmed nodes that
+	// are left in the tree can be used.
+	ModeFrozen
+)
+
+// Records a change of the mode of a tree, at the epoch at which it happened.
+type ModeTransition struct {
+	Epoch uint64
+	Mode  Mode
+}
+
+// Returns the current mode of the tree t.
+func (t *NYTree) Mode() Mode {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case t.ots:
+		return ModeOneTime
+	case t.frozen:
+		return ModeFrozen
+	}
+
+	return ModeLongTerm
+}
+
+// Returns the mode transitions of the tree t, in order. The transitions are
+// included in the serialised tree.
+func (t *NYTree) Transitions() []ModeTransition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.copyTransitions()
+}
+
+func (t *NYTree) copyTransitions() []ModeTransition {
+	return append([]ModeTransition(nil), t.transitions...)
+}
+
+func (t *NYTree) transition(m Mode) {
+	t.epoch++
+	t.transitions = append(t.transitions, ModeTransition{Epoch: t.epoch, Mode: m})
+}
+
+// Freezes the long-term tree t: signatures no longer create child nodes, and
+// only nodes that are already confirmed can be used. This lets a wallet stop a
+// key from growing, e.g. before retiring it, while using its remaining capacity.
+func (t *NYTree) Freeze() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ots {
+		return ErrTreeNotLongTerm
+	}
+
+	if !t.frozen {
+		t.frozen = true
+		t.transition(ModeFrozen)
+	}
+
+	return nil
+}
+
+// Reverts Freeze, allowing signatures to create child nodes again. This is safe,
+// since a frozen tree only consumes nodes.
+func (t *NYTree) Unfreeze() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ots {
+		return ErrTreeNotLongTerm
+	}
+
+	if t.frozen {
+		t.frozen = false
+		t.transition(ModeLongTerm)
+	}
+
+	return nil
+}
+
+// Turns the fresh long-term tree t into a one-time tree, hardened like those
+// created by New (see HardenOneTime). Returns ErrTreeNotFresh if the tree has
+// been used to sign.
+func (t *NYTree) MarkOneTime() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+
+	if !t.ots {
+		// Hardened like the one-time trees created by New
+		t.ots = true
+		t.hardened = true
+		t.frozen = false
+		t.transition(ModeOneTime)
+	}
+
+	return nil
+}
+
+// Turns the fresh one-time tree t into a long-term tree. Returns
+// ErrTreeNotFresh if the tree has been used to sign.
+func (t *NYTree) MarkLongTerm() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+
+	if t.ots {
+		t.ots = false
+		t.hardened = false
+		t.transition(ModeLongTerm)
+	}
+
+	return nil
+}
+
+// Returns whether the tree still only holds its unused root node.
+func (t *NYTree) fresh() bool {
+	return !t.spent && len(t.consumed) == 0 && len(t.consumedSeeds) == 0 &&
+		len(t.nodes) == 1 && t.isRoot(t.nodes[0])
+}
+
+// Encodes mode transitions as a list of epoch || mode.
+func encodeTransitions(transitions []ModeTransition) []byte {
+	b := make([]byte, 0, 9*len(transitions))
+	for _, tr := range transitions {
+		var entry [9]byte
+		binary.BigEndian.PutUint64(entry[:], tr.Epoch)
+		entry[8] = byte(tr.Mode)
+		b = append(b, entry[:]...)
+	}
+
+	return b
+}
+
+func decodeTransitions(b []byte) ([]ModeTransition, error) {
+	if len(b)%9 != 0 {
+		return nil, ErrFieldInvalid
+	}
+
+	transitions := make([]ModeTransition, len(b)/9)
+	for i := range transitions {
+		transitions[i].Epoch = binary.BigEndian.Uint64(b[i*9:])
+		transitions[i].Mode = Mode(b[i*9+8])
+		if transitions[i].Mode > ModeFrozen {
+			return nil, ErrFieldInvalid
+		}
+	}
+
+	return transitions, nil
+}
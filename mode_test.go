@@ -0,0 +1,101 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_Freeze(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	sig, txid, err := signMessage("first signature", tree)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+	// 1 - A frozen tree only uses confirmed nodes and creates no children
+	if err := tree.Freeze(); err != nil {
+		t.Fatal("Failed to freeze tree -", err)
+	}
+	if tree.Mode() != ModeFrozen || tree.Available(txid) != 1 {
+		t.Fatal("Frozen tree has", tree.Available(txid), "nodes available, should be 1")
+	}
+
+	frozenSig, _, err := signMessage("frozen signature", tree)
+	if err != nil {
+		t.Fatal("Failed to sign with frozen tree -", err)
+	}
+	if frozenSig.ChildHashes != nil || len(tree.nodes) != Branches-1 {
+		t.Fatal("Frozen tree created child nodes")
+	}
+	if _, _, err := signMessage("no nodes", tree); err != ErrTreeNoneAvailable {
+		t.Fatal("Frozen tree signed with unconfirmed node, err was", err)
+	}
+
+	// 2 - Transitions are persisted, and the tree can be unfrozen
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.Mode() != ModeFrozen {
+		t.Fatal("Frozen mode was not persisted")
+	}
+	if err := loaded.Unfreeze(); err != nil {
+		t.Fatal("Failed to unfreeze tree -", err)
+	}
+	transitions := loaded.Transitions()
+	if len(transitions) != 2 || transitions[0].Mode != ModeFrozen ||
+		transitions[1].Mode != ModeLongTerm || transitions[0].Epoch >= transitions[1].Epoch {
+		t.Fatal("Invalid mode transitions", transitions)
+	}
+}
+
+func TestNYTree_MarkOneTime(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - A fresh tree can change between one-time and long-term mode
+	tree := New(seed, pubSeed, false)
+	if err := tree.MarkOneTime(); err != nil || tree.Mode() != ModeOneTime {
+		t.Fatal("Failed to mark tree as one-time -", err)
+	}
+	if err := tree.MarkLongTerm(); err != nil || tree.Mode() != ModeLongTerm {
+		t.Fatal("Failed to mark tree as long-term -", err)
+	}
+
+	// 2 - A converted tree behaves like a one-time tree created by New: it is
+	// hardened, so signing spends it
+	if err := tree.MarkOneTime(); err != nil {
+		t.Fatal("Failed to mark tree as one-time -", err)
+	}
+	for _, tree := range []*NYTree{tree, New(seed, pubSeed, true)} {
+		if _, _, err := signMessage("one-time signature", tree); err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if !tree.Spent() || !bytes.Equal(tree.rootSeed, make([]byte, 32)) {
+			t.Fatal("One-time tree was not spent after signing")
+		}
+		if _, _, err := signMessage("second signature", tree); err != ErrTreeExhausted {
+			t.Fatal("Signed twice with a one-time tree, err was", err)
+		}
+	}
+	tree = New(seed, pubSeed, false)
+
+	// 3 - A used tree can not
+	if _, _, err := signMessage("first signature", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := tree.MarkOneTime(); err != ErrTreeNotFresh {
+		t.Fatal("Used tree was marked as one-time, err was", err)
+	}
+	if err := New(seed, pubSeed, true).Freeze(); err != ErrTreeNotLongTerm {
+		t.Fatal("One-time tree was frozen, err was", err)
+	}
+}
@@ -0,0 +1,303 @@
+// Implements threshold co-signing of a message by nodes of several XNYSS
+// trees, e.g. a 2-of-3 setup where any two of three parties must sign.
+//
+// The parties agree on a Statement: the hash of the message, the long-term
+// public keys of all participants and the threshold. Every party signs the
+// digest of the statement with its own tree, so a share can not be reused for
+// another message, another set of participants or another threshold. The
+// shares are collected into a MultiSig, which is valid once at least
+// Threshold distinct participants signed, and which is encoded compactly: the
+// statement is known to the verifier and is not encoded, so every share only
+// holds the index of its participant, the signatures linking the participant's
+// root to the signing node, and the signature of the statement.
+package multisig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrThresholdInvalid  = errors.New("threshold must be between 1 and the number of participants")
+	ErrDuplicateKey      = errors.New("participant public keys are not distinct")
+	ErrNotParticipant    = errors.New("tree is not a participant of the statement")
+	ErrDuplicateShare    = errors.New("participant already signed the statement")
+	ErrThresholdNotMet   = errors.New("fewer participants signed than the threshold")
+	ErrInvalidMultiSig   = errors.New("invalid multisig encoding")
+	ErrStatementMismatch = errors.New("share does not sign the statement")
+)
+
+// Domain separation prefix of statement digests.
+const statementDomain = "xnyss multisig statement"
+
+// Version byte of an encoded multisig.
+const multiSigVersion = 0x01
+
+// The message that participants co-sign, bound to the participants and the
+// threshold.
+type Statement struct {
+	// The message hash, at most xnyss.MsgLen bytes
+	MessageHash []byte
+	// The long-term public keys of the participants. Shares refer to
+	// participants by their index.
+	PubKeys   [][]byte
+	Threshold int
+}
+
+// Creates the statement that threshold of the participants with the long-term
+// public keys pubKeys must sign for msgHash. Returns ErrThresholdInvalid if
+// threshold is not between 1 and len(pubKeys), and ErrDuplicateKey if a key
+// is listed twice.
+func NewStatement(msgHash []byte, pubKeys [][]byte, threshold int) (*Statement, error) {
+	if len(msgHash) > xnyss.MsgLen {
+		return nil, xnyss.ErrInvalidMsgLen
+	}
+	if threshold < 1 || threshold > len(pubKeys) {
+		return nil, ErrThresholdInvalid
+	}
+
+	s := &Statement{MessageHash: append([]byte(nil), msgHash...), Threshold: threshold}
+	seen := make(map[string]bool, len(pubKeys))
+	for _, pk := range pubKeys {
+		if seen[string(pk)] {
+			return nil, ErrDuplicateKey
+		}
+		seen[string(pk)] = true
+		s.PubKeys = append(s.PubKeys, append([]byte(nil), pk...))
+	}
+
+	return s, nil
+}
+
+// Returns the digest that the participants sign: the hash of the message hash,
+// the threshold and the public keys of all participants, in order.
+func (s *Statement) Digest() []byte {
+	var b [4]byte
+	h := sha256.New()
+	h.Write([]byte(statementDomain))
+	writeBytes := func(v []byte) {
+		binary.BigEndian.PutUint32(b[:], uint32(len(v)))
+		h.Write(b[:])
+		h.Write(v)
+	}
+
+	writeBytes(s.MessageHash)
+	binary.BigEndian.PutUint32(b[:], uint32(s.Threshold))
+	h.Write(b[:])
+	binary.BigEndian.PutUint32(b[:], uint32(len(s.PubKeys)))
+	h.Write(b[:])
+	for _, pk := range s.PubKeys {
+		writeBytes(pk)
+	}
+
+	return h.Sum(nil)
+}
+
+// Returns the index of the participant with the long-term public key pubKey,
+// or -1 if it does not participate.
+func (s *Statement) Index(pubKey []byte) int {
+	for i, pk := range s.PubKeys {
+		if bytes.Equal(pk, pubKey) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// One participant's signature of a statement.
+type Share struct {
+	// The index of the participant in Statement.PubKeys
+	Index int
+	// The signatures linking the root of the participant's tree to the node
+	// that signed the statement, oldest first, see xnyss.Bundle
+	Links []*xnyss.Signature
+	// The signature of the statement digest
+	Signature *xnyss.Signature
+}
+
+// Signs the statement s with tree, a tree of one of its participants, like
+// NYTree.SignBundle, so the share links back to the long-term key of tree.
+// Returns ErrNotParticipant if the long-term public key of tree is not one of
+// the participants of s.
+func (s *Statement) Sign(tree *xnyss.NYTree, txid []byte) (*Share, error) {
+	index := s.Index(tree.PublicKey())
+	if index < 0 {
+		return nil, ErrNotParticipant
+	}
+
+	sig, bundle, err := tree.SignBundle(s.Digest(), txid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Share{Index: index, Links: bundle.Links, Signature: sig}, nil
+}
+
+// Verifies that sh is a signature of s by the participant it names.
+func (s *Statement) VerifyShare(sh *Share) error {
+	if sh == nil || sh.Signature == nil || sh.Index < 0 || sh.Index >= len(s.PubKeys) {
+		return ErrNotParticipant
+	}
+	digest := s.Digest()
+	if !bytes.Equal(sh.Signature.Message, digest) {
+		return ErrStatementMismatch
+	}
+
+	chain := append(append([]*xnyss.Signature(nil), sh.Links...), sh.Signature)
+	return xnyss.VerifyChain(s.PubKeys[sh.Index], chain, digest)
+}
+
+// The shares of a statement, collected until the threshold is met.
+type MultiSig struct {
+	Statement *Statement
+	// The shares, ordered by the index of their participant
+	Shares []*Share
+}
+
+// Creates an empty multisig for the statement s.
+func New(s *Statement) *MultiSig {
+	return &MultiSig{Statement: s}
+}
+
+// Verifies the share sh, and adds it to m. Returns ErrDuplicateShare if its
+// participant already signed.
+func (m *MultiSig) Add(sh *Share) error {
+	if err := m.Statement.VerifyShare(sh); err != nil {
+		return err
+	}
+
+	i := sort.Search(len(m.Shares), func(i int) bool { return m.Shares[i].Index >= sh.Index })
+	if i < len(m.Shares) && m.Shares[i].Index == sh.Index {
+		return ErrDuplicateShare
+	}
+	m.Shares = append(m.Shares, nil)
+	copy(m.Shares[i+1:], m.Shares[i:])
+	m.Shares[i] = sh
+
+	return nil
+}
+
+// Reports whether at least Threshold participants signed, without verifying
+// the shares again.
+func (m *MultiSig) Complete() bool {
+	return len(m.Shares) >= m.Statement.Threshold
+}
+
+// Verifies the multisig as a whole: every share must be a valid signature of
+// the statement by a distinct participant, and there must be at least
+// Threshold of them. Returns ErrThresholdNotMet if there are fewer.
+func (m *MultiSig) Verify() error {
+	signed := make(map[int]bool, len(m.Shares))
+	for _, sh := range m.Shares {
+		if err := m.Statement.VerifyShare(sh); err != nil {
+			return err
+		}
+		if signed[sh.Index] {
+			return ErrDuplicateShare
+		}
+		signed[sh.Index] = true
+	}
+
+	if len(signed) < m.Statement.Threshold {
+		return ErrThresholdNotMet
+	}
+
+	return nil
+}
+
+// Returns the encoding of the shares of m. The statement is not encoded, since
+// the verifier knows it, nor is the message of the signature of every share,
+// which is the statement digest. Every share is encoded as the index of its
+// participant, its links as an xnyss.Chain (empty if it has none) and the
+// envelope of its signature, each length-prefixed.
+func (m *MultiSig) Bytes() []byte {
+	buf := []byte{multiSigVersion}
+	buf = binary.AppendUvarint(buf, uint64(len(m.Shares)))
+
+	for _, sh := range m.Shares {
+		var links []byte
+		if len(sh.Links) > 0 {
+			links = xnyss.Chain(sh.Links).Bytes()
+		}
+		envelope := sh.Signature.Envelope()
+
+		buf = binary.AppendUvarint(buf, uint64(sh.Index))
+		buf = binary.AppendUvarint(buf, uint64(len(links)))
+		buf = append(buf, links...)
+		buf = binary.AppendUvarint(buf, uint64(len(envelope)))
+		buf = append(buf, envelope...)
+	}
+
+	return buf
+}
+
+// Decodes the shares of a multisig of the statement s, encoded by
+// MultiSig.Bytes. The multisig must still be verified using Verify.
+func Load(s *Statement, b []byte) (*MultiSig, error) {
+	if len(b) < 1 || b[0] != multiSigVersion {
+		return nil, ErrInvalidMultiSig
+	}
+	b = b[1:]
+
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return 0, ErrInvalidMultiSig
+		}
+		b = b[n:]
+		return v, nil
+	}
+	readBytes := func() ([]byte, error) {
+		l, err := readUvarint()
+		if err != nil || l > uint64(len(b)) {
+			return nil, ErrInvalidMultiSig
+		}
+		v := b[:l]
+		b = b[l:]
+		return v, nil
+	}
+
+	count, err := readUvarint()
+	if err != nil || count > uint64(len(s.PubKeys)) {
+		return nil, ErrInvalidMultiSig
+	}
+
+	m := New(s)
+	digest := s.Digest()
+	for i := uint64(0); i < count; i++ {
+		index, err := readUvarint()
+		if err != nil || index >= uint64(len(s.PubKeys)) {
+			return nil, ErrInvalidMultiSig
+		}
+		links, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		envelope, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		sh := &Share{Index: int(index)}
+		if len(links) > 0 {
+			if sh.Links, err = xnyss.LoadChain(links); err != nil {
+				return nil, err
+			}
+		}
+		if sh.Signature, err = xnyss.ParseEnvelope(envelope, append([]byte(nil), digest...)); err != nil {
+			return nil, err
+		}
+		m.Shares = append(m.Shares, sh)
+	}
+	if len(b) != 0 {
+		return nil, ErrInvalidMultiSig
+	}
+
+	return m, nil
+}
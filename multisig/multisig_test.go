@@ -0,0 +1,124 @@
+package multisig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+// Creates a tree from random seeds.
+func newTree(t *testing.T) *xnyss.NYTree {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+
+	return xnyss.New(seeds[:32], seeds[32:], false)
+}
+
+func TestMultiSig(t *testing.T) {
+	trees := []*xnyss.NYTree{newTree(t), newTree(t), newTree(t)}
+	var pubKeys [][]byte
+	for _, tree := range trees {
+		pubKeys = append(pubKeys, tree.PublicKey())
+	}
+	msgHash := sha256.Sum256([]byte("multisig message"))
+
+	s, err := NewStatement(msgHash[:], pubKeys, 2)
+	if err != nil {
+		t.Fatal("Failed to create statement -", err)
+	}
+
+	// The second participant signs with a node below its root, so its share
+	// carries a link
+	sig, err := trees[2].Sign(make([]byte, xnyss.MsgLen), xnyss.Txid("setup", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trees[2].Confirm(sig.ChildHashes[0], xnyss.ConfirmsRequired)
+
+	// 1 - A share alone does not meet the threshold, and can not be added twice
+	m := New(s)
+	first, err := s.Sign(trees[0], xnyss.Txid("multisig", []byte{0}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := m.Add(first); err != nil {
+		t.Fatal("Failed to add share -", err)
+	}
+	if m.Complete() || m.Verify() != ErrThresholdNotMet {
+		t.Fatal("Threshold met by a single share")
+	}
+	if err := m.Add(first); err != ErrDuplicateShare {
+		t.Fatal("Expected ErrDuplicateShare, got", err)
+	}
+
+	// 2 - Two shares meet the threshold, and are ordered by participant
+	second, err := s.Sign(trees[2], xnyss.Txid("multisig", []byte{2}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if len(second.Links) != 1 {
+		t.Fatal("Expected 1 link, got", len(second.Links))
+	}
+	if err := m.Add(second); err != nil {
+		t.Fatal("Failed to add share -", err)
+	}
+	if !m.Complete() || m.Verify() != nil || m.Shares[0] != first || m.Shares[1] != second {
+		t.Fatal("Complete multisig rejected")
+	}
+
+	// 3 - The encoding round-trips, and holds neither statement nor digests
+	b := m.Bytes()
+	loaded, err := Load(s, b)
+	if err != nil {
+		t.Fatal("Failed to load multisig -", err)
+	}
+	if err := loaded.Verify(); err != nil {
+		t.Fatal("Loaded multisig rejected -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), b) || bytes.Contains(b, s.Digest()) || bytes.Contains(b, pubKeys[0]) {
+		t.Fatal("Invalid multisig encoding")
+	}
+	if _, err := Load(s, append(b, 0)); err != ErrInvalidMultiSig {
+		t.Fatal("Expected ErrInvalidMultiSig, got", err)
+	}
+
+	// 4 - Shares of other statements, and of trees that do not participate,
+	// are rejected
+	other, err := NewStatement(msgHash[:], pubKeys, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherShare, err := other.Sign(trees[1], xnyss.Txid("multisig", []byte{1}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := m.Add(otherShare); err != ErrStatementMismatch {
+		t.Fatal("Expected ErrStatementMismatch, got", err)
+	}
+	if _, err := s.Sign(newTree(t), xnyss.Txid("multisig", nil)); err != ErrNotParticipant {
+		t.Fatal("Expected ErrNotParticipant, got", err)
+	}
+
+	// 5 - Forged shares fail verification of the whole multisig
+	forged, err := Load(s, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Shares[1].Index = 1
+	if forged.Verify() == nil {
+		t.Fatal("Forged multisig accepted")
+	}
+
+	// 6 - Invalid statements are rejected
+	if _, err := NewStatement(msgHash[:], pubKeys, 4); err != ErrThresholdInvalid {
+		t.Fatal("Expected ErrThresholdInvalid, got", err)
+	}
+	if _, err := NewStatement(msgHash[:], [][]byte{pubKeys[0], pubKeys[0]}, 1); err != ErrDuplicateKey {
+		t.Fatal("Expected ErrDuplicateKey, got", err)
+	}
+}
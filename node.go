@@ -1,17 +1,20 @@
 package xnyss
 
 import (
-	wotsp "github.com/Re0h/xnyss/wotsp256"
+	"github.com/Re0h/xnyss/wotsp"
 	"crypto/sha256"
 	"crypto/rand"
-	"errors"
+	"fmt"
 	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
 )
 
-const nodeByteLen = 32 + 32 + 32 + 1
+const nodeByteLen = NodeRecordLen
 
 var (
-	ErrNodeInvalidInput = errors.New("input is not a valid node")
+	ErrNodeInvalidInput = newError(ErrEncoding, "input is not a valid node")
 )
 
 // Represents a node in the signature tree
@@ -20,48 +23,261 @@ type nyNode struct {
 	pubSeed  []byte
 	privSeed []byte
 	confirms uint8
+
+	// Cached hash of the public key, computed when needed
+	pkh []byte
+	// Reservation holding this node, or 0 if it is not reserved
+	reservation ReservationID
+	// Label of the subtree this node belongs to
+	label string
+	// Creation time in Unix seconds, or zero if it is not known, see
+	// NYTree.Prune
+	created int64
+	// Length of the transaction identifier the txid was hashed from, or zero
+	// if it was not hashed, see NYTree.SetTxidHashing
+	txidLen uint32
+	// Public key hash of the node whose signature created this node, or nil
+	// for the root and nodes loaded from states that did not record it, see
+	// NYTree.Topology
+	parent []byte
+	// Distance to the root node. Nodes loaded from states that did not record
+	// depth have depth 0.
+	depth uint32
+	// If not nil, privSeed holds the seed XOR mask, see NYTree.BlindSeed
+	mask []byte
+	// Whether the key of the node is derived using its position, and its index
+	// among the nodes at its depth, see NYTree.EnableAddressing
+	addressed bool
+	index     uint64
+	// Whether the index of a node that is not addressed is known, which is the
+	// case for the nodes of deterministic trees, see NYTree.SetCompact
+	indexed bool
+	// Whether the seeds of the node are derived from the root seeds at its
+	// position, if known (not serialised), see NYTree.SetCompact
+	derivation derivation
+	// The hash suite of the tree, see NYTree.SetHashSuite (not serialised)
+	suite HashSuite
+	// The Winternitz parameter of the tree, see NYTree.SetWinternitz (not
+	// serialised)
+	w uint16
+	// The expanded private key, kept once it is needed so signing does not
+	// expand the seed again (not serialised). Nodes with masked seeds do not
+	// keep one, see privateKey.
+	key *wotsp.PrivateKey
+	// The signature that created the node, if it was created since the tree
+	// was loaded (not serialised), see SignBundle
+	link *link
+	// If not nil, privSeed holds the handle of the seed in this provider (not
+	// serialised), see NewWithSeedProvider
+	provider SeedProvider
 }
 
-func loadNode(b []byte) (*nyNode, int, error) {
+// Loads a node from b. If withFields is true, the node record is followed by
+// the node's additional fields. The seeds and txid of the node are copied,
+// unless alias is set, see LoadOwned.
+func loadNode(b []byte, withFields, alias bool) (*nyNode, int, error) {
 	if len(b) < nodeByteLen {
 		return nil, 0, ErrNodeInvalidInput
 	}
 
-	return &nyNode{
-		privSeed: b[0:32],
-		pubSeed:  b[32:64],
-		txid:     b[64:96],
+	node := &nyNode{
+		privSeed: b[0:32:32],
+		pubSeed:  b[32:64:64],
+		txid:     b[64:96:96],
 		confirms: b[96],
-	}, nodeByteLen, nil
+	}
+	if !alias {
+		node.privSeed = append([]byte(nil), node.privSeed...)
+		node.pubSeed = append([]byte(nil), node.pubSeed...)
+		node.txid = append([]byte(nil), node.txid...)
+	}
+	if !withFields {
+		return node, nodeByteLen, nil
+	}
+
+	if len(b) < nodeByteLen+4 {
+		return nil, 0, ErrNodeInvalidInput
+	}
+	length := binary.BigEndian.Uint32(b[nodeByteLen:])
+	if uint64(length) > uint64(len(b)-nodeByteLen-4) {
+		return nil, 0, ErrNodeInvalidInput
+	}
+
+	end := nodeByteLen + 4 + int(length)
+	if err := node.loadFields(b[nodeByteLen+4 : end]); err != nil {
+		return nil, 0, err
+	}
+
+	return node, end, nil
+}
+
+// Loads a node from a frame of a serialised tree, which holds the node record
+// followed by the node's additional fields. The node aliases the frame if
+// alias is set.
+func loadFramedNode(frame []byte, alias bool) (*nyNode, error) {
+	if len(frame) < nodeByteLen {
+		return nil, ErrNodeInvalidInput
+	}
+
+	node, _, err := loadNode(frame[:nodeByteLen], false, alias)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.loadFields(frame[nodeByteLen:]); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// Encodes the additional fields of the node.
+func (n *nyNode) fields() []byte {
+	return n.encodeFields(true)
+}
+
+// Encodes the additional fields of the node, leaving out its depth and index
+// if withPosition is false, e.g. because the node record holds them.
+func (n *nyNode) encodeFields(withPosition bool) []byte {
+	buf := &bytes.Buffer{}
+	if n.label != "" {
+		writeField(buf, nodeFieldLabel, []byte(n.label))
+	}
+	if n.depth > 0 && withPosition {
+		var depth [4]byte
+		binary.BigEndian.PutUint32(depth[:], n.depth)
+		writeField(buf, nodeFieldDepth, depth[:])
+	}
+	if (n.addressed || n.indexed) && withPosition {
+		var index [8]byte
+		binary.BigEndian.PutUint64(index[:], n.index)
+		tag := byte(nodeFieldIndex)
+		if !n.addressed {
+			tag = nodeFieldDerivedIndex
+		}
+		writeField(buf, tag, index[:])
+	}
+	if n.created != 0 {
+		var created [8]byte
+		binary.BigEndian.PutUint64(created[:], uint64(n.created))
+		writeField(buf, nodeFieldCreated, created[:])
+	}
+	if n.txidLen != 0 {
+		var txidLen [4]byte
+		binary.BigEndian.PutUint32(txidLen[:], n.txidLen)
+		writeField(buf, nodeFieldTxidLen, txidLen[:])
+	}
+	if n.parent != nil {
+		writeField(buf, nodeFieldParent, n.parent)
+	}
+	// Always written, so the encoding does not depend on whether the hash was
+	// computed before
+	writeField(buf, nodeFieldPkh, n.pubKeyHash())
+
+	return buf.Bytes()
 }
 
-// Generates child nodes of the current node.
-func (n *nyNode) childNodes(txid []byte) (children []*nyNode, err error) {
-	r := make([]byte, 64*Branches)
-	_, err = rand.Read(r)
+func (n *nyNode) loadFields(b []byte) error {
+	return readFields(b, func(tag byte, value []byte) error {
+		switch tag {
+		case nodeFieldLabel:
+			n.label = string(value)
+		case nodeFieldDepth:
+			if len(value) != 4 {
+				return ErrFieldInvalid
+			}
+			n.depth = binary.BigEndian.Uint32(value)
+		case nodeFieldIndex:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			n.addressed = true
+			n.index = binary.BigEndian.Uint64(value)
+		case nodeFieldDerivedIndex:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			n.indexed = true
+			n.index = binary.BigEndian.Uint64(value)
+		case nodeFieldPkh:
+			if len(value) != 32 {
+				return ErrFieldInvalid
+			}
+			n.pkh = append([]byte(nil), value...)
+		case nodeFieldCreated:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			n.created = int64(binary.BigEndian.Uint64(value))
+		case nodeFieldTxidLen:
+			if len(value) != 4 {
+				return ErrFieldInvalid
+			}
+			n.txidLen = binary.BigEndian.Uint32(value)
+		case nodeFieldParent:
+			if len(value) != 32 {
+				return ErrFieldInvalid
+			}
+			n.parent = append([]byte(nil), value...)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+}
+
+// Generates the given number of child nodes of the current node, using entropy
+// read from r.
+func (n *nyNode) childNodes(txid []byte, branches int, r io.Reader) (children []*nyNode, err error) {
+	entropy := make([]byte, 64*branches)
+	_, err = io.ReadFull(r, entropy)
 	if err != nil {
 		return
 	}
 
-	children = make([]*nyNode, Branches)
+	seed, done := n.seed()
+	defer done()
+
+	children = make([]*nyNode, branches)
 	s := sha256.New()
 	offset := 0
 	for i := range children {
 		child := &nyNode{
-			txid:     txid,
-			confirms: 0,
+			txid:      txid,
+			confirms:  0,
+			depth:     n.depth + 1,
+			addressed: n.addressed,
+			index:     n.index*uint64(branches) + uint64(i),
+			suite:     n.suite,
+			w:         n.w,
 		}
 
-		s.Write(n.privSeed)
-		s.Write(r[offset : offset+32])
-		child.privSeed = s.Sum(nil)
+		if n.provider == nil {
+			s.Write(seed)
+			s.Write(entropy[offset : offset+32])
+			child.privSeed = s.Sum(nil)
+		}
 
 		s.Reset()
 
 		s.Write(n.pubSeed)
-		s.Write(r[offset+32 : offset+64])
+		s.Write(entropy[offset+32 : offset+64])
 		child.pubSeed = s.Sum(nil)
 
+		if n.provider != nil {
+			var handle, pubKey []byte
+			handle, pubKey, err = n.provider.Derive(seed, entropy[offset:offset+32], child.seedKeyParams())
+			if err == nil && len(handle) != SeedHandleLen {
+				err = ErrSeedHandleInvalid
+			}
+			if err != nil {
+				wipeProviderSeeds(children[:i])
+				return nil, err
+			}
+			child.privSeed, child.provider = handle, n.provider
+			child.pkh = n.suite.sum(pubKey)
+		}
+
 		children[i] = child
 		offset += 64
 	}
@@ -69,39 +285,154 @@ func (n *nyNode) childNodes(txid []byte) (children []*nyNode, err error) {
 	return
 }
 
+// Destroys the seeds of the nodes held by a seed provider, e.g. because the
+// nodes are not added to the tree after all.
+func wipeProviderSeeds(nodes []*nyNode) {
+	for _, node := range nodes {
+		if node.provider != nil {
+			node.provider.Wipe(node.privSeed)
+		}
+	}
+}
+
 func (n *nyNode) genPubKey() []byte {
-	return wotsp.GenPublicKey(n.privSeed, n.pubSeed, &wotsp.Address{})
+	injectHashFault()
+
+	if n.mask != nil {
+		return n.wots().GenPublicKeyMasked(n.privSeed, n.mask, n.pubSeed, n.position().address())
+	}
+
+	return n.privateKey().PublicKey(n.position().address())
+}
+
+// Returns the expanded private key of the node, expanding it on first use. Must
+// not be called for nodes with masked seeds, since their unmasked key is not
+// kept in memory.
+func (n *nyNode) privateKey() *wotsp.PrivateKey {
+	if n.key == nil {
+		n.key = n.wots().NewPrivateKey(n.privSeed, n.pubSeed)
+	}
+
+	return n.key
+}
+
+// Returns a copy of the node that shares no memory with it, except for the
+// signature that created it. The copy does not hold the expanded private key,
+// and its seed is masked with mask if the node is masked.
+func (n *nyNode) clone(mask []byte) *nyNode {
+	c := *n
+	c.privSeed = append([]byte(nil), n.privSeed...)
+	c.pubSeed = append([]byte(nil), n.pubSeed...)
+	c.txid = append([]byte(nil), n.txid...)
+	if n.parent != nil {
+		c.parent = append([]byte(nil), n.parent...)
+	}
+	if n.pkh != nil {
+		c.pkh = append([]byte(nil), n.pkh...)
+	}
+	if n.mask != nil {
+		c.mask = mask
+	}
+	c.key = nil
+
+	return &c
+}
+
+// Wipes and drops the expanded private key of the node, if it has one.
+func (n *nyNode) dropKey() {
+	if n.key != nil {
+		n.key.Wipe()
+		n.key = nil
+	}
+}
+
+// Returns the private seed of the node. If the seed is masked, it is unmasked
+// into a temporary copy, which is wiped by calling done.
+func (n *nyNode) seed() (seed []byte, done func()) {
+	return unmask(n.privSeed, n.mask)
+}
+
+// Returns H(pk) of the node. The hash is cached, since computing the public key
+// is expensive.
+func (n *nyNode) pubKeyHash() []byte {
+	if n.pkh == nil {
+		n.pkh = n.suite.sum(n.genPubKey())
+	}
+
+	return n.pkh
+}
+
+// Returns the entropy source used to generate the children of the node: the
+// node's derivation stream if deterministic is true, crypto/rand otherwise.
+func (n *nyNode) entropy(deterministic bool) io.Reader {
+	if deterministic {
+		return injectEntropyFault(newDerivationStream(n.privSeed, n.mask))
+	}
+
+	return injectEntropyFault(rand.Reader)
 }
 
-func (n *nyNode) sign(msg, txid []byte, ots bool) (sig *Signature, childNodes []*nyNode, err error) {
-	childNodes, err = n.childNodes(txid)
+func (n *nyNode) sign(msg, txid []byte, ots bool, branches int, r io.Reader, counter *uint64, commitment []byte) (sig *Signature, childNodes []*nyNode, err error) {
+	if ots && n.provider != nil {
+		// The children of leaves are not used, so the provider does not
+		// need to create their seeds
+		branches = 0
+	}
+	childNodes, err = n.childNodes(txid, branches, r)
 	if err != nil {
-		err = errors.New("failed to create child nodes " + err.Error())
+		err = fmt.Errorf("failed to create child nodes %w", err)
 		return
 	}
 	childHashes := make([][]byte, len(childNodes))
 
 	// Write message to be signed
-	s:= sha256.New()
+	s:= n.suite.hash()()
 
 	// Calculate the child nodes' public key hashes if required
 	if !ots {
 		for i := range childNodes {
-			pubKey := childNodes[i].genPubKey()
-
-			s.Write(pubKey)
-			childHashes[i] = s.Sum(nil)
-			s.Reset()
+			childHashes[i] = childNodes[i].pubKeyHash()
 		}
 
+		// Child hashes are signed in canonical (ascending) order
+		sort.Slice(childNodes, func(i, j int) bool {
+			return bytes.Compare(childNodes[i].pkh, childNodes[j].pkh) < 0
+		})
+		for i := range childNodes {
+			childHashes[i] = childNodes[i].pkh
+		}
 	}
 
 	s.Write(msg)
 	for i := range childNodes {
 		s.Write(childHashes[i])
 	}
+	if counter != nil {
+		s.Write(encodeCounter(*counter))
+	}
+	if commitment != nil {
+		s.Write(n.suite.sum(commitment))
+	}
 
-	sigBytes := wotsp.Sign(s.Sum(nil), n.privSeed, n.pubSeed, &wotsp.Address{})
+	injectHashFault()
+	var sigBytes []byte
+	if n.provider != nil {
+		sigBytes, err = n.provider.SignExpand(n.privSeed, s.Sum(nil), n.seedKeyParams())
+		if err != nil {
+			wipeProviderSeeds(childNodes)
+			return nil, nil, err
+		}
+		// The seed is one-time, and the tree refuses to sign with it again
+		// either way, so failing to destroy it does not fail the signature
+		n.provider.Wipe(n.privSeed)
+	} else if n.mask != nil {
+		sigBytes = n.wots().SignMasked(s.Sum(nil), n.privSeed, n.mask, n.pubSeed, n.position().address())
+	} else {
+		sigBytes = n.privateKey().Sign(s.Sum(nil), n.position().address())
+		// The key is one-time, so it is not needed anymore
+		n.dropKey()
+	}
+	injectSignFault(sigBytes)
 
 	sig = &Signature{
 		PubSeed:     n.pubSeed,
@@ -112,13 +443,40 @@ func (n *nyNode) sign(msg, txid []byte, ots bool) (sig *Signature, childNodes []
 	if !ots { // If we use a one-time key, we want sig.ChildHashes to be nil
 		sig.ChildHashes = childHashes
 	}
+	if counter != nil {
+		c := *counter
+		sig.counter = &c
+	}
+	if commitment != nil {
+		sig.commitment = append([]byte(nil), commitment...)
+	}
+	sig.position = n.position()
+	sig.suite = n.suite
+	sig.w = n.w
+
+	return
+}
+
+// Returns H(privSeed||pubSeed), which identifies the one-time key of a node
+// without revealing its seeds.
+func (n *nyNode) seedDigest() (digest [32]byte) {
+	seed, done := n.seed()
+	defer done()
+
+	s := sha256.New()
+	s.Write(seed)
+	s.Write(n.pubSeed)
+	s.Sum(digest[:0])
 
 	return
 }
 
 func (n *nyNode) bytes() []byte {
+	seed, done := n.seed()
+	defer done()
+
 	buf := &bytes.Buffer{}
-	buf.Write(n.privSeed)
+	buf.Write(seed)
 	buf.Write(n.pubSeed)
 	buf.Write(n.txid)
 	buf.WriteByte(n.confirms)
@@ -127,7 +485,17 @@ func (n *nyNode) bytes() []byte {
 }
 
 func (n *nyNode) wipe() {
+	n.wipeSeed()
+	for i := range n.pubSeed {
+		n.pubSeed[i] = 0
+	}
+}
+
+// Wipes the private seed and expanded key of the node, keeping its public
+// seed, which the signatures of the node alias.
+func (n *nyNode) wipeSeed() {
 	for i := range n.privSeed {
 		n.privSeed[i] = 0
 	}
+	n.dropKey()
 }
@@ -0,0 +1,149 @@
+package xnyss
+
+// Indexes the nodes of a tree by public key hash and txid, and counts its
+// confirmed nodes, so Confirm and Sign do not scan all nodes. Each map and the
+// count are built on first use, updated by Sign and Confirm, and dropped by
+// the other operations that change the nodes of the tree.
+type nodeIndex struct {
+	// Nodes by public key hash, or nil if not built
+	byPkh map[[32]byte]*nyNode
+	// Nodes by txid in the order of the nodes of the tree, or nil if not built
+	byTxid map[[32]byte][]*nyNode
+
+	// The amount of nodes with at least confirmedAt confirmations, if counted
+	confirmed   int
+	confirmedAt uint8
+	counted     bool
+}
+
+// Returns the node of t with public key hash pkh, or nil if there is none.
+func (t *NYTree) nodeByPkh(pkh []byte) *nyNode {
+	if t.idx.byPkh == nil {
+		t.idx.byPkh = make(map[[32]byte]*nyNode, len(t.nodes))
+		for _, node := range t.nodes {
+			t.idx.byPkh[pkhKey(node)] = node
+		}
+	}
+
+	var key [32]byte
+	copy(key[:], pkh)
+
+	return t.idx.byPkh[key]
+}
+
+// Returns the nodes of t with the given txid, in the order of the tree.
+func (t *NYTree) nodesByTxid(txid []byte) []*nyNode {
+	if t.idx.byTxid == nil {
+		t.idx.byTxid = make(map[[32]byte][]*nyNode)
+		for _, node := range t.nodes {
+			key := txidKey(node)
+			t.idx.byTxid[key] = append(t.idx.byTxid[key], node)
+		}
+	}
+
+	var key [32]byte
+	copy(key[:], txid)
+
+	return t.idx.byTxid[key]
+}
+
+// Returns the amount of nodes of t with at least ConfirmsRequired
+// confirmations. The nodes are counted again if ConfirmsRequired changed.
+func (t *NYTree) confirmedNodes() int {
+	if !t.idx.counted || t.idx.confirmedAt != ConfirmsRequired {
+		t.idx.confirmed, t.idx.confirmedAt, t.idx.counted = 0, ConfirmsRequired, true
+		for _, node := range t.nodes {
+			t.idx.confirmed += t.idx.confirmedCount(node.confirms)
+		}
+	}
+
+	return t.idx.confirmed
+}
+
+// Sets the confirmation count of node, one of the nodes of t, to confirms.
+func (t *NYTree) setConfirms(node *nyNode, confirms uint8) {
+	if t.idx.counted {
+		t.idx.confirmed += t.idx.confirmedCount(confirms) - t.idx.confirmedCount(node.confirms)
+	}
+	node.confirms = confirms
+}
+
+// Returns 1 if a node with the given confirmation count is counted as
+// confirmed, and 0 otherwise.
+func (idx *nodeIndex) confirmedCount(confirms uint8) int {
+	if confirms >= idx.confirmedAt {
+		return 1
+	}
+
+	return 0
+}
+
+// Drops the index of t, after its nodes changed.
+func (t *NYTree) dropIndex() {
+	t.idx = nodeIndex{}
+}
+
+// Adds node, which was appended to the nodes of t, to the index.
+func (t *NYTree) indexNode(node *nyNode) {
+	if t.idx.byPkh != nil {
+		t.idx.byPkh[pkhKey(node)] = node
+	}
+	if t.idx.byTxid != nil {
+		key := txidKey(node)
+		t.idx.byTxid[key] = append(t.idx.byTxid[key], node)
+	}
+	if t.idx.counted {
+		t.idx.confirmed += t.idx.confirmedCount(node.confirms)
+	}
+}
+
+// Removes node, which was removed from the nodes of t, from the index.
+func (t *NYTree) unindexNode(node *nyNode) {
+	if t.idx.byPkh != nil {
+		key := pkhKey(node)
+		if t.idx.byPkh[key] == node {
+			delete(t.idx.byPkh, key)
+		}
+	}
+
+	if t.idx.byTxid != nil {
+		key := txidKey(node)
+		nodes := t.idx.byTxid[key]
+		for i := range nodes {
+			if nodes[i] == node {
+				nodes = append(nodes[:i:i], nodes[i+1:]...)
+				break
+			}
+		}
+		if len(nodes) == 0 {
+			delete(t.idx.byTxid, key)
+		} else {
+			t.idx.byTxid[key] = nodes
+		}
+	}
+
+	if t.idx.counted {
+		t.idx.confirmed -= t.idx.confirmedCount(node.confirms)
+	}
+}
+
+// Returns the position of node in the nodes of t, or -1.
+func (t *NYTree) nodeIndex(node *nyNode) int {
+	for i := range t.nodes {
+		if t.nodes[i] == node {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func pkhKey(node *nyNode) (key [32]byte) {
+	copy(key[:], node.pubKeyHash())
+	return
+}
+
+func txidKey(node *nyNode) (key [32]byte) {
+	copy(key[:], node.txid)
+	return
+}
@@ -0,0 +1,82 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_NodeIndex(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	sig, txid, err := signMessage("index test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+
+	// 1 - Child nodes are indexed by public key hash and txid
+	for i, pkh := range sig.ChildHashes {
+		if node := tree.nodeByPkh(pkh); node == nil || node != tree.nodes[i] {
+			t.Fatal("Child node", i, "is not indexed by its public key hash")
+		}
+	}
+	if len(tree.nodesByTxid(txid)) != Branches {
+		t.Fatal("Child nodes are not indexed by txid")
+	}
+
+	// 2 - Public key hashes are serialised, so loaded trees do not compute them
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	for i, node := range loaded.nodes {
+		if !bytes.Equal(node.pkh, sig.ChildHashes[i]) {
+			t.Fatal("Public key hash of node", i, "was not loaded")
+		}
+	}
+
+	// 3 - Confirming uses the index of the loaded tree
+	loaded.Confirm(sig.ChildHashes[1], ConfirmsRequired)
+	if loaded.nodes[1].confirms != ConfirmsRequired || loaded.nodes[0].confirms != 0 {
+		t.Fatal("Confirmed the wrong node")
+	}
+
+	// 4 - Signing with a node removes it from the index
+	if _, err := loaded.Sign(make([]byte, 32), txid); err != nil {
+		t.Fatal("Failed to sign with child node -", err)
+	}
+	if loaded.nodeByPkh(sig.ChildHashes[0]) != nil && loaded.nodeByPkh(sig.ChildHashes[1]) != nil {
+		t.Fatal("Consumed node is still indexed")
+	}
+
+	// 5 - The index counts the confirmed nodes as their confirmations change,
+	// and counts them again if ConfirmsRequired changes
+	counted := func() bool {
+		n := 0
+		for _, node := range loaded.nodes {
+			if node.confirms >= ConfirmsRequired {
+				n++
+			}
+		}
+		return loaded.confirmedNodes() == n
+	}
+	for _, node := range loaded.nodes {
+		loaded.Confirm(node.pubKeyHash(), ConfirmsRequired)
+	}
+	if loaded.confirmedNodes() == 0 || !counted() {
+		t.Fatal("Confirmed nodes are miscounted after Confirm")
+	}
+	loaded.Forecast(map[string]uint8{string(loaded.nodes[0].txid): ConfirmsRequired + 2})
+	loaded.SetConfirms(loaded.nodes[0].pubKeyHash(), 0)
+	if !counted() {
+		t.Fatal("Confirmed nodes are miscounted after SetConfirms")
+	}
+	defer func(c uint8) { ConfirmsRequired = c }(ConfirmsRequired)
+	ConfirmsRequired += 3
+	if loaded.confirmedNodes() != 0 || !counted() {
+		t.Fatal("Confirmed nodes are miscounted after ConfirmsRequired changed")
+	}
+}
@@ -0,0 +1,174 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Layout of the fixed size record that starts every node of a serialised tree
+// (see Bytes): privSeed || pubSeed || txid || confirms. In the current format
+// the record is followed by the fields of the node, see NodeRecord.
+const (
+	NodeRecordLen = 32 + 32 + TxidLen + 1
+
+	NodeRecordPrivSeedOffset = 0
+	NodeRecordPubSeedOffset  = 32
+	NodeRecordTxidOffset     = 64
+	NodeRecordConfirmsOffset = 64 + TxidLen
+)
+
+// A field of a node record that NodeRecord does not know, see
+// NodeRecord.Unknown.
+type NodeField struct {
+	Tag   byte
+	Value []byte
+}
+
+// Decodes and encodes the nodes of serialised trees, for tools that inspect or
+// edit states without loading them. Records parsed by a version of this package
+// that does not know some of the fields keep them in Unknown, and write them
+// back unchanged.
+type NodeRecord struct {
+	PrivSeed []byte
+	PubSeed  []byte
+	Txid     []byte
+	Confirms uint8
+
+	// The cached public key hash, or nil if the record does not hold one
+	PubKeyHash []byte
+	Label      string
+	Depth      uint32
+	// The index of the node at its depth, if Addressed or Indexed, see
+	// NYTree.EnableAddressing and NYTree.SetCompact
+	Index     uint64
+	Addressed bool
+	Indexed   bool
+	// Creation time in Unix seconds, or zero, see NYTree.Prune
+	Created int64
+	// Length of the identifier Txid was hashed from, or zero, see
+	// NYTree.SetTxidHashing
+	TxidLen uint32
+	// Public key hash of the parent of the node, or nil, see NYTree.Topology
+	Parent []byte
+
+	Unknown []NodeField
+}
+
+// Parses a node frame of a serialised tree in the current format, or a bare
+// legacy node record of NodeRecordLen bytes. Frames of compact states (see
+// NYTree.SetCompact) are not node records, and are rejected with
+// ErrNodeInvalidInput unless they happen to parse. The record does not alias b.
+func ParseNodeRecord(b []byte) (*NodeRecord, error) {
+	if len(b) < NodeRecordLen {
+		return nil, ErrNodeInvalidInput
+	}
+
+	r := &NodeRecord{
+		PrivSeed: append([]byte(nil), b[NodeRecordPrivSeedOffset:NodeRecordPubSeedOffset]...),
+		PubSeed:  append([]byte(nil), b[NodeRecordPubSeedOffset:NodeRecordTxidOffset]...),
+		Txid:     append([]byte(nil), b[NodeRecordTxidOffset:NodeRecordConfirmsOffset]...),
+		Confirms: b[NodeRecordConfirmsOffset],
+	}
+
+	err := readFields(b[NodeRecordLen:], func(tag byte, value []byte) error {
+		switch tag {
+		case nodeFieldLabel:
+			r.Label = string(value)
+		case nodeFieldDepth:
+			if len(value) != 4 {
+				return ErrFieldInvalid
+			}
+			r.Depth = binary.BigEndian.Uint32(value)
+		case nodeFieldIndex, nodeFieldDerivedIndex:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			r.Index = binary.BigEndian.Uint64(value)
+			r.Addressed = tag == nodeFieldIndex
+			r.Indexed = tag == nodeFieldDerivedIndex
+		case nodeFieldPkh:
+			if len(value) != 32 {
+				return ErrFieldInvalid
+			}
+			r.PubKeyHash = append([]byte(nil), value...)
+		case nodeFieldCreated:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			r.Created = int64(binary.BigEndian.Uint64(value))
+		case nodeFieldTxidLen:
+			if len(value) != 4 {
+				return ErrFieldInvalid
+			}
+			r.TxidLen = binary.BigEndian.Uint32(value)
+		case nodeFieldParent:
+			if len(value) != 32 {
+				return ErrFieldInvalid
+			}
+			r.Parent = append([]byte(nil), value...)
+		default:
+			r.Unknown = append(r.Unknown, NodeField{Tag: tag, Value: append([]byte(nil), value...)})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Returns the node frame of the record r, in the format read by
+// ParseNodeRecord. Returns ErrNodeInvalidInput if the seeds or the txid of r
+// have the wrong length.
+func (r *NodeRecord) Bytes() ([]byte, error) {
+	if len(r.PrivSeed) != 32 || len(r.PubSeed) != 32 || len(r.Txid) != TxidLen {
+		return nil, ErrNodeInvalidInput
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(r.PrivSeed)
+	buf.Write(r.PubSeed)
+	buf.Write(r.Txid)
+	buf.WriteByte(r.Confirms)
+
+	if r.Label != "" {
+		writeField(buf, nodeFieldLabel, []byte(r.Label))
+	}
+	if r.Depth > 0 {
+		var depth [4]byte
+		binary.BigEndian.PutUint32(depth[:], r.Depth)
+		writeField(buf, nodeFieldDepth, depth[:])
+	}
+	if r.Addressed || r.Indexed {
+		var index [8]byte
+		binary.BigEndian.PutUint64(index[:], r.Index)
+		tag := byte(nodeFieldIndex)
+		if !r.Addressed {
+			tag = nodeFieldDerivedIndex
+		}
+		writeField(buf, tag, index[:])
+	}
+	if r.Created != 0 {
+		var created [8]byte
+		binary.BigEndian.PutUint64(created[:], uint64(r.Created))
+		writeField(buf, nodeFieldCreated, created[:])
+	}
+	if r.TxidLen != 0 {
+		var txidLen [4]byte
+		binary.BigEndian.PutUint32(txidLen[:], r.TxidLen)
+		writeField(buf, nodeFieldTxidLen, txidLen[:])
+	}
+	if r.Parent != nil {
+		writeField(buf, nodeFieldParent, r.Parent)
+	}
+	if r.PubKeyHash != nil {
+		writeField(buf, nodeFieldPkh, r.PubKeyHash)
+	}
+	for _, f := range r.Unknown {
+		writeField(buf, f.Tag, f.Value)
+	}
+
+	return buf.Bytes(), nil
+}
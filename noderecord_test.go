@@ -0,0 +1,54 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestParseNodeRecord(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, err := tree.SignLabel(testdata.Message, testdata.Txid, "", "savings"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Node frames are parsed and encoded again unchanged
+	for i, node := range tree.nodes {
+		frame := tree.nodeFrame(node)
+		r, err := ParseNodeRecord(frame)
+		if err != nil {
+			t.Fatal("Failed to parse node record", i, "-", err)
+		}
+		if !bytes.Equal(r.PubKeyHash, node.pubKeyHash()) || !bytes.Equal(r.Txid, node.txid) ||
+			r.Label != "savings" || r.Depth != 1 || r.Created == 0 {
+			t.Fatalf("Invalid node record %+v", r)
+		}
+		if b, err := r.Bytes(); err != nil || !bytes.Equal(b, frame) {
+			t.Fatal("Node record", i, "encodes differently -", err)
+		}
+	}
+
+	// 2 - Unknown fields are kept
+	buf := bytes.NewBuffer(tree.nodeFrame(tree.nodes[0]))
+	writeField(buf, 0x7f, []byte("future"))
+	r, err := ParseNodeRecord(buf.Bytes())
+	if err != nil {
+		t.Fatal("Failed to parse node record with unknown field -", err)
+	}
+	if len(r.Unknown) != 1 || r.Unknown[0].Tag != 0x7f {
+		t.Fatal("Unknown field was not kept")
+	}
+	if b, _ := r.Bytes(); !bytes.Equal(b, buf.Bytes()) {
+		t.Fatal("Unknown field was not written back")
+	}
+
+	// 3 - Truncated records are rejected
+	if _, err := ParseNodeRecord(buf.Bytes()[:NodeRecordLen-1]); err != ErrNodeInvalidInput {
+		t.Fatal("Parsed truncated record, err was", err)
+	}
+}
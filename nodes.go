@@ -0,0 +1,71 @@
+package xnyss
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Describes a node of a tree without its secret seeds, e.g. for tools that
+// inspect the state of a tree.
+type NodeInfo struct {
+	PubKeyHash []byte
+	Txid       []byte
+	Confirms   uint8
+	// Distance to the root, see Position for addressed trees.
+	Depth uint32
+	Label string
+	// Whether the node is the root node, which can only sign once.
+	Root bool
+	// Whether the node is held by a reservation, see Reserve.
+	Reserved bool
+	// The length of the transaction identifier Txid was hashed from, or zero
+	// if it was not hashed, see NYTree.SetTxidHashing.
+	TxidLen int
+	// The public key hash of the parent of the node, or nil if it is the
+	// root or its parent is not known, see NYTree.Topology.
+	Parent []byte
+}
+
+// Returns descriptions of the nodes of the tree t, in the order they are
+// considered for signing when no node has a matching txid.
+func (t *NYTree) Nodes() []NodeInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.nodeInfos()
+}
+
+func (t *NYTree) nodeInfos() []NodeInfo {
+	nodes := make([]NodeInfo, len(t.nodes))
+	for i, node := range t.nodes {
+		nodes[i] = t.nodeInfo(node)
+	}
+
+	return nodes
+}
+
+// Returns the description of node.
+func (t *NYTree) nodeInfo(node *nyNode) NodeInfo {
+	return NodeInfo{
+		PubKeyHash: append([]byte(nil), node.pubKeyHash()...),
+		Txid:       append([]byte(nil), node.txid...),
+		Confirms:   node.confirms,
+		Depth:      node.depth,
+		Label:      node.label,
+		Root:       t.isRoot(node),
+		Reserved:   node.reservation != 0,
+		TxidLen:    int(node.txidLen),
+		Parent:     append([]byte(nil), node.parent...),
+	}
+}
+
+// Returns the nodes of the tree t in canonical order: ascending by public key
+// hash. Seeds are unique, so no two nodes share a public key hash.
+func (t *NYTree) canonicalNodes() []*nyNode {
+	nodes := append([]*nyNode(nil), t.nodes...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].pubKeyHash(), nodes[j].pubKeyHash()) < 0
+	})
+
+	return nodes
+}
@@ -0,0 +1,55 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_Nodes(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - A fresh tree only holds its confirmed root
+	nodes := tree.Nodes()
+	if len(nodes) != 1 || !nodes[0].Root || nodes[0].Confirms != ConfirmsRequired || nodes[0].Depth != 0 {
+		t.Fatal("Invalid nodes of fresh tree", nodes)
+	}
+
+	// 2 - Children are described after signing
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.SetLabel(sig.ChildHashes[0], "label")
+	if _, err := tree.Reserve(testdata.Txid); err != nil {
+		t.Fatal("Failed to reserve -", err)
+	}
+
+	nodes = tree.Nodes()
+	if len(nodes) != Branches {
+		t.Fatal("Tree has", len(nodes), "nodes, expected", Branches)
+	}
+	var labelled, reserved int
+	for _, node := range nodes {
+		if node.Root || node.Depth != 1 || node.Confirms != 0 || !bytes.Equal(node.Txid, testdata.Txid) {
+			t.Fatal("Invalid child node", node)
+		}
+		if node.Label == "label" {
+			labelled++
+			if !bytes.Equal(node.PubKeyHash, sig.ChildHashes[0]) {
+				t.Fatal("Label was set on the wrong node")
+			}
+		}
+		if node.Reserved {
+			reserved++
+		}
+	}
+	if labelled != 1 || reserved != 1 {
+		t.Fatal("Invalid labels or reservations")
+	}
+}
@@ -0,0 +1,87 @@
+package xnyss
+
+import "bytes"
+
+var (
+	ErrTreeNotOneTime = newError(ErrState, "tree is not a one-time tree")
+	ErrTreeSpentState = newError(ErrState, "spent one-time state contains secret data or nodes")
+)
+
+// Hardens the one-time tree t, see SetOneTimeHardening. One-time trees created
+// by New are hardened already; this hardens those loaded from states that
+// predate the default.
+func (t *NYTree) HardenOneTime() error {
+	return t.SetOneTimeHardening(true)
+}
+
+// Enables or disables the hardening of the one-time tree t, which is enabled
+// for one-time trees created by New. After the signature of a hardened tree is
+// created, the root seed is wiped immediately and the tree is marked as spent;
+// signing again returns ErrTreeExhausted. A spent tree keeps (and serialises)
+// its public key, but no secret data, so a serialised spent state can never be
+// used to sign again. Load refuses spent states that do contain secret data.
+//
+// Without hardening, the tree keeps its root seed after signing, so reloading
+// a state saved before it signed makes it sign again, reusing its one-time key.
+// A spent tree stays spent. Returns ErrTreeNotOneTime if t is a long-term
+// tree.
+func (t *NYTree) SetOneTimeHardening(enabled bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.ots {
+		return ErrTreeNotOneTime
+	}
+
+	if t.hardened != enabled && !t.spent {
+		t.hardened = enabled
+		t.epoch++
+	}
+
+	return nil
+}
+
+// Returns whether t is a hardened one-time tree that has been used.
+func (t *NYTree) Spent() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.spent
+}
+
+// Marks a hardened one-time tree as spent after its signature was created,
+// keeping only its public key.
+func (t *NYTree) spend() {
+	t.pubKey = t.publicKey()
+	t.spent = true
+
+	// The public seed is kept, since the signature aliases it
+	for _, node := range t.nodes {
+		t.consumeReservation(node)
+		node.wipeSeed()
+	}
+	t.nodes = t.nodes[:0]
+	t.dropIndex()
+
+	for i := range t.rootSeed {
+		t.rootSeed[i] = 0
+	}
+	for i := range t.rootMask {
+		t.rootMask[i] = 0
+	}
+	t.rootMask = nil
+}
+
+// Checks that a loaded spent state does not contain secret data.
+func (t *NYTree) checkSpent() error {
+	if !t.spent {
+		return nil
+	}
+
+	if len(t.nodes) > 0 || !bytes.Equal(t.rootSeed, make([]byte, len(t.rootSeed))) ||
+		len(t.pubKey) != t.wots().PubKeyLen() {
+		return ErrTreeSpentState
+	}
+
+	return nil
+}
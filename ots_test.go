@@ -0,0 +1,98 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_HardenOneTime(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New(seed, pubSeed, false).HardenOneTime(); err != ErrTreeNotOneTime {
+		t.Fatal("Hardened a long-term tree, err was", err)
+	}
+
+	tree := New(seed, pubSeed, true)
+	if err := tree.HardenOneTime(); err != nil {
+		t.Fatal("Failed to harden one-time tree -", err)
+	}
+	pubKey := tree.PublicKey()
+
+	// 1 - After signing, the root seed is wiped and the tree is spent
+	if _, _, err := signMessage("one-time signature", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if !tree.Spent() || !bytes.Equal(tree.rootSeed, make([]byte, 32)) {
+		t.Fatal("Tree was not spent after signing")
+	}
+	if !bytes.Equal(tree.PublicKey(), pubKey) {
+		t.Fatal("Spent tree returned the wrong public key")
+	}
+
+	// 2 - The spent state can be loaded, but not used to sign
+	state := tree.Bytes()
+	loaded, err := Load(state)
+	if err != nil {
+		t.Fatal("Failed to load spent state -", err)
+	}
+	if _, _, err := signMessage("second signature", loaded); err != ErrTreeExhausted {
+		t.Fatal("Signed with a spent tree, err was", err)
+	}
+
+	// 3 - Spent states that contain secret data are refused
+	copy(state[stateHeaderLen-64:], seed)
+	reseal(state)
+	if _, err := Load(state); err != ErrTreeSpentState {
+		t.Fatal("Loaded a spent state with a root seed, err was", err)
+	}
+}
+
+func TestNYTree_SetOneTimeHardening(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(seed, pubSeed, false).SetOneTimeHardening(true); err != ErrTreeNotOneTime {
+		t.Fatal("Hardened a long-term tree, err was", err)
+	}
+
+	// 1 - One-time trees are hardened by default, and their signature still
+	// verifies once the tree is spent
+	tree := New(seed, pubSeed, true)
+	pubKey := tree.PublicKey()
+	sig, _, err := signMessage("one-time signature", tree)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if !tree.Spent() || !bytes.Equal(tree.rootSeed, make([]byte, 32)) {
+		t.Fatal("Tree was not spent after signing")
+	}
+	if err := VerifyChain(pubKey, []*Signature{sig}, sig.Message); err != nil {
+		t.Fatal("Failed to verify signature of spent tree -", err)
+	}
+	loaded, err := Load(tree.Bytes())
+	if err != nil || !loaded.Spent() {
+		t.Fatal("Spent flag was not persisted -", err)
+	}
+	if _, _, err := signMessage("second signature", loaded); err != ErrTreeExhausted {
+		t.Fatal("Signed with a spent tree, err was", err)
+	}
+
+	// 2 - Trees that are not hardened keep their root seed
+	tree = New(seed, pubSeed, true)
+	if err := tree.SetOneTimeHardening(false); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := signMessage("one-time signature", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if tree.Spent() || !bytes.Equal(tree.rootSeed, seed) {
+		t.Fatal("Tree without hardening was spent")
+	}
+	if _, _, err := signMessage("second signature", tree); err != ErrTreeExhausted {
+		t.Fatal("Signed twice with a one-time tree, err was", err)
+	}
+}
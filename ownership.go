@@ -0,0 +1,154 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"sort"
+)
+
+var (
+	ErrOwnershipOverlap = newError(ErrState, "states of different tree instances hold the same nodes")
+)
+
+// Identifies an instance of a tree: a tree split off by Backup is a separate
+// instance from the tree it was split from, although both have the same
+// long-term key. The zero OwnerID is the instance of a tree that was never
+// split.
+type OwnerID [16]byte
+
+func (id OwnerID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Returns the identifier of the instance the tree t belongs to. Trees are
+// assigned an identifier when they are split by Backup for the first time, and
+// keep it when serialised.
+func (t *NYTree) Owner() OwnerID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.owner
+}
+
+// Returns the identifier of the instance the tree t was split from by Backup,
+// or the zero OwnerID if t is not a backup.
+func (t *NYTree) DelegatedFrom() OwnerID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.delegatedFrom
+}
+
+// Assigns owner identifiers to the original tree t and its backup.
+func (t *NYTree) assignOwners(backup *NYTree) error {
+	var ids [2]OwnerID
+	if _, err := io.ReadFull(t.random(), ids[0][:]); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(t.random(), ids[1][:]); err != nil {
+		return err
+	}
+
+	if t.owner == (OwnerID{}) {
+		t.owner = ids[0]
+	}
+	backup.owner, backup.delegatedFrom = ids[1], t.owner
+
+	return nil
+}
+
+// Encodes the owner field of the serialised tree: the owner identifier,
+// followed by the identifier of the original tree for backups.
+func (t *NYTree) encodeOwner() []byte {
+	b := append([]byte(nil), t.owner[:]...)
+	if t.delegatedFrom != (OwnerID{}) {
+		b = append(b, t.delegatedFrom[:]...)
+	}
+
+	return b
+}
+
+func (t *NYTree) loadOwner(b []byte) error {
+	if (len(b) != 16 && len(b) != 32) || bytes.Equal(b[:16], make([]byte, 16)) {
+		return ErrFieldInvalid
+	}
+
+	copy(t.owner[:], b)
+	copy(t.delegatedFrom[:], b[16:])
+
+	return nil
+}
+
+// The result of AuditOwnership.
+type OwnershipAudit struct {
+	// The owner identifiers of the audited states
+	Owners [2]OwnerID
+	// Whether one of the states is a backup of the other
+	Delegated bool
+	// Public key hashes of the nodes held by both states, sorted
+	Shared [][]byte
+	// Public key hashes of the nodes held by one state that were consumed by
+	// the other, sorted
+	ConsumedElsewhere [][]byte
+}
+
+// Checks that two serialised states of the same tree do not both hold a node,
+// which could make the node sign twice, e.g. an original tree and its backup
+// (see Backup). Returns ErrTreeMismatch if the states belong to different
+// trees, and ErrOwnershipOverlap, along with the audit, if states of different
+// instances share nodes or hold nodes consumed by the other.
+//
+// States of the same instance, such as an older and a newer snapshot, are
+// expected to share nodes: their audit is returned without error, and
+// CompareEpoch tells which one is current.
+func AuditOwnership(a, b []byte) (*OwnershipAudit, error) {
+	trees := make([]*NYTree, 2)
+	for i, state := range [][]byte{a, b} {
+		tree, err := Load(state)
+		if err != nil {
+			return nil, err
+		}
+		trees[i] = tree
+	}
+	if !bytes.Equal(trees[0].rootPubSeed, trees[1].rootPubSeed) {
+		return nil, ErrTreeMismatch
+	}
+
+	audit := &OwnershipAudit{Owners: [2]OwnerID{trees[0].owner, trees[1].owner}}
+	audit.Delegated = trees[0].delegatedFrom == trees[1].owner || trees[1].delegatedFrom == trees[0].owner
+
+	held := make([]map[[32]byte]bool, 2)
+	for i, tree := range trees {
+		held[i] = make(map[[32]byte]bool, len(tree.nodes))
+		for _, node := range tree.nodes {
+			var pkh [32]byte
+			copy(pkh[:], node.pubKeyHash())
+			held[i][pkh] = true
+		}
+	}
+	for pkh := range held[0] {
+		if held[1][pkh] {
+			audit.Shared = append(audit.Shared, append([]byte(nil), pkh[:]...))
+		}
+	}
+	for i := range trees {
+		for pkh := range held[i] {
+			if trees[1-i].consumed[pkh] {
+				audit.ConsumedElsewhere = append(audit.ConsumedElsewhere, append([]byte(nil), pkh[:]...))
+			}
+		}
+	}
+	sortHashes(audit.Shared)
+	sortHashes(audit.ConsumedElsewhere)
+
+	if audit.Owners[0] != audit.Owners[1] && (len(audit.Shared) > 0 || len(audit.ConsumedElsewhere) > 0) {
+		return audit, ErrOwnershipOverlap
+	}
+
+	return audit, nil
+}
+
+func sortHashes(hashes [][]byte) {
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i], hashes[j]) < 0 })
+}
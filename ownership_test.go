@@ -0,0 +1,55 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestAuditOwnership(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("ownership test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	before := tree.Bytes()
+
+	// 1 - Backups are separate instances of the original tree
+	backup, err := tree.Backup(1)
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	if tree.Owner() == (OwnerID{}) || backup.Owner() == tree.Owner() || backup.DelegatedFrom() != tree.Owner() {
+		t.Fatal("Invalid owners", tree.Owner(), backup.Owner(), backup.DelegatedFrom())
+	}
+	loaded, err := Load(backup.Bytes())
+	if err != nil || loaded.Owner() != backup.Owner() || loaded.DelegatedFrom() != tree.Owner() {
+		t.Fatal("Owners not serialised -", err)
+	}
+
+	// 2 - The original and its backup do not overlap
+	audit, err := AuditOwnership(tree.Bytes(), backup.Bytes())
+	if err != nil {
+		t.Fatal("Audit failed -", err)
+	}
+	if !audit.Delegated || len(audit.Shared) != 0 {
+		t.Fatal("Invalid audit", audit)
+	}
+
+	// 3 - A stale state of the original overlaps with the backup
+	audit, err = AuditOwnership(before, backup.Bytes())
+	if err != ErrOwnershipOverlap || len(audit.Shared) != 1 {
+		t.Fatal("Overlap not detected, err was", err)
+	}
+
+	// 4 - States of different trees are rejected
+	other := New(seed, pubSeed[1:], false)
+	if _, err := AuditOwnership(tree.Bytes(), other.Bytes()); err != ErrTreeMismatch {
+		t.Fatal("Audited states of different trees, err was", err)
+	}
+}
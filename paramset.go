@@ -0,0 +1,242 @@
+package xnyss
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrParamSetUnknown  = newError(ErrEncoding, "unknown or unregistered parameter set")
+	ErrParamSetInvalid  = newError(ErrState, "invalid parameter set")
+	ErrParamSetConflict = newError(ErrState, "parameter set identifier is registered with other parameters")
+)
+
+// Identifies a parameter set in serialised trees and signature envelopes.
+// Identifiers are stable: once assigned, they always denote the same
+// parameters.
+type ParamSetID uint16
+
+// The parameters of the keys of a tree: everything needed to verify its
+// signatures, and the branching factor of its nodes.
+type ParamSet struct {
+	ID ParamSetID
+	// The hash suite of the keys, see NYTree.SetHashSuite.
+	HashSuite HashSuite
+	// The Winternitz parameter of the keys, see NYTree.SetWinternitz.
+	Winternitz int
+	// The length of hashes and keys in bytes. Only 32 is supported.
+	N int
+	// The branching factor of the nodes, see Branches.
+	Branches int
+}
+
+// Identifiers of the parameter sets registered by this package. Trees and
+// signatures using ParamsDefault do not record their parameter set.
+const (
+	ParamsDefault        ParamSetID = 0x0001
+	ParamsSHA256W16      ParamSetID = 0x0002
+	ParamsSHA256W4       ParamSetID = 0x0003
+	ParamsSHA3_256W256   ParamSetID = 0x0004
+	ParamsSHA3_256W16    ParamSetID = 0x0005
+	ParamsSHA3_256W4     ParamSetID = 0x0006
+	ParamsBLAKE2b256W256 ParamSetID = 0x0007
+	ParamsBLAKE2b256W16  ParamSetID = 0x0008
+	ParamsBLAKE2b256W4   ParamSetID = 0x0009
+)
+
+var (
+	paramSetsMu sync.RWMutex
+	paramSets   = map[ParamSetID]ParamSet{
+		ParamsDefault:        {ParamsDefault, SuiteSHA256, 256, 32, 3},
+		ParamsSHA256W16:      {ParamsSHA256W16, SuiteSHA256, 16, 32, 3},
+		ParamsSHA256W4:       {ParamsSHA256W4, SuiteSHA256, 4, 32, 3},
+		ParamsSHA3_256W256:   {ParamsSHA3_256W256, SuiteSHA3_256, 256, 32, 3},
+		ParamsSHA3_256W16:    {ParamsSHA3_256W16, SuiteSHA3_256, 16, 32, 3},
+		ParamsSHA3_256W4:     {ParamsSHA3_256W4, SuiteSHA3_256, 4, 32, 3},
+		ParamsBLAKE2b256W256: {ParamsBLAKE2b256W256, SuiteBLAKE2b256, 256, 32, 3},
+		ParamsBLAKE2b256W16:  {ParamsBLAKE2b256W16, SuiteBLAKE2b256, 16, 32, 3},
+		ParamsBLAKE2b256W4:   {ParamsBLAKE2b256W4, SuiteBLAKE2b256, 4, 32, 3},
+	}
+)
+
+// Registers the parameter set p under p.ID, so trees and signatures using it
+// can be serialised and loaded. The hash suite of p does not need to be
+// registered yet, but must be before the set is used.
+//
+// The registry is strict: returns ErrParamSetConflict if p.ID is already
+// registered with other parameters, and ErrParamSetInvalid if p is not valid.
+// Registering the same set twice is allowed.
+func RegisterParamSet(p ParamSet) error {
+	if p.ID == 0 || p.HashSuite == 0 || validWinternitz(p.Winternitz) != nil || p.N != 32 ||
+		p.Branches < 1 || p.Branches > 255 {
+		return ErrParamSetInvalid
+	}
+
+	paramSetsMu.Lock()
+	defer paramSetsMu.Unlock()
+
+	if registered, ok := paramSets[p.ID]; ok {
+		if registered != p {
+			return ErrParamSetConflict
+		}
+		return nil
+	}
+	paramSets[p.ID] = p
+
+	return nil
+}
+
+// Returns the parameter set registered under id, or ErrParamSetUnknown.
+func LookupParamSet(id ParamSetID) (ParamSet, error) {
+	paramSetsMu.RLock()
+	defer paramSetsMu.RUnlock()
+
+	p, ok := paramSets[id]
+	if !ok {
+		return ParamSet{}, ErrParamSetUnknown
+	}
+
+	return p, nil
+}
+
+// Returns all registered parameter sets, ordered by identifier.
+func ParamSets() []ParamSet {
+	paramSetsMu.RLock()
+	defer paramSetsMu.RUnlock()
+
+	sets := make([]ParamSet, 0, len(paramSets))
+	for _, p := range paramSets {
+		sets = append(sets, p)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].ID < sets[j].ID })
+
+	return sets
+}
+
+// Returns the registered parameter set with the lowest identifier that matches
+// the given hash suite and Winternitz parameter (both zero for the defaults),
+// and branching factor. If branches is zero, any branching factor matches.
+func findParamSet(suite HashSuite, w uint16, branches int) (ParamSet, bool) {
+	if suite == 0 {
+		suite = SuiteSHA256
+	}
+
+	for _, p := range ParamSets() {
+		if p.HashSuite == suite && p.Winternitz == winternitz(w) && (branches == 0 || p.Branches == branches) {
+			return p, true
+		}
+	}
+
+	return ParamSet{}, false
+}
+
+// Returns the hash suite and Winternitz parameter of p as stored by trees and
+// signatures, where zero denotes the default. Returns ErrHashSuiteUnknown if
+// the hash suite of p is not registered.
+func (p ParamSet) keyParams() (HashSuite, uint16, error) {
+	if _, err := p.HashSuite.lookup(); err != nil {
+		return 0, 0, err
+	}
+
+	suite, w := p.HashSuite, uint16(p.Winternitz)
+	if suite == SuiteSHA256 {
+		suite = 0
+	}
+	if w == DefaultWinternitz {
+		w = 0
+	}
+
+	return suite, w, nil
+}
+
+// Returns the registered parameter set of the tree t, and whether there is one.
+// Trees that use a custom hash suite or branching schedule may not have one;
+// their serialised state records their parameters individually.
+func (t *NYTree) ParamSet() (ParamSet, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.paramSet()
+}
+
+func (t *NYTree) paramSet() (ParamSet, bool) {
+	return findParamSet(t.suite, t.w, t.branchesAt(0))
+}
+
+// Returns the registered parameter set of the tree that created sig, and
+// whether there is one. The branching factor is only taken into account if
+// the parameters of sig are known.
+func (sig *Signature) ParamSet() (ParamSet, bool) {
+	branches := 0
+	if sig.params != nil {
+		branches = sig.params.Branches
+	}
+
+	return findParamSet(sig.suite, sig.w, branches)
+}
+
+// Creates a tree like New, whose keys use the registered parameter set id, e.g.
+// ParamsSHA256W16 for WOTS+ with w=16, which verifies faster than the default
+// at the cost of larger signatures. The parameter set is recorded in the
+// serialised tree and in signature envelopes, so verifiers handle trees of
+// different parameter sets side by side. Returns ErrParamSetUnknown if id is
+// not registered.
+func NewParamSet(id ParamSetID, seed, pubSeed []byte, ots bool) (*NYTree, error) {
+	p, err := LookupParamSet(id)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := New(seed, pubSeed, ots)
+	if err := tree.SetHashSuite(p.HashSuite); err != nil {
+		return nil, err
+	}
+	if err := tree.SetWinternitz(p.Winternitz); err != nil {
+		return nil, err
+	}
+	if p.Branches != Branches {
+		if err := tree.SetBranching([]int{p.Branches}); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree, nil
+}
+
+// Like NewSignature, for signatures created by trees using the registered
+// parameter set id. Returns ErrParamSetUnknown if id is not registered.
+func NewSignatureParamSet(id ParamSetID, sigBytes, msg []byte) (*Signature, error) {
+	p, err := LookupParamSet(id)
+	if err != nil {
+		return nil, err
+	}
+	suite, w, err := p.keyParams()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := newSignature(w, sigBytes, msg)
+	if err != nil {
+		return nil, err
+	}
+	sig.suite = suite
+
+	return sig, nil
+}
+
+// Encodes the identifier of a parameter set as a field value.
+func encodeParamSetID(id ParamSetID) []byte {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(id))
+	return b[:]
+}
+
+// Decodes and resolves the parameter set identified by a field value.
+func decodeParamSetID(value []byte) (ParamSet, error) {
+	if len(value) != 2 {
+		return ParamSet{}, ErrFieldInvalid
+	}
+
+	return LookupParamSet(ParamSetID(binary.BigEndian.Uint16(value)))
+}
@@ -0,0 +1,131 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestRegisterParamSet(t *testing.T) {
+	// 1 - Identifiers can not be reassigned
+	p, err := LookupParamSet(ParamsSHA3_256W16)
+	if err != nil {
+		t.Fatal("Built-in parameter set is not registered -", err)
+	}
+	if err := RegisterParamSet(p); err != nil {
+		t.Fatal("Failed to register identical parameter set -", err)
+	}
+	p.Winternitz = 4
+	if err := RegisterParamSet(p); err != ErrParamSetConflict {
+		t.Fatal("Reassigned parameter set identifier, err was", err)
+	}
+
+	// 2 - Invalid parameters are rejected
+	for _, invalid := range []ParamSet{
+		{ID: 0, HashSuite: SuiteSHA256, Winternitz: 16, N: 32, Branches: 3},
+		{ID: 0x8001, HashSuite: SuiteSHA256, Winternitz: 8, N: 32, Branches: 3},
+		{ID: 0x8001, HashSuite: SuiteSHA256, Winternitz: 16, N: 64, Branches: 3},
+		{ID: 0x8001, HashSuite: SuiteSHA256, Winternitz: 16, N: 32, Branches: 0},
+	} {
+		if err := RegisterParamSet(invalid); err != ErrParamSetInvalid {
+			t.Fatal("Registered invalid parameter set, err was", err)
+		}
+	}
+	if _, err := LookupParamSet(0x8001); err != ErrParamSetUnknown {
+		t.Fatal("Found unregistered parameter set, err was", err)
+	}
+}
+
+func TestNYTree_ParamSet(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if p, ok := tree.ParamSet(); !ok || p.ID != ParamsDefault {
+		t.Fatal("Fresh tree does not use the default parameter set")
+	}
+	if err := tree.SetHashSuite(SuiteSHA3_256); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SetWinternitz(16); err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := tree.ParamSet(); !ok || p.ID != ParamsSHA3_256W16 {
+		t.Fatal("Invalid parameter set of tree")
+	}
+
+	// 1 - Serialised trees record and resolve their parameter set
+	state := tree.Bytes()
+	loaded, err := Load(state)
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !bytes.Equal(loaded.PublicKey(), tree.PublicKey()) {
+		t.Fatal("Loaded tree has another public key")
+	}
+
+	// 2 - Envelopes record and resolve the parameter set
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	parsed, err := ParseEnvelope(sig.Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if p, ok := parsed.ParamSet(); !ok || p.ID != ParamsSHA3_256W16 {
+		t.Fatal("Parameter set was not included in the envelope")
+	}
+	if pk, err := parsed.PublicKey(); err != nil || !bytes.Equal(pk, tree.PublicKey()) {
+		t.Fatal("Failed to verify parsed signature -", err)
+	}
+
+	// 3 - Signatures can be decoded using their parameter set
+	decoded, err := NewSignatureParamSet(ParamsSHA3_256W16, sig.Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to decode signature -", err)
+	}
+	if pk, err := decoded.PublicKey(); err != nil || !bytes.Equal(pk, tree.PublicKey()) {
+		t.Fatal("Failed to verify decoded signature -", err)
+	}
+	if _, err := NewSignatureParamSet(0x8002, sig.Bytes(), testdata.Message); err != ErrParamSetUnknown {
+		t.Fatal("Decoded signature with unknown parameter set, err was", err)
+	}
+}
+
+func TestNewParamSet(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Trees of different parameter sets verify side by side
+	w16, err := NewParamSet(ParamsSHA256W16, seed, pubSeed, false)
+	if err != nil {
+		t.Fatal("Failed to create tree -", err)
+	}
+	w256 := New(seed, pubSeed, false)
+	if w16.Winternitz() != 16 || bytes.Equal(w16.PublicKey(), w256.PublicKey()) {
+		t.Fatal("Tree does not use its parameter set")
+	}
+	for _, tree := range []*NYTree{w16, w256} {
+		sig, err := tree.Sign(testdata.Message, testdata.Txid)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		parsed, err := ParseEnvelope(sig.Envelope(), testdata.Message)
+		if err != nil {
+			t.Fatal("Failed to parse envelope -", err)
+		}
+		if err := VerifyChain(tree.PublicKey(), []*Signature{parsed}, testdata.Message); err != nil {
+			t.Fatal("Failed to verify signature of w =", tree.Winternitz(), "-", err)
+		}
+	}
+
+	// 2 - Unknown parameter sets are rejected
+	if _, err := NewParamSet(0x8002, seed, pubSeed, false); err != ErrParamSetUnknown {
+		t.Fatal("Created tree with unknown parameter set, err was", err)
+	}
+}
@@ -0,0 +1,246 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrInvalidDER = newError(ErrEncoding, "invalid DER encoding")
+	ErrInvalidPEM = newError(ErrEncoding, "invalid PEM encoding")
+)
+
+// The types of the PEM blocks of public keys and signatures.
+const (
+	PEMPublicKey = "XNYSS PUBLIC KEY"
+	PEMSignature = "XNYSS SIGNATURE"
+)
+
+// The object identifier of XNYSS, below the UUID arc of ITU-T X.667. Its last
+// arc exceeds the range of asn1.ObjectIdentifier, so it is encoded by hand.
+const OIDXNYSS = "2.25.333871447990738402178677461156495161115"
+
+// The DER encoding of OIDXNYSS.
+var oidXNYSSDER = []byte{
+	0x06, 0x14,
+	0x69, 0x83, 0xf6, 0xad, 0xa6, 0xd2, 0xe0, 0xfe, 0x8a, 0xbf,
+	0xf7, 0xb2, 0x9c, 0xe0, 0x8c, 0xe2, 0xd6, 0xb3, 0xee, 0x1b,
+}
+
+// The long-term public key of a tree, with the parameters needed to verify its
+// signatures.
+type PublicKeyInfo struct {
+	PublicKey  []byte
+	HashSuite  HashSuite
+	Winternitz int
+}
+
+// The algorithm identifier of XNYSS, in ASN.1:
+//
+//	AlgorithmIdentifier ::= SEQUENCE {
+//	    algorithm   OBJECT IDENTIFIER,
+//	    parameters  SEQUENCE { hashSuite INTEGER, winternitz INTEGER } }
+type algorithmIdentifier struct {
+	Algorithm  asn1.RawValue
+	Parameters algorithmParams
+}
+
+type algorithmParams struct {
+	HashSuite  int
+	Winternitz int
+}
+
+// The encoding of a public key, in ASN.1:
+//
+//	PublicKeyInfo ::= SEQUENCE {
+//	    algorithm  AlgorithmIdentifier,
+//	    publicKey  BIT STRING }
+type publicKeyASN1 struct {
+	Algorithm algorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// The encoding of a signature, in ASN.1:
+//
+//	Signature ::= SEQUENCE {
+//	    algorithm  AlgorithmIdentifier,
+//	    message    OCTET STRING,
+//	    envelope   OCTET STRING }
+type signatureASN1 struct {
+	Algorithm algorithmIdentifier
+	Message   []byte
+	Envelope  []byte
+}
+
+func newAlgorithmIdentifier(s HashSuite, w int) algorithmIdentifier {
+	return algorithmIdentifier{
+		Algorithm:  asn1.RawValue{FullBytes: oidXNYSSDER},
+		Parameters: algorithmParams{HashSuite: int(s), Winternitz: w},
+	}
+}
+
+// Returns the hash suite and Winternitz parameter of a, or ErrInvalidDER if
+// a does not identify XNYSS or has invalid parameters.
+func (a algorithmIdentifier) params() (HashSuite, int, error) {
+	p := a.Parameters
+	if !bytes.Equal(a.Algorithm.FullBytes, oidXNYSSDER) || p.HashSuite <= 0 || p.HashSuite > 0xff {
+		return 0, 0, ErrInvalidDER
+	}
+	if validWinternitz(p.Winternitz) != nil {
+		return 0, 0, ErrInvalidDER
+	}
+
+	return HashSuite(p.HashSuite), p.Winternitz, nil
+}
+
+// Decodes the DER encoding b into v, which must use all of b.
+func unmarshalDER(b []byte, v interface{}) error {
+	rest, err := asn1.Unmarshal(b, v)
+	if err != nil || len(rest) != 0 {
+		return ErrInvalidDER
+	}
+
+	return nil
+}
+
+// Returns the DER encoding of the long-term public key of the tree t, see
+// PublicKeyInfo. Returns ErrTreeWiped if t was wiped.
+func (t *NYTree) PublicKeyDER() ([]byte, error) {
+	t.mu.Lock()
+	pubKey := t.publicKey()
+	suite := t.suite
+	w := winternitz(t.w)
+	t.mu.Unlock()
+
+	if pubKey == nil {
+		return nil, ErrTreeWiped
+	}
+
+	return PublicKeyInfo{PublicKey: pubKey, HashSuite: suite, Winternitz: w}.DER()
+}
+
+// Like PublicKeyDER, armored in a PEMPublicKey block.
+func (t *NYTree) PublicKeyPEM() ([]byte, error) {
+	der, err := t.PublicKeyDER()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMPublicKey, Bytes: der}), nil
+}
+
+// Returns the DER encoding of the public key info k. The zero hash suite is
+// SuiteSHA256, and the zero Winternitz parameter DefaultWinternitz.
+func (k PublicKeyInfo) DER() ([]byte, error) {
+	if k.HashSuite == 0 {
+		k.HashSuite = SuiteSHA256
+	}
+	if k.Winternitz == 0 {
+		k.Winternitz = DefaultWinternitz
+	}
+	if err := validWinternitz(k.Winternitz); err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(publicKeyASN1{
+		Algorithm: newAlgorithmIdentifier(k.HashSuite, k.Winternitz),
+		PublicKey: asn1.BitString{Bytes: k.PublicKey, BitLength: 8 * len(k.PublicKey)},
+	})
+}
+
+// Decodes a public key encoded by PublicKeyDER. Returns ErrInvalidDER if b is
+// malformed, or the length of the key does not match its parameters. The hash
+// suite need not be registered.
+func ParsePublicKeyDER(b []byte) (*PublicKeyInfo, error) {
+	var v publicKeyASN1
+	if err := unmarshalDER(b, &v); err != nil {
+		return nil, err
+	}
+	suite, w, err := v.Algorithm.params()
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey := v.PublicKey.RightAlign()
+	if v.PublicKey.BitLength%8 != 0 || len(pubKey) != (wotsp.Params{W: w, N: 32}).PubKeyLen() {
+		return nil, ErrInvalidDER
+	}
+
+	return &PublicKeyInfo{
+		PublicKey:  append([]byte(nil), pubKey...),
+		HashSuite:  suite,
+		Winternitz: w,
+	}, nil
+}
+
+// Decodes a public key encoded by PublicKeyPEM. Returns ErrInvalidPEM if b does
+// not start with a PEMPublicKey block, and the errors of ParsePublicKeyDER.
+func ParsePublicKeyPEM(b []byte) (*PublicKeyInfo, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != PEMPublicKey {
+		return nil, ErrInvalidPEM
+	}
+
+	return ParsePublicKeyDER(block.Bytes)
+}
+
+// Returns the DER encoding of the signature sig, holding its message and
+// envelope (see Envelope).
+func (sig *Signature) DER() ([]byte, error) {
+	return asn1.Marshal(signatureASN1{
+		Algorithm: newAlgorithmIdentifier(sig.HashSuite(), sig.Winternitz()),
+		Message:   sig.Message,
+		Envelope:  sig.Envelope(),
+	})
+}
+
+// Like DER, armored in a PEMSignature block.
+func (sig *Signature) PEM() ([]byte, error) {
+	der, err := sig.DER()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMSignature, Bytes: der}), nil
+}
+
+// Decodes a signature encoded by Signature.DER. Returns ErrInvalidDER if b is
+// malformed or its parameters do not match the envelope, and the errors of
+// ParseEnvelope if the envelope is invalid.
+func ParseSignatureDER(b []byte) (*Signature, error) {
+	var v signatureASN1
+	if err := unmarshalDER(b, &v); err != nil {
+		return nil, err
+	}
+	suite, w, err := v.Algorithm.params()
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := ParseEnvelope(v.Envelope, v.Message)
+	if err != nil {
+		return nil, err
+	}
+	if sig.HashSuite() != suite || sig.Winternitz() != w {
+		return nil, ErrInvalidDER
+	}
+	if len(v.Message) == 0 {
+		sig.Message = nil
+	}
+
+	return sig, nil
+}
+
+// Decodes a signature encoded by Signature.PEM. Returns ErrInvalidPEM if b does
+// not start with a PEMSignature block, and the errors of ParseSignatureDER.
+func ParseSignaturePEM(b []byte) (*Signature, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != PEMSignature {
+		return nil, ErrInvalidPEM
+	}
+
+	return ParseSignatureDER(block.Bytes)
+}
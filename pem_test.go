@@ -0,0 +1,150 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestPublicKeyPEM(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetWinternitz(16); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.SetHashSuite(SuiteSHA3_256); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Public keys round trip with their parameters
+	b, err := tree.PublicKeyPEM()
+	if err != nil {
+		t.Fatal("Failed to encode public key -", err)
+	}
+	if !strings.HasPrefix(string(b), "-----BEGIN "+PEMPublicKey+"-----") {
+		t.Fatal("Invalid PEM block", string(b))
+	}
+	info, err := ParsePublicKeyPEM(b)
+	if err != nil {
+		t.Fatal("Failed to parse public key -", err)
+	}
+	if !bytes.Equal(info.PublicKey, tree.PublicKey()) || info.HashSuite != SuiteSHA3_256 || info.Winternitz != 16 {
+		t.Fatal("Invalid parsed public key", info)
+	}
+
+	// 2 - Keys whose length does not match their parameters are rejected
+	info.Winternitz = 256
+	der, err := info.DER()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePublicKeyDER(der); err != ErrInvalidDER {
+		t.Fatal("Parsed key of invalid length, err was", err)
+	}
+
+	// 3 - Trailing data and other blocks are rejected
+	der, err = tree.PublicKeyDER()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePublicKeyDER(append(der, 0)); err != ErrInvalidDER {
+		t.Fatal("Parsed key with trailing data, err was", err)
+	}
+	other := pem.EncodeToMemory(&pem.Block{Type: PEMSignature, Bytes: der})
+	if _, err := ParsePublicKeyPEM(other); err != ErrInvalidPEM {
+		t.Fatal("Parsed key from signature block, err was", err)
+	}
+
+	// 4 - Wiped trees have no public key
+	tree.Wipe()
+	if _, err := tree.PublicKeyPEM(); err != ErrTreeWiped {
+		t.Fatal("Encoded public key of wiped tree, err was", err)
+	}
+}
+
+func TestSignaturePEM(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("pem test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 - Signatures round trip, keeping their envelope
+	b, err := sig.PEM()
+	if err != nil {
+		t.Fatal("Failed to encode signature -", err)
+	}
+	decoded, err := ParseSignaturePEM(b)
+	if err != nil {
+		t.Fatal("Failed to parse signature -", err)
+	}
+	if !bytes.Equal(decoded.Envelope(), sig.Envelope()) || !bytes.Equal(decoded.Message, sig.Message) {
+		t.Fatal("Invalid parsed signature")
+	}
+
+	// 2 - Parameters that do not match the envelope are rejected
+	der, err := sig.DER()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v signatureASN1
+	if _, err := asn1.Unmarshal(der, &v); err != nil {
+		t.Fatal(err)
+	}
+	v.Algorithm.Parameters.Winternitz = 16
+	tampered, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSignatureDER(tampered); err != ErrInvalidDER {
+		t.Fatal("Parsed signature with mismatching parameters, err was", err)
+	}
+
+	// 3 - Other algorithms are rejected
+	v.Algorithm = newAlgorithmIdentifier(sig.HashSuite(), sig.Winternitz())
+	v.Algorithm.Algorithm = asn1.RawValue{}
+	oid, err := asn1.Marshal(asn1.ObjectIdentifier{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Algorithm.Algorithm.FullBytes = oid
+	tampered, err = asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseSignatureDER(tampered); err != ErrInvalidDER {
+		t.Fatal("Parsed signature of other algorithm, err was", err)
+	}
+}
+
+func TestOIDXNYSS(t *testing.T) {
+	// 1 - The DER encoding matches the dotted form
+	arc, ok := new(big.Int).SetString(strings.TrimPrefix(OIDXNYSS, "2.25."), 10)
+	if !ok {
+		t.Fatal("Invalid OID", OIDXNYSS)
+	}
+	var content []byte
+	for first := true; first || arc.Sign() > 0; first = false {
+		digit := byte(arc.Uint64() & 0x7f)
+		if !first {
+			digit |= 0x80
+		}
+		content = append([]byte{digit}, content...)
+		arc.Rsh(arc, 7)
+	}
+	content = append([]byte{2*40 + 25}, content...)
+	expected := append([]byte{0x06, byte(len(content))}, content...)
+	if !bytes.Equal(expected, oidXNYSSDER) {
+		t.Fatal("Invalid DER encoding of OID", expected)
+	}
+}
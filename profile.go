@@ -0,0 +1,64 @@
+package xnyss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"runtime/pprof"
+	"time"
+)
+
+// Denotes whether Sign, Confirm and signature verification are executed with
+// pprof labels attached. The labels are "xnyss_op", set to the operation name,
+// and "xnyss_tree", set to the ID of the tree that performs the operation. Since
+// labels are inherited by goroutines, CPU samples of the WOTS+ hash chains
+// computed on behalf of an operation are attributed to it as well.
+var ProfileLabels = false
+
+// When not nil, OpAccounting is called after every Sign, Confirm and
+// signature verification with the name of the operation, the ID of the tree
+// (empty for verification) and the time it took to complete. It can be used to
+// account CPU usage per operation when multiple trees share a process.
+var OpAccounting func(op, treeID string, elapsed time.Duration)
+
+// Operation names used in pprof labels and passed to OpAccounting.
+const (
+	OpSign    = "sign"
+	OpConfirm = "confirm"
+	OpVerify  = "verify"
+)
+
+// Returns an identifier of the tree t that is stable across serialisation and
+// backups: the first 8 bytes of H(rootPubSeed), hex encoded. It is not secret,
+// and is only meant to tell trees apart in profiles and logs.
+func (t *NYTree) ID() string {
+	h := sha256.Sum256(t.rootPubSeed)
+	return hex.EncodeToString(h[:8])
+}
+
+// Runs f on behalf of the tree t, or of no tree if t is nil, attaching pprof
+// labels and accounting for the time spent if enabled. The ID of t is only
+// computed then, so disabled profiling costs nothing.
+func profile(op string, t *NYTree, f func()) {
+	if !ProfileLabels && OpAccounting == nil {
+		f()
+		return
+	}
+
+	treeID := ""
+	if t != nil {
+		treeID = t.ID()
+	}
+
+	start := time.Now()
+	if ProfileLabels {
+		labels := pprof.Labels("xnyss_op", op, "xnyss_tree", treeID)
+		pprof.Do(context.Background(), labels, func(context.Context) { f() })
+	} else {
+		f()
+	}
+
+	if OpAccounting != nil {
+		OpAccounting(op, treeID, time.Since(start))
+	}
+}
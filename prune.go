@@ -0,0 +1,68 @@
+package xnyss
+
+import "time"
+
+// Selects the nodes removed by Prune. Only unconfirmed nodes are pruned.
+type PrunePolicy struct {
+	// Txids of transactions that will never be confirmed, e.g. because they
+	// were double spent or dropped from the mempool
+	Abandoned [][]byte
+	// If not zero, nodes created before this time that are still unconfirmed
+	// are pruned. Nodes loaded from states that did not record their creation
+	// time are never pruned by age.
+	UnconfirmedBefore time.Time
+}
+
+// Removes the unconfirmed nodes of the tree t selected by the policy, and
+// returns their public key hashes so the wallet can log them. Unlike
+// InvalidateTxid, the descendants of pruned nodes are not removed: only
+// unconfirmed nodes are pruned, and those have not signed yet. Pruned nodes are
+// wiped, and are not marked as consumed.
+func (t *NYTree) Prune(policy PrunePolicy) (pruned [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	abandoned := make(map[[32]byte]bool, len(policy.Abandoned))
+	for _, txid := range policy.Abandoned {
+		var key [32]byte
+		copy(key[:], t.lookupTxid(txid))
+		abandoned[key] = true
+	}
+
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		if t.prunable(node, policy, abandoned) {
+			pruned = append(pruned, append([]byte(nil), node.pubKeyHash()...))
+			t.consumeReservation(node)
+			node.wipe()
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	t.nodes = nodes
+	t.dropIndex()
+
+	if len(pruned) > 0 {
+		t.epoch++
+		t.log(LevelAudit, "nodes pruned", "nodes", len(pruned))
+		t.tryWriteThrough()
+	}
+
+	return
+}
+
+// Returns whether node n is removed by Prune with the given policy.
+func (t *NYTree) prunable(n *nyNode, policy PrunePolicy, abandoned map[[32]byte]bool) bool {
+	if n.confirms >= ConfirmsRequired || t.isRoot(n) {
+		return false
+	}
+
+	var txid [32]byte
+	copy(txid[:], n.txid)
+	if abandoned[txid] {
+		return true
+	}
+
+	return !policy.UnconfirmedBefore.IsZero() && n.created != 0 &&
+		n.created < policy.UnconfirmedBefore.Unix()
+}
@@ -0,0 +1,52 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_Prune(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	abandoned := Txid("prune", []byte{1})
+	dropped, err := tree.Sign(testdata.Message, abandoned)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// 1 - Nodes of abandoned txids are pruned
+	pruned := tree.Prune(PrunePolicy{Abandoned: [][]byte{abandoned}})
+	if len(pruned) != Branches {
+		t.Fatal("Pruned", len(pruned), "nodes of abandoned txid")
+	}
+	for _, pkh := range dropped.ChildHashes {
+		if !containsHash(pruned, pkh) || tree.nodeByPkh(pkh) != nil {
+			t.Fatal("Node of abandoned txid was not pruned")
+		}
+	}
+
+	// 2 - Unconfirmed nodes created before the cutoff are pruned, also after
+	// loading the tree
+	tree, err = Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if pruned := tree.Prune(PrunePolicy{UnconfirmedBefore: time.Now().Add(-time.Hour)}); len(pruned) != 0 {
+		t.Fatal("Pruned", len(pruned), "recent nodes")
+	}
+	pruned = tree.Prune(PrunePolicy{UnconfirmedBefore: time.Now().Add(time.Hour)})
+	if len(pruned) != Branches-1 || len(tree.nodes) != 0 {
+		t.Fatal("Pruned", len(pruned), "old unconfirmed nodes, left", len(tree.nodes))
+	}
+}
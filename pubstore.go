@@ -0,0 +1,165 @@
+package xnyss
+
+import "bytes"
+
+// Enables write-through mode for the public tree pt, like NYTree.SetStorage:
+// the tree is written to s, and from then on every observed signature and
+// confirmation is recorded in s, so a verifier that restarts loads the nodes it
+// followed with LoadPubTreeStorage instead of observing every signature from
+// the root again. The storage holds the encoding of pt without its nodes as
+// header, and one record per node, keyed by its public key hash. Nodes are
+// never removed, since used nodes are needed to detect reuse.
+//
+// Observe returns the error of writing its changes, while Confirm writes its
+// change with the next one if writing fails; either way the changes are kept
+// in memory, see Sync. Records of s that do not belong to pt are deleted.
+// Passing nil disables write-through mode.
+func (pt *NYPubTree) SetStorage(s Storage) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	pt.storage, pt.dirty, pt.headerDirty = nil, nil, false
+	if s == nil {
+		return nil
+	}
+
+	var stale [][]byte
+	err := s.ForEachNode(func(pkh, _ []byte) error {
+		var key [32]byte
+		copy(key[:], pkh)
+		if _, ok := pt.nodes[key]; !ok {
+			stale = append(stale, pkh)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, pkh := range stale {
+		if err := s.DeleteNode(pkh); err != nil {
+			return err
+		}
+	}
+
+	pt.storage, pt.headerDirty = s, true
+	for pkh := range pt.nodes {
+		pt.markDirty(pkh)
+	}
+
+	return pt.writeThrough()
+}
+
+// Returns the storage of the public tree pt, or nil if it is not in
+// write-through mode.
+func (pt *NYPubTree) Storage() Storage {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	return pt.storage
+}
+
+// Writes all changes of the public tree pt that were not written to its
+// storage yet, see SetStorage. Does nothing if pt is not in write-through mode.
+func (pt *NYPubTree) Sync() error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	return pt.writeThrough()
+}
+
+// Records that the node with public key hash pkh changed since the previous
+// write, if pt has a storage.
+func (pt *NYPubTree) markDirty(pkh [32]byte) {
+	if pt.storage == nil {
+		return
+	}
+	if pt.dirty == nil {
+		pt.dirty = make(map[[32]byte]bool)
+	}
+	pt.dirty[pkh] = true
+}
+
+// Writes the changes of pt since the previous write to its storage, if it has
+// one. The header and the nodes that signed are written before new nodes, so
+// a crash halfway never stores a node whose parent is not marked as used.
+func (pt *NYPubTree) writeThrough() error {
+	if pt.storage == nil {
+		return nil
+	}
+	if err := injectPersistFault(); err != nil {
+		return err
+	}
+
+	if pt.headerDirty {
+		if err := pt.storage.PutHeader(pt.header()); err != nil {
+			return err
+		}
+		pt.headerDirty = false
+	}
+	for _, used := range []bool{true, false} {
+		for pkh := range pt.dirty {
+			if pt.nodes[pkh].used != used {
+				continue
+			}
+			if err := pt.storage.PutNode(pkh[:], pt.record(pkh)); err != nil {
+				return err
+			}
+			delete(pt.dirty, pkh)
+		}
+	}
+
+	return nil
+}
+
+// Loads the public tree stored in s, and enables write-through mode on it, see
+// NYPubTree.SetStorage. Returns ErrStorageEmpty if s holds no header, and
+// ErrInvalidStorage if a node is not stored under its public key hash, or its
+// parent is not stored, which means records were lost or damaged.
+func LoadPubTreeStorage(s Storage) (*NYPubTree, error) {
+	header, err := s.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, ErrStorageEmpty
+	}
+
+	pt, err := LoadPubTree(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(pt.nodes) > 0 {
+		return nil, ErrInvalidStorage
+	}
+
+	err = s.ForEachNode(func(pkh, record []byte) error {
+		key, node, err := parsePubNode(record)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(key[:], pkh) {
+			return ErrInvalidStorage
+		}
+		pt.nodes[key] = node
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range pt.nodes {
+		if node.parent == ([32]byte{}) {
+			if !pt.rootUsed {
+				return nil, ErrInvalidStorage
+			}
+			continue
+		}
+		if parent, ok := pt.nodes[node.parent]; !ok || !parent.used {
+			return nil, ErrInvalidStorage
+		}
+	}
+
+	pt.storage = s
+
+	return pt, nil
+}
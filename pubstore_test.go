@@ -0,0 +1,71 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYPubTree_SetStorage(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pub := NewPubTree(tree.PublicKey())
+	storage := NewMemStorage()
+	if err := pub.SetStorage(storage); err != nil {
+		t.Fatal("Failed to set storage -", err)
+	}
+	observe := func(pub *NYPubTree) {
+		sig, txid, err := signMessage("pub storage test", tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if err := pub.Observe(sig, txid); err != nil {
+			t.Fatal("Failed to observe -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+			pub.Confirm(pkh, ConfirmsRequired)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		observe(pub)
+	}
+
+	// 1 - The public tree is loaded from the storage, and keeps writing
+	// through
+	loaded, err := LoadPubTreeStorage(storage)
+	if err != nil || !bytes.Equal(loaded.Bytes(), pub.Bytes()) {
+		t.Fatal("Stored public tree differs -", err)
+	}
+	observe(loaded)
+	reloaded, err := LoadPubTreeStorage(storage)
+	if err != nil || !bytes.Equal(reloaded.Bytes(), loaded.Bytes()) {
+		t.Fatal("Stored public tree differs from the loaded one -", err)
+	}
+	if reloaded.Available() != tree.Available(nil) {
+		t.Fatal("Reloaded public tree counts", reloaded.Available(), "available nodes, tree has", tree.Available(nil))
+	}
+
+	// 2 - Lost records are detected
+	var used []byte
+	storage.ForEachNode(func(pkh, record []byte) error {
+		if record[pubNodeLen-1] == 1 {
+			used = pkh
+		}
+		return nil
+	})
+	if used == nil {
+		t.Fatal("No used node stored")
+	}
+	storage.DeleteNode(used)
+	if _, err := LoadPubTreeStorage(storage); err != ErrInvalidStorage {
+		t.Fatal("Loaded public tree with lost records, err was", err)
+	}
+
+	// 3 - Storages holding no tree are detected
+	if _, err := LoadPubTreeStorage(NewMemStorage()); err != ErrStorageEmpty {
+		t.Fatal("Loaded empty storage, err was", err)
+	}
+}
@@ -0,0 +1,313 @@
+package xnyss
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrPubTreeUnknownSigner = newError(ErrCrypto, "signature was not created by a node of the tree")
+	ErrPubTreeNodeReused    = newError(ErrCrypto, "signature was created by a node that signed before")
+	ErrInvalidPubTree       = newError(ErrEncoding, "invalid public tree encoding")
+)
+
+// Version byte of an encoded public tree.
+const pubTreeVersion = 0x01
+
+// Tags of the fields of an encoded public tree. Node fields hold
+// pkh || parent || txid || confirms || used, where parent is the public key
+// hash of the node that created it, or zero for children of the root.
+const (
+	pubTreeFieldRoot     = 0x01
+	pubTreeFieldRootUsed = 0x02
+	pubTreeFieldNode     = 0x03
+
+	pubNodeLen = 32 + 32 + TxidLen + 1 + 1
+)
+
+// A watch-only view of a tree, built from the signatures it creates. It holds
+// no seeds, only the public key hashes of the nodes, the txids they were
+// created for and their confirmation counts, so that verifiers and auditors
+// can follow the use of someone else's long-term key: which signatures link to
+// it, which nodes were used more than once, and how many nodes are left.
+type NYPubTree struct {
+	mu         sync.Mutex
+	rootPubKey []byte
+	rootUsed   bool
+	nodes      map[[32]byte]*pubNode
+
+	// The storage of the tree, and the changes not written to it yet, see
+	// SetStorage.
+	storage     Storage
+	dirty       map[[32]byte]bool
+	headerDirty bool
+}
+
+// A node of a public tree.
+type pubNode struct {
+	parent   [32]byte
+	txid     []byte
+	confirms uint8
+	used     bool
+}
+
+// Creates a public tree for the tree with long-term public key rootPubKey.
+func NewPubTree(rootPubKey []byte) *NYPubTree {
+	return &NYPubTree{
+		rootPubKey: append([]byte(nil), rootPubKey...),
+		nodes:      make(map[[32]byte]*pubNode),
+	}
+}
+
+// Returns the long-term public key of the tree pt.
+func (pt *NYPubTree) PublicKey() []byte {
+	return append([]byte(nil), pt.rootPubKey...)
+}
+
+// Adds the signature sig, included in the transaction txid, to the tree pt. The
+// signature must be created by the root key or by a node of pt; its child
+// hashes are added as new, unconfirmed nodes.
+//
+// Returns ErrPubTreeUnknownSigner if sig does not link to a node of pt, which
+// includes signatures whose parent was not observed yet, and
+// ErrPubTreeNodeReused if the node that created sig signed before. The tree is
+// not changed in either case. If pt has a storage, the error of writing the
+// changes is returned, see SetStorage.
+func (pt *NYPubTree) Observe(sig *Signature, txid []byte) error {
+	if sig == nil {
+		return ErrInvalidSigEncoding
+	}
+	if len(txid) != TxidLen {
+		return ErrInvalidTxidLen
+	}
+
+	pk, err := sig.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var signer [32]byte
+	if bytes.Equal(pk, pt.rootPubKey) {
+		if pt.rootUsed {
+			return ErrPubTreeNodeReused
+		}
+		pt.rootUsed = true
+		pt.headerDirty = true
+	} else {
+		copy(signer[:], sig.suite.sum(pk))
+		node, ok := pt.nodes[signer]
+		if !ok {
+			return ErrPubTreeUnknownSigner
+		}
+		if node.used {
+			return ErrPubTreeNodeReused
+		}
+		node.used = true
+		pt.markDirty(signer)
+	}
+
+	for _, child := range sig.ChildHashes {
+		var pkh [32]byte
+		copy(pkh[:], child)
+		if _, ok := pt.nodes[pkh]; !ok {
+			pt.nodes[pkh] = &pubNode{parent: signer, txid: append([]byte(nil), txid...)}
+			pt.markDirty(pkh)
+		}
+	}
+
+	return pt.writeThrough()
+}
+
+// Sets the confirmation count of the unconfirmed node with public key hash pkh
+// to the given number of confirmations, like NYTree.Confirm. Counts only
+// increase. If pt has a storage and the new count can not be written, it is
+// written with the next change, see SetStorage.
+func (pt *NYPubTree) Confirm(pkh []byte, confirms uint8) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], pkh)
+	if node, ok := pt.nodes[key]; ok && node.confirms < ConfirmsRequired && node.confirms < confirms {
+		node.confirms = confirms
+		pt.markDirty(key)
+		pt.writeThrough()
+	}
+}
+
+// Returns whether pkh is the public key hash of a node of the tree pt, used or
+// not.
+func (pt *NYPubTree) Contains(pkh []byte) bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], pkh)
+	_, ok := pt.nodes[key]
+
+	return ok
+}
+
+// Returns the amount of confirmed nodes of the tree pt that did not sign yet,
+// which is the amount of signatures the owner of the tree can create without
+// waiting for confirmations.
+func (pt *NYPubTree) Available() (n int) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if !pt.rootUsed {
+		n++
+	}
+	for _, node := range pt.nodes {
+		if !node.used && node.confirms >= ConfirmsRequired {
+			n++
+		}
+	}
+
+	return
+}
+
+// Returns the public key hashes of the unconfirmed nodes of the tree pt,
+// sorted.
+func (pt *NYPubTree) Unconfirmed() (pkhashes [][]byte) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	for _, pkh := range pt.sortedHashes() {
+		if pt.nodes[pkh].confirms < ConfirmsRequired {
+			pkhashes = append(pkhashes, append([]byte(nil), pkh[:]...))
+		}
+	}
+
+	return
+}
+
+// Returns the public key hashes of the nodes on the path from the root to the
+// node with public key hash pkh, excluding the root and including pkh, or nil
+// if pkh is not a node of the tree pt.
+func (pt *NYPubTree) Path(pkh []byte) (path [][]byte) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], pkh)
+	for key != ([32]byte{}) {
+		node, ok := pt.nodes[key]
+		if !ok {
+			return nil
+		}
+		path = append([][]byte{append([]byte(nil), key[:]...)}, path...)
+		key = node.parent
+	}
+
+	return path
+}
+
+// Returns the public key hashes of the nodes of pt, sorted.
+func (pt *NYPubTree) sortedHashes() [][32]byte {
+	hashes := make([][32]byte, 0, len(pt.nodes))
+	for pkh := range pt.nodes {
+		hashes = append(hashes, pkh)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return bytes.Compare(hashes[i][:], hashes[j][:]) < 0 })
+
+	return hashes
+}
+
+// Returns the encoding of the public tree pt. Nodes are written in the order
+// of their public key hashes, so equal trees have equal encodings.
+func (pt *NYPubTree) Bytes() []byte {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	buf := bytes.NewBuffer(pt.header())
+	for _, pkh := range pt.sortedHashes() {
+		writeField(buf, pubTreeFieldNode, pt.record(pkh))
+	}
+
+	return buf.Bytes()
+}
+
+// Returns the encoding of pt without its nodes.
+func (pt *NYPubTree) header() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(pubTreeVersion)
+	writeField(buf, pubTreeFieldRoot, pt.rootPubKey)
+	if pt.rootUsed {
+		writeField(buf, pubTreeFieldRootUsed, []byte{1})
+	}
+
+	return buf.Bytes()
+}
+
+// Returns the record of the node of pt with public key hash pkh.
+func (pt *NYPubTree) record(pkh [32]byte) []byte {
+	node := pt.nodes[pkh]
+	used := byte(0)
+	if node.used {
+		used = 1
+	}
+
+	record := make([]byte, 0, pubNodeLen)
+	record = append(record, pkh[:]...)
+	record = append(record, node.parent[:]...)
+	record = append(record, node.txid...)
+	record = append(record, node.confirms, used)
+
+	return record
+}
+
+// Decodes a node record of a public tree, see NYPubTree.record.
+func parsePubNode(value []byte) (pkh [32]byte, node *pubNode, err error) {
+	if len(value) != pubNodeLen || value[pubNodeLen-1] > 1 {
+		return pkh, nil, ErrInvalidPubTree
+	}
+	copy(pkh[:], value)
+	node = &pubNode{
+		txid:     append([]byte(nil), value[64:64+TxidLen]...),
+		confirms: value[64+TxidLen],
+		used:     value[pubNodeLen-1] == 1,
+	}
+	copy(node.parent[:], value[32:64])
+
+	return pkh, node, nil
+}
+
+// Decodes a public tree encoded by NYPubTree.Bytes.
+func LoadPubTree(b []byte) (*NYPubTree, error) {
+	if len(b) < 1 || b[0] != pubTreeVersion {
+		return nil, ErrInvalidPubTree
+	}
+
+	pt := &NYPubTree{nodes: make(map[[32]byte]*pubNode)}
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case pubTreeFieldRoot:
+			pt.rootPubKey = append([]byte(nil), value...)
+		case pubTreeFieldRootUsed:
+			pt.rootUsed = len(value) == 1 && value[0] == 1
+		case pubTreeFieldNode:
+			pkh, node, err := parsePubNode(value)
+			if err != nil {
+				return err
+			}
+			pt.nodes[pkh] = node
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pt.rootPubKey == nil {
+		return nil, ErrInvalidPubTree
+	}
+
+	return pt, nil
+}
@@ -0,0 +1,70 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYPubTree(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pub := NewPubTree(tree.PublicKey())
+
+	// 1 - Signatures of the root and its descendants are observed
+	var sigs []*Signature
+	for i := 0; i < 3; i++ {
+		sig, txid, err := signMessage("watch test", tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if err := pub.Observe(sig, txid); err != nil {
+			t.Fatal("Failed to observe signature", i, "-", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+			pub.Confirm(pkh, ConfirmsRequired)
+		}
+		sigs = append(sigs, sig)
+	}
+
+	// 2 - The public tree counts the same capacity as the tree
+	if pub.Available() != tree.Available(nil) {
+		t.Fatal("Public tree counts", pub.Available(), "available nodes, tree has", tree.Available(nil))
+	}
+	if len(pub.Unconfirmed()) != 0 {
+		t.Fatal("Public tree has unconfirmed nodes")
+	}
+	path := pub.Path(sigs[2].ChildHashes[0])
+	if len(path) < 2 || !containsHash(sigs[0].ChildHashes, path[0]) ||
+		!bytes.Equal(path[len(path)-1], sigs[2].ChildHashes[0]) {
+		t.Fatal("Invalid path", path)
+	}
+
+	// 3 - Reuse and unknown signers are detected
+	if err := pub.Observe(sigs[1], make([]byte, TxidLen)); err != ErrPubTreeNodeReused {
+		t.Fatal("Observed reused node, err was", err)
+	}
+	other := New(seed[1:], pubSeed, false)
+	sig, txid, err := signMessage("other", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Observe(sig, txid); err != ErrPubTreeUnknownSigner {
+		t.Fatal("Observed signature of another tree, err was", err)
+	}
+
+	// 4 - Public trees survive encoding
+	loaded, err := LoadPubTree(pub.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load public tree -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), pub.Bytes()) || loaded.Available() != pub.Available() {
+		t.Fatal("Loaded public tree differs")
+	}
+	if _, err := LoadPubTree([]byte{pubTreeVersion}); err != ErrInvalidPubTree {
+		t.Fatal("Loaded public tree without root key, err was", err)
+	}
+}
@@ -0,0 +1,144 @@
+package xnyss
+
+import "time"
+
+var (
+	ErrRateLimited = newError(ErrCapacity, "signing rate limit exceeded")
+)
+
+// Maximum amount of per-txid buckets kept before idle buckets are pruned.
+const maxTxidBuckets = 1024
+
+// A token bucket limit: Rate signatures per second on average, with bursts of
+// up to Burst signatures. A limit with a Rate of zero is disabled.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Counters describing the rate limiting of Sign since the limits were set.
+type RateLimitStats struct {
+	Allowed uint64
+	// Signatures refused by the limit of the tree
+	Throttled uint64
+	// Signatures refused by the limit of their txid
+	ThrottledTxid uint64
+}
+
+type rateLimiter struct {
+	tree   RateLimit
+	txid   RateLimit
+	bucket tokenBucket
+	txids  map[string]*tokenBucket
+	stats  RateLimitStats
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Adds the tokens accumulated since the last refill, up to the burst size.
+func (b *tokenBucket) refill(l RateLimit, now time.Time) {
+	if b.last.IsZero() {
+		b.tokens = float64(l.Burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * l.Rate
+	}
+	if b.tokens > float64(l.Burst) {
+		b.tokens = float64(l.Burst)
+	}
+	b.last = now
+}
+
+// Limits the rate at which Sign creates signatures with the tree t, both in
+// total and per txid, to protect its capacity against runaway clients. Sign
+// returns ErrRateLimited when either limit is exceeded. Pass zero limits to
+// disable rate limiting. Limits are not persisted, and setting them resets the
+// statistics.
+func (t *NYTree) SetRateLimit(tree, perTxid RateLimit) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if tree.Rate <= 0 && perTxid.Rate <= 0 {
+		t.limiter = nil
+		return
+	}
+
+	t.limiter = &rateLimiter{
+		tree:  tree,
+		txid:  perTxid,
+		txids: make(map[string]*tokenBucket),
+	}
+}
+
+// Returns the rate limiting statistics of the tree t.
+func (t *NYTree) RateLimitStats() RateLimitStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.limiter == nil {
+		return RateLimitStats{}
+	}
+
+	return t.limiter.stats
+}
+
+// Takes a token for a signature for txid, or returns ErrRateLimited. No tokens
+// are taken if either limit is exceeded.
+func (t *NYTree) checkRateLimit(txid []byte) error {
+	l := t.limiter
+	if l == nil {
+		return nil
+	}
+	now := t.now()
+
+	if l.tree.Rate > 0 {
+		l.bucket.refill(l.tree, now)
+		if l.bucket.tokens < 1 {
+			l.stats.Throttled++
+			return ErrRateLimited
+		}
+	}
+
+	var txidBucket *tokenBucket
+	if l.txid.Rate > 0 {
+		txidBucket = l.txidBucket(string(txid), now)
+		txidBucket.refill(l.txid, now)
+		if txidBucket.tokens < 1 {
+			l.stats.ThrottledTxid++
+			return ErrRateLimited
+		}
+	}
+
+	if l.tree.Rate > 0 {
+		l.bucket.tokens--
+	}
+	if txidBucket != nil {
+		txidBucket.tokens--
+	}
+	l.stats.Allowed++
+
+	return nil
+}
+
+// Returns the bucket of txid, pruning buckets that have refilled completely
+// when there are too many. Those behave the same as new buckets.
+func (l *rateLimiter) txidBucket(txid string, now time.Time) *tokenBucket {
+	if b, ok := l.txids[txid]; ok {
+		return b
+	}
+
+	if len(l.txids) >= maxTxidBuckets {
+		for id, b := range l.txids {
+			b.refill(l.txid, now)
+			if b.tokens >= float64(l.txid.Burst) {
+				delete(l.txids, id)
+			}
+		}
+	}
+
+	b := &tokenBucket{}
+	l.txids[txid] = b
+	return b
+}
@@ -0,0 +1,50 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_SetRateLimit(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	txidA, txidB := Txid("test", []byte("a")), Txid("test", []byte("b"))
+
+	// Effectively no refill during the test
+	tree.SetRateLimit(RateLimit{Rate: 0.001, Burst: 3}, RateLimit{Rate: 0.001, Burst: 2})
+
+	// 1 - The txid limit applies first: the root and one child sign for txid A
+	for i := 0; i < 2; i++ {
+		if _, err := tree.Sign(msg, txidA); err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+	}
+	if _, err := tree.Sign(msg, txidA); err != ErrRateLimited {
+		t.Fatal("Txid limit was not applied, err was", err)
+	}
+
+	// 2 - Then the limit of the tree
+	for _, pkh := range tree.Unconfirmed() {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	if _, err := tree.Sign(msg, txidB); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if _, err := tree.Sign(msg, txidB); err != ErrRateLimited {
+		t.Fatal("Tree limit was not applied, err was", err)
+	}
+
+	stats := tree.RateLimitStats()
+	if stats.Allowed != 3 || stats.Throttled != 1 || stats.ThrottledTxid != 1 {
+		t.Fatal("Invalid rate limit statistics", stats)
+	}
+
+	// 3 - Limits can be disabled
+	tree.SetRateLimit(RateLimit{}, RateLimit{})
+	if _, err := tree.Sign(msg, txidB); err != nil {
+		t.Fatal("Failed to sign without limits -", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Implements hash-based multi-party release signing: a release digest is
+// accepted once K of N distinct signers, each using its own XNYSS subtree
+// (possibly on a different machine), have signed it.
+//
+// Every signer signs the digest H("xnyss release" || digest) with its tree. Its
+// signature is accompanied by the chain from the signer's long-term key to the
+// signing node, so the verifier needs no state other than the signers' public
+// keys. Signers keep their chain with a Signer, which confirms its own child
+// nodes immediately, since releases are not confirmed by a blockchain.
+package release
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrThreshold      = errors.New("release is not signed by enough distinct signers")
+	ErrInvalidPolicy  = errors.New("threshold must be between 1 and the number of signers")
+	ErrUnknownSigner  = errors.New("release signed by an unknown signer")
+	ErrDuplicateShare = errors.New("signer contributed more than one share")
+)
+
+// Domain separation prefix of signed release digests.
+const releaseDomain = "xnyss release"
+
+// Returns the message signed for a release digest.
+func message(digest []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(releaseDomain))
+	h.Write(digest)
+	return h.Sum(nil)
+}
+
+// One signer's contribution: its long-term key, and the chain from that key to
+// the signature of the release.
+type Share struct {
+	PublicKey []byte
+	Chain     []*xnyss.Signature
+}
+
+// Signs releases with one party's tree.
+type Signer struct {
+	tree *xnyss.NYTree
+	// Chains of the signatures that created the nodes of the tree, by public
+	// key hash
+	chains map[string][]*xnyss.Signature
+}
+
+// Creates a signer using a fresh tree, whose root creates the first share.
+func NewSigner(tree *xnyss.NYTree) *Signer {
+	return &Signer{tree: tree, chains: make(map[string][]*xnyss.Signature)}
+}
+
+// Signs the release digest, and returns the share of this signer.
+func (s *Signer) Sign(digest []byte) (*Share, error) {
+	msg := message(digest)
+	sig, err := s.tree.Sign(msg, xnyss.Txid(releaseDomain, digest))
+	if err != nil {
+		return nil, err
+	}
+
+	pk, err := sig.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	pkh := sha256.Sum256(pk)
+	chain := append(append([]*xnyss.Signature(nil), s.chains[string(pkh[:])]...), sig)
+	delete(s.chains, string(pkh[:]))
+
+	for _, child := range sig.ChildHashes {
+		s.tree.Confirm(child, xnyss.ConfirmsRequired)
+		s.chains[string(child)] = chain
+	}
+
+	return &Share{PublicKey: s.tree.PublicKey(), Chain: chain}, nil
+}
+
+// A K of N release signing policy.
+type Policy struct {
+	Signers   [][]byte
+	Threshold int
+}
+
+// Verifies that at least Threshold distinct signers of the policy signed the
+// release digest. Shares of unknown signers are rejected, and a signer can
+// only contribute once.
+func (p *Policy) Verify(digest []byte, shares []*Share) error {
+	if p.Threshold < 1 || p.Threshold > len(p.Signers) {
+		return ErrInvalidPolicy
+	}
+
+	msg := message(digest)
+	signed := make(map[int]bool)
+	for _, share := range shares {
+		index := -1
+		for i, pk := range p.Signers {
+			if bytes.Equal(pk, share.PublicKey) {
+				index = i
+			}
+		}
+		if index < 0 {
+			return ErrUnknownSigner
+		}
+		if signed[index] {
+			return ErrDuplicateShare
+		}
+
+		if err := xnyss.VerifyChain(share.PublicKey, share.Chain, msg); err != nil {
+			return err
+		}
+		signed[index] = true
+	}
+
+	if len(signed) < p.Threshold {
+		return ErrThreshold
+	}
+
+	return nil
+}
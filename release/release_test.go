@@ -0,0 +1,73 @@
+package release
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+func newSigner(t *testing.T) *Signer {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSigner(xnyss.New(seeds[:32], seeds[32:], false))
+}
+
+func TestPolicy(t *testing.T) {
+	signers := []*Signer{newSigner(t), newSigner(t), newSigner(t)}
+	policy := &Policy{Threshold: 2}
+	for _, s := range signers {
+		policy.Signers = append(policy.Signers, s.tree.PublicKey())
+	}
+
+	// 1 - Two releases reach the threshold, the second using child nodes
+	for _, release := range []string{"v1.0.0", "v1.1.0"} {
+		digest := sha256.Sum256([]byte(release))
+
+		first, err := signers[0].Sign(digest[:])
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if err := policy.Verify(digest[:], []*Share{first}); err != ErrThreshold {
+			t.Fatal("Accepted release below threshold, err was", err)
+		}
+		if err := policy.Verify(digest[:], []*Share{first, first}); err != ErrDuplicateShare {
+			t.Fatal("Accepted duplicate share, err was", err)
+		}
+
+		second, err := signers[2].Sign(digest[:])
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if err := policy.Verify(digest[:], []*Share{first, second}); err != nil {
+			t.Fatal("Failed to verify release -", err)
+		}
+	}
+
+	// 2 - Shares of other releases or unknown signers are rejected
+	digest := sha256.Sum256([]byte("v2.0.0"))
+	other := sha256.Sum256([]byte("evil"))
+	share, err := signers[1].Sign(other[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, err := signers[0].Sign(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Verify(digest[:], []*Share{good, share}); err != xnyss.ErrChainMessage {
+		t.Fatal("Accepted share of other release, err was", err)
+	}
+
+	stranger, err := newSigner(t).Sign(digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Verify(digest[:], []*Share{good, stranger}); err != ErrUnknownSigner {
+		t.Fatal("Accepted share of unknown signer, err was", err)
+	}
+}
@@ -0,0 +1,176 @@
+package xnyss
+
+import (
+	"bytes"
+	"sort"
+)
+
+// Sets the confirmation count of the nodes with the given txid to zero, e.g.
+// because the transaction was removed from the blockchain by a reorganisation
+// and is waiting to be included again. Unlike Confirm, this also applies to
+// nodes that were confirmed before. Returns the number of changed nodes.
+func (t *NYTree) Unconfirm(txid []byte) (changed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	capacity := t.observedCapacity()
+	for _, node := range t.nodesByTxid(t.lookupTxid(txid)) {
+		if node.confirms != 0 {
+			t.setConfirms(node, 0)
+			changed++
+		}
+	}
+	if changed > 0 {
+		t.epoch++
+		t.log(LevelAudit, "txid unconfirmed", "nodes", changed)
+	}
+	t.notifyCapacity(capacity)
+
+	return
+}
+
+// Sets the confirmation count of the node with public key hash pkh to confirms,
+// which may be lower than its current count, e.g. when the block depth of its
+// transaction decreased after a reorganisation. Returns false if the tree does
+// not contain the node.
+func (t *NYTree) SetConfirms(pkh []byte, confirms uint8) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	node := t.nodeByPkh(pkh)
+	if node == nil {
+		return false
+	}
+
+	confirms = boundConfirms(confirms)
+	if node.confirms != confirms {
+		capacity := t.observedCapacity()
+		confirmed := node.confirms >= ConfirmsRequired
+		t.setConfirms(node, confirms)
+		t.epoch++
+		t.log(LevelAudit, "confirms set", pkhAttr(pkh), "confirms", confirms)
+		if !confirmed && confirms >= ConfirmsRequired {
+			t.notifyConfirmed(pkh)
+		}
+		t.notifyCapacity(capacity)
+	}
+
+	return true
+}
+
+// Removes the nodes created by the transaction txid from the tree, together
+// with all nodes descending from them, because the transaction will never be
+// part of the blockchain (e.g. it was double spent during a reorganisation).
+// Signatures created by such nodes could never be valid on chain. Returns the
+// number of removed nodes.
+//
+// Descendants are found using the txids of the transactions signed by nodes of
+// the tree, which are included in the serialised tree. Removed nodes are
+// wiped, and are not marked as consumed.
+func (t *NYTree) InvalidateTxid(txid []byte) (removed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], t.lookupTxid(txid))
+	invalid := t.txidDescendants(key)
+
+	capacity := t.observedCapacity()
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		var nodeTxid [32]byte
+		copy(nodeTxid[:], node.txid)
+		if invalid[nodeTxid] && !t.isRoot(node) {
+			t.consumeReservation(node)
+			node.wipe()
+			removed++
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	t.nodes = nodes
+	t.dropIndex()
+
+	if removed > 0 {
+		t.epoch++
+		t.log(LevelAudit, "txid invalidated", "nodes", removed)
+	}
+	t.notifyCapacity(capacity)
+
+	return
+}
+
+// Returns txid and the txids of all transactions that (indirectly) spend one
+// of its nodes.
+func (t *NYTree) txidDescendants(txid [32]byte) map[[32]byte]bool {
+	children := make(map[[32]byte][][32]byte)
+	for child, parents := range t.txidParents {
+		for _, parent := range parents {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	descendants := map[[32]byte]bool{txid: true}
+	queue := [][32]byte{txid}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			if !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	return descendants
+}
+
+// Records that a node created by transaction parent signed transaction child.
+func (t *NYTree) addTxidParent(child, parent []byte) {
+	if bytes.Equal(child, parent) {
+		return
+	}
+
+	var c, p [32]byte
+	copy(c[:], child)
+	copy(p[:], parent)
+	for _, known := range t.txidParents[c] {
+		if known == p {
+			return
+		}
+	}
+
+	if t.txidParents == nil {
+		t.txidParents = make(map[[32]byte][][32]byte)
+	}
+	t.txidParents[c] = append(t.txidParents[c], p)
+}
+
+// Encodes the txid parents as a sorted list of child || parent pairs.
+func (t *NYTree) encodeTxidParents() []byte {
+	pairs := make([][]byte, 0, len(t.txidParents))
+	for child, parents := range t.txidParents {
+		for _, parent := range parents {
+			pairs = append(pairs, append(append([]byte(nil), child[:]...), parent[:]...))
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i], pairs[j]) < 0
+	})
+
+	return bytes.Join(pairs, nil)
+}
+
+func (t *NYTree) loadTxidParents(b []byte) error {
+	if len(b)%64 != 0 {
+		return ErrFieldInvalid
+	}
+
+	t.txidParents = nil
+	for i := 0; i < len(b); i += 64 {
+		t.addTxidParent(b[i:i+32], b[i+32:i+64])
+	}
+
+	return nil
+}
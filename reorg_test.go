@@ -0,0 +1,91 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_Unconfirm(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	txid := bytes.Repeat([]byte{1}, 32)
+
+	sig, err := tree.Sign(msg, txid)
+	if err != nil {
+		t.Fatal("Failed to sign with root -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, 3)
+	}
+
+	// 1 - Confirm does not lower confirmations, SetConfirms does
+	tree.Confirm(sig.ChildHashes[0], 0)
+	if tree.Available(nil) != Branches {
+		t.Fatal("Confirm lowered the confirmations of a node")
+	}
+	if !tree.SetConfirms(sig.ChildHashes[0], 0) || tree.Available(nil) != Branches-1 {
+		t.Fatal("Failed to lower confirmations")
+	}
+	if tree.SetConfirms(make([]byte, 32), 1) {
+		t.Fatal("Set confirmations of an unknown node")
+	}
+
+	// 2 - Unconfirm resets all nodes of the txid
+	if changed := tree.Unconfirm(txid); changed != Branches-1 {
+		t.Fatal("Invalid number of unconfirmed nodes", changed)
+	}
+	if tree.Available(nil) != 0 {
+		t.Fatal("Unconfirmed nodes are still available")
+	}
+}
+
+func TestNYTree_InvalidateTxid(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	txid := func(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+	// Transaction 1 is signed by the root, 2 spends a node of 1, and 3 spends
+	// a node of 2
+	sig1, err := tree.Sign(msg, txid(1))
+	if err != nil {
+		t.Fatal("Failed to sign with root -", err)
+	}
+	for _, pkh := range sig1.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	sig2, err := tree.Sign(msg, txid(2))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig2.ChildHashes[0], ConfirmsRequired)
+	for _, pkh := range sig1.ChildHashes {
+		tree.SetConfirms(pkh, 0)
+	}
+	if _, err := tree.Sign(msg, txid(3)); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	tree, err = Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if removed := tree.InvalidateTxid(txid(2)); removed != 2*Branches-1 {
+		t.Fatal("Invalid number of removed nodes", removed)
+	}
+	for _, node := range tree.nodes {
+		if bytes.Equal(node.txid, txid(2)) || bytes.Equal(node.txid, txid(3)) {
+			t.Fatal("Descendant of invalidated transaction was not removed")
+		}
+	}
+	if len(tree.nodes) != Branches-1 {
+		t.Fatal("Removed nodes that do not descend from the transaction")
+	}
+}
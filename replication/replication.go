@@ -0,0 +1,223 @@
+// Implements hot-standby replication of XNYSS tree state. A Primary wraps the
+// tree of the active signer and sends an Update to the standby after every state
+// change, before the result of the operation is returned. A Standby applies
+// these updates, and can only activate once it acquires the tree's fence, which
+// guarantees the primary can no longer sign.
+//
+// Updates carry the serialised state of the tree, tagged with its epoch, so a
+// standby never applies an update older than the state it already has.
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrStaleUpdate     = errors.New("update is older than the replicated state")
+	ErrHandedOff       = errors.New("primary has handed off and can no longer sign")
+	ErrNoState         = errors.New("standby has not received any state")
+	ErrInvalidEncoding = errors.New("invalid update encoding")
+)
+
+// Maximum size of the state that ReadUpdate accepts, 64 MiB.
+const maxStateLen = 64 << 20
+
+// A state update sent from the primary to the standby. Final is set on the last
+// update sent before the primary releases its fence during a handoff.
+type Update struct {
+	Epoch uint64
+	Final bool
+	State []byte
+}
+
+// Delivers updates to the standby. Send must only return nil once the update
+// has been received by the standby, since the primary releases signatures as
+// soon as Send returns.
+type Transport interface {
+	Send(u *Update) error
+}
+
+// Adapts a function to the Transport interface.
+type TransportFunc func(u *Update) error
+
+func (f TransportFunc) Send(u *Update) error {
+	return f(u)
+}
+
+// The active signer. It must be the only user of its tree.
+type Primary struct {
+	tree      *xnyss.NYTree
+	out       Transport
+	handedOff bool
+}
+
+// Creates a primary replicating the given tree, which should have a fence set
+// (see NYTree.SetFence). The current state is sent to the standby immediately.
+func NewPrimary(tree *xnyss.NYTree, out Transport) (*Primary, error) {
+	p := &Primary{tree: tree, out: out}
+	if err := p.replicate(false); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *Primary) replicate(final bool) error {
+	return p.out.Send(&Update{
+		Epoch: p.tree.Epoch(),
+		Final: final,
+		State: p.tree.Bytes(),
+	})
+}
+
+// Signs the message using the replicated tree. The signature is only returned
+// once the new state has been sent to the standby: if that fails, the
+// signature is withheld, since the standby could otherwise reuse the node.
+func (p *Primary) Sign(msg, txid []byte) (*xnyss.Signature, error) {
+	if p.handedOff {
+		return nil, ErrHandedOff
+	}
+
+	sig, err := p.tree.Sign(msg, txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.replicate(false); err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// Confirms nodes in the replicated tree, see NYTree.Confirm.
+func (p *Primary) Confirm(pkh []byte, confirms uint8) error {
+	if p.handedOff {
+		return ErrHandedOff
+	}
+
+	epoch := p.tree.Epoch()
+	p.tree.Confirm(pkh, confirms)
+	if p.tree.Epoch() == epoch {
+		return nil
+	}
+
+	return p.replicate(false)
+}
+
+// Performs a controlled failover: the primary stops signing, sends its final
+// state and then releases its fence, allowing the standby to activate.
+func (p *Primary) Handoff() error {
+	p.handedOff = true
+
+	if err := p.replicate(true); err != nil {
+		return err
+	}
+
+	return p.tree.ReleaseFence()
+}
+
+// Receives state updates from a primary.
+type Standby struct {
+	epoch uint64
+	final bool
+	state []byte
+}
+
+// Creates a standby without state.
+func NewStandby() *Standby {
+	return &Standby{}
+}
+
+// Applies an update. Returns ErrStaleUpdate if it is older than the current
+// state of the standby.
+func (s *Standby) Apply(u *Update) error {
+	if s.state != nil && u.Epoch < s.epoch {
+		return ErrStaleUpdate
+	}
+
+	if _, err := xnyss.Load(u.State); err != nil {
+		return err
+	}
+
+	s.epoch = u.Epoch
+	s.final = u.Final
+	s.state = append(s.state[:0], u.State...)
+
+	return nil
+}
+
+// Returns the epoch of the replicated state, and whether it is the final state
+// of a completed handoff.
+func (s *Standby) Epoch() (epoch uint64, final bool) {
+	return s.epoch, s.final
+}
+
+// Activates the standby by loading the replicated state and acquiring the given
+// fence. Activation fails while the primary holds the lease: it succeeds either
+// after a handoff, or after the primary's lease expired (e.g. when it crashed).
+func (s *Standby) Activate(f xnyss.Fence) (*xnyss.NYTree, error) {
+	if s.state == nil {
+		return nil, ErrNoState
+	}
+
+	tree, err := xnyss.Load(s.state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tree.SetFence(f); err != nil {
+		tree.Wipe()
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// Writes an update to w as epoch || final || uint32(len(state)) || state.
+func WriteUpdate(w io.Writer, u *Update) error {
+	hdr := make([]byte, 13)
+	binary.BigEndian.PutUint64(hdr, u.Epoch)
+	if u.Final {
+		hdr[8] = 0x01
+	}
+	binary.BigEndian.PutUint32(hdr[9:], uint32(len(u.State)))
+
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.Write(u.State)
+	return err
+}
+
+// Reads an update written by WriteUpdate.
+func ReadUpdate(r *bufio.Reader) (*Update, error) {
+	hdr := make([]byte, 13)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+
+	if hdr[8] > 0x01 {
+		return nil, ErrInvalidEncoding
+	}
+	length := binary.BigEndian.Uint32(hdr[9:])
+	if length > maxStateLen {
+		return nil, ErrInvalidEncoding
+	}
+
+	u := &Update{
+		Epoch: binary.BigEndian.Uint64(hdr),
+		Final: hdr[8] == 0x01,
+		State: make([]byte, length),
+	}
+	if _, err := io.ReadFull(r, u.State); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
@@ -0,0 +1,88 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/Re0h/xnyss"
+)
+
+func newTree(t *testing.T) *xnyss.NYTree {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+
+	return xnyss.New(seeds[:32], seeds[32:], false)
+}
+
+func TestFailover(t *testing.T) {
+	leases := xnyss.NewLeaseTable(time.Hour)
+	standby := NewStandby()
+
+	tree := newTree(t)
+	if err := tree.SetFence(leases.Owner("primary")); err != nil {
+		t.Fatal("Failed to acquire lease -", err)
+	}
+
+	// Streams updates through the wire encoding
+	buf := &bytes.Buffer{}
+	primary, err := NewPrimary(tree, TransportFunc(func(u *Update) error {
+		if err := WriteUpdate(buf, u); err != nil {
+			return err
+		}
+		received, err := ReadUpdate(bufio.NewReader(buf))
+		if err != nil {
+			return err
+		}
+		return standby.Apply(received)
+	}))
+	if err != nil {
+		t.Fatal("Failed to create primary -", err)
+	}
+
+	// 1 - Signatures and confirmations are replicated
+	msg, txid := make([]byte, 32), make([]byte, 32)
+	sig, err := primary.Sign(msg, txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := primary.Confirm(sig.ChildHashes[0], xnyss.ConfirmsRequired); err != nil {
+		t.Fatal("Failed to confirm -", err)
+	}
+	if epoch, final := standby.Epoch(); epoch != tree.Epoch() || final {
+		t.Fatal("Standby is at epoch", epoch, "should be", tree.Epoch())
+	}
+
+	// 2 - The standby cannot activate while the primary holds the lease
+	if _, err := standby.Activate(leases.Owner("standby")); err != xnyss.ErrFenceHeld {
+		t.Fatal("Standby activated before the primary was fenced, err was", err)
+	}
+
+	// 3 - After a handoff the primary no longer signs and the standby takes over
+	if err := primary.Handoff(); err != nil {
+		t.Fatal("Handoff failed -", err)
+	}
+	if _, err := primary.Sign(msg, txid); err != ErrHandedOff {
+		t.Fatal("Primary signed after handoff, err was", err)
+	}
+
+	active, err := standby.Activate(leases.Owner("standby"))
+	if err != nil {
+		t.Fatal("Standby failed to activate -", err)
+	}
+	if !bytes.Equal(active.Bytes(), tree.Bytes()) {
+		t.Fatal("Activated state differs from the primary's state")
+	}
+	if _, err := active.Sign(msg, txid); err != nil {
+		t.Fatal("Activated standby failed to sign -", err)
+	}
+
+	// 4 - Stale updates are rejected
+	if err := standby.Apply(&Update{Epoch: 0, State: tree.Bytes()}); err != ErrStaleUpdate {
+		t.Fatal("Stale update was applied, err was", err)
+	}
+}
@@ -0,0 +1,271 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// Denotes how long a reservation made with Reserve remains valid. Reservations
+// that are neither used to sign nor released in time are returned to the pool
+// of available nodes.
+var ReservationTTL = 10 * time.Minute
+
+var (
+	ErrReservationUnknown = newError(ErrState, "unknown or expired reservation")
+)
+
+// Identifies a reservation made with Reserve.
+type ReservationID uint64
+
+type reservation struct {
+	node    *nyNode
+	txid    []byte
+	expires time.Time
+	// Whether the reservation holds the node of a signature in progress,
+	// which does not expire and is only used by CommitSign, see BeginSign
+	pending bool
+	// The public key hash of the node of a loaded reservation, until the
+	// nodes of the tree are loaded, see resolveReservations
+	pkh []byte
+}
+
+// Counters describing reservation churn of a tree since it was created or
+// loaded, plus the number of reservations that are currently active.
+type ReservationStats struct {
+	Reserved uint64
+	Released uint64
+	Expired  uint64
+	Consumed uint64
+	Active   int
+}
+
+// Reserves a node for signing a transaction with the given txid, e.g. while the
+// transaction is being prepared. The reserved node is not used for other txids,
+// and is used by the next call to Sign with txid. Every call reserves another
+// node. Reservations expire after
+// ReservationTTL, so capacity does not leak when a caller never releases them.
+// Reservations are part of the serialised state, so they survive a restart of
+// the wallet, and still expire at the time they were made to.
+// Returns ErrInvalidTxidLen if the tree does not accept txid, see
+// SetTxidHashing.
+func (t *NYTree) Reserve(txid []byte) (ReservationID, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	txid, _, err := t.normalizeTxid(txid)
+	if err != nil {
+		return 0, err
+	}
+
+	// Nodes that are already reserved, even for txid, are left to their
+	// reservation, so releasing it can not free a node another one holds
+	index := t.getSignNode(txid, func(n *nyNode) bool { return n.reservation == 0 })
+	if index < 0 {
+		return 0, t.unavailableErr(txid)
+	}
+
+	return t.reserve(t.nodes[index], &reservation{
+		txid:    append([]byte(nil), txid...),
+		expires: t.now().Add(ReservationTTL),
+	}), nil
+}
+
+// Reserves node using r, which is completed with the node.
+func (t *NYTree) reserve(node *nyNode, r *reservation) ReservationID {
+	if t.reservations == nil {
+		t.reservations = make(map[ReservationID]*reservation)
+	}
+
+	t.lastReservation++
+	id := t.lastReservation
+	node.reservation = id
+	r.node = node
+	t.reservations[id] = r
+	t.resStats.Reserved++
+
+	return id
+}
+
+// Releases a reservation, returning the reserved node to the pool of available
+// nodes. Returns ErrReservationUnknown if the reservation does not exist, e.g.
+// because it already expired or was used to sign.
+func (t *NYTree) Release(id ReservationID) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r, ok := t.reservations[id]
+	if !ok || r.pending {
+		return ErrReservationUnknown
+	}
+
+	r.node.reservation = 0
+	delete(t.reservations, id)
+	t.resStats.Released++
+	t.notifyAvailable()
+
+	return nil
+}
+
+// Returns expired reservations to the pool of available nodes, and returns the
+// number of reservations that expired. Reservations are also reaped whenever a
+// node is selected for signing.
+func (t *NYTree) ReapReservations() (expired int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.reapReservations()
+}
+
+func (t *NYTree) reapReservations() (expired int) {
+	now := t.now()
+	for id, r := range t.reservations {
+		if r.pending || now.Before(r.expires) {
+			continue
+		}
+
+		r.node.reservation = 0
+		delete(t.reservations, id)
+		expired++
+	}
+	t.resStats.Expired += uint64(expired)
+
+	return
+}
+
+// Returns reservation statistics of the tree t.
+func (t *NYTree) ReservationStats() ReservationStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.resStats
+	stats.Active = len(t.reservations)
+
+	return stats
+}
+
+// Returns whether node may be used to sign for txid with regard to
+// reservations: nodes reserved for another txid, or for a signature in
+// progress, may not be used.
+func (t *NYTree) reservedFor(node *nyNode, txid []byte) bool {
+	if node.reservation == 0 {
+		return true
+	}
+
+	r, ok := t.reservations[node.reservation]
+	return ok && !r.pending && bytes.Equal(r.txid, txid)
+}
+
+// Removes the reservation of a node that was consumed by Sign, or that left
+// the tree because it was consumed otherwise.
+func (t *NYTree) consumeReservation(node *nyNode) {
+	if node.reservation == 0 {
+		return
+	}
+
+	delete(t.reservations, node.reservation)
+	node.reservation = 0
+	t.resStats.Consumed++
+}
+
+// Removes the reservation of a node that left the tree without being
+// consumed, e.g. moved to a backup. Every node that leaves the nodes of a tree
+// must have its reservation removed, by this function or consumeReservation,
+// so reservations never refer to nodes the tree does not hold.
+func (t *NYTree) dropReservation(node *nyNode) {
+	if node.reservation == 0 {
+		return
+	}
+
+	delete(t.reservations, node.reservation)
+	node.reservation = 0
+	t.resStats.Released++
+}
+
+// Encodes the reservations of t, other than those of signatures in progress,
+// which do not outlive the process. Every reservation is encoded as
+// pkh || uint64(id) || int64(expires in Unix nanoseconds) || uint32(len(txid))
+// || txid, where pkh is the public key hash of the reserved node.
+func (t *NYTree) encodeReservations() []byte {
+	ids := make([]ReservationID, 0, len(t.reservations))
+	for id, r := range t.reservations {
+		if !r.pending {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var buf []byte
+	for _, id := range ids {
+		r := t.reservations[id]
+		buf = append(buf, r.node.pubKeyHash()...)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(id))
+		buf = binary.BigEndian.AppendUint64(buf, uint64(r.expires.UnixNano()))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(r.txid)))
+		buf = append(buf, r.txid...)
+	}
+
+	return buf
+}
+
+// Loads the reservations encoded by encodeReservations. Their nodes are
+// resolved once the nodes of t are loaded, see resolveReservations.
+func (t *NYTree) loadReservations(b []byte) error {
+	t.reservations = make(map[ReservationID]*reservation)
+	// A node is held by at most one reservation
+	reserved := make(map[string]bool)
+	for len(b) > 0 {
+		if len(b) < 32+8+8+4 {
+			return ErrFieldInvalid
+		}
+		id := ReservationID(binary.BigEndian.Uint64(b[32:]))
+		txidLen := binary.BigEndian.Uint32(b[48:])
+		if id == 0 || t.reservations[id] != nil || reserved[string(b[:32])] ||
+			uint64(txidLen) > uint64(len(b)-52) {
+			return ErrFieldInvalid
+		}
+		reserved[string(b[:32])] = true
+
+		t.reservations[id] = &reservation{
+			pkh:     append([]byte(nil), b[:32]...),
+			txid:    append([]byte(nil), b[52:52+txidLen]...),
+			expires: time.Unix(0, int64(binary.BigEndian.Uint64(b[40:]))),
+		}
+		if id > t.lastReservation {
+			t.lastReservation = id
+		}
+		b = b[52+txidLen:]
+	}
+
+	return nil
+}
+
+// Assigns the loaded reservations to their nodes. Reservations of nodes the
+// tree no longer holds are dropped.
+func (t *NYTree) resolveReservations() {
+	unresolved := make(map[string]ReservationID)
+	for id, r := range t.reservations {
+		if r.node == nil {
+			unresolved[string(r.pkh)] = id
+		}
+	}
+	if len(unresolved) == 0 {
+		return
+	}
+
+	for _, node := range t.nodes {
+		if id, ok := unresolved[string(node.pubKeyHash())]; ok {
+			r := t.reservations[id]
+			r.node, r.pkh = node, nil
+			node.reservation = id
+			delete(unresolved, string(node.pkh))
+		}
+	}
+	for _, id := range unresolved {
+		delete(t.reservations, id)
+	}
+}
@@ -0,0 +1,218 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestNYTree_Reserve(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	txidA, txidB := bytes.Repeat([]byte{0xaa}, 32), bytes.Repeat([]byte{0xbb}, 32)
+	msg := make([]byte, 32)
+
+	// 1 - A reserved node can only be used for its txid
+	id, err := tree.Reserve(txidA)
+	if err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	if tree.Available(nil) != 0 || tree.Available(txidA) != 1 {
+		t.Fatal("Reserved node was not accounted for correctly")
+	}
+	if _, err := tree.Sign(msg, txidB); err != ErrTreeNoneAvailable {
+		t.Fatal("Signed with a node reserved for another txid, err was", err)
+	}
+
+	// 2 - Releasing makes the node available again
+	if err := tree.Release(id); err != nil {
+		t.Fatal("Failed to release reservation -", err)
+	}
+	if err := tree.Release(id); err != ErrReservationUnknown {
+		t.Fatal("Released a reservation twice, err was", err)
+	}
+	if tree.Available(nil) != 1 {
+		t.Fatal("Released node is not available")
+	}
+
+	// 3 - Reservations expire
+	ttl := ReservationTTL
+	ReservationTTL = time.Millisecond
+	defer func() { ReservationTTL = ttl }()
+
+	if _, err := tree.Reserve(txidA); err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if tree.Available(nil) != 1 {
+		t.Fatal("Expired reservation was not reaped")
+	}
+
+	// 4 - Signing consumes the reservation
+	ReservationTTL = ttl
+	if _, err := tree.Reserve(txidA); err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	if _, err := tree.Sign(msg, txidA); err != nil {
+		t.Fatal("Failed to sign with reserved node -", err)
+	}
+
+	stats := tree.ReservationStats()
+	if stats.Reserved != 3 || stats.Released != 1 || stats.Expired != 1 ||
+		stats.Consumed != 1 || stats.Active != 0 {
+		t.Fatalf("Invalid reservation stats %+v", stats)
+	}
+}
+
+func TestNYTree_ReservePersisted(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	tree.SetClock(clock)
+	txidA, txidB := bytes.Repeat([]byte{0xaa}, 32), bytes.Repeat([]byte{0xbb}, 32)
+
+	sig, err := tree.Sign(make([]byte, 32), txidA)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	id, err := tree.Reserve(txidB)
+	if err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	pending, err := tree.BeginSign(txidA)
+	if err != nil {
+		t.Fatal("Failed to begin signing -", err)
+	}
+	defer tree.AbortSign(pending)
+
+	// 1 - Reservations survive serialisation, signatures in progress do not
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	loaded.SetClock(clock)
+	if loaded.ReservationStats().Active != 1 || loaded.Available(nil) != Branches-1 ||
+		loaded.Available(txidB) != Branches {
+		t.Fatal("Reservation was not restored")
+	}
+	if !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Loaded reservations encoded differently")
+	}
+
+	// 2 - Restored reservations can be released, and new ones do not reuse
+	// their ID
+	next, err := loaded.Reserve(txidA)
+	if err != nil || next <= id {
+		t.Fatal("Reservation reused an ID -", err)
+	}
+	if err := loaded.Release(id); err != nil {
+		t.Fatal("Failed to release restored reservation -", err)
+	}
+
+	// 3 - Restored reservations expire when they were made to
+	loaded, err = Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	loaded.SetClock(clock)
+	clock.Advance(ReservationTTL)
+	if loaded.ReapReservations() != 1 || loaded.Available(nil) != Branches {
+		t.Fatal("Restored reservation did not expire")
+	}
+}
+
+func TestNYTree_ReserveTwice(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	txid := bytes.Repeat([]byte{0xaa}, 32)
+
+	sig, err := tree.Sign(make([]byte, 32), txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 1 - Every reservation holds a node of its own
+	first, err := tree.Reserve(txid)
+	if err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	second, err := tree.Reserve(txid)
+	if err != nil {
+		t.Fatal("Failed to reserve node -", err)
+	}
+	if tree.ReservationStats().Active != 2 || tree.Available(nil) != Branches-2 {
+		t.Fatal("Reservations share a node")
+	}
+
+	// 2 - Releasing one leaves the other in place, which signs for its txid
+	if err := tree.Release(first); err != nil {
+		t.Fatal("Failed to release reservation -", err)
+	}
+	if tree.Available(nil) != Branches-1 {
+		t.Fatal("Released reservation freed the node of another one")
+	}
+	if _, err := tree.Sign(make([]byte, 32), txid); err != nil {
+		t.Fatal("Failed to sign with reserved node -", err)
+	}
+	if err := tree.Release(second); err != ErrReservationUnknown {
+		t.Fatal("Reservation was not consumed by Sign, err was", err)
+	}
+}
+
+func TestNYTree_ReserveRemovedNode(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Returns a tree holding unconfirmed children, one of which is reserved
+	// for their txid, and the reserved node.
+	reserved := func() (*NYTree, *nyNode) {
+		tree := New(seed, pubSeed, false)
+		if _, _, err := signMessage("reservation test", tree); err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		id, err := tree.Reserve(tree.nodes[0].txid)
+		if err != nil {
+			t.Fatal("Failed to reserve node -", err)
+		}
+		return tree, tree.reservations[id].node
+	}
+	cleared := func(tree *NYTree, node *nyNode) bool {
+		return tree.ReservationStats().Active == 0 && node.reservation == 0
+	}
+
+	// 1 - Nodes consumed by another state of the tree
+	tree, node := reserved()
+	if tree.MarkConsumed([][]byte{node.pubKeyHash()}) != 1 || !cleared(tree, node) {
+		t.Fatal("Reservation of consumed node was kept")
+	}
+
+	// 2 - Pruned nodes
+	tree, node = reserved()
+	if len(tree.Prune(PrunePolicy{Abandoned: [][]byte{node.txid}})) != Branches || !cleared(tree, node) {
+		t.Fatal("Reservation of pruned node was kept")
+	}
+
+	// 3 - Nodes of a wiped tree
+	tree, node = reserved()
+	tree.Wipe()
+	if !cleared(tree, node) {
+		t.Fatal("Reservation of wiped node was kept")
+	}
+}
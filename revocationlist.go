@@ -0,0 +1,331 @@
+package xnyss
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+var (
+	ErrRevocationListInvalid = newError(ErrCrypto, "revocation list is not signed by the long-term key")
+	ErrRevocationListStale   = newError(ErrState, "revocation list is not newer than the applied one")
+	ErrInvalidRevocationList = newError(ErrEncoding, "invalid revocation list encoding")
+	ErrChainRevoked          = newError(ErrCrypto, "signature chain passes through a revoked node")
+)
+
+// Version byte of an encoded revocation list.
+const revocationListVersion = 0x01
+
+// Tags of the fields of an encoded revocation list. The revoked field holds
+// the concatenated public key hashes of the revoked nodes, in ascending order.
+// Links are encoded as a message field followed by an envelope field.
+const (
+	revocationListFieldSequence  = 0x01
+	revocationListFieldRevoked   = 0x02
+	revocationListFieldSignature = 0x03
+	revocationListFieldMessage   = 0x04
+	revocationListFieldLink      = 0x05
+)
+
+// The signed list of all nodes of a long-term key that were revoked, e.g.
+// because they were delegated to a compromised device, see RevokeNodes. A
+// revoked node may be the root of a subtree: signatures of its descendants are
+// rejected too, since their chains pass through it.
+//
+// Unlike freeze lists, revocation lists are cumulative: every list holds all
+// nodes revoked so far, and replaces the lists with lower sequence numbers, so
+// verifiers only need the latest one, see RevocationSet.
+type RevocationList struct {
+	Sequence uint64
+	// The public key hashes of the revoked nodes, sorted
+	Revoked [][]byte
+	// The signature of RevocationListMessage by the tree
+	Signature *Signature
+	// The signatures linking the root to the node that created Signature, see
+	// Bundle
+	Links []*Signature
+}
+
+// Returns the message signed for the revocation list with sequence number seq
+// of the long-term key rootPubKey, revoking the nodes with the public key
+// hashes revoked, in ascending order. It is also the txid of the signature,
+// see RevokeNodes.
+func RevocationListMessage(rootPubKey []byte, seq uint64, revoked [][]byte) []byte {
+	var header [12]byte
+	binary.BigEndian.PutUint64(header[:], seq)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(revoked)))
+
+	h := sha256.New()
+	h.Write([]byte("xnyss revocation list"))
+	h.Write(rootPubKey)
+	h.Write(header[:])
+	for _, pkh := range revoked {
+		h.Write(pkh)
+	}
+
+	return h.Sum(nil)
+}
+
+// Revokes the nodes of the tree t with the public key hashes pkhs, and returns
+// the next revocation list of t, which holds them together with all nodes
+// revoked before, including those frozen by FreezeBranches. Revoked nodes that
+// t holds are marked consumed. Calling RevokeNodes without hashes reissues the
+// list with a new sequence number.
+//
+// Like Attest, the list includes the signatures linking the root of t to the
+// signing node, and returns ErrBundleIncomplete if they are not available.
+// Returns ErrFrontierInvalid if a hash does not hold 32 bytes.
+func (t *NYTree) RevokeNodes(pkhs [][]byte) (*RevocationList, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	for _, pkh := range pkhs {
+		if len(pkh) != 32 {
+			return nil, ErrFrontierInvalid
+		}
+	}
+
+	t.markConsumed(pkhs)
+	t.addRevokedNodes(pkhs)
+	t.revocationListSeq++
+
+	rl := &RevocationList{Sequence: t.revocationListSeq, Revoked: t.revokedHashes()}
+	t.log(LevelAudit, "nodes revoked", "nodes", len(pkhs), "sequence", rl.Sequence)
+
+	msg := RevocationListMessage(t.publicKey(), rl.Sequence, rl.Revoked)
+	sig, bundle, err := t.signBundle(msg, msg[:TxidLen], nil)
+	if err != nil {
+		return nil, err
+	}
+	rl.Signature, rl.Links = sig, bundle.Links
+
+	return rl, nil
+}
+
+func (t *NYTree) addRevokedNodes(pkhs [][]byte) {
+	if t.revokedNodes == nil {
+		t.revokedNodes = make(map[[32]byte]bool, len(pkhs))
+	}
+	for _, pkh := range pkhs {
+		var key [32]byte
+		copy(key[:], pkh)
+		t.revokedNodes[key] = true
+	}
+}
+
+// Returns the public key hashes of the revoked nodes of t, sorted.
+func (t *NYTree) revokedHashes() [][]byte {
+	revoked := make([][]byte, 0, len(t.revokedNodes))
+	for pkh := range t.revokedNodes {
+		revoked = append(revoked, append([]byte(nil), pkh[:]...))
+	}
+	sortHashes(revoked)
+
+	return revoked
+}
+
+// Encodes the revoked nodes field of the serialised tree: the sequence number
+// of the last revocation list, followed by the revoked public key hashes.
+func (t *NYTree) encodeRevokedNodes() []byte {
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], t.revocationListSeq)
+
+	return append(seq[:], bytes.Join(t.revokedHashes(), nil)...)
+}
+
+func (t *NYTree) loadRevokedNodes(b []byte) error {
+	if len(b) < 8 || (len(b)-8)%32 != 0 {
+		return ErrFieldInvalid
+	}
+
+	t.revocationListSeq = binary.BigEndian.Uint64(b)
+	t.revokedNodes = make(map[[32]byte]bool, (len(b)-8)/32)
+	for i := 8; i < len(b); i += 32 {
+		var pkh [32]byte
+		copy(pkh[:], b[i:])
+		t.revokedNodes[pkh] = true
+	}
+
+	return nil
+}
+
+// Verifies that rl is a revocation list of the long-term key rootPubKey.
+// Returns ErrRevocationListInvalid if rl signs another message, and the errors
+// of VerifyChain if its signatures do not verify.
+func VerifyRevocationList(rootPubKey []byte, rl *RevocationList) error {
+	if rl == nil || rl.Signature == nil {
+		return ErrRevocationListInvalid
+	}
+
+	msg := RevocationListMessage(rootPubKey, rl.Sequence, rl.Revoked)
+	if !bytes.Equal(rl.Signature.Message, msg) {
+		return ErrRevocationListInvalid
+	}
+
+	return (&Bundle{Links: rl.Links}).Verify(rootPubKey, nil, rl.Signature, msg)
+}
+
+// Returns the encoding of the revocation list rl, which holds the envelopes of
+// its signatures (see Signature.Envelope).
+func (rl *RevocationList) Bytes() []byte {
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], rl.Sequence)
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte(revocationListVersion)
+	writeField(buf, revocationListFieldSequence, seq[:])
+	writeField(buf, revocationListFieldRevoked, bytes.Join(rl.Revoked, nil))
+	writeField(buf, revocationListFieldSignature, rl.Signature.Envelope())
+	for _, link := range rl.Links {
+		writeField(buf, revocationListFieldMessage, link.Message)
+		writeField(buf, revocationListFieldLink, link.Envelope())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a revocation list encoded by RevocationList.Bytes of the long-term
+// key rootPubKey. The list still needs to be verified, see
+// VerifyRevocationList.
+func ParseRevocationList(rootPubKey, b []byte) (*RevocationList, error) {
+	if len(b) < 1 || b[0] != revocationListVersion {
+		return nil, ErrInvalidRevocationList
+	}
+
+	rl := &RevocationList{}
+	var sig, msg []byte
+	var hasSeq bool
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case revocationListFieldSequence:
+			if len(value) != 8 {
+				return ErrInvalidRevocationList
+			}
+			rl.Sequence, hasSeq = binary.BigEndian.Uint64(value), true
+		case revocationListFieldRevoked:
+			if len(value)%32 != 0 {
+				return ErrInvalidRevocationList
+			}
+			for i := 0; i < len(value); i += 32 {
+				pkh := append([]byte(nil), value[i:i+32]...)
+				if len(rl.Revoked) > 0 && bytes.Compare(rl.Revoked[len(rl.Revoked)-1], pkh) >= 0 {
+					return ErrInvalidRevocationList
+				}
+				rl.Revoked = append(rl.Revoked, pkh)
+			}
+		case revocationListFieldSignature:
+			sig = value
+		case revocationListFieldMessage:
+			msg = value
+		case revocationListFieldLink:
+			if msg == nil {
+				return ErrInvalidRevocationList
+			}
+			link, err := ParseEnvelope(value, msg)
+			if err != nil {
+				return err
+			}
+			rl.Links = append(rl.Links, link)
+			msg = nil
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !hasSeq || sig == nil {
+		return nil, ErrInvalidRevocationList
+	}
+
+	msg = RevocationListMessage(rootPubKey, rl.Sequence, rl.Revoked)
+	if rl.Signature, err = ParseEnvelope(sig, msg); err != nil {
+		return nil, err
+	}
+
+	return rl, nil
+}
+
+// Enforces the revocation lists of a long-term key on the chains a verifier
+// accepts. It holds the nodes revoked by the latest list applied, see Apply.
+// A RevocationSet is safe for concurrent use.
+type RevocationSet struct {
+	mu         sync.RWMutex
+	rootPubKey []byte
+	sequence   uint64
+	revoked    map[[32]byte]bool
+}
+
+// Creates a revocation set for the long-term key rootPubKey, revoking no
+// nodes.
+func NewRevocationSet(rootPubKey []byte) *RevocationSet {
+	return &RevocationSet{rootPubKey: append([]byte(nil), rootPubKey...)}
+}
+
+// Verifies the revocation list rl, and replaces the revoked nodes of s by
+// those of rl. Returns ErrRevocationListStale if rl does not have a higher
+// sequence number than the list applied before, and the errors of
+// VerifyRevocationList.
+func (s *RevocationSet) Apply(rl *RevocationList) error {
+	if err := VerifyRevocationList(s.rootPubKey, rl); err != nil {
+		return err
+	}
+
+	revoked := make(map[[32]byte]bool, len(rl.Revoked))
+	for _, pkh := range rl.Revoked {
+		var key [32]byte
+		copy(key[:], pkh)
+		revoked[key] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rl.Sequence <= s.sequence {
+		return ErrRevocationListStale
+	}
+	s.sequence, s.revoked = rl.Sequence, revoked
+
+	return nil
+}
+
+// Returns the sequence number of the latest revocation list applied to s, or
+// zero if none was.
+func (s *RevocationSet) Sequence() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.sequence
+}
+
+// Returns whether the node with public key hash pkh is revoked.
+func (s *RevocationSet) Revoked(pkh []byte) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.isRevoked(pkh)
+}
+
+func (s *RevocationSet) isRevoked(pkh []byte) bool {
+	var key [32]byte
+	copy(key[:], pkh)
+
+	return len(pkh) == 32 && s.revoked[key]
+}
+
+// Like VerifyChain for the long-term key of s, also returning ErrChainRevoked
+// if a signature of chain was created by a revoked node, i.e. if chain passes
+// through a revoked subtree.
+func (s *RevocationSet) VerifyChain(chain []*Signature, msg []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return verifyChain(context.Background(), nil, s.rootPubKey, chain, msg, s.isRevoked)
+}
@@ -0,0 +1,95 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNYTree_RevokeNodes(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	rootSig, _, err := signMessage("revocation list test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range rootSig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// Delegate a node to a device, which signs with it
+	device, err := tree.Backup(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delegated := device.Nodes()[0].PubKeyHash
+	msg := sha256.Sum256([]byte("device message"))
+	deviceSig, err := device.Sign(msg[:], msg[:TxidLen])
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := []*Signature{rootSig, deviceSig}
+
+	set := NewRevocationSet(pk)
+	if err := set.VerifyChain(chain, msg[:]); err != nil {
+		t.Fatal("Rejected chain before revocation -", err)
+	}
+
+	// 1 - Revocation lists verify against the long-term key, and verifiers
+	// applying them reject chains through revoked nodes
+	rl, err := tree.RevokeNodes([][]byte{delegated})
+	if err != nil {
+		t.Fatal("Failed to revoke nodes -", err)
+	}
+	if rl.Sequence != 1 || len(rl.Revoked) != 1 {
+		t.Fatal("Invalid revocation list", rl.Sequence, len(rl.Revoked))
+	}
+	if err := set.Apply(rl); err != nil {
+		t.Fatal("Failed to apply revocation list -", err)
+	}
+	if !set.Revoked(delegated) || set.Sequence() != 1 {
+		t.Fatal("Revocation list not applied")
+	}
+	if err := set.VerifyChain(chain, msg[:]); err != ErrChainRevoked {
+		t.Fatal("Accepted chain through revoked node, err was", err)
+	}
+	if err := set.VerifyChain(append(rl.Links, rl.Signature), rl.Signature.Message); err != nil {
+		t.Fatal("Rejected chain through other node -", err)
+	}
+
+	// 2 - Lists are cumulative, survive serialisation of the tree, and only
+	// newer lists are applied
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.revocationListSeq != 1 || len(loaded.revokedHashes()) != 1 {
+		t.Fatal("Revoked nodes not serialised")
+	}
+	next, err := tree.RevokeNodes(nil)
+	if err != nil {
+		t.Fatal("Failed to reissue revocation list -", err)
+	}
+	if next.Sequence != 2 || len(next.Revoked) != 1 {
+		t.Fatal("Reissued list lost revoked nodes", next.Sequence, len(next.Revoked))
+	}
+	if err := set.Apply(rl); err != ErrRevocationListStale {
+		t.Fatal("Applied stale list, err was", err)
+	}
+
+	// 3 - Lists survive encoding, and do not verify for other sequence numbers
+	parsed, err := ParseRevocationList(pk, next.Bytes())
+	if err != nil {
+		t.Fatal("Failed to parse revocation list -", err)
+	}
+	if err := set.Apply(parsed); err != nil {
+		t.Fatal("Failed to apply parsed list -", err)
+	}
+	parsed.Sequence++
+	if err := VerifyRevocationList(pk, parsed); err != ErrRevocationListInvalid {
+		t.Fatal("Verified list with other sequence number, err was", err)
+	}
+}
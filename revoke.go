@@ -0,0 +1,279 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+var (
+	ErrRevocationUnavailable = newError(ErrState, "tree holds no node that can sign a revocation, see EnableRevocation")
+	ErrRevocationInvalid     = newError(ErrCrypto, "revocation does not revoke the long-term key")
+	ErrInvalidRevocation     = newError(ErrEncoding, "invalid revocation encoding")
+)
+
+// Version byte of an encoded revocation.
+const revocationVersion = 0x01
+
+// Tags of the fields of an encoded revocation, and of the revocation field of
+// the serialised tree. Links are encoded as a message field followed by an
+// envelope field.
+const (
+	revocationFieldReason    = 0x01
+	revocationFieldSignature = 0x02
+	revocationFieldMessage   = 0x03
+	revocationFieldLink      = 0x04
+	revocationFieldNode      = 0x05
+)
+
+// A statement that revokes a long-term key, see CreateRevocation.
+type Revocation struct {
+	Reason []byte
+	// The signature of RevocationMessage, by the root or the revocation node
+	Signature *Signature
+	// The signatures linking the root to the node that created Signature, see
+	// Bundle
+	Links []*Signature
+}
+
+// Returns the message signed to revoke the long-term key rootPubKey for the
+// given reason.
+func RevocationMessage(rootPubKey, reason []byte) []byte {
+	reasonHash := sha256.Sum256(reason)
+
+	h := sha256.New()
+	h.Write([]byte("xnyss revocation"))
+	h.Write(rootPubKey)
+	h.Write(reasonHash[:])
+
+	return h.Sum(nil)
+}
+
+// Reserves a node of the fresh tree t for revoking its long-term key, even
+// after all ordinary nodes are exhausted: when the root signs, one of its
+// children is set aside instead of being added to the tree, so the root
+// signature commits to it. Until then, the unused root signs revocations.
+// Returns ErrTreeNotFresh if t was used.
+//
+// The revocation node and the root signature are part of the serialised tree.
+// They are not moved to backups. If the root creates a single child, no node is
+// reserved.
+func (t *NYTree) EnableRevocation() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+	if t.ots {
+		return ErrRevocationUnavailable
+	}
+
+	t.revocable = true
+	t.epoch++
+
+	return nil
+}
+
+// Sets the child node aside as the revocation node, keeping the signature of
+// the root that created it.
+func (t *NYTree) reserveRevocation(node *nyNode, rootSig *Signature) {
+	t.revocationNode = node
+	t.revocationLink = rootSig
+	t.log(LevelAudit, "revocation node reserved", pkhAttr(node.pubKeyHash()))
+}
+
+// Creates a statement revoking the long-term key of the tree t for the given
+// reason, which must be published, see VerifyRevocation. It is signed by the
+// revocation node (see EnableRevocation), or by the root if it did not sign
+// yet. Afterwards, all nodes of t are consumed, so t can no longer sign.
+// Returns ErrRevocationUnavailable if revocation was not enabled, or if the
+// revocation was already created.
+func (t *NYTree) CreateRevocation(reason []byte) (*Revocation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	if !t.revocable {
+		return nil, ErrRevocationUnavailable
+	}
+
+	rev := &Revocation{Reason: append([]byte(nil), reason...)}
+	node := t.revocationNode
+	if node != nil {
+		rev.Links = []*Signature{t.revocationLink}
+	} else {
+		for _, n := range t.nodes {
+			if t.isRoot(n) {
+				node = n
+			}
+		}
+	}
+	if node == nil || t.consumedSeeds[node.seedDigest()] {
+		return nil, ErrRevocationUnavailable
+	}
+
+	msg := RevocationMessage(t.publicKey(), reason)
+	var sig *Signature
+	var err error
+	profile(OpSign, t, func() {
+		sig, _, err = node.sign(msg, msg[:TxidLen], true, 0, bytes.NewReader(nil), nil, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The signature aliases the seeds of the node, which are wiped below
+	sig.PubSeed = append([]byte(nil), sig.PubSeed...)
+	rev.Signature = sig
+
+	// The key is dead: consume every node that could still sign
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool)
+	}
+	if t.consumedSeeds == nil {
+		t.consumedSeeds = make(map[[32]byte]bool)
+	}
+	for _, n := range append(t.nodes, node) {
+		var pkh [32]byte
+		copy(pkh[:], n.pubKeyHash())
+		t.consumed[pkh] = true
+		t.consumedSeeds[n.seedDigest()] = true
+		t.consumeReservation(n)
+	}
+	for _, n := range append(t.nodes, node) {
+		n.wipe()
+	}
+	t.nodes = nil
+	t.dropIndex()
+	t.revocationNode, t.revocationLink = nil, nil
+	t.epoch++
+	t.log(LevelAudit, "key revoked", "reason", string(reason))
+
+	return rev, t.writeThrough()
+}
+
+// Verifies that rev revokes the long-term key rootPubKey. Returns
+// ErrRevocationInvalid if rev signs another message, and the errors of
+// VerifyChain if its signatures do not verify.
+func VerifyRevocation(rootPubKey []byte, rev *Revocation) error {
+	if rev == nil || rev.Signature == nil {
+		return ErrRevocationInvalid
+	}
+
+	msg := RevocationMessage(rootPubKey, rev.Reason)
+	if !bytes.Equal(rev.Signature.Message, msg) {
+		return ErrRevocationInvalid
+	}
+
+	return (&Bundle{Links: rev.Links}).Verify(rootPubKey, nil, rev.Signature, msg)
+}
+
+// Returns the encoding of the revocation rev, which holds the envelopes of its
+// signatures (see Signature.Envelope).
+func (rev *Revocation) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(revocationVersion)
+	writeField(buf, revocationFieldReason, rev.Reason)
+	writeField(buf, revocationFieldSignature, rev.Signature.Envelope())
+	for _, link := range rev.Links {
+		writeField(buf, revocationFieldMessage, link.Message)
+		writeField(buf, revocationFieldLink, link.Envelope())
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a revocation encoded by Revocation.Bytes of the long-term key
+// rootPubKey. The revocation still needs to be verified, see VerifyRevocation.
+func ParseRevocation(rootPubKey, b []byte) (*Revocation, error) {
+	if len(b) < 1 || b[0] != revocationVersion {
+		return nil, ErrInvalidRevocation
+	}
+
+	rev := &Revocation{}
+	var sig, msg []byte
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case revocationFieldReason:
+			rev.Reason = append([]byte(nil), value...)
+		case revocationFieldSignature:
+			sig = value
+		case revocationFieldMessage:
+			msg = value
+		case revocationFieldLink:
+			if msg == nil {
+				return ErrInvalidRevocation
+			}
+			link, err := ParseEnvelope(value, msg)
+			if err != nil {
+				return err
+			}
+			rev.Links = append(rev.Links, link)
+			msg = nil
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sig == nil {
+		return nil, ErrInvalidRevocation
+	}
+
+	if rev.Signature, err = ParseEnvelope(sig, RevocationMessage(rootPubKey, rev.Reason)); err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// Encodes the revocation field of the serialised tree: empty until the root
+// signs, then the revocation node and the root signature.
+func (t *NYTree) encodeRevocation() []byte {
+	if t.revocationNode == nil {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	writeField(buf, revocationFieldNode, append(t.revocationNode.bytes(), t.revocationNode.fields()...))
+	writeField(buf, revocationFieldMessage, t.revocationLink.Message)
+	writeField(buf, revocationFieldLink, t.revocationLink.Envelope())
+
+	return buf.Bytes()
+}
+
+func (t *NYTree) loadRevocation(b []byte) error {
+	t.revocable = true
+	if len(b) == 0 {
+		return nil
+	}
+
+	var msg []byte
+	err := readFields(b, func(tag byte, value []byte) error {
+		var err error
+		switch tag {
+		case revocationFieldNode:
+			t.revocationNode, err = loadFramedNode(value, false)
+		case revocationFieldMessage:
+			msg = value
+		case revocationFieldLink:
+			t.revocationLink, err = ParseEnvelope(value, msg)
+		default:
+			err = ErrFieldInvalid
+		}
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if t.revocationNode == nil || t.revocationLink == nil {
+		return ErrFieldInvalid
+	}
+
+	return nil
+}
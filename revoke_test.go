@@ -0,0 +1,80 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNYTree_CreateRevocation(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.EnableRevocation(); err != nil {
+		t.Fatal("Failed to enable revocation -", err)
+	}
+	pk := tree.PublicKey()
+
+	// 1 - The root sets a child aside for revocation
+	sig, _, err := signMessage("revocation test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Unconfirmed()) != len(sig.ChildHashes)-1 {
+		t.Fatal("Revocation node was not set aside")
+	}
+	if err := tree.EnableRevocation(); err != ErrTreeNotFresh {
+		t.Fatal("Enabled revocation of used tree, err was", err)
+	}
+
+	// 2 - The revocation node survives serialisation, and signs the revocation
+	// even though no ordinary node is available
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	rev, err := loaded.CreateRevocation([]byte("key compromised"))
+	if err != nil {
+		t.Fatal("Failed to revoke -", err)
+	}
+	if err := VerifyRevocation(pk, rev); err != nil {
+		t.Fatal("Failed to verify revocation -", err)
+	}
+	if loaded.Available(nil) != 0 || len(loaded.Unconfirmed()) != 0 {
+		t.Fatal("Revoked tree holds nodes")
+	}
+	if _, err := loaded.CreateRevocation(nil); err != ErrRevocationUnavailable {
+		t.Fatal("Revoked twice, err was", err)
+	}
+
+	// 3 - Revocations survive encoding, and do not verify for other reasons
+	// or keys
+	parsed, err := ParseRevocation(pk, rev.Bytes())
+	if err != nil {
+		t.Fatal("Failed to parse revocation -", err)
+	}
+	if err := VerifyRevocation(pk, parsed); err != nil {
+		t.Fatal("Failed to verify parsed revocation -", err)
+	}
+	parsed.Reason = []byte("other")
+	if err := VerifyRevocation(pk, parsed); err != ErrRevocationInvalid {
+		t.Fatal("Verified revocation for another reason, err was", err)
+	}
+	if err := VerifyRevocation(New(seed, pubSeed[1:], false).PublicKey(), rev); err != ErrRevocationInvalid {
+		t.Fatal("Verified revocation of another key, err was", err)
+	}
+
+	// 4 - An unused root revokes directly, and trees without revocation
+	// can not revoke
+	fresh := New(seed, pubSeed, false)
+	if _, err := fresh.CreateRevocation(nil); err != ErrRevocationUnavailable {
+		t.Fatal("Revoked without revocation node, err was", err)
+	}
+	fresh.EnableRevocation()
+	if rev, err = fresh.CreateRevocation(nil); err != nil || len(rev.Links) != 0 {
+		t.Fatal("Failed to revoke with root -", err)
+	}
+	if err := VerifyRevocation(pk, rev); err != nil {
+		t.Fatal("Failed to verify revocation -", err)
+	}
+}
@@ -0,0 +1,75 @@
+package xnyss
+
+import "bytes"
+
+var (
+	ErrTreeRootLocked = newError(ErrCapacity, "root node is locked, see UnlockRoot")
+)
+
+// Creates a tree like New, but with its root node locked. This should be used
+// when recreating a tree from its seeds, e.g. when restoring a deterministic
+// wallet: the root node might have been used before, and using it again would
+// reuse a one-time key. The root can only be used after an explicit call to
+// UnlockRoot, e.g. once it has been verified that the long-term key was never
+// used on the blockchain.
+func NewRecovered(seed, pubSeed []byte, ots bool) *NYTree {
+	tree := New(seed, pubSeed, ots)
+	tree.rootLocked = true
+
+	return tree
+}
+
+// Locks the root node of t, so it can not be used to sign until UnlockRoot is
+// called. The root node is locked automatically once it creates a signature.
+func (t *NYTree) LockRoot() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.rootLocked {
+		t.rootLocked = true
+		t.epoch++
+	}
+}
+
+// Unlocks the root node of t. This overrides the protection against root reuse,
+// and should only be used when the caller is certain the root node was never
+// used before. Unlocking has no effect once the root has been used by t itself,
+// since it is no longer part of the tree.
+func (t *NYTree) UnlockRoot() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.rootLocked {
+		t.rootLocked = false
+		t.epoch++
+	}
+}
+
+// Returns whether the root node of t is locked.
+func (t *NYTree) RootLocked() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.rootLocked
+}
+
+// Returns whether node is the root node of t.
+func (t *NYTree) isRoot(node *nyNode) bool {
+	return bytes.Equal(node.privSeed, t.rootSeed) &&
+		bytes.Equal(node.pubSeed, t.rootPubSeed)
+}
+
+// Returns whether the tree contains a locked root node.
+func (t *NYTree) hasLockedRoot() bool {
+	if !t.rootLocked {
+		return false
+	}
+
+	for _, node := range t.nodes {
+		if t.isRoot(node) {
+			return true
+		}
+	}
+
+	return false
+}
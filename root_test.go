@@ -0,0 +1,39 @@
+package xnyss
+
+import (
+	"testing"
+)
+
+func TestNewRecovered(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewRecovered(seed, pubSeed, false)
+	msg, txid := make([]byte, 32), make([]byte, 32)
+
+	// 1 - The root of a recovered tree is locked, also after serialisation
+	if _, err := tree.Sign(msg, txid); err != ErrTreeRootLocked {
+		t.Fatal("Signed with a locked root, err was", err)
+	}
+	if tree.Available(nil) != 0 {
+		t.Fatal("Locked root is counted as available")
+	}
+
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !loaded.RootLocked() {
+		t.Fatal("Root lock was not persisted")
+	}
+
+	// 2 - After an explicit override the root signs once, then locks again
+	loaded.UnlockRoot()
+	if _, err := loaded.Sign(msg, txid); err != nil {
+		t.Fatal("Failed to sign with unlocked root -", err)
+	}
+	if !loaded.RootLocked() {
+		t.Fatal("Root was not locked after signing")
+	}
+}
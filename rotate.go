@@ -0,0 +1,103 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+var (
+	ErrRotationInvalid = newError(ErrCrypto, "rotation statement does not transfer the old key to the new key")
+)
+
+// A statement by which the tree with long-term public key OldPublicKey hands
+// over to the tree with long-term public key NewPublicKey, see Rotate.
+type Rotation struct {
+	OldPublicKey []byte
+	NewPublicKey []byte
+	// The signature of RotationMessage by the old tree
+	Signature *Signature
+	// The signatures linking the root of the old tree to the node that
+	// created Signature, oldest first, see Bundle
+	Links []*Signature
+}
+
+// Returns the message signed by the tree with long-term public key oldPubKey to
+// rotate to newPubKey. It is also the txid of the signature, see Rotate.
+func RotationMessage(oldPubKey, newPubKey []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("xnyss rotation"))
+	h.Write(oldPubKey)
+	h.Write(newPubKey)
+
+	return h.Sum(nil)
+}
+
+// Retires the long-term key of the tree t in favour of a fresh tree created
+// from newSeed and newPubSeed, with the same kind of child derivation (see
+// NewDeterministic) and branching schedule. The returned rotation statement
+// is signed by t, and must be published so that verifiers that know the old
+// key can follow it to the new one, see VerifyRotation. The application should
+// stop signing with t afterwards.
+//
+// The statement includes the signatures linking the root of t to the signing
+// node, like SignBundle does for a verifier that only knows the long-term key.
+// Since trees only remember these links since they were loaded, returns
+// ErrBundleIncomplete if they are not available.
+func (t *NYTree) Rotate(newSeed, newPubSeed []byte) (*NYTree, *Rotation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, nil, ErrTreeWiped
+	}
+
+	next := New(newSeed, newPubSeed, false)
+	next.deterministic = t.deterministic
+	next.branching = append([]uint8(nil), t.branching...)
+
+	r := &Rotation{OldPublicKey: t.publicKey(), NewPublicKey: next.PublicKey()}
+	if bytes.Equal(r.OldPublicKey, r.NewPublicKey) {
+		return nil, nil, ErrRotationInvalid
+	}
+
+	msg := RotationMessage(r.OldPublicKey, r.NewPublicKey)
+	sig, bundle, err := t.signBundle(msg, msg[:TxidLen], nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.Signature, r.Links = sig, bundle.Links
+	t.log(LevelAudit, "key rotated", "links", len(r.Links))
+
+	return next, r, nil
+}
+
+// Verifies that r is a rotation statement of the long-term key oldPubKey,
+// created by Rotate. Returns ErrRotationInvalid if r rotates another key or
+// signs another message, and the errors of VerifyChain if its signatures do
+// not verify.
+func VerifyRotation(oldPubKey []byte, r *Rotation) error {
+	if r == nil || r.Signature == nil || !bytes.Equal(r.OldPublicKey, oldPubKey) || len(r.NewPublicKey) == 0 {
+		return ErrRotationInvalid
+	}
+
+	msg := RotationMessage(r.OldPublicKey, r.NewPublicKey)
+	if !bytes.Equal(r.Signature.Message, msg) {
+		return ErrRotationInvalid
+	}
+
+	return (&Bundle{Links: r.Links}).Verify(oldPubKey, nil, r.Signature, msg)
+}
+
+// Follows the lineage of the long-term key pubKey through the rotations,
+// which must be in the order they were made, verifying every one of them.
+// Returns the current key.
+func FollowRotations(pubKey []byte, rotations []*Rotation) ([]byte, error) {
+	for _, r := range rotations {
+		if err := VerifyRotation(pubKey, r); err != nil {
+			return nil, err
+		}
+		pubKey = r.NewPublicKey
+	}
+
+	return append([]byte(nil), pubKey...), nil
+}
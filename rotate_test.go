@@ -0,0 +1,65 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_Rotate(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := NewDeterministic(seed, pubSeed, false)
+	sig, _, err := signMessage("rotate test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	oldPK := tree.PublicKey()
+
+	// 1 - The old key signs the new key
+	newSeed, newPubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, r, err := tree.Rotate(newSeed, newPubSeed)
+	if err != nil {
+		t.Fatal("Failed to rotate -", err)
+	}
+	if !bytes.Equal(r.NewPublicKey, next.PublicKey()) || !next.Deterministic() || len(r.Links) != 1 {
+		t.Fatal("Invalid rotation")
+	}
+	if err := VerifyRotation(oldPK, r); err != nil {
+		t.Fatal("Failed to verify rotation -", err)
+	}
+
+	// 2 - The lineage is followed through several rotations
+	newerSeed, newerPubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, r2, err := next.Rotate(newerSeed, newerPubSeed)
+	if err != nil {
+		t.Fatal("Failed to rotate -", err)
+	}
+	current, err := FollowRotations(oldPK, []*Rotation{r, r2})
+	if err != nil || !bytes.Equal(current, newer.PublicKey()) {
+		t.Fatal("Failed to follow rotations -", err)
+	}
+	if _, err := FollowRotations(oldPK, []*Rotation{r2, r}); err != ErrRotationInvalid {
+		t.Fatal("Followed rotations out of order, err was", err)
+	}
+
+	// 3 - Statements for other keys are rejected
+	forged := *r
+	forged.NewPublicKey = newer.PublicKey()
+	if err := VerifyRotation(oldPK, &forged); err != ErrRotationInvalid {
+		t.Fatal("Verified forged rotation, err was", err)
+	}
+	if _, _, err := tree.Rotate(seed, pubSeed); err != ErrRotationInvalid {
+		t.Fatal("Rotated to the same key, err was", err)
+	}
+}
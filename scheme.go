@@ -0,0 +1,71 @@
+package xnyss
+
+var (
+	ErrSchemeUnknown  = newError(ErrEncoding, "unknown signature scheme")
+	ErrSchemeMismatch = newError(ErrCrypto, "signature does not match its signature scheme")
+)
+
+// Identifies the one-time signature scheme, and the way WOTS+ addresses are
+// derived, used by the keys of a tree. Signatures record the scheme in their
+// parameters, so verifiers fail early and explicitly when a signature is
+// verified with the wrong address, rather than computing a public key that
+// silently does not match.
+type Scheme uint8
+
+const (
+	// WOTS+ with w=256 and SHA-256, using the all-zero address for every key.
+	SchemeWOTSP256 Scheme = 0x01
+	// Like SchemeWOTSP256, but addresses encode the position of the key, see
+	// EnableAddressing.
+	SchemeWOTSP256Addressed Scheme = 0x02
+)
+
+// Returns the signature scheme of the keys of the tree t.
+func (t *NYTree) Scheme() Scheme {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.scheme()
+}
+
+func (t *NYTree) scheme() Scheme {
+	if t.addressed {
+		return SchemeWOTSP256Addressed
+	}
+
+	return SchemeWOTSP256
+}
+
+// Returns ErrSchemeUnknown if sig records a scheme this package does not
+// implement, or ErrSchemeMismatch if the signature does not carry what its
+// scheme needs to be verified, e.g. the position of addressed keys. Signatures
+// without parameters pass the check.
+func (sig *Signature) checkScheme() error {
+	if sig.params == nil || sig.params.Scheme == 0 {
+		return nil
+	}
+
+	switch sig.params.Scheme {
+	case SchemeWOTSP256:
+		if sig.position != nil {
+			return ErrSchemeMismatch
+		}
+	case SchemeWOTSP256Addressed:
+		if sig.position == nil {
+			return ErrSchemeMismatch
+		}
+	default:
+		return ErrSchemeUnknown
+	}
+
+	return nil
+}
+
+// Returns the scheme recorded by sig, or zero if it is not known.
+func (sig *Signature) scheme() Scheme {
+	if sig.params == nil {
+		return 0
+	}
+
+	return sig.params.Scheme
+}
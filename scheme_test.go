@@ -0,0 +1,57 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestSignature_Scheme(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := newLegacy(seed, pubSeed, false)
+	addressed := New(seed, pubSeed, false)
+	if tree.Scheme() != SchemeWOTSP256 || addressed.Scheme() != SchemeWOTSP256Addressed {
+		t.Fatal("Invalid tree schemes")
+	}
+
+	// 1 - Signatures record the scheme of their tree, also in the envelope
+	var chain []*Signature
+	for i := 0; i < 2; i++ {
+		sig, err := addressed.Sign(testdata.Message, Txid("scheme", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		addressed.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+	}
+	parsed, err := ParseEnvelope(chain[1].Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if p, ok := parsed.Params(); !ok || p.Scheme != SchemeWOTSP256Addressed {
+		t.Fatal("Scheme was not included in the envelope")
+	}
+
+	// 2 - Signatures missing what their scheme needs fail early
+	parsed.position = nil
+	if _, err := parsed.PublicKey(); err != ErrSchemeMismatch {
+		t.Fatal("Verified addressed signature without position, err was", err)
+	}
+	parsed.params.Scheme = 0xff
+	if _, err := parsed.PublicKey(); err != ErrSchemeUnknown {
+		t.Fatal("Verified signature of unknown scheme, err was", err)
+	}
+
+	// 3 - Chains can not mix schemes
+	plain, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	mixed := []*Signature{plain, chain[1]}
+	if err := VerifyChain(tree.PublicKey(), mixed, testdata.Message); err != ErrSchemeMismatch {
+		t.Fatal("Verified chain mixing schemes, err was", err)
+	}
+}
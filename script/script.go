@@ -0,0 +1,176 @@
+// Encodes XNYSS signatures as the witness elements of Bitcoin-style script
+// spends, for chains that experiment with post-quantum outputs.
+//
+// Signatures exceed the 520 byte limit of stack elements, so the envelope of a
+// signature (see xnyss.Signature.Envelope) is split into chunks. A witness is
+// a descriptor element followed by the chunks:
+//
+//	version || uint16(parameter set) || uint32(len(envelope))
+//	envelope[0:520]
+//	envelope[520:1040]
+//	...
+//
+// The descriptor lets scripts check the version and parameter set of a
+// signature, and the size of the witness, without parsing the envelope. The
+// parameter set is the xnyss.ParamSetID of the signing tree, or 0 for trees
+// whose parameters are not registered, whose envelope records them instead.
+// The message is not encoded, since the verifier computes it from the spending
+// transaction.
+//
+// The encoding is canonical: every chunk but the last holds exactly
+// MaxElementLen bytes, and the envelope must be the one the signature encodes
+// to. Parse rejects every other witness of the same signature, so third
+// parties can not malleate witnesses.
+package script
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrWitnessVersion   = errors.New("unknown witness version")
+	ErrInvalidWitness   = errors.New("invalid witness encoding")
+	ErrNonCanonical     = errors.New("witness is not canonically encoded")
+	ErrParamSetMismatch = errors.New("witness descriptor does not match the parameter set of the signature")
+)
+
+// The version of witnesses created by Witness.
+const Version = 0x01
+
+// The maximum length of witness elements, that of stack elements in Bitcoin
+// script.
+const MaxElementLen = 520
+
+// The length of the descriptor element of a witness.
+const DescriptorLen = 1 + 2 + 4
+
+// The first element of a witness, describing the signature in its chunks.
+type Descriptor struct {
+	Version uint8
+	// The parameter set of the signing tree, or 0 if it is not registered
+	ParamSet xnyss.ParamSetID
+	// The length of the envelope of the signature
+	Length uint32
+}
+
+// Returns the encoding of the descriptor d.
+func (d Descriptor) Bytes() []byte {
+	b := make([]byte, DescriptorLen)
+	b[0] = d.Version
+	binary.BigEndian.PutUint16(b[1:], uint16(d.ParamSet))
+	binary.BigEndian.PutUint32(b[3:], d.Length)
+
+	return b
+}
+
+// Returns the number of chunks that follow the descriptor d in a witness.
+func (d Descriptor) Chunks() int {
+	return int((uint64(d.Length) + MaxElementLen - 1) / MaxElementLen)
+}
+
+// Decodes the descriptor element of a witness. Returns ErrWitnessVersion if its
+// version is not Version.
+func ParseDescriptor(element []byte) (Descriptor, error) {
+	if len(element) != DescriptorLen {
+		return Descriptor{}, ErrInvalidWitness
+	}
+	if element[0] != Version {
+		return Descriptor{}, ErrWitnessVersion
+	}
+
+	d := Descriptor{
+		Version:  element[0],
+		ParamSet: xnyss.ParamSetID(binary.BigEndian.Uint16(element[1:])),
+		Length:   binary.BigEndian.Uint32(element[3:]),
+	}
+	if d.Length == 0 {
+		return Descriptor{}, ErrInvalidWitness
+	}
+
+	return d, nil
+}
+
+// Returns the witness elements of sig: its descriptor followed by the chunks of
+// its envelope.
+func Witness(sig *xnyss.Signature) [][]byte {
+	envelope := sig.Envelope()
+	d := Descriptor{Version: Version, ParamSet: paramSet(sig), Length: uint32(len(envelope))}
+
+	witness := make([][]byte, 0, 1+d.Chunks())
+	witness = append(witness, d.Bytes())
+	for len(envelope) > 0 {
+		n := len(envelope)
+		if n > MaxElementLen {
+			n = MaxElementLen
+		}
+		witness = append(witness, envelope[:n:n])
+		envelope = envelope[n:]
+	}
+
+	return witness
+}
+
+// Reassembles the signature of msg from the witness elements created by
+// Witness, and validates the witness: the chunks must match the descriptor,
+// and the witness must be the canonical one of the signature. The signature
+// must still be verified, e.g. using xnyss.VerifyChain.
+//
+// Returns ErrNonCanonical if the signature is split or encoded differently,
+// and ErrParamSetMismatch if the descriptor names another parameter set than
+// the signature records.
+func Parse(witness [][]byte, msg []byte) (*xnyss.Signature, error) {
+	if len(witness) < 2 {
+		return nil, ErrInvalidWitness
+	}
+	d, err := ParseDescriptor(witness[0])
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := witness[1:]
+	if len(chunks) != d.Chunks() {
+		return nil, ErrInvalidWitness
+	}
+	envelope := make([]byte, 0, d.Length)
+	for i, chunk := range chunks {
+		if i < len(chunks)-1 && len(chunk) != MaxElementLen {
+			return nil, ErrNonCanonical
+		}
+		envelope = append(envelope, chunk...)
+	}
+	if len(envelope) != int(d.Length) {
+		return nil, ErrInvalidWitness
+	}
+
+	sig, err := xnyss.ParseEnvelope(envelope, msg)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sig.Envelope(), envelope) {
+		return nil, ErrNonCanonical
+	}
+	if paramSet(sig) != d.ParamSet {
+		return nil, ErrParamSetMismatch
+	}
+
+	return sig, nil
+}
+
+// Returns the total size of the witness elements of sig, without their length
+// prefixes, e.g. to estimate transaction fees.
+func WitnessLen(sig *xnyss.Signature) int {
+	return DescriptorLen + len(sig.Envelope())
+}
+
+// Returns the identifier of the parameter set of sig, or 0 if it has none.
+func paramSet(sig *xnyss.Signature) xnyss.ParamSetID {
+	if p, ok := sig.ParamSet(); ok {
+		return p.ID
+	}
+
+	return 0
+}
@@ -0,0 +1,85 @@
+package script
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+)
+
+// Signs a message with a fresh tree of the parameter set id, and returns its
+// long-term public key, the message and the signature.
+func sign(t *testing.T, id xnyss.ParamSetID) ([]byte, []byte, *xnyss.Signature) {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := xnyss.NewParamSet(id, seeds[:32], seeds[32:], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := make([]byte, xnyss.MsgLen)
+	msg[0] = byte(id)
+	sig, err := tree.Sign(msg, xnyss.Txid("script", nil))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	return tree.PublicKey(), msg, sig
+}
+
+func TestWitness(t *testing.T) {
+	for _, id := range []xnyss.ParamSetID{xnyss.ParamsDefault, xnyss.ParamsSHA256W16} {
+		pk, msg, sig := sign(t, id)
+
+		// 1 - Elements respect the push limit, and the descriptor names the
+		// parameter set
+		witness := Witness(sig)
+		size := 0
+		for i, element := range witness {
+			if len(element) > MaxElementLen || len(element) == 0 || i > 0 && i < len(witness)-1 && len(element) != MaxElementLen {
+				t.Fatal("Invalid element length", len(element))
+			}
+			size += len(element)
+		}
+		d, err := ParseDescriptor(witness[0])
+		if err != nil || d.ParamSet != id || d.Chunks() != len(witness)-1 || size != WitnessLen(sig) {
+			t.Fatal("Invalid descriptor", d, err)
+		}
+
+		// 2 - The signature is reassembled, and verifies
+		parsed, err := Parse(witness, msg)
+		if err != nil {
+			t.Fatal("Failed to parse witness -", err)
+		}
+		if !bytes.Equal(parsed.Envelope(), sig.Envelope()) {
+			t.Fatal("Reassembled signature differs")
+		}
+		if err := xnyss.VerifyChain(pk, []*xnyss.Signature{parsed}, msg); err != nil {
+			t.Fatal("Reassembled signature does not verify -", err)
+		}
+
+		// 3 - Witnesses that are split differently are rejected
+		resplit := [][]byte{witness[0], witness[1][:MaxElementLen-1], append(witness[1][MaxElementLen-1:], witness[2]...)}
+		resplit = append(resplit, witness[3:]...)
+		if _, err := Parse(resplit, msg); err != ErrNonCanonical {
+			t.Fatal("Expected ErrNonCanonical, got", err)
+		}
+		if _, err := Parse(witness[:len(witness)-1], msg); err != ErrInvalidWitness {
+			t.Fatal("Expected ErrInvalidWitness, got", err)
+		}
+
+		// 4 - Descriptors of other versions or parameter sets are rejected
+		other := append([][]byte(nil), witness...)
+		other[0] = Descriptor{Version: Version + 1, ParamSet: d.ParamSet, Length: d.Length}.Bytes()
+		if _, err := Parse(other, msg); err != ErrWitnessVersion {
+			t.Fatal("Expected ErrWitnessVersion, got", err)
+		}
+		other[0] = Descriptor{Version: Version, ParamSet: xnyss.ParamsSHA256W4, Length: d.Length}.Bytes()
+		if _, err := Parse(other, msg); err != ErrParamSetMismatch {
+			t.Fatal("Expected ErrParamSetMismatch, got", err)
+		}
+	}
+}
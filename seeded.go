@@ -0,0 +1,288 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+var (
+	ErrTreeNotSeedDerived = newError(ErrState, "tree state can not be derived from its seeds")
+)
+
+// Magic bytes and format version of seed-derived states, see SeedBytes.
+const (
+	seedStateMagic   = "XNYD"
+	seedStateVersion = 0x01
+
+	// Length of the magic bytes, version, flags and seeds
+	seedStateHeaderLen = len(seedStateMagic) + 1 + 1 + 32 + 32
+)
+
+// Tags of the positions walked by the journal of a seed-derived state.
+const (
+	seedNodeAbsent   = 0x00
+	seedNodeConsumed = 0x01
+	seedNodeHeld     = 0x02
+)
+
+// Returns a seed-derived representation of the deterministic tree t, in the
+// format
+//
+//	"XNYD" || version || flags || rootSeed || rootPubSeed || header || journal || checksum
+//
+// where the header holds the fields of the extended header of Bytes, and both
+// are framed as uint32(len(frame)) || frame. Since the seeds of every node
+// follow from the root seeds (see NewDeterministic), the journal only records
+// which nodes were consumed and which are held: it holds a table of txids,
+// uvarint(len(txids)) || txids, followed by one tag per position, walking the
+// tree breadth-first from the root and descending into the children of
+// consumed nodes only. Held nodes are followed by their confirmation count and
+// uvarint(i), referencing txid i-1 of the table, or the all-zero txid if i is
+// zero. A held node takes 3 bytes, where Bytes stores over 150.
+//
+// Only unconfirmed nodes keep their txid, which Sign needs to use them, and the
+// txids of transactions spending nodes are not stored either, see
+// InvalidateTxid: once loaded, confirmed nodes are not found by the txid of
+// the transaction that created them. Creation times are not stored either, so
+// loaded nodes are of unknown age, see Prune. Labelled nodes, and unconfirmed
+// nodes with hashed txids (see SetTxidHashing), are not supported.
+//
+// Returns ErrTreeNotDeterministic if the children of t are random, and
+// ErrTreeNotSeedDerived if t holds state that does not follow from its seeds:
+// nodes that were not derived at their position (see SetCompact), blinded or
+// provider-held seeds, labelled nodes, or consumed nodes of other states (see
+// MarkConsumed).
+func (t *NYTree) SeedBytes() ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	if !t.deterministic {
+		return nil, ErrTreeNotDeterministic
+	}
+	if t.ots || t.rootMask != nil || t.providerSeeds || len(t.consumed) != len(t.consumedSeeds) {
+		return nil, ErrTreeNotSeedDerived
+	}
+
+	held := make(map[Position]*nyNode, len(t.nodes))
+	for _, node := range t.nodes {
+		if node.label != "" || node.confirms < ConfirmsRequired && node.txidLen != 0 || !t.derived(node) {
+			return nil, ErrTreeNotSeedDerived
+		}
+		held[Position{Depth: node.depth, Index: node.index}] = node
+	}
+
+	journal, err := t.seedJournal(held)
+	if err != nil {
+		return nil, err
+	}
+
+	var header bytes.Buffer
+	err = readFields(t.headerFields(), func(tag byte, value []byte) error {
+		if tag != fieldConsumed && tag != fieldConsumedSeeds && tag != fieldTxidParents && tag != fieldNodeParents {
+			writeField(&header, tag, value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(seedStateMagic)
+	buf.WriteByte(seedStateVersion)
+	var flags byte
+	if t.compact {
+		flags |= flagCompact
+	}
+	buf.WriteByte(flags)
+	buf.Write(t.rootSeed)
+	buf.Write(t.rootPubSeed)
+	for _, frame := range [][]byte{header.Bytes(), journal} {
+		binary.Write(buf, binary.BigEndian, uint32(len(frame)))
+		buf.Write(frame)
+	}
+	checksum := sha256.Sum256(buf.Bytes())
+	buf.Write(checksum[:])
+
+	return buf.Bytes(), nil
+}
+
+// Encodes the journal of a seed-derived state of t, whose nodes are held by
+// position. Returns ErrTreeNotSeedDerived if not every held and consumed node
+// is found by walking the tree from the root.
+func (t *NYTree) seedJournal(held map[Position]*nyNode) ([]byte, error) {
+	var txids [][]byte
+	refs := make(map[[32]byte]uint64)
+	walk := &bytes.Buffer{}
+	var found, consumed int
+
+	queue := []*nyNode{t.deriveNode(0, 0, nil)}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if h, ok := held[Position{Depth: node.depth, Index: node.index}]; ok {
+			var ref uint64
+			if h.confirms < ConfirmsRequired {
+				var key [32]byte
+				copy(key[:], h.txid)
+				if ref, ok = refs[key]; !ok && key != ([32]byte{}) {
+					txids = append(txids, key[:])
+					ref = uint64(len(txids))
+					refs[key] = ref
+				}
+			}
+			walk.WriteByte(seedNodeHeld)
+			walk.WriteByte(h.confirms)
+			walk.Write(binary.AppendUvarint(nil, ref))
+			found++
+			continue
+		}
+		if !t.consumedSeeds[node.seedDigest()] {
+			walk.WriteByte(seedNodeAbsent)
+			continue
+		}
+
+		walk.WriteByte(seedNodeConsumed)
+		consumed++
+		// A derivation stream never fails to produce entropy
+		children, _ := node.childNodes(nil, t.branchesAt(node.depth), newDerivationStream(node.privSeed, nil))
+		queue = append(queue, children...)
+	}
+	if found != len(held) || consumed != len(t.consumedSeeds) {
+		return nil, ErrTreeNotSeedDerived
+	}
+
+	buf := binary.AppendUvarint(nil, uint64(len(txids)))
+	buf = append(buf, bytes.Join(txids, nil)...)
+
+	return append(buf, walk.Bytes()...), nil
+}
+
+// Loads a tree from its seed-derived representation, see NYTree.SeedBytes.
+// The seeds of all nodes are derived again, and so is the public key of every
+// consumed node, which makes loading much slower than Load. Returns
+// ErrTreeChecksum if the checksum of the state does not match, and
+// ErrTreeVersion if the state has an unknown format version.
+func LoadSeedBytes(b []byte) (*NYTree, error) {
+	if !bytes.HasPrefix(b, []byte(seedStateMagic)) || len(b) < seedStateHeaderLen+sha256.Size {
+		return nil, ErrTreeInvalidInput
+	}
+	body := b[:len(b)-sha256.Size]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], b[len(body):]) {
+		return nil, ErrTreeChecksum
+	}
+	if body[len(seedStateMagic)] != seedStateVersion {
+		return nil, ErrTreeVersion
+	}
+	flags := body[len(seedStateMagic)+1]
+	if flags&^flagCompact != 0 {
+		return nil, ErrTreeInvalidInput
+	}
+
+	tree := &NYTree{state: state{
+		rootSeed:    append([]byte(nil), body[seedStateHeaderLen-64:seedStateHeaderLen-32]...),
+		rootPubSeed: append([]byte(nil), body[seedStateHeaderLen-32:seedStateHeaderLen]...),
+		compact:     flags&flagCompact != 0,
+	}}
+	header, n, err := readFrame(body[seedStateHeaderLen:])
+	if err != nil {
+		return nil, err
+	}
+	journal, m, err := readFrame(body[seedStateHeaderLen+n:])
+	if err != nil {
+		return nil, err
+	}
+	if seedStateHeaderLen+n+m != len(body) {
+		return nil, ErrTreeInvalidInput
+	}
+	if err := tree.loadHeaderFields(header); err != nil {
+		return nil, err
+	}
+	if !tree.deterministic || tree.providerSeeds {
+		return nil, ErrTreeNotSeedDerived
+	}
+	if err := tree.loadSeedJournal(journal); err != nil {
+		return nil, err
+	}
+
+	tree.loadKeyParams()
+	if err := tree.validateState(); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// Rebuilds the nodes and consumed nodes of t from the journal of a
+// seed-derived state.
+func (t *NYTree) loadSeedJournal(b []byte) error {
+	count, n := binary.Uvarint(b)
+	if n <= 0 || count > uint64(len(b)-n)/32 {
+		return ErrTreeInvalidInput
+	}
+	txids := b[n : n+int(count)*32]
+	walk := b[n+len(txids):]
+
+	t.consumed = make(map[[32]byte]bool)
+	t.consumedSeeds = make(map[[32]byte]bool)
+	root := t.deriveNode(0, 0, nil)
+	root.suite, root.w, root.addressed = t.suite, t.w, t.addressed
+	queue := []*nyNode{root}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if len(walk) == 0 {
+			return ErrTreeInvalidInput
+		}
+		tag := walk[0]
+		walk = walk[1:]
+
+		switch tag {
+		case seedNodeAbsent:
+		case seedNodeHeld:
+			if len(walk) < 1 {
+				return ErrTreeInvalidInput
+			}
+			node.confirms = walk[0]
+			ref, n := binary.Uvarint(walk[1:])
+			if n <= 0 || ref > count {
+				return ErrTreeInvalidInput
+			}
+			walk = walk[1+n:]
+
+			node.txid = make([]byte, TxidLen)
+			if ref > 0 {
+				copy(node.txid, txids[(ref-1)*32:])
+			}
+			node.indexed = node.depth > 0
+			node.derivation = derivationDerived
+			t.nodes = append(t.nodes, node)
+		case seedNodeConsumed:
+			var pkh [32]byte
+			copy(pkh[:], node.pubKeyHash())
+			t.consumed[pkh] = true
+			t.consumedSeeds[node.seedDigest()] = true
+			t.addNodeParent(pkh, node.parent)
+
+			// A derivation stream never fails to produce entropy
+			children, _ := node.childNodes(nil, t.branchesAt(node.depth), newDerivationStream(node.privSeed, nil))
+			for _, child := range children {
+				child.parent = pkh[:]
+			}
+			queue = append(queue, children...)
+		default:
+			return ErrTreeInvalidInput
+		}
+	}
+	if len(walk) != 0 {
+		return ErrTreeInvalidInput
+	}
+
+	return nil
+}
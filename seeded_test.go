@@ -0,0 +1,88 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_SeedBytes(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(seed, pubSeed, false).SeedBytes(); err != ErrTreeNotDeterministic {
+		t.Fatal("Random tree was serialised by its seeds, err was", err)
+	}
+
+	tree := NewDeterministic(seed, pubSeed, false)
+	msg := make([]byte, 32)
+	var pending []byte
+	for i := 0; i < 8; i++ {
+		sig, err := tree.Sign(msg, Txid("seeded", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if i == 7 {
+			pending = Txid("seeded", []byte{byte(i)})
+			break
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired+1)
+		}
+	}
+
+	// 1 - Seed-derived states are a fraction of the size of full states
+	b, err := tree.SeedBytes()
+	if err != nil {
+		t.Fatal("Failed to serialise tree by its seeds -", err)
+	}
+	if full := tree.Bytes(); 4*len(b) > len(full) {
+		t.Fatal("Seed-derived state is not smaller", len(b), len(full))
+	}
+
+	// 2 - Loaded trees hold the same nodes and consumed nodes
+	loaded, err := LoadSeedBytes(b)
+	if err != nil {
+		t.Fatal("Failed to load seed-derived state -", err)
+	}
+	nodes, loadedNodes := tree.canonicalNodes(), loaded.canonicalNodes()
+	if len(nodes) != len(loadedNodes) || len(loaded.consumed) != len(tree.consumed) {
+		t.Fatal("Loaded tree has", len(loadedNodes), "nodes instead of", len(nodes))
+	}
+	for i, node := range loadedNodes {
+		if !bytes.Equal(node.pubKeyHash(), nodes[i].pubKeyHash()) || node.confirms != nodes[i].confirms {
+			t.Fatal("Node", i, "was not loaded correctly")
+		}
+		if node.confirms < ConfirmsRequired && !bytes.Equal(node.txid, pending) {
+			t.Fatal("Unconfirmed node", i, "lost its txid")
+		}
+	}
+	for pkh := range tree.consumed {
+		if !loaded.consumed[pkh] {
+			t.Fatal("Consumed node was not loaded")
+		}
+	}
+
+	// 3 - Loaded trees serialise the same way, and sign with unconfirmed
+	// nodes of matching txid
+	if loaded.Epoch() != tree.Epoch() || loaded.Available(nil) != tree.Available(nil) {
+		t.Fatal("Loaded tree has a different state")
+	}
+	if lb, err := loaded.SeedBytes(); err != nil || !bytes.Equal(lb, b) {
+		t.Fatal("Loaded tree is serialised differently -", err)
+	}
+	if _, err := loaded.Sign(msg, pending); err != nil {
+		t.Fatal("Loaded tree failed to sign for pending txid -", err)
+	}
+
+	// 4 - States that do not follow from the seeds are refused, and damaged
+	// states are detected
+	tree.SetLabel(tree.nodes[0].pubKeyHash(), "label")
+	if _, err := tree.SeedBytes(); err != ErrTreeNotSeedDerived {
+		t.Fatal("Serialised labelled node by its seeds, err was", err)
+	}
+	b[len(b)-40] ^= 1
+	if _, err := LoadSeedBytes(b); err != ErrTreeChecksum {
+		t.Fatal("Loaded damaged state, err was", err)
+	}
+}
@@ -0,0 +1,269 @@
+package xnyss
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrSeedProviderMissing = newError(ErrState, "tree holds seed handles, but no seed provider was set")
+	ErrSeedProviderTree    = newError(ErrState, "operation is not supported by trees using a seed provider")
+	ErrSeedHandleInvalid   = newError(ErrState, "seed handle is invalid or unknown to the provider")
+)
+
+// The length of the handles of a SeedProvider, which take the place of the
+// private seeds in the nodes and serialised state of a tree.
+const SeedHandleLen = 32
+
+// The parameters of the one-time key of a node, passed to a SeedProvider.
+type SeedKeyParams struct {
+	Params  wotsp.Params
+	PubSeed []byte
+	Address *wotsp.Address
+}
+
+// A keystore holding the private seeds of a tree outside of its process, e.g.
+// in an HSM through PKCS#11 or in a cloud KMS, see NewWithSeedProvider. The
+// tree only handles public data and opaque handles of SeedHandleLen bytes,
+// which the provider maps to seeds. A SeedProvider must be safe for concurrent
+// use.
+type SeedProvider interface {
+	// Creates a new seed and returns its handle, together with the WOTS+
+	// public key of the seed for k. If parent is nil, the seed is a root seed
+	// from the provider's own randomness. Otherwise it is the seed of a child
+	// of the node with handle parent, which the software implementation
+	// computes as H(seed || entropy); other providers may mix entropy with
+	// their own randomness instead.
+	Derive(parent, entropy []byte, k SeedKeyParams) (handle, pubKey []byte, err error)
+	// Expands the WOTS+ private key of the seed with handle for k, signs the
+	// digest with it and returns the signature. The expanded key must not
+	// outlive the call.
+	SignExpand(handle, digest []byte, k SeedKeyParams) ([]byte, error)
+	// Destroys the seed with handle. Trees wipe the seed of a node once it
+	// signed, since it is one-time.
+	Wipe(handle []byte) error
+}
+
+// Creates a tree like New whose private seeds are held by the seed provider p,
+// starting from a new root seed created by p. Signing, and creating the
+// children of a node, go through p; the tree and its serialised state only
+// hold handles. Wipe does not destroy the seeds held by p, since other states
+// of the tree, e.g. stored ones, may still use them.
+//
+// The tree uses the default hash suite and Winternitz parameter, which can
+// not be changed, and trees with a provider do not support NewDeterministic
+// derivation or BlindSeed, which need the seeds in process. Loaded states
+// hold handles as well, and need their provider to be set with
+// SetSeedProvider before they can sign.
+func NewWithSeedProvider(p SeedProvider, pubSeed []byte, ots bool) (*NYTree, error) {
+	pub := make([]byte, 32)
+	copy(pub, pubSeed)
+	handle, pubKey, err := p.Derive(nil, nil, SeedKeyParams{
+		Params:  wotsParams(0, 0),
+		PubSeed: pub,
+		Address: &wotsp.Address{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(handle) != SeedHandleLen {
+		return nil, ErrSeedHandleInvalid
+	}
+
+	t := New(handle, pub, ots)
+	t.providerSeeds, t.seedProvider = true, p
+	t.pubKey = append([]byte(nil), pubKey...)
+	t.nodes[0].provider = p
+	t.nodes[0].pkh = t.suite.sum(pubKey)
+
+	return t, nil
+}
+
+// Sets the seed provider holding the private seeds of the tree t, which must
+// have been created by NewWithSeedProvider, e.g. after loading its state.
+// Returns ErrSeedProviderTree if t holds its own seeds.
+func (t *NYTree) SetSeedProvider(p SeedProvider) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.providerSeeds {
+		return ErrSeedProviderTree
+	}
+	if p == nil {
+		p = missingSeedProvider{}
+	}
+
+	t.seedProvider = p
+	t.loadSeedProvider()
+
+	return nil
+}
+
+// Returns whether the private seeds of t are held by a seed provider, see
+// NewWithSeedProvider.
+func (t *NYTree) UsesSeedProvider() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.providerSeeds
+}
+
+// Sets the seed provider of the nodes of t to that of the tree, or to one
+// returning ErrSeedProviderMissing if it has none.
+func (t *NYTree) loadSeedProvider() {
+	if !t.providerSeeds {
+		return
+	}
+	if t.seedProvider == nil {
+		t.seedProvider = missingSeedProvider{}
+	}
+
+	for _, node := range t.nodes {
+		node.provider = t.seedProvider
+	}
+	if t.revocationNode != nil {
+		t.revocationNode.provider = t.seedProvider
+	}
+}
+
+// Returns the parameters of the one-time key of n for its seed provider.
+func (n *nyNode) seedKeyParams() SeedKeyParams {
+	return SeedKeyParams{Params: n.wots(), PubSeed: n.pubSeed, Address: n.position().address()}
+}
+
+// The seed provider of loaded trees until SetSeedProvider is called.
+type missingSeedProvider struct{}
+
+func (missingSeedProvider) Derive(parent, entropy []byte, k SeedKeyParams) ([]byte, []byte, error) {
+	return nil, nil, ErrSeedProviderMissing
+}
+
+func (missingSeedProvider) SignExpand(handle, digest []byte, k SeedKeyParams) ([]byte, error) {
+	return nil, ErrSeedProviderMissing
+}
+
+func (missingSeedProvider) Wipe(handle []byte) error {
+	return ErrSeedProviderMissing
+}
+
+// A SeedProvider keeping the seeds in process memory, under random handles. It
+// is the reference for other providers, and lets applications use the same
+// code paths with and without external keystores. Its seeds are lost when the
+// process exits.
+type SoftwareSeedProvider struct {
+	mu    sync.Mutex
+	seeds map[[SeedHandleLen]byte][]byte
+	rand  io.Reader
+}
+
+// Creates a software seed provider reading root seeds and handles from
+// crypto/rand.
+func NewSoftwareSeedProvider() *SoftwareSeedProvider {
+	return &SoftwareSeedProvider{seeds: make(map[[SeedHandleLen]byte][]byte), rand: rand.Reader}
+}
+
+// Sets the source of the root seeds and handles of p, e.g. the entropy of a
+// Harness. Passing nil restores crypto/rand.
+func (p *SoftwareSeedProvider) SetEntropy(r io.Reader) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r == nil {
+		r = rand.Reader
+	}
+	p.rand = r
+}
+
+// Implements SeedProvider.
+func (p *SoftwareSeedProvider) Derive(parent, entropy []byte, k SeedKeyParams) (handle, pubKey []byte, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seed := make([]byte, 32)
+	if parent == nil {
+		if _, err := io.ReadFull(p.rand, seed); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		parentSeed, err := p.seed(parent)
+		if err != nil {
+			return nil, nil, err
+		}
+		s := sha256.New()
+		s.Write(parentSeed)
+		s.Write(entropy)
+		s.Sum(seed[:0])
+	}
+
+	var key [SeedHandleLen]byte
+	for {
+		if _, err := io.ReadFull(p.rand, key[:]); err != nil {
+			wipeBytes(seed)
+			return nil, nil, err
+		}
+		if _, ok := p.seeds[key]; !ok {
+			break
+		}
+	}
+	p.seeds[key] = seed
+
+	return append([]byte(nil), key[:]...), k.Params.GenPublicKey(seed, k.PubSeed, k.Address), nil
+}
+
+// Implements SeedProvider.
+func (p *SoftwareSeedProvider) SignExpand(handle, digest []byte, k SeedKeyParams) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seed, err := p.seed(handle)
+	if err != nil {
+		return nil, err
+	}
+
+	key := k.Params.NewPrivateKey(seed, k.PubSeed)
+	defer key.Wipe()
+
+	return key.Sign(digest, k.Address), nil
+}
+
+// Implements SeedProvider.
+func (p *SoftwareSeedProvider) Wipe(handle []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	seed, err := p.seed(handle)
+	if err != nil {
+		return err
+	}
+	wipeBytes(seed)
+
+	var key [SeedHandleLen]byte
+	copy(key[:], handle)
+	delete(p.seeds, key)
+
+	return nil
+}
+
+// Returns the number of seeds held by p.
+func (p *SoftwareSeedProvider) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.seeds)
+}
+
+func (p *SoftwareSeedProvider) seed(handle []byte) ([]byte, error) {
+	var key [SeedHandleLen]byte
+	copy(key[:], handle)
+
+	seed, ok := p.seeds[key]
+	if !ok || len(handle) != SeedHandleLen {
+		return nil, ErrSeedHandleInvalid
+	}
+
+	return seed, nil
+}
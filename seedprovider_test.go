@@ -0,0 +1,82 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestNewWithSeedProvider(t *testing.T) {
+	p := NewSoftwareSeedProvider()
+	_, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := NewWithSeedProvider(p, pubSeed, false)
+	if err != nil {
+		t.Fatal("Failed to create tree -", err)
+	}
+
+	// 1 - Signatures verify against the long-term key, and seeds are destroyed
+	// once they signed
+	msg := sha256.Sum256([]byte("seed provider test"))
+	sig, err := tree.Sign(msg[:], msg[:TxidLen])
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if ok, err := Verify(tree.PublicKey(), sig, msg[:]); !ok {
+		t.Fatal("Signature does not verify -", err)
+	}
+	if p.Len() != len(sig.ChildHashes) {
+		t.Fatal("Invalid amount of seeds held by the provider", p.Len())
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 2 - The state only holds handles
+	state := tree.Bytes()
+	for _, seed := range p.seeds {
+		if bytes.Contains(state, seed) {
+			t.Fatal("State holds a seed of the provider")
+		}
+	}
+
+	// 3 - Loaded states sign once their provider is set
+	loaded, err := Load(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.UsesSeedProvider() || !bytes.Equal(loaded.PublicKey(), tree.PublicKey()) {
+		t.Fatal("Loaded tree lost its seed provider")
+	}
+	childMsg := sha256.Sum256([]byte("loaded"))
+	if _, err := loaded.Sign(childMsg[:], childMsg[:TxidLen]); err != ErrSeedProviderMissing {
+		t.Fatal("Signed without seed provider, err was", err)
+	}
+	if err := loaded.SetSeedProvider(p); err != nil {
+		t.Fatal(err)
+	}
+	childSig, err := loaded.Sign(childMsg[:], childMsg[:TxidLen])
+	if err != nil {
+		t.Fatal("Failed to sign with loaded tree -", err)
+	}
+	if err := VerifyChain(tree.PublicKey(), []*Signature{sig, childSig}, childMsg[:]); err != nil {
+		t.Fatal("Signature of loaded tree does not verify -", err)
+	}
+
+	// 4 - Operations needing the seeds in process are rejected
+	if err := tree.BlindSeed(); err != ErrSeedProviderTree {
+		t.Fatal("Blinded seed handles, err was", err)
+	}
+	fresh, err := NewWithSeedProvider(p, pubSeed, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.SetWinternitz(16); err != ErrSeedProviderTree {
+		t.Fatal("Changed Winternitz parameter, err was", err)
+	}
+	if err := New(pubSeed, pubSeed, false).SetSeedProvider(p); err != ErrSeedProviderTree {
+		t.Fatal("Set seed provider of tree holding its seeds, err was", err)
+	}
+}
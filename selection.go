@@ -0,0 +1,170 @@
+package xnyss
+
+var (
+	ErrSelectionInvalid = newError(ErrState, "unknown node selection strategy")
+)
+
+// Determines which confirmed node Sign uses when no node is reserved for the
+// txid it signs for, and no unconfirmed node has a matching txid.
+type Selection uint8
+
+const (
+	// The strategy set by DefaultSelection. This is the default.
+	SelectDefault Selection = iota
+	// The first usable node, in the order of the nodes of the tree. This
+	// tends to grow one side of the tree, which makes chains deep.
+	SelectFirst
+	// The shallowest usable node, so that the depth of the tree, and thus the
+	// length of chains, grows as slowly as possible.
+	SelectBalanced
+	// The usable node whose creating transaction has the fewest descendants,
+	// i.e. transactions signed by the nodes it created and their children, so
+	// that the subtrees of transactions are used up one after the other
+	// instead of spreading signatures across all of them.
+	SelectFewestDescendants
+	// The first usable node after the position of the node selected last,
+	// wrapping around, so that consecutive signatures skip over nodes and use
+	// different parts of the tree.
+	SelectRoundRobin
+	// The usable node that was created first, see Prune, so nodes do not
+	// stay unused for long. Nodes of unknown age are considered the oldest.
+	SelectOldestConfirmed
+)
+
+// The strategy used by trees whose strategy is SelectDefault.
+var DefaultSelection = SelectBalanced
+
+// Sets the node selection strategy of the tree t. The strategy is included in
+// the serialised tree.
+func (t *NYTree) SetSelection(s Selection) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if s > SelectOldestConfirmed {
+		return ErrSelectionInvalid
+	}
+
+	if t.selection != s {
+		t.selection = s
+		t.epoch++
+	}
+
+	return nil
+}
+
+// Returns the node selection strategy of the tree t.
+func (t *NYTree) Selection() Selection {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.selection
+}
+
+// Returns the index of the node for which usable returns true that is selected
+// by the strategy of t, or -1 if there is none.
+func (t *NYTree) selectNode(usable func(*nyNode) bool) int {
+	s := t.selection
+	if s == SelectDefault {
+		s = DefaultSelection
+	}
+
+	switch s {
+	case SelectBalanced:
+		return t.shallowest(usable)
+	case SelectFewestDescendants:
+		return t.fewestDescendants(usable)
+	case SelectRoundRobin:
+		return t.nextRoundRobin(usable)
+	case SelectOldestConfirmed:
+		return t.oldest(usable)
+	}
+
+	// Nodes beyond the maximum depth create no children, so the shallowest is
+	// preferred whenever the depth is limited
+	if t.maxDepth > 0 {
+		return t.shallowest(usable)
+	}
+	for i, node := range t.nodes {
+		if usable(node) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Returns the index of the node for which usable returns true whose txid has
+// the fewest descendant txids, see txidDescendants, or -1 if there is none.
+func (t *NYTree) fewestDescendants(usable func(*nyNode) bool) int {
+	children := make(map[[32]byte][][32]byte)
+	for child, parents := range t.txidParents {
+		for _, parent := range parents {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	counts := make(map[[32]byte]int)
+	count := func(txid [32]byte) int {
+		if n, ok := counts[txid]; ok {
+			return n
+		}
+		seen := map[[32]byte]bool{txid: true}
+		queue := [][32]byte{txid}
+		for len(queue) > 0 {
+			next := queue[0]
+			queue = queue[1:]
+			for _, child := range children[next] {
+				if !seen[child] {
+					seen[child] = true
+					queue = append(queue, child)
+				}
+			}
+		}
+		counts[txid] = len(seen) - 1
+		return counts[txid]
+	}
+
+	index, fewest := -1, 0
+	for i, node := range t.nodes {
+		if !usable(node) {
+			continue
+		}
+		var txid [32]byte
+		copy(txid[:], node.txid)
+		if n := count(txid); index < 0 || n < fewest {
+			index, fewest = i, n
+		}
+	}
+
+	return index
+}
+
+// Returns the index of the first node for which usable returns true after the
+// position of the node selected last by round-robin selection, or -1 if there
+// is none.
+func (t *NYTree) nextRoundRobin(usable func(*nyNode) bool) int {
+	for i := range t.nodes {
+		index := (t.roundRobin + i) % len(t.nodes)
+		if usable(t.nodes[index]) {
+			// The selected node is removed once it signs, so its successor
+			// takes its index and is skipped next time
+			t.roundRobin = index + 1
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Returns the index of the oldest node for which usable returns true, or -1 if
+// there is none.
+func (t *NYTree) oldest(usable func(*nyNode) bool) int {
+	index := -1
+	for i, node := range t.nodes {
+		if usable(node) && (index < 0 || node.created < t.nodes[index].created) {
+			index = i
+		}
+	}
+
+	return index
+}
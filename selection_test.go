@@ -0,0 +1,109 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+// Returns a tree whose root and first child signed and whose nodes are all
+// confirmed, holding the other children of the root followed by the children
+// of the first child.
+func selectionTree(t *testing.T) *NYTree {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetSelection(SelectFirst)
+	for i := byte(0); i < 2; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("selection", []byte{i}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+	}
+
+	return tree
+}
+
+// Signs with tree for a new txid, and returns the public key hash of the node
+// that signed.
+func selectedNode(t *testing.T, tree *NYTree) []byte {
+	sig, err := tree.Sign(testdata.Message, Txid("selection", []byte{0xff, byte(tree.Epoch())}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	pkh, err := sig.PublicKeyHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pkh
+}
+
+func TestNYTree_SetSelection(t *testing.T) {
+	// 1 - Balanced selection prefers shallower nodes, and is the default
+	for _, selection := range []Selection{SelectDefault, SelectFirst} {
+		tree := selectionTree(t)
+		last := len(tree.nodes) - 1
+		tree.nodes[0], tree.nodes[last] = tree.nodes[last], tree.nodes[0]
+		deep, shallow := tree.nodes[0].pubKeyHash(), tree.nodes[1].pubKeyHash()
+		if tree.nodes[0].depth <= tree.nodes[1].depth {
+			t.Fatal("Invalid test tree")
+		}
+		tree.SetSelection(selection)
+		pkh := selectedNode(t, tree)
+		if selection == SelectDefault && !bytes.Equal(pkh, shallow) {
+			t.Fatal("Default selection did not use the shallowest node")
+		}
+		if selection == SelectFirst && !bytes.Equal(pkh, deep) {
+			t.Fatal("First selection did not use the first node")
+		}
+	}
+
+	// 2 - Fewest-descendants selection prefers nodes of transactions with
+	// smaller subtrees
+	tree := selectionTree(t)
+	tree.SetSelection(SelectFewestDescendants)
+	grandchild := tree.nodes[len(tree.nodes)-Branches].pubKeyHash()
+	if !bytes.Equal(selectedNode(t, tree), grandchild) {
+		t.Fatal("Fewest-descendants selection used a node of a larger subtree")
+	}
+
+	// 3 - Round-robin selection skips the successor of the node selected last
+	tree = selectionTree(t)
+	tree.SetSelection(SelectRoundRobin)
+	first := tree.nodes[0].pubKeyHash()
+	third := tree.nodes[2].pubKeyHash()
+	if !bytes.Equal(selectedNode(t, tree), first) || !bytes.Equal(selectedNode(t, tree), third) {
+		t.Fatal("Round-robin selection did not rotate")
+	}
+
+	// 4 - Oldest-confirmed selection prefers nodes created first
+	tree = selectionTree(t)
+	tree.SetSelection(SelectOldestConfirmed)
+	for i, node := range tree.nodes {
+		node.created = int64(len(tree.nodes) - i)
+	}
+	oldest := tree.nodes[len(tree.nodes)-1].pubKeyHash()
+	if !bytes.Equal(selectedNode(t, tree), oldest) {
+		t.Fatal("Oldest-confirmed selection did not use the oldest node")
+	}
+
+	// 5 - The strategy is persisted, and unknown strategies are rejected
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.Selection() != SelectOldestConfirmed {
+		t.Fatal("Selection strategy was not persisted")
+	}
+	if err := tree.SetSelection(SelectOldestConfirmed + 1); err != ErrSelectionInvalid {
+		t.Fatal("Set unknown selection strategy, err was", err)
+	}
+}
@@ -0,0 +1,101 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/Re0h/xnyss/wotsp"
+	"github.com/Re0h/xnyss/wotsp256"
+)
+
+var (
+	ErrSelfTest = newError(ErrCrypto, "self-test failed: primitives do not match known answers")
+)
+
+// Denotes whether Load runs SelfTest before loading the first tree. The result
+// of the self-test is cached, so it only runs once per process.
+var SelfTestOnLoad = false
+
+// Known answers of the self-test: SHA-256 digests of the WOTS+ public keys and
+// signatures of both parameter sets, and of the tree signature, all derived
+// from the inputs in selfTestInput.
+const (
+	selfTestWotsPK     = "e938bffb0d269c9286ba44519d7263c73ce0dc65118f15f43296d8d20ea1923e"
+	selfTestWotsSig    = "34c644f3b8fe73ea75d1727bb6499e5b184084dfbf26f68ba4cf7b948dbacf8c"
+	selfTestWots256PK  = "6a3b12a77c0d4751ca209dcfa986f96b9b13b4d12530e9bff91ed98d0ed3648d"
+	selfTestWots256Sig = "fae90533821e0b3bb27a3c3fb00ea42a446c28c53d88ff2c3a9eed7c31ed2f7f"
+	selfTestTreeSig    = "acb3e1f2e2abcea68be1dce491624d830560a7ddc21d253114e39234388e38e9"
+)
+
+var (
+	selfTestOnceDo sync.Once
+	selfTestErr    error
+)
+
+// Checks the WOTS+ implementations of wotsp and wotsp256, and the digest
+// construction of tree signatures, against embedded known-answer vectors.
+// Returns ErrSelfTest if any result differs, which indicates a miscompiled
+// binary or a faulty hashing backend. The self-test takes a few milliseconds,
+// and does not touch any tree: it is meant to run on startup, before the first
+// real signature is created.
+func SelfTest() error {
+	seed, pubSeed, msg := selfTestInput("seed"), selfTestInput("pub seed"), selfTestInput("message")
+
+	adrs := &wotsp.Address{}
+	adrs.SetLayer(1)
+	adrs.SetTree(2)
+	adrs.SetOTS(3)
+	pk := wotsp.GenPublicKey(seed, pubSeed, adrs)
+	sig := wotsp.Sign(msg, seed, pubSeed, adrs)
+	if !knownAnswer(pk, selfTestWotsPK) || !knownAnswer(sig, selfTestWotsSig) ||
+		!bytes.Equal(wotsp.PkFromSig(sig, msg, pubSeed, adrs), pk) {
+		return ErrSelfTest
+	}
+
+	adrs256 := &wotsp256.Address{}
+	adrs256.SetLayer(1)
+	adrs256.SetTree(2)
+	adrs256.SetOTS(3)
+	pk = wotsp256.GenPublicKey(seed, pubSeed, adrs256)
+	sig = wotsp256.Sign(msg, seed, pubSeed, adrs256)
+	if !knownAnswer(pk, selfTestWots256PK) || !knownAnswer(sig, selfTestWots256Sig) ||
+		!bytes.Equal(wotsp256.PkFromSig(sig, msg, pubSeed, adrs256), pk) {
+		return ErrSelfTest
+	}
+
+	// Sign with a deterministic root node directly, so no events are emitted
+	// and no tree state is involved
+	root := &nyNode{privSeed: seed, pubSeed: pubSeed, txid: make([]byte, 32)}
+	treeSig, _, err := root.sign(msg, selfTestInput("txid"), false, 2, root.entropy(true), nil, nil)
+	if err != nil {
+		return err
+	}
+	if !knownAnswer(treeSig.Bytes(), selfTestTreeSig) {
+		return ErrSelfTest
+	}
+	if rootPK, err := treeSig.publicKey(nil); err != nil || !bytes.Equal(rootPK, root.genPubKey()) {
+		return ErrSelfTest
+	}
+
+	return nil
+}
+
+// Runs SelfTest once, returning its cached result afterwards.
+func selfTestOnce() error {
+	selfTestOnceDo.Do(func() { selfTestErr = SelfTest() })
+	return selfTestErr
+}
+
+// Returns H("xnyss self-test " || name).
+func selfTestInput(name string) []byte {
+	h := sha256.Sum256([]byte("xnyss self-test " + name))
+	return h[:]
+}
+
+// Returns whether H(b) equals the hex encoded answer.
+func knownAnswer(b []byte, answer string) bool {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]) == answer
+}
@@ -0,0 +1,38 @@
+package xnyss
+
+import (
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+func TestSelfTest(t *testing.T) {
+	// 1 - The primitives match the known answers
+	if err := SelfTest(); err != nil {
+		t.Fatal("Self-test failed -", err)
+	}
+
+	// 2 - The constant-time backends produce the same results
+	defer func(ct bool) { wotsp.ConstantTime = ct }(wotsp.ConstantTime)
+	wotsp.ConstantTime = true
+	if err := SelfTest(); err != nil {
+		t.Fatal("Self-test of constant-time backends failed -", err)
+	}
+
+	// 3 - Other answers do not
+	if knownAnswer(selfTestInput("seed"), selfTestWotsPK) {
+		t.Fatal("Known answer matched the wrong input")
+	}
+
+	// 4 - Load runs the self-test if enabled
+	defer func(enabled bool) { SelfTestOnLoad = enabled }(SelfTestOnLoad)
+	SelfTestOnLoad = true
+
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(New(seed, pubSeed, false).Bytes()); err != nil {
+		t.Fatal("Failed to load with self-test -", err)
+	}
+}
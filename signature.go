@@ -1,41 +1,77 @@
 package xnyss
 
 import (
-	wotsp "github.com/Re0h/xnyss/wotsp256"
-	"crypto/sha256"
-	"errors"
+	"github.com/Re0h/xnyss/wotsp"
 	"bytes"
 )
 
 var (
-	ErrInvalidSigEncoding = errors.New("invalid signature encoding")
-	ErrSigMsgNotSet       = errors.New("signature message is not set")
+	ErrInvalidSigEncoding = newError(ErrEncoding, "invalid signature encoding")
+	ErrSigMsgNotSet       = newError(ErrState, "signature message is not set")
+	ErrSigNotCanonical    = newError(ErrEncoding, "signature child hashes are not in canonical order")
+	ErrSigTooManyChildren = newError(ErrEncoding, "signature holds more than MaxChildHashes child hashes")
 )
 
+// The maximum number of child hashes of a signature accepted by NewSignature
+// and the other signature decoders, or zero for no limit. Decoding allocates
+// every child hash, so verifiers of untrusted signatures reject larger
+// encodings before allocating anything. The default fits the largest branching
+// factor of a tree, see SetBranching.
+var MaxChildHashes = 255
+
 type Signature struct {
 	PubSeed     []byte
 	Message     []byte
 	ChildHashes [][]byte
 	SigBytes    []byte
+
+	// Parameters of the tree that created the signature, if known.
+	params *Params
+	// The counter bound into the signed digest, if any, see SetCounterBinding.
+	counter *uint64
+	// The data committed to by the signature, if any, see SignCommitted.
+	commitment []byte
+	// The randomizer of the digest signed as message, if any, see
+	// SignMessage.
+	randomizer []byte
+	// The position of the node that created the signature, if the tree uses
+	// position addressing, see NYTree.EnableAddressing.
+	position *Position
+	// The hash suite of the tree that created the signature, or zero for
+	// SHA-256, see HashSuite.
+	suite HashSuite
+	// The Winternitz parameter of the tree that created the signature, or zero
+	// for the default, see Winternitz.
+	w uint16
 }
 
 func NewSignature(sigBytes, msg []byte) (sig *Signature, err error) {
-	if len(sigBytes) < wotsp.SigLen+32 || (len(sigBytes) - (wotsp.SigLen+32)) % 32 != 0 {
+	return newSignature(0, sigBytes, msg)
+}
+
+func newSignature(w uint16, sigBytes, msg []byte) (sig *Signature, err error) {
+	sigLen := wotsParams(w, 0).SigLen()
+	if len(sigBytes) < sigLen+32 || (len(sigBytes) - sigLen) % 32 != 0 {
 		err = ErrInvalidSigEncoding
 		return
 	}
+	if MaxChildHashes > 0 && (len(sigBytes) - sigLen - 32) / 32 > MaxChildHashes {
+		err = ErrSigTooManyChildren
+		return
+	}
 
 	sig = &Signature{
-		SigBytes:   make([]byte, wotsp.SigLen),
+		SigBytes:   make([]byte, sigLen),
 		PubSeed:    make([]byte, 32),
 		Message:    make([]byte, 32),
+		w:          w,
 	}
 
 	copy(sig.Message, msg)
 	copy(sig.SigBytes, sigBytes)
-	copy(sig.PubSeed, sigBytes[wotsp.SigLen:])
+	copy(sig.PubSeed, sigBytes[sigLen:])
 
-	childBytes := sigBytes[wotsp.SigLen+32:]
+	childBytes := sigBytes[sigLen+32:]
 	if len(childBytes) > 0 {
 		sig.ChildHashes = make([][]byte, len(childBytes) / 32)
 
@@ -45,15 +81,69 @@ func NewSignature(sigBytes, msg []byte) (sig *Signature, err error) {
 		}
 	}
 
+	if !sig.Canonical() {
+		sig, err = nil, ErrSigNotCanonical
+	}
+
+	return
+}
+
+// Returns whether the child hashes of sig are in canonical order: strictly
+// ascending, which also means no child hash occurs twice. Sign only creates
+// canonical signatures, and NewSignature rejects others.
+func (sig *Signature) Canonical() bool {
+	for i := 1; i < len(sig.ChildHashes); i++ {
+		if bytes.Compare(sig.ChildHashes[i-1], sig.ChildHashes[i]) >= 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sig *Signature) PublicKey() (pk []byte, err error) {
+	return sig.publicKeyIn(nil)
+}
+
+// Returns the public key hash of the node that created the signature sig,
+// which is one of the child hashes of the signature of its parent, computed
+// from its public key (see PublicKey) using the hash suite of sig.
+func (sig *Signature) PublicKeyHash() ([]byte, error) {
+	pk, err := sig.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return sig.suite.sum(pk), nil
+}
+
+// Like PublicKey, computing the public key in the arena a if it is not nil.
+func (sig *Signature) publicKeyIn(a *wotsp.Arena) (pk []byte, err error) {
+	profile(OpVerify, nil, func() {
+		pk, err = sig.publicKey(a)
+	})
+
 	return
 }
 
-func (sig *Signature) PublicKey() ([]byte, error) {
+func (sig *Signature) publicKey(a *wotsp.Arena) ([]byte, error) {
 	if len(sig.Message) == 0 {
 		return nil, ErrSigMsgNotSet
 	}
 
-	s := sha256.New()
+	if _, err := sig.suite.lookup(); err != nil {
+		return nil, err
+	}
+	wots := sig.wots()
+
+	if len(sig.SigBytes) != wots.SigLen() || len(sig.PubSeed) != 32 {
+		return nil, ErrInvalidSigEncoding
+	}
+	if err := sig.checkScheme(); err != nil {
+		return nil, err
+	}
+
+	s := wots.Hash()
 	s.Write(sig.Message)
 
 	if sig.ChildHashes != nil {
@@ -61,8 +151,18 @@ func (sig *Signature) PublicKey() ([]byte, error) {
 			s.Write(sig.ChildHashes[i])
 		}
 	}
+	if sig.counter != nil {
+		s.Write(encodeCounter(*sig.counter))
+	}
+	if sig.commitment != nil {
+		s.Write(sig.suite.sum(sig.commitment))
+	}
+
+	if a != nil {
+		return wots.PkFromSigArena(a, sig.SigBytes, s.Sum(nil), sig.PubSeed, sig.position.address()), nil
+	}
 
-	return wotsp.PkFromSig(sig.SigBytes, s.Sum(nil), sig.PubSeed, &wotsp.Address{}), nil
+	return wots.PkFromSig(sig.SigBytes, s.Sum(nil), sig.PubSeed, sig.position.address()), nil
 }
 
 func (sig *Signature) Bytes() []byte {
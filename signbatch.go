@@ -0,0 +1,121 @@
+package xnyss
+
+import "maps"
+
+// Signs the messages of all inputs of the transaction txid as one operation:
+// either a signature is created for every message, or the tree is left
+// unchanged and an error is returned. The first message is signed by a node
+// selected like Sign does, the others by the child nodes of the signatures of
+// the batch, so all inputs are signed within the same subtree and the
+// transaction uses up only one available node.
+//
+// One-time and frozen trees do not create child nodes, so they can only sign a
+// batch of a single message and return ErrTreeNotLongTerm otherwise. Events and
+// log records of signatures created before a batch failed are not retracted.
+func (t *NYTree) SignBatch(msgs [][]byte, txid []byte) (sigs []*Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.signBatch(msgs, txid, nil)
+}
+
+// Like SignBatch, calling selected, if not nil, with the index of every
+// message and the node selected to sign it.
+func (t *NYTree) signBatch(msgs [][]byte, txid []byte, selected func(i int, node *nyNode) error) (sigs []*Signature, err error) {
+	for _, msg := range msgs {
+		if len(msg) > MsgLen {
+			return nil, ErrInvalidMsgLen
+		}
+	}
+	if txid == nil && t.ots {
+		txid = make([]byte, TxidLen)
+	}
+	txid, _, err = t.normalizeTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+	if (t.ots || t.frozen) && len(msgs) > 1 {
+		return nil, ErrTreeNotLongTerm
+	}
+
+	// The seeds of the nodes that signed are only wiped once the batch
+	// succeeded, since restore adds the nodes back otherwise
+	restore := t.snapshot()
+	var signed []*nyNode
+	batch := make(map[*Signature]bool, len(msgs))
+	inBatch := func(n *nyNode) bool {
+		return n.link != nil && batch[n.link.sig]
+	}
+
+	profile(OpSign, t, func() {
+		for i, msg := range msgs {
+			opts := &signOptions{consumed: func(node *nyNode) { signed = append(signed, node) }}
+			if i > 0 {
+				opts.filter = inBatch
+			}
+			if selected != nil {
+				opts.selected = func(node *nyNode) error { return selected(i, node) }
+			}
+
+			var sig *Signature
+			if sig, err = t.sign(msg, txid, opts); err != nil {
+				restore()
+				sigs = nil
+				return
+			}
+			batch[sig] = true
+			sigs = append(sigs, sig)
+		}
+		for _, node := range signed {
+			node.wipeSeed()
+		}
+	})
+
+	return
+}
+
+// Returns a function that restores the parts of the state of t changed by
+// sign, used to undo a batch that failed halfway. Hardened one-time trees wipe
+// their state once they sign, which can not be undone.
+func (t *NYTree) snapshot() func() {
+	s := struct {
+		nodes         []*nyNode
+		consumedSeeds map[[32]byte]bool
+		consumed      map[[32]byte]bool
+		txidParents   map[[32]byte][][32]byte
+		nodeParents   map[[32]byte][32]byte
+		reservations  map[ReservationID]*reservation
+		resStats      ReservationStats
+		epoch         uint64
+		counter       uint64
+		rootLocked    bool
+	}{
+		nodes:         append([]*nyNode(nil), t.nodes...),
+		consumedSeeds: maps.Clone(t.consumedSeeds),
+		consumed:      maps.Clone(t.consumed),
+		txidParents:   maps.Clone(t.txidParents),
+		nodeParents:   maps.Clone(t.nodeParents),
+		reservations:  maps.Clone(t.reservations),
+		resStats:      t.resStats,
+		epoch:         t.epoch,
+		counter:       t.counter,
+		rootLocked:    t.rootLocked,
+	}
+
+	return func() {
+		t.nodes = s.nodes
+		t.consumedSeeds = s.consumedSeeds
+		t.consumed = s.consumed
+		t.txidParents = s.txidParents
+		t.nodeParents = s.nodeParents
+		t.reservations = s.reservations
+		for id, r := range t.reservations {
+			r.node.reservation = id
+		}
+		t.resStats = s.resStats
+		t.epoch = s.epoch
+		t.counter = s.counter
+		t.rootLocked = s.rootLocked
+		t.dropIndex()
+	}
+}
@@ -0,0 +1,53 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_SignBatch(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msgs := [][]byte{make([]byte, 32), bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)}
+	txid := bytes.Repeat([]byte{0xaa}, 32)
+
+	// 1 - All inputs are signed within the subtree of the first signature
+	sigs, err := tree.SignBatch(msgs, txid)
+	if err != nil {
+		t.Fatal("Failed to sign batch -", err)
+	}
+	if len(sigs) != len(msgs) {
+		t.Fatal("Invalid number of signatures")
+	}
+	for i := 1; i < len(sigs); i++ {
+		chain := []*Signature{sigs[0], sigs[i]}
+		if err := VerifyChain(tree.PublicKey(), chain, msgs[i]); err != nil {
+			t.Fatal("Signature", i, "was not created by a child node of the first -", err)
+		}
+	}
+
+	// 2 - A failing batch leaves the tree unchanged
+	for _, pkh := range sigs[2].ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	tree.SetRateLimit(RateLimit{Rate: 0.001, Burst: 2}, RateLimit{})
+	before := tree.Bytes()
+	if _, err := tree.SignBatch(msgs, bytes.Repeat([]byte{0xbb}, 32)); err != ErrRateLimited {
+		t.Fatal("Batch should have been rate limited, err was", err)
+	}
+	if !bytes.Equal(before, tree.Bytes()) {
+		t.Fatal("Failed batch changed the tree")
+	}
+
+	// 3 - One-time trees only sign batches of a single message
+	ots := New(seed, pubSeed, true)
+	if _, err := ots.SignBatch(msgs, txid); err != ErrTreeNotLongTerm {
+		t.Fatal("One-time tree signed a batch, err was", err)
+	}
+	if _, err := ots.SignBatch(msgs[:1], txid); err != nil {
+		t.Fatal("Failed to sign single message batch -", err)
+	}
+}
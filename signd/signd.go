@@ -0,0 +1,388 @@
+//go:build xnyssexp
+
+// Serves a shared XNYSS tree to other applications over a JSON API, so that
+// they can sign with one long-term key without holding its seeds. Requests are
+// authenticated by the access package, with an API key passed as a bearer
+// token or a verified TLS client certificate, and every request is authorized
+// and logged by an access.Guard. The tree should be in write-through mode (see
+// xnyss.NYTree.SetStorage), so that a signature is only returned once the
+// consumed node is durable.
+//
+// The API is served under /v1, with hex encoded binary values:
+//
+//	POST /v1/sign         {"message", "txid"} -> {"envelope"}
+//	POST /v1/confirm      {"pub_key_hash", "confirms"} -> {}
+//	GET  /v1/available    ?txid= -> {"available"}
+//	GET  /v1/unconfirmed  -> {"pub_key_hashes"}
+//	POST /v1/backup       {"count"} -> {"state"}
+//
+// Failed requests return {"error"} with a status matching the category of the
+// error. Only JSON over HTTP is provided: a gRPC service would add a dependency
+// the module does not have, but can be built on Server's Guard in the same way.
+//
+// The package is experimental: its API may change between releases, and it is
+// only built with the xnyssexp build tag, see the README.
+package signd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Re0h/xnyss"
+	"github.com/Re0h/xnyss/access"
+)
+
+var (
+	ErrMethod  = errors.New("method not allowed")
+	ErrRequest = errors.New("invalid request")
+)
+
+// The largest request body accepted.
+const maxBody = 1 << 16
+
+type SignRequest struct {
+	Message string `json:"message"`
+	Txid    string `json:"txid"`
+}
+
+type SignResponse struct {
+	// The envelope of the signature, see xnyss.Signature.Envelope
+	Envelope string `json:"envelope"`
+}
+
+type ConfirmRequest struct {
+	PubKeyHash string `json:"pub_key_hash"`
+	Confirms   uint8  `json:"confirms"`
+}
+
+type AvailableResponse struct {
+	Available int `json:"available"`
+}
+
+type UnconfirmedResponse struct {
+	PubKeyHashes []string `json:"pub_key_hashes"`
+}
+
+type BackupRequest struct {
+	Count int `json:"count"`
+}
+
+type BackupResponse struct {
+	// The serialised backup, see xnyss.NYTree.Bytes
+	State string `json:"state"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// An http.Handler serving the API of a guarded tree.
+type Server struct {
+	guard *access.Guard
+	auth  *access.Authenticator
+	mux   *http.ServeMux
+}
+
+func NewServer(guard *access.Guard, auth *access.Authenticator) *Server {
+	s := &Server{guard: guard, auth: auth, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/v1/sign", s.handle(http.MethodPost, s.sign))
+	s.mux.HandleFunc("/v1/confirm", s.handle(http.MethodPost, s.confirm))
+	s.mux.HandleFunc("/v1/available", s.handle(http.MethodGet, s.available))
+	s.mux.HandleFunc("/v1/unconfirmed", s.handle(http.MethodGet, s.unconfirmed))
+	s.mux.HandleFunc("/v1/backup", s.handle(http.MethodPost, s.backup))
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+type handlerFunc func(p *access.Principal, r *http.Request) (interface{}, error)
+
+// Wraps f, authenticating the request and encoding the result of f.
+func (s *Server) handle(method string, f handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var res interface{}
+		p, err := s.authenticate(r)
+		if err == nil && r.Method != method {
+			err = ErrMethod
+		}
+		if err == nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+			res, err = f(p, r)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(status(err))
+			res = &errorResponse{Error: err.Error()}
+		}
+		json.NewEncoder(w).Encode(res)
+	}
+}
+
+// Returns the principal of the client certificate of r, or else of the API key
+// in its Authorization header.
+func (s *Server) authenticate(r *http.Request) (*access.Principal, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return s.auth.TLS(r.TLS)
+	}
+
+	key := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" {
+		return nil, access.ErrUnauthenticated
+	}
+
+	return s.auth.Key(key)
+}
+
+// Returns the HTTP status of the error err.
+func status(err error) int {
+	switch {
+	case err == access.ErrUnauthenticated:
+		return http.StatusUnauthorized
+	case err == access.ErrUnauthorized:
+		return http.StatusForbidden
+	case err == ErrMethod:
+		return http.StatusMethodNotAllowed
+	case err == ErrRequest, errors.Is(err, xnyss.ErrEncoding):
+		return http.StatusBadRequest
+	case errors.Is(err, xnyss.ErrCapacity):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, xnyss.ErrState):
+		return http.StatusConflict
+	}
+
+	return http.StatusInternalServerError
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return ErrRequest
+	}
+
+	return nil
+}
+
+func decodeHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, ErrRequest
+	}
+
+	return b, nil
+}
+
+func (s *Server) sign(p *access.Principal, r *http.Request) (interface{}, error) {
+	req := &SignRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	msg, err := decodeHex(req.Message)
+	if err != nil {
+		return nil, err
+	}
+	txid, err := decodeHex(req.Txid)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := s.guard.Sign(p, msg, txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignResponse{Envelope: hex.EncodeToString(sig.Envelope())}, nil
+}
+
+func (s *Server) confirm(p *access.Principal, r *http.Request) (interface{}, error) {
+	req := &ConfirmRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+	pkh, err := decodeHex(req.PubKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Confirm(p, pkh, req.Confirms); err != nil {
+		return nil, err
+	}
+
+	return struct{}{}, nil
+}
+
+func (s *Server) available(p *access.Principal, r *http.Request) (interface{}, error) {
+	var txid []byte
+	if v := r.URL.Query().Get("txid"); v != "" {
+		var err error
+		if txid, err = decodeHex(v); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := s.guard.Available(p, txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvailableResponse{Available: n}, nil
+}
+
+func (s *Server) unconfirmed(p *access.Principal, r *http.Request) (interface{}, error) {
+	pkhs, err := s.guard.Unconfirmed(p)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &UnconfirmedResponse{PubKeyHashes: make([]string, len(pkhs))}
+	for i, pkh := range pkhs {
+		res.PubKeyHashes[i] = hex.EncodeToString(pkh)
+	}
+
+	return res, nil
+}
+
+func (s *Server) backup(p *access.Principal, r *http.Request) (interface{}, error) {
+	req := &BackupRequest{}
+	if err := decode(r, req); err != nil {
+		return nil, err
+	}
+
+	backup, err := s.guard.Backup(p, req.Count)
+	if err != nil {
+		return nil, err
+	}
+	defer backup.Wipe()
+
+	return &BackupResponse{State: hex.EncodeToString(backup.Bytes())}, nil
+}
+
+// A client of a Server, authenticating with an API key. For client
+// certificates, configure the TLS settings of HTTP and leave Key empty.
+type Client struct {
+	// The URL the server is served at, without the /v1 prefix
+	URL  string
+	Key  string
+	HTTP *http.Client
+}
+
+func NewClient(url, key string) *Client {
+	return &Client{URL: strings.TrimSuffix(url, "/"), Key: key, HTTP: http.DefaultClient}
+}
+
+// Performs a request, decoding the response into res. Errors of the server are
+// returned with their message.
+func (c *Client) do(method, path string, req, res interface{}) error {
+	var body bytes.Buffer
+	if req != nil {
+		if err := json.NewEncoder(&body).Encode(req); err != nil {
+			return err
+		}
+	}
+
+	r, err := http.NewRequest(method, c.URL+path, &body)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if c.Key != "" {
+		r.Header.Set("Authorization", "Bearer "+c.Key)
+	}
+
+	resp, err := c.HTTP.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e := &errorResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(e); err != nil || e.Error == "" {
+			return fmt.Errorf("signd: %s", resp.Status)
+		}
+		return fmt.Errorf("signd: %s: %s", resp.Status, e.Error)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(res)
+}
+
+// Signs msg for txid with the tree of the server.
+func (c *Client) Sign(msg, txid []byte) (*xnyss.Signature, error) {
+	res := &SignResponse{}
+	req := &SignRequest{Message: hex.EncodeToString(msg), Txid: hex.EncodeToString(txid)}
+	if err := c.do(http.MethodPost, "/v1/sign", req, res); err != nil {
+		return nil, err
+	}
+
+	envelope, err := hex.DecodeString(res.Envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	return xnyss.ParseEnvelope(envelope, msg)
+}
+
+// Confirms the node with public key hash pkh.
+func (c *Client) Confirm(pkh []byte, confirms uint8) error {
+	req := &ConfirmRequest{PubKeyHash: hex.EncodeToString(pkh), Confirms: confirms}
+	return c.do(http.MethodPost, "/v1/confirm", req, &struct{}{})
+}
+
+// Returns the number of signatures the tree of the server can create for txid,
+// which may be nil.
+func (c *Client) Available(txid []byte) (int, error) {
+	path := "/v1/available"
+	if txid != nil {
+		path += "?txid=" + url.QueryEscape(hex.EncodeToString(txid))
+	}
+
+	res := &AvailableResponse{}
+	if err := c.do(http.MethodGet, path, nil, res); err != nil {
+		return 0, err
+	}
+
+	return res.Available, nil
+}
+
+// Returns the public key hashes of the unconfirmed nodes of the tree of the
+// server.
+func (c *Client) Unconfirmed() ([][]byte, error) {
+	res := &UnconfirmedResponse{}
+	if err := c.do(http.MethodGet, "/v1/unconfirmed", nil, res); err != nil {
+		return nil, err
+	}
+
+	pkhs := make([][]byte, len(res.PubKeyHashes))
+	for i, s := range res.PubKeyHashes {
+		pkh, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		pkhs[i] = pkh
+	}
+
+	return pkhs, nil
+}
+
+// Moves count nodes of the tree of the server into a backup, and returns it.
+func (c *Client) Backup(count int) (*xnyss.NYTree, error) {
+	res := &BackupResponse{}
+	if err := c.do(http.MethodPost, "/v1/backup", &BackupRequest{Count: count}, res); err != nil {
+		return nil, err
+	}
+
+	state, err := hex.DecodeString(res.State)
+	if err != nil {
+		return nil, err
+	}
+
+	return xnyss.Load(state)
+}
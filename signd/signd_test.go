@@ -0,0 +1,93 @@
+//go:build xnyssexp
+
+package signd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Re0h/xnyss"
+	"github.com/Re0h/xnyss/access"
+)
+
+func TestServer(t *testing.T) {
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree := xnyss.New(seeds[:32], seeds[32:], false)
+
+	auth := access.NewAuthenticator()
+	auth.AddKey("signer-key", access.Principal{Name: "signer", Roles: access.RoleSign})
+	auth.AddKey("oracle-key", access.Principal{Name: "oracle", Roles: access.RoleConfirm})
+	auth.AddKey("admin-key", access.Principal{Name: "admin", Roles: access.RoleAdmin})
+	log := &bytes.Buffer{}
+	srv := httptest.NewServer(NewServer(access.NewGuard(tree, access.NewLog(log)), auth))
+	defer srv.Close()
+
+	signer := NewClient(srv.URL, "signer-key")
+	oracle := NewClient(srv.URL, "oracle-key")
+
+	// 1 - Signatures created by the server verify against the long-term key
+	msg := sha256.Sum256([]byte("signd test"))
+	sig, err := signer.Sign(msg[:], msg[:xnyss.TxidLen])
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if ok, err := xnyss.Verify(tree.PublicKey(), sig, msg[:]); !ok {
+		t.Fatal("Signature of the server does not verify -", err)
+	}
+
+	// 2 - Unconfirmed nodes are listed and confirmed through the API
+	pkhs, err := oracle.Unconfirmed()
+	if err != nil || len(pkhs) != len(sig.ChildHashes) {
+		t.Fatal("Invalid unconfirmed nodes", len(pkhs), err)
+	}
+	if n, err := signer.Available(nil); err != nil || n != 0 {
+		t.Fatal("Unconfirmed nodes available", n, err)
+	}
+	for _, pkh := range pkhs {
+		if err := oracle.Confirm(pkh, xnyss.ConfirmsRequired); err != nil {
+			t.Fatal("Failed to confirm -", err)
+		}
+	}
+	if n, err := signer.Available(nil); err != nil || n != len(pkhs) {
+		t.Fatal("Confirmed nodes not available", n, err)
+	}
+
+	// 3 - Roles are enforced, and unknown keys rejected
+	if _, err := oracle.Sign(msg[:], msg[:xnyss.TxidLen]); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatal("Oracle was allowed to sign, err was", err)
+	}
+	if _, err := NewClient(srv.URL, "unknown-key").Unconfirmed(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatal("Unknown key was authenticated, err was", err)
+	}
+	resp, err := http.Get(srv.URL + "/v1/sign")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatal("Unauthenticated request returned status", resp.StatusCode)
+	}
+
+	// 4 - Backups are returned to administrators
+	backup, err := NewClient(srv.URL, "admin-key").Backup(1)
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	if len(backup.Nodes()) != 1 || tree.Available(nil) != len(pkhs)-1 {
+		t.Fatal("Backup did not move a node", len(backup.Nodes()))
+	}
+
+	// 5 - Every request is logged
+	last, err := access.Verify(bytes.NewReader(log.Bytes()))
+	if err != nil || last.Op != "backup" || last.Principal != "admin" {
+		t.Fatal("Requests not logged", last, err)
+	}
+}
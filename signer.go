@@ -0,0 +1,69 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+)
+
+var (
+	ErrSignerOpts = newError(ErrState, "signer options must be *SignerOpts")
+)
+
+// The long-term public key of a tree as a crypto.PublicKey. Signatures created
+// by the tree are verified against it with VerifyChain.
+type PublicKey []byte
+
+// Implements the Equal method of the public keys of the standard library.
+func (pk PublicKey) Equal(x crypto.PublicKey) bool {
+	other, ok := x.(PublicKey)
+	return ok && bytes.Equal(pk, other)
+}
+
+// Options of Signer.Sign. Every signature needs a txid, see NYTree.Sign.
+type SignerOpts struct {
+	Txid []byte
+	// The hash function used to compute the digest, if any. The digest is
+	// signed as is either way.
+	Hash crypto.Hash
+}
+
+// Implements crypto.SignerOpts.
+func (o *SignerOpts) HashFunc() crypto.Hash {
+	return o.Hash
+}
+
+// Wraps a tree as a crypto.Signer, for code that works with the key pairs of
+// the standard library. NYTree itself can not implement it, since its Sign
+// method takes a txid rather than options.
+type Signer struct {
+	tree *NYTree
+}
+
+// Returns the tree as a crypto.Signer.
+func (t *NYTree) Signer() *Signer {
+	return &Signer{tree: t}
+}
+
+// Implements crypto.Signer, returning the long-term key as a PublicKey.
+func (s *Signer) Public() crypto.PublicKey {
+	return PublicKey(s.tree.PublicKey())
+}
+
+// Implements crypto.Signer, returning the signature of digest in the encoding
+// of Signature.Bytes. The signature is created by the tree from its own
+// entropy, so rand is not used. The opts must be a *SignerOpts holding the
+// txid of the signature.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	o, ok := opts.(*SignerOpts)
+	if !ok || o == nil {
+		return nil, ErrSignerOpts
+	}
+
+	sig, err := s.tree.Sign(digest, o.Txid)
+	if err != nil {
+		return nil, err
+	}
+
+	return sig.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+package xnyss
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestSigner(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	var signer crypto.Signer = tree.Signer()
+
+	// 1 - The public key is the long-term key
+	pk, ok := signer.Public().(PublicKey)
+	if !ok || !pk.Equal(PublicKey(tree.PublicKey())) {
+		t.Fatal("Public key does not equal the long-term key")
+	}
+	if pk.Equal(tree.PublicKey()) {
+		t.Fatal("Public key equals a key of another type")
+	}
+
+	// 2 - Signatures verify against the public key
+	digest := sha256.Sum256([]byte("message"))
+	opts := &SignerOpts{Txid: testdata.Txid, Hash: crypto.SHA256}
+	sigBytes, err := signer.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	sig, err := NewSignature(sigBytes, digest[:])
+	if err != nil {
+		t.Fatal("Failed to decode signature -", err)
+	}
+	if err := VerifyChain(pk, []*Signature{sig}, digest[:]); err != nil {
+		t.Fatal("Failed to verify signature -", err)
+	}
+
+	// 3 - The txid must be passed in the options
+	if _, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256); err != ErrSignerOpts {
+		t.Fatal("Signed without a txid, err was", err)
+	}
+}
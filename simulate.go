@@ -0,0 +1,131 @@
+package xnyss
+
+import (
+	"math/rand"
+	"time"
+)
+
+var (
+	ErrSimulationParams = newError(ErrState, "simulation needs a latency distribution and positive durations")
+)
+
+// Parameters of a capacity simulation, see Simulate.
+type SimulationParams struct {
+	// Branching schedule as accepted by SetBranching. If empty, Branches is used.
+	Branching []int
+	// Returns the time it takes until a signature's transaction has the required
+	// number of confirmations, i.e. until its child nodes can be used.
+	ConfirmLatency func(r *rand.Rand) time.Duration
+	// Mean time between signing requests. Requests arrive as a Poisson process.
+	SignInterval time.Duration
+	// Total simulated time, and the interval at which samples are taken.
+	Duration time.Duration
+	Step     time.Duration
+	// Seed of the random number generator, so simulations can be repeated.
+	Seed int64
+}
+
+// The projected state of a tree at a point in simulated time.
+type SimulationSample struct {
+	Time time.Duration
+	// Nodes that can be used to sign, and nodes waiting for confirmations.
+	Available int
+	Pending   int
+	// Cumulative number of created signatures, and of requests that failed
+	// because no nodes were available.
+	Signed int
+	Failed int
+}
+
+// Returns a latency distribution that always takes d.
+func FixedLatency(d time.Duration) func(r *rand.Rand) time.Duration {
+	return func(*rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Returns an exponential latency distribution with the given mean, which
+// roughly models waiting for a block on proof-of-work chains.
+func ExponentialLatency(mean time.Duration) func(r *rand.Rand) time.Duration {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+type simNode struct {
+	depth   int
+	readyAt time.Duration
+}
+
+// Projects the available capacity of a new long-term tree over time, given its
+// branching schedule, the latency of confirmations and the signing rate. Every
+// request is assumed to belong to a different transaction, and the simulation
+// does not perform any cryptographic operations. This lets integrators choose
+// Branches and ConfirmsRequired (through its effect on latency) with data.
+//
+// Returns ErrInvalidBranching if the branching schedule is not valid, and
+// ErrSimulationParams if ConfirmLatency is nil, or Step, SignInterval or
+// Duration are not positive.
+func Simulate(p SimulationParams) ([]SimulationSample, error) {
+	tree := &NYTree{}
+	if err := tree.SetBranching(p.Branching); err != nil {
+		return nil, err
+	}
+	if p.ConfirmLatency == nil || p.Step <= 0 || p.SignInterval <= 0 || p.Duration <= 0 {
+		return nil, ErrSimulationParams
+	}
+	r := rand.New(rand.NewSource(p.Seed))
+
+	available := []simNode{{depth: 0}}
+	var pending []simNode
+	var signed, failed int
+
+	samples := make([]SimulationSample, 0, p.Duration/p.Step+1)
+	nextRequest := time.Duration(r.ExpFloat64() * float64(p.SignInterval))
+
+	for now := time.Duration(0); now <= p.Duration; now += p.Step {
+		for ; nextRequest <= now; nextRequest += time.Duration(r.ExpFloat64() * float64(p.SignInterval)) {
+			// Confirm pending nodes that are ready at the time of the request
+			remaining := pending[:0]
+			for _, node := range pending {
+				if node.readyAt <= nextRequest {
+					available = append(available, node)
+				} else {
+					remaining = append(remaining, node)
+				}
+			}
+			pending = remaining
+
+			if len(available) == 0 {
+				failed++
+				continue
+			}
+
+			node := available[0]
+			available = available[1:]
+			signed++
+
+			readyAt := nextRequest + p.ConfirmLatency(r)
+			for i := 0; i < tree.branchesAt(uint32(node.depth)); i++ {
+				pending = append(pending, simNode{depth: node.depth + 1, readyAt: readyAt})
+			}
+		}
+
+		ready := 0
+		for _, node := range pending {
+			if node.readyAt <= now {
+				ready++
+			}
+		}
+
+		samples = append(samples, SimulationSample{
+			Time:      now,
+			Available: len(available) + ready,
+			Pending:   len(pending) - ready,
+			Signed:    signed,
+			Failed:    failed,
+		})
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,63 @@
+package xnyss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulate(t *testing.T) {
+	// 1 - A chain (one child per node) can sign at most once per confirmation
+	samples, err := Simulate(SimulationParams{
+		Branching:      []int{1},
+		ConfirmLatency: FixedLatency(10 * time.Minute),
+		SignInterval:   time.Minute,
+		Duration:       time.Hour,
+		Step:           time.Minute,
+	})
+	if err != nil {
+		t.Fatal("Failed to simulate -", err)
+	}
+	if len(samples) != 61 {
+		t.Fatal("Got", len(samples), "samples, should be 61")
+	}
+	last := samples[len(samples)-1]
+	if last.Signed > 7 || last.Failed == 0 {
+		t.Fatalf("Invalid projection for a chain %+v", last)
+	}
+
+	// 2 - Simulations are reproducible, and wider trees build up capacity
+	params := SimulationParams{
+		Branching:      []int{3},
+		ConfirmLatency: ExponentialLatency(10 * time.Minute),
+		SignInterval:   5 * time.Minute,
+		Duration:       24 * time.Hour,
+		Step:           time.Hour,
+		Seed:           42,
+	}
+	a, err := Simulate(params)
+	if err != nil {
+		t.Fatal("Failed to simulate -", err)
+	}
+	b, _ := Simulate(params)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("Simulation with the same seed is not reproducible")
+		}
+	}
+	if last := a[len(a)-1]; last.Available < 10 {
+		t.Fatalf("Tree with branching factor 3 did not build capacity %+v", last)
+	}
+
+	// 3 - Invalid parameters are refused
+	if _, err := Simulate(SimulationParams{Branching: []int{0}, Step: time.Second}); err != ErrInvalidBranching {
+		t.Fatal("Invalid branching schedule was accepted, err was", err)
+	}
+	params.ConfirmLatency = nil
+	if _, err := Simulate(params); err != ErrSimulationParams {
+		t.Fatal("Missing latency distribution was accepted, err was", err)
+	}
+	params.ConfirmLatency, params.Step = FixedLatency(time.Minute), 0
+	if _, err := Simulate(params); err != ErrSimulationParams {
+		t.Fatal("Zero step was accepted, err was", err)
+	}
+}
@@ -0,0 +1,118 @@
+package xnyss
+
+import "math"
+
+// Length of the counter field in a signature envelope.
+const EnvelopeCounterLen = 5 + 8
+
+// Length of the position field in a signature envelope.
+const EnvelopePositionLen = 5 + 12
+
+// Length of the hash suite field in a signature envelope.
+const EnvelopeHashSuiteLen = 5 + 1
+
+// Length of the Winternitz parameter field in a signature envelope.
+const EnvelopeWinternitzLen = 5 + 2
+
+// Length of the parameter set field in a signature envelope.
+const EnvelopeParamSetLen = 5 + 2
+
+// Length of the message randomizer field in a signature envelope.
+const EnvelopeRandomizerLen = 5 + RandomizerLen
+
+// Length of the public key hash field, which every serialised node holds.
+const pkhFieldLen = 5 + 32
+
+// Returns the length of Signature.Bytes for a signature with the given amount
+// of child hashes (0 for signatures of one-time trees), created by a tree with
+// the default Winternitz parameter.
+func SignatureLen(branches int) int {
+	return SigLen + 32 + 32*branches
+}
+
+// Returns the length of sig.Bytes(), see SignatureLen.
+func (sig *Signature) EncodedLen() int {
+	return len(sig.SigBytes) + len(sig.PubSeed) + 32*len(sig.ChildHashes)
+}
+
+// Returns the length of Signature.Envelope for a signature with the given
+// amount of child hashes. If withParams is false, the length of an envelope
+// without parameters is returned. Envelopes of signatures with a counter (see
+// SetCounterBinding) are EnvelopeCounterLen bytes longer, those of addressed
+// signatures, which includes those of all trees created by New (see
+// EnableAddressing), EnvelopePositionLen bytes, those of trees
+// that do not use SHA-256 (see SetHashSuite) EnvelopeHashSuiteLen bytes, and
+// those of trees with another Winternitz parameter (see SetWinternitz)
+// EnvelopeWinternitzLen bytes plus the difference in signature length. If the
+// tree has a registered parameter set (see ParamSet), EnvelopeParamSetLen bytes
+// replace both the hash suite and Winternitz fields. A commitment (see
+// SignCommitted) adds 5 bytes plus its length, and a message randomizer (see
+// SignMessage) EnvelopeRandomizerLen bytes.
+func EnvelopeLen(branches int, withParams bool) int {
+	// Version byte and the field holding the signature
+	length := 1 + 5 + SignatureLen(branches)
+	if withParams {
+		length += 5 + 4 + 5 + 1 + 5 + 1
+	}
+
+	return length
+}
+
+// Returns the length of a serialised tree holding the given amount of nodes,
+// without its extended header and node fields other than the public key hash.
+// This is the exact length of legacy trees without position addressing that
+// only use the basic settings, and a lower bound for all others.
+// Use NYTree.EncodedLen for the exact length of a tree.
+func StateLen(nodes int) int {
+	return stateHeaderLen + nodes*(4+nodeByteLen+pkhFieldLen) + 32
+}
+
+// Returns the largest amount of signatures a tree whose nodes have the given
+// amount of children can create if its nodes are at most depth levels below
+// the root, see SetMaxDepth: every node signs once, including the root and the
+// nodes at the maximum depth, which do not create children. The result
+// saturates at math.MaxUint64.
+func MaxSignatures(depth uint32, branches int) uint64 {
+	switch {
+	case branches <= 0:
+		return 1
+	case branches == 1:
+		return uint64(depth) + 1
+	}
+
+	// Every level holds branches times the nodes of the one above it, so the
+	// sum saturates after at most 64 levels
+	var total uint64
+	level := uint64(1)
+	for i := uint64(0); i <= uint64(depth); i++ {
+		if total > math.MaxUint64-level {
+			return math.MaxUint64
+		}
+		total += level
+		if level > math.MaxUint64/uint64(branches) {
+			level = math.MaxUint64
+		} else {
+			level *= uint64(branches)
+		}
+	}
+
+	return total
+}
+
+// Returns the length of t.Bytes().
+func (t *NYTree) EncodedLen() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	length := StateLen(len(t.nodes))
+
+	if header := t.headerFields(); len(header) > 0 {
+		length += 4 + len(header)
+	}
+
+	for _, node := range t.nodes {
+		length += len(t.nodeFrame(node)) - nodeByteLen - pkhFieldLen
+	}
+
+	return length
+}
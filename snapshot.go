@@ -0,0 +1,79 @@
+package xnyss
+
+// An immutable view of the nodes of a tree at one point in time, see
+// NYTree.Snapshot. Long-running scans, e.g. of a dashboard, iterate a snapshot
+// instead of the tree, so they neither block nor race with signing. A snapshot
+// holds no seeds, and is safe for concurrent use.
+type Snapshot struct {
+	epoch     uint64
+	nodes     []NodeInfo
+	consumed  [][]byte
+	available int
+}
+
+// Returns a snapshot of the nodes of the tree t. The tree is only locked while
+// the descriptions of its nodes are copied; later changes of t do not affect
+// the snapshot.
+func (t *NYTree) Snapshot() *Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &Snapshot{
+		epoch:     t.epoch,
+		nodes:     t.nodeInfos(),
+		consumed:  t.consumedHashes(),
+		available: t.available(nil),
+	}
+}
+
+// Returns the epoch of the tree when the snapshot was taken, see
+// NYTree.Epoch.
+func (s *Snapshot) Epoch() uint64 {
+	return s.epoch
+}
+
+// Returns the amount of nodes in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.nodes)
+}
+
+// Returns the description of the node with index i, in the order of
+// NYTree.Nodes.
+func (s *Snapshot) Node(i int) NodeInfo {
+	return copyNodeInfo(s.nodes[i])
+}
+
+// Calls fn for every node of the snapshot in order, until it returns false.
+func (s *Snapshot) ForEach(fn func(NodeInfo) bool) {
+	for _, node := range s.nodes {
+		if !fn(copyNodeInfo(node)) {
+			return
+		}
+	}
+}
+
+// Returns the public key hashes of the nodes consumed when the snapshot was
+// taken, see NYTree.Consumed.
+func (s *Snapshot) Consumed() [][]byte {
+	consumed := make([][]byte, len(s.consumed))
+	for i, pkh := range s.consumed {
+		consumed[i] = append([]byte(nil), pkh...)
+	}
+
+	return consumed
+}
+
+// Returns the amount of nodes that could sign without a matching txid when the
+// snapshot was taken, see NYTree.Available.
+func (s *Snapshot) Available() int {
+	return s.available
+}
+
+// Returns a copy of the node description n, so callers can not change the
+// snapshot it belongs to.
+func copyNodeInfo(n NodeInfo) NodeInfo {
+	n.PubKeyHash = append([]byte(nil), n.PubKeyHash...)
+	n.Txid = append([]byte(nil), n.Txid...)
+
+	return n
+}
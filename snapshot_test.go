@@ -0,0 +1,67 @@
+package xnyss
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestNYTree_Snapshot(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("snapshot test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 1 - The snapshot describes the nodes of the tree
+	s := tree.Snapshot()
+	if s.Len() != len(sig.ChildHashes) || s.Available() != len(sig.ChildHashes) || len(s.Consumed()) != 1 || s.Epoch() != tree.Epoch() {
+		t.Fatal("Invalid snapshot")
+	}
+	nodes := tree.Nodes()
+	for i := range nodes {
+		if !bytes.Equal(s.Node(i).PubKeyHash, nodes[i].PubKeyHash) {
+			t.Fatal("Snapshot node", i, "does not match")
+		}
+	}
+
+	// 2 - Scans of the snapshot are not affected by signing
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			n := 0
+			s.ForEach(func(info NodeInfo) bool {
+				n++
+				return true
+			})
+			if n != len(nodes) {
+				t.Error("Snapshot changed during scan")
+				return
+			}
+		}
+	}()
+	for range sig.ChildHashes {
+		if _, _, err := signMessage("snapshot test", tree); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+	if s.Available() != len(sig.ChildHashes) || len(s.Consumed()) != 1 || s.Epoch() == tree.Epoch() {
+		t.Fatal("Snapshot changed")
+	}
+
+	// 3 - Changes to returned descriptions do not change the snapshot
+	s.Node(0).PubKeyHash[0] ^= 1
+	if !bytes.Equal(s.Node(0).PubKeyHash, nodes[0].PubKeyHash) {
+		t.Fatal("Snapshot changed through returned description")
+	}
+}
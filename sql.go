@@ -0,0 +1,85 @@
+package xnyss
+
+import "database/sql/driver"
+
+var (
+	ErrScanType = newError(ErrEncoding, "unsupported type for scanning, expected []byte")
+)
+
+// Implements driver.Valuer, storing the signature with its message and
+// parameters in the format of GobEncode, so it fits a BLOB column.
+func (sig *Signature) Value() (driver.Value, error) {
+	return sig.GobEncode()
+}
+
+// Implements sql.Scanner for values stored by Value.
+func (sig *Signature) Scan(src interface{}) error {
+	b, err := scanBytes(src)
+	if err != nil {
+		return err
+	}
+
+	return sig.GobDecode(b)
+}
+
+// Wraps a tree so its serialised state can be stored in, and scanned from, a
+// BLOB column. A NULL value scans into a TreeState without a tree, and a
+// TreeState without a tree is stored as NULL.
+type TreeState struct {
+	Tree *NYTree
+	// The codec the state is stored in, or nil for the binary encoding of
+	// Bytes. Values must be scanned using the codec they were stored with.
+	Codec Codec
+}
+
+// Implements driver.Valuer.
+func (s TreeState) Value() (driver.Value, error) {
+	if s.Tree == nil {
+		return nil, nil
+	}
+	if s.Codec != nil {
+		return s.Codec.EncodeTree(s.Tree)
+	}
+	if err := injectPersistFault(); err != nil {
+		return nil, err
+	}
+
+	return s.Tree.Bytes(), nil
+}
+
+// Implements sql.Scanner.
+func (s *TreeState) Scan(src interface{}) error {
+	if src == nil {
+		s.Tree = nil
+		return nil
+	}
+
+	b, err := scanBytes(src)
+	if err != nil {
+		return err
+	}
+
+	var tree *NYTree
+	if s.Codec != nil {
+		tree, err = s.Codec.DecodeTree(b)
+	} else {
+		tree, err = Load(b)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.Tree = tree
+	return nil
+}
+
+func scanBytes(src interface{}) ([]byte, error) {
+	switch v := src.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+
+	return nil, ErrScanType
+}
@@ -0,0 +1,56 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestSQL(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// 1 - Signatures
+	value, err := sig.Value()
+	if err != nil {
+		t.Fatal("Failed to create signature value -", err)
+	}
+	scanned := &Signature{}
+	if err := scanned.Scan(value); err != nil {
+		t.Fatal("Failed to scan signature -", err)
+	}
+	if !bytes.Equal(scanned.Bytes(), sig.Bytes()) || !bytes.Equal(scanned.Message, sig.Message) {
+		t.Fatal("Scanned signature differs")
+	}
+	if err := scanned.Scan(42); err != ErrScanType {
+		t.Fatal("Scanned invalid type, err was", err)
+	}
+
+	// 2 - Tree states, including NULL
+	value, err = TreeState{Tree: tree}.Value()
+	if err != nil {
+		t.Fatal("Failed to create tree value -", err)
+	}
+	state := &TreeState{}
+	if err := state.Scan(value); err != nil {
+		t.Fatal("Failed to scan tree -", err)
+	}
+	if !bytes.Equal(state.Tree.Bytes(), tree.Bytes()) {
+		t.Fatal("Scanned tree differs")
+	}
+
+	if err := state.Scan(nil); err != nil || state.Tree != nil {
+		t.Fatal("Failed to scan NULL -", err)
+	}
+	if value, err := state.Value(); value != nil || err != nil {
+		t.Fatal("Empty tree state is not stored as NULL")
+	}
+}
@@ -0,0 +1,135 @@
+package xnyss
+
+import (
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Import path of the module, and the build tag of experimental packages.
+const (
+	modulePath      = "github.com/Re0h/xnyss"
+	experimentalTag = "xnyssexp"
+)
+
+// Returns whether the file with build constraint expr is only built with the
+// experimental build tag.
+func experimentalFile(expr constraint.Expr) bool {
+	if expr == nil {
+		return false
+	}
+
+	with := expr.Eval(func(string) bool { return true })
+	without := expr.Eval(func(tag string) bool { return tag != experimentalTag })
+
+	return with && !without
+}
+
+// The Go files of a package of the module, and its imports of other packages
+// of the module.
+type modulePackage struct {
+	files        int
+	experimental int
+	imports      map[string]string
+}
+
+// Parses the imports and build constraints of the packages of the module,
+// keyed by import path.
+func modulePackages(t *testing.T) map[string]*modulePackage {
+	pkgs := make(map[string]*modulePackage)
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(".", func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != "." && (d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+
+		f, err := parser.ParseFile(fset, p, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		var expr constraint.Expr
+		for _, group := range f.Comments {
+			if group.Pos() > f.Package {
+				break
+			}
+			for _, c := range group.List {
+				if constraint.IsGoBuild(c.Text) {
+					if expr, err = constraint.Parse(c.Text); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		importPath := path.Join(modulePath, filepath.ToSlash(filepath.Dir(p)))
+		pkg, ok := pkgs[importPath]
+		if !ok {
+			pkg = &modulePackage{imports: make(map[string]string)}
+			pkgs[importPath] = pkg
+		}
+		pkg.files++
+		if experimentalFile(expr) {
+			pkg.experimental++
+		}
+		for _, spec := range f.Imports {
+			imp, _ := strconv.Unquote(spec.Path.Value)
+			if imp == modulePath || strings.HasPrefix(imp, modulePath+"/") {
+				pkg.imports[imp] = p
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pkgs
+}
+
+func TestStability(t *testing.T) {
+	pkgs := modulePackages(t)
+	if pkgs[modulePath] == nil || pkgs[modulePath+"/signd"] == nil {
+		t.Fatal("Packages of the module not found")
+	}
+
+	// 1 - Packages are either experimental or stable as a whole, and the root
+	// package is stable
+	for importPath, pkg := range pkgs {
+		if pkg.experimental != 0 && pkg.experimental != pkg.files {
+			t.Fatal("Package", importPath, "mixes stable and experimental files")
+		}
+	}
+	if pkgs[modulePath].experimental != 0 {
+		t.Fatal("Root package is experimental")
+	}
+	if pkgs[modulePath+"/signd"].experimental == 0 {
+		t.Fatal("Package signd is not experimental")
+	}
+
+	// 2 - Stable packages, and their tests, never import experimental ones
+	for importPath, pkg := range pkgs {
+		if pkg.experimental != 0 {
+			continue
+		}
+		for imp, file := range pkg.imports {
+			if dep, ok := pkgs[imp]; ok && dep.experimental != 0 {
+				t.Fatal("Stable package", importPath, "imports experimental package", imp, "in", file)
+			}
+		}
+	}
+}
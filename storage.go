@@ -0,0 +1,448 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrStorageEmpty   = newError(ErrState, "storage holds no tree state")
+	ErrInvalidStorage = newError(ErrEncoding, "invalid stored tree state")
+)
+
+// A backend persisting the state of a tree incrementally, see SetStorage. The
+// state is stored as a header, holding the seeds and the fields of the tree
+// itself, and one record per node, keyed by the public key hash of the node.
+//
+// Every Put and Delete must be durable once it returns. The slices returned by
+// Header and passed to the function of ForEachNode are owned by the caller.
+// A storage must only be used by one tree at a time.
+type Storage interface {
+	// Returns the stored header, or nil if no header was stored.
+	Header() ([]byte, error)
+	// Replaces the stored header.
+	PutHeader(header []byte) error
+	// Stores the record of the node with public key hash pkh, replacing the
+	// record stored before.
+	PutNode(pkh, node []byte) error
+	// Removes the record of the node with public key hash pkh. Removing a node
+	// that is not stored is not an error.
+	DeleteNode(pkh []byte) error
+	// Calls f for every stored node record, in any order. Stops at, and
+	// returns, the first error returned by f.
+	ForEachNode(f func(pkh, node []byte) error) error
+}
+
+// Enables write-through mode: the state of the tree t is written to s, and from
+// then on every signature and confirmation is recorded in s as a delta, holding
+// only the header and the nodes that were added, changed or removed. A
+// signature is only returned once its changes were written, so a crash never
+// loses the fact that a node signed. Backup, MarkConsumed and Prune write their
+// changes as well; changes made by other methods are written with the next
+// signature or confirmation, or by Sync.
+//
+// If writing fails, the operation returns the error (Confirm, MarkConsumed and
+// Prune log it instead), and the tree keeps the changes in memory and writes
+// them with the next change. Removed nodes are deleted first, then the header
+// is written, then new and changed nodes, so a crash halfway may lose nodes
+// but never brings back a node that signed. Passing nil disables write-through
+// mode.
+func (t *NYTree) SetStorage(s Storage) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return ErrTreeWiped
+	}
+
+	t.storage, t.stored, t.storedHeader = nil, nil, [32]byte{}
+	if s == nil {
+		return nil
+	}
+
+	// Records that are not known to match the tree are marked by a zero
+	// digest, so they are replaced or deleted
+	stored := make(map[[32]byte][32]byte)
+	err := s.ForEachNode(func(pkh, _ []byte) error {
+		var key [32]byte
+		copy(key[:], pkh)
+		stored[key] = [32]byte{}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	t.storage, t.stored = s, stored
+
+	return t.writeThrough()
+}
+
+// Returns the storage of the tree t, or nil if it is not in write-through mode.
+func (t *NYTree) Storage() Storage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.storage
+}
+
+// Writes all changes of the tree t that were not written to its storage yet,
+// see SetStorage. Does nothing if t is not in write-through mode.
+func (t *NYTree) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.writeThrough()
+}
+
+// Writes the changes of the state of t since the previous write to its
+// storage, if it has one, see SetStorage.
+func (t *NYTree) writeThrough() error {
+	if t.storage == nil || t.wiped {
+		return nil
+	}
+	if err := injectPersistFault(); err != nil {
+		return err
+	}
+
+	frames := make(map[[32]byte][]byte, len(t.nodes))
+	for _, node := range t.nodes {
+		var pkh [32]byte
+		copy(pkh[:], node.pubKeyHash())
+		frames[pkh] = t.nodeFrame(node)
+	}
+
+	for pkh := range t.stored {
+		if _, ok := frames[pkh]; ok {
+			continue
+		}
+		if err := t.storage.DeleteNode(pkh[:]); err != nil {
+			return err
+		}
+		delete(t.stored, pkh)
+	}
+
+	header := t.storageHeader()
+	if digest := sha256.Sum256(header); digest != t.storedHeader {
+		if err := t.storage.PutHeader(header); err != nil {
+			return err
+		}
+		t.storedHeader = digest
+	}
+
+	for pkh, frame := range frames {
+		digest := sha256.Sum256(frame)
+		if stored, ok := t.stored[pkh]; ok && stored == digest {
+			continue
+		}
+		if err := t.storage.PutNode(pkh[:], frame); err != nil {
+			return err
+		}
+		t.stored[pkh] = digest
+	}
+
+	return nil
+}
+
+// Writes the changes of t to its storage, logging the error if that fails.
+// Used by operations that can not return an error.
+func (t *NYTree) tryWriteThrough() {
+	if err := t.writeThrough(); err != nil {
+		t.log(LevelAudit, "storage write failed", "err", err.Error())
+	}
+}
+
+// Returns the header of the tree t as stored in its storage: everything of its
+// serialised state (see Bytes) that precedes the nodes.
+func (t *NYTree) storageHeader() []byte {
+	buf := &bytes.Buffer{}
+	t.writeHead(&stateWriter{w: buf, h: sha256.New()})
+
+	return buf.Bytes()
+}
+
+// Loads the tree stored in s, and enables write-through mode on it, see
+// SetStorage. Returns ErrStorageEmpty if s holds no header, and
+// ErrInvalidStorage if a node is not stored under its public key hash.
+//
+// If SelfTestOnLoad is set, returns ErrSelfTest if the self-test fails.
+func LoadStorage(s Storage) (*NYTree, error) {
+	if SelfTestOnLoad {
+		if err := selfTestOnce(); err != nil {
+			return nil, err
+		}
+	}
+
+	header, err := s.Header()
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, ErrStorageEmpty
+	}
+	if len(header) < stateHeaderLen || !bytes.HasPrefix(header, []byte(stateMagic)) {
+		return nil, ErrInvalidStorage
+	}
+
+	tree, flags, err := loadHead(header[:stateHeaderLen], 0)
+	if err != nil {
+		return nil, err
+	}
+	offset := stateHeaderLen
+	if flags&flagExtended != 0 {
+		fields, n, err := readFrame(header[offset:])
+		if err != nil {
+			return nil, err
+		}
+		if err := tree.loadHeaderFields(fields); err != nil {
+			return nil, err
+		}
+		offset += n
+	}
+	if offset != len(header) {
+		return nil, ErrInvalidStorage
+	}
+
+	stored := make(map[[32]byte][32]byte)
+	err = s.ForEachNode(func(pkh, frame []byte) error {
+		node, err := tree.loadFrame(frame)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(node.pkh, pkh) {
+			return ErrInvalidStorage
+		}
+
+		var key [32]byte
+		copy(key[:], pkh)
+		stored[key] = sha256.Sum256(frame)
+		tree.nodes = append(tree.nodes, node)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keep the order of loaded states, see Bytes
+	sort.Slice(tree.nodes, func(i, j int) bool {
+		return bytes.Compare(tree.nodes[i].pkh, tree.nodes[j].pkh) < 0
+	})
+
+	tree.loadKeyParams()
+	if err := tree.expandNodes(); err != nil {
+		return nil, err
+	}
+	if err := tree.checkSpent(); err != nil {
+		return nil, err
+	}
+
+	tree.storage, tree.stored, tree.storedHeader = s, stored, sha256.Sum256(header)
+
+	return tree, nil
+}
+
+// A Storage keeping the state in memory, e.g. for tests, or as a staging area
+// that is copied to another backend.
+type MemStorage struct {
+	mu     sync.Mutex
+	header []byte
+	nodes  map[[32]byte][]byte
+}
+
+// Creates an empty in-memory storage.
+func NewMemStorage() *MemStorage {
+	return &MemStorage{nodes: make(map[[32]byte][]byte)}
+}
+
+func (m *MemStorage) Header() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.header == nil {
+		return nil, nil
+	}
+
+	return append([]byte(nil), m.header...), nil
+}
+
+func (m *MemStorage) PutHeader(header []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.header = append([]byte(nil), header...)
+
+	return nil
+}
+
+func (m *MemStorage) PutNode(pkh, node []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], pkh)
+	m.nodes[key] = append([]byte(nil), node...)
+
+	return nil
+}
+
+func (m *MemStorage) DeleteNode(pkh []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var key [32]byte
+	copy(key[:], pkh)
+	delete(m.nodes, key)
+
+	return nil
+}
+
+func (m *MemStorage) ForEachNode(f func(pkh, node []byte) error) error {
+	m.mu.Lock()
+	records := make(map[[32]byte][]byte, len(m.nodes))
+	for pkh, node := range m.nodes {
+		records[pkh] = append([]byte(nil), node...)
+	}
+	m.mu.Unlock()
+
+	for pkh, node := range records {
+		if err := f(append([]byte(nil), pkh[:]...), node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// File names used by FileStorage.
+const (
+	storageHeaderFile = "header"
+	storageNodeSuffix = ".node"
+	storageTempPrefix = ".tmp-"
+)
+
+// A Storage keeping the state in a directory: the header in a file named
+// "header", and every node in a file named after the hex encoded public key
+// hash of the node. Files are replaced atomically by writing a temporary file
+// and renaming it, and the directory is synced after every change.
+type FileStorage struct {
+	dir string
+}
+
+// Creates a storage in the directory dir, creating the directory if it does
+// not exist. Temporary files left behind by an interrupted write are removed.
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), storageTempPrefix) {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &FileStorage{dir: dir}, nil
+}
+
+func (fs *FileStorage) Header() ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(fs.dir, storageHeaderFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return b, err
+}
+
+func (fs *FileStorage) PutHeader(header []byte) error {
+	return fs.write(storageHeaderFile, header)
+}
+
+func (fs *FileStorage) PutNode(pkh, node []byte) error {
+	return fs.write(hex.EncodeToString(pkh)+storageNodeSuffix, node)
+}
+
+func (fs *FileStorage) DeleteNode(pkh []byte) error {
+	err := os.Remove(filepath.Join(fs.dir, hex.EncodeToString(pkh)+storageNodeSuffix))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return fs.syncDir()
+}
+
+func (fs *FileStorage) ForEachNode(f func(pkh, node []byte) error) error {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), storageNodeSuffix)
+		if !ok || strings.HasPrefix(name, storageTempPrefix) {
+			continue
+		}
+		pkh, err := hex.DecodeString(name)
+		if err != nil || len(pkh) != 32 {
+			return ErrInvalidStorage
+		}
+
+		node, err := os.ReadFile(filepath.Join(fs.dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := f(pkh, node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Atomically replaces the file name in the directory of fs by b.
+func (fs *FileStorage) write(name string, b []byte) error {
+	tmp, err := os.CreateTemp(fs.dir, storageTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(fs.dir, name)); err != nil {
+		return err
+	}
+
+	return fs.syncDir()
+}
+
+func (fs *FileStorage) syncDir() error {
+	d, err := os.Open(fs.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
@@ -0,0 +1,112 @@
+package xnyss
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestNYTree_SetStorage(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	storage := NewMemStorage()
+	if err := tree.SetStorage(storage); err != nil {
+		t.Fatal("Failed to set storage -", err)
+	}
+
+	// 1 - Signatures and confirmations are written through
+	for i := 0; i < 3; i++ {
+		sig, _, err := signMessage("storage test", tree)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	}
+	loaded, err := LoadStorage(storage)
+	if err != nil {
+		t.Fatal("Failed to load storage -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Stored state differs from the tree")
+	}
+
+	// 2 - Nodes that signed are deleted from the storage
+	stored := 0
+	storage.ForEachNode(func(pkh, _ []byte) error {
+		if containsHash(tree.Consumed(), pkh) {
+			t.Fatal("Storage holds a consumed node")
+		}
+		stored++
+		return nil
+	})
+	if stored != len(tree.nodes) {
+		t.Fatal("Storage holds", stored, "nodes, tree has", len(tree.nodes))
+	}
+
+	// 3 - Signatures are not returned if their changes can not be written
+	failed := errors.New("storage failed")
+	restore := InjectFaults(&FaultInjection{Persist: func() error { return failed }})
+	_, _, err = signMessage("storage test", tree)
+	restore()
+	if err != failed {
+		t.Fatal("Signed although storage failed, err was", err)
+	}
+	if err := tree.Sync(); err != nil {
+		t.Fatal("Failed to sync -", err)
+	}
+	loaded, err = LoadStorage(storage)
+	if err != nil || !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Stored state differs from the tree after sync -", err)
+	}
+
+	// 4 - Storages holding no tree are detected
+	if _, err := LoadStorage(NewMemStorage()); err != ErrStorageEmpty {
+		t.Fatal("Loaded empty storage, err was", err)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatal("Failed to create storage -", err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetStorage(storage); err != nil {
+		t.Fatal("Failed to set storage -", err)
+	}
+	sig, _, err := signMessage("file storage test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[1], ConfirmsRequired)
+
+	// 1 - The tree is loaded from the directory
+	storage, err = NewFileStorage(dir)
+	if err != nil {
+		t.Fatal("Failed to open storage -", err)
+	}
+	loaded, err := LoadStorage(storage)
+	if err != nil {
+		t.Fatal("Failed to load storage -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), tree.Bytes()) {
+		t.Fatal("Stored state differs from the tree")
+	}
+
+	// 2 - The loaded tree keeps writing through
+	if _, _, err := signMessage("file storage test", loaded); err != nil {
+		t.Fatal("Failed to sign with loaded tree -", err)
+	}
+	reloaded, err := LoadStorage(storage)
+	if err != nil || !bytes.Equal(reloaded.Bytes(), loaded.Bytes()) {
+		t.Fatal("Stored state differs from the loaded tree -", err)
+	}
+}
@@ -0,0 +1,267 @@
+package xnyss
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// Implements io.WriterTo, writing the encoding of Bytes to w one node at a
+// time, so the state of large trees is not held in memory twice.
+func (t *NYTree) WriteTo(w io.Writer) (n int64, err error) {
+	if err := injectPersistFault(); err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.writeTo(w)
+}
+
+func (t *NYTree) writeTo(w io.Writer) (int64, error) {
+	if t.wiped {
+		return 0, ErrTreeWiped
+	}
+
+	sw := &stateWriter{w: w, h: sha256.New()}
+	t.writeBody(sw)
+	sw.Write(sw.h.Sum(nil))
+
+	return sw.n, sw.err
+}
+
+// Writes the state of t without its checksum to sw.
+func (t *NYTree) writeBody(sw *stateWriter) {
+	t.writeHead(sw)
+	for _, node := range t.canonicalNodes() {
+		sw.frame(t.nodeFrame(node))
+	}
+}
+
+// Writes everything of the state of t that precedes the nodes to sw: the magic
+// bytes, version, flags, seeds and extended header.
+func (t *NYTree) writeHead(sw *stateWriter) {
+	header := t.headerFields()
+
+	var flags byte
+	if t.ots {
+		flags |= flagOTS
+	}
+	if len(header) > 0 {
+		flags |= flagExtended
+	}
+	if t.compact {
+		flags |= flagCompact
+	}
+	sw.Write([]byte(stateMagic))
+	sw.Write([]byte{stateVersion, flags})
+
+	rootSeed, done := unmask(t.rootSeed, t.rootMask)
+	sw.Write(rootSeed)
+	done()
+	sw.Write(t.rootPubSeed)
+
+	if len(header) > 0 {
+		sw.frame(header)
+	}
+}
+
+// Implements io.ReaderFrom, replacing the state of t with the tree read from
+// r, which is read until EOF. Only one node is decoded at a time. The state is
+// only replaced if it was read completely and its checksum matches. States in
+// the legacy format are accepted as well, and are read as a whole.
+func (t *NYTree) ReadFrom(r io.Reader) (n int64, err error) {
+	cr := &countingReader{r: r}
+	br := bufio.NewReader(cr)
+
+	var tree *NYTree
+	if magic, _ := br.Peek(len(stateMagic)); string(magic) == stateMagic {
+		tree, err = readState(br)
+	} else {
+		var b []byte
+		if b, err = io.ReadAll(br); err == nil {
+			// Nothing else holds b, so the tree may alias it
+			tree, err = loadLegacy(b, true)
+		}
+	}
+	if err != nil {
+		return cr.n, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state = tree.state
+	return cr.n, nil
+}
+
+// Reads a tree in the current format from br, see Bytes.
+func readState(br *bufio.Reader) (*NYTree, error) {
+	h := sha256.New()
+	head := make([]byte, stateHeaderLen)
+	if _, err := io.ReadFull(br, head); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	h.Write(head)
+
+	tree, flags, err := loadHead(head, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var header []byte
+	if flags&flagExtended != 0 {
+		if header, err = readStreamFrame(br, h); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		// Nodes are framed and longer than the checksum, so the state ends if
+		// exactly the checksum is left.
+		next, err := br.Peek(sha256.Size + 1)
+		if len(next) == sha256.Size && err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		frame, err := readStreamFrame(br, h)
+		if err != nil {
+			return nil, err
+		}
+		node, err := tree.loadFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		tree.nodes = append(tree.nodes, node)
+	}
+
+	checksum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(br, checksum); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if !bytes.Equal(checksum, h.Sum(nil)) {
+		return nil, ErrTreeChecksum
+	}
+
+	// The header fields are only trusted once the checksum is verified
+	if header != nil {
+		if err := tree.loadHeaderFields(header); err != nil {
+			return nil, err
+		}
+	}
+	tree.loadKeyParams()
+	if err := tree.expandNodes(); err != nil {
+		return nil, err
+	}
+	if err := tree.checkSpent(); err != nil {
+		return nil, err
+	}
+	if err := tree.validateState(); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// Reads a frame written by stateWriter.frame from br, adding it to h. The frame is
+// read into a growing buffer, so a corrupted length does not cause a large
+// allocation up front.
+func readStreamFrame(br *bufio.Reader, h hash.Hash) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(br, length[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	h.Write(length[:])
+
+	frame := &bytes.Buffer{}
+	size := int64(binary.BigEndian.Uint32(length[:]))
+	if _, err := io.CopyN(frame, br, size); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	h.Write(frame.Bytes())
+
+	return frame.Bytes(), nil
+}
+
+// Returns ErrTreeInvalidInput for truncated states, and other errors of the
+// reader as is.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTreeInvalidInput
+	}
+
+	return err
+}
+
+// Implements encoding.BinaryMarshaler, see Bytes.
+func (t *NYTree) MarshalBinary() ([]byte, error) {
+	if err := injectPersistFault(); err != nil {
+		return nil, err
+	}
+
+	return t.Bytes(), nil
+}
+
+// Implements encoding.BinaryUnmarshaler, see Load.
+func (t *NYTree) UnmarshalBinary(b []byte) error {
+	loaded, err := Load(b)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state = loaded.state
+	return nil
+}
+
+// Writes to w and to the checksum h, remembering the first error.
+type stateWriter struct {
+	w   io.Writer
+	h   hash.Hash
+	n   int64
+	err error
+}
+
+func (sw *stateWriter) Write(b []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	n, err := sw.w.Write(b)
+	sw.h.Write(b[:n])
+	sw.n += int64(n)
+	sw.err = err
+
+	return n, err
+}
+
+// Writes b framed as uint32(len(b)) || b.
+func (sw *stateWriter) frame(b []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+
+	sw.Write(length[:])
+	sw.Write(b)
+}
+
+// Counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+
+	return n, err
+}
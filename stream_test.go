@@ -0,0 +1,129 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"testing/iotest"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+// Fails after writing limit bytes.
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if len(b) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("disk full")
+	}
+
+	w.limit -= len(b)
+	return len(b), nil
+}
+
+func TestNYTree_WriteTo(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.SetLabel(sig.ChildHashes[0], "label")
+	state := tree.Bytes()
+
+	// 1 - The stream holds the encoding of Bytes
+	buf := &bytes.Buffer{}
+	n, err := tree.WriteTo(buf)
+	if err != nil || n != int64(len(state)) || !bytes.Equal(buf.Bytes(), state) {
+		t.Fatal("Streamed state differs from Bytes, err was", err)
+	}
+
+	// 2 - Errors of the writer are returned
+	if n, err := tree.WriteTo(&limitedWriter{limit: 100}); err == nil || n != 100 {
+		t.Fatal("Write error was not returned, wrote", n, "bytes")
+	}
+
+	// 3 - Reading the stream, one byte at a time, restores the tree
+	loaded := &NYTree{}
+	n, err = loaded.ReadFrom(iotest.OneByteReader(bytes.NewReader(state)))
+	if err != nil || n != int64(len(state)) {
+		t.Fatal("Failed to read state -", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), state) {
+		t.Fatal("Read tree differs")
+	}
+}
+
+func TestNYTree_ReadFrom(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	state := tree.Bytes()
+
+	// 1 - Corrupted and truncated streams do not replace the tree
+	loaded := New(seed, pubSeed, false)
+	fresh := loaded.Bytes()
+
+	corrupted := append([]byte(nil), state...)
+	corrupted[stateHeaderLen+10] ^= 0x01
+	if _, err := loaded.ReadFrom(bytes.NewReader(corrupted)); err != ErrTreeChecksum {
+		t.Fatal("Read corrupted state, err was", err)
+	}
+	if _, err := loaded.ReadFrom(bytes.NewReader(state[:len(state)-40])); err != ErrTreeInvalidInput {
+		t.Fatal("Read truncated state, err was", err)
+	}
+
+	// States that Load refuses are refused as well
+	tree.nodes = append(tree.nodes, tree.nodes[0])
+	duplicate := tree.Bytes()
+	tree.nodes = tree.nodes[:len(tree.nodes)-1]
+	var stateErr *StateError
+	if _, err := loaded.ReadFrom(bytes.NewReader(duplicate)); !errors.As(err, &stateErr) {
+		t.Fatal("Read state holding a node twice, err was", err)
+	}
+	if !bytes.Equal(loaded.Bytes(), fresh) {
+		t.Fatal("Tree was replaced by an invalid state")
+	}
+
+	// 2 - Legacy states are read as well
+	legacy := make([]byte, 65+97)
+	if _, err := rand.Read(legacy); err != nil {
+		t.Fatal(err)
+	}
+	legacy[0] = 0x00
+	if _, err := loaded.ReadFrom(bytes.NewReader(legacy)); err != nil {
+		t.Fatal("Failed to read legacy state -", err)
+	}
+	if len(loaded.nodes) != 1 || !bytes.Equal(loaded.rootPubSeed, legacy[33:65]) {
+		t.Fatal("Read legacy state incorrectly")
+	}
+
+	// 3 - Binary marshalling uses the same encoding
+	b, err := tree.MarshalBinary()
+	if err != nil || !bytes.Equal(b, state) {
+		t.Fatal("Marshalled state differs from Bytes, err was", err)
+	}
+	unmarshalled := &NYTree{}
+	if err := unmarshalled.UnmarshalBinary(b); err != nil {
+		t.Fatal("Failed to unmarshal -", err)
+	}
+	for i := range b {
+		b[i] ^= 0xff
+	}
+	if !bytes.Equal(unmarshalled.Bytes(), state) {
+		t.Fatal("Unmarshalled tree differs, or aliases its input")
+	}
+}
@@ -0,0 +1,498 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+var (
+	ErrSyncSameInstance = newError(ErrState, "states of the same tree instance can not be synchronised, see Fork")
+	ErrSyncReuse        = newError(ErrCrypto, "node was used by both synchronised devices")
+	ErrInvalidSync      = newError(ErrEncoding, "invalid synchronisation message encoding")
+)
+
+// Version bytes of encoded synchronisation summaries and deltas.
+const (
+	syncSummaryVersion = 0x01
+	syncDeltaVersion   = 0x01
+)
+
+// Tags of the fields of encoded synchronisation summaries and deltas. Node
+// fields hold pkh || confirms, use fields pkh || owner, and added fields the
+// node frame of a state that is not compact.
+const (
+	syncFieldTree        = 0x01
+	syncFieldOwner       = 0x02
+	syncFieldFingerprint = 0x03
+	syncFieldNode        = 0x04
+	syncFieldUse         = 0x05
+	syncFieldAdded       = 0x06
+)
+
+// The use of a node by a tree instance, see SyncDelta. By is zero if the
+// instance that used the node is not known, e.g. because the node was marked
+// consumed using MarkConsumed.
+type SyncUse struct {
+	PubKeyHash []byte
+	By         OwnerID
+}
+
+// The confirmation count of a node, see SyncSummary.
+type SyncConfirms struct {
+	PubKeyHash []byte
+	Confirms   uint8
+}
+
+// Describes the state of a device for synchronisation, see NYTree.Diff. It
+// holds no seeds.
+type SyncSummary struct {
+	// H(rootPubSeed) of the tree, so states of different trees are not mixed
+	Tree        [32]byte
+	Owner       OwnerID
+	Fingerprint [32]byte
+	// The nodes of the device, sorted
+	Nodes []SyncConfirms
+	// The nodes consumed by the device, sorted
+	Consumed []SyncUse
+}
+
+// The changes of a device that another device does not know of yet, see
+// NYTree.Diff. Added nodes include their seeds, so deltas must only be sent
+// over channels that keep them secret, like the state of the tree.
+type SyncDelta struct {
+	Tree [32]byte
+	From OwnerID
+	// Nodes consumed by the sender, unless the receiver knew of their use
+	Used []SyncUse
+	// Node frames of the nodes of the sender that the receiver neither holds
+	// nor consumed
+	Added [][]byte
+	// Confirmation counts of the nodes of the sender that are higher than
+	// those of the receiver
+	Confirmed []SyncConfirms
+}
+
+// The outcome of NYTree.Apply.
+type SyncReport struct {
+	Used      int
+	Added     int
+	Confirmed int
+	// Public key hashes of the nodes used by both devices. Signatures of such
+	// nodes reveal more of their one-time keys than is safe, so the
+	// application must treat them as compromised.
+	Conflicts [][]byte
+}
+
+// Creates a copy of the tree t holding all of its nodes, for a second device
+// that signs with the same long-term key. Unlike Backup, both trees keep all
+// nodes, so the devices must synchronise (see Diff and Apply) before using
+// nodes the other device may have used. The copy is a separate instance of
+// the tree (see Owner), so nodes used by both devices are detected.
+func (t *NYTree) Fork() (*NYTree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := t.writeTo(buf); err != nil {
+		return nil, err
+	}
+	// The fork takes over the buffer, so Wipe covers its copy of the seeds
+	fork, err := LoadOwned(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if err := t.assignOwners(fork); err != nil {
+		fork.Wipe()
+		return nil, err
+	}
+	fork.backupInfo = nil
+	// Only the original can revoke the key, see EnableRevocation
+	fork.revocable, fork.revocationNode, fork.revocationLink = false, nil, nil
+	t.epoch++
+	t.log(LevelAudit, "tree forked", "owner", fork.owner.String())
+
+	return fork, t.writeThrough()
+}
+
+// Returns the summary of the state of t that another device passes to Diff.
+func (t *NYTree) Summary() *SyncSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &SyncSummary{Tree: sha256.Sum256(t.rootPubSeed), Owner: t.owner, Fingerprint: t.fingerprint()}
+	for _, node := range t.canonicalNodes() {
+		s.Nodes = append(s.Nodes, SyncConfirms{
+			PubKeyHash: append([]byte(nil), node.pubKeyHash()...),
+			Confirms:   node.confirms,
+		})
+	}
+	s.Consumed = t.uses()
+
+	return s
+}
+
+// Returns the nodes consumed by t, sorted.
+func (t *NYTree) uses() []SyncUse {
+	uses := make([]SyncUse, 0, len(t.consumed))
+	for _, pkh := range t.consumedHashes() {
+		var key [32]byte
+		copy(key[:], pkh)
+		uses = append(uses, SyncUse{PubKeyHash: pkh, By: t.consumedBy[key]})
+	}
+
+	return uses
+}
+
+// Returns the changes of t that the device with the given fingerprint and
+// summary does not know of yet, to be passed to Apply on that device. Returns
+// an empty delta if the fingerprints are equal, in which case remote may be
+// nil. Returns ErrTreeMismatch if remote belongs to another tree, and
+// ErrSyncSameInstance if it belongs to the same instance as t: devices must be
+// created using Fork for uses of the same node to be detected.
+func (t *NYTree) Diff(remoteFingerprint [32]byte, remote *SyncSummary) (*SyncDelta, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+
+	delta := &SyncDelta{Tree: sha256.Sum256(t.rootPubSeed), From: t.owner}
+	if remoteFingerprint == t.fingerprint() {
+		return delta, nil
+	}
+	if remote == nil {
+		return nil, ErrInvalidSync
+	}
+	if remote.Tree != delta.Tree {
+		return nil, ErrTreeMismatch
+	}
+	if remote.Owner == t.owner {
+		return nil, ErrSyncSameInstance
+	}
+
+	remoteNodes := make(map[[32]byte]uint8, len(remote.Nodes))
+	for _, n := range remote.Nodes {
+		var key [32]byte
+		copy(key[:], n.PubKeyHash)
+		remoteNodes[key] = n.Confirms
+	}
+	remoteUses := make(map[[32]byte]OwnerID, len(remote.Consumed))
+	for _, u := range remote.Consumed {
+		var key [32]byte
+		copy(key[:], u.PubKeyHash)
+		remoteUses[key] = u.By
+	}
+
+	for _, u := range t.uses() {
+		var key [32]byte
+		copy(key[:], u.PubKeyHash)
+		if by, ok := remoteUses[key]; !ok || (by != u.By && u.By != (OwnerID{})) {
+			delta.Used = append(delta.Used, u)
+		}
+	}
+	for _, node := range t.canonicalNodes() {
+		var key [32]byte
+		copy(key[:], node.pubKeyHash())
+		if _, ok := remoteUses[key]; ok {
+			continue
+		}
+		confirms, ok := remoteNodes[key]
+		if !ok {
+			delta.Added = append(delta.Added, append(node.bytes(), node.fields()...))
+		} else if node.confirms > confirms {
+			delta.Confirmed = append(delta.Confirmed, SyncConfirms{PubKeyHash: key[:], Confirms: node.confirms})
+		}
+	}
+
+	return delta, nil
+}
+
+// Merges the changes of another device, returned by its Diff, into the tree
+// t: nodes used by the other device are removed and marked as consumed, nodes
+// it added are added, and higher confirmation counts are applied.
+//
+// If both devices used the same node, the node is reported in the conflicts
+// of the report, logged, and ErrSyncReuse is returned along with the report;
+// the rest of the delta is applied nonetheless. Returns ErrTreeMismatch,
+// without changing t, if the delta belongs to another tree.
+func (t *NYTree) Apply(delta *SyncDelta) (*SyncReport, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	if delta.Tree != sha256.Sum256(t.rootPubSeed) {
+		return nil, ErrTreeMismatch
+	}
+
+	added := make([]*nyNode, len(delta.Added))
+	for i, frame := range delta.Added {
+		node, err := loadFramedNode(frame, false)
+		if err != nil {
+			return nil, err
+		}
+		node.suite, node.w = t.suite, t.w
+		added[i] = node
+	}
+
+	report := &SyncReport{}
+	capacity := t.observedCapacity()
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool, len(delta.Used))
+	}
+	used := make(map[[32]byte]bool, len(delta.Used))
+	for _, u := range delta.Used {
+		var key [32]byte
+		copy(key[:], u.PubKeyHash)
+
+		by := t.consumedBy[key]
+		if t.consumed[key] && by != (OwnerID{}) && u.By != (OwnerID{}) && by != u.By {
+			report.Conflicts = append(report.Conflicts, append([]byte(nil), key[:]...))
+			t.log(LevelAudit, "node used by both devices", pkhAttr(key[:]), "owner", by.String(), "remote", u.By.String())
+			continue
+		}
+		if by == (OwnerID{}) && u.By != (OwnerID{}) {
+			t.setConsumedBy(key, u.By)
+		}
+		t.consumed[key] = true
+		used[key] = true
+		report.Used++
+	}
+
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		var key [32]byte
+		copy(key[:], node.pubKeyHash())
+		if used[key] {
+			t.consumeReservation(node)
+			node.wipe()
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	t.nodes = nodes
+	t.dropIndex()
+
+	for _, node := range added {
+		var key [32]byte
+		copy(key[:], node.pubKeyHash())
+		if t.consumed[key] || t.nodeByPkh(key[:]) != nil {
+			continue
+		}
+		t.nodes = append(t.nodes, node)
+		t.indexNode(node)
+		report.Added++
+	}
+	for _, c := range delta.Confirmed {
+		if node := t.nodeByPkh(c.PubKeyHash); node != nil && node.confirms < c.Confirms {
+			t.setConfirms(node, c.Confirms)
+			report.Confirmed++
+		}
+	}
+
+	t.epoch++
+	t.log(LevelAudit, "sync applied", "from", delta.From.String(), "used", report.Used,
+		"added", report.Added, "confirmed", report.Confirmed, "conflicts", len(report.Conflicts))
+	t.notifyCapacity(capacity)
+	if err := t.writeThrough(); err != nil {
+		return report, err
+	}
+	if len(report.Conflicts) > 0 {
+		return report, ErrSyncReuse
+	}
+
+	return report, nil
+}
+
+// Records that the node with public key hash pkh was consumed by the instance
+// owner.
+func (t *NYTree) setConsumedBy(pkh [32]byte, owner OwnerID) {
+	if t.consumedBy == nil {
+		t.consumedBy = make(map[[32]byte]OwnerID)
+	}
+	t.consumedBy[pkh] = owner
+}
+
+// Encodes the consumedBy field of the serialised tree: pkh || owner for every
+// node whose consumer is known, sorted.
+func (t *NYTree) encodeConsumedBy() []byte {
+	records := make([][]byte, 0, len(t.consumedBy))
+	for pkh, owner := range t.consumedBy {
+		records = append(records, append(append([]byte(nil), pkh[:]...), owner[:]...))
+	}
+	sortHashes(records)
+
+	return bytes.Join(records, nil)
+}
+
+func (t *NYTree) loadConsumedBy(b []byte) error {
+	if len(b)%48 != 0 {
+		return ErrFieldInvalid
+	}
+
+	t.consumedBy = make(map[[32]byte]OwnerID, len(b)/48)
+	for i := 0; i < len(b); i += 48 {
+		var pkh [32]byte
+		var owner OwnerID
+		copy(pkh[:], b[i:])
+		copy(owner[:], b[i+32:])
+		t.consumedBy[pkh] = owner
+	}
+
+	return nil
+}
+
+// Returns the encoding of the summary s.
+func (s *SyncSummary) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(syncSummaryVersion)
+	writeField(buf, syncFieldTree, s.Tree[:])
+	writeField(buf, syncFieldOwner, s.Owner[:])
+	writeField(buf, syncFieldFingerprint, s.Fingerprint[:])
+	for _, n := range s.Nodes {
+		writeField(buf, syncFieldNode, append(append([]byte(nil), n.PubKeyHash...), n.Confirms))
+	}
+	for _, u := range s.Consumed {
+		writeField(buf, syncFieldUse, append(append([]byte(nil), u.PubKeyHash...), u.By[:]...))
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a summary encoded by SyncSummary.Bytes.
+func ParseSyncSummary(b []byte) (*SyncSummary, error) {
+	if len(b) < 1 || b[0] != syncSummaryVersion {
+		return nil, ErrInvalidSync
+	}
+
+	s := &SyncSummary{}
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case syncFieldTree, syncFieldFingerprint:
+			if len(value) != 32 {
+				return ErrInvalidSync
+			}
+			if tag == syncFieldTree {
+				copy(s.Tree[:], value)
+			} else {
+				copy(s.Fingerprint[:], value)
+			}
+		case syncFieldOwner:
+			if len(value) != 16 {
+				return ErrInvalidSync
+			}
+			copy(s.Owner[:], value)
+		case syncFieldNode:
+			c, err := decodeSyncConfirms(value)
+			if err != nil {
+				return err
+			}
+			s.Nodes = append(s.Nodes, c)
+		case syncFieldUse:
+			u, err := decodeSyncUse(value)
+			if err != nil {
+				return err
+			}
+			s.Consumed = append(s.Consumed, u)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns the encoding of the delta d.
+func (d *SyncDelta) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte(syncDeltaVersion)
+	writeField(buf, syncFieldTree, d.Tree[:])
+	writeField(buf, syncFieldOwner, d.From[:])
+	for _, u := range d.Used {
+		writeField(buf, syncFieldUse, append(append([]byte(nil), u.PubKeyHash...), u.By[:]...))
+	}
+	for _, frame := range d.Added {
+		writeField(buf, syncFieldAdded, frame)
+	}
+	for _, c := range d.Confirmed {
+		writeField(buf, syncFieldNode, append(append([]byte(nil), c.PubKeyHash...), c.Confirms))
+	}
+
+	return buf.Bytes()
+}
+
+// Decodes a delta encoded by SyncDelta.Bytes.
+func ParseSyncDelta(b []byte) (*SyncDelta, error) {
+	if len(b) < 1 || b[0] != syncDeltaVersion {
+		return nil, ErrInvalidSync
+	}
+
+	d := &SyncDelta{}
+	err := readFields(b[1:], func(tag byte, value []byte) error {
+		switch tag {
+		case syncFieldTree:
+			if len(value) != 32 {
+				return ErrInvalidSync
+			}
+			copy(d.Tree[:], value)
+		case syncFieldOwner:
+			if len(value) != 16 {
+				return ErrInvalidSync
+			}
+			copy(d.From[:], value)
+		case syncFieldUse:
+			u, err := decodeSyncUse(value)
+			if err != nil {
+				return err
+			}
+			d.Used = append(d.Used, u)
+		case syncFieldAdded:
+			d.Added = append(d.Added, append([]byte(nil), value...))
+		case syncFieldNode:
+			c, err := decodeSyncConfirms(value)
+			if err != nil {
+				return err
+			}
+			d.Confirmed = append(d.Confirmed, c)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func decodeSyncConfirms(b []byte) (SyncConfirms, error) {
+	if len(b) != 33 {
+		return SyncConfirms{}, ErrInvalidSync
+	}
+
+	return SyncConfirms{PubKeyHash: append([]byte(nil), b[:32]...), Confirms: b[32]}, nil
+}
+
+func decodeSyncUse(b []byte) (SyncUse, error) {
+	if len(b) != 48 {
+		return SyncUse{}, ErrInvalidSync
+	}
+
+	u := SyncUse{PubKeyHash: append([]byte(nil), b[:32]...)}
+	copy(u.By[:], b[32:])
+
+	return u, nil
+}
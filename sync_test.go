@@ -0,0 +1,148 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_Sync(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(seed, pubSeed, false)
+	sig, _, err := signMessage("sync test", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		a.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 1 - Forks are separate instances holding the same nodes
+	b, err := a.Fork()
+	if err != nil {
+		t.Fatal("Failed to fork -", err)
+	}
+	if b.Owner() == a.Owner() || b.Available(nil) != a.Available(nil) {
+		t.Fatal("Invalid fork")
+	}
+	delta, err := a.Diff(b.Fingerprint(), b.Summary())
+	if err != nil || len(delta.Used) != 0 || len(delta.Added) != 0 || len(delta.Confirmed) != 0 {
+		t.Fatal("Fork not in sync, err was", err)
+	}
+
+	// 2 - Nodes used and added by one device are synchronised to the other
+	sig, _, err = signMessage("sync test", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err = a.Diff(b.Fingerprint(), b.Summary())
+	if err != nil || len(delta.Used) != 1 || len(delta.Added) != len(sig.ChildHashes) {
+		t.Fatal("Invalid delta, err was", err)
+	}
+	report, err := b.Apply(delta)
+	if err != nil || report.Used != 1 || report.Added != len(sig.ChildHashes) {
+		t.Fatal("Failed to apply delta -", err)
+	}
+	if b.Available(nil) != a.Available(nil) || len(b.Consumed()) != 2 || !bytes.Equal(bytes.Join(b.Consumed(), nil), bytes.Join(a.Consumed(), nil)) {
+		t.Fatal("Used node not removed")
+	}
+
+	// 3 - Confirmations are synchronised
+	for _, pkh := range sig.ChildHashes {
+		a.Confirm(pkh, ConfirmsRequired)
+	}
+	delta, err = a.Diff(b.Fingerprint(), b.Summary())
+	if err != nil || len(delta.Confirmed) != len(sig.ChildHashes) || len(delta.Added) != 0 {
+		t.Fatal("Invalid delta, err was", err)
+	}
+	if report, err = b.Apply(delta); err != nil || report.Confirmed != len(sig.ChildHashes) {
+		t.Fatal("Failed to apply delta -", err)
+	}
+	if b.Available(nil) != a.Available(nil) {
+		t.Fatal("Confirmations not applied")
+	}
+
+	// 4 - Deltas and summaries survive encoding
+	summary, err := ParseSyncSummary(b.Summary().Bytes())
+	if err != nil || summary.Owner != b.Owner() || len(summary.Consumed) != 2 {
+		t.Fatal("Failed to parse summary -", err)
+	}
+	if _, err = b.Diff(a.Fingerprint(), a.Summary()); err != nil {
+		t.Fatal("Failed to diff -", err)
+	}
+	delta, err = b.Diff([32]byte{}, a.Summary())
+	if err != nil {
+		t.Fatal("Failed to diff -", err)
+	}
+	parsed, err := ParseSyncDelta(delta.Bytes())
+	if err != nil || !bytes.Equal(parsed.Bytes(), delta.Bytes()) {
+		t.Fatal("Failed to parse delta -", err)
+	}
+	if _, err = ParseSyncDelta(append([]byte{syncDeltaVersion + 1}, delta.Bytes()[1:]...)); err != ErrInvalidSync {
+		t.Fatal("Parsed invalid delta, err was", err)
+	}
+
+	// 5 - States of the same instance or of other trees are rejected
+	loaded, err := Load(a.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = signMessage("sync test", loaded); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Diff(loaded.Fingerprint(), loaded.Summary()); err != ErrSyncSameInstance {
+		t.Fatal("Diffed the same instance, err was", err)
+	}
+	other := New(seed, pubSeed[1:], false)
+	if _, err = a.Diff(other.Fingerprint(), other.Summary()); err != ErrTreeMismatch {
+		t.Fatal("Diffed another tree, err was", err)
+	}
+}
+
+func TestNYTree_SyncReuse(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New(seed, pubSeed, false)
+	b, err := a.Fork()
+	if err != nil {
+		t.Fatal("Failed to fork -", err)
+	}
+
+	// 1 - Both devices use the root node before synchronising
+	sigA, _, err := signMessage("sync reuse test", a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = signMessage("sync reuse test", b); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 - The reuse is flagged, and the rest of the delta is applied
+	delta, err := a.Diff(b.Fingerprint(), b.Summary())
+	if err != nil || len(delta.Used) != 1 {
+		t.Fatal("Invalid delta, err was", err)
+	}
+	report, err := b.Apply(delta)
+	if err != ErrSyncReuse {
+		t.Fatal("Reuse not detected, err was", err)
+	}
+	if len(report.Conflicts) != 1 || !bytes.Equal(report.Conflicts[0], a.Consumed()[0]) {
+		t.Fatal("Invalid conflicts", report.Conflicts)
+	}
+	if report.Added != len(sigA.ChildHashes) {
+		t.Fatal("Delta not applied")
+	}
+
+	// 3 - The reuse is detected after a round trip through storage
+	loaded, err := Load(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loaded.Apply(delta); err != ErrSyncReuse {
+		t.Fatal("Reuse not detected after loading, err was", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Fixed inputs for the xnyss benchmarks, so results are comparable across
+// machines and commits.
+package testdata
+
+var Seed = []byte{
+	0x1c, 0xc4, 0xbf, 0xad,
+	0xa8, 0xed, 0xc8, 0xb4,
+	0x77, 0x7f, 0x4c, 0xa3,
+	0x52, 0x6d, 0xcf, 0xb9,
+	0x90, 0x44, 0xbf, 0x49,
+	0x73, 0x8c, 0xf3, 0x3e,
+	0x03, 0x3f, 0x19, 0xd3,
+	0xc7, 0x56, 0x19, 0x45,
+}
+
+var PubSeed = []byte{
+	0xc8, 0xd5, 0xb4, 0xc1,
+	0x6e, 0xe9, 0x66, 0x74,
+	0x0c, 0x41, 0x30, 0x74,
+	0xe2, 0xe7, 0xd4, 0x24,
+	0x59, 0x44, 0xf3, 0x5d,
+	0xe9, 0xe3, 0xf9, 0xcf,
+	0x4d, 0xf4, 0xaf, 0x79,
+	0x2c, 0xe7, 0xb2, 0x4c,
+}
+
+var Message = []byte{
+	0xf3, 0xa7, 0xd1, 0xd2,
+	0xfb, 0x29, 0xa1, 0xa8,
+	0xff, 0x8c, 0x14, 0x64,
+	0x3b, 0x3d, 0x65, 0x3d,
+	0x86, 0x68, 0xde, 0xfa,
+	0x4e, 0x0e, 0x90, 0x6b,
+	0x6f, 0x73, 0x48, 0x63,
+	0xa6, 0x76, 0xc6, 0x58,
+}
+
+var Txid = []byte{
+	0x69, 0x2a, 0x86, 0xce,
+	0x4d, 0x4c, 0x20, 0xb9,
+	0x61, 0xe6, 0x78, 0xe4,
+	0x9c, 0xd8, 0x5f, 0x99,
+	0xa3, 0x91, 0x3d, 0xb5,
+	0x09, 0xa9, 0x29, 0x32,
+	0x7a, 0xdd, 0xfd, 0x84,
+	0x2c, 0xaf, 0xa0, 0xc9,
+}
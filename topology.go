@@ -0,0 +1,209 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// A node in the shape of a tree, see NYTree.Topology.
+type TopologyNode struct {
+	PubKeyHash []byte
+	// Whether the tree holds the node. Other nodes signed, or were removed
+	// from the tree, e.g. by Backup or Prune.
+	Held bool
+	// The confirmations and label of held nodes
+	Confirms uint8
+	Label    string
+	// The child nodes created by the signature of the node, if it signed, in
+	// ascending order of their public key hash
+	Children []*TopologyNode
+}
+
+// The shape of a tree, see NYTree.Topology.
+type Topology struct {
+	// The root node, or nil if the tree holds no node that descends from it
+	// through known parents
+	Root *TopologyNode
+	// Subtrees whose parent is not known, because their nodes were created
+	// before parents were recorded, or were received from another state of
+	// the tree. Their roots are in ascending order of their public key hash.
+	Detached []*TopologyNode
+}
+
+// Returns the shape of the tree t: the held nodes, and the consumed nodes they
+// descend from, linked to the nodes whose signatures created them. Every node
+// records the public key hash of its parent, and the parents of consumed nodes
+// are kept as well, so the path from any held node to the root is known; both
+// are included in the serialised tree. Nodes loaded from states that did not
+// record parents end up in Topology.Detached.
+//
+// The topology shows how deep and how balanced the tree grew (see
+// SetSelection), and gives the public key hashes on the path of a node, e.g. to
+// collect the signatures of its ancestry proof. Topology.WriteDOT exports it
+// for Graphviz.
+func (t *NYTree) Topology() *Topology {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.topology()
+}
+
+func (t *NYTree) topology() *Topology {
+	vertices := make(map[[32]byte]*TopologyNode)
+	vertex := func(pkh [32]byte) *TopologyNode {
+		v, ok := vertices[pkh]
+		if !ok {
+			v = &TopologyNode{PubKeyHash: append([]byte(nil), pkh[:]...)}
+			vertices[pkh] = v
+		}
+		return v
+	}
+
+	parents := make(map[[32]byte][32]byte, len(t.nodeParents)+len(t.nodes))
+	for child, parent := range t.nodeParents {
+		parents[child] = parent
+	}
+	for _, node := range t.nodes {
+		v := vertex(pkhKey(node))
+		v.Held, v.Confirms, v.Label = true, node.confirms, node.label
+		if node.parent != nil {
+			var parent [32]byte
+			copy(parent[:], node.parent)
+			parents[pkhKey(node)] = parent
+		}
+	}
+	for child, parent := range parents {
+		p := vertex(parent)
+		p.Children = append(p.Children, vertex(child))
+	}
+
+	var root [32]byte
+	if !t.wiped {
+		copy(root[:], t.suite.sum(t.publicKey()))
+	}
+	topology := &Topology{}
+	for pkh, v := range vertices {
+		sortTopology(v.Children)
+		if _, ok := parents[pkh]; ok {
+			continue
+		}
+		if pkh == root {
+			topology.Root = v
+		} else {
+			topology.Detached = append(topology.Detached, v)
+		}
+	}
+	sortTopology(topology.Detached)
+
+	return topology
+}
+
+func sortTopology(nodes []*TopologyNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].PubKeyHash, nodes[j].PubKeyHash) < 0
+	})
+}
+
+// Returns the depth of the deepest node below n, relative to n.
+func (n *TopologyNode) Height() int {
+	height := 0
+	for _, child := range n.Children {
+		height = max(height, child.Height()+1)
+	}
+
+	return height
+}
+
+// Calls f for n and every node below it, parents before their children.
+func (n *TopologyNode) Walk(f func(node *TopologyNode, depth int)) {
+	n.walk(f, 0)
+}
+
+func (n *TopologyNode) walk(f func(*TopologyNode, int), depth int) {
+	f(n, depth)
+	for _, child := range n.Children {
+		child.walk(f, depth+1)
+	}
+}
+
+// Writes the topology tp to w in the DOT language of Graphviz, e.g. to render
+// it with `dot -Tsvg`. Nodes are named by the first 8 hex digits of their
+// public key hash; held nodes are drawn as boxes, labelled with their
+// confirmations, and consumed nodes as ellipses.
+func (tp *Topology) WriteDOT(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("digraph xnyss {\n")
+	roots := tp.Detached
+	if tp.Root != nil {
+		roots = append([]*TopologyNode{tp.Root}, roots...)
+	}
+	for _, root := range roots {
+		root.Walk(func(node *TopologyNode, _ int) {
+			id := hex.EncodeToString(node.PubKeyHash)
+			if node.Held {
+				printf("\t%q [shape=box, label=\"%s\\n%d conf\"];\n", id, id[:8], node.Confirms)
+			} else {
+				printf("\t%q [label=%q];\n", id, id[:8])
+			}
+			for _, child := range node.Children {
+				printf("\t%q -> %q;\n", id, hex.EncodeToString(child.PubKeyHash))
+			}
+		})
+	}
+	printf("}\n")
+
+	return err
+}
+
+// Records that the node with public key hash child, which was consumed, was
+// created by the node with public key hash parent, if it is known.
+func (t *NYTree) addNodeParent(child [32]byte, parent []byte) {
+	if parent == nil {
+		return
+	}
+	if t.nodeParents == nil {
+		t.nodeParents = make(map[[32]byte][32]byte)
+	}
+
+	var p [32]byte
+	copy(p[:], parent)
+	t.nodeParents[child] = p
+}
+
+// Encodes the parents of consumed nodes as a sorted list of child || parent
+// pairs.
+func (t *NYTree) encodeNodeParents() []byte {
+	pairs := make([][]byte, 0, len(t.nodeParents))
+	for child, parent := range t.nodeParents {
+		pairs = append(pairs, append(append([]byte(nil), child[:]...), parent[:]...))
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i], pairs[j]) < 0
+	})
+
+	return bytes.Join(pairs, nil)
+}
+
+func (t *NYTree) loadNodeParents(b []byte) error {
+	if len(b)%64 != 0 {
+		return ErrFieldInvalid
+	}
+
+	t.nodeParents = nil
+	for i := 0; i < len(b); i += 64 {
+		var child [32]byte
+		copy(child[:], b[i:])
+		t.addNodeParent(child, b[i+32:i+64])
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestNYTree_Topology(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, MsgLen)
+	var sigs []*Signature
+	for i := 0; i < 3; i++ {
+		sig, err := tree.Sign(msg, Txid("topology", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+		sigs = append(sigs, sig)
+	}
+
+	// 1 - The topology links all nodes to the root through their parents
+	tp := tree.Topology()
+	if tp.Root == nil || len(tp.Detached) != 0 {
+		t.Fatal("Invalid topology roots")
+	}
+	if tp.Root.Held || len(tp.Root.Children) != len(sigs[0].ChildHashes) || tp.Root.Height() != 2 {
+		t.Fatal("Invalid topology root", len(tp.Root.Children), tp.Root.Height())
+	}
+	var held, consumed int
+	tp.Root.Walk(func(node *TopologyNode, depth int) {
+		if node.Held {
+			held++
+		} else {
+			consumed++
+		}
+	})
+	if held != len(tree.nodes) || consumed != len(sigs) {
+		t.Fatal("Topology holds", held, "held and", consumed, "consumed nodes")
+	}
+	pkh, _ := sigs[1].PublicKeyHash()
+	for _, node := range tree.Nodes() {
+		if node.Parent == nil {
+			t.Fatal("Node has no parent")
+		}
+	}
+
+	// 2 - Parents are persisted
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	ltp := loaded.Topology()
+	if ltp.Root == nil || ltp.Root.Height() != 2 || !bytes.Equal(ltp.Root.PubKeyHash, tp.Root.PubKeyHash) {
+		t.Fatal("Topology of loaded tree differs")
+	}
+
+	// 3 - Nodes of unknown parent are detached
+	loaded.nodes[0].parent = nil
+	if ltp = loaded.Topology(); len(ltp.Detached) != 1 || !ltp.Detached[0].Held {
+		t.Fatal("Node without parent was not detached")
+	}
+
+	// 4 - Topologies export to DOT, with an edge per child
+	out := &strings.Builder{}
+	if err := tp.WriteDOT(out); err != nil {
+		t.Fatal("Failed to write DOT -", err)
+	}
+	dot := out.String()
+	if !strings.HasPrefix(dot, "digraph") || strings.Count(dot, "->") != held+consumed-1 {
+		t.Fatal("Invalid DOT export", dot)
+	}
+	if !strings.Contains(dot, hex.EncodeToString(pkh)[:8]) {
+		t.Fatal("DOT export lacks consumed node")
+	}
+}
@@ -1,16 +1,24 @@
-// Implements the eXtended Naor-Yung Signature Scheme (XNYSS). Note that the
-// NYTree struct is not thread safe.
+// Implements the eXtended Naor-Yung Signature Scheme (XNYSS). The NYTree struct
+// is safe for concurrent use.
 package xnyss
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	wotsp "github.com/Re0h/xnyss/wotsp256"
-	"errors"
+	"io"
+	"maps"
 	"bytes"
-	"crypto/sha256"
+	"context"
+	"errors"
+	"encoding/binary"
+	"sort"
+	"sync"
 )
 
 const (
 	MsgLen    = 32
+	TxidLen   = 32
 	SigLen    = wotsp.SigLen
 	PubKeyLen = wotsp.PubKeyLen
 )
@@ -23,21 +31,188 @@ var ConfirmsRequired uint8 = 1
 var Branches = 3
 
 var (
-	ErrInvalidMsgLen     = errors.New("invalid message length (must be 32 bytes)")
-	ErrTreeInvalidInput  = errors.New("invalid input, must contain at least a private and a public seed")
-	ErrTreeNoneAvailable = errors.New("no signature nodes available")
-	ErrTreeBackupOneTime = errors.New("cannot create a backup of a one-time tree")
-	ErrTreeBackupFailed  = errors.New("more backup nodes requested than are available")
+	ErrInvalidMsgLen     = newError(ErrEncoding, "invalid message length (must be 32 bytes)")
+	ErrInvalidTxidLen    = newError(ErrEncoding, "invalid txid length (must be 32 bytes, see Txid)")
+	ErrTreeInvalidInput  = newError(ErrEncoding, "invalid input, must contain at least a private and a public seed")
+	ErrTreeNoneAvailable = newError(ErrCapacity, "no signature nodes available")
+	ErrTreeExhausted     = newError(ErrCapacity, "tree has no nodes left and can not sign again")
+	ErrTreeBackupOneTime = newError(ErrState, "cannot create a backup of a one-time tree")
+	ErrTreeNotLongTerm   = newError(ErrState, "operation requires a long-term tree")
+	ErrTreeBackupFailed  = newError(ErrCapacity, "more backup nodes requested than are available")
+	ErrTreeSeedReuse     = newError(ErrState, "node seeds collide with a previously consumed node")
+	ErrTreeDuplicateSeed = newError(ErrState, "tree contains multiple nodes with the same seeds")
+	ErrTreeNodeConsumed  = newError(ErrState, "node was already consumed by another state of this tree")
+	ErrTreeWiped         = newError(ErrState, "tree was wiped and can not be used")
+	ErrTreeMismatch      = newError(ErrState, "states belong to different trees")
+	ErrTreeChecksum      = newError(ErrEncoding, "tree state checksum mismatch")
+	ErrTreeVersion       = newError(ErrEncoding, "unsupported tree state format version")
+
+	ErrTreeStrictUnconfirmed = newError(ErrCapacity, "no confirmed nodes available (strict mode does not use unconfirmed nodes with matching txid)")
 )
 
+// A tree may be used by multiple goroutines: its methods are serialised by a
+// mutex. Since even the read-only methods fill the caches of nodes, no reader
+// lock is used. Callbacks such as event handlers and Log are called while the mutex is
+// held, so they must not call methods of the tree.
 type NYTree struct {
+	mu sync.Mutex
+	// Closed when nodes may have become available, see SignOrWait.
+	avail chan struct{}
+	state
+}
+
+// The state of a tree, kept apart from its mutex so it can be replaced as a
+// whole, see GobDecode.
+type state struct {
 	nodes       []*nyNode
 	rootSeed    []byte
 	rootPubSeed []byte
 	ots         bool
+
+	// Seed digests of the nodes consumed by Sign, used to detect seed reuse.
+	// These are included in the serialised tree as well.
+	consumedSeeds map[[32]byte]bool
+	// Public key hashes of consumed nodes. These are included in the serialised
+	// tree, and can be extended with the consumed nodes of other states of the
+	// same tree (see MarkConsumed).
+	consumed map[[32]byte]bool
+
+	// Incremented on every change of the tree's state, see Epoch.
+	epoch uint64
+
+	// Optional fence that must be held while signing, see SetFence.
+	fence Fence
+	// Optional rate limits of Sign, see SetRateLimit.
+	limiter *rateLimiter
+	// Optional entropy source of child nodes, see SetEntropy.
+	entropy io.Reader
+	// Optional source of the current time, see SetClock.
+	clock Clock
+	// Optional handler of the events of this tree, and the capacity below
+	// which it is warned, see OnEvent and SetCapacityThreshold.
+	onEvent           func(Event)
+	capacityThreshold int
+	// Optional policy of Expand, see SetExpansionPolicy.
+	expansion ExpansionPolicy
+	// Optional audit log of signatures, see EnableAudit.
+	audit *auditLog
+
+	// Active reservations, see Reserve.
+	reservations    map[ReservationID]*reservation
+	lastReservation ReservationID
+	resStats        ReservationStats
+
+	// Whether the root node may not be used to sign, see LockRoot.
+	rootLocked bool
+	// Whether child nodes are derived from their parent, see NewDeterministic.
+	deterministic bool
+	// Branching factor per depth, see SetBranching.
+	branching []uint8
+	// Whether unconfirmed nodes with matching txid may not be used, see
+	// SetStrict.
+	strict bool
+
+	// Hardened one-time trees, see HardenOneTime. Once spent, the tree only
+	// holds its public key.
+	hardened bool
+	spent    bool
+	pubKey   []byte
+
+	// If not nil, rootSeed (and the seed of the root node) hold the seed XOR
+	// rootMask, see BlindSeed.
+	rootMask []byte
+	// The serialised state the nodes alias (not serialised), which Wipe wipes,
+	// see LoadOwned.
+	owned []byte
+
+	// Whether the seeds are held by a seed provider, whose handles take their
+	// place, and the provider (not serialised), see NewWithSeedProvider. The
+	// long-term public key is kept in pubKey.
+	providerSeeds bool
+	seedProvider  SeedProvider
+
+	// Whether signatures no longer create child nodes, see Freeze.
+	frozen      bool
+	transitions []ModeTransition
+
+	// Whether signatures include the counter, see SetCounterBinding.
+	counterBound bool
+	counter      uint64
+
+	// Whether keys are derived using their position, see EnableAddressing.
+	addressed bool
+
+	// The hash suite of the keys, or zero for SHA-256, see SetHashSuite.
+	suite HashSuite
+	// The Winternitz parameter of the keys, or zero for the default, see
+	// SetWinternitz.
+	w uint16
+
+	// Index of the nodes (not serialised), see nodeIndex.
+	idx nodeIndex
+	// Whether nodes are serialised by position, see SetCompact.
+	compact bool
+
+	// The txids of the transactions that created the nodes that signed each
+	// txid, see InvalidateTxid.
+	txidParents map[[32]byte][][32]byte
+	// The public key hash of the parent of every consumed node whose parent
+	// is known, see Topology.
+	nodeParents map[[32]byte][32]byte
+
+	// The depth at which nodes no longer create children, or zero, see
+	// SetMaxDepth.
+	maxDepth uint32
+	// The txid of child nodes, see SetTxidPolicy.
+	txidPolicy TxidPolicy
+	// The node selection strategy, see SetSelection, and the index of the
+	// node selected last by round-robin selection (not serialised).
+	selection  Selection
+	roundRobin int
+	// Whether txids of other lengths than TxidLen are hashed, see
+	// SetTxidHashing.
+	txidHashing bool
+	// Whether signatures are verified before they are returned, see
+	// SetVerifyAfterSign.
+	verifyAfterSign bool
+
+	// Whether the secret data of the tree was wiped, see Wipe.
+	wiped bool
+	// Whether seed buffers are locked into memory (not serialised), see
+	// LockMemory.
+	lockSeeds bool
+
+	// The instance of the tree, and the instance it was split from by Backup,
+	// see Owner.
+	owner         OwnerID
+	delegatedFrom OwnerID
+	// The number of backups created, and the description of the backup the
+	// tree was created as, see BackupInfo.
+	backups    uint64
+	backupInfo *BackupInfo
+	// The instances that consumed nodes, if known, see Apply.
+	consumedBy map[[32]byte]OwnerID
+	// Whether a revocation node is reserved, the node, and the signature of
+	// the root that created it, see EnableRevocation.
+	revocable      bool
+	revocationNode *nyNode
+	revocationLink *Signature
+	// The nodes revoked by revocation and freeze lists, and the sequence
+	// number of the last revocation list, see RevokeNodes.
+	revokedNodes      map[[32]byte]bool
+	revocationListSeq uint64
+
+	// The storage written in write-through mode, see SetStorage, with the
+	// digests of the node records and header it holds (not serialised).
+	storage      Storage
+	stored       map[[32]byte][32]byte
+	storedHeader [32]byte
 }
 
 // Creates a new Naor-Yung chain tree using the given secret and public seeds.
+// If ots is set, the tree signs only once, and is hardened (see
+// SetOneTimeHardening): its seeds are wiped as soon as it signed. The keys of
+// the tree are addressed by their position, see EnableAddressing.
 func New(seed, pubSeed []byte, ots bool) *NYTree {
 	root := &nyNode{
 		privSeed: make([]byte, 32),
@@ -49,24 +224,44 @@ func New(seed, pubSeed []byte, ots bool) *NYTree {
 	copy(root.privSeed, seed)
 	copy(root.pubSeed, pubSeed)
 
-	tree := &NYTree{
+	tree := &NYTree{state: state{
 		nodes:       make([]*nyNode, 0, 32),
 		rootSeed:    make([]byte, 32),
 		rootPubSeed: make([]byte, 32),
-	}
+	}}
 
 	copy(tree.rootSeed, seed)
 	copy(tree.rootPubSeed, pubSeed)
 
 	tree.nodes = append(tree.nodes, root)
 	tree.ots = ots
+	tree.hardened = ots
+	tree.addressed, root.addressed = true, true
 
 	return tree
 }
 
 // Returns the long-term public key of a tree.
 func (t *NYTree) PublicKey() []byte {
-	return wotsp.GenPublicKey(t.rootSeed, t.rootPubSeed, &wotsp.Address{})
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.publicKey()
+}
+
+func (t *NYTree) publicKey() []byte {
+	if t.wiped {
+		return nil
+	}
+	if t.spent || t.providerSeeds {
+		return append([]byte(nil), t.pubKey...)
+	}
+
+	if t.rootMask != nil {
+		return t.wots().GenPublicKeyMasked(t.rootSeed, t.rootMask, t.rootPubSeed, &wotsp.Address{})
+	}
+
+	return t.wots().GenPublicKey(t.rootSeed, t.rootPubSeed, &wotsp.Address{})
 }
 
 // Searches for a node in the tree that can be used to create a new signature.
@@ -75,67 +270,359 @@ func (t *NYTree) PublicKey() []byte {
 // signature for. If no nodes are available, an ErrTreeNoneAvailable error is
 // returned.
 //
-// First goes through all nodes to find whether there is a node with matching
-// txid, so that inputs in the same transaction are all signed in one subtree
-// and thus effectively use up only one node in the tree. If no nodes have a
-// matching txid, we try to find a confirmed node.
-func (t *NYTree) getSignNode(txid []byte) int {
+// First looks up the reservations and the txid index to find whether there is
+// a node reserved for txid, or a node with matching txid, so that inputs in the
+// same transaction are all signed in one subtree and thus effectively use up
+// only one node in the tree. If no nodes have a matching txid, we try to find a
+// confirmed node using the selection strategy of the tree, unless the index
+// counts none. Nodes reserved for other txids are skipped.
+//
+// If filter is not nil, only nodes for which it returns true are considered.
+// In strict mode, nodes with a matching txid are only used once confirmed.
+func (t *NYTree) getSignNode(txid []byte, filter func(*nyNode) bool) int {
+	t.reapReservations()
+
+	usable := func(n *nyNode) bool {
+		return t.canSign(n, txid) && (filter == nil || filter(n))
+	}
+
+	// Find the node reserved first for this txid
+	var reserved *nyNode
+	for _, r := range t.reservations {
+		if (reserved == nil || r.node.reservation < reserved.reservation) && usable(r.node) {
+			reserved = r.node
+		}
+	}
+	if i := t.nodeIndex(reserved); reserved != nil && i >= 0 {
+		return i
+	}
 	// Find nodes with the same txid
-	for i := range t.nodes {
-		if bytes.Equal(t.nodes[i].txid, txid) {
-			return i
+	for _, node := range t.nodesByTxid(txid) {
+		if usable(node) {
+			return t.nodeIndex(node)
 		}
 	}
-	// Find confirmed nodes
-	for i := range t.nodes {
-		if t.nodes[i].confirms >= ConfirmsRequired {
-			return i
+	// Find confirmed nodes, see SetSelection
+	if t.confirmedNodes() == 0 {
+		return -1
+	}
+	return t.selectNode(usable)
+}
+
+// Returns the index of the node that signs for txid, see getSignNode. Nodes
+// whose seeds or public key hash were consumed before can never sign, so they
+// are quarantined and another node is selected. If no other node is available,
+// the error of the first quarantined node is returned, ErrTreeSeedReuse or
+// ErrTreeNodeConsumed.
+func (t *NYTree) selectSignNode(txid []byte, filter func(*nyNode) bool) (int, error) {
+	var refused error
+	for {
+		index := t.getSignNode(txid, filter)
+		if index < 0 {
+			if refused != nil {
+				t.tryWriteThrough()
+				return -1, refused
+			}
+			return -1, t.unavailableErr(txid)
 		}
+
+		err := t.checkReuse(t.nodes[index])
+		if err == nil {
+			return index, nil
+		}
+		if refused == nil {
+			refused = err
+		}
+		t.quarantine(index, err)
+	}
+}
+
+// Returns ErrTreeSeedReuse if the seeds of node were consumed by a signature
+// before, and ErrTreeNodeConsumed if its public key hash was consumed, e.g. by
+// another state of the tree.
+func (t *NYTree) checkReuse(node *nyNode) error {
+	if t.consumedSeeds[node.seedDigest()] {
+		return ErrTreeSeedReuse
 	}
+	if t.consumed[pkhKey(node)] {
+		return ErrTreeNodeConsumed
+	}
+
+	return nil
+}
+
+// Removes the node at index, which failed checkReuse for the given reason,
+// from t, and records it as consumed so it does not return, e.g. by Apply.
+func (t *NYTree) quarantine(index int, reason error) {
+	node := t.nodes[index]
+	pkh := pkhKey(node)
+	t.log(LevelAudit, "node quarantined", pkhAttr(pkh[:]), "reason", reason.Error())
+
+	if node.reservation != 0 {
+		delete(t.reservations, node.reservation)
+		node.reservation = 0
+	}
+	t.nodes = append(t.nodes[:index], t.nodes[index+1:]...)
+	t.unindexNode(node)
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool)
+	}
+	t.consumed[pkh] = true
+	node.wipe()
+	t.epoch++
+}
+
+// Returns the reason why no node is available to sign for txid. Returns
+// ErrTreeExhausted if the tree has no nodes left at all, which is final: no
+// nodes will become available by waiting for confirmations.
+func (t *NYTree) unavailableErr(txid []byte) error {
+	switch {
+	case len(t.nodes) == 0:
+		return ErrTreeExhausted
+	case t.hasLockedRoot():
+		return ErrTreeRootLocked
+	case t.strict && t.hasTxid(txid):
+		return ErrTreeStrictUnconfirmed
+	}
+
+	return ErrTreeNoneAvailable
+}
+
+// Returns whether the tree t has no nodes left, e.g. because it is a one-time
+// tree that was used. An exhausted tree can never sign again, so the key must be
+// rotated.
+func (t *NYTree) Exhausted() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-	return -1
+	return len(t.nodes) == 0
+}
+
+// Returns whether node can currently be used to sign for txid: it must not be a
+// locked root or be reserved for another txid, and must either be confirmed or
+// have a matching txid (the latter is not allowed in strict mode).
+func (t *NYTree) canSign(node *nyNode, txid []byte) bool {
+	if (t.rootLocked && t.isRoot(node)) || !t.reservedFor(node, txid) {
+		return false
+	}
+
+	return node.confirms >= ConfirmsRequired ||
+		(!t.strict && !t.frozen && bytes.Equal(node.txid, txid))
 }
 
 // Creates a signature for the given message. The txid and input are used to
 // create new nodes in the tree. Returns an error if no nodes are available to
-// create new signatures, of if the input message is longer than 32 bytes.
+// create new signatures, and ErrInvalidMsgLen unless the message is exactly 32
+// bytes long: shorter messages are not padded, so callers hash their messages
+// to MsgLen bytes (see SignMessage).
+//
+// The txid must be 32 bytes long. Applications that do not sign transactions
+// with such an identifier should derive it from their own request identifiers
+// using Txid. One-time trees (see New) create no nodes, and accept a nil
+// txid.
 //
 // Whenever a signature is created, two new nodes are added to the tree. These
 // new nodes can be used in the future to create new signatures. The returned
 // signature signs the message H(msg||H(pk1)||H(pk2)) where msg is the original
 // message passed to this function. Both H(pk1) and H(pk2) are included in the
 // returned signature structure.
-func (t *NYTree) Sign(msg, txid []byte) (*Signature, error) {
-	if len(msg) > MsgLen {
+func (t *NYTree) Sign(msg, txid []byte) (sig *Signature, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, &signOptions{})
+	})
+
+	return
+}
+
+// Options for a single signature, set by the different Sign variants.
+type signOptions struct {
+	// If not nil, only nodes for which filter returns true are used.
+	filter func(*nyNode) bool
+	// If not nil, the label of the new child nodes. Otherwise, the children
+	// inherit the label of their parent.
+	childLabel *string
+	// If not nil, called with the node selected to sign. Signing is aborted if
+	// it returns an error.
+	selected func(*nyNode) error
+	// If not nil, the data committed to by the signature, see SignCommitted.
+	commitment []byte
+	// If not nil, called with the node that signed instead of wiping its
+	// seed, for callers that may still restore the node, see snapshot.
+	consumed func(*nyNode)
+	// If not nil, signing is aborted with ctx.Err() once ctx is done, see
+	// SignContext.
+	ctx context.Context
+}
+
+func (t *NYTree) sign(msg, txid []byte, opts *signOptions) (*Signature, error) {
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
+	if len(msg) != MsgLen {
 		return nil, ErrInvalidMsgLen
 	}
+	if _, ok := t.seedProvider.(missingSeedProvider); ok {
+		return nil, ErrSeedProviderMissing
+	}
+	if txid == nil && t.ots {
+		txid = make([]byte, TxidLen)
+	}
+	txid, txidLen, err := t.normalizeTxid(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := t.selectSignNode(txid, opts.filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.checkFence(); err != nil {
+		return nil, err
+	}
+	if err := t.checkRateLimit(txid); err != nil {
+		return nil, err
+	}
+	capacity := t.observedCapacity()
 
-	index := t.getSignNode(txid)
-	if index < 0 {
-		return nil, ErrTreeNoneAvailable
+	digest := t.nodes[index].seedDigest()
+	var pkh [32]byte
+	copy(pkh[:], t.nodes[index].pubKeyHash())
+	t.log(LevelDecision, "node selected", pkhAttr(pkh[:]), "txid", hex.EncodeToString(txid),
+		"depth", t.nodes[index].depth, "confirms", t.nodes[index].confirms)
+	if txidLen > 0 {
+		t.log(LevelDecision, "txid hashed", "txid", hex.EncodeToString(txid), "length", txidLen)
 	}
 
 	// Create a signature, retrieving the next nodes to add to the tree
-	sig, childNodes, err := t.nodes[index].sign(msg, txid, t.ots)
+	node := t.nodes[index]
+	if opts.selected != nil {
+		if err := opts.selected(node); err != nil {
+			return nil, err
+		}
+	}
+	branches := t.branchesAt(node.depth)
+	var counter *uint64
+	if t.counterBound {
+		next := t.counter + 1
+		counter = &next
+	}
+	leaf := t.ots || t.frozen || t.atMaxDepth(node)
+	if opts.ctx != nil {
+		if err := opts.ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	sig, childNodes, err := node.sign(msg, txid, leaf, branches, t.childEntropy(node), counter, opts.commitment)
 	if err != nil {
 		return nil, err
 	}
+	if err := t.checkSignature(sig, pkh[:], childNodes); err != nil {
+		if node.provider != nil {
+			// The provider destroyed the seed of the node when it signed
+			t.markConsumed([][]byte{pkh[:]})
+		}
+		return nil, err
+	}
+	if err := t.auditSign(sig, pkh[:], msg, txid); err != nil {
+		if node.provider != nil {
+			t.markConsumed([][]byte{pkh[:]})
+		}
+		return nil, err
+	}
+	t.lockChildSeeds(childNodes)
+	if counter != nil {
+		t.counter = *counter
+	}
+	sig.params = &Params{Branches: branches, ConfirmsRequired: ConfirmsRequired, Scheme: t.scheme(),
+		HashSuite: sig.HashSuite(), Winternitz: sig.Winternitz()}
 
-	// Remove used node from the tree
+	// Remove used node from the tree, remembering its seeds
+	if t.consumedSeeds == nil {
+		t.consumedSeeds = make(map[[32]byte]bool)
+	}
+	t.consumedSeeds[digest] = true
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool)
+	}
+	t.consumed[pkh] = true
+	if t.owner != (OwnerID{}) {
+		t.setConsumedBy(pkh, t.owner)
+	}
+	t.log(LevelAudit, "node consumed", pkhAttr(pkh[:]), "children", len(sig.ChildHashes))
+	parent := t.nodes[index]
+	if t.ots && t.hardened {
+		t.spend()
+		t.epoch++
+		t.notifyConsumed(pkh[:], true)
+		t.notifyCapacity(capacity)
+		if err := t.writeThrough(); err != nil {
+			return nil, err
+		}
+		return sig, nil
+	}
+	signedByRoot := t.isRoot(parent)
+	if signedByRoot {
+		// Sign once, then lock
+		t.rootLocked = true
+	}
+	t.consumeReservation(parent)
+	if !signedByRoot {
+		t.addTxidParent(txid, parent.txid)
+	}
+	t.addNodeParent(pkh, parent.parent)
 	t.nodes = append(t.nodes[:index], t.nodes[index+1:]...)
+	t.unindexNode(parent)
+	t.epoch++
+	if opts.consumed != nil {
+		opts.consumed(parent)
+	} else {
+		parent.wipeSeed()
+	}
 
 	// Add child nodes to the tree
-	if !t.ots && childNodes != nil {
+	if signedByRoot && t.revocable && t.revocationNode == nil && len(childNodes) > 1 {
+		t.reserveRevocation(childNodes[0], sig)
+		childNodes = childNodes[1:]
+	}
+	if !leaf && childNodes != nil {
+		l := &link{sig: sig, signer: pkh, byRoot: signedByRoot, parent: parent.link}
+		childTxid := t.childTxid(txid, pkh)
+		created := t.now().Unix()
 		for i := range childNodes {
+			childNodes[i].txid = childTxid
+			childNodes[i].created = created
+			if t.txidPolicy == TxidInherit {
+				childNodes[i].txidLen = txidLen
+			}
+			childNodes[i].link = l
+			childNodes[i].parent = append([]byte(nil), pkh[:]...)
+			childNodes[i].indexed = t.deterministic
+			childNodes[i].label = parent.label
+			if opts.childLabel != nil {
+				childNodes[i].label = *opts.childLabel
+			}
 			t.nodes = append(t.nodes, childNodes[i])
+			t.indexNode(childNodes[i])
 		}
 	}
+	t.notifyConsumed(pkh[:], leaf || childNodes == nil)
+	t.notifyCapacity(capacity)
+	if err := t.writeThrough(); err != nil {
+		return nil, err
+	}
 
 	return sig, nil
 }
 
 // Returns a list of public key hashes of unconfirmed nodes present in the tree.
 func (t *NYTree) Unconfirmed() (pkhashes [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	idxs := make([]int, 0, len(t.nodes))
 	for idx, node := range t.nodes {
 		if node.confirms >= ConfirmsRequired {
@@ -147,47 +634,68 @@ func (t *NYTree) Unconfirmed() (pkhashes [][]byte) {
 
 	pkhashes = make([][]byte, len(idxs))
 	for i, idx := range idxs {
-		pkh := sha256.Sum256(t.nodes[idx].genPubKey())
-
 		pkhashes[i] = make([]byte, 32)
-		copy(pkhashes[i], pkh[:])
+		copy(pkhashes[i], t.nodes[idx].pubKeyHash())
 	}
 
 	return
 }
 
-// Sets the confirmation count of all nodes in the tree with the given txid to
-// the given number of confirmations.
+// Sets the confirmation count of the unconfirmed node with public key hash pkh
+// to the given number of confirmations. Confirmation counts only increase: a
+// count lower than the current one, e.g. reported late or by a lagging
+// backend, is ignored. Use SetConfirms to lower the count of a node after a
+// reorganisation.
 //
-// Because we have to calculate the public key hash for every node on the fly,
-// this function can be a performance hog if you need to confirm many nodes. We
-// can speed this up by saving the public key hash of every (unconfirmed) node,
-// which would increase the size of every node with 32 bytes. Depending on the
-// amount of (unconfirmed) nodes that are in the state, this could be an
-// acceptable tradeoff. An ameliorating factor is that when we are confirming a
-// batch of nodes, the performance of this function will improve after every
-// call since each time an additional node will be confirmed.
+// Nodes cache their public key hash, which is also saved in the serialised
+// tree (adding 37 bytes to every node that has one), and are indexed by it, so
+// confirming a node does not compute any public keys.
 func (t *NYTree) Confirm(pkh []byte, confirms uint8) {
-	for _, node := range t.nodes {
-		if node.confirms >= ConfirmsRequired {
-			continue
-		}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	profile(OpConfirm, t, func() {
+		t.confirm(pkh, confirms)
+	})
+}
 
-		nodePkh := sha256.Sum256(node.genPubKey())
-		if bytes.Equal(pkh, nodePkh[:]) {
-			node.confirms = confirms
+func (t *NYTree) confirm(pkh []byte, confirms uint8) {
+	confirms = boundConfirms(confirms)
+	capacity := t.observedCapacity()
+	if node := t.nodeByPkh(pkh); node != nil && node.confirms < ConfirmsRequired && node.confirms < confirms {
+		t.setConfirms(node, confirms)
+		t.epoch++
+		t.log(LevelAudit, "confirm applied", pkhAttr(pkh), "confirms", confirms)
+		if confirms >= ConfirmsRequired {
+			t.notifyConfirmed(pkh)
 		}
+		t.tryWriteThrough()
 	}
+	t.notifyCapacity(capacity)
 }
 
 // Returns the amount of signatures that can be created with the tree t. If txid
 // is not nil, nodes with a matching txid are counted as valid even if they do
 // not have enough confirmations. This is useful when a transaction includes
-// multiple inputs: these can all be signed in one subtree.
+// multiple inputs: these can all be signed in one subtree. Nodes reserved for a
+// different txid are not counted. In strict mode, only confirmed nodes are
+// counted.
 func (t *NYTree) Available(txid []byte) (n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if txid != nil {
+		txid = t.lookupTxid(txid)
+	}
+
+	return t.available(txid)
+}
+
+func (t *NYTree) available(txid []byte) (n int) {
+	t.reapReservations()
+
 	for i := range t.nodes {
-		if bytes.Equal(t.nodes[i].txid, txid) ||
-			t.nodes[i].confirms >= ConfirmsRequired {
+		if t.canSign(t.nodes[i], txid) {
 			n++
 		}
 	}
@@ -198,103 +706,902 @@ func (t *NYTree) Available(txid []byte) (n int) {
 // Create a backup of the tree t by moving 'count' nodes of t to a new tree. A
 // backup can only be created if the original tree contains more than one node
 // that is available for signing (i.e. has at least ConfirmsRequired
-// confirmations).
+// confirmations). The backup holds copies of the moved nodes, whose seeds are
+// wiped in t, so the trees share no memory and either can be wiped without
+// affecting the other. The backup does not use the storage of t, see
+// SetStorage, and is a separate instance of the tree, see Owner.
+//
+// The moved nodes are the first available ones; see BackupWith to select
+// them otherwise.
 func (t *NYTree) Backup(count int) (*NYTree, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.backup(BackupPolicy{Count: count})
+}
+
+func (t *NYTree) backup(policy BackupPolicy) (*NYTree, error) {
+	count := policy.count()
+	if t.wiped {
+		return nil, ErrTreeWiped
+	}
 	if t.ots {
 		return nil, ErrTreeBackupOneTime
 	}
 
-	backup := &NYTree{
-		ots:         t.ots,
-		rootSeed:    make([]byte, 32),
-		rootPubSeed: make([]byte, 32),
-		nodes:       make([]*nyNode, 0, count),
-	}
+	backup := &NYTree{state: state{
+		ots:             t.ots,
+		rootLocked:      t.rootLocked,
+		deterministic:   t.deterministic,
+		branching:       append([]uint8(nil), t.branching...),
+		strict:          t.strict,
+		frozen:          t.frozen,
+		transitions:     t.copyTransitions(),
+		counterBound:    t.counterBound,
+		counter:         t.counter,
+		addressed:       t.addressed,
+		suite:           t.suite,
+		w:               t.w,
+		maxDepth:        t.maxDepth,
+		txidPolicy:      t.txidPolicy,
+		selection:       t.selection,
+		txidHashing:     t.txidHashing,
+		verifyAfterSign: t.verifyAfterSign,
+		providerSeeds:   t.providerSeeds,
+		seedProvider:    t.seedProvider,
+		pubKey:          append([]byte(nil), t.pubKey...),
+		rootSeed:        make([]byte, 32),
+		rootPubSeed:     make([]byte, 32),
+		nodes:           make([]*nyNode, 0, count),
+	}}
 
 	// When not enough nodes are available, return a backup tree without nodes.
 	// This can be useful to make sure deterministic wallets using this backup
 	// correctly see no signatures are available, and do not create a new state
 	// including a root node (which might have already been used).
-	if count >= t.Available(nil) {
+	if count >= t.available(nil) {
 		return backup, ErrTreeBackupFailed
 	}
+	selected, err := t.backupSelection(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.assignOwners(backup); err != nil {
+		return nil, err
+	}
 
 	copy(backup.rootSeed, t.rootSeed)
 	copy(backup.rootPubSeed, t.rootPubSeed)
-	// After removing a node from t.nodes, start from the beginning again to
-	// prevent issues with indexing.
-	for added := 0; added < count; added++ {
-		for i := range t.nodes {
-			if t.nodes[i].confirms >= ConfirmsRequired {
-				node := t.nodes[i]
-				// Remove node i from t's node list ...
-				t.nodes = append(t.nodes[:i], t.nodes[i+1:]...)
-				// ... and add it to the backup tree.
-				backup.nodes = append(backup.nodes, node)
-				break
-			}
+	if t.rootMask != nil {
+		backup.rootMask = append([]byte(nil), t.rootMask...)
+	}
+
+	// The backup shares the history of t
+	backup.consumedSeeds = make(map[[32]byte]bool, len(t.consumedSeeds))
+	for digest := range t.consumedSeeds {
+		backup.consumedSeeds[digest] = true
+	}
+	backup.consumed = make(map[[32]byte]bool, len(t.consumed))
+	for pkh := range t.consumed {
+		backup.consumed[pkh] = true
+	}
+	for pkh, owner := range t.consumedBy {
+		backup.setConsumedBy(pkh, owner)
+	}
+	backup.nodeParents = maps.Clone(t.nodeParents)
+	// Remove the selected nodes from t's node list, and add copies to the
+	// backup tree, wiping the originals so the trees share no seeds
+	moved := make(map[*nyNode]bool, len(selected))
+	for _, node := range selected {
+		moved[node] = true
+	}
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		if !moved[node] {
+			nodes = append(nodes, node)
+		} else {
+			t.dropReservation(node)
 		}
 	}
+	t.nodes = nodes
+	t.dropIndex()
+	for _, node := range selected {
+		backup.nodes = append(backup.nodes, node.clone(backup.rootMask))
+		node.wipe()
+	}
+
+	t.epoch++
+	backup.epoch = t.epoch
+	t.backups++
+	backup.backupInfo = t.newBackupInfo()
+
+	t.log(LevelAudit, "backup created", "nodes", len(backup.nodes), "epoch", t.epoch)
+
+	// The moved nodes are only in the backup now, so it is returned even if
+	// their removal could not be written
+	if err := t.writeThrough(); err != nil {
+		return backup, err
+	}
 
 	return backup, nil
 }
 
-// Wipes secret data.
+// Returns the public key hashes of all nodes that were consumed by this tree,
+// or by other states of the same tree as reported to MarkConsumed.
+func (t *NYTree) Consumed() (pkhashes [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.consumedHashes()
+}
+
+func (t *NYTree) consumedHashes() (pkhashes [][]byte) {
+	return sortedDigests(t.consumed)
+}
+
+// Returns the digests of the set, in ascending order.
+func sortedDigests(set map[[32]byte]bool) (digests [][]byte) {
+	digests = make([][]byte, 0, len(set))
+	for digest := range set {
+		digests = append(digests, append([]byte(nil), digest[:]...))
+	}
+
+	sort.Slice(digests, func(i, j int) bool {
+		return bytes.Compare(digests[i], digests[j]) < 0
+	})
+
+	return
+}
+
+// Decodes a set of digests, encoded as their concatenation.
+func loadDigests(b []byte) (map[[32]byte]bool, error) {
+	if len(b)%32 != 0 {
+		return nil, ErrFieldInvalid
+	}
+
+	set := make(map[[32]byte]bool, len(b)/32)
+	for i := 0; i < len(b); i += 32 {
+		var digest [32]byte
+		copy(digest[:], b[i:])
+		set[digest] = true
+	}
+
+	return set, nil
+}
+
+// Marks the nodes with the given public key hashes as consumed, e.g. using the
+// Consumed list of a newer state of the same tree, or public key hashes taken
+// from signatures found on the blockchain. Nodes of t that are marked consumed
+// are removed from the tree and can no longer be used to sign. Returns the
+// number of removed nodes: if it is greater than zero, t was behind.
+func (t *NYTree) MarkConsumed(pkhashes [][]byte) (removed int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.markConsumed(pkhashes)
+}
+
+func (t *NYTree) markConsumed(pkhashes [][]byte) (removed int) {
+	if t.consumed == nil {
+		t.consumed = make(map[[32]byte]bool, len(pkhashes))
+	}
+
+	for _, pkh := range pkhashes {
+		var key [32]byte
+		copy(key[:], pkh)
+		t.consumed[key] = true
+	}
+
+	nodes := t.nodes[:0]
+	for _, node := range t.nodes {
+		var key [32]byte
+		copy(key[:], node.pubKeyHash())
+		if t.consumed[key] {
+			t.consumeReservation(node)
+			node.wipe()
+			removed++
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	t.nodes = nodes
+	t.dropIndex()
+	t.epoch++
+	t.tryWriteThrough()
+
+	return
+}
+
+// Returns the epoch of the tree t: a counter that is incremented on every state
+// change (signatures, confirmations, backups) and is included in the serialised
+// tree. A snapshot with a lower epoch than the current state is stale: loading
+// it would make nodes available again that were already used.
+func (t *NYTree) Epoch() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.epoch
+}
+
+// Compares the epoch of t to that of other, returning -1 if t is older, 0 if
+// they are equal and 1 if t is newer. Returns ErrTreeMismatch if t and other are
+// not states of the same tree.
+func (t *NYTree) CompareEpoch(other *NYTree) (int, error) {
+	// Read other first, so t and other are never locked at the same time
+	other.mu.Lock()
+	otherEpoch := other.epoch
+	otherPubSeed := append([]byte(nil), other.rootPubSeed...)
+	other.mu.Unlock()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !bytes.Equal(t.rootPubSeed, otherPubSeed) {
+		return 0, ErrTreeMismatch
+	}
+
+	switch {
+	case t.epoch < otherEpoch:
+		return -1, nil
+	case t.epoch > otherEpoch:
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// Returns the epoch of a serialised tree, so that a snapshot can be checked
+// before it replaces the current state. Only the header of the state is
+// decoded, so its seeds and nodes are never copied; the state must still be
+// loaded with Load, which validates it.
+func StateEpoch(b []byte) (uint64, error) {
+	var header []byte
+	if bytes.HasPrefix(b, []byte(stateMagic)) {
+		if len(b) < stateHeaderLen+sha256.Size {
+			return 0, ErrTreeInvalidInput
+		}
+		body := b[:len(b)-sha256.Size]
+		if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], b[len(body):]) {
+			return 0, ErrTreeChecksum
+		}
+		if body[len(stateMagic)] != stateVersion {
+			return 0, ErrTreeVersion
+		}
+		if body[len(stateMagic)+1]&flagExtended != 0 {
+			frame, _, err := readFrame(body[stateHeaderLen:])
+			if err != nil {
+				return 0, err
+			}
+			header = frame
+		}
+	} else {
+		if len(b) < 65 || b[0]&^knownFlags != 0 {
+			return 0, ErrTreeInvalidInput
+		}
+		if b[0]&flagExtended != 0 {
+			frame, _, err := readFrame(b[65:])
+			if err != nil {
+				return 0, err
+			}
+			header = frame
+		}
+	}
+
+	var epoch uint64
+	err := readFields(header, func(tag byte, value []byte) error {
+		if tag == fieldEpoch {
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			epoch = binary.BigEndian.Uint64(value)
+		}
+		return nil
+	})
+
+	return epoch, err
+}
+
+// Checks the tree t for inconsistencies that could lead to a one-time key being
+// used more than once, like ValidateState, but returns the error of the first
+// node that fails without its position, e.g. ErrTreeDuplicateSeed if multiple
+// nodes share the same private and public seeds, or ErrTreeSeedReuse if a node
+// has the same seeds as a node that was already consumed by Sign.
+//
+// Deprecated: Use ValidateState, which reports the failing node.
+func (t *NYTree) Validate() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stateErr *StateError
+	if err := t.validateState(); errors.As(err, &stateErr) {
+		return stateErr.Err
+	}
+
+	return nil
+}
+
+// Wipes the seeds and expanded keys of the tree t, and removes its nodes, as
+// well as the state it was loaded from by LoadOwned. The tree can not be used
+// afterwards: signing, serialising and creating backups return ErrTreeWiped,
+// Bytes and PublicKey return nil, and Wiped reports true.
 func (t *NYTree) Wipe() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	for _, node := range t.nodes {
+		t.dropReservation(node)
 		node.wipe()
 	}
+	t.nodes = nil
+	t.dropIndex()
 
 	for i := range t.rootSeed {
 		t.rootSeed[i] = 0
 	}
+	for i := range t.rootPubSeed {
+		t.rootPubSeed[i] = 0
+	}
+	for i := range t.rootMask {
+		t.rootMask[i] = 0
+	}
+	wipeBytes(t.owned)
+	t.owned = nil
+	if t.revocationNode != nil {
+		t.revocationNode.wipe()
+		t.revocationNode = nil
+	}
+	t.wiped = true
 }
 
-// Returns a byte representation of the tree t.
+// Returns whether the tree t was wiped, see Wipe.
+func (t *NYTree) Wiped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.wiped
+}
+
+// Returns a byte representation of the tree t, in the format
+//
+//	"XNYS" || version || flags || rootSeed || rootPubSeed || [header] || nodes || checksum
+//
+// where the header (present if flagExtended is set) and every node are framed
+// as uint32(len(frame)) || frame. A node frame holds the 97 byte node record
+// followed by the node's fields. The checksum is the SHA-256 digest of
+// everything before it, so Load detects corrupted states.
+//
+// Nodes are written in ascending order of their public key hash, so the output
+// only depends on the state of the tree, not on the order in which nodes were
+// added and removed. Loaded trees keep that order. Returns nil if the tree was
+// wiped.
 func (t *NYTree) Bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	buf := &bytes.Buffer{}
+	if _, err := t.writeTo(buf); err != nil {
+		return nil
+	}
 
-	if t.ots {
-		buf.WriteByte(0x01)
-	} else {
-		buf.WriteByte(0x00)
+	return buf.Bytes()
+}
+
+// Loads an existing Naor-Yung chain tree from bytes. Both the current format
+// (see Bytes) and the legacy format without magic bytes and checksum are
+// accepted. The tree does not alias b, which the caller may wipe or reuse.
+// Returns ErrTreeChecksum if the checksum of the state does not match, and
+// ErrTreeVersion if the state has an unknown format version.
+//
+// If SelfTestOnLoad is set, returns ErrSelfTest if the self-test fails.
+func Load(b []byte) (*NYTree, error) {
+	return load(b, false)
+}
+
+// Loads a tree from bytes like Load, but takes ownership of b instead of
+// copying the seeds and txids of the nodes out of it, which saves an
+// allocation per node when loading large states. The nodes alias b, so the
+// caller must neither use nor change b afterwards: Wipe wipes all of b, since
+// it holds the seeds of the tree. If loading fails, b is wiped right away.
+func LoadOwned(b []byte) (*NYTree, error) {
+	tree, err := load(b, true)
+	if err != nil {
+		wipeBytes(b)
+		return nil, err
 	}
 
-	buf.Write(t.rootSeed)
-	buf.Write(t.rootPubSeed)
+	return tree, nil
+}
 
-	for _, node := range t.nodes {
-		buf.Write(node.bytes())
+// Loads a tree from bytes, aliasing b if owned is set, see LoadOwned.
+func load(b []byte, owned bool) (*NYTree, error) {
+	if SelfTestOnLoad {
+		if err := selfTestOnce(); err != nil {
+			return nil, err
+		}
 	}
 
-	return buf.Bytes()
+	if !bytes.HasPrefix(b, []byte(stateMagic)) {
+		return loadLegacy(b, owned)
+	}
+
+	if len(b) < stateHeaderLen+sha256.Size {
+		return nil, ErrTreeInvalidInput
+	}
+	body := b[:len(b)-sha256.Size]
+	if checksum := sha256.Sum256(body); !bytes.Equal(checksum[:], b[len(body):]) {
+		return nil, ErrTreeChecksum
+	}
+
+	tree, flags, err := loadHead(body[:stateHeaderLen], (len(body)-stateHeaderLen)/(4+nodeByteLen))
+	if err != nil {
+		return nil, err
+	}
+	if owned {
+		tree.owned = b
+	}
+
+	offset := stateHeaderLen
+	if flags&flagExtended != 0 {
+		header, n, err := readFrame(body[offset:])
+		if err != nil {
+			return nil, err
+		}
+		if err := tree.loadHeaderFields(header); err != nil {
+			return nil, err
+		}
+		offset += n
+	}
+
+	for offset < len(body) {
+		frame, n, err := readFrame(body[offset:])
+		if err != nil {
+			return nil, &StateError{Node: len(tree.nodes), Offset: offset, Err: err}
+		}
+		node, err := tree.loadFrame(frame)
+		if err != nil {
+			return nil, &StateError{Node: len(tree.nodes), Offset: offset, Err: err}
+		}
+
+		tree.nodes = append(tree.nodes, node)
+		offset += n
+	}
+
+	tree.loadKeyParams()
+	if err := tree.expandNodes(); err != nil {
+		return nil, err
+	}
+	if err := tree.checkSpent(); err != nil {
+		return nil, err
+	}
+	if err := tree.validateState(); err != nil {
+		return nil, err
+	}
+
+	return tree, nil
 }
 
-// Loads an existing Naor-Yung chain tree from bytes.
-func Load(b []byte) (*NYTree, error) {
+// Decodes the head of a serialised tree in the current format: the magic
+// bytes, version, flags and seeds. Returns the tree, with room for the given
+// amount of nodes, and its flags.
+func loadHead(head []byte, nodes int) (*NYTree, byte, error) {
+	if head[len(stateMagic)] != stateVersion {
+		return nil, 0, ErrTreeVersion
+	}
+
+	flags := head[len(stateMagic)+1]
+	if flags&^(flagOTS|flagExtended|flagCompact) != 0 {
+		return nil, 0, ErrTreeInvalidInput
+	}
+
+	tree := &NYTree{state: state{
+		nodes:       make([]*nyNode, 0, nodes),
+		rootSeed:    make([]byte, 32),
+		rootPubSeed: make([]byte, 32),
+	}}
+	tree.ots = flags&flagOTS != 0
+	tree.compact = flags&flagCompact != 0
+	copy(tree.rootSeed, head[stateHeaderLen-64:])
+	copy(tree.rootPubSeed, head[stateHeaderLen-32:])
+
+	return tree, flags, nil
+}
+
+// Loads a tree serialised in the legacy format: flags || rootSeed ||
+// rootPubSeed || [uint32(len(header)) || header] || nodes, where every node
+// record is followed by uint32(len(fields)) || fields if flagNodeFields is set.
+// The nodes alias b if owned is set, see LoadOwned.
+func loadLegacy(b []byte, owned bool) (*NYTree, error) {
 	if len(b) < 65 {
 		return nil, ErrTreeInvalidInput
 	}
 
-	tree := &NYTree{
+	tree := &NYTree{state: state{
 		nodes:       make([]*nyNode, 0, (len(b)-65)/nodeByteLen),
 		rootSeed:    make([]byte, 32),
 		rootPubSeed: make([]byte, 32),
+	}}
+	if owned {
+		tree.owned = b
+	}
+
+	flags := b[0]
+	if flags&^knownFlags != 0 {
+		return nil, ErrTreeInvalidInput
 	}
 
-	tree.ots = b[0] == 0x01
+	tree.ots = flags&flagOTS != 0
 	copy(tree.rootSeed, b[1:33])
 	copy(tree.rootPubSeed, b[33:65])
 
-	for offset := 65; offset < len(b); {
-		node, bytesRead, err := loadNode(b[offset:])
-		if err != nil {
+	offset := 65
+	if flags&flagExtended != 0 {
+		if len(b) < offset+4 {
+			return nil, ErrTreeInvalidInput
+		}
+		length := binary.BigEndian.Uint32(b[offset:])
+		offset += 4
+
+		if uint64(length) > uint64(len(b)-offset) {
+			return nil, ErrTreeInvalidInput
+		}
+		if err := tree.loadHeaderFields(b[offset : offset+int(length)]); err != nil {
 			return nil, err
 		}
+		offset += int(length)
+	}
+
+	for offset < len(b) {
+		node, bytesRead, err := loadNode(b[offset:], flags&flagNodeFields != 0, owned)
+		if err != nil {
+			return nil, &StateError{Node: len(tree.nodes), Offset: offset, Err: err}
+		}
 
 		tree.nodes = append(tree.nodes, node)
 		offset += bytesRead
 	}
 
+	tree.loadKeyParams()
+	if err := tree.checkSpent(); err != nil {
+		return nil, err
+	}
+	if err := tree.validateState(); err != nil {
+		return nil, err
+	}
+
 	return tree, nil
 }
+
+// Enables or disables strict mode. By default, unconfirmed nodes whose txid
+// matches the txid of a signature can be used, so that all inputs of a
+// transaction are signed in the same subtree. In strict mode only confirmed
+// nodes are used, and signing fails with ErrTreeStrictUnconfirmed if only
+// unconfirmed nodes with matching txid are left.
+func (t *NYTree) SetStrict(strict bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.strict != strict {
+		t.strict = strict
+		t.epoch++
+	}
+}
+
+// Returns whether the tree t is in strict mode.
+func (t *NYTree) Strict() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.strict
+}
+
+// Returns whether the tree contains a node with the given txid.
+func (t *NYTree) hasTxid(txid []byte) bool {
+	return len(t.nodesByTxid(txid)) > 0
+}
+
+// Encodes the fields of the extended header of the serialised tree. Returns nil
+// if no fields need to be stored, in which case the legacy format is used.
+func (t *NYTree) headerFields() []byte {
+	buf := &bytes.Buffer{}
+
+	if t.epoch > 0 {
+		var epoch [8]byte
+		binary.BigEndian.PutUint64(epoch[:], t.epoch)
+		writeField(buf, fieldEpoch, epoch[:])
+	}
+
+	if len(t.consumed) > 0 {
+		writeField(buf, fieldConsumed, bytes.Join(t.consumedHashes(), nil))
+	}
+	if len(t.consumedSeeds) > 0 {
+		writeField(buf, fieldConsumedSeeds, bytes.Join(sortedDigests(t.consumedSeeds), nil))
+	}
+
+	if t.rootLocked {
+		writeField(buf, fieldRootLocked, nil)
+	}
+
+	if t.deterministic {
+		writeField(buf, fieldDeterministic, nil)
+	}
+
+	if len(t.branching) > 0 {
+		writeField(buf, fieldBranching, t.branching)
+	}
+
+	if t.strict {
+		writeField(buf, fieldStrict, nil)
+	}
+
+	if t.hardened {
+		writeField(buf, fieldHardened, nil)
+	}
+	if t.spent {
+		writeField(buf, fieldSpent, t.pubKey)
+	}
+
+	if t.frozen {
+		writeField(buf, fieldFrozen, nil)
+	}
+	if len(t.transitions) > 0 {
+		writeField(buf, fieldTransitions, encodeTransitions(t.transitions))
+	}
+
+	if t.counterBound {
+		writeField(buf, fieldCounter, encodeCounter(t.counter))
+	}
+
+	if t.addressed {
+		writeField(buf, fieldAddressed, nil)
+	}
+
+	if len(t.txidParents) > 0 {
+		writeField(buf, fieldTxidParents, t.encodeTxidParents())
+	}
+
+	if len(t.nodeParents) > 0 {
+		writeField(buf, fieldNodeParents, t.encodeNodeParents())
+	}
+
+	if t.maxDepth > 0 {
+		writeField(buf, fieldMaxDepth, encodeMaxDepth(t.maxDepth))
+	}
+
+	if t.txidPolicy != TxidInherit {
+		writeField(buf, fieldTxidPolicy, []byte{byte(t.txidPolicy)})
+	}
+
+	if t.selection != SelectDefault {
+		writeField(buf, fieldSelection, []byte{byte(t.selection)})
+	}
+
+	if t.owner != (OwnerID{}) {
+		writeField(buf, fieldOwner, t.encodeOwner())
+	}
+
+	if t.txidHashing {
+		writeField(buf, fieldTxidHashing, nil)
+	}
+
+	if t.verifyAfterSign {
+		writeField(buf, fieldVerifyAfterSign, nil)
+	}
+
+	if t.audit != nil {
+		writeField(buf, fieldAudit, t.encodeAudit())
+	}
+
+	if t.backups > 0 {
+		var backups [8]byte
+		binary.BigEndian.PutUint64(backups[:], t.backups)
+		writeField(buf, fieldBackups, backups[:])
+	}
+	if t.backupInfo != nil {
+		writeField(buf, fieldBackupInfo, t.backupInfo.encode())
+	}
+
+	if len(t.consumedBy) > 0 {
+		writeField(buf, fieldConsumedBy, t.encodeConsumedBy())
+	}
+
+	if reservations := t.encodeReservations(); reservations != nil {
+		writeField(buf, fieldReservations, reservations)
+	}
+
+	if t.revocable {
+		writeField(buf, fieldRevocation, t.encodeRevocation())
+	}
+
+	if len(t.revokedNodes) > 0 || t.revocationListSeq > 0 {
+		writeField(buf, fieldRevokedNodes, t.encodeRevokedNodes())
+	}
+
+	if t.providerSeeds {
+		writeField(buf, fieldSeedProvider, t.pubKey)
+	}
+
+	if p, ok := t.paramSet(); ok && p.ID != ParamsDefault {
+		writeField(buf, fieldParamSet, encodeParamSetID(p.ID))
+	} else {
+		if t.suite != 0 {
+			writeField(buf, fieldHashSuite, []byte{byte(t.suite)})
+		}
+
+		if t.w != 0 {
+			var w [2]byte
+			binary.BigEndian.PutUint16(w[:], t.w)
+			writeField(buf, fieldWinternitz, w[:])
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// Loads the fields of the extended header of a serialised tree.
+func (t *NYTree) loadHeaderFields(b []byte) error {
+	// The parameter set replaces the hash suite and Winternitz fields
+	var paramSet, keyFields bool
+	err := readFields(b, func(tag byte, value []byte) error {
+		switch tag {
+		case fieldConsumed:
+			consumed, err := loadDigests(value)
+			if err != nil {
+				return err
+			}
+			t.consumed = consumed
+		case fieldConsumedSeeds:
+			consumedSeeds, err := loadDigests(value)
+			if err != nil {
+				return err
+			}
+			t.consumedSeeds = consumedSeeds
+		case fieldEpoch:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			t.epoch = binary.BigEndian.Uint64(value)
+		case fieldRootLocked:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.rootLocked = true
+		case fieldDeterministic:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.deterministic = true
+		case fieldBranching:
+			if err := validBranching(value); err != nil {
+				return err
+			}
+			t.branching = append([]uint8(nil), value...)
+		case fieldStrict:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.strict = true
+		case fieldHardened:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.hardened = true
+		case fieldSpent:
+			t.spent = true
+			t.pubKey = append([]byte(nil), value...)
+		case fieldFrozen:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.frozen = true
+		case fieldTransitions:
+			transitions, err := decodeTransitions(value)
+			if err != nil {
+				return err
+			}
+			t.transitions = transitions
+		case fieldCounter:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			t.counterBound = true
+			t.counter = binary.BigEndian.Uint64(value)
+		case fieldAddressed:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.addressed = true
+		case fieldHashSuite:
+			if len(value) != 1 || value[0] == byte(SuiteSHA256) {
+				return ErrFieldInvalid
+			}
+			if _, err := HashSuite(value[0]).lookup(); err != nil {
+				return err
+			}
+			t.suite = HashSuite(value[0])
+			keyFields = true
+		case fieldWinternitz:
+			if len(value) != 2 {
+				return ErrFieldInvalid
+			}
+			w := binary.BigEndian.Uint16(value)
+			if validWinternitz(int(w)) != nil || w == DefaultWinternitz {
+				return ErrFieldInvalid
+			}
+			t.w = w
+			keyFields = true
+		case fieldParamSet:
+			p, err := decodeParamSetID(value)
+			if err != nil {
+				return err
+			}
+			if t.suite, t.w, err = p.keyParams(); err != nil {
+				return err
+			}
+			paramSet = true
+		case fieldTxidParents:
+			return t.loadTxidParents(value)
+		case fieldNodeParents:
+			return t.loadNodeParents(value)
+		case fieldMaxDepth:
+			if len(value) != 4 || binary.BigEndian.Uint32(value) == 0 {
+				return ErrFieldInvalid
+			}
+			t.maxDepth = binary.BigEndian.Uint32(value)
+		case fieldTxidPolicy:
+			if len(value) != 1 || value[0] == byte(TxidInherit) || value[0] > byte(TxidParentPkh) {
+				return ErrFieldInvalid
+			}
+			t.txidPolicy = TxidPolicy(value[0])
+		case fieldSelection:
+			if len(value) != 1 || value[0] == byte(SelectDefault) || value[0] > byte(SelectOldestConfirmed) {
+				return ErrFieldInvalid
+			}
+			t.selection = Selection(value[0])
+		case fieldOwner:
+			return t.loadOwner(value)
+		case fieldTxidHashing:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.txidHashing = true
+		case fieldVerifyAfterSign:
+			if len(value) != 0 {
+				return ErrFieldInvalid
+			}
+			t.verifyAfterSign = true
+		case fieldAudit:
+			return t.loadAudit(value)
+		case fieldBackups:
+			if len(value) != 8 {
+				return ErrFieldInvalid
+			}
+			t.backups = binary.BigEndian.Uint64(value)
+		case fieldBackupInfo:
+			info, err := decodeBackupInfo(value)
+			if err != nil {
+				return err
+			}
+			t.backupInfo = info
+		case fieldConsumedBy:
+			return t.loadConsumedBy(value)
+		case fieldReservations:
+			return t.loadReservations(value)
+		case fieldRevocation:
+			return t.loadRevocation(value)
+		case fieldRevokedNodes:
+			return t.loadRevokedNodes(value)
+		case fieldSeedProvider:
+			t.providerSeeds = true
+			t.pubKey = append([]byte(nil), value...)
+		default:
+			return ErrFieldInvalid
+		}
+
+		return nil
+	})
+	if err == nil && paramSet && keyFields {
+		err = ErrFieldInvalid
+	}
+
+	return err
+}
@@ -3,10 +3,16 @@ package xnyss
 import (
 	"crypto/rand"
 	"crypto/sha256"
+	"errors"
 	"testing"
 	"fmt"
 	wotsp "github.com/Re0h/xnyss/wotsp256"
+	"github.com/Re0h/xnyss/testdata"
 	"bytes"
+	"encoding/binary"
+	"math"
+	mrand "math/rand"
+	"time"
 )
 
 func genSeeds() (seed, pubs []byte, err error) {
@@ -109,6 +115,13 @@ func TestNYTree_Sign(t *testing.T) {
 	if !foundMatch {
 		t.Fatal("Invalid public key generated from second signature")
 	}
+
+	// 5 - Child nodes keep the private key expanded for their public key
+	for _, node := range tree.nodes {
+		if node.key == nil {
+			t.Fatal("Child node did not keep its expanded private key")
+		}
+	}
 }
 
 func TestNYTree_Confirm(t *testing.T) {
@@ -131,6 +144,43 @@ func TestNYTree_Confirm(t *testing.T) {
 	}
 }
 
+func TestNYTree_ConfirmMonotonic(t *testing.T) {
+	required := ConfirmsRequired
+	ConfirmsRequired = 3
+	defer func() { ConfirmsRequired = required }()
+
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("monotonic confirm test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkh := sig.ChildHashes[0]
+	confirms := func() uint8 {
+		for _, node := range tree.Nodes() {
+			if bytes.Equal(node.PubKeyHash, pkh) {
+				return node.Confirms
+			}
+		}
+		return 0
+	}
+
+	// 1 - Pending counts increase, but are not lowered by late reports
+	tree.Confirm(pkh, 2)
+	tree.Confirm(pkh, 1)
+	if confirms() != 2 {
+		t.Fatal("Confirm lowered a pending count to", confirms())
+	}
+
+	// 2 - SetConfirms overrides the count
+	if !tree.SetConfirms(pkh, 1) || confirms() != 1 {
+		t.Fatal("Failed to lower the count")
+	}
+}
+
 func TestNYTree_Unconfirmed(t *testing.T) {
 	seed, pubSeed, err := genSeeds()
 	if err != nil {
@@ -216,10 +266,11 @@ func TestNYTree_Bytes(t *testing.T) {
 	}
 	tree := New(seed, pubSeed, false)
 
-	// Serialise empty tree
+	// Serialise empty tree, whose extended header records addressing
 	empty := tree.Bytes()
-	if empty[0] != 0x00 || !bytes.Equal(tree.rootSeed, empty[1:33]) ||
-		!bytes.Equal(tree.rootPubSeed, empty[33:65]) {
+	if string(empty[:4]) != stateMagic || empty[4] != stateVersion || empty[5] != flagExtended ||
+		!bytes.Equal(tree.rootSeed, empty[6:38]) ||
+		!bytes.Equal(tree.rootPubSeed, empty[38:70]) {
 		t.Fatal("Serialisation of empty tree failed")
 	}
 
@@ -240,20 +291,119 @@ func TestNYTree_Bytes(t *testing.T) {
 
 	// Check serialisation
 	treeBytes := tree.Bytes()
-	if !bytes.Equal(treeBytes[1:33], tree.rootSeed) ||
-		!bytes.Equal(treeBytes[33:65], tree.rootPubSeed) {
+	if !bytes.Equal(treeBytes[6:38], tree.rootSeed) ||
+		!bytes.Equal(treeBytes[38:70], tree.rootPubSeed) {
 		t.Fatal("Invalid seeds")
 	}
 
-	offset := 65
-	for _, node := range tree.nodes {
-		if !bytes.Equal(node.privSeed, treeBytes[offset:offset+32]) ||
+	// Both consumed nodes are recorded in the extended header, by public key
+	// hash and by seed digest, as well as the txid of the second signature and
+	// that of its signer, the parent of the second signer, and addressing
+	if treeBytes[5] != flagExtended {
+		t.Fatal("Extended header flag was not set")
+	}
+	headerLen := int(binary.BigEndian.Uint32(treeBytes[70:74]))
+	if headerLen != 5+8+5+2*32+5+2*32+5+5+64+5+64+5 || treeBytes[74] != fieldEpoch {
+		t.Fatal("Invalid extended header")
+	}
+
+	offset := 74 + headerLen
+	for _, node := range tree.canonicalNodes() {
+		// Every node is framed, and starts with the node record
+		frameLen := int(binary.BigEndian.Uint32(treeBytes[offset:]))
+		offset += 4
+		if frameLen < 97 ||
+			!bytes.Equal(node.privSeed, treeBytes[offset:offset+32]) ||
 			!bytes.Equal(node.pubSeed, treeBytes[offset+32:offset+64]) ||
 			!bytes.Equal(node.txid, treeBytes[offset+64:offset+96]) ||
 			node.confirms != treeBytes[offset+96] {
 			t.Fatal("Invalid serialized node")
 		}
-		offset += 97
+		offset += frameLen
+	}
+	if offset != len(treeBytes)-32 {
+		t.Fatal("Trailing bytes after serialized nodes")
+	}
+	checksum := sha256.Sum256(treeBytes[:offset])
+	if !bytes.Equal(checksum[:], treeBytes[offset:]) {
+		t.Fatal("Invalid checksum")
+	}
+}
+
+func TestNYTree_BytesCanonical(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, _, err := signMessage("canonical", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// The order of the nodes in memory does not change the serialised tree
+	state := tree.Bytes()
+	for i, j := 0, len(tree.nodes)-1; i < j; i, j = i+1, j-1 {
+		tree.nodes[i], tree.nodes[j] = tree.nodes[j], tree.nodes[i]
+	}
+	if !bytes.Equal(state, tree.Bytes()) {
+		t.Fatal("Serialised tree depends on the order of its nodes")
+	}
+
+	loaded, err := Load(state)
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !bytes.Equal(state, loaded.Bytes()) {
+		t.Fatal("Loaded tree is serialised differently")
+	}
+}
+
+// Recomputes the checksum of a serialised tree after it was modified.
+func reseal(state []byte) {
+	checksum := sha256.Sum256(state[:len(state)-32])
+	copy(state[len(state)-32:], checksum[:])
+}
+
+func TestLoad_Checksum(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, _, err := signMessage("first signature", tree); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	state := tree.Bytes()
+
+	// 1 - Corrupted states are detected
+	for _, offset := range []int{5, 20, stateHeaderLen + 10, len(state) - 1} {
+		corrupted := append([]byte(nil), state...)
+		corrupted[offset] ^= 0x01
+		if _, err := Load(corrupted); err != ErrTreeChecksum {
+			t.Fatal("Loaded state corrupted at offset", offset, "err was", err)
+		}
+	}
+	if _, err := Load(state[:len(state)-1]); err != ErrTreeChecksum {
+		t.Fatal("Loaded truncated state, err was", err)
+	}
+
+	// 2 - Unknown versions are refused
+	future := append([]byte(nil), state...)
+	future[4] = stateVersion + 1
+	reseal(future)
+	if _, err := Load(future); err != ErrTreeVersion {
+		t.Fatal("Loaded state with unknown version, err was", err)
+	}
+
+	// 3 - Frames must hold whole nodes
+	offset := stateHeaderLen + 4 + int(binary.BigEndian.Uint32(state[stateHeaderLen:]))
+	short := append(append([]byte(nil), state[:offset+4+96]...), make([]byte, 32)...)
+	binary.BigEndian.PutUint32(short[offset:], 96)
+	reseal(short)
+	var stateErr *StateError
+	if _, err := Load(short); !errors.As(err, &stateErr) || stateErr.Err != ErrNodeInvalidInput ||
+		stateErr.Node != 0 || stateErr.Offset != offset {
+		t.Fatal("Loaded state with a truncated node frame, err was", err)
 	}
 }
 
@@ -325,35 +475,299 @@ func TestOneTime(t *testing.T) {
 		t.Fatal("0 nodes should be available, is", tree.Available(nil))
 	}
 
+	if !tree.Exhausted() {
+		t.Fatal("Used one-time tree is not exhausted")
+	}
 	_, _, err = signMessage("Sign test with node 1", tree)
-	if err != ErrTreeNoneAvailable {
-		t.Fatal("Signing should have failed with ErrTreeNoneAvailable, err was", err)
+	if err != ErrTreeExhausted {
+		t.Fatal("Signing should have failed with ErrTreeExhausted, err was", err)
 	}
 }
 
-func benchmarkSign(n int, ots bool, b *testing.B) {
-	b.ReportAllocs()
+func TestNYTree_Validate(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	// The seed of the root is wiped once it signed, so keep a copy
+	root := *tree.nodes[0]
+	root.privSeed = append([]byte(nil), root.privSeed...)
+
+	_, txid, err := signMessage("first signature test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+	if err := tree.Validate(); err != nil {
+		t.Fatal("Valid tree failed validation -", err)
+	}
+
+	// 1 - Duplicate a child node
+	dup := *tree.nodes[0]
+	tree.nodes = append(tree.nodes, &dup)
+	if err := tree.Validate(); err != ErrTreeDuplicateSeed {
+		t.Fatal("Duplicate seeds were not detected, err was", err)
+	}
+	tree.nodes = tree.nodes[:len(tree.nodes)-1]
+
+	// 2 - Reintroduce the consumed root node
+	root.txid = txid
+	tree.nodes = append([]*nyNode{&root}, tree.nodes...)
+	tree.dropIndex()
+	if err := tree.Validate(); err != ErrTreeSeedReuse {
+		t.Fatal("Seed reuse was not detected, err was", err)
+	}
 
+	// 3 - The seeds of consumed nodes are persisted, so reuse is detected
+	// after a reload
+	if _, err := Load(tree.Bytes()); !errors.Is(err, ErrTreeSeedReuse) {
+		t.Fatal("Seed reuse was not detected after reload, err was", err)
+	}
+
+	// 4 - Sign quarantines the consumed node, and signs with another one. The
+	// root is locked after signing, override the lock to reach the check.
+	tree.UnlockRoot()
+	msgHash := sha256.Sum256([]byte("second signature test"))
+	sig, err := tree.Sign(msgHash[:], txid)
+	if err != nil {
+		t.Fatal("Failed to sign past the consumed node -", err)
+	}
+	if signer, err := sig.PublicKeyHash(); err != nil || bytes.Equal(signer, root.pubKeyHash()) ||
+		tree.nodeByPkh(root.pubKeyHash()) != nil {
+		t.Fatal("Consumed node was not quarantined")
+	}
+	if err := tree.Validate(); err != nil {
+		t.Fatal("Tree invalid after quarantine -", err)
+	}
+
+	// 5 - If no other node is available, the reason is returned
+	ots := New(seed, pubSeed, true)
+	ots.consumedSeeds = map[[32]byte]bool{ots.nodes[0].seedDigest(): true}
+	if _, err := ots.Sign(msgHash[:], nil); err != ErrTreeSeedReuse {
+		t.Fatal("Signing with a consumed node should have failed, err was", err)
+	}
+	if len(ots.nodes) != 0 {
+		t.Fatal("Consumed node was not quarantined")
+	}
+}
+
+func TestNYTree_MarkConsumed(t *testing.T) {
 	seed, pubSeed, err := genSeeds()
 	if err != nil {
-		b.Fatal("Failed to generate seeds -", err)
+		t.Fatal(err)
 	}
+	tree := New(seed, pubSeed, false)
 
-	s := sha256.New()
-	s.Write([]byte("a message to sign"))
-	msgHash := s.Sum(nil)
+	sig, _, err := signMessage("first signature test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
 
-	txid := make([]byte, 32)
-	_, err = rand.Read(txid)
+	// 1 - Create an older state, and let the current state consume a node
+	old, err := Load(tree.Bytes())
 	if err != nil {
-		b.Fatal("Failed to generate txid -", err)
+		t.Fatal("Failed to load tree -", err)
+	}
+	if len(old.Consumed()) != 1 {
+		t.Fatal("Consumed nodes were not loaded, got", len(old.Consumed()))
 	}
 
-	b.ResetTimer()
+	if _, _, err = signMessage("second signature test", tree); err != nil {
+		t.Fatal("Failed to sign second msg -", err)
+	}
+	if len(tree.Consumed()) != 2 {
+		t.Fatal(len(tree.Consumed()), "consumed nodes, should be 2")
+	}
+
+	// 2 - The older state learns which nodes the newer one consumed
+	if removed := old.MarkConsumed(tree.Consumed()); removed != 1 {
+		t.Fatal(removed, "nodes removed from old state, should be 1")
+	}
+	if old.Available(nil) != Branches-1 {
+		t.Fatal(old.Available(nil), "nodes available, should be", Branches-1)
+	}
+	if removed := old.MarkConsumed(tree.Consumed()); removed != 0 {
+		t.Fatal(removed, "nodes removed when marking again, should be 0")
+	}
+
+	// 3 - Sign quarantines nodes that are recorded as consumed, and signs
+	// with another one
+	for _, node := range old.nodes[1:] {
+		old.consumed[pkhKey(node)] = true
+	}
+	stale := old.nodes[0].pubKeyHash()
+	sig, _, err = signMessage("third signature test", old)
+	if err != nil {
+		t.Fatal("Failed to sign past the consumed nodes -", err)
+	}
+	if signer, err := sig.PublicKeyHash(); err != nil || !bytes.Equal(signer, stale) {
+		t.Fatal("Signed with a consumed node")
+	}
+	for _, node := range old.nodes {
+		old.consumed[pkhKey(node)] = true
+	}
+	if _, _, err = signMessage("fourth signature test", old); err != ErrTreeNodeConsumed {
+		t.Fatal("Signing with consumed nodes should have failed, err was", err)
+	}
+}
+
+func TestNYTree_Epoch(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if tree.Epoch() != 0 {
+		t.Fatal("New tree has epoch", tree.Epoch())
+	}
+
+	sig, _, err := signMessage("first signature test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+	snapshot := tree.Bytes()
+
+	// 1 - Confirming changes the epoch, confirming again does not
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	epoch := tree.Epoch()
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if epoch != 2 || tree.Epoch() != epoch {
+		t.Fatal("Invalid epoch after confirming", tree.Epoch())
+	}
+
+	// 2 - The snapshot is detected as stale
+	snapshotEpoch, err := StateEpoch(snapshot)
+	if err != nil {
+		t.Fatal("Failed to read snapshot epoch -", err)
+	}
+	if snapshotEpoch != 1 {
+		t.Fatal("Snapshot has epoch", snapshotEpoch, "should be 1")
+	}
+	corrupt := append([]byte(nil), snapshot...)
+	corrupt[len(corrupt)-1] ^= 1
+	if _, err := StateEpoch(corrupt); err != ErrTreeChecksum {
+		t.Fatal("Read epoch of corrupt snapshot, err was", err)
+	}
+	if epoch, err := StateEpoch(New(seed, pubSeed, false).Bytes()); err != nil || epoch != 0 {
+		t.Fatal("Invalid epoch of fresh state", epoch, err)
+	}
+
+	old, _ := Load(snapshot)
+	if cmp, err := old.CompareEpoch(tree); err != nil || cmp != -1 {
+		t.Fatal("Snapshot was not detected as older", cmp, err)
+	}
+	if cmp, err := tree.CompareEpoch(old); err != nil || cmp != 1 {
+		t.Fatal("Current state was not detected as newer", cmp, err)
+	}
+
+	// 3 - States of different trees cannot be compared
+	if _, err := tree.CompareEpoch(New(pubSeed, seed, false)); err != ErrTreeMismatch {
+		t.Fatal("Comparing different trees should fail, err was", err)
+	}
+}
+
+func TestNYTree_SetStrict(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetStrict(true)
+
+	sig, txid, err := signMessage("first signature test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+
+	// 1 - Unconfirmed nodes with the same txid are not used in strict mode
+	if tree.Available(txid) != 0 {
+		t.Fatal(tree.Available(txid), "nodes available in strict mode, should be 0")
+	}
+	msgHash := sha256.Sum256([]byte("second signature test"))
+	if _, err := tree.Sign(msgHash[:], txid); err != ErrTreeStrictUnconfirmed {
+		t.Fatal("Signing with unconfirmed node should have failed, err was", err)
+	}
+
+	// 2 - Strict mode is persisted, and confirmed nodes can be used
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !loaded.Strict() {
+		t.Fatal("Strict mode was not persisted")
+	}
+	loaded.Confirm(sig.ChildHashes[1], ConfirmsRequired)
+	if _, err := loaded.Sign(msgHash[:], txid); err != nil {
+		t.Fatal("Failed to sign with confirmed node -", err)
+	}
+
+	// 3 - Disabling strict mode restores txid-based availability
+	loaded.SetStrict(false)
+	if loaded.Available(txid) != Branches-1+Branches {
+		t.Fatal(loaded.Available(txid), "nodes available, should be", 2*Branches-1)
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	ProfileLabels = true
+	defer func() {
+		ProfileLabels = false
+		OpAccounting = nil
+	}()
+
+	ops := make(map[string]string)
+	OpAccounting = func(op, treeID string, elapsed time.Duration) {
+		ops[op] = treeID
+	}
+
+	tree := New(testdata.Seed, testdata.PubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if _, err := sig.PublicKey(); err != nil {
+		t.Fatal("Failed to compute public key -", err)
+	}
+
+	if ops[OpSign] != tree.ID() || ops[OpConfirm] != tree.ID() {
+		t.Fatal("Operations were not accounted with the tree ID", ops)
+	}
+	if id, ok := ops[OpVerify]; !ok || id != "" {
+		t.Fatal("Verification was not accounted correctly", ops)
+	}
+}
+
+// Signs messages with the same txid until the tree holds at least size nodes.
+// Since every signature replaces one node with Branches new ones, this builds a
+// tree of (roughly) the requested size using only the fixed benchmark inputs.
+// The children are generated from a fixed entropy source, so benchmarks use the
+// same trees on every run.
+func growTree(size int, b *testing.B) *NYTree {
+	tree := New(testdata.Seed, testdata.PubSeed, false)
+	tree.SetEntropy(mrand.New(mrand.NewSource(1)))
+	for len(tree.nodes) < size {
+		if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+			b.Fatal("Failed to grow tree -", err)
+		}
+	}
+
+	return tree
+}
+
+func benchmarkSign(n int, ots bool, b *testing.B) {
+	b.ReportAllocs()
+
 	for i := 0; i < b.N; i++ {
-		tree := New(seed, pubSeed, ots)
+		b.StopTimer()
+		tree := New(testdata.Seed, testdata.PubSeed, ots)
+		tree.SetEntropy(mrand.New(mrand.NewSource(1)))
+		b.StartTimer()
 		for i := 0; i < n; i++ {
-			_, _ = tree.Sign(msgHash, txid)
+			_, _ = tree.Sign(testdata.Message, testdata.Txid)
 		}
 	}
 }
@@ -380,31 +794,103 @@ func BenchmarkSign1000(b *testing.B) {
 }
 */
 
-func BenchmarkKeyGen(b *testing.B) {
+// Signs once with a tree of size nodes. Every iteration signs with a fresh copy
+// of the same tree, so the tree does not grow with b.N.
+func benchmarkSignTree(size int, b *testing.B) {
+	state := growTree(size, b).Bytes()
+
 	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		tree, err := Load(state)
+		if err != nil {
+			b.Fatal("Failed to load tree -", err)
+		}
+		tree.SetEntropy(mrand.New(mrand.NewSource(2)))
+		b.StartTimer()
+		_, _ = tree.Sign(testdata.Message, testdata.Txid)
+	}
+}
 
-	seed, pubSeed, err := genSeeds()
-	if err != nil {
-		b.Fatal("Failed to generate seeds")
+func BenchmarkSignTree10(b *testing.B) {
+	benchmarkSignTree(10, b)
+}
+
+func BenchmarkSignTree100(b *testing.B) {
+	benchmarkSignTree(100, b)
+}
+
+// Confirms a pkh that is not present in the tree, which is the worst case since
+// the public key of every unconfirmed node has to be computed.
+func benchmarkConfirm(size int, b *testing.B) {
+	tree := growTree(size, b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Confirm(testdata.Message, ConfirmsRequired)
 	}
+}
+
+func BenchmarkConfirm10(b *testing.B) {
+	benchmarkConfirm(10, b)
+}
 
+func BenchmarkConfirm100(b *testing.B) {
+	benchmarkConfirm(100, b)
+}
+
+func benchmarkUnconfirmed(size int, b *testing.B) {
+	tree := growTree(size, b)
+
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		tree := New(seed, pubSeed, true)
-		_ = tree.PublicKey()
+		_ = tree.Unconfirmed()
 	}
 }
 
-func BenchmarkPkFromSig(b *testing.B) {
+func BenchmarkUnconfirmed10(b *testing.B) {
+	benchmarkUnconfirmed(10, b)
+}
+
+func BenchmarkUnconfirmed100(b *testing.B) {
+	benchmarkUnconfirmed(100, b)
+}
+
+func benchmarkBytes(size int, b *testing.B) {
+	tree := growTree(size, b)
+
 	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = tree.Bytes()
+	}
+}
 
-	seed, pubSeed, err := genSeeds()
-	if err != nil {
-		b.Fatal("Failed to generate seeds -", err)
+func BenchmarkBytes100(b *testing.B) {
+	benchmarkBytes(100, b)
+}
+
+func BenchmarkBytes1000(b *testing.B) {
+	benchmarkBytes(1000, b)
+}
+
+func BenchmarkKeyGen(b *testing.B) {
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		tree := New(testdata.Seed, testdata.PubSeed, true)
+		_ = tree.PublicKey()
 	}
-	tree := New(seed, pubSeed, true)
+}
+
+func BenchmarkPkFromSig(b *testing.B) {
+	b.ReportAllocs()
 
-	sig, _, err := signMessage("a message to sign", tree)
+	tree := New(testdata.Seed, testdata.PubSeed, true)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
 	if err != nil {
 		b.Fatal("Failed to sign message -", err)
 	}
@@ -414,3 +900,511 @@ func BenchmarkPkFromSig(b *testing.B) {
 		_, _ = sig.PublicKey()
 	}
 }
+
+func TestTxid(t *testing.T) {
+	// 1 - Namespaces are separated from request IDs
+	if bytes.Equal(Txid("a", []byte("bc")), Txid("ab", []byte("c"))) {
+		t.Fatal("Txids of different namespaces collide")
+	}
+	if !bytes.Equal(Txid("app", []byte("req")), Txid("app", []byte("req"))) {
+		t.Fatal("Txid is not deterministic")
+	}
+
+	// 2 - Sign only accepts txids of TxidLen bytes
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, err := tree.Sign(testdata.Message, []byte("request-1")); err != ErrInvalidTxidLen {
+		t.Fatal("Signed with invalid txid, err was", err)
+	}
+	if _, err := tree.Sign(testdata.Message, Txid("app", []byte("request-1"))); err != nil {
+		t.Fatal("Failed to sign with derived txid -", err)
+	}
+}
+
+func TestNYTree_SetTxidHashing(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	id := []byte("request-1")
+
+	// 1 - Without txid hashing, other lengths are rejected everywhere
+	if _, err := tree.Reserve(id); err != ErrInvalidTxidLen {
+		t.Fatal("Reserved with invalid txid, err was", err)
+	}
+
+	// 2 - With txid hashing, identifiers of other lengths are hashed
+	tree.SetTxidHashing(true)
+	if _, err := tree.Sign(testdata.Message, nil); err != ErrInvalidTxidLen {
+		t.Fatal("Signed with empty txid, err was", err)
+	}
+	sig, err := tree.Sign(testdata.Message, id)
+	if err != nil {
+		t.Fatal("Failed to sign with hashed txid -", err)
+	}
+	for _, node := range tree.Nodes() {
+		if !bytes.Equal(node.Txid, HashTxid(id)) || node.TxidLen != len(id) {
+			t.Fatal("Node does not record the hashed txid", node.Txid, node.TxidLen)
+		}
+	}
+	if tree.Available(id) != len(sig.ChildHashes) {
+		t.Fatal("Nodes of hashed txid are not available for the identifier")
+	}
+
+	// 3 - The setting and recorded lengths are serialised
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.TxidHashing() || loaded.Nodes()[0].TxidLen != len(id) {
+		t.Fatal("Txid hashing not serialised")
+	}
+	if _, err := loaded.Sign(testdata.Message, testdata.Txid); err != ErrTreeNoneAvailable {
+		t.Fatal("Signed with unconfirmed nodes of another txid, err was", err)
+	}
+}
+
+func TestSignature_Envelope(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetBranching([]int{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// 1 - Signatures created by Sign know their parameters
+	params, ok := sig.Params()
+	if !ok || params.Branches != 2 || params.ConfirmsRequired != ConfirmsRequired {
+		t.Fatal("Invalid signature parameters", params)
+	}
+
+	// 2 - The envelope includes the parameters, the plain encoding does not
+	parsed, err := ParseEnvelope(sig.Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if p, ok := parsed.Params(); !ok || p != params {
+		t.Fatal("Parameters were not included in the envelope")
+	}
+	if !bytes.Equal(parsed.Bytes(), sig.Bytes()) {
+		t.Fatal("Envelope does not contain the signature")
+	}
+
+	plain, err := NewSignature(sig.Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := plain.Params(); ok {
+		t.Fatal("Parameters known for plain signature encoding")
+	}
+	if _, err := ParseEnvelope(plain.Envelope(), testdata.Message); err != nil {
+		t.Fatal("Failed to parse envelope without parameters -", err)
+	}
+}
+
+func TestSignature_EnvelopeVersion(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	defer func(floor uint8) { MinEnvelopeVersion = floor }(MinEnvelopeVersion)
+
+	// 1 - Envelopes carry their version, unknown versions are refused
+	envelope := sig.Envelope()
+	if envelope[0] != EnvelopeVersion {
+		t.Fatal("Envelope does not start with its version")
+	}
+	future := append([]byte{EnvelopeVersion + 1}, envelope[1:]...)
+	if _, err := ParseEnvelope(future, testdata.Message); err != ErrEnvelopeVersion {
+		t.Fatal("Parsed envelope of unknown version, err was", err)
+	}
+	if _, err := sig.EnvelopeVersion(OldestEnvelopeVersion - 1); err != ErrEnvelopeVersion {
+		t.Fatal("Encoded envelope of unknown version, err was", err)
+	}
+
+	// 2 - Peers agree on the newest common version
+	v, err := NegotiateEnvelope([]uint8{EnvelopeVersion + 1, OldestEnvelopeVersion})
+	if err != nil || v != OldestEnvelopeVersion {
+		t.Fatal("Negotiated version", v, "err was", err)
+	}
+	if _, err := NegotiateEnvelope([]uint8{EnvelopeVersion + 1}); err != ErrEnvelopeVersion {
+		t.Fatal("Negotiated a version the peer does not support, err was", err)
+	}
+
+	// 3 - Versions below the floor are refused
+	MinEnvelopeVersion = EnvelopeVersion + 1
+	if _, err := ParseEnvelope(envelope, testdata.Message); err != ErrEnvelopeVersion {
+		t.Fatal("Parsed envelope below the version floor, err was", err)
+	}
+	if len(EnvelopeVersions()) != 0 {
+		t.Fatal("Versions below the floor are supported")
+	}
+	if _, err := NegotiateEnvelope([]uint8{EnvelopeVersion}); err != ErrEnvelopeVersion {
+		t.Fatal("Negotiated a version below the floor, err was", err)
+	}
+}
+
+func TestEncodedLen(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Lengths of a fresh tree and its signature, where StateLen is exact for
+	// legacy trees
+	if StateLen(1) > len(tree.Bytes()) || tree.EncodedLen() != len(tree.Bytes()) {
+		t.Fatal("Invalid length of fresh tree state")
+	}
+	if legacy := newLegacy(seed, pubSeed, false); StateLen(1) != len(legacy.Bytes()) {
+		t.Fatal("Invalid length of fresh legacy tree state")
+	}
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if SignatureLen(Branches) != len(sig.Bytes()) || EnvelopeLen(Branches, true)+EnvelopePositionLen != len(sig.Envelope()) ||
+		sig.EncodedLen() != len(sig.Bytes()) {
+		t.Fatal("Invalid signature length")
+	}
+
+	// 2 - Lengths including header and node fields
+	tree.SetLabel(sig.ChildHashes[0], "label")
+	if tree.EncodedLen() != len(tree.Bytes()) {
+		t.Fatal("Invalid length of tree state", tree.EncodedLen(), len(tree.Bytes()))
+	}
+
+	plain, err := NewSignature(sig.Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if EnvelopeLen(Branches, false) != len(plain.Envelope()) {
+		t.Fatal("Invalid length of envelope without parameters")
+	}
+}
+
+func TestMaxSignatures(t *testing.T) {
+	// 1 - Every node up to the maximum depth signs once
+	cases := []struct {
+		depth    uint32
+		branches int
+		max      uint64
+	}{
+		{0, 4, 1}, {2, 2, 7}, {3, 1, 4}, {2, 0, 1}, {62, 2, 1<<63 - 1},
+		{63, 2, math.MaxUint64}, {100, 2, math.MaxUint64}, {math.MaxUint32, 1, 1 << 32},
+	}
+	for i, c := range cases {
+		if max := MaxSignatures(c.depth, c.branches); max != c.max {
+			t.Fatal("Case", i, "- expected", c.max, "signatures, got", max)
+		}
+	}
+
+	// 2 - Trees with a maximum depth create exactly that many signatures
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetMaxDepth(1)
+	var n uint64
+	for ; ; n++ {
+		sig, err := tree.Sign(testdata.Message, Txid("max", []byte{byte(n)}))
+		if err != nil {
+			break
+		}
+		for _, pkh := range sig.ChildHashes {
+			tree.Confirm(pkh, ConfirmsRequired)
+		}
+	}
+	if n != MaxSignatures(1, Branches) {
+		t.Fatal("Expected", MaxSignatures(1, Branches), "signatures, got", n)
+	}
+}
+
+func TestSignature_Canonical(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Child hashes are signed in ascending order
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if !sig.Canonical() {
+		t.Fatal("Signature is not canonical")
+	}
+
+	// 2 - Reordered or duplicated child hashes are rejected
+	b := sig.Bytes()
+	children := b[SignatureLen(0):]
+	reordered := append([]byte(nil), b[:SignatureLen(0)]...)
+	reordered = append(reordered, children[32:64]...)
+	reordered = append(reordered, children[:32]...)
+	reordered = append(reordered, children[64:]...)
+	if _, err := NewSignature(reordered, testdata.Message); err != ErrSigNotCanonical {
+		t.Fatal("Decoded reordered child hashes, err was", err)
+	}
+
+	duplicated := append(append([]byte(nil), b...), children[len(children)-32:]...)
+	if _, err := NewSignature(duplicated, testdata.Message); err != ErrSigNotCanonical {
+		t.Fatal("Decoded duplicated child hashes, err was", err)
+	}
+}
+
+func TestNewSignature_MaxChildHashes(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	defer func(max int) { MaxChildHashes = max }(MaxChildHashes)
+
+	// 1 - Signatures with up to the maximum amount of child hashes decode
+	MaxChildHashes = len(sig.ChildHashes)
+	if _, err := NewSignature(sig.Bytes(), testdata.Message); err != nil {
+		t.Fatal("Failed to decode signature -", err)
+	}
+
+	// 2 - Signatures with more child hashes are rejected
+	MaxChildHashes = len(sig.ChildHashes) - 1
+	if _, err := NewSignature(sig.Bytes(), testdata.Message); err != ErrSigTooManyChildren {
+		t.Fatal("Decoded signature exceeding the maximum, err was", err)
+	}
+	huge := make([]byte, SignatureLen(0)+32*(1<<16))
+	if _, err := NewSignature(huge, testdata.Message); err != ErrSigTooManyChildren {
+		t.Fatal("Decoded huge signature, err was", err)
+	}
+}
+
+func TestNYTree_Wipe(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if _, _, err := signMessage("wipe test", tree); err != nil {
+		t.Fatal(err)
+	}
+	nodes := append([]*nyNode(nil), tree.nodes...)
+
+	// 1 - Seeds are wiped, and the tree reports it
+	tree.Wipe()
+	if !tree.Wiped() {
+		t.Fatal("Tree does not report being wiped")
+	}
+	for _, node := range nodes {
+		if !bytes.Equal(node.privSeed, make([]byte, 32)) || !bytes.Equal(node.pubSeed, make([]byte, 32)) {
+			t.Fatal("Seeds of node were not wiped")
+		}
+	}
+	if !bytes.Equal(tree.rootSeed, make([]byte, 32)) || !bytes.Equal(tree.rootPubSeed, make([]byte, 32)) {
+		t.Fatal("Root seeds were not wiped")
+	}
+
+	// 2 - The wiped tree can not be used
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != ErrTreeWiped {
+		t.Fatal("Signed with wiped tree, err was", err)
+	}
+	if _, err := tree.Backup(1); err != ErrTreeWiped {
+		t.Fatal("Created backup of wiped tree, err was", err)
+	}
+	if _, err := tree.WriteTo(&bytes.Buffer{}); err != ErrTreeWiped {
+		t.Fatal("Serialised wiped tree, err was", err)
+	}
+	if tree.Bytes() != nil || tree.PublicKey() != nil {
+		t.Fatal("Wiped tree returned state or public key")
+	}
+}
+
+func TestLoadOwned(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("owned test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	b := tree.Bytes()
+
+	// 1 - Load copies the nodes out of its input, so the input may be wiped
+	loaded, err := Load(b)
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	input := append([]byte(nil), b...)
+	wipeBytes(b)
+	if !bytes.Equal(loaded.Bytes(), input) {
+		t.Fatal("Loaded tree aliases its input")
+	}
+
+	// 2 - LoadOwned aliases its input, and Wipe wipes all of it
+	b = append([]byte(nil), input...)
+	owned, err := LoadOwned(b)
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if !bytes.Equal(owned.Bytes(), input) {
+		t.Fatal("Tree loaded with LoadOwned differs from its state")
+	}
+	if _, _, err := signMessage("owned test", owned); err != nil {
+		t.Fatal("Failed to sign with tree loaded with LoadOwned -", err)
+	}
+	owned.Wipe()
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatal("Input of LoadOwned was not wiped")
+	}
+
+	// 3 - Inputs that fail to load are wiped
+	b = append([]byte(nil), input...)
+	b[len(b)-1] ^= 1
+	if _, err := LoadOwned(b); err != ErrTreeChecksum {
+		t.Fatal("Loaded corrupted state, err was", err)
+	}
+	if !bytes.Equal(b, make([]byte, len(b))) {
+		t.Fatal("Input of failed LoadOwned was not wiped")
+	}
+}
+
+func TestNYTree_SignWipesSeed(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	pk := tree.PublicKey()
+	root := tree.nodes[0]
+
+	// 1 - The seed of a node is wiped once it signed, and its signature
+	// still verifies
+	sig, _, err := signMessage("sign wipe test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root.privSeed, make([]byte, 32)) {
+		t.Fatal("Seed of consumed node was not wiped")
+	}
+	if ok, err := Verify(pk, sig, sig.Message); !ok {
+		t.Fatal("Signature does not verify after wiping its node -", err)
+	}
+
+	// 2 - So are the seeds of the nodes that signed a batch
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	nodes := append([]*nyNode(nil), tree.nodes...)
+	msgs := [][]byte{make([]byte, 32), bytes.Repeat([]byte{1}, 32)}
+	if _, err := tree.SignBatch(msgs, bytes.Repeat([]byte{0xaa}, 32)); err != nil {
+		t.Fatal("Failed to sign batch -", err)
+	}
+	var wiped int
+	for _, node := range nodes {
+		if bytes.Equal(node.privSeed, make([]byte, 32)) {
+			wiped++
+		}
+	}
+	if wiped != 1 {
+		t.Fatal("Invalid number of wiped nodes", wiped)
+	}
+
+	// 3 - Wiping the tree wipes its revocation node
+	revocable := New(seed, pubSeed, false)
+	if err := revocable.EnableRevocation(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := signMessage("revocation wipe test", revocable); err != nil {
+		t.Fatal(err)
+	}
+	revocation := revocable.revocationNode
+	if revocation == nil {
+		t.Fatal("No revocation node reserved")
+	}
+	revocable.Wipe()
+	if !bytes.Equal(revocation.privSeed, make([]byte, 32)) || revocable.revocationNode != nil {
+		t.Fatal("Revocation node was not wiped")
+	}
+}
+
+func TestNYTree_BackupWipe(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, _, err := signMessage("backup test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// Load the tree, so its nodes alias the serialised state
+	tree, err = Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	backup, err := tree.Backup(1)
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+
+	// 1 - Wiping the original does not affect the backup
+	pkh := backup.nodes[0].pubKeyHash()
+	tree.Wipe()
+	sig, err = backup.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign with backup -", err)
+	}
+	if pk, err := sig.PublicKey(); err != nil || !bytes.Equal(SuiteSHA256.sum(pk), pkh) {
+		t.Fatal("Node of backup was wiped with the original")
+	}
+
+	// 2 - Wiping the backup does not affect the original
+	tree = New(seed, pubSeed, false)
+	sig, _, err = signMessage("backup test", tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+	backup, err = tree.Backup(1)
+	if err != nil {
+		t.Fatal("Failed to create backup -", err)
+	}
+	backup.Wipe()
+	if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+		t.Fatal("Failed to sign after wiping the backup -", err)
+	}
+	for _, node := range tree.nodes {
+		if bytes.Equal(node.privSeed, make([]byte, 32)) {
+			t.Fatal("Node of original was wiped with the backup")
+		}
+	}
+}
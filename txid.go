@@ -0,0 +1,96 @@
+package xnyss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Domain separation prefix of the txids derived by Txid.
+const txidDomain = "xnyss txid"
+
+// Derives a txid for Sign from an application's request identifier, which can
+// have any shape. The namespace identifies the application (or the kind of
+// request), so equal request IDs of different namespaces never share a txid.
+//
+// The txid is H("xnyss txid" || len(namespace) || namespace || requestID), where
+// the length is a 4 byte big endian integer. Prefixing the namespace with its
+// length makes the encoding injective: the namespace "a" with request ID "bc"
+// does not collide with the namespace "ab" with request ID "c".
+func Txid(namespace string, requestID []byte) []byte {
+	var nsLen [4]byte
+	binary.BigEndian.PutUint32(nsLen[:], uint32(len(namespace)))
+
+	h := sha256.New()
+	h.Write([]byte(txidDomain))
+	h.Write(nsLen[:])
+	h.Write([]byte(namespace))
+	h.Write(requestID)
+	return h.Sum(nil)
+}
+
+// The namespace of the txids hashed from transaction identifiers that are not
+// TxidLen bytes long, see SetTxidHashing.
+const hashedTxidNamespace = "xnyss hashed txid"
+
+// Returns the txid that a tree with txid hashing enabled uses for the
+// transaction identifier id: id itself if it is TxidLen bytes long, and
+// Txid("xnyss hashed txid", id) otherwise. Returns nil if id is empty.
+func HashTxid(id []byte) []byte {
+	switch len(id) {
+	case 0:
+		return nil
+	case TxidLen:
+		return append([]byte(nil), id...)
+	}
+
+	return Txid(hashedTxidNamespace, id)
+}
+
+// Enables or disables txid hashing. By default, Sign and the other methods
+// that take a txid return ErrInvalidTxidLen unless the txid is TxidLen bytes
+// long. With txid hashing, transaction identifiers of any non-zero length are
+// accepted, and those that are not TxidLen bytes long are hashed, see
+// HashTxid. Nodes created for a hashed txid record the length of the original
+// identifier for debugging, see NodeInfo. The setting is included in the
+// serialised tree.
+func (t *NYTree) SetTxidHashing(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.txidHashing != enabled {
+		t.txidHashing = enabled
+		t.epoch++
+	}
+}
+
+// Returns whether txid hashing is enabled for the tree t.
+func (t *NYTree) TxidHashing() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.txidHashing
+}
+
+// Returns the txid the tree t uses for the transaction identifier id, along
+// with the length of id if it was hashed, or zero otherwise. Returns
+// ErrInvalidTxidLen if t does not accept id.
+func (t *NYTree) normalizeTxid(id []byte) (txid []byte, hashedLen uint32, err error) {
+	switch {
+	case len(id) == TxidLen:
+		return id, 0, nil
+	case !t.txidHashing || len(id) == 0:
+		return nil, 0, ErrInvalidTxidLen
+	}
+
+	return HashTxid(id), uint32(len(id)), nil
+}
+
+// Returns the txid the tree t uses for the transaction identifier id, or id
+// itself if t does not accept it, for methods that only look up nodes by txid.
+func (t *NYTree) lookupTxid(id []byte) []byte {
+	if txid, _, err := t.normalizeTxid(id); err == nil {
+		return txid
+	}
+
+	return id
+}
@@ -0,0 +1,66 @@
+package xnyss
+
+var (
+	ErrTxidPolicyInvalid = newError(ErrState, "unknown child txid policy")
+)
+
+// Determines the txid of the child nodes created by a signature, which decides
+// which signatures may use them before they are confirmed: Sign uses
+// unconfirmed nodes whose txid matches the txid it signs for.
+type TxidPolicy uint8
+
+const (
+	// Children inherit the txid of the signature that created them, so all
+	// inputs of a transaction can be signed in one subtree. This is the
+	// default.
+	TxidInherit TxidPolicy = iota
+	// Children have the all-zero txid, so they are only used once confirmed.
+	// The all-zero txid must then not be used to sign.
+	TxidZero
+	// Children have the public key hash of the node that created them as txid,
+	// so signatures for that txid share the subtree of a single node.
+	TxidParentPkh
+)
+
+// Sets the child txid policy of the tree t, which applies to the children of
+// future signatures. The policy is included in the serialised tree.
+//
+// Unconfirm and InvalidateTxid find the nodes created by a transaction through
+// their txid, so they only apply to the children of signatures created with
+// TxidInherit.
+func (t *NYTree) SetTxidPolicy(p TxidPolicy) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if p > TxidParentPkh {
+		return ErrTxidPolicyInvalid
+	}
+
+	if t.txidPolicy != p {
+		t.txidPolicy = p
+		t.epoch++
+	}
+
+	return nil
+}
+
+// Returns the child txid policy of the tree t.
+func (t *NYTree) TxidPolicy() TxidPolicy {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.txidPolicy
+}
+
+// Returns the txid of the children created by the node with public key hash
+// parent, signing for txid.
+func (t *NYTree) childTxid(txid []byte, parent [32]byte) []byte {
+	switch t.txidPolicy {
+	case TxidZero:
+		return make([]byte, TxidLen)
+	case TxidParentPkh:
+		return parent[:]
+	}
+
+	return txid
+}
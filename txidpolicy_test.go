@@ -0,0 +1,61 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetTxidPolicy(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPkh := SuiteSHA256.sum(New(seed, pubSeed, false).PublicKey())
+
+	for _, policy := range []TxidPolicy{TxidInherit, TxidZero, TxidParentPkh} {
+		tree := New(seed, pubSeed, false)
+		if err := tree.SetTxidPolicy(policy); err != nil {
+			t.Fatal("Failed to set policy -", err)
+		}
+
+		// 1 - Children get the txid of the policy
+		if _, err := tree.Sign(testdata.Message, testdata.Txid); err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		expected := map[TxidPolicy][]byte{
+			TxidInherit:   testdata.Txid,
+			TxidZero:      make([]byte, TxidLen),
+			TxidParentPkh: rootPkh,
+		}[policy]
+		for _, node := range tree.nodes {
+			if !bytes.Equal(node.txid, expected) {
+				t.Fatal("Child of policy", policy, "has txid", node.txid)
+			}
+		}
+
+		// 2 - Unconfirmed children are only used for a matching txid
+		_, err := tree.Sign(testdata.Message, testdata.Txid)
+		if policy == TxidInherit && err != nil {
+			t.Fatal("Failed to sign with inherited txid -", err)
+		}
+		if policy != TxidInherit && err != ErrTreeNoneAvailable {
+			t.Fatal("Signed with unconfirmed node under policy", policy, "- err was", err)
+		}
+
+		// 3 - The policy is persisted
+		loaded, err := Load(tree.Bytes())
+		if err != nil {
+			t.Fatal("Failed to load tree -", err)
+		}
+		if loaded.TxidPolicy() != policy {
+			t.Fatal("Policy", policy, "was not persisted")
+		}
+	}
+
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetTxidPolicy(TxidParentPkh + 1); err != ErrTxidPolicyInvalid {
+		t.Fatal("Set unknown policy, err was", err)
+	}
+}
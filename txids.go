@@ -0,0 +1,116 @@
+package xnyss
+
+import "bytes"
+
+// Summarises the nodes of a tree created by one transaction, i.e. the child
+// nodes of the signatures with that txid, see NYTree.TxidStatuses.
+type TxidStatus struct {
+	Txid []byte
+	// The amount of nodes of the txid held by the tree, and how many of them
+	// are confirmed, pending (awaiting confirmations) and reserved.
+	Nodes     int
+	Confirmed int
+	Pending   int
+	Reserved  int
+	// The lowest confirmation count of the nodes, i.e. the block depth of the
+	// transaction as far as the tree knows.
+	Confirms uint8
+}
+
+// Reports whether the nodes of the txid still await confirmations before they
+// add to the signing capacity of the tree.
+func (s TxidStatus) Blocking() bool {
+	return s.Pending > 0
+}
+
+// Returns the txids of the nodes of the tree t, i.e. of the transactions whose
+// signatures created them, in the order their first node is considered for
+// signing. The root node was not created by a transaction, and is left out.
+func (t *NYTree) Txids() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var txids [][]byte
+	for _, s := range t.txidStatuses() {
+		txids = append(txids, s.Txid)
+	}
+
+	return txids
+}
+
+// Returns descriptions of the nodes of the tree t created by the transaction
+// txid, in the order they are considered for signing. txid is hashed like for
+// Sign if t uses txid hashing, see SetTxidHashing.
+func (t *NYTree) NodesForTxid(txid []byte) []NodeInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	txid = t.lookupTxid(txid)
+	var nodes []NodeInfo
+	for _, node := range t.nodes {
+		if !t.isRoot(node) && bytes.Equal(node.txid, txid) {
+			nodes = append(nodes, t.nodeInfo(node))
+		}
+	}
+
+	return nodes
+}
+
+// Returns the status of the nodes created by the transaction txid, and false
+// if the tree t holds none, see TxidStatuses.
+func (t *NYTree) TxidStatus(txid []byte) (TxidStatus, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	txid = t.lookupTxid(txid)
+	for _, s := range t.txidStatuses() {
+		if bytes.Equal(s.Txid, txid) {
+			return s, true
+		}
+	}
+
+	return TxidStatus{}, false
+}
+
+// Returns the status of the nodes of every txid of the tree t, in the order of
+// Txids, e.g. for a wallet to show which transactions still block future
+// signing capacity (see TxidStatus.Blocking).
+func (t *NYTree) TxidStatuses() []TxidStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.txidStatuses()
+}
+
+func (t *NYTree) txidStatuses() []TxidStatus {
+	var statuses []TxidStatus
+	index := make(map[[32]byte]int)
+	for _, node := range t.nodes {
+		if t.isRoot(node) {
+			continue
+		}
+
+		var key [32]byte
+		copy(key[:], node.txid)
+		i, ok := index[key]
+		if !ok {
+			i = len(statuses)
+			index[key] = i
+			statuses = append(statuses, TxidStatus{Txid: append([]byte(nil), node.txid...), Confirms: node.confirms})
+		}
+
+		s := &statuses[i]
+		s.Nodes++
+		s.Confirms = min(s.Confirms, node.confirms)
+		if node.confirms >= ConfirmsRequired {
+			s.Confirmed++
+		} else {
+			s.Pending++
+		}
+		if node.reservation != 0 {
+			s.Reserved++
+		}
+	}
+
+	return statuses
+}
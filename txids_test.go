@@ -0,0 +1,62 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_TxidStatuses(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	first, second := Txid("txids", []byte{1}), Txid("txids", []byte{2})
+
+	// 1 - Fresh trees only hold the root, which has no txid
+	if len(tree.Txids()) != 0 || len(tree.TxidStatuses()) != 0 {
+		t.Fatal("Fresh tree has txids")
+	}
+
+	// 2 - Nodes are grouped by the txid of the signature that created them
+	sig, err := tree.Sign(testdata.Message, first)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+	if _, err := tree.Sign(testdata.Message, second); err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	txids := tree.Txids()
+	if len(txids) != 2 || bytes.Equal(txids[0], txids[1]) ||
+		!bytes.Equal(txids[0], first) && !bytes.Equal(txids[0], second) ||
+		!bytes.Equal(txids[1], first) && !bytes.Equal(txids[1], second) {
+		t.Fatal("Invalid txids", len(txids))
+	}
+	nodes := tree.NodesForTxid(second)
+	if len(nodes) != len(sig.ChildHashes) || !bytes.Equal(nodes[0].Txid, second) {
+		t.Fatal("Invalid nodes for txid", len(nodes))
+	}
+
+	// 3 - Statuses tell which transactions block capacity
+	s, ok := tree.TxidStatus(first)
+	if !ok || s.Nodes != len(sig.ChildHashes)-1 || s.Pending != s.Nodes || s.Confirmed != 0 || !s.Blocking() {
+		t.Fatal("Invalid status of first txid", s)
+	}
+	s, ok = tree.TxidStatus(second)
+	if !ok || s.Pending != len(sig.ChildHashes) || s.Confirms != 0 {
+		t.Fatal("Invalid status of second txid", s)
+	}
+	for _, node := range nodes {
+		tree.Confirm(node.PubKeyHash, ConfirmsRequired+1)
+	}
+	s, _ = tree.TxidStatus(second)
+	if s.Blocking() || s.Confirmed != s.Nodes || s.Confirms != ConfirmsRequired+1 {
+		t.Fatal("Confirmed txid is still blocking", s)
+	}
+	if _, ok := tree.TxidStatus(Txid("txids", []byte{3})); ok {
+		t.Fatal("Status of unknown txid")
+	}
+}
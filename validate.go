@@ -0,0 +1,100 @@
+package xnyss
+
+import "fmt"
+
+// The highest confirmation count a node can hold, unless ConfirmsRequired is
+// higher. Confirm and SetConfirms store at most this count, since higher ones
+// make no difference, and Load bounds the counts of states written before
+// they were bounded. ValidateState rejects higher counts.
+var MaxConfirms uint8 = 100
+
+var (
+	ErrNodeConfirms     = newError(ErrEncoding, "node confirmation count exceeds MaxConfirms")
+	ErrNodeInvalidSeeds = newError(ErrEncoding, "node seeds or txid have an invalid length")
+)
+
+// An error found in a node of a tree, by Load or ValidateState. The error
+// matches Err, and its category, using errors.Is.
+type StateError struct {
+	// The index of the node among the nodes of the tree.
+	Node int
+	// The offset of the node in the serialised tree, or -1 if it is not known.
+	Offset int
+	Err    error
+}
+
+func (e *StateError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("node %d: %v", e.Node, e.Err)
+	}
+
+	return fmt.Sprintf("node %d at offset %d: %v", e.Node, e.Offset, e.Err)
+}
+
+func (e *StateError) Unwrap() error {
+	return e.Err
+}
+
+// Returns confirms, bounded by MaxConfirms, or ConfirmsRequired if it is
+// higher.
+func boundConfirms(confirms uint8) uint8 {
+	return min(confirms, max(MaxConfirms, ConfirmsRequired))
+}
+
+// Audits the state of the tree t, e.g. after loading it from a source that
+// is not fully trusted: every node must have seeds and a txid of the right
+// length and a confirmation count of at most MaxConfirms (see boundConfirms),
+// no node may be held twice, and no node whose key was consumed by a signature
+// may still be held. Returns a StateError for the first node that fails,
+// wrapping ErrNodeInvalidSeeds, ErrNodeConfirms, ErrTreeDuplicateSeed, or
+// ErrTreeSeedReuse or ErrTreeNodeConsumed if the seeds or the public key hash
+// of the node were consumed. Load performs the same audit, after bounding the
+// confirmation counts of the loaded nodes.
+func (t *NYTree) ValidateState() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.validateState()
+}
+
+func (t *NYTree) validateState() error {
+	seen := make(map[[32]byte]bool, len(t.nodes))
+	for i, node := range t.nodes {
+		if err := t.validateNode(node); err != nil {
+			return &StateError{Node: i, Offset: -1, Err: err}
+		}
+
+		digest := node.seedDigest()
+		if seen[digest] {
+			return &StateError{Node: i, Offset: -1, Err: ErrTreeDuplicateSeed}
+		}
+		seen[digest] = true
+		if t.consumedSeeds[digest] {
+			return &StateError{Node: i, Offset: -1, Err: ErrTreeSeedReuse}
+		}
+		// Public key hashes that are not cached are not computed here, Sign
+		// checks them before using the node, see checkReuse
+		if node.pkh != nil && t.consumed[pkhKey(node)] {
+			return &StateError{Node: i, Offset: -1, Err: ErrTreeNodeConsumed}
+		}
+	}
+
+	return nil
+}
+
+// Checks the fields of a single node, see ValidateState.
+func (t *NYTree) validateNode(node *nyNode) error {
+	if len(node.pubSeed) != 32 || len(node.txid) != TxidLen ||
+		(node.pkh != nil && len(node.pkh) != 32) ||
+		(node.mask != nil && len(node.mask) != len(node.privSeed)) {
+		return ErrNodeInvalidSeeds
+	}
+	if !t.providerSeeds && len(node.privSeed) != 32 {
+		return ErrNodeInvalidSeeds
+	}
+	if node.confirms != boundConfirms(node.confirms) {
+		return ErrNodeConfirms
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package xnyss
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_ValidateState(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	sig, err := tree.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+
+	// 1 - Trees created by the package are valid, and so are confirm counts
+	// up to MaxConfirms
+	tree.Confirm(sig.ChildHashes[0], 255)
+	if err := tree.ValidateState(); err != nil {
+		t.Fatal("Valid tree failed validation -", err)
+	}
+	if _, err := Load(tree.Bytes()); err != nil {
+		t.Fatal("Failed to load valid tree -", err)
+	}
+
+	// 2 - Nodes with nonsensical confirm counts are refused by ValidateState,
+	// with context
+	tree.nodeByPkh(sig.ChildHashes[1]).confirms = MaxConfirms + 1
+	var stateErr *StateError
+	if err := tree.ValidateState(); !errors.As(err, &stateErr) || stateErr.Err != ErrNodeConfirms ||
+		stateErr.Offset != -1 || tree.nodes[stateErr.Node].confirms != MaxConfirms+1 {
+		t.Fatal("Validated node with invalid confirms, err was", err)
+	}
+
+	// Load clamps them instead, since earlier versions stored any count
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load node with high confirms -", err)
+	}
+	if loaded.nodeByPkh(sig.ChildHashes[1]).confirms != MaxConfirms {
+		t.Fatal("Loaded confirms were not clamped")
+	}
+	tree.nodeByPkh(sig.ChildHashes[1]).confirms = 0
+
+	// 3 - Duplicate and consumed nodes are refused
+	tree.nodes = append(tree.nodes, tree.nodes[0])
+	if err := tree.ValidateState(); !errors.Is(err, ErrTreeDuplicateSeed) {
+		t.Fatal("Validated duplicate node, err was", err)
+	}
+	tree.nodes = tree.nodes[:len(tree.nodes)-1]
+	tree.consumedSeeds[tree.nodes[0].seedDigest()] = true
+	if err := tree.ValidateState(); !errors.Is(err, ErrTreeSeedReuse) {
+		t.Fatal("Validated consumed node, err was", err)
+	}
+	delete(tree.consumedSeeds, tree.nodes[0].seedDigest())
+	tree.consumed[pkhKey(tree.nodes[0])] = true
+	if err := tree.ValidateState(); !errors.Is(err, ErrTreeNodeConsumed) {
+		t.Fatal("Validated node with consumed public key hash, err was", err)
+	}
+}
+
+func TestNYTree_SignStrictInput(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	// 1 - Messages must be exactly MsgLen bytes long, and txids are required
+	if _, err := tree.Sign(testdata.Message[:MsgLen-1], testdata.Txid); err != ErrInvalidMsgLen {
+		t.Fatal("Signed short message, err was", err)
+	}
+	if _, err := tree.Sign(testdata.Message, nil); err != ErrInvalidTxidLen {
+		t.Fatal("Signed without txid, err was", err)
+	}
+
+	// 2 - One-time trees do not need a txid
+	ots := New(seed, pubSeed, true)
+	sig, err := ots.Sign(testdata.Message, nil)
+	if err != nil {
+		t.Fatal("Failed to sign without txid in one-time tree -", err)
+	}
+	if err := VerifyChain(ots.PublicKey(), []*Signature{sig}, testdata.Message); err != nil {
+		t.Fatal("Failed to verify one-time signature -", err)
+	}
+}
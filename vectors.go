@@ -0,0 +1,166 @@
+package xnyss
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+)
+
+// A known-answer test vector of xnyss, see GenerateVectors: a signature created
+// by a tree, with the inputs that produced it and the state of the tree after
+// signing.
+type TestVector struct {
+	// The seeds of the tree, see New, and whether it is a one-time tree
+	Seed    []byte
+	PubSeed []byte
+	OneTime bool
+	// The public key of the tree, see NYTree.PublicKey
+	PublicKey []byte
+	// The arguments of Sign, and the signature and child hashes it returned
+	Txid        []byte
+	Message     []byte
+	Signature   []byte
+	ChildHashes [][]byte
+	// The position of the node that created the signature, which verifiers
+	// need to derive its WOTS+ address, see Signature.Position
+	Depth uint32
+	Index uint64
+	// The serialised tree after signing, see NYTree.Bytes
+	State []byte
+}
+
+// Generates known-answer test vectors for ports of xnyss to other languages.
+// The vectors are created in a harness with the given seed (see Harness), so
+// their child seeds, times and states are reproducible: the same seed always
+// gives the same vectors, byte for byte.
+//
+// The first vector is the signature of a one-time tree. It is followed by n
+// signatures of a long-term tree, which confirms the first child node of every
+// signature, so that every signature is created by the child of the one
+// before it. Txids and messages are read from the entropy of the harness.
+func GenerateVectors(seed []byte, n int) ([]TestVector, error) {
+	h := NewHarness(seed)
+	inputs := h.Entropy()
+
+	var vectors []TestVector
+	for _, ots := range []bool{true, false} {
+		treeSeed, treePubSeed := h.Seeds()
+		tree := New(treeSeed, treePubSeed, ots)
+		h.Attach(tree)
+		pubKey := tree.PublicKey()
+
+		for i := 0; i < n && (!ots || i == 0); i++ {
+			txid, msg := make([]byte, TxidLen), make([]byte, MsgLen)
+			// Derivation streams do not fail
+			io.ReadFull(inputs, txid)
+			io.ReadFull(inputs, msg)
+
+			sig, err := tree.Sign(msg, txid)
+			if err != nil {
+				return nil, err
+			}
+			if !ots {
+				tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+			}
+
+			v := TestVector{
+				Seed:        treeSeed,
+				PubSeed:     treePubSeed,
+				OneTime:     ots,
+				PublicKey:   pubKey,
+				Txid:        txid,
+				Message:     msg,
+				Signature:   sig.Bytes(),
+				ChildHashes: make([][]byte, len(sig.ChildHashes)),
+				State:       tree.Bytes(),
+			}
+			if p, ok := sig.Position(); ok {
+				v.Depth, v.Index = p.Depth, p.Index
+			}
+			for j, pkh := range sig.ChildHashes {
+				v.ChildHashes[j] = append([]byte(nil), pkh...)
+			}
+			vectors = append(vectors, v)
+		}
+	}
+
+	return vectors, nil
+}
+
+// The JSON encoding of a test vector.
+type testVectorJSON struct {
+	Seed        string   `json:"seed"`
+	PubSeed     string   `json:"pubSeed"`
+	OneTime     bool     `json:"oneTime"`
+	PublicKey   string   `json:"publicKey"`
+	Txid        string   `json:"txid"`
+	Message     string   `json:"message"`
+	Signature   string   `json:"signature"`
+	ChildHashes []string `json:"childHashes"`
+	Depth       uint32   `json:"depth"`
+	Index       uint64   `json:"index"`
+	State       string   `json:"state"`
+}
+
+// Implements json.Marshaler. All byte strings of the vector are encoded in
+// hex.
+func (v TestVector) MarshalJSON() ([]byte, error) {
+	childHashes := hexList(v.ChildHashes)
+	if childHashes == nil {
+		childHashes = []string{}
+	}
+
+	return json.Marshal(testVectorJSON{
+		Seed:        hex.EncodeToString(v.Seed),
+		PubSeed:     hex.EncodeToString(v.PubSeed),
+		OneTime:     v.OneTime,
+		PublicKey:   hex.EncodeToString(v.PublicKey),
+		Txid:        hex.EncodeToString(v.Txid),
+		Message:     hex.EncodeToString(v.Message),
+		Signature:   hex.EncodeToString(v.Signature),
+		ChildHashes: childHashes,
+		Depth:       v.Depth,
+		Index:       v.Index,
+		State:       hex.EncodeToString(v.State),
+	})
+}
+
+// Implements json.Unmarshaler for the encoding of MarshalJSON.
+func (v *TestVector) UnmarshalJSON(b []byte) error {
+	var j testVectorJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return ErrInvalidJSON
+	}
+
+	var decoded TestVector
+	fields := []struct {
+		s string
+		b *[]byte
+	}{
+		{j.Seed, &decoded.Seed},
+		{j.PubSeed, &decoded.PubSeed},
+		{j.PublicKey, &decoded.PublicKey},
+		{j.Txid, &decoded.Txid},
+		{j.Message, &decoded.Message},
+		{j.Signature, &decoded.Signature},
+		{j.State, &decoded.State},
+	}
+	for _, f := range fields {
+		var err error
+		if *f.b, err = hex.DecodeString(f.s); err != nil {
+			return ErrInvalidJSON
+		}
+	}
+	decoded.ChildHashes = make([][]byte, len(j.ChildHashes))
+	for i, s := range j.ChildHashes {
+		var err error
+		if decoded.ChildHashes[i], err = hex.DecodeString(s); err != nil {
+			return ErrInvalidJSON
+		}
+	}
+	decoded.OneTime = j.OneTime
+	decoded.Depth, decoded.Index = j.Depth, j.Index
+
+	*v = decoded
+	return nil
+}
@@ -0,0 +1,97 @@
+package xnyss
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update", false, "regenerate the golden test vectors")
+
+// The golden test vectors, and the arguments of GenerateVectors they were
+// generated with.
+const (
+	vectorsFile  = "testdata/vectors.json"
+	vectorsSeed  = "xnyss test vectors"
+	vectorsCount = 3
+)
+
+func TestGenerateVectors(t *testing.T) {
+	vectors, err := GenerateVectors([]byte(vectorsSeed), vectorsCount)
+	if err != nil {
+		t.Fatal("Failed to generate vectors -", err)
+	}
+	encoded, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded = append(encoded, '\n')
+	if *updateVectors {
+		if err := os.WriteFile(vectorsFile, encoded, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 - Vectors match the golden file
+	golden, err := os.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, golden) {
+		t.Fatal("Vectors do not match", vectorsFile, "- run the test with -update if the change is intended")
+	}
+
+	// 2 - The golden vectors decode to the generated ones
+	var decoded []TestVector
+	if err := json.Unmarshal(golden, &decoded); err != nil {
+		t.Fatal("Failed to decode vectors -", err)
+	}
+	if len(decoded) != 1+vectorsCount {
+		t.Fatal("Expected", 1+vectorsCount, "vectors, got", len(decoded))
+	}
+	for i := range decoded {
+		if !bytes.Equal(decoded[i].Signature, vectors[i].Signature) || !bytes.Equal(decoded[i].State, vectors[i].State) ||
+			decoded[i].Depth != vectors[i].Depth || decoded[i].Index != vectors[i].Index {
+			t.Fatal("Vector", i, "changed in decoding")
+		}
+	}
+
+	// 3 - The signatures of the long-term tree form a chain from its root at
+	// the positions of their nodes, and the states load
+	var chain []*Signature
+	for i, v := range decoded {
+		sig, err := NewSignature(v.Signature, v.Message)
+		if err != nil {
+			t.Fatal("Failed to parse signature of vector", i, "-", err)
+		}
+		sig.position = &Position{Depth: v.Depth, Index: v.Index}
+		if !equalHexList(hexList(v.ChildHashes), sig.ChildHashes) {
+			t.Fatal("Child hashes of vector", i, "do not match its signature")
+		}
+		if !v.OneTime {
+			chain = append(chain, sig)
+			if err := VerifyChain(v.PublicKey, chain, v.Message); err != nil {
+				t.Fatal("Failed to verify vector", i, "-", err)
+			}
+		}
+
+		tree, err := Load(v.State)
+		if err != nil {
+			t.Fatal("Failed to load state of vector", i, "-", err)
+		}
+		if !bytes.Equal(tree.PublicKey(), v.PublicKey) {
+			t.Fatal("Public key of the state of vector", i, "does not match")
+		}
+	}
+
+	// 4 - Other seeds give other vectors
+	other, err := GenerateVectors([]byte("other seed"), vectorsCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(other[0].Signature, vectors[0].Signature) {
+		t.Fatal("Vectors do not depend on the seed")
+	}
+}
@@ -0,0 +1,152 @@
+package xnyss
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrChainEmpty   = newError(ErrCrypto, "signature chain is empty")
+	ErrChainBroken  = newError(ErrCrypto, "signature chain does not link to the public key")
+	ErrChainMessage = newError(ErrCrypto, "last signature of the chain does not sign the message")
+	ErrChainTooLong = newError(ErrCrypto, "signature chain is longer than MaxChainDepth")
+)
+
+// The maximum number of signatures in a chain accepted by VerifyChain, or zero
+// for no limit. Every signature of a chain costs a WOTS+ public key computation,
+// so verifiers of untrusted proofs should set a limit that fits the depth their
+// signers reach.
+var MaxChainDepth = 0
+
+// Verifies that sig is a signature of msgHash by the one-time key pubKey, i.e.
+// the key of the node that created it, reconstructing the signed digest from
+// the message and the child hashes of sig. Returns false if the signature is
+// not valid, and an error if it can not be checked, e.g. due to an invalid
+// encoding, or ErrInvalidMsgLen unless msgHash is exactly MsgLen bytes long,
+// like the messages Sign accepts. To verify a signature against the long-term
+// key of a tree, use VerifyChain.
+func Verify(pubKey []byte, sig *Signature, msgHash []byte) (bool, error) {
+	if sig == nil {
+		return false, ErrInvalidSigEncoding
+	}
+	if len(msgHash) != MsgLen {
+		return false, ErrInvalidMsgLen
+	}
+
+	// The signature must sign msgHash, not just any message
+	verified := *sig
+	verified.Message = msgHash
+
+	pk, err := verified.PublicKey()
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(pk, pubKey), nil
+}
+
+// Like Verify, for a signature in the encoding of Signature.Bytes.
+func VerifyBytes(pubKey, sigBytes, msgHash []byte) (bool, error) {
+	sig, err := NewSignature(sigBytes, msgHash)
+	if err != nil {
+		return false, err
+	}
+
+	return Verify(pubKey, sig, msgHash)
+}
+
+// Verifies that the last signature of chain signs msg under the long-term
+// public key rootPubKey. The chain holds the signatures on the path from the
+// root of the tree to the node that signed msg: the first signature must be
+// created by the root key, and the public key of every next signature must be
+// one of the child hashes of the signature before it.
+//
+// Returns ErrChainTooLong before verifying any signature if the chain holds
+// more than MaxChainDepth signatures, and ErrSchemeMismatch if its signatures
+// record different signature schemes or hash suites.
+func VerifyChain(rootPubKey []byte, chain []*Signature, msg []byte) error {
+	return verifyChain(context.Background(), nil, rootPubKey, chain, msg, nil)
+}
+
+// Like VerifyChain, computing public keys in the arena a if it is not nil, and
+// returning ctx.Err() once ctx is done. Returns ErrChainRevoked if revoked is
+// not nil and returns true for the public key hash of a node that created a
+// signature of the chain, other than the root.
+func verifyChain(ctx context.Context, a *wotsp.Arena, rootPubKey []byte, chain []*Signature, msg []byte, revoked func(pkh []byte) bool) error {
+	if len(chain) == 0 {
+		return ErrChainEmpty
+	}
+	if MaxChainDepth > 0 && len(chain) > MaxChainDepth {
+		return ErrChainTooLong
+	}
+
+	last := chain[len(chain)-1]
+	if last == nil || !bytes.Equal(last.Message, msg) {
+		return ErrChainMessage
+	}
+
+	// All keys of a tree use the same scheme
+	var scheme Scheme
+	for _, sig := range chain {
+		if sig == nil || sig.scheme() == 0 {
+			continue
+		}
+		if scheme != 0 && sig.scheme() != scheme {
+			return ErrSchemeMismatch
+		}
+		scheme = sig.scheme()
+	}
+	var suite HashSuite
+	for _, sig := range chain {
+		if sig == nil {
+			continue
+		}
+		if suite != 0 && (sig.HashSuite() != suite || sig.w != chain[0].w) {
+			return ErrSchemeMismatch
+		}
+		suite = sig.HashSuite()
+	}
+
+	var parent *Signature
+	for _, sig := range chain {
+		if sig == nil {
+			return ErrChainBroken
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		pk, err := sig.publicKeyIn(a)
+		if err != nil {
+			return err
+		}
+
+		if parent == nil {
+			if !bytes.Equal(pk, rootPubKey) {
+				return ErrChainBroken
+			}
+		} else if !hasChild(parent, pk) {
+			return ErrChainBroken
+		} else if revoked != nil && revoked(sig.suite.sum(pk)) {
+			return ErrChainRevoked
+		}
+
+		parent = sig
+	}
+
+	return nil
+}
+
+// Returns whether H(pk) is one of the child hashes of sig.
+func hasChild(sig *Signature, pk []byte) bool {
+	pkh := sig.suite.sum(pk)
+	for _, child := range sig.ChildHashes {
+		if bytes.Equal(child, pkh) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,252 @@
+package xnyss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+	"testing/quick"
+)
+
+// Creates a chain of count signatures, each signed by a child of the previous
+// one. Returns the chain and the public key of the tree.
+func signChain(t testing.TB, count int) ([]*Signature, []byte) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+
+	chain := make([]*Signature, count)
+	for i := range chain {
+		msg := Txid("chain message", []byte{byte(i)})
+		sig, err := tree.Sign(msg, Txid("chain txid", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain[i] = sig
+	}
+
+	return chain, tree.PublicKey()
+}
+
+// Returns a deep copy of the chain.
+func copyChain(chain []*Signature) []*Signature {
+	c := make([]*Signature, len(chain))
+	for i, sig := range chain {
+		c[i] = &Signature{
+			PubSeed:  append([]byte(nil), sig.PubSeed...),
+			Message:  append([]byte(nil), sig.Message...),
+			SigBytes: append([]byte(nil), sig.SigBytes...),
+		}
+		for _, h := range sig.ChildHashes {
+			c[i].ChildHashes = append(c[i].ChildHashes, append([]byte(nil), h...))
+		}
+	}
+
+	return c
+}
+
+func TestVerifyChain(t *testing.T) {
+	chain, pk := signChain(t, 3)
+	msg := chain[2].Message
+
+	// 1 - Valid chains verify
+	if err := VerifyChain(pk, chain, msg); err != nil {
+		t.Fatal("Failed to verify chain -", err)
+	}
+	if err := VerifyChain(pk, chain[:1], chain[0].Message); err != nil {
+		t.Fatal("Failed to verify root signature -", err)
+	}
+
+	// 2 - Invalid chains do not
+	if err := VerifyChain(pk, nil, msg); err != ErrChainEmpty {
+		t.Fatal("Verified empty chain, err was", err)
+	}
+	if err := VerifyChain(pk, chain[:2], msg); err != ErrChainMessage {
+		t.Fatal("Verified truncated chain, err was", err)
+	}
+	if err := VerifyChain(pk, chain[1:], msg); err != ErrChainBroken {
+		t.Fatal("Verified chain without root signature, err was", err)
+	}
+	swapped := []*Signature{chain[0], chain[2], chain[1], chain[2]}
+	if err := VerifyChain(pk, swapped, msg); err != ErrChainBroken {
+		t.Fatal("Verified chain with swapped links, err was", err)
+	}
+}
+
+func TestVerifyChain_MaxDepth(t *testing.T) {
+	chain, pk := signChain(t, 3)
+	msg := chain[2].Message
+	defer func(depth int) { MaxChainDepth = depth }(MaxChainDepth)
+
+	// 1 - Chains up to the maximum depth verify
+	MaxChainDepth = 3
+	if err := VerifyChain(pk, chain, msg); err != nil {
+		t.Fatal("Failed to verify chain -", err)
+	}
+
+	// 2 - Longer chains do not
+	MaxChainDepth = 2
+	if err := VerifyChain(pk, chain, msg); err != ErrChainTooLong {
+		t.Fatal("Verified chain exceeding the maximum depth, err was", err)
+	}
+	bundle := &Bundle{Links: chain[:2]}
+	if bundle.Depth() != 3 {
+		t.Fatal("Bundle depth is", bundle.Depth(), "expected 3")
+	}
+	if err := bundle.Verify(pk, nil, chain[2], msg); err != ErrChainTooLong {
+		t.Fatal("Verified bundle exceeding the maximum depth, err was", err)
+	}
+}
+
+// Mutates a valid chain and asserts the result is rejected, and that the
+// verifier never panics.
+func FuzzVerifyChain(f *testing.F) {
+	chain, pk := signChain(f, 3)
+	msg := chain[2].Message
+
+	f.Add(uint8(0), uint8(0), uint16(0))
+	f.Add(uint8(1), uint8(1), uint16(12))
+	f.Add(uint8(2), uint8(2), uint16(3000))
+	f.Add(uint8(3), uint8(0), uint16(2))
+	f.Add(uint8(4), uint8(1), uint16(0))
+
+	f.Fuzz(func(t *testing.T, op, idx uint8, pos uint16) {
+		c := copyChain(chain)
+		sig := c[int(idx)%len(c)]
+
+		switch op % 6 {
+		case 0: // Flip a bit of a child hash
+			h := sig.ChildHashes[int(pos/256)%len(sig.ChildHashes)]
+			h[int(pos/8)%len(h)] ^= 1 << (pos % 8)
+		case 1: // Flip a bit of the WOTS+ signature
+			sig.SigBytes[int(pos/8)%len(sig.SigBytes)] ^= 1 << (pos % 8)
+		case 2: // Flip a bit of the public seed
+			sig.PubSeed[int(pos/8)%len(sig.PubSeed)] ^= 1 << (pos % 8)
+		case 3: // Swap two different links
+			j := int(pos) % len(c)
+			if j == int(idx)%len(c) {
+				j = (j + 1) % len(c)
+			}
+			c[int(idx)%len(c)], c[j] = c[j], c[int(idx)%len(c)]
+		case 4: // Truncate the chain, keeping at least one signature
+			c = c[:int(idx)%len(c)+1]
+			if len(c) == len(chain) {
+				c = c[1:]
+			}
+		case 5: // Truncate the encoding of a signature
+			sig.SigBytes = sig.SigBytes[:int(pos)%len(sig.SigBytes)]
+		}
+
+		if err := VerifyChain(pk, c, msg); err == nil {
+			t.Fatal("Verified mutated chain, op", op%6, "index", idx, "position", pos)
+		}
+	})
+}
+
+// Performs random sequences of Sign and Confirm on random trees, and checks
+// that every signature verifies against the public key of the tree through its
+// chain, and that no one-time key is used twice.
+func TestVerifyChain_Property(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+
+		seeds := make([]byte, 64)
+		r.Read(seeds)
+		tree := New(seeds[:32], seeds[32:], false)
+		if err := tree.SetBranching([]int{1 + r.Intn(3), 1 + r.Intn(3)}); err != nil {
+			t.Error(err)
+			return false
+		}
+		rootPK := tree.PublicKey()
+
+		// The chain of the signature that created each node, by public key hash
+		chains := map[string][]*Signature{}
+		used := map[string]bool{}
+
+		for op := 0; op < 12; op++ {
+			if unconfirmed := tree.Unconfirmed(); len(unconfirmed) > 0 && r.Intn(2) == 0 {
+				tree.Confirm(unconfirmed[r.Intn(len(unconfirmed))], ConfirmsRequired)
+				continue
+			}
+
+			msg := make([]byte, 32)
+			r.Read(msg)
+			sig, err := tree.Sign(msg, Txid("property", []byte{byte(r.Intn(3))}))
+			if err == ErrTreeNoneAvailable {
+				continue
+			} else if err != nil {
+				t.Error("Failed to sign -", err)
+				return false
+			}
+
+			pk, err := sig.PublicKey()
+			if err != nil {
+				t.Error(err)
+				return false
+			}
+			if used[string(pk)] {
+				t.Error("One-time key was used twice")
+				return false
+			}
+			used[string(pk)] = true
+
+			pkh := sha256.Sum256(pk)
+			chain, ok := chains[string(pkh[:])]
+			if !ok && !bytes.Equal(pk, rootPK) {
+				t.Error("Signature was not created by a known node")
+				return false
+			}
+			chain = append(append([]*Signature(nil), chain...), sig)
+
+			if err := VerifyChain(rootPK, chain, msg); err != nil {
+				t.Error("Failed to verify chain -", err)
+				return false
+			}
+
+			for _, child := range sig.ChildHashes {
+				chains[string(child)] = chain
+			}
+		}
+
+		return true
+	}
+
+	if err := quick.Check(property, &quick.Config{MaxCount: 10}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	chain, pk := signChain(t, 1)
+	sig := chain[0]
+
+	// 1 - Valid signatures verify, also from the wire encoding
+	if ok, err := Verify(pk, sig, sig.Message); !ok || err != nil {
+		t.Fatal("Failed to verify signature -", err)
+	}
+	if ok, err := VerifyBytes(pk, sig.Bytes(), sig.Message); !ok || err != nil {
+		t.Fatal("Failed to verify encoded signature -", err)
+	}
+
+	// 2 - Invalid signatures do not
+	other := make([]byte, 32)
+	if ok, err := Verify(pk, sig, other); ok || err != nil {
+		t.Fatal("Verified signature for other message, err was", err)
+	}
+	if ok, err := VerifyBytes(pk, sig.Bytes()[1:], sig.Message); ok || err != ErrInvalidSigEncoding {
+		t.Fatal("Verified invalid encoding, err was", err)
+	}
+
+	// 3 - Message hashes must be exactly MsgLen bytes long, as in Sign
+	for _, msgHash := range [][]byte{nil, sig.Message[:MsgLen-1], append(sig.Message[:MsgLen:MsgLen], 0)} {
+		if ok, err := Verify(pk, sig, msgHash); ok || err != ErrInvalidMsgLen {
+			t.Fatal("Verified message hash of length", len(msgHash), "err was", err)
+		}
+		if ok, err := VerifyBytes(pk, sig.Bytes(), msgHash); ok || err != ErrInvalidMsgLen {
+			t.Fatal("Verified encoded signature of message hash of length", len(msgHash), "err was", err)
+		}
+	}
+}
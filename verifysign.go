@@ -0,0 +1,61 @@
+package xnyss
+
+import "bytes"
+
+var ErrSignatureFault = newError(ErrCrypto, "signature does not verify against the key of its node, the computation was faulty")
+
+// Enables or disables verification after signing. Hash-based one-time
+// signatures reveal parts of the private key, so a signature corrupted by a
+// faulty computation, e.g. a bit flip, may leak key material once it is
+// published. With verification after signing, every signature created by t is
+// checked before it is returned: its public key is recomputed from the
+// signature, like a verifier would, and its hash compared to the known public
+// key hash of the node that signed. This roughly doubles the cost of Sign. The
+// setting is included in the serialised tree.
+//
+// If the check fails, the signature is discarded and ErrSignatureFault is
+// returned. The tree is left unchanged, since the faulty signature was never
+// released: the node stays available, and signing again recomputes it. Only
+// for trees using a seed provider, which destroys the seed of a node once it
+// signed, the node is removed like MarkConsumed does.
+func (t *NYTree) SetVerifyAfterSign(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.verifyAfterSign != enabled {
+		t.verifyAfterSign = enabled
+		t.epoch++
+	}
+}
+
+// Returns whether the tree t verifies its signatures before returning them,
+// see SetVerifyAfterSign.
+func (t *NYTree) VerifyAfterSign() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.verifyAfterSign
+}
+
+// Checks the signature sig created by the node with public key hash pkh, if t
+// verifies its signatures after signing. If the check fails, the signature
+// and the child nodes created with it are wiped.
+func (t *NYTree) checkSignature(sig *Signature, pkh []byte, childNodes []*nyNode) error {
+	if !t.verifyAfterSign {
+		return nil
+	}
+
+	pk, err := sig.publicKey(nil)
+	if err == nil && bytes.Equal(sig.suite.sum(pk), pkh) {
+		return nil
+	}
+
+	t.log(LevelAudit, "signature fault detected", pkhAttr(pkh))
+	wipeBytes(sig.SigBytes)
+	wipeProviderSeeds(childNodes)
+	for _, node := range childNodes {
+		node.wipe()
+	}
+
+	return ErrSignatureFault
+}
@@ -0,0 +1,61 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNYTree_SetVerifyAfterSign(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	tree.SetVerifyAfterSign(true)
+	pk := tree.PublicKey()
+
+	// 1 - Correct signatures are returned
+	sig, _, err := signMessage("verify after sign test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if ok, err := Verify(pk, sig, sig.Message); !ok {
+		t.Fatal("Signature does not verify -", err)
+	}
+	for _, pkh := range sig.ChildHashes {
+		tree.Confirm(pkh, ConfirmsRequired)
+	}
+
+	// 2 - Faulty signatures are detected, and leave the tree unchanged
+	restore := InjectFaults(&FaultInjection{Sign: func(sigBytes []byte) { sigBytes[7] ^= 0x10 }})
+	before := tree.Bytes()
+	_, _, err = signMessage("faulty signature", tree)
+	restore()
+	if err != ErrSignatureFault {
+		t.Fatal("Faulty signature was not detected, err was", err)
+	}
+	if !bytes.Equal(before, tree.Bytes()) {
+		t.Fatal("Faulty signature changed the tree")
+	}
+	childSig, _, err := signMessage("faulty signature", tree)
+	if err != nil {
+		t.Fatal("Failed to sign after fault -", err)
+	}
+	if err := VerifyChain(pk, []*Signature{sig, childSig}, childSig.Message); err != nil {
+		t.Fatal("Signature after fault does not verify -", err)
+	}
+
+	// 3 - The setting survives serialisation, and faults go undetected
+	// without it
+	loaded, err := Load(tree.Bytes())
+	if err != nil || !loaded.VerifyAfterSign() {
+		t.Fatal("Loaded tree lost the setting -", err)
+	}
+	loaded.SetVerifyAfterSign(false)
+	restore = InjectFaults(&FaultInjection{Sign: func(sigBytes []byte) { sigBytes[7] ^= 0x10 }})
+	_, _, err = signMessage("faulty signature", loaded)
+	restore()
+	if err != nil {
+		t.Fatal("Failed to sign without verification -", err)
+	}
+}
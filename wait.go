@@ -0,0 +1,53 @@
+package xnyss
+
+import "context"
+
+// Signs the message like Sign, but if no node is available because nodes are
+// still waiting for confirmations, blocks until a node is confirmed (see
+// Confirm) or a reservation is released, and tries again. Returns ctx.Err() if
+// ctx is done before a signature could be created.
+//
+// Errors that waiting can not resolve, such as ErrTreeExhausted, are returned
+// immediately. Expired reservations do not wake SignOrWait, since they are only
+// reaped when signing or by ReapReservations.
+func (t *NYTree) SignOrWait(ctx context.Context, msg, txid []byte) (*Signature, error) {
+	for {
+		sig, wait, err := t.trySign(msg, txid)
+		if wait == nil {
+			return sig, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// Signs the message like Sign. If signing failed because no node is available
+// yet, returns a channel that is closed once nodes may have become available.
+func (t *NYTree) trySign(msg, txid []byte) (sig *Signature, wait <-chan struct{}, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	profile(OpSign, t, func() {
+		sig, err = t.sign(msg, txid, &signOptions{})
+	})
+	if err == ErrTreeNoneAvailable || err == ErrTreeStrictUnconfirmed {
+		if t.avail == nil {
+			t.avail = make(chan struct{})
+		}
+		wait = t.avail
+	}
+
+	return
+}
+
+// Wakes the callers of SignOrWait waiting for nodes to become available.
+func (t *NYTree) notifyAvailable() {
+	if t.avail != nil {
+		close(t.avail)
+		t.avail = nil
+	}
+}
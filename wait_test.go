@@ -0,0 +1,52 @@
+package xnyss
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNYTree_SignOrWait(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	msg := make([]byte, 32)
+
+	sig, _, err := signMessage("wait test", tree)
+	if err != nil {
+		t.Fatal("Failed to sign msg with root -", err)
+	}
+
+	// 1 - Waiting ends when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tree.SignOrWait(ctx, msg, bytes.Repeat([]byte{1}, 32)); err != context.DeadlineExceeded {
+		t.Fatal("Signing should have timed out, err was", err)
+	}
+
+	// 2 - A confirmation wakes the waiting signer
+	done := make(chan error, 1)
+	go func() {
+		_, err := tree.SignOrWait(context.Background(), msg, bytes.Repeat([]byte{2}, 32))
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal("Failed to sign after confirmation -", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Signer was not woken by the confirmation")
+	}
+
+	// 3 - Errors that waiting can not resolve are returned immediately
+	if _, err := tree.SignOrWait(context.Background(), msg, make([]byte, 1)); err != ErrInvalidTxidLen {
+		t.Fatal("Invalid txid was not rejected, err was", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Delivers XNYSS tree events to HTTP endpoints, so that upstream systems of a
+// hosted signer can react to confirmations and low capacity without polling.
+//
+// A Sender is installed as xnyss.OnEvent (or called from it). Events are queued
+// and posted as JSON by a background goroutine, so signing and confirming never
+// wait for the endpoint. When a secret is configured, every request carries an
+// HMAC-SHA256 of its body in the X-Xnyss-Signature header, hex encoded.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Re0h/xnyss"
+)
+
+var (
+	ErrDeliveryFailed = errors.New("webhook endpoint did not accept the event")
+)
+
+// Name of the header holding the HMAC of the request body.
+const SignatureHeader = "X-Xnyss-Signature"
+
+// The JSON body posted for every event.
+type Payload struct {
+	Kind       string `json:"kind"`
+	TreeID     string `json:"tree_id"`
+	PubKeyHash string `json:"pub_key_hash,omitempty"`
+	Capacity   int    `json:"capacity"`
+	Threshold  int    `json:"threshold,omitempty"`
+	Time       int64  `json:"time"`
+}
+
+// Posts events to a single URL.
+type Sender struct {
+	URL    string
+	Secret []byte
+	Client *http.Client
+	// Amount of attempts per event, and the delay between them.
+	Attempts int
+	Backoff  time.Duration
+	// Called when an event could not be delivered, or was dropped because
+	// the queue was full. May be nil.
+	OnError func(e xnyss.Event, err error)
+	// The clock of the times of payloads. May be nil, for xnyss.SystemClock.
+	Clock xnyss.Clock
+
+	queue   chan xnyss.Event
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+// Creates a sender posting to url, and starts its delivery goroutine. At most
+// queueLen events are buffered; further events are dropped until the queue
+// drains.
+func New(url string, secret []byte, queueLen int) *Sender {
+	s := &Sender{
+		URL:      url,
+		Secret:   secret,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Attempts: 3,
+		Backoff:  time.Second,
+		queue:    make(chan xnyss.Event, queueLen),
+		done:     make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Queues the event e for delivery. It has the signature of xnyss.OnEvent.
+func (s *Sender) Notify(e xnyss.Event) {
+	select {
+	case s.queue <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		if s.OnError != nil {
+			s.OnError(e, errors.New("webhook queue is full"))
+		}
+	}
+}
+
+// Returns the amount of events dropped because the queue was full.
+func (s *Sender) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Stops accepting events, and waits until the queued events are delivered. The
+// sender must not be notified after it was closed.
+func (s *Sender) Close() {
+	s.once.Do(func() { close(s.queue) })
+	<-s.done
+}
+
+func (s *Sender) run() {
+	defer close(s.done)
+
+	for e := range s.queue {
+		if err := s.Deliver(e); err != nil && s.OnError != nil {
+			s.OnError(e, err)
+		}
+	}
+}
+
+func (s *Sender) now() time.Time {
+	if s.Clock == nil {
+		return xnyss.SystemClock.Now()
+	}
+
+	return s.Clock.Now()
+}
+
+// Posts the event e synchronously, retrying until it is accepted with a 2xx
+// status or the attempts are used up.
+func (s *Sender) Deliver(e xnyss.Event) error {
+	body, err := json.Marshal(&Payload{
+		Kind:       e.Kind,
+		TreeID:     e.TreeID,
+		PubKeyHash: hex.EncodeToString(e.PubKeyHash),
+		Capacity:   e.Capacity,
+		Threshold:  e.Threshold,
+		Time:       s.now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ErrDeliveryFailed
+	for attempt := 0; attempt < s.Attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.Backoff)
+		}
+
+		if err = s.post(body); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (s *Sender) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != nil {
+		req.Header.Set(SignatureHeader, Sign(s.Secret, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ErrDeliveryFailed
+	}
+
+	return nil
+}
+
+// Returns the hex encoded HMAC-SHA256 of body under secret, as sent in the
+// SignatureHeader. Receivers should compare it using hmac.Equal.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Re0h/xnyss"
+)
+
+func TestSender(t *testing.T) {
+	secret := []byte("webhook secret")
+	received := make(chan *Payload, 10)
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !hmac.Equal([]byte(r.Header.Get(SignatureHeader)), []byte(Sign(secret, body))) {
+			t.Error("Invalid signature header")
+		}
+
+		// The first delivery fails, and must be retried
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		p := &Payload{}
+		if err := json.Unmarshal(body, p); err != nil {
+			t.Error("Invalid payload -", err)
+		}
+		received <- p
+	}))
+	defer server.Close()
+
+	sender := New(server.URL, secret, 10)
+	sender.Backoff = time.Millisecond
+	sender.OnError = func(e xnyss.Event, err error) { t.Error("Failed to deliver", e.Kind, "-", err) }
+
+	xnyss.OnEvent = sender.Notify
+	defer func() { xnyss.OnEvent = nil }()
+
+	// 1 - Confirming the child of the root emits a confirmation, and restores
+	// capacity
+	seeds := make([]byte, 64)
+	if _, err := rand.Read(seeds); err != nil {
+		t.Fatal(err)
+	}
+	tree := xnyss.New(seeds[:32], seeds[32:], false)
+	sig, err := tree.Sign(make([]byte, 32), make([]byte, 32))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	tree.Confirm(sig.ChildHashes[0], xnyss.ConfirmsRequired)
+	sender.Close()
+
+	if len(received) != 3 {
+		t.Fatal("Received", len(received), "events, expected 3")
+	}
+	if p := <-received; p.Kind != xnyss.EventCapacityLow || p.TreeID != tree.ID() || p.Threshold != 1 {
+		t.Fatal("Invalid first event", p)
+	}
+	if p := <-received; p.Kind != xnyss.EventConfirmed || p.Capacity != 1 {
+		t.Fatal("Invalid second event", p)
+	}
+	if p := <-received; p.Kind != xnyss.EventCapacityRestored || p.Threshold != 1 {
+		t.Fatal("Invalid third event", p)
+	}
+}
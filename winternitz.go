@@ -0,0 +1,109 @@
+package xnyss
+
+import "github.com/Re0h/xnyss/wotsp"
+
+var (
+	ErrWinternitzInvalid = newError(ErrCrypto, "Winternitz parameter must be 4, 16 or 256")
+)
+
+// The Winternitz parameter of trees that do not set one.
+const DefaultWinternitz = 256
+
+// Sets the Winternitz parameter of the WOTS+ keys of the fresh tree t to w,
+// which must be 4, 16 or 256 (the default). Smaller values of w create larger
+// signatures that are faster to create and verify: signatures of w=16 are about
+// twice as large as those of w=256, and take about an eighth of the time.
+//
+// Changing w changes the long-term public key, so returns ErrTreeNotFresh if t
+// was used, and ErrSeedProviderTree if the seeds of t are held by a seed
+// provider. Signatures of trees that do not use the default must be transferred
+// using Signature.Envelope, or decoded with NewSignatureWinternitz.
+func (t *NYTree) SetWinternitz(w int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := validWinternitz(w); err != nil {
+		return err
+	}
+	if w == DefaultWinternitz {
+		w = 0
+	}
+	if int(t.w) == w {
+		return nil
+	}
+	if !t.fresh() {
+		return ErrTreeNotFresh
+	}
+	if t.providerSeeds {
+		return ErrSeedProviderTree
+	}
+
+	t.w = uint16(w)
+	t.nodes[0].w = uint16(w)
+	t.nodes[0].pkh = nil
+	t.nodes[0].dropKey()
+	t.dropIndex()
+	t.epoch++
+
+	return nil
+}
+
+// Returns the Winternitz parameter of the keys of the tree t.
+func (t *NYTree) Winternitz() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return winternitz(t.w)
+}
+
+// Returns the Winternitz parameter of the tree that created the signature sig.
+func (sig *Signature) Winternitz() int {
+	return winternitz(sig.w)
+}
+
+// Like NewSignature, for signatures created by trees with Winternitz parameter
+// w, see NYTree.SetWinternitz.
+func NewSignatureWinternitz(w int, sigBytes, msg []byte) (*Signature, error) {
+	if err := validWinternitz(w); err != nil {
+		return nil, err
+	}
+	if w == DefaultWinternitz {
+		w = 0
+	}
+
+	return newSignature(uint16(w), sigBytes, msg)
+}
+
+func validWinternitz(w int) error {
+	if w != 4 && w != 16 && w != 256 {
+		return ErrWinternitzInvalid
+	}
+
+	return nil
+}
+
+// Returns the Winternitz parameter stored as w, where zero is the default.
+func winternitz(w uint16) int {
+	if w == 0 {
+		return DefaultWinternitz
+	}
+
+	return int(w)
+}
+
+// Returns the WOTS+ parameter set for Winternitz parameter w and suite.
+func wotsParams(w uint16, suite HashSuite) wotsp.Params {
+	return wotsp.Params{W: winternitz(w), N: 32, Hash: suite.hash()}
+}
+
+func (t *NYTree) wots() wotsp.Params {
+	return wotsParams(t.w, t.suite)
+}
+
+func (n *nyNode) wots() wotsp.Params {
+	return wotsParams(n.w, n.suite)
+}
+
+func (sig *Signature) wots() wotsp.Params {
+	return wotsParams(sig.w, sig.suite)
+}
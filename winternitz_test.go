@@ -0,0 +1,94 @@
+package xnyss
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/testdata"
+)
+
+func TestNYTree_SetWinternitz(t *testing.T) {
+	seed, pubSeed, err := genSeeds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := New(seed, pubSeed, false)
+	if err := tree.SetWinternitz(16); err != nil {
+		t.Fatal("Failed to set Winternitz parameter -", err)
+	}
+	if tree.Winternitz() != 16 || New(seed, pubSeed, false).Winternitz() != DefaultWinternitz {
+		t.Fatal("Invalid Winternitz parameters")
+	}
+	if err := tree.SetWinternitz(8); err != ErrWinternitzInvalid {
+		t.Fatal("Set invalid Winternitz parameter, err was", err)
+	}
+
+	// 1 - The parameter changes the long-term public key, and its length
+	if len(tree.PublicKey()) != 67*32 || bytes.Equal(tree.PublicKey(), New(seed, pubSeed, false).PublicKey()) {
+		t.Fatal("Winternitz parameter did not change the public key")
+	}
+
+	// 2 - Chains of signatures verify, and record the parameter in the envelope
+	var chain []*Signature
+	for i := 0; i < 2; i++ {
+		sig, err := tree.Sign(testdata.Message, Txid("winternitz", []byte{byte(i)}))
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		tree.Confirm(sig.ChildHashes[0], ConfirmsRequired)
+		chain = append(chain, sig)
+	}
+	if err := VerifyChain(tree.PublicKey(), chain, testdata.Message); err != nil {
+		t.Fatal("Failed to verify chain -", err)
+	}
+	parsed, err := ParseEnvelope(chain[1].Envelope(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to parse envelope -", err)
+	}
+	if p, ok := parsed.Params(); !ok || p.Winternitz != 16 || parsed.Winternitz() != 16 {
+		t.Fatal("Winternitz parameter was not included in the envelope")
+	}
+
+	// 3 - Signatures decoded from bytes need the parameter
+	if plain, err := NewSignature(chain[0].Bytes(), testdata.Message); err == nil {
+		if pk, _ := plain.PublicKey(); bytes.Equal(pk, tree.PublicKey()) {
+			t.Fatal("Verified signature with the wrong Winternitz parameter")
+		}
+	}
+	decoded, err := NewSignatureWinternitz(16, chain[0].Bytes(), testdata.Message)
+	if err != nil {
+		t.Fatal("Failed to decode signature -", err)
+	}
+	if !bytes.Equal(mustPublicKey(t, decoded), tree.PublicKey()) {
+		t.Fatal("Failed to verify decoded signature")
+	}
+
+	// 4 - Chains can not mix parameters
+	other := New(seed, pubSeed, false)
+	sig, err := other.Sign(testdata.Message, testdata.Txid)
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if err := VerifyChain(other.PublicKey(), []*Signature{sig, chain[1]}, testdata.Message); err != ErrSchemeMismatch {
+		t.Fatal("Verified chain mixing Winternitz parameters, err was", err)
+	}
+
+	// 5 - The parameter is persisted, and can not change once the tree was used
+	loaded, err := Load(tree.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load tree -", err)
+	}
+	if loaded.Winternitz() != 16 {
+		t.Fatal("Winternitz parameter was not persisted")
+	}
+	sig, err = loaded.Sign(testdata.Message, Txid("winternitz", []byte{2}))
+	if err != nil {
+		t.Fatal("Failed to sign -", err)
+	}
+	if !hasChild(chain[0], mustPublicKey(t, sig)) && !hasChild(chain[1], mustPublicKey(t, sig)) {
+		t.Fatal("Loaded tree did not sign with a child node")
+	}
+	if err := loaded.SetWinternitz(DefaultWinternitz); err != ErrTreeNotFresh {
+		t.Fatal("Changed Winternitz parameter of used tree, err was", err)
+	}
+}
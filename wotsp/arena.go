@@ -0,0 +1,149 @@
+package wotsp
+
+import (
+	"hash"
+	"reflect"
+	"sync"
+)
+
+// The size of the buffers allocated by an Arena.
+const arenaChunkLen = 64 << 10
+
+// An Arena holds the buffers and hash functions used by a burst of
+// verifications, e.g. of all signatures in a block, so they are reused rather
+// than allocated for every call to PkFromSig. Public keys computed using an
+// arena live in its buffers, and must not be used after Release. An Arena is
+// not safe for concurrent use.
+type Arena struct {
+	chunks [][]byte
+	chunk  int
+	off    int
+
+	// Hash functions by the code pointer of the Hash that created them, and
+	// the amount of them in use
+	hashes map[uintptr][]hash.Hash
+	used   map[uintptr]int
+
+	// Hasher structs, and the amount of them in use
+	hashers     []*hasher
+	hashersUsed int
+
+	// The parameter sets derived by derive
+	derived []*derived
+}
+
+// Arenas used by GenPublicKey, Sign and PkFromSig, whose results are not
+// returned in arena buffers, so the buffers can be reused by the next call.
+var arenas = sync.Pool{New: func() any { return NewArena() }}
+
+// Returns an arena from the pool.
+func getArena() *Arena {
+	return arenas.Get().(*Arena)
+}
+
+// Releases the arena a and returns it to the pool. Buffers and hash functions
+// that held secret data must have been wiped, see PrivateKey.Wipe.
+func putArena(a *Arena) {
+	a.Release()
+	arenas.Put(a)
+}
+
+// Creates an empty arena.
+func NewArena() *Arena {
+	return &Arena{
+		hashes: make(map[uintptr][]hash.Hash),
+		used:   make(map[uintptr]int),
+	}
+}
+
+// Releases everything allocated from the arena since the last call to Release,
+// so the next verifications reuse it.
+func (a *Arena) Release() {
+	a.chunk, a.off = 0, 0
+	a.hashersUsed = 0
+	for k := range a.used {
+		a.used[k] = 0
+	}
+}
+
+// Like PkFromSig, computing the public key in buffers of the arena a.
+func (p Params) PkFromSigArena(a *Arena, sig, msg, pubSeed []byte, adrs *Address) []byte {
+	d := p.derive()
+	if !d.validInput(sig, msg) {
+		return nil
+	}
+	numRoutines := routines()
+	h := precomputeIn(a, d, nil, pubSeed, numRoutines)
+
+	// Compute chain lengths
+	lengths := h.lengths(msg)
+
+	// Compute public key
+	pubKey := h.alloc(h.l * h.n)
+	computeChains(h, numRoutines, sig, pubKey, lengths, adrs, true)
+
+	return pubKey
+}
+
+// Returns a buffer of length n from the arena.
+func (a *Arena) bytes(n int) []byte {
+	for a.chunk < len(a.chunks) && a.off+n > len(a.chunks[a.chunk]) {
+		a.chunk++
+		a.off = 0
+	}
+	if a.chunk == len(a.chunks) {
+		size := arenaChunkLen
+		if n > size {
+			size = n
+		}
+		a.chunks = append(a.chunks, make([]byte, size))
+	}
+
+	b := a.chunks[a.chunk][a.off : a.off+n : a.off+n]
+	a.off += n
+
+	return b
+}
+
+// Returns a hasher struct from the arena, to be initialised by precomputeIn.
+func (a *Arena) hasher() *hasher {
+	if a.hashersUsed == len(a.hashers) {
+		a.hashers = append(a.hashers, new(hasher))
+	}
+	a.hashersUsed++
+
+	return a.hashers[a.hashersUsed-1]
+}
+
+// Like p.derive, returning the parameters derived for an earlier call with the
+// same parameter set, so they are only allocated and validated once.
+func (a *Arena) derive(p Params) *derived {
+	p = p.withDefaults()
+	hash := reflect.ValueOf(p.Hash).Pointer()
+	for _, d := range a.derived {
+		if d.w == p.W && d.n == p.N && reflect.ValueOf(d.hash).Pointer() == hash {
+			return d
+		}
+	}
+
+	d := p.derive()
+	a.derived = append(a.derived, d)
+
+	return d
+}
+
+// Returns a reset hash function created by newHash from the arena.
+func (a *Arena) hash(newHash Hash) hash.Hash {
+	key := reflect.ValueOf(newHash).Pointer()
+
+	used := a.used[key]
+	if used == len(a.hashes[key]) {
+		a.hashes[key] = append(a.hashes[key], newHash())
+	}
+	a.used[key] = used + 1
+
+	h := a.hashes[key][used]
+	h.Reset()
+
+	return h
+}
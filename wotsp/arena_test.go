@@ -0,0 +1,81 @@
+package wotsp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestArena(t *testing.T) {
+	a := NewArena()
+
+	// 1 - Public keys computed in the arena are correct, also after Release
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			pubKey := W16.PkFromSigArena(a, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+			if !bytes.Equal(pubKey, testdata.PubKey) {
+				t.Fatal("Wrong public key")
+			}
+		}
+		a.Release()
+	}
+
+	// 2 - Released buffers are reused
+	if raceEnabled {
+		t.Skip("Allocation counts are not reliable under the race detector")
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		W16.PkFromSigArena(a, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+		a.Release()
+	})
+	plain := testing.AllocsPerRun(10, func() {
+		PkFromSig(testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+	})
+	if allocs >= plain {
+		t.Fatal("Arena did not reduce allocations:", allocs, "vs", plain)
+	}
+}
+
+func TestArena_Pool(t *testing.T) {
+	// 1 - Concurrent calls using pooled arenas compute correct results
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func() {
+			ok := true
+			for j := 0; j < 4; j++ {
+				ok = ok && bytes.Equal(Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature)
+				ok = ok && bytes.Equal(GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{}), testdata.PubKey)
+			}
+			done <- ok
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if !<-done {
+			t.Fatal("Wrong result using pooled arena")
+		}
+	}
+
+	// 2 - Arenas are returned to the pool without the expanded key
+	k := NewPrivateKey(testdata.Seed, testdata.PubSeed)
+	privKey := append([]byte(nil), k.privKey...)
+	k.Wipe()
+	Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+	a := getArena()
+	defer putArena(a)
+	for _, chunk := range a.chunks {
+		if bytes.Contains(chunk, privKey[:n]) {
+			t.Fatal("Pooled arena holds the expanded key")
+		}
+	}
+}
+
+func BenchmarkPkFromSigArena(b *testing.B) {
+	b.ReportAllocs()
+	a := NewArena()
+
+	for i := 0; i < b.N; i++ {
+		_ = W16.PkFromSigArena(a, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+		a.Release()
+	}
+}
@@ -0,0 +1,117 @@
+package wotsp
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+)
+
+// A signature verified by VerifyBatch, with the arguments of Verify. A nil
+// Address is the all-zero address.
+type VerifyItem struct {
+	PubKey  []byte
+	Sig     []byte
+	Msg     []byte
+	PubSeed []byte
+	Address *Address
+}
+
+// The number of public seeds whose hash precomputations a worker of
+// VerifyBatch keeps. Once it saw more, it starts over.
+const batchSeedCache = 64
+
+// Verifies every item like Verify, using w=16, see Params.VerifyBatch.
+func VerifyBatch(items []VerifyItem, workers int) []bool {
+	return W16.VerifyBatch(items, workers)
+}
+
+// Verifies every item like VerifyBatch until ctx is done, using w=16, see
+// Params.VerifyBatchContext.
+func VerifyBatchContext(ctx context.Context, items []VerifyItem, workers int) ([]bool, error) {
+	return W16.VerifyBatchContext(ctx, items, workers)
+}
+
+// Verifies every item like Verify, and returns whether each of them is valid.
+// Items are verified by a pool of at most workers goroutines, or GOMAXPROCS if
+// workers is not positive, which each compute the chains of their items one
+// after the other, in buffers they reuse for every item; in serial mode (see
+// Serial), a single worker runs in the calling goroutine. A worker computes
+// the hash precomputations of a public seed once for all items it verifies
+// under that seed, so items sharing public seeds are cheaper to verify in one
+// batch than one by one. Items whose signature, message or public seed has
+// the wrong length are invalid.
+func (p Params) VerifyBatch(items []VerifyItem, workers int) []bool {
+	valid, _ := p.VerifyBatchContext(context.Background(), items, workers)
+	return valid
+}
+
+// Verifies every item like VerifyBatch, but stops with ctx.Err() once ctx is
+// done. Workers check ctx between the chains they compute, and the items that
+// were not verified completely are invalid.
+func (p Params) VerifyBatchContext(ctx context.Context, items []VerifyItem, workers int) ([]bool, error) {
+	d := p.derive()
+	valid := make([]bool, len(items))
+
+	batchWorkers(len(items), workers, func(next func() int) {
+		hashers := make(map[string]*hasher)
+		pubKey := make([]byte, d.l*d.n)
+		lengths := make([]uint8, d.l)
+		scratch := make([]byte, scratchLen*d.n)
+
+		for i := next(); i >= 0; i = next() {
+			item := &items[i]
+			if len(item.Sig) != d.l*d.n || len(item.Msg) != d.n || len(item.PubSeed) != d.n {
+				continue
+			}
+
+			h, ok := hashers[string(item.PubSeed)]
+			if !ok {
+				if len(hashers) == batchSeedCache {
+					clear(hashers)
+				}
+				h = precompute(d, nil, item.PubSeed, 1)
+				hashers[string(item.PubSeed)] = h
+			}
+
+			var adrs Address
+			if item.Address != nil {
+				adrs = *item.Address
+			}
+			d.lengthsIn(lengths, item.Msg)
+			j := 0
+			for j < d.l && ctx.Err() == nil {
+				j += computeNextChains(h, 0, j, d.l, item.Sig, pubKey, scratch, lengths, &adrs, true, false)
+			}
+			valid[i] = j == d.l && bytes.Equal(pubKey, item.PubKey)
+		}
+	})
+
+	return valid, ctx.Err()
+}
+
+// Runs a pool of at most workers goroutines, or GOMAXPROCS if workers is not
+// positive, to process n items. Every worker calls next until it returns -1 to
+// take the index of the next item to process. A single worker, as in serial
+// mode, runs in the calling goroutine.
+func batchWorkers(n, workers int, worker func(next func() int)) {
+	if workers <= 0 || Serial || serialBuild {
+		workers = routines()
+	}
+	if workers > n {
+		workers = n
+	}
+
+	var taken atomic.Int64
+	next := func() int {
+		if i := int(taken.Add(1)) - 1; i < n {
+			return i
+		}
+		return -1
+	}
+
+	if workers == 1 {
+		worker(next)
+		return
+	}
+	spawnWorkers(workers, func() { worker(next) })
+}
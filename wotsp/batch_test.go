@@ -0,0 +1,77 @@
+package wotsp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	otherSeed := append([]byte(nil), testdata.PubSeed...)
+	otherSeed[0] ^= 1
+	otherAdrs := &Address{}
+	otherAdrs.SetOTS(7)
+	otherPk := GenPublicKey(testdata.Seed, otherSeed, otherAdrs)
+	otherSig := Sign(testdata.Message, testdata.Seed, otherSeed, otherAdrs)
+
+	var items []VerifyItem
+	for i := 0; i < 20; i++ {
+		items = append(items,
+			VerifyItem{PubKey: testdata.PubKey, Sig: testdata.Signature, Msg: testdata.Message, PubSeed: testdata.PubSeed},
+			VerifyItem{PubKey: otherPk, Sig: otherSig, Msg: testdata.Message, PubSeed: otherSeed, Address: otherAdrs})
+	}
+	forged := append([]byte(nil), testdata.Signature...)
+	forged[3] ^= 1
+	items = append(items,
+		VerifyItem{PubKey: testdata.PubKey, Sig: forged, Msg: testdata.Message, PubSeed: testdata.PubSeed},
+		VerifyItem{PubKey: otherPk, Sig: otherSig, Msg: testdata.Message, PubSeed: otherSeed},
+		VerifyItem{PubKey: testdata.PubKey, Sig: testdata.Signature[:n], Msg: testdata.Message, PubSeed: testdata.PubSeed})
+
+	// 1 - Every item is verified like Verify, with any number of workers
+	for _, workers := range []int{0, 1, 3, 100} {
+		valid := VerifyBatch(items, workers)
+		if len(valid) != len(items) {
+			t.Fatal("Invalid number of results")
+		}
+		for i, ok := range valid {
+			if ok != (i < 40) {
+				t.Fatal("Wrong result for item", i, "with", workers, "workers")
+			}
+		}
+	}
+
+	// 2 - Empty batches verify nothing
+	if len(VerifyBatch(nil, 4)) != 0 {
+		t.Fatal("Results for empty batch")
+	}
+}
+
+func BenchmarkVerifyBatch(b *testing.B) {
+	b.ReportAllocs()
+	items := make([]VerifyItem, 64)
+	for i := range items {
+		items[i] = VerifyItem{PubKey: testdata.PubKey, Sig: testdata.Signature, Msg: testdata.Message, PubSeed: testdata.PubSeed}
+	}
+
+	for i := 0; i < b.N; i++ {
+		_ = VerifyBatch(items, 0)
+	}
+}
+
+func TestVerifyBatchContext(t *testing.T) {
+	items := []VerifyItem{{PubKey: testdata.PubKey, Sig: testdata.Signature, Msg: testdata.Message, PubSeed: testdata.PubSeed}}
+
+	// 1 - Cancelled batches verify nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	valid, err := VerifyBatchContext(ctx, items, 1)
+	if err != context.Canceled || valid[0] {
+		t.Fatal("Verified with cancelled context", err, valid[0])
+	}
+
+	// 2 - Batches with a live context verify their items
+	if valid, err := VerifyBatchContext(context.Background(), items, 1); err != nil || !valid[0] {
+		t.Fatal("Failed to verify batch", err, valid[0])
+	}
+}
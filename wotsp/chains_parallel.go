@@ -0,0 +1,58 @@
+//go:build !wotspserial && !tinygo
+
+package wotsp
+
+import "sync"
+
+// Whether the package was built for serial mode only, see Serial.
+const serialBuild = false
+
+// Computes the chains for computeChains, distributed between numRoutines
+// goroutines that each use the hasher of their routine.
+func computeChainsParallel(h *hasher, numRoutines int, in, out []byte, lengths []uint8, adrs *Address, fromSig bool) {
+	l, n := h.l, h.n
+	constantTime := ConstantTime
+	chainsPerRoutine := (l-1)/numRoutines + 1
+
+	// Initialise scratch pad
+	scratch := h.alloc(numRoutines * scratchLen * n)
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < numRoutines; i++ {
+		// Copy address structure
+		chainAdrs := new(Address)
+		copy(chainAdrs.data[:], adrs.data[:])
+
+		wg.Add(1)
+		go func(nr int, scratch []byte, adrs *Address) {
+			firstChain := nr * chainsPerRoutine
+			lastChain := firstChain + chainsPerRoutine - 1
+
+			// Make sure the last routine ends at the right chain
+			if lastChain >= l {
+				lastChain = l - 1
+			}
+
+			// Compute the hash chains
+			for j := firstChain; j <= lastChain; {
+				j += computeNextChains(h, nr, j, lastChain+1, in, out, scratch, lengths, adrs, fromSig, constantTime)
+			}
+			wg.Done()
+		}(i, scratch[i*scratchLen*n:(i+1)*scratchLen*n], chainAdrs)
+	}
+
+	wg.Wait()
+}
+
+// Runs f in workers goroutines, and waits for them to return.
+func spawnWorkers(workers int, f func()) {
+	wg := new(sync.WaitGroup)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f()
+		}()
+	}
+	wg.Wait()
+}
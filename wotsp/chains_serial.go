@@ -0,0 +1,16 @@
+//go:build wotspserial || tinygo
+
+package wotsp
+
+// Whether the package was built for serial mode only, see Serial.
+const serialBuild = true
+
+// Serial builds compute all chains in the calling goroutine, see Serial.
+func computeChainsParallel(h *hasher, numRoutines int, in, out []byte, lengths []uint8, adrs *Address, fromSig bool) {
+	computeChainsSerial(h, in, out, lengths, adrs, fromSig)
+}
+
+// Serial builds run a single worker in the calling goroutine, see Serial.
+func spawnWorkers(workers int, f func()) {
+	f()
+}
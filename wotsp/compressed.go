@@ -0,0 +1,219 @@
+package wotsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+)
+
+var (
+	ErrCompressionInvalid = errors.New("invalid WOTS+C compression parameters")
+	ErrCounterExhausted   = errors.New("WOTS+C counter exhausted before a valid digest was found")
+	ErrCounterInvalid     = errors.New("WOTS+C counter does not give a valid digest")
+)
+
+// The length of the counter that prefixes compressed signatures.
+const CounterLen = 4
+
+// A parameter set for WOTS+C, the compressed WOTS+ variant of SPHINCS+C.
+// Signing hashes the message with a counter, and increments the counter until
+// the base-w digits of the digest sum to a fixed target, and their last Zeros
+// digits are zero. Since every digest has the same digit sum, increasing one
+// digit of a signature requires decreasing another, so the checksum chains are
+// not needed, and neither are the chains of the zero digits: keys and
+// signatures hold l1-Zeros chains rather than l, and signatures are prefixed
+// with the counter.
+//
+// The price is paid when signing: about a hundred digests are tried for w=16
+// and a thousand for w=256 to hit the target sum, and every zero digit
+// multiplies that by w. Verification computes a single digest for the counter
+// of the signature. Keys of a Compressed set can not verify plain WOTS+
+// signatures, nor the other way around.
+type Compressed struct {
+	Params
+	// The amount of message chains that are dropped in addition to the checksum
+	// chains. Zeros*log2(W) may be at most 16.
+	Zeros int
+	// The amount of counters tried when signing, or 0 to try all 2^32.
+	MaxCounter uint32
+}
+
+// Returns ErrParamsInvalid if the Params of c are not valid, and
+// ErrCompressionInvalid if Zeros is not. The functions of c panic if it is not
+// valid.
+func (c Compressed) Validate() error {
+	if err := c.Params.Validate(); err != nil {
+		return err
+	}
+
+	if c.Zeros < 0 || c.Zeros*c.Params.derive().logw > 16 {
+		return ErrCompressionInvalid
+	}
+
+	return nil
+}
+
+// Returns the length of signatures, including the counter.
+func (c Compressed) SigLen() int {
+	d := c.derive()
+	return CounterLen + d.l*d.n
+}
+
+// Returns the length of public keys.
+func (c Compressed) PubKeyLen() int {
+	d := c.derive()
+	return d.l * d.n
+}
+
+// Returns the derived values of c, whose l is the amount of chains of keys
+// and signatures rather than that of plain WOTS+.
+func (c Compressed) derive() *derived {
+	if err := c.Validate(); err != nil {
+		panic(err)
+	}
+
+	d := *c.Params.derive()
+	d.l = d.l1 - c.Zeros
+	d.l2 = 0
+
+	return &d
+}
+
+// Returns the digit sum that digests must have to be signed.
+func (c Compressed) target(d *derived) int {
+	return d.l * (d.w - 1) / 2
+}
+
+// Computes the public key that corresponds to the expanded seed.
+func (c Compressed) GenPublicKey(seed, pubSeed []byte, adrs *Address) []byte {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, c.derive(), seed, pubSeed, numRoutines)
+
+	return genPublicKey(h, numRoutines, adrs)
+}
+
+// Signs message msg using the private key generated using the given seed.
+// Returns ErrCounterExhausted if none of the MaxCounter counters gives a digest
+// that can be signed.
+func (c Compressed) Sign(msg, seed, pubSeed []byte, adrs *Address) ([]byte, error) {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, c.derive(), seed, pubSeed, numRoutines)
+
+	// The counter only depends on public values, so it is found before the
+	// private key is expanded
+	lengths := h.alloc(h.l1)
+	digest := newCounterDigest(h, msg, pubSeed, adrs)
+	counter := uint32(0)
+	for !digest.lengths(counter, c.target(h.derived), lengths) {
+		if counter == c.MaxCounter-1 {
+			h.wipe()
+			return nil, ErrCounterExhausted
+		}
+		counter++
+	}
+
+	k := newPrivateKey(h, numRoutines)
+	defer k.Wipe()
+
+	sig := make([]byte, CounterLen+h.l*h.n)
+	binary.BigEndian.PutUint32(sig, counter)
+	computeChains(h, numRoutines, k.privKey, sig[CounterLen:], lengths, adrs, false)
+
+	return sig, nil
+}
+
+// Generates a public key from the given signature. Returns ErrCounterInvalid
+// if the counter of sig does not give a digest that can be signed, and
+// ErrShortBuffer if sig is shorter than SigLen.
+func (c Compressed) PkFromSig(sig, msg, pubSeed []byte, adrs *Address) ([]byte, error) {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	d := c.derive()
+	if len(sig) < CounterLen+d.l*d.n {
+		return nil, ErrShortBuffer
+	}
+	h := precomputeIn(a, d, nil, pubSeed, numRoutines)
+
+	// Compute chain lengths
+	lengths := h.alloc(h.l1)
+	counter := binary.BigEndian.Uint32(sig)
+	if !newCounterDigest(h, msg, pubSeed, adrs).lengths(counter, c.target(d), lengths) {
+		return nil, ErrCounterInvalid
+	}
+
+	// Compute public key
+	pubKey := make([]byte, h.l*h.n)
+	computeChains(h, numRoutines, sig[CounterLen:], pubKey, lengths, adrs, true)
+
+	return pubKey, nil
+}
+
+// Verifies the given signature on the given message.
+func (c Compressed) Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
+	pubKey, err := c.PkFromSig(sig, msg, pubSeed, adrs)
+
+	return err == nil && bytes.Equal(pk, pubKey)
+}
+
+// The digest of a message under the counters tried by Compressed.Sign:
+// H(toByte(4, n) || pubSeed || adrs || msg || counter), where the padding
+// separates it from F and PRF, and the public seed and address from the
+// digests of other keys. The digest of the prefix is precomputed, like the
+// digests of the hasher.
+type counterDigest struct {
+	h       *hasher
+	precomp reflect.Value
+	sum     []byte
+	digits  []uint8
+}
+
+func newCounterDigest(h *hasher, msg, pubSeed []byte, adrs *Address) *counterDigest {
+	padding := h.alloc(h.n)
+	wipe(padding)
+	binary.BigEndian.PutUint16(padding[h.n-2:], uint16(4))
+
+	prefix := h.hash()
+	prefix.Write(padding)
+	prefix.Write(pubSeed)
+	prefix.Write(adrs.ToBytes())
+	prefix.Write(msg)
+
+	return &counterDigest{
+		h:       h,
+		precomp: reflect.ValueOf(prefix).Elem(),
+		sum:     h.alloc(h.n),
+		digits:  h.alloc(h.l1),
+	}
+}
+
+// Computes the digest for counter, and reports whether its digits sum to
+// target with the trailing zero digits of the parameter set. If so, the chain
+// lengths of the signature are copied into lengths.
+func (c *counterDigest) lengths(counter uint32, target int, lengths []uint8) bool {
+	var ctr [CounterLen]byte
+	binary.BigEndian.PutUint32(ctr[:], counter)
+
+	c.h.hasherVal[0].Set(c.precomp)
+	c.h.hasher[0].Write(ctr[:])
+	c.h.baseW(c.h.hasher[0].Sum(c.sum[:0]), c.digits)
+
+	sum := 0
+	for i, digit := range c.digits {
+		if i >= c.h.l && digit != 0 {
+			return false
+		}
+		sum += int(digit)
+	}
+	if sum != target {
+		return false
+	}
+
+	copy(lengths, c.digits[:c.h.l])
+	return true
+}
@@ -0,0 +1,73 @@
+package wotsp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestCompressed(t *testing.T) {
+	for _, c := range []Compressed{{Params: W4}, {Params: W16}, {Params: W16, Zeros: 1}, {Params: W256}} {
+		pk := c.GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})
+		sig, err := c.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+		if err != nil {
+			t.Fatal("Failed to sign for w =", c.W, "-", err)
+		}
+
+		// 1 - Keys and signatures drop the checksum and zero digit chains
+		d := c.Params.derive()
+		if len(pk) != c.PubKeyLen() || len(sig) != c.SigLen() ||
+			c.PubKeyLen() != (d.l1-c.Zeros)*n || c.SigLen() >= c.Params.SigLen() {
+			t.Fatal("Invalid lengths for w =", c.W)
+		}
+
+		// 2 - Signatures verify, and signing is deterministic
+		if !c.Verify(pk, sig, testdata.Message, testdata.PubSeed, &Address{}) {
+			t.Fatal("Valid signature rejected for w =", c.W)
+		}
+		again, _ := c.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+		if !bytes.Equal(sig, again) {
+			t.Fatal("Signing is not deterministic for w =", c.W)
+		}
+
+		// 3 - Signatures with another counter, chain or message are rejected
+		forged := append([]byte(nil), sig...)
+		forged[CounterLen-1] ^= 1
+		if _, err := c.PkFromSig(forged, testdata.Message, testdata.PubSeed, &Address{}); err != ErrCounterInvalid {
+			t.Fatal("Expected ErrCounterInvalid, got", err)
+		}
+		forged = append([]byte(nil), sig...)
+		forged[CounterLen] ^= 1
+		if c.Verify(pk, forged, testdata.Message, testdata.PubSeed, &Address{}) ||
+			c.Verify(pk, sig, testdata.PubSeed, testdata.PubSeed, &Address{}) {
+			t.Fatal("Forged signature accepted for w =", c.W)
+		}
+		if _, err := c.PkFromSig(sig[:len(sig)-1], testdata.Message, testdata.PubSeed, &Address{}); err != ErrShortBuffer {
+			t.Fatal("Expected ErrShortBuffer, got", err)
+		}
+	}
+
+	// 4 - Signing fails once MaxCounter counters were tried
+	c := Compressed{Params: W256, Zeros: 2, MaxCounter: 1}
+	if _, err := c.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}); err != ErrCounterExhausted {
+		t.Fatal("Expected ErrCounterExhausted, got", err)
+	}
+
+	// 5 - Invalid parameters are rejected
+	for _, c := range []Compressed{{Params: W16, Zeros: -1}, {Params: W16, Zeros: 5}, {Params: W256, Zeros: 3}} {
+		if err := c.Validate(); err != ErrCompressionInvalid {
+			t.Fatal("Invalid compression accepted -", c.W, c.Zeros)
+		}
+	}
+	if err := (Compressed{Params: Params{W: 8}}).Validate(); err != ErrParamsInvalid {
+		t.Fatal("Expected ErrParamsInvalid, got", err)
+	}
+}
+
+func BenchmarkCompressed_Sign(b *testing.B) {
+	c := Compressed{Params: W16}
+	for i := 0; i < b.N; i++ {
+		_, _ = c.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+	}
+}
@@ -0,0 +1,50 @@
+package wotsp
+
+import (
+	"crypto/subtle"
+)
+
+// Denotes whether Sign runs in constant time with respect to the message.
+//
+// None of the computations on secret data branch on it: the hash chains, the
+// base-w conversion and the checksum only use the message to decide how often
+// to iterate, and the private key only ever passes through the hash function
+// and XOR. The message is public once the signature is, but by default the
+// time Sign takes does depend on it, since every chain is only computed up to
+// the message digit. When ConstantTime is set, every chain is computed to its
+// full length and the signature values are selected with constant-time copies,
+// so the timing reveals nothing about a message before it is published. This
+// doubles the cost of Sign on average.
+//
+// ConstantTime applies to all parameter sets, including those of package
+// wotsp256.
+var ConstantTime = false
+
+// Computes the full chain starting at in, and copies the element at position
+// length into out. Every iteration performs the same operations, regardless of
+// length. The current element is kept in the scratch pad, and wiped afterwards.
+func chainSelect(h *hasher, routineNr int, in, out, scratch []byte, length uint8, adrs *Address) {
+	n := h.n
+	cur := scratch[2*n : 3*n]
+	defer wipe(cur)
+	copy(cur, in)
+	copy(out, in)
+
+	for i := 0; i < h.w-1; i++ {
+		adrs.setHash(uint32(i))
+
+		adrs.setKeyAndMask(0)
+		h.prfPubSeed(routineNr, adrs, scratch[:n])
+		adrs.setKeyAndMask(1)
+		h.prfPubSeed(routineNr, adrs, scratch[n:2*n])
+
+		for j := 0; j < n; j++ {
+			cur[j] = cur[j] ^ scratch[n+j]
+		}
+
+		h.hashF(routineNr, scratch[:n], cur)
+
+		// After i+1 iterations, cur is the element at position i+1
+		subtle.ConstantTimeCopy(subtle.ConstantTimeByteEq(uint8(i+1), length), out, cur)
+	}
+}
@@ -0,0 +1,185 @@
+package wotsp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+// The parameter sets that are cross-checked against each other.
+var differentialParams = []Params{W4, W16, W256}
+
+// Returns count random n-byte inputs, preceded by the inputs at the boundaries
+// of the chains: all-zero digits and all-maximum digits.
+func differentialInputs(t *testing.T, count int) [][]byte {
+	inputs := [][]byte{make([]byte, n), bytes.Repeat([]byte{0xff}, n)}
+	for i := 0; i < count; i++ {
+		b := make([]byte, n)
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, b)
+	}
+
+	return inputs
+}
+
+// Packs base-w digits back into bytes, most significant digit first.
+func packDigits(logw int, digits []uint8) []byte {
+	b := make([]byte, len(digits)*logw/8)
+	for i, digit := range digits {
+		b[i*logw/8] = b[i*logw/8]<<uint(logw) | digit
+	}
+
+	return b
+}
+
+// Returns the value of the base-w digits of x.
+func digitsValue(w int, x []uint8) int {
+	v := 0
+	for _, digit := range x {
+		v = v*w + int(digit)
+	}
+
+	return v
+}
+
+func TestDifferential_Lengths(t *testing.T) {
+	inputs := differentialInputs(t, 64)
+
+	for _, p := range differentialParams {
+		d := p.derive()
+
+		for _, msg := range inputs {
+			lengths := d.lengths(msg)
+
+			// 1 - There are l digits, none of which exceed w-1
+			if len(lengths) != d.l {
+				t.Fatal("Invalid number of lengths for w =", p.W)
+			}
+			for _, length := range lengths {
+				if int(length) > d.w-1 {
+					t.Fatal("Length exceeds w-1 for w =", p.W)
+				}
+			}
+
+			// 2 - The message digits encode msg
+			if !bytes.Equal(packDigits(d.logw, lengths[:d.l1]), msg) {
+				t.Fatal("Message digits do not encode the message for w =", p.W)
+			}
+
+			// 3 - The checksum digits encode the full checksum, without
+			// truncation
+			csum := 0
+			for _, digit := range lengths[:d.l1] {
+				csum += d.w - 1 - int(digit)
+			}
+			if digitsValue(d.w, lengths[d.l1:]) != csum {
+				t.Fatal("Checksum digits do not encode the checksum for w =", p.W)
+			}
+		}
+	}
+}
+
+func TestDifferential_ChecksumForgery(t *testing.T) {
+	inputs := differentialInputs(t, 64)
+
+	for _, p := range differentialParams {
+		d := p.derive()
+
+		for _, msg := range inputs {
+			lengths := d.lengths(msg)
+
+			// Raise the message digits by a total of w, the smallest increase
+			// that would go unnoticed if the checksum were truncated to its
+			// lowest digit
+			raised := append([]uint8{}, lengths[:d.l1]...)
+			for i, todo := 0, d.w; i < d.l1 && todo > 0; i++ {
+				step := d.w - 1 - int(raised[i])
+				if step > todo {
+					step = todo
+				}
+				raised[i] += uint8(step)
+				todo -= step
+			}
+			if bytes.Equal(raised, lengths[:d.l1]) {
+				continue
+			}
+			forged := d.lengths(packDigits(d.logw, raised))
+
+			// 1 - Some checksum chain must be shorter, so the signature of
+			// msg cannot be completed into a signature of the raised message
+			lower := false
+			for i := d.l1; i < d.l; i++ {
+				lower = lower || forged[i] < lengths[i]
+			}
+			if !lower {
+				t.Fatal("Raised message digits did not lower the checksum for w =", p.W)
+			}
+		}
+	}
+}
+
+func TestDifferential_ChainBoundaries(t *testing.T) {
+	inputs := differentialInputs(t, 1)
+	in, pubSeed := inputs[2], inputs[1]
+
+	for _, p := range differentialParams {
+		d := p.derive()
+		h := precompute(d, nil, pubSeed, 1)
+		scratch := make([]byte, scratchLen*d.n)
+		end := uint8(d.w - 1)
+
+		full := make([]byte, d.n)
+		chain(h, 0, in, full, scratch, 0, end, &Address{})
+
+		// 1 - Splitting the chain anywhere gives the same end, including the
+		// splits where start+steps is w-1, which is 255 for w=256
+		for _, start := range []uint8{0, 1, end / 2, end - 1, end} {
+			mid := make([]byte, d.n)
+			out := make([]byte, d.n)
+			chain(h, 0, in, mid, scratch, 0, start, &Address{})
+			chain(h, 0, mid, out, scratch, start, end-start, &Address{})
+
+			if !bytes.Equal(out, full) {
+				t.Fatal("Split chain differs at", start, "for w =", p.W)
+			}
+		}
+
+		// 2 - The constant-time chain agrees at the boundaries
+		for _, length := range []uint8{0, 1, end - 1, end} {
+			want := make([]byte, d.n)
+			got := make([]byte, d.n)
+			chain(h, 0, in, want, scratch, 0, length, &Address{})
+			chainSelect(h, 0, in, got, scratch, length, &Address{})
+
+			if !bytes.Equal(got, want) {
+				t.Fatal("Constant-time chain differs at", length, "for w =", p.W)
+			}
+		}
+	}
+}
+
+func TestDifferential_RoundTrip(t *testing.T) {
+	inputs := differentialInputs(t, 4)
+	seed, pubSeed := inputs[2], inputs[3]
+
+	for _, p := range differentialParams {
+		pubKey := p.GenPublicKey(seed, pubSeed, &Address{})
+
+		for _, msg := range inputs {
+			// 1 - Signatures of shared inputs verify under every parameter set
+			sig := p.Sign(msg, seed, pubSeed, &Address{})
+			if !p.Verify(pubKey, sig, msg, pubSeed, &Address{}) {
+				t.Fatal("Failed to verify signature for w =", p.W)
+			}
+
+			// 2 - They do not verify for another message
+			other := append([]byte{}, msg...)
+			other[n-1] ^= 1
+			if p.Verify(pubKey, sig, other, pubSeed, &Address{}) {
+				t.Fatal("Verified signature of another message for w =", p.W)
+			}
+		}
+	}
+}
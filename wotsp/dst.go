@@ -0,0 +1,84 @@
+package wotsp
+
+// Like GenPublicKey, writing the public key into dst, which must hold at least
+// PubKeyLen bytes, and returning dst[:PubKeyLen]. Once the pooled buffers have
+// been allocated by earlier calls, nothing is allocated: unlike GenPublicKey,
+// the chains are computed in the calling goroutine, so callers needing
+// throughput run several calls concurrently. Panics with ErrShortBuffer if dst
+// is too short.
+func GenPublicKeyTo(dst, seed, pubSeed []byte, adrs *Address) []byte {
+	return W16.GenPublicKeyTo(dst, seed, pubSeed, adrs)
+}
+
+// Like GenPublicKeyTo, using the parameter set p.
+func (p Params) GenPublicKeyTo(dst, seed, pubSeed []byte, adrs *Address) []byte {
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, a.derive(p), seed, pubSeed, 1)
+	defer h.wipe()
+	dst = dstBuffer(dst, h.l*h.n)
+
+	privKey := expandSeed(h)
+	defer wipe(privKey)
+	lengths := h.alloc(h.l)
+	for i := range lengths {
+		lengths[i] = uint8(h.w - 1)
+	}
+	computeChains(h, 1, privKey, dst, lengths, adrs, false)
+
+	return dst
+}
+
+// Like Sign, writing the signature into dst, which must hold at least SigLen
+// bytes, and returning dst[:SigLen]. Allocates nothing, see GenPublicKeyTo.
+func SignTo(dst, msg, seed, pubSeed []byte, adrs *Address) []byte {
+	return W16.SignTo(dst, msg, seed, pubSeed, adrs)
+}
+
+// Like SignTo, using the parameter set p.
+func (p Params) SignTo(dst, msg, seed, pubSeed []byte, adrs *Address) []byte {
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, a.derive(p), seed, pubSeed, 1)
+	defer h.wipe()
+	dst = dstBuffer(dst, h.l*h.n)
+
+	privKey := expandSeed(h)
+	defer wipe(privKey)
+	computeChains(h, 1, privKey, dst, h.lengths(msg), adrs, false)
+
+	return dst
+}
+
+// Like PkFromSig, writing the public key into dst, which must hold at least
+// PubKeyLen bytes, and returning dst[:PubKeyLen], or nil if sig or msg has the
+// wrong length. Allocates nothing, see GenPublicKeyTo.
+func PkFromSigTo(dst, sig, msg, pubSeed []byte, adrs *Address) []byte {
+	return W16.PkFromSigTo(dst, sig, msg, pubSeed, adrs)
+}
+
+// Like PkFromSigTo, using the parameter set p.
+func (p Params) PkFromSigTo(dst, sig, msg, pubSeed []byte, adrs *Address) []byte {
+	a := getArena()
+	defer putArena(a)
+	d := a.derive(p)
+	if !d.validInput(sig, msg) {
+		return nil
+	}
+	h := precomputeIn(a, d, nil, pubSeed, 1)
+	dst = dstBuffer(dst, h.l*h.n)
+
+	computeChains(h, 1, sig, dst, h.lengths(msg), adrs, true)
+
+	return dst
+}
+
+// Returns the first n bytes of dst, panicking with ErrShortBuffer if it is
+// shorter.
+func dstBuffer(dst []byte, n int) []byte {
+	if len(dst) < n {
+		panic(ErrShortBuffer)
+	}
+
+	return dst[:n]
+}
@@ -0,0 +1,68 @@
+package wotsp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestTo(t *testing.T) {
+	pubKey := make([]byte, PubKeyLen)
+	sig := make([]byte, SigLen+1)
+
+	// 1 - Results match those of the allocating functions
+	if !bytes.Equal(GenPublicKeyTo(pubKey, testdata.Seed, testdata.PubSeed, &Address{}), testdata.PubKey) {
+		t.Fatal("Wrong public key")
+	}
+	if !bytes.Equal(SignTo(sig, testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature) {
+		t.Fatal("Wrong signature")
+	}
+	wipe(pubKey)
+	if !bytes.Equal(PkFromSigTo(pubKey, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{}), testdata.PubKey) {
+		t.Fatal("Wrong public key from signature")
+	}
+	for _, p := range []Params{W4, W256} {
+		pk := p.GenPublicKeyTo(make([]byte, p.PubKeyLen()), testdata.Seed, testdata.PubSeed, &Address{})
+		s := p.SignTo(make([]byte, p.SigLen()), testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+		if !bytes.Equal(pk, p.GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})) ||
+			!bytes.Equal(s, p.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})) ||
+			!bytes.Equal(p.PkFromSigTo(make([]byte, p.PubKeyLen()), s, testdata.Message, testdata.PubSeed, &Address{}), pk) {
+			t.Fatal("Wrong results for w =", p.W)
+		}
+	}
+
+	// 2 - Nothing is allocated, also in constant-time mode
+	adrs := &Address{}
+	for _, constantTime := range []bool{false, true} {
+		if raceEnabled {
+			break
+		}
+		ConstantTime = constantTime
+		allocs := testing.AllocsPerRun(10, func() {
+			GenPublicKeyTo(pubKey, testdata.Seed, testdata.PubSeed, adrs)
+			SignTo(sig, testdata.Message, testdata.Seed, testdata.PubSeed, adrs)
+			PkFromSigTo(pubKey, sig, testdata.Message, testdata.PubSeed, adrs)
+		})
+		ConstantTime = false
+		if allocs != 0 {
+			t.Fatal("Allocated", allocs, "times, constant time", constantTime)
+		}
+	}
+
+	// 3 - Short buffers are refused
+	defer func() {
+		if r := recover(); r != ErrShortBuffer {
+			t.Fatal("Short buffer was not refused, recovered", r)
+		}
+	}()
+	SignTo(sig[:SigLen-1], testdata.Message, testdata.Seed, testdata.PubSeed, adrs)
+}
+
+func BenchmarkSignTo(b *testing.B) {
+	b.ReportAllocs()
+	sig := make([]byte, SigLen)
+	for i := 0; i < b.N; i++ {
+		_ = SignTo(sig, testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+	}
+}
@@ -0,0 +1,16 @@
+package wotsp
+
+import (
+	"crypto/sha256"
+	"hash"
+)
+
+// A hash function used for the PRF and F functions of WOTS+. Its digests must
+// be Params.N bytes long, and it must return pointers to a struct whose state
+// can be copied by value, as the hash functions of the standard library do:
+// the digests of the seeds are precomputed once, and copied into the state of
+// the hash before every evaluation.
+type Hash func() hash.Hash
+
+// SHA-256, the default hash function of Params.
+var SHA256 Hash = sha256.New
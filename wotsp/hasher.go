@@ -3,7 +3,6 @@ package wotsp
 import (
 	"reflect"
 	"hash"
-	"crypto/sha256"
 	"encoding/binary"
 )
 
@@ -20,78 +19,141 @@ import (
 // calculates H(toByte(0, 32) || key || M) where key is the result of an
 // evaluation of PRF.
 type hasher struct {
+	*derived
+
+	// If not nil, the arena buffers are allocated from
+	arena *Arena
+
 	// Precomputed hash digests
 	precompPrfPubSeed  reflect.Value
 	precompPrfPrivSeed reflect.Value
 	precompHashF       reflect.Value
 
+	// Whether precompPrfPrivSeed holds the digest of a private seed
+	privSeeded bool
+
 	// Hash function instance
 	hasher []hash.Hash
 	// Hash digest of hasher
 	hasherVal []reflect.Value
 
-	// PRF with precomputed hash digests for pub and priv seeds
-	prfPubSeed  func(routineNr int, addr *Address, out []byte)
-	prfPrivSeed func(routineNr int, ctr []byte, out []byte)
-	hashF       func(routineNr int, key, inout []byte)
+	// Whether chains are computed on the multi-buffer path, and the SHA-256
+	// state of PRF with the public seed it uses, see multibuffer.go
+	multiBuffer bool
+	prfPubState [8]uint32
+
+	// The address of the chains computed in the calling goroutine, see
+	// computeChains
+	chainAdrs Address
 }
 
-func precompute(privSeed, pubSeed []byte, nrRoutines int) *hasher {
-	c := new(hasher)
-	c.hasher = make([]hash.Hash, nrRoutines)
-	c.hasherVal = make([]reflect.Value, nrRoutines)
+func precompute(d *derived, privSeed, pubSeed []byte, nrRoutines int) *hasher {
+	return precomputeIn(nil, d, privSeed, pubSeed, nrRoutines)
+}
+
+// Like precompute, taking the hash functions of the hasher from the arena a if
+// it is not nil.
+func precomputeIn(a *Arena, d *derived, privSeed, pubSeed []byte, nrRoutines int) *hasher {
+	n := d.n
+	newHash := func() hash.Hash {
+		if a != nil {
+			return a.hash(d.hash)
+		}
+		return d.hash()
+	}
+	var c *hasher
+	if a != nil {
+		c = a.hasher()
+		*c = hasher{derived: d, arena: a, hasher: c.hasher[:0], hasherVal: c.hasherVal[:0]}
+	} else {
+		c = &hasher{derived: d}
+	}
 
 	for i := 0; i < nrRoutines; i++ {
-		c.hasher[i] = sha256.New()
-		c.hasherVal[i] = reflect.ValueOf(c.hasher[i]).Elem()
+		c.hasher = append(c.hasher, newHash())
+		c.hasherVal = append(c.hasherVal, reflect.ValueOf(c.hasher[i]).Elem())
 	}
 
-	padding := make([]byte, n)
+	padding := c.alloc(n)
+	wipe(padding)
 
 	// While padding is all zero, precompute hashF
-	hashHashF := sha256.New()
+	hashHashF := newHash()
 	hashHashF.Write(padding)
 
 	c.precompHashF = reflect.ValueOf(hashHashF).Elem()
 
-	c.hashF = func(routineNr int, key, inout []byte) {
-		c.hasherVal[routineNr].Set(c.precompHashF)
-		c.hasher[routineNr].Write(key)
-		c.hasher[routineNr].Write(inout)
-		c.hasher[routineNr].Sum(inout[:0])
-	}
-
 	// Set padding for prf
 	binary.BigEndian.PutUint16(padding[n-2:], uint16(3))
 
 	if privSeed != nil {
 		// Precompute prf with private seed (not used in PkFromSig)
-		hashPrfSk := sha256.New()
+		hashPrfSk := newHash()
 		hashPrfSk.Write(padding)
 		hashPrfSk.Write(privSeed)
 
 		c.precompPrfPrivSeed = reflect.ValueOf(hashPrfSk).Elem()
-
-		c.prfPrivSeed = func(routineNr int, ctr []byte, out []byte) {
-			c.hasherVal[routineNr].Set(c.precompPrfPrivSeed)
-			c.hasher[routineNr].Write(ctr)
-			c.hasher[routineNr].Sum(out[:0]) // Must make sure that out's capacity is >= 32 bytes!
-		}
+		c.privSeeded = true
 	}
 
 	// Precompute prf with public seed
-	hashPrfPub := sha256.New()
+	hashPrfPub := newHash()
 	hashPrfPub.Write(padding)
 	hashPrfPub.Write(pubSeed)
 
 	c.precompPrfPubSeed = reflect.ValueOf(hashPrfPub).Elem()
 
-	c.prfPubSeed = func(routineNr int, addr *Address, out []byte) {
-		c.hasherVal[routineNr].Set(c.precompPrfPubSeed)
-		c.hasher[routineNr].Write(addr.ToBytes())
-		c.hasher[routineNr].Sum(out[:0]) // Must make sure that out's capacity is >= 32 bytes!
+	if multiBufferable(d, pubSeed) {
+		c.multiBuffer = true
+		c.prfPubState = prfPubSeedState(pubSeed)
 	}
 
 	return c
 }
 
+// F with the precomputed hash digest of the padding, computed in place on
+// inout using the hash function of routine routineNr.
+func (c *hasher) hashF(routineNr int, key, inout []byte) {
+	c.hasherVal[routineNr].Set(c.precompHashF)
+	c.hasher[routineNr].Write(key)
+	c.hasher[routineNr].Write(inout)
+	c.hasher[routineNr].Sum(inout[:0])
+}
+
+// PRF with the precomputed hash digest of the private seed, which is not used
+// in PkFromSig.
+func (c *hasher) prfPrivSeed(routineNr int, ctr []byte, out []byte) {
+	c.hasherVal[routineNr].Set(c.precompPrfPrivSeed)
+	c.hasher[routineNr].Write(ctr)
+	c.hasher[routineNr].Sum(out[:0]) // Must make sure that out's capacity is >= n bytes!
+}
+
+// PRF with the precomputed hash digest of the public seed.
+func (c *hasher) prfPubSeed(routineNr int, addr *Address, out []byte) {
+	c.hasherVal[routineNr].Set(c.precompPrfPubSeed)
+	c.hasher[routineNr].Write(addr.ToBytes())
+	c.hasher[routineNr].Sum(out[:0]) // Must make sure that out's capacity is >= n bytes!
+}
+
+// Overwrites the hash states derived from the private seed with zeroes: the
+// precomputed PRF digest of the seed, and the state of the hash function of
+// every routine, which last processed a secret chain value or the seed. The
+// hasher can not be used afterwards.
+func (c *hasher) wipe() {
+	if c.privSeeded {
+		c.precompPrfPrivSeed.Set(reflect.Zero(c.precompPrfPrivSeed.Type()))
+		c.privSeeded = false
+	}
+	for _, v := range c.hasherVal {
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
+
+// Returns a buffer of length n, from the arena of the hasher if it has one.
+func (c *hasher) alloc(n int) []byte {
+	if c.arena != nil {
+		return c.arena.bytes(n)
+	}
+
+	return make([]byte, n)
+}
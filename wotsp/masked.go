@@ -0,0 +1,53 @@
+package wotsp
+
+// Like GenPublicKey, for a seed that is kept XOR-masked in memory: the seed is
+// maskedSeed XOR mask. The seed is only unmasked while the hash precomputation
+// is derived from it, and is wiped immediately after, as is the expanded
+// private key.
+func GenPublicKeyMasked(maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	return W16.GenPublicKeyMasked(maskedSeed, mask, pubSeed, adrs)
+}
+
+// Like GenPublicKeyMasked, using the parameter set p.
+func (p Params) GenPublicKeyMasked(maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeMasked(a, p.derive(), maskedSeed, mask, pubSeed, numRoutines)
+
+	return genPublicKey(h, numRoutines, adrs)
+}
+
+// Like Sign, for a seed that is kept XOR-masked in memory, see
+// GenPublicKeyMasked.
+func SignMasked(msg, maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	return W16.SignMasked(msg, maskedSeed, mask, pubSeed, adrs)
+}
+
+// Like SignMasked, using the parameter set p.
+func (p Params) SignMasked(msg, maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeMasked(a, p.derive(), maskedSeed, mask, pubSeed, numRoutines)
+
+	return sign(h, numRoutines, msg, adrs)
+}
+
+func precomputeMasked(a *Arena, d *derived, maskedSeed, mask, pubSeed []byte, numRoutines int) *hasher {
+	seed := make([]byte, len(maskedSeed))
+	defer wipe(seed)
+
+	for i := range seed {
+		seed[i] = maskedSeed[i] ^ mask[i]
+	}
+
+	return precomputeIn(a, d, seed, pubSeed, numRoutines)
+}
+
+// Overwrites b with zeroes.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
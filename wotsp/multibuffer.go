@@ -0,0 +1,190 @@
+package wotsp
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"reflect"
+)
+
+// Whether chains are computed on the multi-buffer path where the CPU and the
+// parameter set support it. Tests switch it off to compare both paths.
+var multiBuffer = useSHANI
+
+// The multi-buffer path computes two chains in lockstep, with SHA-256 for
+// n=32 on CPUs with the SHA extensions. Every step of a chain evaluates PRF
+// twice and F once, each hashing 96 bytes in two blocks. The first block of
+// PRF only depends on the public seed, so its state is precomputed like the
+// digest of precompPrfPubSeed; the other blocks are compressed directly, for
+// both chains at once, without the overhead of hash.Hash. This makes chain
+// computations about twice as fast as on the hash.Hash path. Builds with the
+// purego build tag leave the assembly out, and always use the hash.Hash path.
+
+// The initial SHA-256 state.
+var sha256IV = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+// Whether chains of d with the public seed pubSeed can be computed on the
+// multi-buffer path.
+func multiBufferable(d *derived, pubSeed []byte) bool {
+	return multiBuffer && d.n == 32 && len(pubSeed) == 32 &&
+		reflect.ValueOf(d.hash).Pointer() == reflect.ValueOf(sha256.New).Pointer()
+}
+
+// Returns the SHA-256 state after the first block of PRF with the public seed.
+func prfPubSeedState(pubSeed []byte) (state [8]uint32) {
+	var block [64]byte
+	block[31] = 3
+	copy(block[32:], pubSeed)
+
+	state = sha256IV
+	sha256Block(&state, &block)
+
+	return state
+}
+
+// A chain computed on the multi-buffer path.
+type lane struct {
+	adrs  Address
+	state [8]uint32
+	block [64]byte
+	key   [32]byte
+	cur   [32]byte
+	out   []byte
+
+	// The current and the end position in the chain
+	i, end int
+	// Whether the element at position length is copied into out in constant
+	// time after every step, as chainSelect does
+	constantTime bool
+	length       uint8
+}
+
+// Fills the block of l with the 32 bytes of the second block of PRF or F and
+// the padding of their 96-byte input.
+func (l *lane) setLastBlock(b []byte) {
+	copy(l.block[:32], b)
+	l.block[32] = 0x80
+	clear(l.block[33:62])
+	binary.BigEndian.PutUint16(l.block[62:], 96*8)
+}
+
+// Loads PRF with the public seed of the current step into l, for its key if km
+// is 0 or for its bitmask if km is 1.
+func (l *lane) loadPRF(pubSeedState *[8]uint32, km uint32) {
+	l.adrs.setHash(uint32(l.i))
+	l.adrs.setKeyAndMask(km)
+	l.state = *pubSeedState
+	l.setLastBlock(l.adrs.data[:])
+}
+
+// Loads the first block of F, keyed with the key of l, into l.
+func (l *lane) loadF() {
+	l.state = sha256IV
+	clear(l.block[:32])
+	copy(l.block[32:], l.key[:])
+}
+
+// Writes the digest of the state of l into out.
+func (l *lane) digest(out []byte) {
+	for i, v := range l.state {
+		binary.BigEndian.PutUint32(out[4*i:], v)
+	}
+}
+
+// Compresses the blocks of a and, if it is not nil, b.
+func compressLanes(a, b *lane) {
+	if b == nil {
+		sha256Block(&a.state, &a.block)
+		return
+	}
+	sha256Blockx2(&a.state, &b.state, &a.block, &b.block)
+}
+
+// Performs the next step of the chain a and, if it is not nil, of b, like an
+// iteration of chain.
+func stepLanes(h *hasher, a, b *lane) {
+	lanes := []*lane{a, b}
+	if b == nil {
+		lanes = lanes[:1]
+	}
+
+	for _, l := range lanes {
+		l.loadPRF(&h.prfPubState, 0)
+	}
+	compressLanes(a, b)
+	for _, l := range lanes {
+		l.digest(l.key[:])
+		l.loadPRF(&h.prfPubState, 1)
+	}
+	compressLanes(a, b)
+	for _, l := range lanes {
+		for i, v := range l.state {
+			binary.BigEndian.PutUint32(l.cur[4*i:], binary.BigEndian.Uint32(l.cur[4*i:])^v)
+		}
+		l.loadF()
+	}
+	compressLanes(a, b)
+	for _, l := range lanes {
+		l.setLastBlock(l.cur[:])
+	}
+	compressLanes(a, b)
+	for _, l := range lanes {
+		l.digest(l.cur[:])
+		l.i++
+		if l.constantTime {
+			subtle.ConstantTimeCopy(subtle.ConstantTimeByteEq(uint8(l.i), l.length), l.out, l.cur[:])
+		}
+	}
+}
+
+// Computes chains j and j+1 for computeChains on the multi-buffer path, in
+// lockstep for as long as both have steps left. The lanes hold chain values,
+// and are wiped afterwards.
+func computeChainPair(h *hasher, j int, in, out []byte, lengths []uint8, adrs *Address, fromSig, constantTime bool) {
+	n, w := h.n, h.w
+
+	var lanes [2]lane
+	for k := range lanes {
+		l, c := &lanes[k], j+k
+		l.adrs = *adrs
+		l.adrs.setChain(uint32(c))
+		copy(l.cur[:], in[c*n:(c+1)*n])
+		l.out = out[c*n : (c+1)*n]
+
+		switch {
+		case fromSig:
+			l.i, l.end = int(lengths[c]), w-1
+		case constantTime:
+			l.end, l.constantTime, l.length = w-1, true, lengths[c]
+		default:
+			l.end = int(lengths[c])
+		}
+	}
+	// Chains in constant time are selected into out while they are computed,
+	// starting from the first element
+	for k := range lanes {
+		if lanes[k].constantTime {
+			copy(lanes[k].out, lanes[k].cur[:])
+		}
+	}
+
+	a, b := &lanes[0], &lanes[1]
+	for a.i < a.end && b.i < b.end {
+		stepLanes(h, a, b)
+	}
+	for _, l := range [2]*lane{a, b} {
+		for l.i < l.end {
+			stepLanes(h, l, nil)
+		}
+		if !l.constantTime {
+			copy(l.out, l.cur[:])
+		}
+	}
+
+	for k := range lanes {
+		lanes[k] = lane{}
+	}
+}
@@ -0,0 +1,108 @@
+package wotsp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+// Runs f with the multi-buffer path switched on or off.
+func withMultiBuffer(on bool, f func()) {
+	defer func(old bool) { multiBuffer = old }(multiBuffer)
+	multiBuffer = on
+
+	f()
+}
+
+func TestMultiBuffer(t *testing.T) {
+	if !useSHANI {
+		t.Skip("SHA extensions not supported")
+	}
+
+	seed, pubSeed, msg := make([]byte, n), make([]byte, n), make([]byte, n)
+	for _, b := range [][]byte{seed, pubSeed, msg} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	adrs := &Address{}
+	adrs.SetOTS(7)
+
+	// 1 - Keys and signatures match those of the hash.Hash path, for every w,
+	// with chains of odd and even count, also in constant-time mode
+	for _, p := range []Params{W4, W16, W256} {
+		for _, constantTime := range []bool{false, true} {
+			var results [2][3][]byte
+			for i, on := range []bool{false, true} {
+				withMultiBuffer(on, func() {
+					ConstantTime = constantTime
+					defer func() { ConstantTime = false }()
+
+					pk := p.GenPublicKey(seed, pubSeed, adrs)
+					sig := p.Sign(msg, seed, pubSeed, adrs)
+					results[i] = [3][]byte{pk, sig, p.PkFromSig(sig, msg, pubSeed, adrs)}
+				})
+			}
+			for j := range results[0] {
+				if !bytes.Equal(results[0][j], results[1][j]) {
+					t.Fatal("Multi-buffer result", j, "differs for w =", p.W, "constant time", constantTime)
+				}
+			}
+		}
+	}
+
+	// 2 - The known answers are met
+	if !bytes.Equal(GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{}), testdata.PubKey) ||
+		!bytes.Equal(Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature) {
+		t.Fatal("Multi-buffer path fails known answers")
+	}
+
+	// 3 - Other hash functions and public seeds of another length use the
+	// hash.Hash path
+	if h := precompute(W16.derive(), nil, pubSeed[:31], 1); h.multiBuffer {
+		t.Fatal("Short public seed uses the multi-buffer path")
+	}
+	if h := precompute(W16.derive(), nil, pubSeed, 1); !h.multiBuffer {
+		t.Fatal("SHA-256 does not use the multi-buffer path")
+	}
+}
+
+// Compares the multi-buffer path with the hash.Hash path. On a CPU with the
+// SHA extensions, GenPublicKey is about twice as fast, and Sign about 1.8
+// times.
+func BenchmarkMultiBuffer(b *testing.B) {
+	for _, p := range []Params{W16, W256} {
+		for _, on := range []bool{false, true} {
+			name := "hash"
+			if on {
+				name = "multibuffer"
+			}
+
+			b.Run(fmt.Sprintf("%s/GenPublicKey/w=%d", name, p.W), func(b *testing.B) {
+				if on && !useSHANI {
+					b.Skip("SHA extensions not supported")
+				}
+				withMultiBuffer(on, func() {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						_ = p.GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})
+					}
+				})
+			})
+			b.Run(fmt.Sprintf("%s/Sign/w=%d", name, p.W), func(b *testing.B) {
+				if on && !useSHANI {
+					b.Skip("SHA extensions not supported")
+				}
+				withMultiBuffer(on, func() {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						_ = p.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+					}
+				})
+			})
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !race
+
+package wotsp
+
+// See race_test.go.
+const raceEnabled = false
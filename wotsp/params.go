@@ -0,0 +1,106 @@
+package wotsp
+
+import (
+	"errors"
+	"math/bits"
+)
+
+var (
+	ErrParamsInvalid = errors.New("invalid WOTS+ parameters")
+)
+
+// A WOTS+ parameter set. The Winternitz parameter W trades signature size
+// against signing time: keys and signatures of W=4 are about twice as large as
+// those of W=16, while W=256 halves their size, but takes about eight times as
+// long to sign and verify.
+//
+// The zero values of N and Hash select n=32 and SHA-256.
+type Params struct {
+	// The Winternitz parameter, one of 4, 16 or 256.
+	W int
+	// The length in bytes of messages, and of the elements of keys and
+	// signatures. It must equal the digest size of Hash.
+	N int
+	// The hash function used for the PRF and F functions.
+	Hash Hash
+}
+
+var (
+	// WOTS+ with w=4, n=32 and SHA-256.
+	W4 = Params{W: 4}
+	// WOTS+ with w=16, n=32 and SHA-256, as used by the functions of the
+	// package.
+	W16 = Params{W: 16}
+	// WOTS+ with w=256, n=32 and SHA-256, as implemented by package wotsp256.
+	W256 = Params{W: 256}
+
+	// WOTSP-SHA2_256 of RFC 8391, the same parameter set as W16. See the
+	// package documentation for its compatibility with the RFC.
+	ParamsSHA2_256_W16 = W16
+)
+
+// Returns ErrParamsInvalid if p is not a valid parameter set. The functions of
+// p panic if it is not.
+func (p Params) Validate() error {
+	if p.W != 4 && p.W != 16 && p.W != 256 || p.N < 0 {
+		return ErrParamsInvalid
+	}
+
+	if p.withDefaults().Hash().Size() != p.withDefaults().N {
+		return ErrParamsInvalid
+	}
+
+	return nil
+}
+
+// Returns the length of messages.
+func (p Params) MsgLen() int {
+	return p.derive().n
+}
+
+// Returns the length of signatures.
+func (p Params) SigLen() int {
+	d := p.derive()
+	return d.l * d.n
+}
+
+// Returns the length of public keys.
+func (p Params) PubKeyLen() int {
+	d := p.derive()
+	return d.l * d.n
+}
+
+func (p Params) withDefaults() Params {
+	if p.N == 0 {
+		p.N = n
+	}
+	if p.Hash == nil {
+		p.Hash = SHA256
+	}
+
+	return p
+}
+
+// The values derived from a parameter set, as named by the XMSS draft.
+type derived struct {
+	w, n      int
+	logw      int
+	l1, l2, l int
+	hash      Hash
+}
+
+func (p Params) derive() *derived {
+	if err := p.Validate(); err != nil {
+		panic(err)
+	}
+	p = p.withDefaults()
+
+	d := &derived{w: p.W, n: p.N, hash: p.Hash}
+	d.logw = bits.Len(uint(p.W)) - 1
+	d.l1 = 8 * d.n / d.logw
+	// floor(log2(l1 * (w - 1)) / log2(w)) + 1
+	d.l2 = (bits.Len(uint(d.l1*(d.w-1)))-1)/d.logw + 1
+	d.l = d.l1 + d.l2
+
+	return d
+}
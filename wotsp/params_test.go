@@ -0,0 +1,75 @@
+package wotsp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+	testdata256 "github.com/Re0h/xnyss/wotsp256/testdata"
+)
+
+func TestParams_Lengths(t *testing.T) {
+	for _, tc := range []struct {
+		p   Params
+		len int
+	}{{W4, 133 * n}, {W16, SigLen}, {W256, 34 * n}} {
+		if tc.p.SigLen() != tc.len || tc.p.PubKeyLen() != tc.len || tc.p.MsgLen() != n {
+			t.Fatal("Invalid lengths for w =", tc.p.W)
+		}
+	}
+}
+
+func TestParams_Validate(t *testing.T) {
+	for _, p := range []Params{W4, W16, W256, {W: 16, N: 32, Hash: SHA256}} {
+		if err := p.Validate(); err != nil {
+			t.Fatal("Valid parameters rejected for w =", p.W)
+		}
+	}
+
+	for _, p := range []Params{{}, {W: 8}, {W: 16, N: 16}, {W: 16, Hash: sha512.New}} {
+		if err := p.Validate(); err != ErrParamsInvalid {
+			t.Fatal("Invalid parameters accepted -", p.W, p.N)
+		}
+	}
+}
+
+func TestParams_KnownAnswers(t *testing.T) {
+	// 1 - W16 is the parameter set of the package functions
+	if !bytes.Equal(W16.Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature) {
+		t.Fatal("Wrong signature for w = 16")
+	}
+
+	// 2 - W256 matches the known answers of package wotsp256
+	pubKey := W256.GenPublicKey(testdata256.Seed, testdata256.PubSeed, &Address{})
+	if !bytes.Equal(pubKey, testdata256.PublicKey) {
+		t.Fatal("Wrong public key for w = 256")
+	}
+	sig := W256.Sign(testdata256.Message, testdata256.Seed, testdata256.PubSeed, &Address{})
+	if !bytes.Equal(sig, testdata256.Signature) {
+		t.Fatal("Wrong signature for w = 256")
+	}
+}
+
+func TestParams_W4(t *testing.T) {
+	seed := make([]byte, 32)
+	pubSeed := make([]byte, 32)
+	msg := make([]byte, 32)
+	for _, b := range [][]byte{seed, pubSeed, msg} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pubKey := W4.GenPublicKey(seed, pubSeed, &Address{})
+	signed := W4.Sign(msg, seed, pubSeed, &Address{})
+	if !W4.Verify(pubKey, signed, msg, pubSeed, &Address{}) {
+		t.Fatal("Failed to verify signature for w = 4")
+	}
+
+	msg[0] ^= 1
+	if W4.Verify(pubKey, signed, msg, pubSeed, &Address{}) {
+		t.Fatal("Verified signature of another message for w = 4")
+	}
+}
@@ -0,0 +1,67 @@
+package wotsp
+
+// A private key whose seed has been expanded, and whose hash precomputations
+// have been derived, once: GenPublicKey and Sign pay that cost on every call,
+// while the methods of PrivateKey reuse it. The expanded key is as secret as
+// the seed, so uses that keep seeds masked (see GenPublicKeyMasked) should not
+// keep a PrivateKey. A PrivateKey is not safe for concurrent use.
+type PrivateKey struct {
+	h           *hasher
+	privKey     []byte
+	numRoutines int
+}
+
+// Expands the private key of the given seed and public seed, using w=16.
+func NewPrivateKey(seed, pubSeed []byte) *PrivateKey {
+	return W16.NewPrivateKey(seed, pubSeed)
+}
+
+// Like NewPrivateKey, using the parameter set p.
+func (p Params) NewPrivateKey(seed, pubSeed []byte) *PrivateKey {
+	numRoutines := routines()
+
+	return newPrivateKey(precompute(p.derive(), seed, pubSeed, numRoutines), numRoutines)
+}
+
+func newPrivateKey(h *hasher, numRoutines int) *PrivateKey {
+	return &PrivateKey{h: h, privKey: expandSeed(h), numRoutines: numRoutines}
+}
+
+// Computes the public key of k, like GenPublicKey.
+func (k *PrivateKey) PublicKey(adrs *Address) []byte {
+	h := k.h
+
+	// Initialise list of chain lengths for full chains
+	lengths := h.alloc(h.l)
+	for i := range lengths {
+		lengths[i] = uint8(h.w - 1)
+	}
+
+	// Compute public key
+	pubKey := make([]byte, h.l*h.n)
+	computeChains(h, k.numRoutines, k.privKey, pubKey, lengths, adrs, false)
+
+	return pubKey
+}
+
+// Signs message msg using k, like Sign.
+func (k *PrivateKey) Sign(msg []byte, adrs *Address) []byte {
+	h := k.h
+
+	// Compute signature
+	sig := make([]byte, h.l*h.n)
+	computeChains(h, k.numRoutines, k.privKey, sig, h.lengths(msg), adrs, false)
+
+	return sig
+}
+
+// Overwrites the expanded private key, and the hash precomputations derived
+// from the seed, with zeroes. The key can not be used afterwards.
+func (k *PrivateKey) Wipe() {
+	wipe(k.privKey)
+	if k.h != nil {
+		k.h.wipe()
+	}
+	k.privKey = nil
+	k.h = nil
+}
@@ -0,0 +1,47 @@
+package wotsp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestPrivateKey(t *testing.T) {
+	k := NewPrivateKey(testdata.Seed, testdata.PubSeed)
+
+	// The key can be used more than once
+	for i := 0; i < 2; i++ {
+		if !bytes.Equal(k.PublicKey(&Address{}), testdata.PubKey) {
+			t.Fatal("Wrong key")
+		}
+		if !bytes.Equal(k.Sign(testdata.Message, &Address{}), testdata.Signature) {
+			t.Fatal("Wrong signature")
+		}
+	}
+
+	h := k.h
+	k.Wipe()
+	if k.privKey != nil || k.h != nil {
+		t.Fatal("Key was not wiped")
+	}
+
+	// The hash states derived from the seed are zeroed
+	if !h.precompPrfPrivSeed.IsZero() {
+		t.Fatal("Precomputed PRF state was not wiped")
+	}
+	for _, v := range h.hasherVal {
+		if !v.IsZero() {
+			t.Fatal("Hash state of routine was not wiped")
+		}
+	}
+}
+
+func BenchmarkPrivateKey_Sign(b *testing.B) {
+	b.ReportAllocs()
+	k := NewPrivateKey(testdata.Seed, testdata.PubSeed)
+
+	for i := 0; i < b.N; i++ {
+		_ = k.Sign(testdata.Message, &Address{})
+	}
+}
@@ -0,0 +1,7 @@
+//go:build race
+
+package wotsp
+
+// Whether the tests run under the race detector, which randomly drops pooled
+// arenas, so allocation counts can not be asserted.
+const raceEnabled = true
@@ -0,0 +1,161 @@
+package wotsp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+// A direct transcription of the WOTS+ algorithms of RFC 8391 (sections 2.5,
+// 2.6, 3.1 and 5.1) for WOTSP-SHA2_256, used to check the optimised
+// implementation. The RFC does not publish known answers for WOTS+ alone.
+
+const rfcW, rfcLen1, rfcLen2 = 16, 64, 3
+
+func rfcToByte(x uint64, y int) []byte {
+	b := make([]byte, y)
+	for i := y - 1; i >= 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+
+	return b
+}
+
+func rfcHash(prefix uint64, key, m []byte) []byte {
+	h := sha256.New()
+	h.Write(rfcToByte(prefix, 32))
+	h.Write(key)
+	h.Write(m)
+
+	return h.Sum(nil)
+}
+
+func rfcF(key, m []byte) []byte   { return rfcHash(0, key, m) }
+func rfcPRF(key, m []byte) []byte { return rfcHash(3, key, m) }
+
+// ADRS is kept as the 32 bytes of its encoding, see section 2.5.
+func rfcSetWord(adrs []byte, offset int, v uint32) {
+	binary.BigEndian.PutUint32(adrs[offset:], v)
+}
+
+func rfcChain(x []byte, i, s int, seed, adrs []byte) []byte {
+	if s == 0 {
+		return append([]byte(nil), x...)
+	}
+	if i+s > rfcW-1 {
+		return nil
+	}
+	tmp := rfcChain(x, i, s-1, seed, adrs)
+
+	rfcSetWord(adrs, 24, uint32(i+s-1))
+	rfcSetWord(adrs, 28, 0)
+	key := rfcPRF(seed, adrs)
+	rfcSetWord(adrs, 28, 1)
+	bm := rfcPRF(seed, adrs)
+	for j := range tmp {
+		tmp[j] ^= bm[j]
+	}
+
+	return rfcF(key, tmp)
+}
+
+func rfcBaseW(x []byte, outLen int) []int {
+	in, bits, total := 0, 0, 0
+	basew := make([]int, outLen)
+	for out := 0; out < outLen; out++ {
+		if bits == 0 {
+			total = int(x[in])
+			in++
+			bits = 8
+		}
+		bits -= 4
+		basew[out] = (total >> bits) & (rfcW - 1)
+	}
+
+	return basew
+}
+
+// Returns the chain lengths of message m, see WOTS_sign in section 3.1.5.
+func rfcLengths(m []byte) []int {
+	msg := rfcBaseW(m, rfcLen1)
+	csum := 0
+	for _, digit := range msg {
+		csum += rfcW - 1 - digit
+	}
+	csum <<= 8 - (rfcLen2*4)%8
+	lenBytes := (rfcLen2*4 + 7) / 8
+
+	return append(msg, rfcBaseW(rfcToByte(uint64(csum), lenBytes), rfcLen2)...)
+}
+
+// Private key element i, derived from the seed as by the XMSS reference
+// implementation.
+func rfcSK(seed []byte, i int) []byte {
+	return rfcPRF(seed, rfcToByte(uint64(i), 32))
+}
+
+func rfcGenPK(seed, pubSeed, adrs []byte) []byte {
+	var pk []byte
+	for i := 0; i < rfcLen1+rfcLen2; i++ {
+		rfcSetWord(adrs, 20, uint32(i))
+		pk = append(pk, rfcChain(rfcSK(seed, i), 0, rfcW-1, pubSeed, adrs)...)
+	}
+
+	return pk
+}
+
+func rfcSign(m, seed, pubSeed, adrs []byte) []byte {
+	var sig []byte
+	for i, length := range rfcLengths(m) {
+		rfcSetWord(adrs, 20, uint32(i))
+		sig = append(sig, rfcChain(rfcSK(seed, i), 0, length, pubSeed, adrs)...)
+	}
+
+	return sig
+}
+
+func rfcPkFromSig(sig, m, pubSeed, adrs []byte) []byte {
+	var pk []byte
+	for i, length := range rfcLengths(m) {
+		rfcSetWord(adrs, 20, uint32(i))
+		pk = append(pk, rfcChain(sig[i*n:(i+1)*n], length, rfcW-1-length, pubSeed, adrs)...)
+	}
+
+	return pk
+}
+
+func TestParams_RFC8391(t *testing.T) {
+	adrs := &Address{}
+	adrs.SetLayer(1)
+	adrs.SetTree(0x0102030405060708)
+	adrs.SetOTS(9)
+	rfcAdrs := func() []byte { return append([]byte(nil), adrs.ToBytes()...) }
+
+	for _, m := range [][]byte{testdata.Message, make([]byte, n), bytes.Repeat([]byte{0xff}, n)} {
+		// 1 - Public keys match the RFC
+		pk := ParamsSHA2_256_W16.GenPublicKey(testdata.Seed, testdata.PubSeed, adrs)
+		if !bytes.Equal(pk, rfcGenPK(testdata.Seed, testdata.PubSeed, rfcAdrs())) {
+			t.Fatal("Public key differs from RFC 8391")
+		}
+
+		// 2 - Signatures match the RFC
+		sig := ParamsSHA2_256_W16.Sign(m, testdata.Seed, testdata.PubSeed, adrs)
+		if !bytes.Equal(sig, rfcSign(m, testdata.Seed, testdata.PubSeed, rfcAdrs())) {
+			t.Fatal("Signature differs from RFC 8391")
+		}
+
+		// 3 - Public keys computed from signatures match the RFC
+		if !bytes.Equal(ParamsSHA2_256_W16.PkFromSig(sig, m, testdata.PubSeed, adrs), rfcPkFromSig(sig, m, testdata.PubSeed, rfcAdrs())) {
+			t.Fatal("Public key from signature differs from RFC 8391")
+		}
+	}
+
+	// 4 - The known answers of the package are RFC 8391 signatures
+	if !bytes.Equal(rfcSign(testdata.Message, testdata.Seed, testdata.PubSeed, make([]byte, 32)), testdata.Signature) {
+		t.Fatal("Known answer differs from RFC 8391")
+	}
+}
@@ -0,0 +1,25 @@
+package wotsp
+
+import "runtime"
+
+// Denotes whether the functions of the package compute all hash chains in the
+// calling goroutine, rather than distributing them over GOMAXPROCS goroutines.
+// Spawning and synchronising workers only adds overhead on single-core
+// targets, where the chains are computed serially anyway: serial mode is used
+// automatically when GOMAXPROCS is 1, and can be forced by setting Serial.
+//
+// Builds with the wotspserial build tag, and TinyGo builds, leave the
+// goroutine-based code out altogether and always run in serial mode, for
+// targets such as WASM or microcontrollers that do not support it well. The
+// chains are computed by the same code in either mode.
+var Serial = false
+
+// Returns the amount of goroutines to compute chains in: 1 in serial mode,
+// GOMAXPROCS otherwise.
+func routines() int {
+	if Serial || serialBuild {
+		return 1
+	}
+
+	return runtime.GOMAXPROCS(-1)
+}
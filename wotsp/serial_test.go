@@ -0,0 +1,50 @@
+package wotsp
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestSerial(t *testing.T) {
+	defer func(serial bool) { Serial = serial }(Serial)
+
+	// 1 - Serial mode computes the chains in the calling goroutine
+	Serial = true
+	if routines() != 1 {
+		t.Fatal("Serial mode uses", routines(), "routines")
+	}
+	Serial = false
+	if !serialBuild && routines() != runtime.GOMAXPROCS(-1) {
+		t.Fatal("Parallel mode uses", routines(), "routines")
+	}
+
+	// 2 - Both modes compute the same results
+	for _, serial := range []bool{true, false} {
+		Serial = serial
+		if !bytes.Equal(GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{}), testdata.PubKey) {
+			t.Fatal("Wrong public key, serial", serial)
+		}
+		if !bytes.Equal(Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature) {
+			t.Fatal("Wrong signature, serial", serial)
+		}
+		if !Verify(testdata.PubKey, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{}) {
+			t.Fatal("Failed to verify signature, serial", serial)
+		}
+		items := []VerifyItem{{PubKey: testdata.PubKey, Sig: testdata.Signature, Msg: testdata.Message, PubSeed: testdata.PubSeed}}
+		if valid := VerifyBatch(append(items, items...), 4); !valid[0] || !valid[1] {
+			t.Fatal("Failed to verify batch, serial", serial)
+		}
+	}
+}
+
+func BenchmarkSignSerial(b *testing.B) {
+	defer func(serial bool) { Serial = serial }(Serial)
+	Serial = true
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+	}
+}
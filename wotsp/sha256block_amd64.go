@@ -0,0 +1,29 @@
+//go:build amd64 && !purego
+
+package wotsp
+
+// Whether the CPU supports the SHA extensions, and the SSSE3 and SSE4.1
+// instructions used alongside them.
+var useSHANI = hasSHANI()
+
+func hasSHANI() bool {
+	if maxID, _, _, _ := cpuid(0, 0); maxID < 7 {
+		return false
+	}
+	_, _, ecx1, _ := cpuid(1, 0)
+	_, ebx7, _, _ := cpuid(7, 0)
+
+	return ecx1&(1<<9) != 0 && ecx1&(1<<19) != 0 && ebx7&(1<<29) != 0
+}
+
+// Compresses the 64-byte block p into the SHA-256 state h.
+//
+//go:noescape
+func sha256Block(h *[8]uint32, p *[64]byte)
+
+// Compresses the blocks pa and pb into the SHA-256 states a and b.
+//
+//go:noescape
+func sha256Blockx2(a, b *[8]uint32, pa, pb *[64]byte)
+
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
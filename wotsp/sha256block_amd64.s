@@ -0,0 +1,621 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// SHA-256 compression using the Intel SHA extensions, for the multi-buffer
+// chain path (see multibuffer.go). The rounds are those of blockSHANI in
+// crypto/sha256 of the Go standard library (Copyright The Go Authors, BSD
+// license), using SSE instead of AVX moves and unaligned loads of the round
+// constants. The state is kept in X1 and X2 with X3-X7 holding the message
+// schedule, X0 the round input and X8 the byte order mask.
+//
+// sha256Blockx2 compresses two independent blocks: the rounds of the second block
+// use X9-X15 and are interleaved with those of the first, so that each hides
+// the latency of the other.
+
+// func sha256Block(h *[8]uint32, p *[64]byte)
+TEXT ·sha256Block(SB), NOSPLIT, $32-16
+	MOVQ        h+0(FP), DI
+	MOVQ        p+8(FP), SI
+	LEAQ        k256<>(SB), AX
+	MOVOU       flipMask<>(SB), X8
+	MOVOU       (DI), X1
+	MOVOU       16(DI), X2
+	PSHUFD      $0xb1, X1, X1
+	PSHUFD      $0x1b, X2, X2
+	MOVO        X1, X7
+	PALIGNR     $0x08, X2, X1
+	PBLENDW     $0xf0, X7, X2
+	MOVOU       X1, 0(SP)
+	MOVOU       X2, 16(SP)
+
+	MOVOU       (SI), X3
+	PSHUFB      X8, X3
+	MOVOU       (AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	MOVOU       16(SI), X4
+	PSHUFB      X8, X4
+	MOVOU       16(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X4, X3
+	MOVOU       32(SI), X5
+	PSHUFB      X8, X5
+	MOVOU       32(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X5, X4
+	MOVOU       48(SI), X6
+	PSHUFB      X8, X6
+	MOVOU       48(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X6, X5
+	MOVOU       64(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X3, X6
+	MOVOU       80(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X4, X3
+	MOVOU       96(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X5, X4
+	MOVOU       112(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X6, X5
+	MOVOU       128(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X3, X6
+	MOVOU       144(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X4, X3
+	MOVOU       160(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X5, X4
+	MOVOU       176(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X6, X5
+	MOVOU       192(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	SHA256MSG1  X3, X6
+	MOVOU       208(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	MOVOU       224(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	MOVOU       240(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+
+	MOVOU       0(SP), X7
+	PADDD       X7, X1
+	MOVOU       16(SP), X7
+	PADDD       X7, X2
+	PSHUFD      $0x1b, X1, X1
+	PSHUFD      $0xb1, X2, X2
+	MOVO        X1, X7
+	PBLENDW     $0xf0, X2, X1
+	PALIGNR     $0x08, X7, X2
+	MOVOU       X1, (DI)
+	MOVOU       X2, 16(DI)
+	RET
+
+// func sha256Blockx2(a, b *[8]uint32, pa, pb *[64]byte)
+TEXT ·sha256Blockx2(SB), NOSPLIT, $64-32
+	MOVQ        a+0(FP), DI
+	MOVQ        b+8(FP), DX
+	MOVQ        pa+16(FP), SI
+	MOVQ        pb+24(FP), BX
+	LEAQ        k256<>(SB), AX
+	MOVOU       flipMask<>(SB), X8
+	MOVOU       (DI), X1
+	MOVOU       16(DI), X2
+	PSHUFD      $0xb1, X1, X1
+	PSHUFD      $0x1b, X2, X2
+	MOVO        X1, X7
+	PALIGNR     $0x08, X2, X1
+	PBLENDW     $0xf0, X7, X2
+	MOVOU       (DX), X9
+	MOVOU       16(DX), X10
+	PSHUFD      $0xb1, X9, X9
+	PSHUFD      $0x1b, X10, X10
+	MOVO        X9, X15
+	PALIGNR     $0x08, X10, X9
+	PBLENDW     $0xf0, X15, X10
+	MOVOU       X1, 0(SP)
+	MOVOU       X2, 16(SP)
+	MOVOU       X9, 32(SP)
+	MOVOU       X10, 48(SP)
+
+	MOVOU       (SI), X3
+	PSHUFB      X8, X3
+	MOVOU       (AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	MOVOU       (BX), X11
+	PSHUFB      X8, X11
+	MOVOU       (AX), X0
+	PADDD       X11, X0
+	SHA256RNDS2 X0, X9, X10
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	MOVOU       16(SI), X4
+	PSHUFB      X8, X4
+	MOVOU       16(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	MOVOU       16(BX), X12
+	PSHUFB      X8, X12
+	MOVOU       16(AX), X0
+	PADDD       X12, X0
+	SHA256RNDS2 X0, X9, X10
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X4, X3
+	MOVOU       32(SI), X5
+	PSHUFB      X8, X5
+	MOVOU       32(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X12, X11
+	MOVOU       32(BX), X13
+	PSHUFB      X8, X13
+	MOVOU       32(AX), X0
+	PADDD       X13, X0
+	SHA256RNDS2 X0, X9, X10
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X5, X4
+	MOVOU       48(SI), X6
+	PSHUFB      X8, X6
+	MOVOU       48(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X13, X12
+	MOVOU       48(BX), X14
+	PSHUFB      X8, X14
+	MOVOU       48(AX), X0
+	PADDD       X14, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X14, X15
+	PALIGNR     $0x04, X13, X15
+	PADDD       X15, X11
+	SHA256MSG2  X14, X11
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X6, X5
+	MOVOU       64(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X14, X13
+	MOVOU       64(AX), X0
+	PADDD       X11, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X11, X15
+	PALIGNR     $0x04, X14, X15
+	PADDD       X15, X12
+	SHA256MSG2  X11, X12
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X3, X6
+	MOVOU       80(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X11, X14
+	MOVOU       80(AX), X0
+	PADDD       X12, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X12, X15
+	PALIGNR     $0x04, X11, X15
+	PADDD       X15, X13
+	SHA256MSG2  X12, X13
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X4, X3
+	MOVOU       96(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X12, X11
+	MOVOU       96(AX), X0
+	PADDD       X13, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X13, X15
+	PALIGNR     $0x04, X12, X15
+	PADDD       X15, X14
+	SHA256MSG2  X13, X14
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X5, X4
+	MOVOU       112(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X13, X12
+	MOVOU       112(AX), X0
+	PADDD       X14, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X14, X15
+	PALIGNR     $0x04, X13, X15
+	PADDD       X15, X11
+	SHA256MSG2  X14, X11
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X6, X5
+	MOVOU       128(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X14, X13
+	MOVOU       128(AX), X0
+	PADDD       X11, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X11, X15
+	PALIGNR     $0x04, X14, X15
+	PADDD       X15, X12
+	SHA256MSG2  X11, X12
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X3, X6
+	MOVOU       144(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X11, X14
+	MOVOU       144(AX), X0
+	PADDD       X12, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X12, X15
+	PALIGNR     $0x04, X11, X15
+	PADDD       X15, X13
+	SHA256MSG2  X12, X13
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X4, X3
+	MOVOU       160(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X12, X11
+	MOVOU       160(AX), X0
+	PADDD       X13, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X13, X15
+	PALIGNR     $0x04, X12, X15
+	PADDD       X15, X14
+	SHA256MSG2  X13, X14
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X5, X4
+	MOVOU       176(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X6, X7
+	PALIGNR     $0x04, X5, X7
+	PADDD       X7, X3
+	SHA256MSG2  X6, X3
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X13, X12
+	MOVOU       176(AX), X0
+	PADDD       X14, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X14, X15
+	PALIGNR     $0x04, X13, X15
+	PADDD       X15, X11
+	SHA256MSG2  X14, X11
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X6, X5
+	MOVOU       192(AX), X0
+	PADDD       X3, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X3, X7
+	PALIGNR     $0x04, X6, X7
+	PADDD       X7, X4
+	SHA256MSG2  X3, X4
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X14, X13
+	MOVOU       192(AX), X0
+	PADDD       X11, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X11, X15
+	PALIGNR     $0x04, X14, X15
+	PADDD       X15, X12
+	SHA256MSG2  X11, X12
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	SHA256MSG1  X3, X6
+	MOVOU       208(AX), X0
+	PADDD       X4, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X4, X7
+	PALIGNR     $0x04, X3, X7
+	PADDD       X7, X5
+	SHA256MSG2  X4, X5
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	SHA256MSG1  X11, X14
+	MOVOU       208(AX), X0
+	PADDD       X12, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X12, X15
+	PALIGNR     $0x04, X11, X15
+	PADDD       X15, X13
+	SHA256MSG2  X12, X13
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	MOVOU       224(AX), X0
+	PADDD       X5, X0
+	SHA256RNDS2 X0, X1, X2
+	MOVO        X5, X7
+	PALIGNR     $0x04, X4, X7
+	PADDD       X7, X6
+	SHA256MSG2  X5, X6
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	MOVOU       224(AX), X0
+	PADDD       X13, X0
+	SHA256RNDS2 X0, X9, X10
+	MOVO        X13, X15
+	PALIGNR     $0x04, X12, X15
+	PADDD       X15, X14
+	SHA256MSG2  X13, X14
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	MOVOU       240(AX), X0
+	PADDD       X6, X0
+	SHA256RNDS2 X0, X1, X2
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X2, X1
+	MOVOU       240(AX), X0
+	PADDD       X14, X0
+	SHA256RNDS2 X0, X9, X10
+	PSHUFD      $0x0e, X0, X0
+	SHA256RNDS2 X0, X10, X9
+
+	MOVOU       0(SP), X7
+	PADDD       X7, X1
+	MOVOU       16(SP), X7
+	PADDD       X7, X2
+	MOVOU       32(SP), X15
+	PADDD       X15, X9
+	MOVOU       48(SP), X15
+	PADDD       X15, X10
+	PSHUFD      $0x1b, X1, X1
+	PSHUFD      $0xb1, X2, X2
+	MOVO        X1, X7
+	PBLENDW     $0xf0, X2, X1
+	PALIGNR     $0x08, X7, X2
+	MOVOU       X1, (DI)
+	MOVOU       X2, 16(DI)
+	PSHUFD      $0x1b, X9, X9
+	PSHUFD      $0xb1, X10, X10
+	MOVO        X9, X15
+	PBLENDW     $0xf0, X10, X9
+	PALIGNR     $0x08, X15, X10
+	MOVOU       X9, (DX)
+	MOVOU       X10, 16(DX)
+	RET
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL        eaxArg+0(FP), AX
+	MOVL        ecxArg+4(FP), CX
+	CPUID
+	MOVL        AX, eax+8(FP)
+	MOVL        BX, ebx+12(FP)
+	MOVL        CX, ecx+16(FP)
+	MOVL        DX, edx+20(FP)
+	RET
+
+// Round constants, four per round group.
+DATA k256<>+0x00(SB)/8, $0x71374491428a2f98
+DATA k256<>+0x08(SB)/8, $0xe9b5dba5b5c0fbcf
+DATA k256<>+0x10(SB)/8, $0x59f111f13956c25b
+DATA k256<>+0x18(SB)/8, $0xab1c5ed5923f82a4
+DATA k256<>+0x20(SB)/8, $0x12835b01d807aa98
+DATA k256<>+0x28(SB)/8, $0x550c7dc3243185be
+DATA k256<>+0x30(SB)/8, $0x80deb1fe72be5d74
+DATA k256<>+0x38(SB)/8, $0xc19bf1749bdc06a7
+DATA k256<>+0x40(SB)/8, $0xefbe4786e49b69c1
+DATA k256<>+0x48(SB)/8, $0x240ca1cc0fc19dc6
+DATA k256<>+0x50(SB)/8, $0x4a7484aa2de92c6f
+DATA k256<>+0x58(SB)/8, $0x76f988da5cb0a9dc
+DATA k256<>+0x60(SB)/8, $0xa831c66d983e5152
+DATA k256<>+0x68(SB)/8, $0xbf597fc7b00327c8
+DATA k256<>+0x70(SB)/8, $0xd5a79147c6e00bf3
+DATA k256<>+0x78(SB)/8, $0x1429296706ca6351
+DATA k256<>+0x80(SB)/8, $0x2e1b213827b70a85
+DATA k256<>+0x88(SB)/8, $0x53380d134d2c6dfc
+DATA k256<>+0x90(SB)/8, $0x766a0abb650a7354
+DATA k256<>+0x98(SB)/8, $0x92722c8581c2c92e
+DATA k256<>+0xa0(SB)/8, $0xa81a664ba2bfe8a1
+DATA k256<>+0xa8(SB)/8, $0xc76c51a3c24b8b70
+DATA k256<>+0xb0(SB)/8, $0xd6990624d192e819
+DATA k256<>+0xb8(SB)/8, $0x106aa070f40e3585
+DATA k256<>+0xc0(SB)/8, $0x1e376c0819a4c116
+DATA k256<>+0xc8(SB)/8, $0x34b0bcb52748774c
+DATA k256<>+0xd0(SB)/8, $0x4ed8aa4a391c0cb3
+DATA k256<>+0xd8(SB)/8, $0x682e6ff35b9cca4f
+DATA k256<>+0xe0(SB)/8, $0x78a5636f748f82ee
+DATA k256<>+0xe8(SB)/8, $0x8cc7020884c87814
+DATA k256<>+0xf0(SB)/8, $0xa4506ceb90befffa
+DATA k256<>+0xf8(SB)/8, $0xc67178f2bef9a3f7
+GLOBL k256<>(SB), RODATA|NOPTR, $256
+
+// Byte order of the message words.
+DATA flipMask<>+0x00(SB)/8, $0x0405060700010203
+DATA flipMask<>+0x08(SB)/8, $0x0c0d0e0f08090a0b
+GLOBL flipMask<>(SB), RODATA|NOPTR, $16
@@ -0,0 +1,15 @@
+//go:build !amd64 || purego
+
+package wotsp
+
+// Other architectures, and builds with the purego build tag, compute all
+// chains with the hash function of the parameter set.
+const useSHANI = false
+
+func sha256Block(h *[8]uint32, p *[64]byte) {
+	panic("wotsp: SHA extensions not supported")
+}
+
+func sha256Blockx2(a, b *[8]uint32, pa, pb *[64]byte) {
+	panic("wotsp: SHA extensions not supported")
+}
@@ -0,0 +1,161 @@
+package wotsp
+
+import (
+	"bytes"
+	"container/list"
+	"reflect"
+	"sync"
+)
+
+// The number of public seeds whose hash precomputations a Verifier keeps if
+// NewVerifier is passed a size that is not positive.
+const DefaultVerifierCacheSize = 1024
+
+// Verifies signatures like PkFromSig and Verify, keeping the hash
+// precomputations of the public seeds it saw in a least-recently-used cache,
+// for verification-heavy workloads that see many signatures under the same
+// public seeds, e.g. nodes processing blocks. Verifications under a cached
+// public seed skip the precomputation; all other work is the same as for
+// PkFromSig. The private seed is never involved in verification, so the cache
+// is keyed by public seed alone.
+//
+// A Verifier is safe for concurrent use.
+type Verifier struct {
+	d    *derived
+	size int
+
+	mu    sync.Mutex
+	lru   *list.List
+	seeds map[string]*list.Element
+}
+
+// A cached precomputation of a Verifier.
+type verifierEntry struct {
+	pubSeed string
+	h       *hasher
+}
+
+// Creates a verifier using w=16 that caches the precomputations of at most
+// size public seeds, or DefaultVerifierCacheSize if size is not positive.
+func NewVerifier(size int) *Verifier {
+	return W16.NewVerifier(size)
+}
+
+// Like NewVerifier, using the parameter set p.
+func (p Params) NewVerifier(size int) *Verifier {
+	if size <= 0 {
+		size = DefaultVerifierCacheSize
+	}
+
+	return &Verifier{
+		d:     p.derive(),
+		size:  size,
+		lru:   list.New(),
+		seeds: make(map[string]*list.Element),
+	}
+}
+
+// Generates a public key from the given signature, like PkFromSig.
+func (v *Verifier) PkFromSig(sig, msg, pubSeed []byte, adrs *Address) []byte {
+	if !v.d.validInput(sig, msg) {
+		return nil
+	}
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := v.hasher(a, pubSeed, numRoutines)
+
+	// Compute chain lengths
+	lengths := h.lengths(msg)
+
+	// Compute public key
+	pubKey := make([]byte, h.l*h.n)
+	computeChains(h, numRoutines, sig, pubKey, lengths, adrs, true)
+
+	return pubKey
+}
+
+// Verifies the given signature on the given message, like Verify.
+func (v *Verifier) Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
+	pubKey := v.PkFromSig(sig, msg, pubSeed, adrs)
+
+	return pubKey != nil && bytes.Equal(pk, pubKey)
+}
+
+// Returns a hasher for pubSeed with the hash functions of numRoutines
+// routines, taken from the arena a. Its precomputed digests are copies of the
+// cached ones, so they remain valid if the cache entry is evicted or wiped
+// while the hasher is used.
+func (v *Verifier) hasher(a *Arena, pubSeed []byte, numRoutines int) *hasher {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	cached := v.lookup(pubSeed)
+	h := a.hasher()
+	*h = hasher{derived: v.d, arena: a, hasher: h.hasher[:0], hasherVal: h.hasherVal[:0]}
+	for i := 0; i < numRoutines; i++ {
+		h.hasher = append(h.hasher, a.hash(v.d.hash))
+		h.hasherVal = append(h.hasherVal, reflect.ValueOf(h.hasher[i]).Elem())
+	}
+	h.precompHashF = reflect.ValueOf(a.hash(v.d.hash)).Elem()
+	h.precompHashF.Set(cached.precompHashF)
+	h.precompPrfPubSeed = reflect.ValueOf(a.hash(v.d.hash)).Elem()
+	h.precompPrfPubSeed.Set(cached.precompPrfPubSeed)
+	h.multiBuffer, h.prfPubState = cached.multiBuffer, cached.prfPubState
+
+	return h
+}
+
+// Returns the cached precomputation of pubSeed, computing it on a miss and
+// evicting the least recently used one if the cache is full.
+func (v *Verifier) lookup(pubSeed []byte) *hasher {
+	if e, ok := v.seeds[string(pubSeed)]; ok {
+		v.lru.MoveToFront(e)
+		return e.Value.(*verifierEntry).h
+	}
+
+	if v.lru.Len() == v.size {
+		v.remove(v.lru.Back())
+	}
+	h := precompute(v.d, nil, pubSeed, 0)
+	v.seeds[string(pubSeed)] = v.lru.PushFront(&verifierEntry{pubSeed: string(pubSeed), h: h})
+
+	return h
+}
+
+// Removes the cache entry e, overwriting its precomputed digests.
+func (v *Verifier) remove(e *list.Element) {
+	entry := v.lru.Remove(e).(*verifierEntry)
+	delete(v.seeds, entry.pubSeed)
+	entry.h.precompHashF.Set(reflect.Zero(entry.h.precompHashF.Type()))
+	entry.h.precompPrfPubSeed.Set(reflect.Zero(entry.h.precompPrfPubSeed.Type()))
+}
+
+// Removes the precomputation of pubSeed from the cache, if it holds one.
+func (v *Verifier) Invalidate(pubSeed []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if e, ok := v.seeds[string(pubSeed)]; ok {
+		v.remove(e)
+	}
+}
+
+// Removes all precomputations from the cache, overwriting their digests. The
+// verifier remains usable, starting with an empty cache.
+func (v *Verifier) Wipe() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for v.lru.Len() > 0 {
+		v.remove(v.lru.Back())
+	}
+}
+
+// Returns the number of public seeds whose precomputations are cached.
+func (v *Verifier) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.lru.Len()
+}
@@ -0,0 +1,85 @@
+package wotsp
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp/testdata"
+)
+
+func TestVerifier(t *testing.T) {
+	otherSeed := append([]byte(nil), testdata.PubSeed...)
+	otherSeed[0] ^= 1
+	otherPk := GenPublicKey(testdata.Seed, otherSeed, &Address{})
+	otherSig := Sign(testdata.Message, testdata.Seed, otherSeed, &Address{})
+
+	// 1 - Public keys match PkFromSig, for cached and uncached public seeds
+	v := NewVerifier(1)
+	for i := 0; i < 3; i++ {
+		if !bytes.Equal(v.PkFromSig(testdata.Signature, testdata.Message, testdata.PubSeed, &Address{}), testdata.PubKey) {
+			t.Fatal("Verifier computed wrong public key")
+		}
+		if !v.Verify(otherPk, otherSig, testdata.Message, otherSeed, &Address{}) {
+			t.Fatal("Verifier rejected valid signature")
+		}
+	}
+	forged := append([]byte(nil), testdata.Signature...)
+	forged[3] ^= 1
+	if v.Verify(testdata.PubKey, forged, testdata.Message, testdata.PubSeed, &Address{}) {
+		t.Fatal("Verifier accepted forged signature")
+	}
+
+	// 2 - The cache holds at most size public seeds
+	if v.Len() != 1 {
+		t.Fatal("Expected 1 cached public seed, got", v.Len())
+	}
+	v = NewVerifier(0)
+	v.Verify(testdata.PubKey, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+	v.Verify(otherPk, otherSig, testdata.Message, otherSeed, &Address{})
+	if v.Len() != 2 {
+		t.Fatal("Expected 2 cached public seeds, got", v.Len())
+	}
+
+	// 3 - Invalidated and wiped precomputations are dropped, and computed again
+	// when needed
+	v.Invalidate(otherSeed)
+	if v.Len() != 1 {
+		t.Fatal("Invalidated public seed is still cached")
+	}
+	v.Wipe()
+	if v.Len() != 0 {
+		t.Fatal("Wiped verifier still caches public seeds")
+	}
+	if !v.Verify(otherPk, otherSig, testdata.Message, otherSeed, &Address{}) {
+		t.Fatal("Wiped verifier rejected valid signature")
+	}
+
+	// 4 - Verifiers are safe for concurrent use, also while the cache is wiped
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if i%2 == 0 && !v.Verify(otherPk, otherSig, testdata.Message, otherSeed, &Address{}) ||
+					i%2 == 1 && !v.Verify(testdata.PubKey, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{}) {
+					t.Error("Concurrent verification failed")
+				}
+				if j == 5 {
+					v.Wipe()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func BenchmarkVerifier_PkFromSig(b *testing.B) {
+	b.ReportAllocs()
+	v := NewVerifier(0)
+
+	for i := 0; i < b.N; i++ {
+		_ = v.PkFromSig(testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
+	}
+}
@@ -1,42 +1,60 @@
 // Implements WOTSP-SHA2_256 as documented in the IETF XMSS draft
 // (https://datatracker.ietf.org/doc/draft-irtf-cfrg-xmss-hash-based-signatures/)
+//
+// The functions of the package use w=16. Other Winternitz parameters and hash
+// functions are supported through Params, and the compressed signatures of
+// WOTS+C through Compressed.
+//
+// The chains are those of WOTS-T, the tightened WOTS+ of XMSS-T that the draft
+// adopted: every call of F uses its own key and bitmask, derived from the
+// public seed and the address of the call. Keys that differ in their public
+// seed or address are thereby domain separated, which prevents multi-target
+// attacks on the chains.
+//
+// ParamsSHA2_256_W16, and so the functions of the package, are bit-for-bit
+// compatible with WOTSP-SHA2_256 of RFC 8391: F and PRF hash their key and
+// input after a 32 byte prefix holding 0 and 3 respectively, the Address has
+// the layout of an RFC OTS hash address, and messages are signed as they are.
+// The RFC leaves the derivation of private keys to implementations: like the
+// XMSS reference implementation, element i of the private key is PRF(seed,
+// toByte(i, 32)). Callers must leave the type of the address at zero, the type
+// of OTS hash addresses.
+//
+// XNYSS builds on these primitives, but its signatures are not RFC 8391
+// signatures: it uses w=256 (package wotsp256), which the RFC does not define,
+// signs a digest of the message and the child public key hashes rather than the
+// message, uses the all-zero address unless position addressing is
+// enabled, and may use other hash functions than SHA-256.
 package wotsp
 
 import (
 	"encoding/binary"
 	"bytes"
-	"runtime"
-	"sync"
+	"errors"
+)
+
+var (
+	ErrChainBounds = errors.New("WOTS+ chain computed beyond its last element")
+	ErrShortBuffer = errors.New("WOTS+ destination buffer too short")
 )
 
 const n = 32
-const w = 16
-const l1 = 64
-const l2 = 3
-const l = l1 + l2
 
+// Lengths for the parameter set W16, used by the functions of the package.
 const MsgLen = n
-const SigLen = l * n
-const PubKeyLen = l * n
-
-// Computes the base-16 representation of a binary input.
-func base16(x []byte, outlen int) []uint8 {
-	var total byte
-	in := 0
-	out := 0
-	bits := uint(0)
-	baseW := make([]uint8, outlen)
-
-	for consumed := 0; consumed < outlen; consumed++ {
-		if bits == 0 {
-			total = x[in]
-			in++
-			bits += 8
-		}
-
-		bits -= 4
-		baseW[out] = uint8((total >> bits) & byte(15))
-		out++
+const SigLen = 67 * n
+const PubKeyLen = 67 * n
+
+// Computes the base-w representation of a binary input into baseW, whose
+// length is the number of digits.
+func (d *derived) baseW(x []byte, baseW []uint8) []uint8 {
+	perByte := 8 / d.logw
+
+	// Every output digit is computed the same way, without branching on x:
+	// the first digit of a byte holds its most significant bits.
+	for i := range baseW {
+		shift := uint(d.logw * (perByte - 1 - i%perByte))
+		baseW[i] = (x[i/perByte] >> shift) & uint8(d.w-1)
 	}
 
 	return baseW
@@ -48,27 +66,42 @@ func base16(x []byte, outlen int) []uint8 {
 //
 // Scratch is used as a scratch pad: it is pre-allocated to precent every call
 // to chain from allocating slices for keys and bitmask. It is used as:
-// 		scratch = output || key || bitmask.
+// 		scratch = key || bitmask,
+// followed by the current chain element in chainSelect.
+//
+// Panics with ErrChainBounds if start+steps exceeds w-1. The sum is computed
+// on ints, since it does not fit in a uint8 for w=256.
 func chain(h *hasher, routineNr int, in, out, scratch []byte, start, steps uint8, adrs *Address) {
+	n := h.n
+	end := int(start) + int(steps)
+	if end > h.w-1 {
+		panic(ErrChainBounds)
+	}
 	copy(out, in)
 
-	for i := start; i < start+steps; i++ {
+	for i := int(start); i < end; i++ {
 		adrs.setHash(uint32(i))
 
 		adrs.setKeyAndMask(0)
-		h.prfPubSeed(routineNr, adrs, scratch[:32])
+		h.prfPubSeed(routineNr, adrs, scratch[:n])
 		adrs.setKeyAndMask(1)
-		h.prfPubSeed(routineNr, adrs, scratch[32:64])
+		h.prfPubSeed(routineNr, adrs, scratch[n:2*n])
 
 		for j := 0; j < n; j++ {
-			out[j] = out[j] ^ scratch[32+j]
+			out[j] = out[j] ^ scratch[n+j]
 		}
 
-		h.hashF(routineNr, scratch[:32], out)
+		h.hashF(routineNr, scratch[:n], out)
 	}
 }
 
-// Distributes the chains that must be computed between GOMAXPROCS goroutines.
+// The length of the scratch pad of a chain computation, in multiples of n.
+const scratchLen = 3
+
+// Distributes the chains that must be computed between numRoutines
+// goroutines, see routines. With a single routine, or in serial builds, the
+// chains are computed in the calling goroutine, since spawning workers would
+// only add overhead.
 //
 // When fromSig is true, in contains a signature and out must be a public key;
 // in this case the routines must complete the signature chains so they use
@@ -76,51 +109,62 @@ func chain(h *hasher, routineNr int, in, out, scratch []byte, start, steps uint8
 // public key from a private key, or a signature from a private key, so the
 // routines use lengths as the amount of iterations to perform.
 func computeChains(h *hasher, numRoutines int, in, out []byte, lengths []uint8, adrs *Address, fromSig bool) {
-	chainsPerRoutine := (l-1)/numRoutines + 1
-
-	// Initialise scratch pad
-	scratch := make([]byte, numRoutines * 64)
-
-	wg := new(sync.WaitGroup)
-	for i := 0; i < numRoutines; i++ {
-		// Copy address structure
-		chainAdrs := new(Address)
-		copy(chainAdrs.data[:], adrs.data[:])
-
-		wg.Add(1)
-		go func(nr int, scratch []byte, adrs *Address) {
-			firstChain := nr * chainsPerRoutine
-			lastChain := firstChain + chainsPerRoutine - 1
-
-			// Make sure the last routine ends at the right chain
-			if lastChain >= l {
-				lastChain = l - 1
-			}
-
-			// Compute the hash chains
-			for j := firstChain; j <= lastChain; j++ {
-				adrs.setChain(uint32(j))
-				if fromSig {
-					chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, lengths[j], w-1-lengths[j], adrs)
-				} else {
-					chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, 0, lengths[j], adrs)
-				}
-			}
-			wg.Done()
-		}(i, scratch[i*64:(i+1)*64], chainAdrs)
+	if numRoutines == 1 || serialBuild {
+		computeChainsSerial(h, in, out, lengths, adrs, fromSig)
+		return
 	}
 
-	wg.Wait()
+	computeChainsParallel(h, numRoutines, in, out, lengths, adrs, fromSig)
 }
 
-// Expands a 32-byte seed into an (l*n)-byte private key.
+// Computes all chains in the calling goroutine, using the hasher of routine 0.
+// The address is copied into the hasher, so it does not escape to the heap.
+func computeChainsSerial(h *hasher, in, out []byte, lengths []uint8, adrs *Address, fromSig bool) {
+	constantTime := ConstantTime
+
+	h.chainAdrs = *adrs
+	scratch := h.alloc(scratchLen * h.n)
+	for j := 0; j < h.l; {
+		j += computeNextChains(h, 0, j, h.l, in, out, scratch, lengths, &h.chainAdrs, fromSig, constantTime)
+	}
+}
+
+// Computes chain j for computeChains, together with chain j+1 if it is below
+// end and the hasher computes chains on the multi-buffer path. Returns the
+// number of chains computed.
+func computeNextChains(h *hasher, nr, j, end int, in, out, scratch []byte, lengths []uint8, adrs *Address, fromSig, constantTime bool) int {
+	if h.multiBuffer && j+1 < end {
+		computeChainPair(h, j, in, out, lengths, adrs, fromSig, constantTime)
+		return 2
+	}
+	computeChain(h, nr, j, in, out, scratch, lengths, adrs, fromSig, constantTime)
+
+	return 1
+}
+
+// Computes chain j for computeChains, using the hasher of routine nr.
+func computeChain(h *hasher, nr, j int, in, out, scratch []byte, lengths []uint8, adrs *Address, fromSig, constantTime bool) {
+	n, w := h.n, h.w
+
+	adrs.setChain(uint32(j))
+	if fromSig {
+		chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, lengths[j], uint8(w-1)-lengths[j], adrs)
+	} else if constantTime {
+		chainSelect(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, lengths[j], adrs)
+	} else {
+		chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, 0, lengths[j], adrs)
+	}
+}
+
+// Expands a seed into an (l*n)-byte private key, in a buffer of the hasher.
 func expandSeed(h *hasher) []byte {
-	privKey := make([]byte, l*n)
-	ctr := make([]byte, 32)
+	privKey := h.alloc(h.l * h.n)
+	ctr := h.alloc(32)
+	wipe(ctr)
 
-	for i := 0; i < l; i++ {
+	for i := 0; i < h.l; i++ {
 		binary.BigEndian.PutUint16(ctr[30:], uint16(i))
-		h.prfPrivSeed(0, ctr, privKey[i*n:])
+		h.prfPrivSeed(0, ctr, privKey[i*h.n:])
 	}
 
 	return privKey
@@ -128,81 +172,130 @@ func expandSeed(h *hasher) []byte {
 
 // Computes the public key that corresponds to the expanded seed.
 func GenPublicKey(seed, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(seed, pubSeed, numRoutines)
+	return W16.GenPublicKey(seed, pubSeed, adrs)
+}
 
-	// Initialise private key
-	privKey := expandSeed(h)
+// Like GenPublicKey, using the parameter set p.
+func (p Params) GenPublicKey(seed, pubSeed []byte, adrs *Address) []byte {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, p.derive(), seed, pubSeed, numRoutines)
 
-	// Initialise list of chain lengths for full chains
-	lengths := make([]uint8, l)
-	for i := range lengths {
-		lengths[i] = w-1
-	}
+	return genPublicKey(h, numRoutines, adrs)
+}
 
-	// Compute public key
-	pubKey := make([]byte, l*n)
-	computeChains(h, numRoutines, privKey, pubKey, lengths, adrs, false)
+func genPublicKey(h *hasher, numRoutines int, adrs *Address) []byte {
+	k := newPrivateKey(h, numRoutines)
+	defer k.Wipe()
 
-	return pubKey
+	return k.PublicKey(adrs)
 }
 
-func checksum(msg []uint8) []uint8 {
+// Computes the base-w digits of the checksum of msg into out.
+func (d *derived) checksum(msg []uint8, out []uint8) []uint8 {
 	csum := uint32(0)
-	for i := 0; i < l1; i++ {
-		csum += uint32(w - 1 - msg[i])
+	for i := 0; i < d.l1; i++ {
+		csum += uint32(d.w - 1 - int(msg[i]))
 	}
-	csum <<= 4 // 8 - ((l2 * logw) % 8)
+	// Left-align the checksum in its bytes. If l2*logw is a multiple of 8, as
+	// for w=256, the checksum is already aligned.
+	csum <<= uint((8 - (d.l2*d.logw)%8) % 8)
+
+	// Length of the checksum is (l2*logw + 7) / 8, which is at most 2 bytes
+	// for the supported parameters, so we can truncate csum.
+	var csumBytes [4]byte
+	binary.BigEndian.PutUint32(csumBytes[:], csum)
+
+	return d.baseW(csumBytes[4-(d.l2*d.logw+7)/8:], out[:d.l2])
+}
+
+// Computes the chain lengths for msg: its base-w digits, followed by the
+// digits of the checksum.
+func (d *derived) lengths(msg []byte) []uint8 {
+	return d.lengthsIn(make([]uint8, d.l), msg)
+}
 
-	// Length of the checksum is (l2*logw + 7) / 8
-	csumBytes := make([]byte, 2)
-	// Since bytesLen is always 2, we can truncate csum to a uint16.
-	binary.BigEndian.PutUint16(csumBytes, uint16(csum))
+// Like lengths, computing the l lengths into out.
+func (d *derived) lengthsIn(out, msg []byte) []uint8 {
+	d.baseW(msg, out[:d.l1])
+
+	// Compute checksum
+	d.checksum(out[:d.l1], out[d.l1:])
+
+	return out
+}
+
+// Reports whether sig and msg have the lengths of signatures and messages.
+// Signatures that are verified must be checked first, since the chains are
+// computed on slices of them.
+func (d *derived) validInput(sig, msg []byte) bool {
+	return len(sig) == d.l*d.n && len(msg) == d.n
+}
 
-	return base16(csumBytes, l2)
+// Like derived.lengths, in a buffer allocated by the hasher.
+func (c *hasher) lengths(msg []byte) []uint8 {
+	return c.lengthsIn(c.alloc(c.l), msg)
 }
 
 // Signs message msg using the private key generated using the given seed.
 func Sign(msg, seed, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(seed, pubSeed, numRoutines)
-
-	// Initialise private key
-	privKey := expandSeed(h)
+	return W16.Sign(msg, seed, pubSeed, adrs)
+}
 
-	// Compute chain lengths
-	lengths := base16(msg, l1)
+// Like Sign, using the parameter set p.
+func (p Params) Sign(msg, seed, pubSeed []byte, adrs *Address) []byte {
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, p.derive(), seed, pubSeed, numRoutines)
 
-	// Compute checksum
-	csum := checksum(lengths)
-	lengths = append(lengths, csum...)
+	return sign(h, numRoutines, msg, adrs)
+}
 
-	// Compute signature
-	sig := make([]byte, l*n)
-	computeChains(h, numRoutines, privKey, sig, lengths, adrs, false)
+func sign(h *hasher, numRoutines int, msg []byte, adrs *Address) []byte {
+	k := newPrivateKey(h, numRoutines)
+	defer k.Wipe()
 
-	return sig
+	return k.Sign(msg, adrs)
 }
 
-// Generates a public key from the given signature
+// Generates a public key from the given signature. Returns nil if sig or msg
+// is not SigLen or MsgLen bytes long.
 func PkFromSig(sig, msg, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(nil, pubSeed, numRoutines)
+	return W16.PkFromSig(sig, msg, pubSeed, adrs)
+}
 
-	lengths := base16(msg, l1)
+// Like PkFromSig, using the parameter set p.
+func (p Params) PkFromSig(sig, msg, pubSeed []byte, adrs *Address) []byte {
+	d := p.derive()
+	if !d.validInput(sig, msg) {
+		return nil
+	}
+	numRoutines := routines()
+	a := getArena()
+	defer putArena(a)
+	h := precomputeIn(a, d, nil, pubSeed, numRoutines)
 
-	// Compute checksum
-	csum := checksum(lengths)
-	lengths = append(lengths, csum...)
+	// Compute chain lengths
+	lengths := h.lengths(msg)
 
 	// Compute public key
-	pubKey := make([]byte, l*n)
+	pubKey := make([]byte, h.l*h.n)
 	computeChains(h, numRoutines, sig, pubKey, lengths, adrs, true)
 
 	return pubKey
 }
 
-// Verifies the given signature on the given message.
+// Verifies the given signature on the given message. Signatures and messages
+// of the wrong length are invalid.
 func Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
-	return bytes.Equal(pk, PkFromSig(sig, msg, pubSeed, adrs))
+	return W16.Verify(pk, sig, msg, pubSeed, adrs)
+}
+
+// Like Verify, using the parameter set p.
+func (p Params) Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
+	pubKey := p.PkFromSig(sig, msg, pubSeed, adrs)
+
+	return pubKey != nil && bytes.Equal(pk, pubKey)
 }
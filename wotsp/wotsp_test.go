@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"github.com/Re0h/xnyss/wotsp/testdata"
 	"crypto/rand"
+	"runtime"
 )
 
 func TestAddressToBytes(t *testing.T) {
@@ -92,6 +93,20 @@ func TestAll(t *testing.T) {
 	}
 }
 
+func TestSingleWorker(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	if !bytes.Equal(GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{}), testdata.PubKey) {
+		t.Error("Wrong key")
+	}
+	if !bytes.Equal(Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{}), testdata.Signature) {
+		t.Error("Wrong signature")
+	}
+	if !Verify(testdata.PubKey, testdata.Signature, testdata.Message, testdata.PubSeed, &Address{}) {
+		t.Error("Wrong public key")
+	}
+}
+
 func BenchmarkGenPublicKey(b *testing.B) {
 	b.ReportAllocs()
 
@@ -115,3 +130,94 @@ func BenchmarkPkFromSig(b *testing.B) {
 		_ = PkFromSig(testdata.Signature, testdata.Message, testdata.PubSeed, &Address{})
 	}
 }
+
+func BenchmarkSign_SingleWorker(b *testing.B) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_ = Sign(testdata.Message, testdata.Seed, testdata.PubSeed, &Address{})
+	}
+}
+
+func TestChain_Bounds(t *testing.T) {
+	h := precompute(W256.derive(), nil, testdata.PubSeed, 1)
+	scratch := make([]byte, 2*n)
+	full := make([]byte, n)
+	chain(h, 0, testdata.Seed, full, scratch, 0, 255, &Address{})
+
+	// 1 - Chains may end at element w-1, even if start+steps wraps as a uint8
+	for _, start := range []uint8{200, 254, 255} {
+		mid := make([]byte, n)
+		out := make([]byte, n)
+		chain(h, 0, testdata.Seed, mid, scratch, 0, start, &Address{})
+		chain(h, 0, mid, out, scratch, start, 255-start, &Address{})
+		if !bytes.Equal(out, full) {
+			t.Fatal("Invalid chain ending at w-1 from", start)
+		}
+	}
+
+	// 2 - Chains beyond element w-1 are rejected instead of wrapping
+	for _, tc := range []struct{ start, steps uint8 }{{1, 255}, {255, 1}, {128, 200}} {
+		func() {
+			defer func() {
+				if recover() != ErrChainBounds {
+					t.Fatal("Chain beyond w-1 not rejected -", tc.start, tc.steps)
+				}
+			}()
+			chain(h, 0, full, make([]byte, n), scratch, tc.start, tc.steps, &Address{})
+		}()
+	}
+
+	// 3 - The same holds for smaller w, where the lengths fit a uint8
+	h = precompute(W16.derive(), nil, testdata.PubSeed, 1)
+	func() {
+		defer func() {
+			if recover() != ErrChainBounds {
+				t.Fatal("Chain beyond w-1 not rejected for w = 16")
+			}
+		}()
+		chain(h, 0, full, make([]byte, n), scratch, 15, 1, &Address{})
+	}()
+}
+
+func TestPkFromSig_InvalidLength(t *testing.T) {
+	for _, p := range []Params{W16, W256} {
+		msg := make([]byte, p.MsgLen())
+		sig := p.Sign(msg, testdata.Seed, testdata.PubSeed, &Address{})
+		pk := p.GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})
+		verifier := p.NewVerifier(1)
+
+		// 1 - Truncated and extended signatures and messages are rejected
+		// without panicking
+		for _, tc := range []struct{ sig, msg []byte }{
+			{sig[:10], msg},
+			{sig[:len(sig)-1], msg},
+			{append(sig, 0), msg},
+			{sig, msg[:3]},
+			{sig, append(msg, 0)},
+			{nil, nil},
+		} {
+			if p.PkFromSig(tc.sig, tc.msg, testdata.PubSeed, &Address{}) != nil ||
+				p.PkFromSigTo(make([]byte, p.PubKeyLen()), tc.sig, tc.msg, testdata.PubSeed, &Address{}) != nil ||
+				p.PkFromSigArena(NewArena(), tc.sig, tc.msg, testdata.PubSeed, &Address{}) != nil ||
+				verifier.PkFromSig(tc.sig, tc.msg, testdata.PubSeed, &Address{}) != nil {
+				t.Fatal("Computed public key of input with invalid length", len(tc.sig), len(tc.msg))
+			}
+			if p.Verify(pk, tc.sig, tc.msg, testdata.PubSeed, &Address{}) ||
+				verifier.Verify(pk, tc.sig, tc.msg, testdata.PubSeed, &Address{}) {
+				t.Fatal("Verified input with invalid length", len(tc.sig), len(tc.msg))
+			}
+		}
+
+		// 2 - An empty public key does not match the missing key
+		if p.Verify(nil, sig[:10], msg, testdata.PubSeed, &Address{}) {
+			t.Fatal("Verified truncated signature against empty public key")
+		}
+
+		// 3 - Valid input still verifies
+		if !p.Verify(pk, sig, msg, testdata.PubSeed, &Address{}) {
+			t.Fatal("Valid signature rejected")
+		}
+	}
+}
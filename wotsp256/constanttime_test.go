@@ -0,0 +1,58 @@
+package wotsp256
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/Re0h/xnyss/wotsp"
+	"github.com/Re0h/xnyss/wotsp256/testdata"
+)
+
+func signConstantTime(msg []byte) []byte {
+	wotsp.ConstantTime = true
+	defer func() { wotsp.ConstantTime = false }()
+
+	return Sign(msg, testdata.Seed, testdata.PubSeed, &Address{})
+}
+
+func TestSign_ConstantTime(t *testing.T) {
+	for _, fill := range []byte{0x00, 0x5a, 0xff} {
+		msg := bytes.Repeat([]byte{fill}, MsgLen)
+
+		if !bytes.Equal(signConstantTime(msg), Sign(msg, testdata.Seed, testdata.PubSeed, &Address{})) {
+			t.Fatal("Constant-time signature differs for message filled with", fill)
+		}
+	}
+}
+
+// Returns the median time of count constant-time signatures of msg.
+func medianSignTime(msg []byte, count int) time.Duration {
+	times := make([]time.Duration, count)
+	for i := range times {
+		start := time.Now()
+		signConstantTime(msg)
+		times[i] = time.Since(start)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[count/2]
+}
+
+// A timing-leak heuristic: the messages with the shortest and longest chains
+// should take about as long to sign. Without ConstantTime, their times differ
+// by more than a factor of two.
+func TestSign_ConstantTimeLeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test skipped in short mode")
+	}
+
+	short, long := make([]byte, MsgLen), bytes.Repeat([]byte{0xff}, MsgLen)
+	medianSignTime(short, 1)
+
+	ratio := float64(medianSignTime(long, 7)) / float64(medianSignTime(short, 7))
+	if ratio < 0.7 || ratio > 1.4 {
+		t.Fatal("Signing time depends on the message, ratio", ratio)
+	}
+}
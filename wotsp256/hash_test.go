@@ -0,0 +1,32 @@
+package wotsp256
+
+import (
+	"crypto/rand"
+	"crypto/sha3"
+	"hash"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+func TestHash(t *testing.T) {
+	seed := make([]byte, 32)
+	pubSeed := make([]byte, 32)
+	msg := make([]byte, 32)
+	for _, b := range [][]byte{seed, pubSeed, msg} {
+		if _, err := rand.Read(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hf := wotsp.Params{W: 256, Hash: func() hash.Hash { return sha3.New256() }}
+	pubKey := hf.GenPublicKey(seed, pubSeed, &Address{})
+	signed := hf.Sign(msg, seed, pubSeed, &Address{})
+
+	if !hf.Verify(pubKey, signed, msg, pubSeed, &Address{}) {
+		t.Fatal("Failed to verify signature using SHA3-256")
+	}
+	if Verify(pubKey, signed, msg, pubSeed, &Address{}) {
+		t.Fatal("Verified SHA3-256 signature using SHA-256")
+	}
+}
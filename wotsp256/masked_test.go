@@ -0,0 +1,26 @@
+package wotsp256
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Re0h/xnyss/wotsp256/testdata"
+)
+
+func TestMasked(t *testing.T) {
+	mask := bytes.Repeat([]byte{0xa5}, n)
+	masked := make([]byte, n)
+	for i := range masked {
+		masked[i] = testdata.Seed[i] ^ mask[i]
+	}
+
+	pubKey := GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})
+	if !bytes.Equal(GenPublicKeyMasked(masked, mask, testdata.PubSeed, &Address{}), pubKey) {
+		t.Fatal("Masked public key differs")
+	}
+
+	sig := SignMasked(testdata.Message, masked, mask, testdata.PubSeed, &Address{})
+	if !Verify(pubKey, sig, testdata.Message, testdata.PubSeed, &Address{}) {
+		t.Fatal("Masked signature does not verify")
+	}
+}
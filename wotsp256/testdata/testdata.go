@@ -149,10 +149,10 @@ var Signature = []byte{
 	0x24, 0xb2, 0x77, 0xe9, 0xda, 0xa6, 0x2d, 0x30, 0x0b, 0xe7, 0x00, 0x34, 0x1e, 0xc5, 0xa0, 0x64, 0x58, 0x32, 0x49,
 	0x11, 0xbf, 0xa9, 0x25, 0x96, 0xa0, 0x6a, 0xea, 0xd6, 0x49, 0x2b, 0x58, 0x9c, 0xdf, 0xa8, 0x3b, 0xfc, 0x14, 0xb7,
 	0x56, 0xaa, 0xb6, 0x0e, 0xae, 0x7c, 0x89, 0xa7, 0x55, 0x14, 0xfb, 0x57, 0xae, 0x05, 0x65, 0xd1, 0x53, 0xd4, 0x38,
-	0xcf, 0x8a, 0x2c, 0xeb, 0x78, 0x18, 0x26, 0x0f, 0xf7, 0xe9, 0x21, 0x48, 0xcc, 0xe0, 0x9b, 0x46, 0xe1, 0x30, 0x78,
-	0x10, 0x9a, 0xcf, 0x41, 0xd9, 0x6c, 0x71, 0xb8, 0xb8, 0xc7, 0xe4, 0x15, 0xea, 0xd2, 0x32, 0x81, 0x37, 0xdc, 0xea,
-	0x92, 0x86, 0x54, 0xc7, 0x6d, 0xa5, 0x9e, 0xf8, 0xa2, 0xb0, 0x77, 0x9c, 0x65, 0xb0, 0xd7, 0x40, 0x21, 0x9d, 0xf0,
-	0xc1, 0x8c, 0x49, 0x37, 0xb8, 0xf8, 0x61, 0x4b, 0x0a, 0x06, 0x91, 0x73, 0x95, 0xf2, 0x41, 0x33, 0x82, 0x10, 0x68,
-	0xbc, 0x49, 0x88, 0x42, 0x15,
+	0xcf, 0x8a, 0x2c, 0xeb, 0x78, 0x18, 0x26, 0x0f, 0xf7, 0xe9, 0x21, 0x48, 0xcc, 0xe0, 0x9b, 0x46, 0xe1, 0x8e, 0xbd,
+	0x74, 0xaa, 0xb3, 0xbe, 0x2a, 0x46, 0xea, 0xa7, 0x36, 0x90, 0x40, 0x31, 0x0a, 0x3f, 0x84, 0x6f, 0x6c, 0x85, 0x24,
+	0x11, 0xcb, 0x61, 0xe6, 0x8d, 0x10, 0xb7, 0x7a, 0xbf, 0xe2, 0xa2, 0x9c, 0x20, 0xb9, 0x16, 0x92, 0x36, 0xb3, 0x1c,
+	0xf3, 0x5e, 0x20, 0x1d, 0x69, 0x68, 0x4f, 0x93, 0xdf, 0x90, 0x62, 0x0f, 0xb3, 0x5b, 0xf7, 0xab, 0xea, 0x3b, 0x3b,
+	0xdd, 0x99, 0x27, 0x50, 0x30,
 }
 
@@ -1,192 +1,106 @@
+// Implements WOTS+ with w=256 on top of package wotsp: this package is the
+// parameter set wotsp.W256, with the same API as package wotsp.
 package wotsp256
 
 import (
-	"encoding/binary"
-	"bytes"
-	"sync"
-	"runtime"
+	"context"
+
+	"github.com/Re0h/xnyss/wotsp"
 )
 
 const n = 32
-const w = 256
-const l1 = 32
-const l2 = 2
-const l = l1 + l2
 
 const MsgLen = n
-const SigLen = l * n
-const PubKeyLen = l * n
-
-// Computes the base-256 representation of a binary input.
-func base256(x []byte, outlen int) []uint8 {
-	baseW := make([]uint8, outlen)
-	copy(baseW, x)
-
-	return baseW
-}
+const SigLen = 34 * n
+const PubKeyLen = 34 * n
 
-// Performs the chaining operation using an n-byte input and n-byte seed.
-// Assumes the input is the <start>-th element in the chain, and performs
-// <steps> iterations.
-//
-// Scratch is used as a scratch pad: it is pre-allocated to precent every call
-// to chain from allocating slices for keys and bitmask. It is used as:
-// 		scratch = key || bitmask.
-func chain(h *hasher, routineNr int, in, out, scratch []byte, start, steps uint8, adrs *Address) {
-	copy(out, in)
-
-	for i := start; i < start+steps; i++ {
-		adrs.setHash(uint32(i))
-
-		adrs.setKeyAndMask(0)
-		h.prfPubSeed(routineNr, adrs, scratch[:32])
-		adrs.setKeyAndMask(1)
-		h.prfPubSeed(routineNr, adrs, scratch[32:64])
-
-		for j := 0; j < n; j++ {
-			out[j] = out[j] ^ scratch[32+j]
-		}
-
-		h.hashF(routineNr, scratch[:32], out)
-	}
-}
-
-// Distributes the chains that must be computed between GOMAXPROCS goroutines.
-//
-// When fromSig is true, in contains a signature and out must be a public key;
-// in this case the routines must complete the signature chains so they use
-// lengths as start indices. If fromSig is false, we are either computing a
-// public key from a private key, or a signature from a private key, so the
-// routines use lengths as the amount of iterations to perform.
-func computeChains(h *hasher, numRoutines int, in, out []byte, lengths []uint8, adrs *Address, fromSig bool) {
-	chainsPerRoutine := (l-1)/numRoutines + 1
-
-	// Initialise scratch pad
-	scratch := make([]byte, numRoutines * 64)
-
-	wg := new(sync.WaitGroup)
-	for i := 0; i < numRoutines; i++ {
-		// Copy address structure
-		chainAdrs := new(Address)
-		copy(chainAdrs.data[:], adrs.data[:])
-
-		wg.Add(1)
-		go func(nr int, scratch []byte, adrs *Address) {
-			firstChain := nr * chainsPerRoutine
-			lastChain := firstChain + chainsPerRoutine - 1
-
-			// Make sure the last routine ends at the right chain
-			if lastChain >= l {
-				lastChain = l - 1
-			}
-
-			// Compute the hash chains
-			for j := firstChain; j <= lastChain; j++ {
-				adrs.setChain(uint32(j))
-				if fromSig {
-					chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, lengths[j], w-1-lengths[j], adrs)
-				} else {
-					chain(h, nr, in[j*n:(j+1)*n], out[j*n:(j+1)*n], scratch, 0, lengths[j], adrs)
-				}
-			}
-			wg.Done()
-		}(i, scratch[i*64:(i+1)*64], chainAdrs)
-	}
-
-	wg.Wait()
-}
+// See wotsp.Address.
+type Address = wotsp.Address
 
-// Expands a 32-byte seed into an (l*n)-byte private key.
-func expandSeed(h *hasher) []byte {
-	privKey := make([]byte, l*n)
-	ctr := make([]byte, 32)
+// See wotsp.Hash.
+type Hash = wotsp.Hash
 
-	for i := 0; i < l; i++ {
-		binary.BigEndian.PutUint16(ctr[30:], uint16(i))
-		h.prfPrivSeed(0, ctr, privKey[i*n:])
-	}
+// SHA-256, the default hash function of wotsp.Params.
+var SHA256 = wotsp.SHA256
 
-	return privKey
-}
+// The parameter set implemented by the package.
+var Params = wotsp.W256
 
 // Computes the public key that corresponds to the expanded seed.
 func GenPublicKey(seed, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(seed, pubSeed, numRoutines)
-
-	privKey := expandSeed(h)
-
-	// Initialise list of chain lengths for full chains
-	lengths := make([]uint8, l)
-	for i := range lengths {
-		lengths[i] = w-1
-	}
-
-	// Compute public key
-	pubKey := make([]byte, l*n)
-	computeChains(h, numRoutines, privKey, pubKey, lengths, adrs, false)
+	return Params.GenPublicKey(seed, pubSeed, adrs)
+}
 
-	return pubKey
+// Signs message msg using the private key generated using the given seed.
+func Sign(msg, seed, pubSeed []byte, adrs *Address) []byte {
+	return Params.Sign(msg, seed, pubSeed, adrs)
 }
 
-func checksum(msg []uint8) []uint8 {
-	csum := uint32(0)
-	for i := 0; i < l1; i++ {
-		csum += uint32(w - 1 - msg[i])
-	}
-	csum <<= 8 // 8 - ((l2 * logw) % 8)
+// Generates a public key from the given signature. Returns nil if sig or msg
+// has the wrong length.
+func PkFromSig(sig, msg, pubSeed []byte, adrs *Address) []byte {
+	return Params.PkFromSig(sig, msg, pubSeed, adrs)
+}
 
-	// Length of the checksum is (l2*logw + 7) / 8
-	csumBytes := make([]byte, 2)
-	// Since bytesLen is always 2, we can truncate it to a uint16.
-	binary.BigEndian.PutUint16(csumBytes, uint16(csum))
+// Verifies the given signature on the given message. Signatures and messages
+// of the wrong length are invalid.
+func Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
+	return Params.Verify(pk, sig, msg, pubSeed, adrs)
+}
 
-	return base256(csumBytes, l2)
+// See wotsp.GenPublicKeyMasked.
+func GenPublicKeyMasked(maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	return Params.GenPublicKeyMasked(maskedSeed, mask, pubSeed, adrs)
 }
 
-// Signs message msg using the private key generated using the given seed.
-func Sign(msg, seed, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(seed, pubSeed, numRoutines)
+// See wotsp.SignMasked.
+func SignMasked(msg, maskedSeed, mask, pubSeed []byte, adrs *Address) []byte {
+	return Params.SignMasked(msg, maskedSeed, mask, pubSeed, adrs)
+}
 
-	// Initialise private key
-	privKey := expandSeed(h)
+// See wotsp.GenPublicKeyTo.
+func GenPublicKeyTo(dst, seed, pubSeed []byte, adrs *Address) []byte {
+	return Params.GenPublicKeyTo(dst, seed, pubSeed, adrs)
+}
 
-	// Compute chain lengths
-	lengths := base256(msg, l1)
+// See wotsp.SignTo.
+func SignTo(dst, msg, seed, pubSeed []byte, adrs *Address) []byte {
+	return Params.SignTo(dst, msg, seed, pubSeed, adrs)
+}
 
-	// Compute checksum
-	csum := checksum(lengths)
-	lengths = append(lengths, csum...)
+// See wotsp.PkFromSigTo.
+func PkFromSigTo(dst, sig, msg, pubSeed []byte, adrs *Address) []byte {
+	return Params.PkFromSigTo(dst, sig, msg, pubSeed, adrs)
+}
 
-	// Compute signature
-	sig := make([]byte, l*n)
-	computeChains(h, numRoutines, privKey, sig, lengths, adrs, false)
+// See wotsp.PrivateKey.
+type PrivateKey = wotsp.PrivateKey
 
-	return sig
+// Expands the private key of the given seed and public seed, see
+// wotsp.PrivateKey.
+func NewPrivateKey(seed, pubSeed []byte) *PrivateKey {
+	return Params.NewPrivateKey(seed, pubSeed)
 }
 
-// Generates a public key from the given signature
-func PkFromSig(sig, msg, pubSeed []byte, adrs *Address) []byte {
-	numRoutines := runtime.GOMAXPROCS(-1)
-	h := precompute(nil, pubSeed, numRoutines)
-
-	// Compute chain lengths
-	lengths := base256(msg, l1)
+// See wotsp.Arena.
+type Arena = wotsp.Arena
 
-	// Compute checksum
-	csum := checksum(lengths)
-	lengths = append(lengths, csum...)
+// Like PkFromSig, computing the public key in buffers of the arena a, see
+// wotsp.Arena.
+func PkFromSigArena(a *Arena, sig, msg, pubSeed []byte, adrs *Address) []byte {
+	return Params.PkFromSigArena(a, sig, msg, pubSeed, adrs)
+}
 
-	// Compute public key
-	pubKey := make([]byte, l*n)
-	computeChains(h, numRoutines, sig, pubKey, lengths, adrs, true)
+// See wotsp.VerifyItem.
+type VerifyItem = wotsp.VerifyItem
 
-	return pubKey
+// Verifies every item like Verify, see wotsp.Params.VerifyBatch.
+func VerifyBatch(items []VerifyItem, workers int) []bool {
+	return Params.VerifyBatch(items, workers)
 }
 
-// Verifies the given signature on the given message.
-func Verify(pk, sig, msg, pubSeed []byte, adrs *Address) bool {
-	return bytes.Equal(pk, PkFromSig(sig, msg, pubSeed, adrs))
+// Verifies every item like VerifyBatch until ctx is done, see
+// wotsp.Params.VerifyBatchContext.
+func VerifyBatchContext(ctx context.Context, items []VerifyItem, workers int) ([]bool, error) {
+	return Params.VerifyBatchContext(ctx, items, workers)
 }
-
@@ -33,6 +33,19 @@ func TestAll(t *testing.T) {
 	}
 }
 
+func TestVerify_InvalidLength(t *testing.T) {
+	pubKey := GenPublicKey(testdata.Seed, testdata.PubSeed, &Address{})
+
+	if PkFromSig(testdata.Signature[:10], testdata.Message, testdata.PubSeed, &Address{}) != nil ||
+		Verify(pubKey, testdata.Signature[:10], testdata.Message, testdata.PubSeed, &Address{}) {
+		t.Fatal("Accepted truncated signature")
+	}
+	if PkFromSig(testdata.Signature, testdata.Message[:3], testdata.PubSeed, &Address{}) != nil ||
+		Verify(pubKey, testdata.Signature, testdata.Message[:3], testdata.PubSeed, &Address{}) {
+		t.Fatal("Accepted truncated message")
+	}
+}
+
 func BenchmarkGenPublicKey(b *testing.B) {
 	b.ReportAllocs()
 
@@ -0,0 +1,426 @@
+// Implements XMSS as specified by RFC 8391: a single Merkle tree over a fixed
+// set of 2^h WOTS+ keys, whose root is the long-term public key. Unlike the
+// Naor-Yung chains of XNYSS trees, an XMSS key can create a fixed amount of
+// signatures, known in advance, and its signatures and public keys can be
+// verified by any RFC 8391 implementation.
+//
+// Keys use the WOTS+ implementation of package wotsp with w=16, n=32 and
+// SHA-256, i.e. the XMSS-SHA2_h_256 parameter sets. The index of the next
+// unused WOTS+ key is part of the private key, which must be stored every time
+// it signs, before the signature is published: signing twice with the same
+// index reveals enough of the WOTS+ key to forge signatures.
+package xmss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/Re0h/xnyss/wotsp"
+)
+
+var (
+	ErrParamsUnknown = errors.New("unknown XMSS parameter set")
+	ErrKeyExhausted  = errors.New("all one-time keys of the XMSS key have been used")
+	ErrInvalidKey    = errors.New("invalid XMSS key encoding")
+	ErrInvalidSig    = errors.New("invalid XMSS signature encoding")
+)
+
+const n = 32
+
+// Domain separation prefixes of the keyed hash functions, see RFC 8391
+// section 5.1. F is computed by package wotsp.
+const (
+	padH    = 1
+	padHMsg = 2
+	padPRF  = 3
+)
+
+// Address types of the hash functions, see RFC 8391 section 2.5.
+const (
+	adrsOTS   = 0
+	adrsLTree = 1
+	adrsTree  = 2
+)
+
+// An XMSS parameter set: the height of the tree, and the identifier of the set
+// in encoded public keys.
+type Params struct {
+	OID    uint32
+	Height int
+}
+
+// The XMSS-SHA2_h_256 parameter sets of RFC 8391, which can create 2^h
+// signatures. Generating a key computes all 2^h WOTS+ public keys, which takes
+// about a second for every 2^10 keys.
+var (
+	SHA2_10_256 = Params{OID: 0x00000001, Height: 10}
+	SHA2_16_256 = Params{OID: 0x00000002, Height: 16}
+	SHA2_20_256 = Params{OID: 0x00000003, Height: 20}
+)
+
+var paramSets = []Params{SHA2_10_256, SHA2_16_256, SHA2_20_256}
+
+// Returns the parameter set identified by oid, or ErrParamsUnknown.
+func ParamsByOID(oid uint32) (Params, error) {
+	for _, p := range paramSets {
+		if p.OID == oid {
+			return p, nil
+		}
+	}
+
+	return Params{}, ErrParamsUnknown
+}
+
+// Returns the length of public keys: OID || root || SEED.
+func (p Params) PubKeyLen() int {
+	return 4 + 2*n
+}
+
+// Returns the length of signatures: idx || r || WOTS+ signature || auth path.
+func (p Params) SigLen() int {
+	return 4 + n + wotsp.SigLen + p.Height*n
+}
+
+// Returns the amount of signatures a key of p can create.
+func (p Params) Signatures() uint64 {
+	return 1 << uint(p.Height)
+}
+
+func (p Params) validate() error {
+	if q, err := ParamsByOID(p.OID); err != nil || q != p {
+		return ErrParamsUnknown
+	}
+
+	return nil
+}
+
+// An XMSS private key.
+type PrivateKey struct {
+	mu      sync.Mutex
+	params  Params
+	index   uint32
+	skSeed  []byte
+	skPRF   []byte
+	pubSeed []byte
+	root    []byte
+
+	// The nodes of the tree by height, computed when the key is generated or
+	// first used after loading it
+	levels [][]byte
+}
+
+// Generates a key with parameter set p, reading its 96 bytes of seeds from
+// rand.
+func GenerateKey(p Params, rand io.Reader) (*PrivateKey, error) {
+	seeds := make([]byte, 3*n)
+	if _, err := io.ReadFull(rand, seeds); err != nil {
+		return nil, err
+	}
+
+	return NewKey(p, seeds[:n], seeds[n:2*n], seeds[2*n:])
+}
+
+// Creates a key with parameter set p from the secret seeds skSeed and skPRF,
+// and the public seed pubSeed, each 32 bytes long.
+func NewKey(p Params, skSeed, skPRF, pubSeed []byte) (*PrivateKey, error) {
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if len(skSeed) != n || len(skPRF) != n || len(pubSeed) != n {
+		return nil, ErrInvalidKey
+	}
+
+	k := &PrivateKey{
+		params:  p,
+		skSeed:  append([]byte(nil), skSeed...),
+		skPRF:   append([]byte(nil), skPRF...),
+		pubSeed: append([]byte(nil), pubSeed...),
+	}
+	k.buildTree()
+	k.root = k.levels[p.Height]
+
+	return k, nil
+}
+
+// Returns the parameter set of the key k.
+func (k *PrivateKey) Params() Params {
+	return k.params
+}
+
+// Returns the encoded public key of k, as specified by RFC 8391.
+func (k *PrivateKey) PublicKey() []byte {
+	return encodePublicKey(k.params.OID, k.root, k.pubSeed)
+}
+
+// Returns the amount of signatures k can still create.
+func (k *PrivateKey) Remaining() uint64 {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return k.params.Signatures() - uint64(k.index)
+}
+
+// Signs msg with the next unused WOTS+ key of k. The private key changes with
+// every signature, so k must be stored (see Bytes) before the signature is
+// published. Returns ErrKeyExhausted once all keys have been used.
+func (k *PrivateKey) Sign(msg []byte) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if uint64(k.index) >= k.params.Signatures() {
+		return nil, ErrKeyExhausted
+	}
+	if k.levels == nil {
+		k.buildTree()
+	}
+
+	idx := k.index
+	k.index++
+
+	var idxBytes [32]byte
+	binary.BigEndian.PutUint32(idxBytes[28:], idx)
+	r := prf(k.skPRF, idxBytes[:])
+	digest := hashMsg(r, k.root, idx, msg)
+
+	sig := make([]byte, 4, k.params.SigLen())
+	binary.BigEndian.PutUint32(sig, idx)
+	sig = append(sig, r...)
+	sig = append(sig, wotsp.Sign(digest, k.leafSeed(idx), k.pubSeed, otsAddress(idx))...)
+	for h := 0; h < k.params.Height; h++ {
+		sibling := (idx >> uint(h)) ^ 1
+		sig = append(sig, k.levels[h][int(sibling)*n:int(sibling+1)*n]...)
+	}
+
+	return sig, nil
+}
+
+// Returns the encoding of the private key k: OID || idx || SK_SEED || SK_PRF ||
+// root || SEED. The tree is not stored; it is computed again when the loaded
+// key first signs.
+func (k *PrivateKey) Bytes() []byte {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	b := make([]byte, 8, 8+4*n)
+	binary.BigEndian.PutUint32(b, k.params.OID)
+	binary.BigEndian.PutUint32(b[4:], k.index)
+	b = append(b, k.skSeed...)
+	b = append(b, k.skPRF...)
+	b = append(b, k.root...)
+
+	return append(b, k.pubSeed...)
+}
+
+// Decodes a private key encoded by PrivateKey.Bytes.
+func LoadKey(b []byte) (*PrivateKey, error) {
+	if len(b) != 8+4*n {
+		return nil, ErrInvalidKey
+	}
+	p, err := ParamsByOID(binary.BigEndian.Uint32(b))
+	if err != nil {
+		return nil, err
+	}
+	index := binary.BigEndian.Uint32(b[4:])
+	if uint64(index) > p.Signatures() {
+		return nil, ErrInvalidKey
+	}
+
+	seeds := b[8:]
+
+	return &PrivateKey{
+		params:  p,
+		index:   index,
+		skSeed:  append([]byte(nil), seeds[:n]...),
+		skPRF:   append([]byte(nil), seeds[n:2*n]...),
+		root:    append([]byte(nil), seeds[2*n:3*n]...),
+		pubSeed: append([]byte(nil), seeds[3*n:4*n]...),
+	}, nil
+}
+
+// Computes all nodes of the tree of k.
+func (k *PrivateKey) buildTree() {
+	h := k.params.Height
+	k.levels = make([][]byte, h+1)
+
+	leaves := make([]byte, 0, (1<<uint(h))*n)
+	for i := uint32(0); i < 1<<uint(h); i++ {
+		pk := wotsp.GenPublicKey(k.leafSeed(i), k.pubSeed, otsAddress(i))
+		leaves = append(leaves, lTree(pk, k.pubSeed, i)...)
+	}
+	k.levels[0] = leaves
+
+	for height := 0; height < h; height++ {
+		below := k.levels[height]
+		level := make([]byte, 0, len(below)/2)
+		for j := 0; j < len(below)/(2*n); j++ {
+			adrs := treeAddress(uint32(height), uint32(j))
+			level = append(level, randHash(below[2*j*n:(2*j+1)*n], below[(2*j+1)*n:(2*j+2)*n], k.pubSeed, adrs)...)
+		}
+		k.levels[height+1] = level
+	}
+}
+
+// Returns the seed of WOTS+ key i, from which package wotsp expands the key.
+func (k *PrivateKey) leafSeed(i uint32) []byte {
+	var ctr [32]byte
+	binary.BigEndian.PutUint32(ctr[28:], i)
+
+	return prf(k.skSeed, ctr[:])
+}
+
+// Verifies that sig is a signature of msg by the XMSS public key pk, as
+// encoded by PrivateKey.PublicKey. Returns an error if pk or sig can not be
+// decoded.
+func Verify(pk, sig, msg []byte) (bool, error) {
+	if len(pk) != 4+2*n {
+		return false, ErrInvalidKey
+	}
+	p, err := ParamsByOID(binary.BigEndian.Uint32(pk))
+	if err != nil {
+		return false, err
+	}
+	if len(sig) != p.SigLen() {
+		return false, ErrInvalidSig
+	}
+	root, pubSeed := pk[4:4+n], pk[4+n:]
+
+	idx := binary.BigEndian.Uint32(sig)
+	if uint64(idx) >= p.Signatures() {
+		return false, nil
+	}
+	r := sig[4 : 4+n]
+	otsSig := sig[4+n : 4+n+wotsp.SigLen]
+	auth := sig[4+n+wotsp.SigLen:]
+
+	digest := hashMsg(r, root, idx, msg)
+	node := lTree(wotsp.PkFromSig(otsSig, digest, pubSeed, otsAddress(idx)), pubSeed, idx)
+	for h := 0; h < p.Height; h++ {
+		adrs := treeAddress(uint32(h), idx>>uint(h+1))
+		if (idx>>uint(h))&1 == 0 {
+			node = randHash(node, auth[h*n:(h+1)*n], pubSeed, adrs)
+		} else {
+			node = randHash(auth[h*n:(h+1)*n], node, pubSeed, adrs)
+		}
+	}
+
+	return bytes.Equal(node, root), nil
+}
+
+// Returns the index of the WOTS+ key that created sig, which verifiers can use
+// to detect reuse.
+func SigIndex(sig []byte) (uint32, error) {
+	if len(sig) < 4 {
+		return 0, ErrInvalidSig
+	}
+
+	return binary.BigEndian.Uint32(sig), nil
+}
+
+func encodePublicKey(oid uint32, root, pubSeed []byte) []byte {
+	pk := make([]byte, 4, 4+2*n)
+	binary.BigEndian.PutUint32(pk, oid)
+	pk = append(pk, root...)
+
+	return append(pk, pubSeed...)
+}
+
+// Compresses the WOTS+ public key pk of key i into a leaf of the tree, see
+// RFC 8391 algorithm 8.
+func lTree(pk, pubSeed []byte, i uint32) []byte {
+	nodes := make([][]byte, len(pk)/n)
+	for j := range nodes {
+		nodes[j] = pk[j*n : (j+1)*n]
+	}
+
+	for height := uint32(0); len(nodes) > 1; height++ {
+		next := make([][]byte, 0, (len(nodes)+1)/2)
+		for j := 0; j+1 < len(nodes); j += 2 {
+			adrs := lTreeAddress(i, height, uint32(j/2))
+			next = append(next, randHash(nodes[j], nodes[j+1], pubSeed, adrs))
+		}
+		if len(nodes)%2 == 1 {
+			next = append(next, nodes[len(nodes)-1])
+		}
+		nodes = next
+	}
+
+	return nodes[0]
+}
+
+// The keyed hash function RAND_HASH, see RFC 8391 algorithm 7. Word 7 of adrs
+// holds the key and mask selector.
+func randHash(left, right, pubSeed []byte, adrs [32]byte) []byte {
+	binary.BigEndian.PutUint32(adrs[28:], 0)
+	key := prf(pubSeed, adrs[:])
+	binary.BigEndian.PutUint32(adrs[28:], 1)
+	bm0 := prf(pubSeed, adrs[:])
+	binary.BigEndian.PutUint32(adrs[28:], 2)
+	bm1 := prf(pubSeed, adrs[:])
+
+	m := make([]byte, 2*n)
+	for j := 0; j < n; j++ {
+		m[j] = left[j] ^ bm0[j]
+		m[n+j] = right[j] ^ bm1[j]
+	}
+
+	return keyedHash(padH, key, m)
+}
+
+// Computes H_msg(r || root || toByte(idx, 32), msg).
+func hashMsg(r, root []byte, idx uint32, msg []byte) []byte {
+	var idxBytes [32]byte
+	binary.BigEndian.PutUint32(idxBytes[28:], idx)
+
+	key := make([]byte, 0, 3*n)
+	key = append(key, r...)
+	key = append(key, root...)
+	key = append(key, idxBytes[:]...)
+
+	return keyedHash(padHMsg, key, msg)
+}
+
+func prf(key, m []byte) []byte {
+	return keyedHash(padPRF, key, m)
+}
+
+// Computes SHA-256(toByte(pad, 32) || key || m).
+func keyedHash(pad uint32, key, m []byte) []byte {
+	var prefix [32]byte
+	binary.BigEndian.PutUint32(prefix[28:], pad)
+
+	h := sha256.New()
+	h.Write(prefix[:])
+	h.Write(key)
+	h.Write(m)
+
+	return h.Sum(nil)
+}
+
+func otsAddress(i uint32) *wotsp.Address {
+	adrs := &wotsp.Address{}
+	adrs.SetType(adrsOTS)
+	adrs.SetOTS(i)
+
+	return adrs
+}
+
+func lTreeAddress(i, height, index uint32) (adrs [32]byte) {
+	binary.BigEndian.PutUint32(adrs[12:], adrsLTree)
+	binary.BigEndian.PutUint32(adrs[16:], i)
+	binary.BigEndian.PutUint32(adrs[20:], height)
+	binary.BigEndian.PutUint32(adrs[24:], index)
+
+	return
+}
+
+func treeAddress(height, index uint32) (adrs [32]byte) {
+	binary.BigEndian.PutUint32(adrs[12:], adrsTree)
+	binary.BigEndian.PutUint32(adrs[20:], height)
+	binary.BigEndian.PutUint32(adrs[24:], index)
+
+	return
+}
@@ -0,0 +1,75 @@
+package xmss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestPrivateKey_Sign(t *testing.T) {
+	k, err := GenerateKey(SHA2_10_256, rand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate key -", err)
+	}
+	pk := k.PublicKey()
+	if len(pk) != SHA2_10_256.PubKeyLen() {
+		t.Fatal("Public key has length", len(pk))
+	}
+	msg := []byte("xmss test")
+
+	// 1 - Signatures use consecutive keys and verify
+	for i := 0; i < 3; i++ {
+		sig, err := k.Sign(msg)
+		if err != nil {
+			t.Fatal("Failed to sign -", err)
+		}
+		if len(sig) != SHA2_10_256.SigLen() {
+			t.Fatal("Signature has length", len(sig))
+		}
+		if idx, _ := SigIndex(sig); idx != uint32(i) {
+			t.Fatal("Signature", i, "used key", idx)
+		}
+		if ok, err := Verify(pk, sig, msg); !ok || err != nil {
+			t.Fatal("Failed to verify signature", i, "-", err)
+		}
+		if ok, _ := Verify(pk, sig, []byte("other")); ok {
+			t.Fatal("Verified signature of other message")
+		}
+	}
+	if k.Remaining() != SHA2_10_256.Signatures()-3 {
+		t.Fatal("Remaining signatures is", k.Remaining())
+	}
+
+	// 2 - Loaded keys continue at the stored index
+	loaded, err := LoadKey(k.Bytes())
+	if err != nil {
+		t.Fatal("Failed to load key -", err)
+	}
+	if !bytes.Equal(loaded.PublicKey(), pk) {
+		t.Fatal("Loaded key has another public key")
+	}
+	sig, err := loaded.Sign(msg)
+	if err != nil {
+		t.Fatal("Failed to sign with loaded key -", err)
+	}
+	if idx, _ := SigIndex(sig); idx != 3 {
+		t.Fatal("Loaded key reused key", idx)
+	}
+	if ok, err := Verify(pk, sig, msg); !ok || err != nil {
+		t.Fatal("Failed to verify signature of loaded key -", err)
+	}
+
+	// 3 - Exhausted keys refuse to sign
+	loaded.index = uint32(SHA2_10_256.Signatures())
+	if _, err := loaded.Sign(msg); err != ErrKeyExhausted {
+		t.Fatal("Signed with exhausted key, err was", err)
+	}
+
+	// 4 - Malformed keys and signatures are rejected
+	if _, err := Verify(pk, sig[1:], msg); err != ErrInvalidSig {
+		t.Fatal("Verified truncated signature, err was", err)
+	}
+	if _, err := Verify(pk[1:], sig, msg); err != ErrInvalidKey {
+		t.Fatal("Verified with truncated key, err was", err)
+	}
+}